@@ -24,21 +24,44 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
 	"github.com/Azure/azure-storage-azcopy/v10/ste"
+	"github.com/minio/minio-go"
 	"log"
+	"net"
+	"net/http"
 	"runtime"
+	"time"
 )
 
+func init() {
+	// Catch everything that uses http.DefaultTransport, and the S3 client, with the proxy that AzCopy's own
+	// transports use: the system's on Windows, and AZCOPY_PROXY or ALL_PROXY, which may be SOCKS5 ones, everywhere
+	http.DefaultTransport.(*http.Transport).Proxy = common.GlobalProxyLookup
+	minio.DefaultTransport.(*http.Transport).Proxy = common.GlobalProxyLookup
+}
+
 type Client struct {
 	CurrentJobID common.JobID // TODO (gapra): In future this should only be set when there is a current job running. On complete, this should be cleared. It can also behave as something we can check to see if a current job is running
 }
 
 type ClientOptions struct {
-	CapMbps float64
+	CapMbps           float64
+	CapBurstMB        float64                   // how much can go at once, above CapMbps, after a quiet spell; 0 for a second's worth
+	BandwidthSchedule *common.BandwidthSchedule // if not nil, changes the cap from CapMbps at the times it gives
 }
 
 func NewClient(opts ClientOptions) (Client, error) {
 	c := Client{}
 	common.InitializeFolders()
+	if config := common.ClientTLSConfig(); config != nil {
+		http.DefaultTransport.(*http.Transport).TLSClientConfig = config
+		minio.DefaultTransport.(*http.Transport).TLSClientConfig = common.ClientTLSConfig()
+	}
+	if common.DialingConfigured() {
+		// the same as the default transports' dialer, but with the source address, IP version and overrides that were given
+		dial := common.NewDialer(net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		http.DefaultTransport.(*http.Transport).DialContext = dial
+		minio.DefaultTransport.(*http.Transport).DialContext = dial
+	}
 	configureGoMaxProcs()
 	// Perform os specific initialization
 	azcopyMaxFileAndSocketHandles, err := processOSSpecificInitialization()
@@ -47,10 +70,14 @@ func NewClient(opts ClientOptions) (Client, error) {
 	}
 	// startup of the STE happens here, so that the startup can access the values of command line parameters that are defined for "root" command
 	concurrencySettings := ste.NewConcurrencySettings(azcopyMaxFileAndSocketHandles)
-	err = jobsAdmin.MainSTE(concurrencySettings, opts.CapMbps)
+	capMbps := jobsAdmin.InitialMbps(opts.BandwidthSchedule, opts.CapMbps)
+	err = jobsAdmin.MainSTE(concurrencySettings, capMbps, opts.CapBurstMB)
 	if err != nil {
 		return c, err
 	}
+	if opts.BandwidthSchedule != nil {
+		jobsAdmin.FollowBandwidthSchedule(opts.BandwidthSchedule, opts.CapMbps, capMbps)
+	}
 	return c, nil
 }
 
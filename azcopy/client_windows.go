@@ -21,10 +21,7 @@
 package azcopy
 
 import (
-	"github.com/Azure/azure-storage-azcopy/v10/common"
-	"github.com/minio/minio-go"
 	"math"
-	"net/http"
 )
 
 // processOSSpecificInitialization changes the soft limit for filedescriptor for process
@@ -39,9 +36,3 @@ func processOSSpecificInitialization() (int, error) {
 
 	return effectivelyUnlimited, nil
 }
-
-func init() {
-	//Catch everything that uses http.DefaultTransport with ieproxy.GetProxyFunc()
-	http.DefaultTransport.(*http.Transport).Proxy = common.GlobalProxyLookup
-	minio.DefaultTransport.(*http.Transport).Proxy = common.GlobalProxyLookup
-}
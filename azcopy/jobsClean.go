@@ -22,6 +22,8 @@ package azcopy
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
 )
@@ -29,21 +31,78 @@ import (
 // TODO (gapra) : Consider adding an onDelete callback to CleanJobsOptions? That way we can print to console as we clean jobs.
 type CleanJobsOptions struct {
 	WithStatus *common.JobStatus // Default: All
+	Retention  RetentionPolicy   // if any of its limits are set, only the jobs it selects are removed
+}
+
+// RetentionPolicy limits how many jobs' plan and log files are kept. A job is removed if any of the limits that are set
+// says so. Jobs that are still in progress, and the current job, are never removed.
+type RetentionPolicy struct {
+	OlderThan     time.Duration // remove the jobs that started longer ago than this
+	KeepLast      int           // remove all but the newest this many jobs
+	MaxTotalBytes int64         // keep only the newest jobs whose files, together, take up no more than this
+}
+
+// IsSet says whether any of the limits are set
+func (p RetentionPolicy) IsSet() bool {
+	return p.OlderThan > 0 || p.KeepLast > 0 || p.MaxTotalBytes > 0
+}
+
+// selectJobs returns the jobs that the policy removes, given how much space the files of each take up
+func (p RetentionPolicy) selectJobs(jobs []JobDetail, sizes map[common.JobID]int64, now time.Time) []common.JobID {
+	sortJobs(jobs) // newest first
+
+	var selected []common.JobID
+	var keptBytes int64
+	overBudget := false
+	for i, job := range jobs {
+		overBudget = overBudget || (p.MaxTotalBytes > 0 && keptBytes+sizes[job.JobID] > p.MaxTotalBytes)
+		if overBudget ||
+			(p.KeepLast > 0 && i >= p.KeepLast) ||
+			(p.OlderThan > 0 && job.StartTime.Before(now.Add(-p.OlderThan))) {
+			selected = append(selected, job.JobID)
+			continue
+		}
+		keptBytes += sizes[job.JobID]
+	}
+	return selected
 }
 
 type CleanJobsResult struct {
 	Count int            // Number of files cleaned
-	Jobs  []common.JobID // List of job IDs cleaned if WithStatus is not All, otherwise nil
+	Jobs  []common.JobID // List of job IDs cleaned if WithStatus is not All or Retention is set, otherwise nil
 }
 
 // CleanJobs removes jobs with a specified status.
 // If WithStatus is All, it cleans all jobs and returns the count of jobs cleaned.
 // If WithStatus is not All, it cleans jobs with that status and returns the count of jobs cleaned and list of job IDs cleaned.
+// If Retention is set, it cleans only the jobs with that status that the policy selects, and lists them too.
 func (c Client) CleanJobs(opts CleanJobsOptions) (result CleanJobsResult, err error) {
 	result = CleanJobsResult{}
 	status := common.IffNil(opts.WithStatus, common.EJobStatus.All())
 
-	if status == common.EJobStatus.All() {
+	if opts.Retention.IsSet() {
+		resp := jobsAdmin.ListJobs(status)
+		if resp.ErrorMessage != "" {
+			return result, fmt.Errorf("failed to list jobs due to error: %s", resp.ErrorMessage)
+		}
+		var candidates []JobDetail
+		for _, job := range resp.JobIDDetails {
+			if job.JobId == c.CurrentJobID || job.JobStatus == common.EJobStatus.InProgress() {
+				continue
+			}
+			candidates = append(candidates, JobDetail{JobID: job.JobId, StartTime: time.Unix(0, job.StartTime), Status: job.JobStatus})
+		}
+
+		result.Jobs = []common.JobID{}
+		for _, jobID := range opts.Retention.selectJobs(candidates, jobsAdmin.JobFileSizes(), time.Now()) {
+			count, err := jobsAdmin.RemoveSingleJobFiles(jobID)
+			if err != nil {
+				return result, fmt.Errorf("failed to remove job %s due to error: %w", jobID, err)
+			}
+			result.Jobs = append(result.Jobs, jobID)
+			result.Count += count
+		}
+	} else if status == common.EJobStatus.All() {
 		result.Count, err = jobsAdmin.DeleteAllJobFilesExceptCurrent(c.CurrentJobID)
 	} else {
 		resp := jobsAdmin.ListJobs(status)
@@ -0,0 +1,42 @@
+package azcopy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionPolicySelectJobs(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Now()
+	var jobs []JobDetail
+	sizes := map[common.JobID]int64{}
+	for age := 0; age < 5; age++ { // jobs[i] started i days ago, and its files take up i+1 MB
+		job := JobDetail{JobID: common.NewJobID(), StartTime: now.Add(-time.Duration(age) * 24 * time.Hour)}
+		jobs = append(jobs, job)
+		sizes[job.JobID] = int64(age+1) << 20
+	}
+	ids := func(indexes ...int) []common.JobID {
+		var result []common.JobID
+		for _, i := range indexes {
+			result = append(result, jobs[i].JobID)
+		}
+		return result
+	}
+	shuffled := func() []JobDetail {
+		return []JobDetail{jobs[3], jobs[0], jobs[4], jobs[2], jobs[1]}
+	}
+
+	a.Nil(RetentionPolicy{}.selectJobs(shuffled(), sizes, now))
+	a.Equal(ids(3, 4), RetentionPolicy{KeepLast: 3}.selectJobs(shuffled(), sizes, now))
+	a.Equal(ids(3, 4), RetentionPolicy{OlderThan: 60 * time.Hour}.selectJobs(shuffled(), sizes, now))
+
+	// the newest jobs that fit are kept: 1+2+3 MB fit in 7 MB, and once one doesn't fit, nor do any older ones
+	a.Equal(ids(3, 4), RetentionPolicy{MaxTotalBytes: 7 << 20}.selectJobs(shuffled(), sizes, now))
+
+	// a job is removed if any limit says so
+	a.Equal(ids(1, 2, 3, 4), RetentionPolicy{KeepLast: 4, MaxTotalBytes: 2 << 20}.selectJobs(shuffled(), sizes, now))
+}
@@ -0,0 +1,73 @@
+// Copyright © 2025 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package azcopy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+type JobHistoryOptions struct {
+	WithStatus *common.JobStatus // Default: All
+	Since      time.Time         // if set, only the jobs that started since then
+	Command    string            // if set, only the jobs whose command starts with this, e.g. "sync" or "copy"
+	Last       int               // if set, only the newest this many of the jobs that match
+}
+
+type JobHistoryResponse struct {
+	Jobs []jobsAdmin.JobHistoryRecord // newest first
+}
+
+// JobHistory returns the finished jobs in the job history that match the options
+func (c Client) JobHistory(opts JobHistoryOptions) (result JobHistoryResponse, err error) {
+	records, err := jobsAdmin.ReadJobHistory()
+	if err != nil {
+		return result, fmt.Errorf("failed to read the job history due to error: %w", err)
+	}
+	return JobHistoryResponse{Jobs: filterJobHistory(records, opts)}, nil
+}
+
+func filterJobHistory(records []jobsAdmin.JobHistoryRecord, opts JobHistoryOptions) []jobsAdmin.JobHistoryRecord {
+	status := common.IffNil(opts.WithStatus, common.EJobStatus.All())
+
+	jobs := []jobsAdmin.JobHistoryRecord{}
+	for i := len(records) - 1; i >= 0; i-- { // the history is oldest first
+		r := records[i]
+		if status != common.EJobStatus.All() && r.Status != status.String() {
+			continue
+		}
+		if !opts.Since.IsZero() && r.StartTime.Before(opts.Since) {
+			continue
+		}
+		if opts.Command != "" && !strings.HasPrefix(r.Command, opts.Command) {
+			continue
+		}
+		jobs = append(jobs, r)
+		if opts.Last > 0 && len(jobs) == opts.Last {
+			break
+		}
+	}
+	return jobs
+}
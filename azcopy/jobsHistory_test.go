@@ -0,0 +1,101 @@
+package azcopy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobHistory(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	// there's no history until a job finishes
+	resp, err := Client{}.JobHistory(JobHistoryOptions{})
+	a.NoError(err)
+	a.Empty(resp.Jobs)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var records []jobsAdmin.JobHistoryRecord
+	for i, command := range []string{"copy a b", "sync a b", "copy c d", "sync c d"} {
+		record := jobsAdmin.JobHistoryRecord{
+			JobID:     common.NewJobID(),
+			Command:   command,
+			Flags:     []string{"recursive"},
+			Status:    common.Iff(i%2 == 0, common.EJobStatus.Completed(), common.EJobStatus.CompletedWithErrors()).String(),
+			StartTime: start.Add(time.Duration(i) * 24 * time.Hour),
+		}
+		a.NoError(jobsAdmin.RecordJobHistory(record))
+		records = append(records, record)
+	}
+
+	// a line cut short is left out
+	f, err := os.OpenFile(filepath.Join(common.AzcopyJobPlanFolder, "history.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	a.NoError(err)
+	_, _ = f.WriteString(`{"JobID": "`)
+	a.NoError(f.Close())
+
+	resp, err = Client{}.JobHistory(JobHistoryOptions{})
+	a.NoError(err)
+	a.Equal([]jobsAdmin.JobHistoryRecord{records[3], records[2], records[1], records[0]}, resp.Jobs)
+
+	filtered := filterJobHistory(records, JobHistoryOptions{WithStatus: to.Ptr(common.EJobStatus.Completed())})
+	a.Equal([]jobsAdmin.JobHistoryRecord{records[2], records[0]}, filtered)
+
+	filtered = filterJobHistory(records, JobHistoryOptions{Command: "sync", Since: start.Add(time.Hour)})
+	a.Equal([]jobsAdmin.JobHistoryRecord{records[3], records[1]}, filtered)
+
+	filtered = filterJobHistory(records, JobHistoryOptions{Last: 1})
+	a.Equal([]jobsAdmin.JobHistoryRecord{records[3]}, filtered)
+}
+
+func TestJobHistoryLongLineAndPruning(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+	t.Setenv(common.EEnvironmentVariable.JobHistoryKeepLast().Name, "10")
+	path := filepath.Join(common.AzcopyJobPlanFolder, "history.jsonl")
+
+	// a line longer than any buffer doesn't hide the jobs after it
+	a.NoError(os.WriteFile(path, []byte(`{"Command": "`+strings.Repeat("x", 2*1024*1024)+"\n"), 0644))
+	var records []jobsAdmin.JobHistoryRecord
+	for i := 0; i < 11; i++ {
+		record := jobsAdmin.JobHistoryRecord{JobID: common.NewJobID(), Command: "copy a b"}
+		a.NoError(jobsAdmin.RecordJobHistory(record))
+		records = append(records, record)
+	}
+	history, err := jobsAdmin.ReadJobHistory()
+	a.NoError(err)
+	a.Equal(records, history)
+
+	// the history is only readable by its owner, even if it was written by an earlier version
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		a.NoError(err)
+		a.Equal(os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	// once it's more than a tenth over, only the newest jobs are kept
+	record := jobsAdmin.JobHistoryRecord{JobID: common.NewJobID(), Command: "copy a b"}
+	a.NoError(jobsAdmin.RecordJobHistory(record))
+	records = append(records, record)
+	history, err = jobsAdmin.ReadJobHistory()
+	a.NoError(err)
+	a.Equal(records[len(records)-10:], history)
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		a.NoError(err)
+		a.Equal(os.FileMode(0600), fi.Mode().Perm())
+	}
+}
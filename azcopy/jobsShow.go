@@ -69,3 +69,21 @@ func (c Client) ListJobTransfers(opts ListJobTransfersOptions) (result ListJobTr
 	}
 	return ListJobTransfersResponse(resp), nil
 }
+
+type ExportJobPlanOptions struct {
+	JobID common.JobID
+}
+
+type ExportJobPlanResponse jobsAdmin.ExportedJobPlan
+
+// ExportJobPlan returns everything the plan files of the job with the specified JobID record about it
+func (c Client) ExportJobPlan(opts ExportJobPlanOptions) (result ExportJobPlanResponse, err error) {
+	if opts.JobID.IsEmpty() {
+		return result, errors.New("export job plan requires the JobID")
+	}
+	plan, err := jobsAdmin.ExportJobPlan(opts.JobID)
+	if err != nil {
+		return result, fmt.Errorf("failed to export the plan of job %s due to error: %w", opts.JobID, err)
+	}
+	return ExportJobPlanResponse(plan), nil
+}
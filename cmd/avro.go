@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// avroSchema is a parsed Avro schema: a primitive type's name, a named type, or a union, array, map or record
+type avroSchema struct {
+	kind    string // null, boolean, int, long, float, double, bytes, string, record, enum, array, map, fixed or union
+	fields  []avroField
+	symbols []string
+	items   *avroSchema // of an array or map
+	size    int         // of a fixed
+	union   []*avroSchema
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+// parseAvroSchema parses the JSON of a schema. Named types are kept in named, so that later references to them,
+// by name or full name, can be resolved.
+func parseAvroSchema(raw json.RawMessage, named map[string]*avroSchema, namespace string) (*avroSchema, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		switch name {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{kind: name}, nil
+		}
+		if s, ok := named[name]; ok {
+			return s, nil
+		}
+		if s, ok := named[namespace+"."+name]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown Avro type %q", name)
+	}
+
+	var branches []json.RawMessage
+	if err := json.Unmarshal(raw, &branches); err == nil {
+		s := &avroSchema{kind: "union"}
+		for _, b := range branches {
+			branch, err := parseAvroSchema(b, named, namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.union = append(s.union, branch)
+		}
+		return s, nil
+	}
+
+	var complex struct {
+		Type      json.RawMessage `json:"type"`
+		Name      string          `json:"name"`
+		Namespace string          `json:"namespace"`
+		Fields    []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+		Symbols []string        `json:"symbols"`
+		Items   json.RawMessage `json:"items"`
+		Values  json.RawMessage `json:"values"`
+		Size    int             `json:"size"`
+	}
+	if err := json.Unmarshal(raw, &complex); err != nil {
+		return nil, err
+	}
+	var kind string
+	if err := json.Unmarshal(complex.Type, &kind); err != nil {
+		// as in {"type": {"type": "array", ...}}
+		return parseAvroSchema(complex.Type, named, namespace)
+	}
+
+	s := &avroSchema{kind: kind, symbols: complex.Symbols, size: complex.Size}
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		if complex.Namespace != "" {
+			namespace = complex.Namespace
+		}
+		// a record's fields may refer to the record itself
+		named[complex.Name] = s
+		named[namespace+"."+complex.Name] = s
+	}
+	switch kind {
+	case "record", "error":
+		s.kind = "record"
+		for _, f := range complex.Fields {
+			fs, err := parseAvroSchema(f.Type, named, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			s.fields = append(s.fields, avroField{name: f.Name, schema: fs})
+		}
+	case "array", "map":
+		items := complex.Items
+		if kind == "map" {
+			items = complex.Values
+		}
+		var err error
+		if s.items, err = parseAvroSchema(items, named, namespace); err != nil {
+			return nil, err
+		}
+	case "fixed":
+		if s.size < 0 {
+			return nil, fmt.Errorf("fixed %s has a negative size", complex.Name)
+		}
+	case "enum":
+	default:
+		// a primitive type, perhaps with a logicalType that only says how to take it
+		return parseAvroSchema(complex.Type, named, namespace)
+	}
+	return s, nil
+}
+
+// avroMaxBlockSize is the most that a block of a container file is decompressed to, so that a damaged or hostile file
+// can't have a small block take all of memory
+const avroMaxBlockSize = 64 * 1024 * 1024
+
+type avroDecoder struct {
+	r interface {
+		io.Reader
+		io.ByteReader
+	}
+}
+
+func (d avroDecoder) long() (int64, error) {
+	return binary.ReadVarint(d.r) // Avro's ints and longs are zig-zag varints, as Go's are
+}
+
+// left returns how many bytes are left to decode, if that's known, as it is within a block of a container file
+func (d avroDecoder) left() (int64, bool) {
+	if r, ok := d.r.(*bytes.Reader); ok {
+		return int64(r.Len()), true
+	}
+	return 0, false
+}
+
+// read reads n bytes. Where it isn't known how many are left, they're read as they come, rather than allocated up
+// front, so that a length that's been damaged fails once the bytes run out.
+func (d avroDecoder) read(n int64) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("negative Avro length")
+	} else if left, ok := d.left(); ok && n > left {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf, err := io.ReadAll(io.LimitReader(d.r, n))
+	if err == nil && int64(len(buf)) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf, err
+}
+
+func (d avroDecoder) bytes() ([]byte, error) {
+	n, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	return d.read(n)
+}
+
+// blocks reads the blocks of an array or map, calling item for each item in them
+func (d avroDecoder) blocks(item func() error) error {
+	for {
+		n, err := d.long()
+		if err != nil || n == 0 {
+			return err
+		}
+		if n < 0 {
+			// the block's size in bytes follows, for skipping it
+			n = -n
+			if _, err = d.long(); err != nil {
+				return err
+			}
+		}
+		if left, ok := d.left(); ok && n > left {
+			return io.ErrUnexpectedEOF // each item takes a byte at least
+		}
+		for ; n > 0; n-- {
+			if err = item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// avroMaxDepth is how deeply values may be nested, so that a schema whose records hold themselves can't recurse forever
+const avroMaxDepth = 64
+
+// decode reads a value of the given schema. Records and maps are decoded as map[string]any, arrays as []any, ints and
+// longs as int64, floats and doubles as float64, enums as their symbol, and bytes and fixeds as []byte.
+func (d avroDecoder) decode(s *avroSchema) (any, error) {
+	return d.value(s, 0)
+}
+
+func (d avroDecoder) value(s *avroSchema, depth int) (any, error) {
+	if depth > avroMaxDepth {
+		return nil, fmt.Errorf("Avro values are nested more than %d deep", avroMaxDepth)
+	}
+	switch s.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.r.ReadByte()
+		return b != 0, err
+	case "int", "long":
+		return d.long()
+	case "float":
+		var buf [4]byte
+		_, err := io.ReadFull(d.r, buf[:])
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[:]))), err
+	case "double":
+		var buf [8]byte
+		_, err := io.ReadFull(d.r, buf[:])
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), err
+	case "bytes":
+		return d.bytes()
+	case "string":
+		b, err := d.bytes()
+		return string(b), err
+	case "fixed":
+		return d.read(int64(s.size))
+	case "enum":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		} else if i < 0 || int(i) >= len(s.symbols) {
+			return nil, fmt.Errorf("Avro enum index %d is out of range", i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := d.long()
+		if err != nil {
+			return nil, err
+		} else if i < 0 || int(i) >= len(s.union) {
+			return nil, fmt.Errorf("Avro union index %d is out of range", i)
+		}
+		return d.value(s.union[i], depth+1)
+	case "array":
+		items := []any{}
+		err := d.blocks(func() error {
+			v, err := d.value(s.items, depth+1)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		m := map[string]any{}
+		err := d.blocks(func() error {
+			k, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			m[string(k)], err = d.value(s.items, depth+1)
+			return err
+		})
+		return m, err
+	case "record":
+		m := make(map[string]any, len(s.fields))
+		for _, f := range s.fields {
+			v, err := d.value(f.schema, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown Avro type %q", s.kind)
+}
+
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// readAvroContainer calls fn with each object in an Avro object container file, such as the change feed's chunks are,
+// decoded as avroDecoder.decode does. Objects that aren't compressed, or are compressed with deflate, can be read.
+func readAvroContainer(r io.Reader, fn func(any) error) error {
+	d := avroDecoder{r: bufio.NewReader(r)}
+	magic := make([]byte, len(avroMagic))
+	if _, err := io.ReadFull(d.r, magic); err != nil || !bytes.Equal(magic, avroMagic) {
+		return errors.New("not an Avro object container file")
+	}
+	header, err := d.decode(&avroSchema{kind: "map", items: &avroSchema{kind: "bytes"}})
+	if err != nil {
+		return err
+	}
+	meta := header.(map[string]any)
+	schemaJSON, _ := meta["avro.schema"].([]byte)
+	schema, err := parseAvroSchema(schemaJSON, map[string]*avroSchema{}, "")
+	if err != nil {
+		return fmt.Errorf("the Avro schema can't be read: %w", err)
+	}
+	codec, _ := meta["avro.codec"].([]byte)
+	if c := string(codec); c != "" && c != "null" && c != "deflate" {
+		return fmt.Errorf("the Avro codec %s isn't supported", c)
+	}
+	var sync [16]byte
+	if _, err = io.ReadFull(d.r, sync[:]); err != nil {
+		return err
+	}
+
+	for {
+		count, err := d.long()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		block, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		if string(codec) == "deflate" {
+			if block, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(block)), avroMaxBlockSize+1)); err != nil {
+				return err
+			} else if len(block) > avroMaxBlockSize {
+				return fmt.Errorf("the Avro file has a block of more than %d bytes", avroMaxBlockSize)
+			}
+		}
+		if count < 0 || count > int64(len(block)) {
+			return fmt.Errorf("the Avro file is damaged: a block of %d bytes can't have %d objects", len(block), count)
+		}
+		objects := avroDecoder{r: bytes.NewReader(block)}
+		for ; count > 0; count-- {
+			v, err := objects.decode(schema)
+			if err != nil {
+				return err
+			}
+			if err = fn(v); err != nil {
+				return err
+			}
+		}
+		var marker [16]byte
+		if _, err = io.ReadFull(d.r, marker[:]); err != nil {
+			return err
+		} else if marker != sync {
+			return errors.New("the Avro file is damaged: a block doesn't end with its sync marker")
+		}
+	}
+}
@@ -343,7 +343,7 @@ func init() {
 		"If true, then the benchmark data will be deleted at the end of the benchmark run.  \n"+
 			"Set it to false if you want to keep the data at the destination - \n e.g. to use it for manual tests outside benchmark mode")
 	benchCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0,
-		"Use this block size (specified in MiB). The default is automatically calculated based on file size.\n"+
+		"Use this block size (specified in MiB). The default is chosen for each file, from its size, the measured throughput and the available memory.\n"+
 			" Decimal fractions are allowed - e.g. 0.25. \nIdentical to the same-named parameter in the copy command")
 	benchCmd.PersistentFlags().Float64Var(&raw.putBlobSizeMB, "put-blob-size-mb", 0,
 		"Use this size (specified in MiB) as a threshold to determine whether to upload a blob as\n"+
@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const (
+	changeFeedContainer     = "$blobchangefeed"
+	changeFeedSegmentPrefix = "idx/segments/"
+	changeFeedSegmentLength = time.Hour
+)
+
+// changeFeedSync runs sync --change-feed, which compares only the paths that the Blob change feed of the source's
+// account says have changed since the last sync, instead of the whole source and destination. The first sync compares
+// everything, and records how far the change feed had got when it started; each sync after it reads the changes from
+// there on. The change feed only has changes once they're an hour or so old, so the latest changes are left for the
+// sync after; and changes that are read again, or are already at the destination, are found to be in sync, as usual.
+type changeFeedSync struct {
+	path      string
+	container string
+	prefix    string // the source's path in its container, with a / on the end unless it's the whole container
+	dryrun    bool
+
+	cursor time.Time // how far the change feed had got when the last sync started; zero before the first sync
+	next   time.Time // where the next sync carries on from, if this one succeeds
+}
+
+// the content of the cursor file
+type changeFeedCursor struct {
+	Cursor time.Time
+}
+
+func changeFeedCursorPath(source, destination common.ResourceString) string {
+	// the SAS isn't part of the name, since it may be renewed between one sync and the next
+	sum := sha256.Sum256([]byte(source.Value + "?" + source.ExtraQuery + "\n" + destination.Value + "?" + destination.ExtraQuery))
+	return filepath.Join(common.AzcopyJobPlanFolder, "changefeed", hex.EncodeToString(sum[:])+".json")
+}
+
+// newChangeFeedSync returns where the last sync of the source to the destination left the change feed, or nil if
+// the sync doesn't use the change feed
+func newChangeFeedSync(cca *cookedSyncCmdArgs) (*changeFeedSync, error) {
+	if !cca.changeFeed {
+		return nil, nil
+	}
+	parts, err := blob.ParseURL(cca.source.Value)
+	if err != nil {
+		return nil, err
+	}
+	f := &changeFeedSync{
+		path:      changeFeedCursorPath(cca.source, cca.destination),
+		container: parts.ContainerName,
+		prefix:    parts.BlobName,
+		dryrun:    cca.dryrunMode,
+	}
+	if f.prefix != "" && !strings.HasSuffix(f.prefix, "/") {
+		f.prefix += "/"
+	}
+
+	content, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cursor changeFeedCursor
+	if err = json.Unmarshal(content, &cursor); err != nil {
+		// the sync compares everything, as it did the first time
+		common.LogToJobLogWithPrefix("The record of how far the change feed had got is damaged, so the whole source is compared: "+err.Error(), common.LogWarning)
+		return f, nil
+	}
+	f.cursor = cursor.Cursor
+	return f, nil
+}
+
+// changedPaths returns the paths, relative to the source, that the change feed has changes to since the last sync,
+// or nil if there was no last sync, and everything is to be compared
+func (f *changeFeedSync) changedPaths(ctx context.Context, sourceClient *common.ServiceClient) ([]string, error) {
+	bsc, err := sourceClient.BlobServiceClient()
+	if err != nil {
+		return nil, err
+	}
+	feed := bsc.NewContainerClient(changeFeedContainer)
+
+	f.next, err = changeFeedLastConsumable(ctx, feed)
+	if bloberror.HasCode(err, bloberror.ContainerNotFound, bloberror.BlobNotFound) {
+		return nil, errors.New("the source's account has no change feed; enable it in the account's data protection settings")
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't read the change feed: %w", err)
+	}
+	if f.cursor.IsZero() {
+		glcm.Info("Comparing the whole source, since it hasn't been synced with --change-feed before")
+		return nil, nil
+	}
+
+	changes := changeFeedChanges{}
+	segments, err := changeFeedSegments(ctx, feed, f.cursor, f.next)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the change feed: %w", err)
+	}
+	for _, segment := range segments {
+		if err = readChangeFeedSegment(ctx, feed, segment, func(event any) {
+			changes.add(event, f.container, f.prefix)
+		}); err != nil {
+			return nil, fmt.Errorf("couldn't read the change feed: %w", err)
+		}
+	}
+
+	paths := changes.paths()
+	message := fmt.Sprintf("Comparing the %d paths that the change feed has changes to since %s", len(paths), f.cursor.Format(time.RFC3339))
+	glcm.Info(message)
+	common.LogToJobLogWithPrefix(message, common.LogInfo)
+	return paths, nil
+}
+
+// save records where the next sync carries on reading the change feed from, if this one has succeeded. Otherwise the
+// next sync reads the changes that this one did again.
+func (f *changeFeedSync) save(succeeded bool) {
+	if f == nil || f.dryrun || !succeeded || f.next.IsZero() {
+		return
+	}
+	content, err := json.Marshal(changeFeedCursor{Cursor: f.next})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(f.path), os.ModePerm)
+	}
+	if err == nil {
+		// written aside and renamed, so that an interruption can't leave half a cursor
+		temp := f.path + ".tmp"
+		if err = os.WriteFile(temp, content, 0644); err == nil {
+			err = os.Rename(temp, f.path)
+		}
+	}
+	if err != nil {
+		common.LogToJobLogWithPrefix("Couldn't record how far the change feed had got, so the next sync will read the same changes again: "+err.Error(), common.LogWarning)
+	}
+}
+
+func downloadChangeFeedBlob(ctx context.Context, feed *container.Client, name string) (io.ReadCloser, error) {
+	resp, err := feed.NewBlobClient(name).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// changeFeedLastConsumable is the time before which the change feed has all the changes to the account
+func changeFeedLastConsumable(ctx context.Context, feed *container.Client) (time.Time, error) {
+	body, err := downloadChangeFeedBlob(ctx, feed, "meta/segments.json")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer body.Close()
+	var meta struct {
+		LastConsumable time.Time `json:"lastConsumable"`
+	}
+	err = json.NewDecoder(body).Decode(&meta)
+	return meta.LastConsumable, err
+}
+
+// changeFeedSegmentTime is when the segment with the given meta.json, as in idx/segments/2019/02/22/1800/meta.json, begins
+func changeFeedSegmentTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, changeFeedSegmentPrefix) || !strings.HasSuffix(name, "/meta.json") {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006/01/02/1504", strings.TrimSuffix(strings.TrimPrefix(name, changeFeedSegmentPrefix), "/meta.json"))
+	return t, err == nil
+}
+
+// changeFeedSegments lists the segments of the change feed with changes in it from from until to
+func changeFeedSegments(ctx context.Context, feed *container.Client, from, to time.Time) ([]string, error) {
+	var segments []string
+	prefix := changeFeedSegmentPrefix
+	pager := feed.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			if begins, ok := changeFeedSegmentTime(*item.Name); ok && begins.Add(changeFeedSegmentLength).After(from) && begins.Before(to) {
+				segments = append(segments, *item.Name)
+			}
+		}
+	}
+	return segments, nil
+}
+
+// readChangeFeedSegment calls fn with each of the events in the segment with the given meta.json
+func readChangeFeedSegment(ctx context.Context, feed *container.Client, segment string, fn func(event any)) error {
+	body, err := downloadChangeFeedBlob(ctx, feed, segment)
+	if err != nil {
+		return err
+	}
+	var meta struct {
+		ChunkFilePaths []string `json:"chunkFilePaths"`
+	}
+	err = json.NewDecoder(body).Decode(&meta)
+	_ = body.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, chunkPath := range meta.ChunkFilePaths {
+		prefix := strings.TrimPrefix(chunkPath, changeFeedContainer+"/")
+		pager := feed.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, item := range page.Segment.BlobItems {
+				if item.Name == nil {
+					continue
+				}
+				chunk, err := downloadChangeFeedBlob(ctx, feed, *item.Name)
+				if err != nil {
+					return err
+				}
+				err = readAvroContainer(chunk, func(event any) error {
+					fn(event)
+					return nil
+				})
+				_ = chunk.Close()
+				if err != nil {
+					return fmt.Errorf("%s: %w", *item.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// changeFeedChanges is the set of paths in the source that have changed, with whether each is a directory, all of
+// which may have changed
+type changeFeedChanges map[string]bool
+
+// add records the path that a change feed event is about, if it's in the source. Renames are about both the path that
+// was renamed and its new path.
+func (c changeFeedChanges) add(event any, containerName, prefix string) {
+	e, _ := event.(map[string]any)
+	eventType, _ := e["eventType"].(string)
+	isDir := strings.HasPrefix(eventType, "Directory")
+
+	addPath := func(eventContainer, name string) {
+		var relativePath string
+		switch {
+		case eventContainer != containerName:
+			return
+		case name+"/" == prefix:
+			relativePath = "" // the source itself
+		case strings.HasPrefix(name, prefix):
+			relativePath = strings.TrimSuffix(strings.TrimPrefix(name, prefix), "/")
+		default:
+			return
+		}
+		c[relativePath] = c[relativePath] || isDir
+	}
+
+	// as in /blobServices/default/containers/container/blobs/dir/file
+	subject, _ := e["subject"].(string)
+	if rest, ok := strings.CutPrefix(subject, "/blobServices/default/containers/"); ok {
+		if eventContainer, name, ok := strings.Cut(rest, "/blobs/"); ok {
+			addPath(eventContainer, name)
+		}
+	}
+	data, _ := e["data"].(map[string]any)
+	if destinationURL, _ := data["destinationUrl"].(string); destinationURL != "" {
+		if parts, err := blob.ParseURL(destinationURL); err == nil {
+			addPath(parts.ContainerName, parts.BlobName)
+		}
+	}
+}
+
+// paths is the paths that have changed, leaving out those in directories that have changed, since everything in them
+// is compared
+func (c changeFeedChanges) paths() []string {
+	paths := make([]string, 0, len(c))
+	for p := range c {
+		covered := false
+		for dir := p; dir != "" && !covered; {
+			if dir = path.Dir(dir); dir == "." {
+				dir = ""
+			}
+			covered = c[dir]
+		}
+		if !covered {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// newChangedPathsTraverser returns a traverser of the given paths under resource, for which a path that isn't there
+// is empty
+func newChangedPathsTraverser(resource common.ResourceString, location common.Location, ctx context.Context, options InitResourceTraverserOptions, paths []string) ResourceTraverser {
+	list := make(chan string, len(paths))
+	for _, p := range paths {
+		list <- p
+	}
+	close(list)
+	options.ListOfFiles = list
+
+	t := newListTraverser(resource, location, ctx, options).(*listTraverser)
+	generate := t.childTraverserGenerator
+	t.childTraverserGenerator = func(childPath string) (ResourceTraverser, error) {
+		child, err := generate(childPath)
+		if err != nil {
+			return nil, err
+		}
+		return &missingIsEmptyTraverser{ResourceTraverser: child}, nil
+	}
+	return t
+}
+
+// missingIsEmptyTraverser finds nothing where there's nothing to find, instead of failing
+type missingIsEmptyTraverser struct {
+	ResourceTraverser
+}
+
+func (t *missingIsEmptyTraverser) IsDirectory(isSource bool) (bool, error) {
+	isDir, err := t.ResourceTraverser.IsDirectory(isSource)
+	if isMissingResourceError(err) {
+		return false, nil
+	}
+	return isDir, err
+}
+
+func (t *missingIsEmptyTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	if err := t.ResourceTraverser.Traverse(preprocessor, processor, filters); !isMissingResourceError(err) {
+		return err
+	}
+	return nil
+}
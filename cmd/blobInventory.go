@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+// blobInventoryManifest is the manifest that a blob inventory run writes beside its reports, which says what rule the
+// reports were made with, and which files they're in
+type blobInventoryManifest struct {
+	Files []struct {
+		Blob string `json:"blob"`
+		Size int64  `json:"size"`
+	} `json:"files"`
+	InventoryCompletionTime time.Time `json:"inventoryCompletionTime"`
+	RuleDefinition          struct {
+		Filters struct {
+			PrefixMatch         []string `json:"prefixMatch"`
+			ExcludePrefix       []string `json:"excludePrefix"`
+			IncludeBlobVersions bool     `json:"includeBlobVersions"`
+		} `json:"filters"`
+		Format       string   `json:"format"`
+		ObjectType   string   `json:"objectType"`
+		SchemaFields []string `json:"schemaFields"`
+	} `json:"ruleDefinition"`
+	Status string `json:"status"`
+}
+
+// the fields of the inventory that a blob can't be listed without
+var requiredInventoryFields = []string{"Name", "Last-Modified", "Content-Length"}
+
+// blobInventoryTraverser lists a Blob container, or a directory in one, from the report of a blob inventory run, given
+// by --from-inventory, instead of listing it live. That saves the hours a listing of a container of billions of blobs
+// takes, at the price of listing the blobs as they were when the inventory was taken: blobs made since then aren't
+// listed, and those changed since have the properties they had then. Downloads of blobs that have changed fail, since
+// they're only downloaded if they haven't been modified since the time they're listed with.
+//
+// The blobs' properties are those in the report, so for them to be preserved, the inventory's rule has to have their
+// fields in its schema; those it hasn't are left empty.
+type blobInventoryTraverser struct {
+	container string
+	prefix    string // of the blobs in the source, with a / on the end unless it's the whole container
+
+	recursive                   bool
+	includeDirStubs             bool
+	incrementEnumerationCounter enumerationCounterFunc
+
+	// rows reads the inventory's report, calling fn with each row of it
+	rows func(fn func(inventoryRow) error) error
+}
+
+func newBlobInventoryTraverser(rawURL string, serviceClient *service.Client, ctx context.Context, opts InitResourceTraverserOptions, clientOptions *blob.ClientOptions) (*blobInventoryTraverser, error) {
+	source, err := blob.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	t := &blobInventoryTraverser{
+		container:                   source.ContainerName,
+		prefix:                      source.BlobName,
+		recursive:                   opts.Recursive,
+		includeDirStubs:             opts.IncludeDirectoryStubs,
+		incrementEnumerationCounter: opts.IncrementEnumeration,
+	}
+	if t.prefix != "" && !strings.HasSuffix(t.prefix, common.AZCOPY_PATH_SEPARATOR_STRING) {
+		t.prefix += common.AZCOPY_PATH_SEPARATOR_STRING
+	}
+
+	manifestURL, err := blob.ParseURL(opts.InventoryManifest)
+	if err != nil {
+		return nil, fmt.Errorf("the inventory manifest's URL is invalid: %w", err)
+	}
+	// the reports are beside the manifest, in the container the inventory was written to
+	var report func(name string) (*blob.Client, error)
+	if manifestURL.SAS.Signature() != "" {
+		report = func(name string) (*blob.Client, error) {
+			parts := manifestURL
+			parts.BlobName = name
+			return blob.NewClientWithNoCredential(parts.String(), clientOptions)
+		}
+	} else if strings.EqualFold(manifestURL.Host, source.Host) {
+		inventoryContainer := serviceClient.NewContainerClient(manifestURL.ContainerName)
+		report = func(name string) (*blob.Client, error) {
+			return inventoryContainer.NewBlobClient(name), nil
+		}
+	} else {
+		return nil, errors.New("the inventory manifest isn't in the source's account, so give its URL with a SAS for the container the inventory is in")
+	}
+
+	manifest, err := readBlobInventoryManifest(ctx, report, manifestURL.BlobName)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the inventory manifest: %w", err)
+	}
+	if err = t.checkCovers(manifest); err != nil {
+		return nil, err
+	}
+	t.rows = func(fn func(inventoryRow) error) error {
+		return readBlobInventoryReports(ctx, report, manifest, fn)
+	}
+
+	message := fmt.Sprintf("Listing the source from the blob inventory completed at %s, so blobs changed since then are listed as they were then",
+		manifest.InventoryCompletionTime.Format(time.RFC3339))
+	glcm.Info(message)
+	common.LogToJobLogWithPrefix(message, common.LogInfo)
+	return t, nil
+}
+
+func readBlobInventoryManifest(ctx context.Context, report func(name string) (*blob.Client, error), name string) (*blobInventoryManifest, error) {
+	client, err := report(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	manifest := &blobInventoryManifest{}
+	if err = json.NewDecoder(resp.Body).Decode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// checkCovers checks that the inventory has every blob in the source that it can list
+func (t *blobInventoryTraverser) checkCovers(m *blobInventoryManifest) error {
+	rule := m.RuleDefinition
+	if !strings.EqualFold(m.Status, "Succeeded") {
+		return fmt.Errorf("the inventory's status is %s, so it may not have every blob in it", m.Status)
+	}
+	if !strings.EqualFold(rule.ObjectType, "Blob") {
+		return errors.New("the inventory is of containers, not blobs")
+	}
+	if f := strings.ToLower(rule.Format); f != "csv" && f != "parquet" {
+		return fmt.Errorf("the inventory's format, %s, isn't supported", rule.Format)
+	}
+	for _, field := range requiredInventoryFields {
+		if !hasInventoryField(rule.SchemaFields, field) {
+			return fmt.Errorf("the inventory has no %s field, which blobs can't be listed without", field)
+		}
+	}
+	if rule.Filters.IncludeBlobVersions && !hasInventoryField(rule.SchemaFields, "IsCurrentVersion") {
+		return errors.New("the inventory has blob versions in it, but no IsCurrentVersion field to tell the current ones by")
+	}
+
+	// the rule's prefixes are of blob names with their container's name in front
+	source := t.container + "/" + t.prefix
+	covered := len(rule.Filters.PrefixMatch) == 0
+	for _, p := range rule.Filters.PrefixMatch {
+		covered = covered || strings.HasPrefix(source, p)
+	}
+	if !covered {
+		return fmt.Errorf("the inventory only has blobs starting with %s in it, which the source isn't within", strings.Join(rule.Filters.PrefixMatch, ", "))
+	}
+	for _, p := range rule.Filters.ExcludePrefix {
+		if strings.HasPrefix(source, p) {
+			return fmt.Errorf("the inventory leaves out blobs starting with %s, which the source is within", p)
+		} else if strings.HasPrefix(p, source) {
+			glcm.Warn(fmt.Sprintf("The inventory leaves out blobs starting with %s, so they aren't listed", p))
+		}
+	}
+	return nil
+}
+
+func hasInventoryField(fields []string, field string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBlobInventoryReports calls fn with each row of each of the inventory's report files, in turn
+func readBlobInventoryReports(ctx context.Context, report func(name string) (*blob.Client, error), m *blobInventoryManifest, fn func(inventoryRow) error) error {
+	for _, f := range m.Files {
+		client, err := report(f.Blob)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(m.RuleDefinition.Format, "parquet") {
+			err = readParquet(blobReaderAt{ctx: ctx, client: client}, f.Size, func(row map[string]any) error {
+				return fn(row)
+			})
+		} else {
+			var resp blob.DownloadStreamResponse
+			if resp, err = client.DownloadStream(ctx, nil); err == nil {
+				body := resp.NewRetryReader(ctx, &blob.RetryReaderOptions{MaxRetries: ste.MaxRetryPerDownloadBody})
+				err = readInventoryCSV(body, fn)
+				_ = body.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read the inventory report %s: %w", f.Blob, err)
+		}
+	}
+	return nil
+}
+
+// blobReaderAt reads ranges of a blob, for readParquet, which reads a file's metadata from its end before its data
+type blobReaderAt struct {
+	ctx    context.Context
+	client *blob.Client
+}
+
+func (b blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	resp, err := b.client.DownloadStream(b.ctx, &blob.DownloadStreamOptions{Range: blob.HTTPRange{Offset: off, Count: int64(len(p))}})
+	if err != nil {
+		return 0, err
+	}
+	body := resp.NewRetryReader(b.ctx, &blob.RetryReaderOptions{MaxRetries: ste.MaxRetryPerDownloadBody})
+	defer body.Close()
+	return io.ReadFull(body, p)
+}
+
+// readInventoryCSV calls fn with each row of a CSV report, whose first row names its columns
+func readInventoryCSV(r io.Reader, fn func(inventoryRow) error) error {
+	records := csv.NewReader(bufio.NewReader(r))
+	records.ReuseRecord = true
+	header, err := records.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.TrimPrefix(name, "\ufeff") // a byte order mark
+	}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		row := make(inventoryRow, len(columns))
+		for i, name := range columns {
+			row[name] = record[i]
+		}
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// inventoryRow is a row of an inventory report: its fields by name, as strings in a CSV report, or as readParquet
+// reads them from a Parquet one
+type inventoryRow map[string]any
+
+func (r inventoryRow) text(field string) string {
+	switch v := r[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (r inventoryRow) textPtr(field string) *string {
+	if v := r.text(field); v != "" {
+		return &v
+	}
+	return nil
+}
+
+func (r inventoryRow) bool(field string) bool {
+	if v, ok := r[field].(bool); ok {
+		return v
+	}
+	return strings.EqualFold(r.text(field), "true")
+}
+
+func (r inventoryRow) int(field string) (int64, error) {
+	if v, ok := r[field].(int64); ok {
+		return v, nil
+	}
+	return strconv.ParseInt(r.text(field), 10, 64)
+}
+
+func (r inventoryRow) time(field string) (time.Time, error) {
+	if v, ok := r[field].(time.Time); ok {
+		return v, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, r.text(field))
+	if err != nil {
+		if t, err = time.Parse(http.TimeFormat, r.text(field)); err != nil {
+			return time.Time{}, fmt.Errorf("%s isn't a time: %s", field, r.text(field))
+		}
+	}
+	return t, nil
+}
+
+// md5 is the row's Content-MD5, which is base64 encoded in CSV reports, and may be bytes in Parquet ones
+func (r inventoryRow) md5() []byte {
+	v := r.text("Content-MD5")
+	if md5, err := base64.StdEncoding.DecodeString(v); err == nil && len(md5) == 16 {
+		return md5
+	} else if len(v) == 16 {
+		return []byte(v)
+	}
+	return nil
+}
+
+// metadata is the row's Metadata, which is a JSON object of the blob's metadata
+func (r inventoryRow) metadata() (common.Metadata, error) {
+	v := strings.TrimSpace(r.text("Metadata"))
+	if v == "" {
+		return common.Metadata{}, nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(v), &values); err != nil {
+		return nil, fmt.Errorf("its Metadata can't be read: %w", err)
+	}
+	meta := make(common.Metadata, len(values))
+	for k, value := range values {
+		meta[k] = &value
+	}
+	return meta, nil
+}
+
+// object makes the StoredObject of the blob in a row, or returns false if the row isn't of a blob in the source that
+// is listed
+func (t *blobInventoryTraverser) object(preprocessor objectMorpher, row inventoryRow) (StoredObject, bool, error) {
+	name, ok := strings.CutPrefix(row.text("Name"), t.container+"/")
+	if !ok {
+		return StoredObject{}, false, nil
+	}
+	relativePath, ok := strings.CutPrefix(name, t.prefix)
+	if !ok || relativePath == "" || (!t.recursive && strings.Contains(relativePath, common.AZCOPY_PATH_SEPARATOR_STRING)) {
+		return StoredObject{}, false, nil
+	}
+	// only the current version of a blob is listed, as it is when listed live
+	if row.bool("Deleted") || row.text("Snapshot") != "" || (row.text("VersionId") != "" && !row.bool("IsCurrentVersion")) {
+		return StoredObject{}, false, nil
+	}
+
+	lmt, err := row.time("Last-Modified")
+	if err != nil {
+		return StoredObject{}, false, err
+	}
+	size, err := row.int("Content-Length")
+	if err != nil {
+		return StoredObject{}, false, fmt.Errorf("its Content-Length isn't a number: %w", err)
+	}
+	meta, err := row.metadata()
+	if err != nil {
+		return StoredObject{}, false, err
+	}
+	entityType := getEntityType(meta)
+	if row.bool("hdi_isfolder") {
+		entityType = common.EEntityType.Folder()
+	}
+	if entityType == common.EEntityType.Folder() && !t.includeDirStubs {
+		// as when listed live, folders are left out unless they're asked for
+		return StoredObject{}, false, nil
+	}
+
+	props := &container.BlobProperties{
+		LastModified:       &lmt,
+		ContentLength:      &size,
+		ContentMD5:         row.md5(),
+		ContentType:        row.textPtr("Content-Type"),
+		ContentEncoding:    row.textPtr("Content-Encoding"),
+		ContentLanguage:    row.textPtr("Content-Language"),
+		ContentDisposition: row.textPtr("Content-Disposition"),
+		CacheControl:       row.textPtr("Cache-Control"),
+		EncryptionScope:    row.textPtr("EncryptionScope"),
+	}
+	if v := row.text("BlobType"); v != "" {
+		props.BlobType = to.Ptr(blob.BlobType(v))
+	}
+	if v := row.text("AccessTier"); v != "" {
+		props.AccessTier = to.Ptr(blob.AccessTier(v))
+	}
+	adapter := blobPropertiesAdapter{props}
+	obj := newStoredObject(preprocessor, getObjectNameOnly(name), relativePath, entityType, lmt, size, adapter, adapter, meta, t.container)
+	obj.blobEncryptionScope = common.IffNotNil(props.EncryptionScope, "")
+	return obj, true, nil
+}
+
+func (t *blobInventoryTraverser) IsDirectory(bool) (bool, error) {
+	// the source is a container, or a directory in one, since a single blob is listed without an inventory
+	return true, nil
+}
+
+func (t *blobInventoryTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	return t.rows(func(row inventoryRow) error {
+		obj, ok, err := t.object(preprocessor, row)
+		if err != nil {
+			return fmt.Errorf("the inventory's row for %s can't be read: %w", row.text("Name"), err)
+		} else if !ok {
+			return nil
+		}
+		if t.incrementEnumerationCounter != nil {
+			t.incrementEnumerationCounter(obj.entityType)
+		}
+		err = processIfPassedFilters(filters, obj, processor)
+		_, err = getProcessingError(err)
+		return err
+	})
+}
@@ -21,7 +21,22 @@ package cmd
 
 // Common Error and Info messages
 const (
-	PreservePOSIXPropertiesIncompatibilityMsg = "to use the --preserve-posix-properties flag, both the source and destination must be POSIX-aware. Valid combinations are: Linux -> Blob, Blob -> Linux, or Blob -> Blob"
+	PreservePOSIXPropertiesIncompatibilityMsg = "to use the --preserve-posix-properties flag, both the source and destination must be POSIX-aware. Valid combinations are: Linux or FreeBSD -> Blob, Blob -> Linux or FreeBSD, or Blob -> Blob"
+	PreserveACLsIncompatibilityMsg            = "to use the --preserve-acls flag, both the source and destination must be ACL-aware. Valid combinations are: FreeBSD -> Blob, Blob -> FreeBSD, or Blob -> Blob"
+	PreserveExtAttrsIncompatibilityMsg        = "to use the --preserve-extattrs flag, both the source and destination must support extended attributes. Valid combinations are: FreeBSD -> Blob, Blob -> FreeBSD, or Blob -> Blob"
+	PreserveFileFlagsIncompatibilityMsg       = "to use the --preserve-file-flags flag, both the source and destination must support BSD file flags. Valid combinations are: FreeBSD -> Blob, Blob -> FreeBSD, or Blob -> Blob"
+	OneFileSystemIncompatibilityMsg           = "the --one-file-system flag only applies to local sources"
+	ExcludeNodumpIncompatibilityMsg           = "the --exclude-nodump flag only applies to local sources on FreeBSD"
+	PackSmallFilesIncompatibilityMsg          = "the --pack-small-files-kb flag only applies to uploads from local files to Blob storage"
+	ExtractPacksIncompatibilityMsg            = "the --extract-packs flag only applies to downloads from Blob storage"
+	ExtractArchivesIncompatibilityMsg         = "the --untar and --unzip flags only apply to downloads to local files"
+	CompressIncompatibilityMsg                = "the --compress flag only applies to uploads from local files to Blob storage"
+	SandboxIncompatibilityMsg                 = "the --sandbox flag only applies to uploads from local files to Blob storage"
+	ClientSideEncryptionIncompatibilityMsg    = "the --client-side-encryption-key flag only applies to uploads from local files to Blob storage, and downloads from Blob storage to local files"
+	EncryptionScopeIncompatibilityMsg         = "the --encryption-scope flag only applies to uploads and copies to Blob storage"
+	PreserveEncryptionScopeIncompatibilityMsg = "the --s2s-preserve-encryption-scope flag only applies to copies from Blob storage to Blob storage"
+	HashAlgorithmIncompatibilityMsg           = "hashes other than MD5 can only be checked when downloading, or created with --put-md5 when uploading from local files to Blob storage"
+	CpkS2SDestinationOnlyMsg                  = "Client Provided Key (CPK) applies to the destination of a service-to-service copy. Blobs encrypted with a client provided key can't be the source of one, so download and upload them instead."
 	PreservePermissionsDisabledMsg            = "Note: The preserve-permissions flag is set to false. As a result, AzCopy will not copy SMB ACLs between the source and destination. For more information, visit: https://aka.ms/AzCopyandAzureFiles."
 
 	PreserveNFSPermissionsDisabledMsg = "Note: The preserve-permissions flag is set to false. As a result, AzCopy will not copy NFS permissions between the source and destination."
@@ -35,8 +50,33 @@ const (
 	PreserveSMBPermissionsFlag = "preserve-smb-permissions"
 	PreservePermissionsFlag    = "preserve-permissions"
 	PreserveInfoFlag           = "preserve-info"
+	PreserveACLsFlag           = "preserve-acls"
+	PreserveExtAttrsFlag       = "preserve-extattrs"
+	PreserveFileFlagsFlag      = "preserve-file-flags"
 	IsNFSProtocolFlag          = "nfs"
 	HardlinksFlag              = "hardlinks"
+	FromZFSSnapshotFlag        = "from-zfs-snapshot"
+	OneFileSystemFlag          = "one-file-system"
+	SymlinksFlag               = "symlinks"
+	SpecialFilesFlag           = "special-files"
+	ExcludeNodumpFlag          = "exclude-nodump"
+	TransferReportFlag         = "transfer-report"
+	TransferReportFormatFlag   = "transfer-report-format"
+	NotifyWebhookFlag          = "notify-webhook"
+	NotifyEmailFlag            = "notify-email"
+	PreHookFlag                = "pre-hook"
+	PostHookFlag               = "post-hook"
+	OrderFlag                  = "order"
+	ScanConcurrencyFlag        = "scan-concurrency"
+	TraversalFlag              = "traversal"
+	ContinueJobFlag            = "continue-job"
+	SyncStateCacheFlag         = "sync-state-cache"
+	LockDestinationFlag        = "lock-destination"
+	ChangeFeedFlag             = "change-feed"
+	FromInventoryFlag          = "from-inventory"
+	BlockDeltaFlag             = "block-delta"
+	ContentDefinedBlocksFlag   = "content-defined-blocks"
+	FsyncFlag                  = "fsync"
 )
 
 const (
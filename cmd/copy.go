@@ -29,6 +29,7 @@ import (
 	"math"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -75,12 +76,30 @@ type rawCopyCmdArgs struct {
 
 	// Indicates the user wants to upload the symlink itself, not the file on the other end
 	preserveSymlinks bool
+	// one of skip, follow or preserve; supersedes the two booleans above
+	symlinks string
 
 	// filters from flags
 	listOfFilesToCopy string
+	listOfURLs        string
 	recursive         bool
 	followSymlinks    bool
+	oneFileSystem     bool
+	excludeNodump     bool
+	specialFiles      string
+	order             string
+	traversal         string
 	autoDecompress    bool
+	packSmallFilesKB  uint32
+	packSizeMB        uint32
+	extractPacks      bool
+	untar             bool
+	unzip             bool
+	compress          string
+	clientSideKey     string
+	blockDelta        bool
+	// split files into blocks where their content says, for --block-delta, which it implies
+	contentDefinedBlocks bool
 	// forceWrite flag is used to define the User behavior
 	// to overwrite the existing blobs or not.
 	forceWrite      string
@@ -99,9 +118,17 @@ type rawCopyCmdArgs struct {
 	preserveLastModifiedTime bool
 	putMd5                   bool
 	md5ValidationOption      string
+	hashAlgorithm            string
+	fsync                    string
 	CheckLength              bool
 	deleteSnapshotsOption    string
 	dryrun                   bool
+	transferReport           string
+	transferReportFormat     string
+	notifyWebhook            string
+	notifyEmail              string
+	preHook                  string
+	postHook                 string
 
 	blobTags string
 	// defines the type of the blob at the destination in case of upload / account to account copy
@@ -115,6 +142,7 @@ type rawCopyCmdArgs struct {
 	preserveSMBPermissions bool
 	preservePermissions    bool // Separate flag so that we don't get funkiness with two "flags" targeting the same boolean
 	preserveOwner          bool // works in conjunction with preserveSmbPermissions
+	idmapFile              string
 	// Default true; false indicates that the destination is the target directory, rather than something we'd put a directory under (e.g. a container)
 	asSubdir bool
 	// Opt-in flag to persist additional SMB properties to Azure Files. Named ...info instead of ...properties
@@ -122,10 +150,38 @@ type rawCopyCmdArgs struct {
 	preserveSMBInfo bool
 	// Opt-in flag to persist additional POSIX properties
 	preservePOSIXProperties bool
+	// Opt-in flag to persist POSIX.1e/NFSv4 ACLs into object metadata
+	preserveACLs bool
+	// Opt-in flag to persist FreeBSD extended attributes into object metadata
+	preserveExtAttrs bool
+	// Opt-in flag to persist BSD file flags (chflags) into object metadata
+	preserveFileFlags bool
 	// Opt-in flag to preserve the blob index tags during service to service transfer.
 	s2sPreserveBlobTags bool
 	// Flag to enable Window's special privileges
 	backupMode bool
+	// Flag to reserve disk space for downloaded files before writing them
+	preallocate bool
+	// How to keep uploaded files from filling the page cache or ZFS ARC
+	bypassCache string
+	// Flag to write downloaded files with kernel AIO
+	aioWrites bool
+	// Flag to lock files while they're downloaded
+	lockFiles bool
+	// Flag to upload from capsicum(4)'s capability mode
+	sandbox bool
+	// How many chunks of each file to download ahead of where it has been written up to
+	prefetchChunks uint32
+	// Flag to upload local files from memory mappings of them
+	mmapUploads bool
+	// How many directories to read at once when scanning, or 0 for AZCOPY_CONCURRENT_SCAN's number
+	scanConcurrency uint32
+	// ID of the job whose interrupted scan to carry on, which jobs resume gives (see scanCheckpoint.go)
+	continueJob string
+	// Name of a ZFS snapshot to upload from, or "auto" to take one for the duration of the job
+	fromZFSSnapshot string
+	// URL of the manifest of a blob inventory to list a Blob source from, instead of listing it live
+	fromInventory string
 	// whether user wants to preserve full properties during service to service copy, the default value is true.
 	// For S3 and Azure File non-single file source, as list operation doesn't return full properties of objects/files,
 	// to preserve full properties AzCopy needs to send one additional request per object/file.
@@ -160,6 +216,10 @@ type rawCopyCmdArgs struct {
 	// Key is present in AzureKeyVault and Azure KeyVault is linked with storage account.
 	// Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data
 	cpkScopeInfo string
+	// Encryption scope that uploaded or copied blobs are encrypted with
+	encryptionScope string
+	// Opt-in flag to encrypt copied blobs with the encryption scope of their source
+	s2sPreserveEncryptionScope bool
 
 	// Optional flag that permanently deletes soft-deleted snapshots/versions
 	permanentDeleteOption string
@@ -204,6 +264,12 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 		Recursive:                raw.recursive,
 		ForceIfReadOnly:          raw.forceIfReadOnly,
 		autoDecompress:           raw.autoDecompress,
+		keepCompressed:           cpCmd.Flags().Changed("decompress") && !raw.autoDecompress,
+		packFilesSmallerThan:     int64(raw.packSmallFilesKB) * common.KiloByte,
+		packSize:                 int64(raw.packSizeMB) * common.MegaByte,
+		extractArchivePacks:      raw.extractPacks,
+		untar:                    raw.untar,
+		unzip:                    raw.unzip,
 		BlockSizeMB:              raw.blockSizeMB,
 		PutBlobSizeMB:            raw.putBlobSizeMB,
 		ListOfFiles:              raw.listOfFilesToCopy,
@@ -224,10 +290,25 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 		putMd5:                   raw.putMd5,
 		CheckLength:              raw.CheckLength,
 		preserveOwner:            raw.preserveOwner,
+		idmapFile:                raw.idmapFile,
 
 		asSubdir:              raw.asSubdir, // --as-subdir is OK on all sources and destinations, but additional verification has to be done down the line. (e.g. https://account.blob.core.windows.net is not a valid root)
 		IncludeDirectoryStubs: raw.includeDirectoryStubs,
 		backupMode:            raw.backupMode,
+		preallocate:           raw.preallocate,
+		bypassCache:           raw.bypassCache,
+		aioWrites:             raw.aioWrites,
+		lockFiles:             raw.lockFiles,
+		sandbox:               raw.sandbox,
+		prefetchChunks:        raw.prefetchChunks,
+		mmapUploads:           raw.mmapUploads,
+		scanConcurrency:       raw.scanConcurrency,
+		continueJob:           raw.continueJob != "",
+		OneFileSystem:         raw.oneFileSystem,
+		excludeNodump:         raw.excludeNodump,
+		skippedSpecialFiles:   &specialFileReport{},
+		zfsSnapshotName:       raw.fromZFSSnapshot,
+		fromInventory:         raw.fromInventory,
 		s2sPreserveProperties: boolDefaultTrue{
 			value:         raw.s2sPreserveProperties,
 			isManuallySet: cpCmd.Flags().Changed("s2s-preserve-properties"),
@@ -239,6 +320,12 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 		s2sGetPropertiesInBackend:        raw.s2sGetPropertiesInBackend,
 		s2sSourceChangeValidation:        raw.s2sSourceChangeValidation,
 		dryrunMode:                       raw.dryrun,
+		transferReportPath:               raw.transferReport,
+		transferReportFormat:             raw.transferReportFormat,
+		notifyWebhook:                    raw.notifyWebhook,
+		notifyEmail:                      raw.notifyEmail,
+		preHook:                          raw.preHook,
+		postHook:                         raw.postHook,
 		deleteDestinationFileIfNecessary: raw.deleteDestinationFileIfNecessary,
 	}
 
@@ -262,8 +349,9 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 
 	// Source
 	tempSrc := raw.src
-	// Check if source has a trailing wildcard on a URL
-	if cooked.FromTo.From().IsRemote() {
+	// Check if source has a trailing wildcard on a URL. Provider URLs aren't storage service URLs, and can't have wildcards,
+	// and a URL list is a local file.
+	if from := cooked.FromTo.From(); from.IsRemote() && from != common.ELocation.Provider() && from != common.ELocation.URLList() {
 		tempSrc, cooked.StripTopDir, err = stripTrailingWildcardOnRemoteSource(raw.src, cooked.FromTo.From())
 
 		if err != nil {
@@ -278,6 +366,10 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 	if raw.internalOverrideStripTopDir {
 		cooked.StripTopDir = true
 	}
+	// the objects in a list of URLs go straight into the destination, not into a folder named after the list
+	if cooked.FromTo.From() == common.ELocation.URLList() {
+		cooked.StripTopDir = true
+	}
 	// cooked.StripTopDir is effectively a workaround for the lack of wildcards in remote sources.
 	// Local, however, still supports wildcards, and thus needs its top directory stripped whenever a wildcard is used.
 	// Thus, we check for wildcards and instruct the processor to strip the top dir later instead of repeatedly checking cca.Source for wildcards.
@@ -285,7 +377,7 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 		cooked.StripTopDir = true
 	}
 
-	if err = cooked.SymlinkHandling.Determine(raw.followSymlinks, raw.preserveSymlinks); err != nil {
+	if err = cooked.SymlinkHandling.Determine(raw.followSymlinks, raw.preserveSymlinks, raw.symlinks); err != nil {
 		return cooked, err
 	}
 
@@ -299,6 +391,23 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 		return cooked, err
 	}
 
+	cooked.uploadCompression, err = common.ParseUploadCompression(strings.ToLower(raw.compress))
+	if err != nil {
+		return cooked, err
+	}
+
+	cooked.clientSideKey = raw.clientSideKey
+	cooked.blockDelta = raw.blockDelta || raw.contentDefinedBlocks
+	cooked.contentDefinedBlocks = raw.contentDefinedBlocks
+	if cooked.clientSideKey != "" && !common.IsKeyVaultKey(cooked.clientSideKey) {
+		// the key file is found again by its absolute path if the job is resumed, which may be from elsewhere
+		if cooked.clientSideKey, err = filepath.Abs(cooked.clientSideKey); err != nil {
+			return cooked, err
+		}
+	}
+	cooked.encryptionScope = raw.encryptionScope
+	cooked.s2sPreserveEncryptionScope = raw.s2sPreserveEncryptionScope
+
 	err = cooked.blockBlobTier.Parse(raw.blockBlobTier)
 	if err != nil {
 		return cooked, err
@@ -360,6 +469,14 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 	if err != nil {
 		return cooked, err
 	}
+	if raw.hashAlgorithm != "" {
+		if err = cooked.hashAlgorithm.Parse(raw.hashAlgorithm); err != nil {
+			return cooked, err
+		}
+	}
+	if err = cooked.fsync.Parse(raw.fsync); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use per-file, per-chunk, interval:N or off", FsyncFlag, raw.fsync)
+	}
 
 	// length of devnull will be 0, thus this will always fail unless downloading an empty file
 	if cooked.Destination.Value == common.Dev_Null {
@@ -383,9 +500,33 @@ func (raw *rawCopyCmdArgs) toOptions() (cooked CookedCopyCmdArgs, err error) {
 	} else {
 		cooked.preserveInfo = raw.preserveInfo && areBothLocationsSMBAware(cooked.FromTo)
 		cooked.preservePOSIXProperties = raw.preservePOSIXProperties
+		cooked.preserveACLs = raw.preserveACLs
+		cooked.preserveExtAttrs = raw.preserveExtAttrs
+		cooked.preserveFileFlags = raw.preserveFileFlags
 		cooked.preservePermissions = common.NewPreservePermissionsOption(raw.preservePermissions,
 			raw.preserveOwner,
 			cooked.FromTo)
+		if err = cooked.hardlinks.Parse(raw.hardlinks); err != nil {
+			return cooked, err
+		}
+	}
+
+	if err = cooked.specialFiles.Parse(raw.specialFiles); err != nil {
+		return cooked, err
+	}
+
+	if err = cooked.order.Parse(raw.order); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use as-scanned, smallest-first or largest-first", OrderFlag, raw.order)
+	}
+
+	if err = cooked.traversal.Parse(raw.traversal); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use auto, breadth-first or depth-first", TraversalFlag, raw.traversal)
+	}
+
+	// The POSIXHardlinkMeta of a blob is only acted on when asked to, since anyone who can write the container can set it
+	if cooked.hardlinks == common.EHardlinkHandlingType.Preserve() && cooked.FromTo.To() == common.ELocation.Local() &&
+		cooked.Destination.Value != common.Dev_Null {
+		cooked.deferredHardlinks = newDeferredHardlinks(cooked.Destination.ValueLocal())
 	}
 
 	// TODO: Figure out this preservePermissinos stuff
@@ -453,6 +594,7 @@ func (raw *rawCopyCmdArgs) setMandatoryDefaults() {
 	raw.blockBlobTier = common.EBlockBlobTier.None().String()
 	raw.pageBlobTier = common.EPageBlobTier.None().String()
 	raw.md5ValidationOption = common.DefaultHashValidationOption.String()
+	raw.hashAlgorithm = common.EContentHashType.MD5().String()
 	raw.s2sInvalidMetadataHandleOption = common.DefaultInvalidMetadataHandleOption.String()
 	raw.forceWrite = common.EOverwriteOption.True().String()
 	raw.preserveOwner = common.PreserveOwnerDefault
@@ -475,7 +617,7 @@ func areBothLocationsPOSIXAware(fromTo common.FromTo) bool {
 	// POSIX properties are stored in blob metadata-- They don't need a special persistence strategy for S2S methods.
 	switch fromTo {
 	case common.EFromTo.BlobLocal(), common.EFromTo.LocalBlob(), common.EFromTo.BlobFSLocal(), common.EFromTo.LocalBlobFS():
-		return runtime.GOOS == "linux"
+		return runtime.GOOS == "linux" || runtime.GOOS == "freebsd"
 	case common.EFromTo.BlobBlob(), common.EFromTo.BlobFSBlobFS(), common.EFromTo.BlobFSBlob(), common.EFromTo.BlobBlobFS():
 		return true
 	default:
@@ -483,6 +625,42 @@ func areBothLocationsPOSIXAware(fromTo common.FromTo) bool {
 	}
 }
 
+// areBothLocationsFreeBSDMetadataAware covers properties that, so far, only the FreeBSD build knows how to read and write locally.
+func areBothLocationsFreeBSDMetadataAware(fromTo common.FromTo) bool {
+	// Like POSIX properties, these travel in blob metadata, so S2S needs nothing special.
+	switch fromTo {
+	case common.EFromTo.BlobLocal(), common.EFromTo.LocalBlob():
+		return runtime.GOOS == "freebsd"
+	case common.EFromTo.BlobBlob():
+		return true
+	case common.EFromTo.LocalLocal():
+		return runtime.GOOS == "freebsd" // copied directly, without going through metadata
+	default:
+		return false
+	}
+}
+
+func validatePreserveACLs(preserve bool, fromTo common.FromTo) error {
+	if preserve && !areBothLocationsFreeBSDMetadataAware(fromTo) {
+		return errors.New(PreserveACLsIncompatibilityMsg)
+	}
+	return nil
+}
+
+func validatePreserveExtAttrs(preserve bool, fromTo common.FromTo) error {
+	if preserve && !areBothLocationsFreeBSDMetadataAware(fromTo) {
+		return errors.New(PreserveExtAttrsIncompatibilityMsg)
+	}
+	return nil
+}
+
+func validatePreserveFileFlags(preserve bool, fromTo common.FromTo) error {
+	if preserve && !areBothLocationsFreeBSDMetadataAware(fromTo) {
+		return errors.New(PreserveFileFlagsIncompatibilityMsg)
+	}
+	return nil
+}
+
 func validatePreserveOwner(preserve bool, fromTo common.FromTo) error {
 	if fromTo.IsDownload() {
 		return nil // it can be used in downloads
@@ -493,6 +671,16 @@ func validatePreserveOwner(preserve bool, fromTo common.FromTo) error {
 	return nil
 }
 
+func validateIDMapFile(idmapFile string, preserveOwner bool, fromTo common.FromTo) error {
+	if idmapFile == "" {
+		return nil
+	}
+	if !fromTo.IsDownload() || !preserveOwner {
+		return fmt.Errorf("flag --%s can only be used on downloads that preserve the owner (--%s)", common.IDMapFileFlagName, common.PreserveOwnerFlagName)
+	}
+	return nil
+}
+
 func validateSymlinkHandlingMode(symlinkHandling common.SymlinkHandlingType, fromTo common.FromTo) error {
 	if symlinkHandling.Preserve() {
 		switch fromTo {
@@ -501,13 +689,157 @@ func validateSymlinkHandlingMode(symlinkHandling common.SymlinkHandlingType, fro
 		case common.EFromTo.BlobBlob(), common.EFromTo.BlobFSBlobFS(), common.EFromTo.BlobBlobFS(), common.EFromTo.BlobFSBlob():
 			return nil // Blob->Blob doesn't involve any local requirements
 		default:
-			return fmt.Errorf("flag --%s can only be used on Blob<->Blob or Local<->Blob", common.PreserveSymlinkFlagName)
+			return fmt.Errorf("flag --%s (or --%s=preserve) can only be used on Blob<->Blob or Local<->Blob", common.PreserveSymlinkFlagName, SymlinksFlag)
 		}
 	}
 
 	return nil // other older symlink handling modes can work on all OSes
 }
 
+func validateOneFileSystem(oneFileSystem bool, fromTo common.FromTo) error {
+	if oneFileSystem && fromTo.From() != common.ELocation.Local() {
+		return errors.New(OneFileSystemIncompatibilityMsg)
+	}
+	return nil
+}
+
+func validateArchivePacks(cooked *CookedCopyCmdArgs) error {
+	if cooked.extractArchivePacks && cooked.FromTo != common.EFromTo.BlobLocal() {
+		return errors.New(ExtractPacksIncompatibilityMsg)
+	}
+	if cooked.packFilesSmallerThan == 0 {
+		return nil
+	}
+
+	switch {
+	case cooked.FromTo != common.EFromTo.LocalBlob():
+		return errors.New(PackSmallFilesIncompatibilityMsg)
+	case cooked.blobType != common.EBlobType.Detect() && cooked.blobType != common.EBlobType.BlockBlob():
+		return errors.New("archive packs can only be uploaded as block blobs")
+	case cooked.preservePOSIXProperties || cooked.preserveACLs || cooked.preserveExtAttrs || cooked.preserveFileFlags:
+		return errors.New("the properties of files in archive packs can't be preserved, beyond their modification times")
+	case cooked.dryrunMode:
+		return errors.New("the --pack-small-files-kb flag can't be used in a dry run")
+	case cooked.packSize < cooked.packFilesSmallerThan:
+		return errors.New("archive packs must be bigger than the files packed into them")
+	}
+	return nil
+}
+
+func validateUploadCompression(cooked *CookedCopyCmdArgs) error {
+	if cooked.uploadCompression == common.ECompressionType.None() {
+		return nil
+	}
+	if cooked.FromTo != common.EFromTo.LocalBlob() {
+		return errors.New(CompressIncompatibilityMsg)
+	}
+	if cooked.blobType != common.EBlobType.Detect() && cooked.blobType != common.EBlobType.BlockBlob() {
+		return errors.New("only block blobs can be compressed as they are uploaded")
+	}
+	if cooked.contentEncoding != "" {
+		return errors.New("the --content-encoding flag can't be used with --compress, which sets the content encoding itself")
+	}
+	return nil
+}
+
+// validateSandbox refuses what can't be done in the sandbox of --sandbox: anything but an upload to Blob storage, and
+// anything that needs a file by its path, a program run or a connection that doesn't go through the dialer
+func validateSandbox(cooked *CookedCopyCmdArgs) error {
+	switch {
+	case !cooked.sandbox:
+		return nil
+	case cooked.FromTo != common.EFromTo.LocalBlob():
+		return errors.New(SandboxIncompatibilityMsg)
+	case !cooked.SymlinkHandling.None():
+		return errors.New("symbolic links can't be followed or preserved in the sandbox")
+	case cooked.preservePOSIXProperties || cooked.preserveACLs || cooked.preserveExtAttrs || cooked.preserveFileFlags:
+		return errors.New("the properties of files can't be preserved in the sandbox, beyond their modification times")
+	case cooked.packFilesSmallerThan != 0:
+		return errors.New("small files can't be packed into archive packs in the sandbox")
+	case cooked.clientSideKey != "":
+		return errors.New("files can't be encrypted on the client in the sandbox")
+	case cooked.zfsSnapshotName != "":
+		return errors.New("the --" + FromZFSSnapshotFlag + " flag can't be used with --" + common.SandboxFlagName)
+	case cooked.postHook != "":
+		return errors.New("the --" + PostHookFlag + " command can't be run from the sandbox")
+	case cooked.notifyEmail != "":
+		return errors.New("the --" + NotifyEmailFlag + " flag can't be used with --" + common.SandboxFlagName)
+	}
+	return nil
+}
+
+func validateClientSideEncryption(cooked *CookedCopyCmdArgs) error {
+	switch {
+	case cooked.clientSideKey == "":
+		return nil
+	case cooked.FromTo != common.EFromTo.LocalBlob() && cooked.FromTo != common.EFromTo.BlobLocal():
+		return errors.New(ClientSideEncryptionIncompatibilityMsg)
+	case len(cooked.clientSideKey) > len(ste.JobPartPlanHeader{}.ClientSideKey):
+		return errors.New("the client-side encryption key's URL or path is too long")
+	case cooked.FromTo.IsUpload() && cooked.blobType != common.EBlobType.Detect() && cooked.blobType != common.EBlobType.BlockBlob():
+		return errors.New("only block blobs can be encrypted on the client")
+	case cooked.uploadCompression != common.ECompressionType.None():
+		return errors.New("files can't be both compressed and encrypted on the client as they are uploaded")
+	}
+
+	// a key file is read now, so that a bad one is reported before the job starts. A Key Vault key is fetched once
+	// the job's credentials are known.
+	if !common.IsKeyVaultKey(cooked.clientSideKey) {
+		if _, err := common.NewLocalKeyWrapper(cooked.clientSideKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateBlockDelta(cooked *CookedCopyCmdArgs) error {
+	flag := common.Iff(cooked.contentDefinedBlocks, ContentDefinedBlocksFlag, BlockDeltaFlag)
+	switch {
+	case !cooked.blockDelta:
+		return nil
+	case cooked.FromTo != common.EFromTo.LocalBlob():
+		return errors.New(flag + " is only supported for uploads from local files to Blob storage")
+	case cooked.blobType != common.EBlobType.Detect() && cooked.blobType != common.EBlobType.BlockBlob():
+		return errors.New(flag + " only applies to block blobs")
+	case cooked.uploadCompression != common.ECompressionType.None() || cooked.clientSideKey != "":
+		return errors.New(flag + " cannot be combined with --compress or --client-side-encryption-key, " +
+			"since the blocks they make of a file aren't the same from one upload to the next")
+	}
+	return nil
+}
+
+// validateEncryptionScope checks that blobs can be encrypted with an encryption scope as they are written. An encryption
+// scope is the same thing a key by name is, so they can't both be given.
+func validateEncryptionScope(encryptionScope string, preserve bool, cpkByName string, cpkByValue bool, fromTo common.FromTo) error {
+	switch {
+	case encryptionScope == "" && !preserve:
+		return nil
+	case encryptionScope != "" && fromTo.To() != common.ELocation.Blob():
+		return errors.New(EncryptionScopeIncompatibilityMsg)
+	case preserve && fromTo != common.EFromTo.BlobBlob():
+		return errors.New(PreserveEncryptionScopeIncompatibilityMsg)
+	case encryptionScope != "" && cpkByName != "":
+		return errors.New("cannot use both encryption-scope and cpk-by-name, which is the same thing, at the same time")
+	case cpkByValue:
+		return errors.New("blobs can't be encrypted with both an encryption scope and a client provided key (cpk-by-value)")
+	}
+	return nil
+}
+
+func validateArchiveExtraction(untar, unzip bool, fromTo common.FromTo) error {
+	if (untar || unzip) && !(fromTo.IsDownload() && fromTo.To() == common.ELocation.Local()) {
+		return errors.New(ExtractArchivesIncompatibilityMsg)
+	}
+	return nil
+}
+
+func validateExcludeNodump(excludeNodump bool, fromTo common.FromTo) error {
+	if excludeNodump && (fromTo.From() != common.ELocation.Local() || runtime.GOOS != "freebsd") {
+		return errors.New(ExcludeNodumpIncompatibilityMsg)
+	}
+	return nil
+}
+
 func validateBackupMode(backupMode bool, fromTo common.FromTo) error {
 	if !backupMode {
 		return nil
@@ -522,6 +854,78 @@ func validateBackupMode(backupMode bool, fromTo common.FromTo) error {
 	}
 }
 
+// validateDownloadTempDir checks, before anything is downloaded, that AZCOPY_DOWNLOAD_TEMP_DIR is on the same file
+// system as the destination, since each file is renamed from there into place once all of it has been downloaded
+func validateDownloadTempDir(fromTo common.FromTo, dst common.ResourceString) error {
+	tempDir := common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadTempDir())
+	if tempDir == "" || fromTo.To() != common.ELocation.Local() {
+		return nil
+	}
+	destination := dst.ValueLocal()
+	if strings.EqualFold(destination, common.Dev_Null) {
+		return nil
+	}
+	if toTempPath, err := strconv.ParseBool(common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadToTempPath())); err == nil && !toTempPath {
+		return nil
+	}
+
+	tempDev, tempOK := nearestDeviceID(tempDir)
+	dstDev, dstOK := nearestDeviceID(destination)
+	if tempOK && dstOK && tempDev != dstDev {
+		return fmt.Errorf("%s (%s) must be on the same file system as the destination %s",
+			common.EEnvironmentVariable.DownloadTempDir().Name, tempDir, destination)
+	}
+	return nil
+}
+
+// nearestDeviceID returns the device of the file system that path is on, or will be on once it's been created: that
+// of the closest of its parents that exists
+func nearestDeviceID(path string) (uint64, bool) {
+	path = filepath.Clean(path)
+	for {
+		if fi, err := os.Stat(path); err == nil {
+			return getDeviceID(fi)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, false
+		}
+		path = parent
+	}
+}
+
+func validateFromZFSSnapshot(snapshot string, fromTo common.FromTo) error {
+	if snapshot == "" {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return errors.New(FromZFSSnapshotFlag + " is not supported on Windows")
+	}
+	if fromTo.From() != common.ELocation.Local() || fromTo.To() == common.ELocation.Local() {
+		return errors.New(FromZFSSnapshotFlag + " is only supported for uploads")
+	}
+	if strings.ContainsAny(snapshot, "@/") {
+		return fmt.Errorf("invalid snapshot name %q: give only the part after the '@'", snapshot)
+	}
+	return nil
+}
+
+func validateFromInventory(cooked *CookedCopyCmdArgs) error {
+	if cooked.fromInventory == "" {
+		return nil
+	}
+	if cooked.FromTo.From() != common.ELocation.Blob() {
+		return errors.New(FromInventoryFlag + " is only supported for copies from Blob storage")
+	}
+	if cooked.ListOfFiles != "" || len(cooked.IncludePathPatterns) > 0 || cooked.ListOfVersionIDs != "" {
+		return errors.New(FromInventoryFlag + " cannot be combined with list-of-files, include-path or list-of-versions")
+	}
+	if cooked.S2sPreserveBlobTags {
+		return errors.New(FromInventoryFlag + " cannot be combined with s2s-preserve-blob-tags, since blob tags aren't read from the inventory")
+	}
+	return nil
+}
+
 func validatePutMd5(putMd5 bool, fromTo common.FromTo) error {
 	// In case of S2S transfers, log info message to inform the users that MD5 check doesn't work for S2S Transfers.
 	// This is because we cannot calculate MD5 hash of the data stored at a remote locations.
@@ -539,6 +943,29 @@ func validateMd5Option(option common.HashValidationOption, fromTo common.FromTo)
 	return nil
 }
 
+// validateFsync checks that --fsync is only given for downloads, since they're the only files AzCopy writes
+func validateFsync(policy common.FsyncPolicy, fromTo common.FromTo) error {
+	if policy.Mode != common.EFsyncMode.Off() && !fromTo.IsDownload() {
+		return fmt.Errorf("%s is set but the job is not a download", FsyncFlag)
+	}
+	return nil
+}
+
+// validateHashAlgorithm checks that a hash other than MD5 can be put or checked. Only MD5 has a property of its own, so
+// the others are recorded in metadata, which AzCopy only does for blobs.
+func validateHashAlgorithm(hashType common.ContentHashType, putMd5 bool, fromTo common.FromTo) error {
+	if hashType == common.EContentHashType.MD5() {
+		return nil
+	}
+	if fromTo.IsDownload() {
+		return nil // checked against the hash in the source's metadata
+	}
+	if !putMd5 || fromTo.From() != common.ELocation.Local() || fromTo.To() != common.ELocation.Blob() {
+		return errors.New(HashAlgorithmIncompatibilityMsg)
+	}
+	return nil
+}
+
 // Valid tag key and value characters include:
 // 1. Lowercase and uppercase letters (a-z, A-Z)
 // 2. Digits (0-9)
@@ -633,11 +1060,34 @@ type CookedCopyCmdArgs struct {
 	Recursive          bool
 	StripTopDir        bool
 	SymlinkHandling    common.SymlinkHandlingType
+	OneFileSystem      bool                   // don't descend into directories on other file systems than the source root
 	ForceWrite         common.OverwriteOption // says whether we should try to overwrite
 	ForceIfReadOnly    bool                   // says whether we should _force_ any overwrites (triggered by forceWrite) to work on Azure Files objects that are set to read-only
 	IsSourceDir        bool
 
 	autoDecompress bool
+	// whether blobs compressed by --compress are to be left compressed too, as they are if --decompress=false is given
+	keepCompressed bool
+
+	// files smaller than this are packed into archive blobs of packSize, rather than uploaded one by one. 0 if none are.
+	packFilesSmallerThan int64
+	packSize             int64
+	extractArchivePacks  bool
+
+	// extract archives of these kinds as they are downloaded, rather than save them
+	untar bool
+	unzip bool
+
+	// compress files with this as they are uploaded
+	uploadCompression common.CompressionType
+
+	// encrypt blobs on the client with this key as they are uploaded, and decrypt them with it as they are downloaded
+	clientSideKey string
+
+	// upload only the blocks of each file that the destination blob doesn't have already
+	blockDelta bool
+	// and split files into blocks where their content says, instead of every blockSize bytes
+	contentDefinedBlocks bool
 
 	// options from flags
 	blockSize   int64
@@ -661,7 +1111,10 @@ type CookedCopyCmdArgs struct {
 	deleteSnapshotsOption    common.DeleteSnapshotsOption
 	putMd5                   bool
 	md5ValidationOption      common.HashValidationOption
-	CheckLength              bool
+	hashAlgorithm            common.ContentHashType
+	// when downloaded files, and the directories they're saved in, are synced to disk
+	fsync       common.FsyncPolicy
+	CheckLength bool
 	// commandString hold the user given command which is logged to the Job log file
 	commandString string
 
@@ -685,15 +1138,74 @@ type CookedCopyCmdArgs struct {
 	// it is useful to indicate whether we are simply waiting for the purpose of cancelling
 	isEnumerationComplete bool
 
+	// what the scan has found so far, which is shown until it's finished
+	scanProgress *scanProgress
+
+	// how far the scan has got, for jobs resume to carry it on from, and how copy was run, to run it again with
+	scanCheckpoint *scanCheckpoint
+	command        copyCommand
+	// whether this carries on the scan of the job with the ID that was given, which was interrupted
+	continueJob bool
+
+	// set once we start shutting the job down cleanly, so that it exits with EExitCode.Interrupted (see drainJob)
+	interrupted bool
+
 	// Whether the user wants to preserve the SMB ACLs assigned to their files when moving between resources that are SMB ACL aware.
 	preservePermissions common.PreservePermissionsOption
 
 	// Whether the user wants to preserve the POSIX properties ...
 	preservePOSIXProperties bool
 
+	// Whether the user wants to preserve POSIX.1e/NFSv4 ACLs via object metadata
+	preserveACLs bool
+
+	// Whether the user wants to preserve extended attributes via object metadata
+	preserveExtAttrs bool
+
+	// Whether the user wants to preserve BSD file flags via object metadata
+	preserveFileFlags bool
+
 	// Whether to enable Windows special privileges
 	backupMode bool
 
+	// Whether to reserve disk space for downloaded files up front
+	preallocate bool
+
+	// One of the common.CacheBypass* modes, for reading local files
+	bypassCache string
+
+	// Whether downloads are written with aio_write(2) rather than write(2)
+	aioWrites bool
+
+	// Whether downloads lock the files they write
+	lockFiles bool
+
+	// Whether the job's transfers run in capsicum(4)'s capability mode, once the source has been scanned, and the
+	// sandbox that they run in, once it has been opened (see copySandbox.go)
+	sandbox   bool
+	sandboxed *common.Sandbox
+
+	// How many chunks of each file are downloaded ahead of where it has been written up to, or 0 for no limit but RAM
+	prefetchChunks uint32
+
+	// Whether uploads read local files through memory mappings of them, rather than into buffers
+	mmapUploads bool
+
+	// How many directories the scan reads at once, if not AZCOPY_CONCURRENT_SCAN's number
+	scanConcurrency uint32
+
+	// ZFS snapshot to read the source from (see zfsSnapshot.go). liveSource is the source as the user gave it,
+	// which destination names are based on.
+	zfsSnapshotName string
+	zfsSnapshot     *zfsSnapshot
+	liveSource      string
+
+	// the manifest of the blob inventory that the source is listed from, if it isn't listed live (see blobInventory.go)
+	fromInventory string
+
+	// hard links found while enumerating a download, made once the job is done (see hardlinkTracker.go)
+	deferredHardlinks *deferredHardlinks
+
 	// Whether to rename/share the root
 	asSubdir bool
 
@@ -732,6 +1244,18 @@ type CookedCopyCmdArgs struct {
 	// specify if dry run mode on
 	dryrunMode bool
 
+	// file to write a line to for each transfer, in transferReportFormat (see transferReport.go)
+	transferReportPath   string
+	transferReportFormat string
+
+	// where to send the outcome of the job (see notify.go)
+	notifyWebhook string
+	notifyEmail   string
+
+	// commands to run before and after the job (see jobHooks.go)
+	preHook  string
+	postHook string
+
 	CpkOptions common.CpkOptions
 
 	// Optional flag that permanently deletes soft deleted blobs
@@ -751,6 +1275,12 @@ type CookedCopyCmdArgs struct {
 	hardlinks                     common.HardlinkHandlingType
 	atomicSkippedSymlinkCount     uint32
 	atomicSkippedSpecialFileCount uint32
+	specialFiles                  common.SpecialFileHandlingType
+	order                         common.TransferOrder
+	traversal                     common.TraversalOrder
+	skippedSpecialFiles           *specialFileReport
+	excludeNodump                 bool
+	atomicSkippedNodumpCount      uint32
 	BlockSizeMB                   float64
 	PutBlobSizeMB                 float64
 	IncludePathPatterns           []string
@@ -759,7 +1289,10 @@ type CookedCopyCmdArgs struct {
 	blobTagsMap                   common.BlobTags
 	cpkByName                     string
 	cpkByValue                    bool
+	encryptionScope               string
+	s2sPreserveEncryptionScope    bool
 	preserveOwner                 bool
+	idmapFile                     string
 }
 
 func (cca *CookedCopyCmdArgs) isRedirection() bool {
@@ -774,11 +1307,40 @@ func (cca *CookedCopyCmdArgs) isRedirection() bool {
 }
 
 func (cca *CookedCopyCmdArgs) process() error {
+	if !cca.isCleanupJob {
+		if err := runPreHook(); err != nil {
+			return err
+		}
+	}
 
 	err := common.SetBackupMode(cca.backupMode, cca.FromTo)
 	if err != nil {
 		return err
 	}
+	common.SetPreallocateFiles(cca.preallocate)
+	if err = common.SetCacheBypass(cca.bypassCache); err != nil {
+		return err
+	}
+	if err = common.SetAIOWrites(cca.aioWrites); err != nil {
+		return err
+	}
+	if err = common.SetLockFiles(cca.lockFiles); err != nil {
+		return err
+	}
+	common.SetPrefetchChunks(cca.prefetchChunks)
+	if err = common.SetMmapUploads(cca.mmapUploads); err != nil {
+		return err
+	}
+	setTraversalOrder(cca.traversal)
+	if err = common.SetIDMapFile(cca.idmapFile); err != nil {
+		return err
+	}
+
+	if cca.zfsSnapshotName != "" {
+		if err = cca.switchToZFSSnapshot(); err != nil {
+			return err
+		}
+	}
 
 	if cca.isRedirection() {
 		err := cca.processRedirectionCopy()
@@ -964,7 +1526,8 @@ func (cca *CookedCopyCmdArgs) processRedirectionUpload(blobResource common.Resou
 // get source credential - if there is a token it will be used to get passed along our pipeline
 func (cca *CookedCopyCmdArgs) getSrcCredential(ctx context.Context, jpo *common.CopyJobPartOrderRequest) (common.CredentialInfo, error) {
 	switch cca.FromTo.From() {
-	case common.ELocation.Local(), common.ELocation.Benchmark():
+	case common.ELocation.Local(), common.ELocation.Benchmark(), common.ELocation.Provider(),
+		common.ELocation.URLList(): // the URLs in a list are public, or carry their own signatures
 		return common.CredentialInfo{CredentialType: common.ECredentialType.Anonymous()}, nil
 	case common.ELocation.S3():
 		return common.CredentialInfo{CredentialType: common.ECredentialType.S3AccessKey()}, nil
@@ -1053,6 +1616,27 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		}
 	}
 
+	// A Key Vault key for client-side encryption is signed in to with the user's OAuth token, even when the storage
+	// account isn't. Fetching it now reports a key that can't be used before the job starts.
+	if common.IsKeyVaultKey(cca.clientSideKey) {
+		if !cca.credentialInfo.CredentialType.IsAzureOAuth() {
+			tokenInfo, err := GetUserOAuthTokenManagerInstance().GetTokenInfo(ctx)
+			if err != nil {
+				return fmt.Errorf("a Key Vault key needs you to be logged in with azcopy login or the AZCOPY_AUTO_LOGIN_TYPE variable: %w", err)
+			}
+			cca.credentialInfo.OAuthTokenInfo = *tokenInfo
+		}
+		if _, err := common.NewKeyWrapper(ctx, cca.clientSideKey, cca.credentialInfo.OAuthTokenInfo.GetTokenCredential); err != nil {
+			return err
+		}
+	}
+
+	if cca.sandbox {
+		if err = cca.openSandbox(); err != nil {
+			return err
+		}
+	}
+
 	// initialize the fields that are constant across all job part orders,
 	// and for which we have sufficient info now to set them
 	jobPartOrder := common.CopyJobPartOrderRequest{
@@ -1061,6 +1645,13 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		ForceWrite:          cca.ForceWrite,
 		ForceIfReadOnly:     cca.ForceIfReadOnly,
 		AutoDecompress:      cca.autoDecompress,
+		KeepCompressed:      cca.keepCompressed,
+		ExtractArchivePacks: cca.extractArchivePacks,
+		Untar:               cca.untar,
+		Unzip:               cca.unzip,
+		UploadCompression:   cca.uploadCompression,
+		ClientSideKey:       cca.clientSideKey,
+		HoldTransfers:       cca.sandbox,
 		Priority:            common.EJobPriority.Normal(),
 		LogLevel:            LogLevel,
 		ExcludeBlobType:     cca.excludeBlobType,
@@ -1081,10 +1672,14 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 			PreserveLastModifiedTime: cca.preserveLastModifiedTime,
 			PutMd5:                   cca.putMd5,
 			MD5ValidationOption:      cca.md5ValidationOption,
+			ContentHashType:          cca.hashAlgorithm,
+			Fsync:                    cca.fsync,
 			DeleteSnapshotsOption:    cca.deleteSnapshotsOption,
 			// Setting tags when tags explicitly provided by the user through blob-tags flag
 			BlobTagsString:                   cca.blobTagsMap.ToString(),
 			DeleteDestinationFileIfNecessary: cca.deleteDestinationFileIfNecessary,
+			BlockDelta:                       cca.blockDelta,
+			ContentDefinedBlocks:             cca.contentDefinedBlocks,
 		},
 		CommandString:  cca.commandString,
 		CredentialInfo: cca.credentialInfo,
@@ -1188,15 +1783,25 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		return err
 	}
 
+	endScan := ste.TraceScan(cca.jobID)
 	switch {
-	case cca.FromTo.IsUpload(), cca.FromTo.IsDownload(), cca.FromTo.IsS2S():
+	case cca.FromTo.IsUpload(), cca.FromTo.IsDownload(), cca.FromTo.IsS2S(), cca.FromTo == common.EFromTo.LocalLocal():
 		// Execute a standard copy command
 		var e *CopyEnumerator
+		cca.scanProgress = newScanProgress()
+		if err = cca.startScanCheckpoint(&jobPartOrder); err != nil {
+			return err
+		}
 		e, err = cca.initEnumerator(jobPartOrder, srcCredInfo, ctx)
 		if err != nil {
 			return fmt.Errorf("failed to initialize enumerator: %w", err)
 		}
+		if !cca.dryrunMode {
+			// until the first part is sent, and the job's progress can be shown
+			cca.scanProgress.startReporting(glcm, cca.jobID)
+		}
 		err = e.enumerate()
+		cca.scanProgress.stopReporting()
 
 	case cca.FromTo.IsDelete():
 		// Delete gets ran through copy, so handle delete
@@ -1224,12 +1829,13 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 	default:
 		return fmt.Errorf("copy direction %v is not supported", cca.FromTo)
 	}
+	endScan(err)
 
 	if err != nil {
 		if err == ErrNothingToRemove || err == NothingScheduledError {
 			return err // don't wrap it with anything that uses the word "error"
 		} else {
-			return fmt.Errorf("cannot start job due to error %s", err)
+			return fmt.Errorf("cannot start job due to error %w", err)
 		}
 	}
 
@@ -1240,6 +1846,9 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 // if blocking is specified to true, then this method will never return
 // if blocking is specified to false, then another goroutine spawns and wait out the job
 func (cca *CookedCopyCmdArgs) waitUntilJobCompletion(blocking bool) {
+	// the job's progress, which includes the scan's, is shown from now on
+	cca.scanProgress.stopReporting()
+
 	// print initial message to indicate that the job is starting
 	// if on dry run mode do not want to print message since no  job is being done
 	if !cca.dryrunMode {
@@ -1258,6 +1867,7 @@ func (cca *CookedCopyCmdArgs) waitUntilJobCompletion(blocking bool) {
 	cca.jobStartTime = time.Now()
 	cca.intervalStartTime = time.Now()
 	cca.intervalBytesTransferred = 0
+	startLiveProgress(cca.jobID)
 
 	// hand over control to the lifecycle manager if blocking
 	if blocking {
@@ -1332,6 +1942,10 @@ func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 
 	jobDone := summary.JobStatus.IsJobDone()
 	totalKnownCount = summary.TotalTransfers
+	if !summary.CompleteJobOrdered && cca.scanProgress != nil {
+		scan := cca.scanProgress.report(time.Now())
+		summary.ScanProgress = &scan
+	}
 
 	// if json is not desired, and job is done, then we generate a special end message to conclude the job
 	duration := time.Since(cca.jobStartTime) // report the total run time of the job
@@ -1363,6 +1977,8 @@ func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 			var scanningString = " (scanning...)"
 			if summary.CompleteJobOrdered {
 				scanningString = ""
+			} else if summary.ScanProgress != nil {
+				scanningString = " (scanning: " + describeScanProgress(*summary.ScanProgress) + ")"
 			}
 
 			throughput := computeThroughput()
@@ -1387,10 +2003,28 @@ func (cca *CookedCopyCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 	if jobDone {
 		summary.SkippedSymlinkCount = atomic.LoadUint32(&cca.atomicSkippedSymlinkCount)
 		summary.SkippedSpecialFileCount = atomic.LoadUint32(&cca.atomicSkippedSpecialFileCount)
+		summary.SkippedNodumpCount = atomic.LoadUint32(&cca.atomicSkippedNodumpCount)
+		summary.SkippedSpecialFiles = cca.skippedSpecialFiles.list()
+
+		if !cca.isCleanupJob {
+			recordJobHistory(summary, cca.FromTo, cca.jobStartTime)
+		}
+		finishTransferReport()
 
 		exitCode := cca.getSuccessExitCode()
-		if summary.TransfersFailed > 0 || summary.JobStatus == common.EJobStatus.Cancelled() || summary.JobStatus == common.EJobStatus.Cancelling() {
-			exitCode = common.EExitCode.Error()
+		if code := jobExitCode(summary); code != common.EExitCode.Success() {
+			exitCode = code
+		}
+		exitCode = interruptedExitCode(cca.jobID, summary.JobStatus, cca.interrupted, exitCode)
+
+		if cca.deferredHardlinks != nil && summary.JobStatus != common.EJobStatus.Cancelled() && summary.JobStatus != common.EJobStatus.Cancelling() {
+			created, failed := cca.deferredHardlinks.create(cca.ForceWrite)
+			if created > 0 {
+				glcm.Info(fmt.Sprintf("Created %d hard links", created))
+			}
+			if failed > 0 {
+				exitCode = common.EExitCode.Error()
+			}
 		}
 
 		builder := func(format common.OutputFormat) string {
@@ -1418,8 +2052,8 @@ Number of File Transfers Skipped: %v
 Number of Folder Transfers Skipped: %v
 Number of Symbolic Links Skipped: %v
 Number of Hardlinks Converted: %v
-Number of Special Files Skipped: %v
-Total Number of Bytes Transferred: %v
+Number of Special Files Skipped: %v%s
+Total Number of Bytes Transferred: %v%s
 Final Job Status: %v%s%s
 `,
 					summary.JobID.String(),
@@ -1437,7 +2071,9 @@ Final Job Status: %v%s%s
 					summary.SkippedSymlinkCount,
 					summary.HardlinksConvertedCount,
 					summary.SkippedSpecialFileCount,
+					formatSpecialFiles(summary.SkippedSpecialFiles, summary.SkippedSpecialFileCount)+formatNodumpStats(cca.excludeNodump, summary.SkippedNodumpCount),
 					summary.TotalBytesTransferred,
+					formatRetryAfterStats(summary.RetryAfterCount, summary.ThrottledMilliseconds)+formatStallStats(summary.StallCount, summary.StalledMilliseconds),
 					summary.JobStatus,
 					screenStats,
 					formatPerfAdvice(summary.PerformanceAdvice))
@@ -1457,7 +2093,12 @@ Final Job Status: %v%s%s
 			}
 		}
 
-		if cca.hasFollowup() {
+		cca.releaseZFSSnapshot(exitCode == common.EExitCode.Success())
+		if !cca.isCleanupJob {
+			notifyJobDone(summary, cca.jobStartTime, exitCode)
+		}
+
+		if _, draining := jobTransferState(cca.jobID); cca.hasFollowup() && !cca.interrupted && !draining { // no point starting more work if we're shutting down
 			lcm.Exit(builder, common.EExitCode.NoExit()) // leave the app running to process the followup
 			cca.launchFollowup(exitCode)
 			lcm.SurrenderControl() // the followup job will run on its own goroutines
@@ -1496,6 +2137,32 @@ func formatPerfAdvice(advice []common.PerformanceAdvice) string {
 	return b.String()
 }
 
+// formatNodumpStats adds a summary line for --exclude-nodump, but only when it was used
+func formatNodumpStats(excludeNodump bool, skipped uint32) string {
+	if !excludeNodump {
+		return ""
+	}
+	return fmt.Sprintf("\nNumber of Files and Folders Skipped for nodump: %v", skipped)
+}
+
+// formatRetryAfterStats is the line of the job summary that says how long the job waited for the server's
+// Retry-After, if it did
+func formatRetryAfterStats(count, throttledMilliseconds int64) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nTime Waiting for Retry-After: %v (%v retries)", time.Duration(throttledMilliseconds)*time.Millisecond, count)
+}
+
+// formatStallStats is the line of the job summary that says how many requests were retried because their
+// connections stalled, if any were
+func formatStallStats(count, stalledMilliseconds int64) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nRequests Retried After Stalling: %v (%v lost)", count, time.Duration(stalledMilliseconds)*time.Millisecond)
+}
+
 // format extra stats to include in the log.  If benchmarking, also output them on screen (but not to screen in normal
 // usage because too cluttered)
 func formatExtraStats(fromTo common.FromTo, avgIOPS int, avgE2EMilliseconds int, networkErrorPercent float32, serverBusyPercent float32) (screenStats, logStats string) {
@@ -1566,7 +2233,23 @@ func init() {
 		Long:       copyCmdLongDescription,
 		Example:    copyCmdExample,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 1 { // redirection
+			if raw.listOfURLs != "" { // the list of URLs is the source
+				if len(args) != 1 {
+					return errors.New("with --list-of-urls, give only the destination")
+				}
+				if raw.fromTo == "" {
+					raw.fromTo = common.EFromTo.URLListBlob().String()
+				} else if !strings.EqualFold(raw.fromTo, common.EFromTo.URLListBlob().String()) {
+					return fmt.Errorf("fatal: invalid from-to argument passed with --list-of-urls: %s", raw.fromTo)
+				}
+				raw.src = raw.listOfURLs
+				raw.dst = args[0]
+
+				glcm.EnableInputWatcher()
+				if cancelFromStdin {
+					glcm.EnableCancelFromStdIn()
+				}
+			} else if len(args) == 1 { // redirection
 				// Enforce the usage of from-to flag when pipes are involved
 				if raw.fromTo == "" {
 					return fmt.Errorf("fatal: from-to argument required, PipeBlob (upload) or BlobPipe (download) is acceptable")
@@ -1621,9 +2304,11 @@ func init() {
 			glcm.Info("Scanning...")
 
 			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
+			cooked.command = recordCopyCommand(cmd, raw.src, raw.dst, cooked.FromTo)
 			err = cooked.process()
 			if err != nil {
-				glcm.Error("failed to perform copy command due to error: " + err.Error() + getErrorCodeUrl(err))
+				notifyCommandFailed(err, errorExitCode(err))
+				glcm.ErrorWithExitCode("failed to perform copy command due to error: "+err.Error()+getErrorCodeUrl(err), errorExitCode(err))
 			}
 
 			if cooked.dryrunMode {
@@ -1637,7 +2322,41 @@ func init() {
 
 	// filters change which files get transferred
 	cpCmd.PersistentFlags().BoolVar(&raw.followSymlinks, "follow-symlinks", false,
-		"False by default. Follow symbolic links when uploading from local file system.")
+		"False by default. Follow symbolic links when uploading from local file system. Same as --symlinks=follow.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.symlinks, SymlinksFlag, "",
+		"Skip by default. How symbolic links are handled: 'skip' leaves them out, 'follow' transfers whatever they point to, "+
+			"and 'preserve' stores the link target in an empty blob (marked in its metadata) and recreates the link on download.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.oneFileSystem, OneFileSystemFlag, false,
+		"False by default. When recursively uploading from the local file system, don't descend into directories that are on "+
+			"a different file system than the source, such as NFS mounts, nullfs mounts or mounted media. The mount points themselves are still included.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.excludeNodump, ExcludeNodumpFlag, false,
+		"False by default. Skips local files and folders that have the nodump flag set (see chflags(1)), along with everything inside those folders, "+
+			"the same way dump(8) does. The number skipped is shown in the job summary. Currently only supported on FreeBSD.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.traversal, TraversalFlag, "auto",
+		"Specifies the order in which the directories of the source are read when scanning it, and so the order in which their files are transferred. "+
+			"\n 'auto' (default) reads them level by level, which spreads transfers across the tree, until a great many are waiting, and then goes depth first to save memory. "+
+			"\n 'breadth-first' always reads them level by level, finishing each level of the tree before starting the one below it; the list of directories waiting can get large. "+
+			"\n 'depth-first' reads a subdirectory, and everything in it, before its siblings, so that whole subdirectories are complete at the destination sooner, "+
+			"which helps anything reading the destination while the job runs. With --"+ScanConcurrencyFlag+" above 1, several subdirectories are read at once. "+
+			"\n Has no effect on flat listings of Blob containers, which are always in the order of the names.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.order, OrderFlag, "as-scanned",
+		"Specifies the order in which files are transferred. "+
+			"\n 'as-scanned' (default) starts each file as soon as it is found. "+
+			"\n 'smallest-first' starts the smallest files first, to get as many files done as soon as possible. "+
+			"\n 'largest-first' starts the largest files first, so that the longest transfers aren't left until last. "+
+			"\n Either of the last two waits until every file has been found before any starts, and holds the list of them in memory.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.specialFiles, SpecialFilesFlag, "warn",
+		"Specifies what to do with named pipes, sockets and device nodes found in a local source, none of which are transferred. "+
+			"\n 'warn' (default) skips each one with a warning. "+
+			"\n 'skip' skips them without a warning. "+
+			"\n 'fail' stops the job at the first one. "+
+			"\n Skipped special files are counted and listed in the job summary. Azure Files NFS transfers always skip them with a warning.")
 
 	cpCmd.PersistentFlags().StringVar(&raw.includeBefore, common.IncludeBeforeFlagName, "",
 		"Include only those files were modified before or on the given date/time. \n "+
@@ -1679,6 +2398,11 @@ func init() {
 		"Exclude all the relative path of the files that align with regular expressions. "+
 			"Separate regular expressions with ';'.")
 
+	cpCmd.PersistentFlags().StringVar(&raw.listOfURLs, "list-of-urls", "",
+		"Copy the http or https URLs listed in this file, one per line, such as presigned or public URLs. "+
+			"\n Give only the destination, which must be in Blob Storage; the service reads each URL itself. "+
+			"\n Each file is named after its URL's path, unless a name follows the URL after a tab.")
+
 	// This flag is implemented only for Storage Explorer.
 	cpCmd.PersistentFlags().StringVar(&raw.listOfFilesToCopy, "list-of-files", "",
 		"Defines the location of text file which has the list of files to be copied. "+
@@ -1697,7 +2421,53 @@ func init() {
 	cpCmd.PersistentFlags().BoolVar(&raw.autoDecompress, "decompress", false,
 		"False by default. Automatically decompress files when downloading, if their content-encoding indicates"+
 			"that they are compressed.\n  The supported content-encoding values are 'gzip' and 'deflate'. "+
-			"\n File extensions of '.gz'/'.gzip' or '.zz' aren't necessary, but will be removed if present.")
+			"\n File extensions of '.gz'/'.gzip' or '.zz' aren't necessary, but will be removed if present. "+
+			"\n Files that were compressed with --compress as they were uploaded are always decompressed, and checked against "+
+			"the size and MD5 hash they had before, unless --decompress=false is given.")
+
+	cpCmd.PersistentFlags().Uint32Var(&raw.packSmallFilesKB, "pack-small-files-kb", 0,
+		"0 by default. When uploading to Blob storage, pack files smaller than this many KiB into tar archive blobs, "+
+			"instead of uploading each as a blob of its own, which is much faster for very many small files. "+
+			"\n Each archive begins with an index, "+common.ArchivePackIndexName+", giving the name, offset and size of every file in it, "+
+			"and an index of the files in all of the job's archives, azcopy-pack-[job ID]-index.jsonl, "+
+			"is uploaded beside them, with a line of JSON giving the archive, offset and size of each file. "+
+			"Use --extract-packs to unpack the archives when downloading.")
+	cpCmd.PersistentFlags().Uint32Var(&raw.packSizeMB, "pack-size-mb", 256,
+		"Use this flag with --pack-small-files-kb to set how much data is packed into each archive blob, in MiB.")
+	cpCmd.PersistentFlags().BoolVar(&raw.extractPacks, "extract-packs", false,
+		"False by default. When downloading, extract the files from archive blobs made with --pack-small-files-kb "+
+			"as they are downloaded, into the folder each archive is downloaded to, instead of saving the archives.")
+	cpCmd.PersistentFlags().StringVar(&raw.compress, "compress", "",
+		"Compress files as they are uploaded to block blobs, and set their Content-Encoding to say so. "+
+			"Each chunk is compressed as it is read, and the size of the original file is kept in the metadata key "+common.UncompressedSizeMeta+". "+
+			"\n Saves space and bandwidth for compressible files such as logs and text. Use --decompress to decompress them when downloading. "+
+			"\n The only available value is 'gzip'.")
+	cpCmd.PersistentFlags().StringVar(&raw.clientSideKey, "client-side-encryption-key", "",
+		"Encrypt files on the client as they are uploaded to block blobs, and decrypt blobs encrypted that way as they are downloaded. "+
+			"Give either the URL of an RSA key in Key Vault (https://<vault>.vault.azure.net/keys/<name>), which needs you to be logged in, "+
+			"or the path of a file holding a 256-bit AES key, as 32 bytes or in base64. "+
+			"\n Each file is encrypted with AES-GCM, with a key of its own. That key is kept in the blob's "+common.ClientSideEncryptionMeta+" metadata, "+
+			"wrapped by the key given here, along with the key's ID and the algorithms used, as version 2 of the Storage client libraries' client-side encryption keeps them. "+
+			"Blobs downloaded without this flag are saved still encrypted.")
+	cpCmd.PersistentFlags().BoolVar(&raw.blockDelta, BlockDeltaFlag, false,
+		"False by default. When uploading to block blobs that exist already, upload only the blocks of each file that the blob doesn't have, "+
+			"and commit them with those it has. Saves most of the upload of large files that change in part, such as VM images and database dumps. "+
+			"\n Blocks are named by the SHA-256 of their content, so the first upload with this flag sends every block. "+
+			"Blocks only match if they start at the same place, so give the same --block-size-mb each time, or none, which picks a block size from the size of the file alone. "+
+			"Files no bigger than --put-blob-size-mb are uploaded whole.")
+	cpCmd.PersistentFlags().BoolVar(&raw.contentDefinedBlocks, ContentDefinedBlocksFlag, false,
+		"False by default. Implies --"+BlockDeltaFlag+", and splits each file into blocks at places chosen by its content, as rsync does, "+
+			"instead of every --block-size-mb, so that bytes inserted into or removed from a file only change the blocks they fall in, "+
+			"and the blocks after them aren't uploaded again. Worth it for very large files over slow links. "+
+			"\n Each file is read twice: once to find its blocks, and once to upload them. Blocks are --block-size-mb on average, "+
+			"from a quarter of that to four times it.")
+	cpCmd.PersistentFlags().BoolVar(&raw.untar, "untar", false,
+		"False by default. When downloading, extract .tar, .tar.gz and .tgz archives as they are downloaded, "+
+			"into the folder each archive would have been saved in, instead of saving the archives. "+
+			"Only files and folders are extracted, and files that already exist are only replaced if --overwrite is true.")
+	cpCmd.PersistentFlags().BoolVar(&raw.unzip, "unzip", false,
+		"False by default. When downloading, extract .zip archives as they are downloaded, in the same way as --untar. "+
+			"Zip archives whose stored (uncompressed) files have no size before their data can't be read as they are downloaded, and fail.")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", false,
 		"False by default. Look into sub-directories recursively when uploading from local file system.")
@@ -1712,7 +2482,7 @@ func init() {
 	// options change how the transfers are performed
 	cpCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0,
 		"Use this block size (specified in MiB) when uploading to Azure Storage, and downloading from Azure Storage. "+
-			"\n The default value is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25)."+
+			"\n The default value is chosen for each file, from its size, the measured throughput and the available memory. Decimal fractions are allowed (For example: 0.25)."+
 			"\n When uploading or downloading, maximum allowed block size is 0.75 * AZCOPY_BUFFER_GB. "+
 			"\n Please refer https://learn.microsoft.com/en-us/azure/storage/common/storage-use-azcopy-optimize#optimize-memory-use.")
 
@@ -1772,10 +2542,17 @@ func init() {
 		"True by default. Places folder sources as subdirectories under the destination.")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveOwner, common.PreserveOwnerFlagName, common.PreserveOwnerDefault,
-		"Only has an effect in downloads, and only when --preserve-smb-permissions is used. "+
+		"Only has an effect in downloads, and only when --preserve-smb-permissions or --preserve-posix-properties is used. "+
 			"\n If true (the default), the file Owner and Group are preserved in downloads. "+
 			"\n If set to false, --preserve-smb-permissions will still preserve ACLs but Owner and Group "+
-			"will be based on the user running AzCopy")
+			"will be based on the user running AzCopy. "+
+			"\n On FreeBSD, with --preserve-posix-properties, the uid and gid are only restored when AzCopy runs as root, as with tar and rsync.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.idmapFile, common.IDMapFileFlagName, "",
+		"Path to a file that translates the owners and groups recorded at the source into local ones, when restoring them "+
+			"as root with --preserve-posix-properties. Each line reads 'user <source> <local>' or 'group <source> <local>', where "+
+			"<source> is the uid or gid recorded at the source (or the owner shown by a hierarchical namespace account) and "+
+			"<local> is a uid, gid, user name or group name on this machine. Anything not listed keeps its recorded ID.")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveSMBInfo, "preserve-smb-info", (runtime.GOOS == "windows"),
 		"Preserves SMB property info (last write time, creation time, attribute bits) between SMB-aware resources (Windows and Azure Files SMB). "+
@@ -1794,11 +2571,25 @@ func init() {
 			"share and for Linux when copying to Azure Files NFS share. ")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.preservePOSIXProperties, "preserve-posix-properties", false,
-		"False by default. 'Preserves' property info gleaned from stat or statx into object metadata.")
+		"False by default. 'Preserves' property info gleaned from stat or statx into object metadata. "+
+			"When the Blob account has a hierarchical namespace, the owner, group and mode are also set as its x-ms-owner, x-ms-group and x-ms-permissions, "+
+			"and on download are applied back with chown and chmod (ownership only when running as root, and only for numeric IDs).")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.preserveACLs, PreserveACLsFlag, false,
+		"False by default. Preserves POSIX.1e or NFSv4 ACLs in object metadata on upload, and reapplies them on download. "+
+			"Currently only supported on FreeBSD.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.preserveExtAttrs, PreserveExtAttrsFlag, false,
+		"False by default. Preserves user and system namespace extended attributes in object metadata on upload, and restores them on download. "+
+			"Attributes larger than 1KiB, or beyond 4KiB in total, are skipped and listed in the job log. Currently only supported on FreeBSD.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.preserveFileFlags, PreserveFileFlagsFlag, false,
+		"False by default. Preserves BSD file flags, such as uchg, uappnd and nodump, in object metadata on upload, and restores them on download. "+
+			"System flags (schg, sappnd, ...) can only be restored when running as root. Currently only supported on FreeBSD.")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.preserveSymlinks, common.PreserveSymlinkFlagName, false,
 		"False by default. If enabled, symlink destinations are preserved as the blob content, rather"+
-			"than uploading the file/folder on the other end of the symlink")
+			"than uploading the file/folder on the other end of the symlink. Same as --symlinks=preserve.")
 
 	cpCmd.PersistentFlags().BoolVar(&raw.forceIfReadOnly, "force-if-read-only", false,
 		"False by default. When overwriting an existing file on Windows or Azure Files, force the overwrite"+
@@ -1811,6 +2602,85 @@ func init() {
 			"(e.g. has Administrator rights or is a member of the 'Backup Operators' group). "+
 			"\n All this flag does is activate privileges that the account already has.")
 
+	cpCmd.PersistentFlags().BoolVar(&raw.preallocate, common.PreallocateFlagName, true,
+		"True by default. Reserves disk space for each downloaded file before writing it, so that a full disk is reported "+
+			"straight away and files are less fragmented. Falls back to creating sparse files on file systems that can't preallocate, such as ZFS. "+
+			"On FreeBSD, runs of zeros in the downloaded data are skipped rather than written, and only become holes in files that aren't preallocated, "+
+			"so page blobs (usually mostly-empty disk images) are never preallocated. Set to false to always create sparse files.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.bypassCache, common.CacheBypassFlagName, common.CacheBypassNone,
+		"None by default. Keeps the files being uploaded from evicting everything else in the page cache or ZFS ARC. "+
+			"'dontneed' advises the kernel to drop each chunk once it has been read; 'direct' also opens files with O_DIRECT on FreeBSD. "+
+			"Useful for large backups of busy servers.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.aioWrites, common.AIOWritesFlagName, false,
+		"False by default. Writes downloaded files with kernel AIO, so that disk writes overlap with network reads "+
+			"without tying up a thread per file. Only supported on 64-bit FreeBSD.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.lockFiles, common.LockFilesFlagName, false,
+		"False by default. Locks each file while it's being downloaded, with flock(2), so that other programs that lock files "+
+			"before reading them, such as indexers and web servers, don't read it half-written, and so that a second AzCopy with this flag "+
+			"downloading to the same file fails instead of writing over this one. Files are downloaded to a temporary name unless "+
+			common.EEnvironmentVariable.DownloadToTempPath().Name+" is false, so it matters most when that is set. Not supported on Windows.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.sandbox, common.SandboxFlagName, false,
+		"False by default. Uploads from capsicum(4)'s capability mode, in which AzCopy can't open any file but those beneath the source "+
+			"and its own plan and log folders, nor connect anywhere but through the system.net service of libcasper(3). "+
+			"The source is scanned first, and nothing is uploaded until the scan is over. Only supported for uploads to Blob storage, "+
+			"with a SAS or shared key, by builds of AzCopy for FreeBSD made with cgo.")
+
+	cpCmd.PersistentFlags().Uint32Var(&raw.prefetchChunks, common.PrefetchChunksFlagName, 0,
+		"0 by default, which leaves it to "+common.EEnvironmentVariable.BufferGB().Name+". How many chunks of each file may be downloaded "+
+			"ahead of the point it has been written up to. Raise it to keep a fast link with high latency busy, or lower it "+
+			"to stop one slow file from using up the memory that others could use.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.mmapUploads, common.MmapUploadsFlagName, false,
+		"False by default. Uploads files of 8 MiB and more from memory mappings of them, sending each chunk straight from the mapping "+
+			"rather than reading it into a buffer first, which saves a copy and memory for large files. Can't be used with --"+common.CacheBypassFlagName+". "+
+			"Not supported on Windows.")
+
+	cpCmd.PersistentFlags().Uint32Var(&raw.scanConcurrency, ScanConcurrencyFlag, 0,
+		"How many directories to read at once when scanning the source, in place of the "+common.EEnvironmentVariable.EnumerationPoolSize().Name+" environment variable, "+
+			"which defaults to 16. Raise it to find files sooner in trees of millions of files on file systems, such as ZFS, that serve many readers at once well. "+
+			"Also applies to scans of Blob and Azure Files sources.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.fromZFSSnapshot, FromZFSSnapshotFlag, "",
+		"Upload from the named snapshot of the ZFS dataset holding the source, through its .zfs/snapshot directory, "+
+			"so that files changing during the upload don't produce an inconsistent copy. Destination names are those of the live dataset. "+
+			"Use 'auto' to snapshot the dataset when the job starts and destroy the snapshot once the job has succeeded "+
+			"(it is kept if the job fails, so that the job can be resumed). Datasets mounted below the source are not included in the snapshot.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.fromInventory, FromInventoryFlag, "",
+		"List a Blob source from the report of a blob inventory run, given by the URL of its manifest, instead of listing it live, "+
+			"which saves hours on containers of billions of blobs. The report is read with the source's credentials, unless the URL has a SAS for the inventory's container. "+
+			"\n Blobs are copied as they were listed when the inventory was taken: those made since aren't copied, and downloads of those changed since fail. "+
+			"The inventory's rule must list blobs, in CSV or Parquet, with the Last-Modified and Content-Length fields, and the fields of any properties to preserve.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.transferReport, TransferReportFlag, "",
+		"Write a line about each transfer to this file as it finishes: its source and destination, status, size, start and end time, "+
+			"number of retries and, if it failed, why. Useful as an audit trail, or to build retry tooling on.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.transferReportFormat, TransferReportFormatFlag, ste.TransferReportFormatCSV,
+		"The format of the --"+TransferReportFlag+" file: csv (the default), or json for a JSON object per line.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.notifyWebhook, NotifyWebhookFlag, "",
+		"POST the outcome of the job to this URL, as JSON, once it finishes or fails: its status and exit code, the number of transfers "+
+			"completed, failed and skipped, the bytes transferred and how long it took. Useful to alert someone when an unattended job fails.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.notifyEmail, NotifyEmailFlag, "",
+		"Email the outcome of the job, as --"+NotifyWebhookFlag+" posts it, to these addresses (separated by commas), "+
+			"through the mail server in AZCOPY_SMTP_SERVER.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.preHook, PreHookFlag, "",
+		"Run this command, through the shell, before the source is scanned, e.g. to mount a dataset or take a snapshot of it. "+
+			"The job isn't started if the command fails. AZCOPY_JOB_ID, AZCOPY_COMMAND, AZCOPY_SOURCE and AZCOPY_DESTINATION are set in its environment.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.postHook, PostHookFlag, "",
+		"Run this command, through the shell, once the job has finished or failed, e.g. to unmount a dataset or destroy a snapshot. "+
+			"As well as what the --"+PreHookFlag+" command is given, AZCOPY_JOB_STATUS, AZCOPY_EXIT_CODE, AZCOPY_ERROR, AZCOPY_TRANSFERS_TOTAL, "+
+			"AZCOPY_TRANSFERS_COMPLETED, AZCOPY_TRANSFERS_FAILED, AZCOPY_TRANSFERS_SKIPPED, AZCOPY_BYTES_TRANSFERRED and AZCOPY_DURATION_SECONDS are set in its environment. "+
+			"The output of both commands goes to the job log.")
+
 	cpCmd.PersistentFlags().BoolVar(&raw.putMd5, "put-md5", false,
 		"Create an MD5 hash of each file, and save the hash as the Content-MD5 property of the destination"+
 			"blob or file.\n By default the hash is NOT created. Only available when uploading.")
@@ -1820,6 +2690,18 @@ func init() {
 		"Specifies how strictly MD5 hashes should be validated when downloading. Only available when downloading. "+
 			"\n Available options: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing (default 'FailIfDifferent').")
 
+	cpCmd.PersistentFlags().StringVar(&raw.fsync, FsyncFlag, "off",
+		"When downloading, when to sync files to disk: "+
+			"\n per-file syncs each file once it's complete, before it's renamed into place, and then the directory it's in, so that a file that has been saved is kept if the machine goes down. "+
+			"\n interval:N also syncs a file every N seconds while it's being written, so that no more of it than that is lost, and less is left for the file system to write out at once. "+
+			"\n per-chunk also syncs a file after every chunk, which is the safest, and slowest. "+
+			"\n off, the default, leaves it to the operating system, which keeps the fastest downloads but may lose the last of them if the machine goes down.")
+
+	cpCmd.PersistentFlags().StringVar(&raw.hashAlgorithm, "hash-algorithm", common.EContentHashType.MD5().String(),
+		"The hash that put-md5 creates and check-md5 validates. "+
+			"\n Available options: MD5, SHA256, CRC64 (default 'MD5'). MD5 is saved as the Content-MD5 property, and the others as metadata of the blob. "+
+			"\n Hashes other than MD5 can only be created when uploading to Blob storage.")
+
 	cpCmd.PersistentFlags().StringVar(&raw.includeFileAttributes, "include-attributes", "",
 		"(Windows only) Include files whose attributes match the attribute list. For example: A;S;R")
 
@@ -1931,10 +2813,21 @@ func init() {
 			"\n Provided key and its hash will be fetched from environment variables"+
 			"(CPK_ENCRYPTION_KEY and CPK_ENCRYPTION_KEY_SHA256 must be set).")
 
+	cpCmd.PersistentFlags().StringVar(&raw.encryptionScope, "encryption-scope", "",
+		"Encrypt the blobs that are uploaded or copied with this encryption scope of the destination account.")
+
+	cpCmd.PersistentFlags().BoolVar(&raw.s2sPreserveEncryptionScope, "s2s-preserve-encryption-scope", false,
+		"False by default. Encrypt blobs copied from one blob storage to another with the encryption scope of their source. "+
+			"\n The destination account must have encryption scopes of the same names. "+
+			"\n Blobs whose source has no encryption scope are encrypted with --encryption-scope, if given.")
+
 	// permanently hidden
 	// Hide the list-of-files flag since it is implemented only for Storage Explorer.
 	_ = cpCmd.PersistentFlags().MarkHidden("list-of-files")
 	_ = cpCmd.PersistentFlags().MarkHidden("s2s-get-properties-in-backend")
+	// Hide the continue-job flag since it is only for jobs resume to run copy with.
+	cpCmd.PersistentFlags().StringVar(&raw.continueJob, ContinueJobFlag, "", "Carry on the interrupted scan of the job with this ID.")
+	_ = cpCmd.PersistentFlags().MarkHidden(ContinueJobFlag)
 
 	// temp, to assist users with change in param names, by providing a clearer message when these obsolete ones are accidentally used
 	cpCmd.PersistentFlags().StringVar(&raw.legacyInclude, "include", "", "Legacy include param. DO NOT USE")
@@ -1966,5 +2859,8 @@ func init() {
 		"Specifies how hardlinks should be handled. "+
 			"\n This flag is only applicable when downloading from an Azure NFS file share, uploading "+
 			"to an Azure Files NFS share, or performing service-to-service copies involving Azure Files NFS. \n"+
-			"\n The only supported option is 'follow' (default), which copies hardlinks as regular, independent files at the destination.")
+			"\n 'follow' (default) copies hardlinks as regular, independent files at the destination. "+
+			"\n 'preserve' sends the content of each hard-linked file once, and recreates the other names as hard links when downloading "+
+			"or copying between local directories. Links are only made inside the destination. Not supported for NFS. "+
+			"\n Without 'preserve', blobs that stand in for hard links are downloaded as the empty files they are.")
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+// archivePacker gathers small files into archive packs (see common.ArchivePack), each of which is uploaded as one tar
+// blob at the root of the destination, instead of a blob per file. Files are named in a pack by their path under the
+// destination, so extracting a pack where it was downloaded to puts them where they'd have been downloaded to. The
+// index of every file in every pack is written as the packs are laid out, and uploaded beside them once they all are.
+type archivePacker struct {
+	jobID       common.JobID
+	smallerThan int64 // the size of file that is too big to pack
+	packSize    int64 // how much data to put in each pack
+
+	members  []common.ArchivePackMember
+	dataSize int64 // of the members so far
+
+	packCount   uint32
+	packedFiles uint64
+
+	index       *os.File
+	indexWriter *bufio.Writer
+}
+
+func newArchivePacker(jobID common.JobID, smallerThan, packSize int64) *archivePacker {
+	return &archivePacker{jobID: jobID, smallerThan: smallerThan, packSize: packSize}
+}
+
+// add takes the file of a transfer into the current pack, if it is small enough, and returns the transfer for the pack
+// if that fills it
+func (p *archivePacker) add(transfer common.CopyTransfer, sourceRoot, srcRelPath, dstRelPath string) (pack *common.CopyTransfer, taken bool, err error) {
+	if transfer.EntityType != common.EEntityType.File() || transfer.SourceSize >= p.smallerThan || srcRelPath == "" {
+		return nil, false, nil
+	}
+	name, err := url.PathUnescape(strings.TrimPrefix(dstRelPath, common.AZCOPY_PATH_SEPARATOR_STRING))
+	if err != nil || name == "" {
+		return nil, false, nil // it can still be uploaded on its own
+	}
+
+	p.members = append(p.members, common.ArchivePackMember{
+		Source:  common.GenerateFullPath(sourceRoot, srcRelPath),
+		Name:    name,
+		Size:    transfer.SourceSize,
+		ModTime: transfer.LastModifiedTime,
+	})
+	p.dataSize += transfer.SourceSize
+	if p.dataSize < p.packSize {
+		return nil, true, nil
+	}
+	pack, err = p.flush()
+	return pack, true, err
+}
+
+// flush returns the transfer for a pack of the files gathered so far, or nil if there aren't any. The list of files is
+// saved alongside the job's plan files, for the pack to be read from.
+func (p *archivePacker) flush() (*common.CopyTransfer, error) {
+	if len(p.members) == 0 {
+		return nil, nil
+	}
+	pack, err := common.NewArchivePack(p.members)
+	if err != nil {
+		return nil, err
+	}
+	if err = pack.Save(ste.ArchivePackListPath(p.jobID, p.packCount)); err != nil {
+		return nil, fmt.Errorf("saving the list of files in an archive pack: %w", err)
+	}
+
+	if err = p.addToIndex(pack, common.ArchivePackName(p.jobID, p.packCount)); err != nil {
+		return nil, fmt.Errorf("writing the index of the archive packs: %w", err)
+	}
+
+	// the source isn't a file of its own. The STE recognizes the pack's name, and reads the files in it instead.
+	name := common.AZCOPY_PATH_SEPARATOR_STRING + common.ArchivePackName(p.jobID, p.packCount)
+	p.packCount++
+	p.packedFiles += uint64(len(p.members))
+	p.members, p.dataSize = nil, 0
+
+	return &common.CopyTransfer{
+		Source:           name,
+		Destination:      name,
+		EntityType:       common.EEntityType.File(),
+		LastModifiedTime: pack.ModTime(),
+		SourceSize:       pack.Size(),
+	}, nil
+}
+
+func (p *archivePacker) addToIndex(pack *common.ArchivePack, name string) error {
+	if p.index == nil {
+		f, err := os.Create(ste.ArchivePackJobIndexPath(p.jobID))
+		if err != nil {
+			return err
+		}
+		p.index, p.indexWriter = f, bufio.NewWriter(f)
+	}
+	enc := json.NewEncoder(p.indexWriter)
+	for _, entry := range pack.JobIndexEntries(name) {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishIndex returns the transfer for the index of the packs, once they have all been flushed, or nil if there
+// weren't any
+func (p *archivePacker) finishIndex() (*common.CopyTransfer, error) {
+	if p.index == nil {
+		return nil, nil
+	}
+	err := p.indexWriter.Flush()
+	if closeErr := p.index.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("writing the index of the archive packs: %w", err)
+	}
+	fi, err := os.Stat(p.index.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	// as with the packs, the STE recognizes the name, and reads the index from where it was written
+	name := common.AZCOPY_PATH_SEPARATOR_STRING + common.ArchivePackJobIndexName(p.jobID)
+	return &common.CopyTransfer{
+		Source:           name,
+		Destination:      name,
+		EntityType:       common.EEntityType.File(),
+		LastModifiedTime: fi.ModTime(),
+		SourceSize:       fi.Size(),
+	}, nil
+}
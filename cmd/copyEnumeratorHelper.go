@@ -5,11 +5,25 @@ import (
 	"math/rand"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/common/parallel"
 	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
 )
 
 var EnumerationParallelism = 1
 var EnumerationParallelStatFiles = false
+var EnumerationTraversal = parallel.TraversalAuto
+
+// setTraversalOrder has scans of local folders, Blob containers and Azure Files shares read directories in the given order
+func setTraversalOrder(order common.TraversalOrder) {
+	switch order {
+	case common.ETraversalOrder.BreadthFirst():
+		EnumerationTraversal = parallel.TraversalBreadthFirst
+	case common.ETraversalOrder.DepthFirst():
+		EnumerationTraversal = parallel.TraversalDepthFirst
+	default:
+		EnumerationTraversal = parallel.TraversalAuto
+	}
+}
 
 // addTransfer accepts a new transfer, if the threshold is reached, dispatch a job part order.
 func addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer, cca *CookedCopyCmdArgs) error {
@@ -19,7 +33,9 @@ func addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer
 	// we do this so that in the case of large transfer, the transfer engine can get started
 	// while the frontend is still gathering more transfers
 	if len(e.Transfers.List) == NumOfFilesPerDispatchJobPart {
-		shuffleTransfers(e.Transfers.List)
+		if cca.order == common.ETransferOrder.AsScanned() {
+			shuffleTransfers(e.Transfers.List)
+		}
 		resp := jobsAdmin.ExecuteNewCopyJobPartOrder(*e)
 
 		if !resp.JobStarted {
@@ -31,6 +47,7 @@ func addTransfer(e *common.CopyJobPartOrderRequest, transfer common.CopyTransfer
 		}
 		e.Transfers = common.Transfers{}
 		e.PartNum++
+		cca.scanCheckpoint.commit(e.PartNum)
 	}
 
 	// only append the transfer after we've checked and dispatched a part
@@ -64,7 +81,9 @@ func shuffleTransfers(transfers []common.CopyTransfer) {
 // we need to send a last part with isFinalPart set to true, along with whatever transfers that still haven't been sent
 // dispatchFinalPart sends a last part with isFinalPart set to true, along with whatever transfers that still haven't been sent.
 func dispatchFinalPart(e *common.CopyJobPartOrderRequest, cca *CookedCopyCmdArgs) error {
-	shuffleTransfers(e.Transfers.List)
+	if cca.order == common.ETransferOrder.AsScanned() {
+		shuffleTransfers(e.Transfers.List)
+	}
 	e.IsFinalPart = true
 	resp := jobsAdmin.ExecuteNewCopyJobPartOrder(*e)
 
@@ -88,6 +107,10 @@ func dispatchFinalPart(e *common.CopyJobPartOrderRequest, cca *CookedCopyCmdArgs
 	}
 
 	common.LogToJobLogWithPrefix(FinalPartCreatedMessage, common.LogInfo)
+	cca.scanCheckpoint.finish()
+	if err := cca.enterSandbox(); err != nil {
+		return err
+	}
 
 	// set the flag on cca, to indicate the enumeration is done
 	cca.isEnumerationComplete = true
@@ -57,6 +57,10 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 	jobPartOrder.PreserveInfo = cca.preserveInfo
 	// We set preservePOSIXProperties if the customer has explicitly asked for this in transfer or if it is just a Posix-property only transfer
 	jobPartOrder.PreservePOSIXProperties = cca.preservePOSIXProperties || (cca.ForceWrite == common.EOverwriteOption.PosixProperties())
+	jobPartOrder.PreserveACLs = cca.preserveACLs
+	jobPartOrder.PreserveExtAttrs = cca.preserveExtAttrs
+	jobPartOrder.PreserveFileFlags = cca.preserveFileFlags
+	jobPartOrder.RestoreOwner = cca.preserveOwner && cca.FromTo.IsDownload()
 
 	// Infer on download so that we get LMT and MD5 on files download
 	// On S2S transfers the following rules apply:
@@ -72,6 +76,7 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 	jobPartOrder.DestLengthValidation = cca.CheckLength
 	jobPartOrder.S2SInvalidMetadataHandleOption = cca.s2sInvalidMetadataHandleOption
 	jobPartOrder.S2SPreserveBlobTags = cca.S2sPreserveBlobTags
+	jobPartOrder.S2SPreserveEncryptionScope = cca.s2sPreserveEncryptionScope
 
 	dest := cca.FromTo.To()
 	traverser, err = InitResourceTraverser(cca.Source, cca.FromTo.From(), ctx, InitResourceTraverserOptions{
@@ -86,6 +91,7 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 
 		PreservePermissions: cca.preservePermissions,
 		SymlinkHandling:     cca.SymlinkHandling,
+		HardlinkHandling:    cca.hardlinks,
 		PermanentDelete:     cca.permanentDeleteOption,
 		SyncHashType:        common.ESyncHashType.None(),
 		TrailingDotOption:   cca.trailingDot,
@@ -95,9 +101,23 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		IncludeDirectoryStubs:   cca.IncludeDirectoryStubs,
 		PreserveBlobTags:        cca.S2sPreserveBlobTags,
 		StripTopDir:             cca.StripTopDir,
+		OneFileSystem:           cca.OneFileSystem,
+		ExcludeNodump:           cca.excludeNodump,
+		IncrementNodumpSkipped: func() {
+			atomic.AddUint32(&cca.atomicSkippedNodumpCount, 1)
+		},
+		SpecialFileHandling: cca.specialFiles,
+		ReportSpecialFile: func(path, kind string) {
+			atomic.AddUint32(&cca.atomicSkippedSpecialFileCount, 1)
+			cca.skippedSpecialFiles.add(path, kind)
+		},
 
+		ScanCheckpoint:    cca.scanCheckpoint,
+		ScanConcurrency:   int(cca.scanConcurrency),
+		InventoryManifest: cca.fromInventory,
 		ExcludeContainers: cca.excludeContainer,
 		IncrementEnumeration: func(entityType common.EntityType) {
+			cca.scanProgress.found(entityType)
 			if common.IsNFSCopy() {
 				if entityType == common.EEntityType.Other() {
 					atomic.AddUint32(&cca.atomicSkippedSpecialFileCount, 1)
@@ -144,7 +164,7 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		return nil, errors.New("cannot combine list-of-files or include-path with account traversal")
 	}
 
-	if (srcLevel == ELocationLevel.Object() || cca.FromTo.From().IsLocal()) && dstLevel == ELocationLevel.Service() {
+	if (srcLevel == ELocationLevel.Object() || cca.FromTo.From().IsLocal() || cca.FromTo.From() == common.ELocation.URLList()) && dstLevel == ELocationLevel.Service() {
 		return nil, errors.New("cannot transfer individual files/folders to the root of a service. Add a container or directory to the destination URL")
 	}
 
@@ -275,12 +295,28 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 
 	// decide our folder transfer strategy
 	var message string
-	jobPartOrder.Fpo, message = NewFolderPropertyOption(cca.FromTo, cca.Recursive, cca.StripTopDir, filters, cca.preserveInfo, cca.preservePermissions.IsTruthy(), cca.preservePOSIXProperties, strings.EqualFold(cca.Destination.Value, common.Dev_Null), cca.IncludeDirectoryStubs)
+	jobPartOrder.Fpo, message = NewFolderPropertyOption(cca.FromTo, cca.Recursive, cca.StripTopDir, filters, cca.preserveInfo, cca.preservePermissions.IsTruthy(), cca.preservePOSIXProperties || cca.preserveACLs || cca.preserveExtAttrs || cca.preserveFileFlags, strings.EqualFold(cca.Destination.Value, common.Dev_Null), cca.IncludeDirectoryStubs)
 	if !cca.dryrunMode {
 		glcm.Info(message)
 	}
 	common.LogToJobLogWithPrefix(message, common.LogInfo)
 
+	var packer *archivePacker
+	if cca.packFilesSmallerThan > 0 {
+		packer = newArchivePacker(cca.jobID, cca.packFilesSmallerThan, cca.packSize)
+	}
+
+	orderer := newTransferOrderer(cca.order)
+	if orderer != nil {
+		common.LogToJobLogWithPrefix(fmt.Sprintf("Transfers are held until scanning has finished, for --%s=%s", OrderFlag, cca.order), common.LogInfo)
+	}
+	send := func(transfer common.CopyTransfer) error {
+		if orderer.hold(transfer) {
+			return nil
+		}
+		return addTransfer(&jobPartOrder, transfer, cca)
+	}
+
 	processor := func(object StoredObject) error {
 		// Start by resolving the name and creating the container
 		if object.ContainerName != "" {
@@ -315,14 +351,57 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		srcRelPath := cca.MakeEscapedRelativePath(true, isDestDir, cca.asSubdir, object)
 		dstRelPath := cca.MakeEscapedRelativePath(false, isDestDir, cca.asSubdir, object)
 
+		// found again by a scan that carries on from where it was interrupted
+		if cca.scanCheckpoint.alreadyOrdered(srcRelPath) {
+			return nil
+		}
+
 		transfer, shouldSendToSte := object.ToNewCopyTransfer(cca.autoDecompress && cca.FromTo.IsDownload(), srcRelPath, dstRelPath, cca.s2sPreserveAccessTier.Value(), jobPartOrder.Fpo, cca.SymlinkHandling, cca.hardlinks)
+
+		// Links have to wait until whatever they link to has been downloaded. They still go to the STE, so that
+		// they are in the plan for jobs resume.
+		if target, ok := common.TryReadMetadata(object.Metadata, common.POSIXHardlinkMeta); ok && target != nil &&
+			object.entityType == common.EEntityType.Hardlink() && cca.FromTo.To() == common.ELocation.Local() && shouldSendToSte {
+			if cca.deferredHardlinks == nil {
+				// without --hardlinks=preserve the metadata isn't trusted, and the stand-in is downloaded as the empty file it is
+				hardlinkStandInOnce.Do(func() {
+					WarnStdoutAndScanningLog("Some blobs stand in for hard links, and are downloaded as empty files. Use --hardlinks=preserve to make the links.")
+				})
+				transfer.EntityType = common.EEntityType.File()
+			} else if !cca.dryrunMode {
+				if err := cca.deferredHardlinks.add(common.GenerateFullPath(cca.Destination.ValueLocal(), dstRelPath), *target); err != nil {
+					WarnStdoutAndScanningLog("Skipping hard link: " + err.Error())
+					return nil
+				}
+			}
+		}
 		if !cca.S2sPreserveBlobTags {
 			transfer.BlobTags = cca.blobTagsMap
 		}
 
+		// the index of a job's packs is of no use once they've been extracted
+		if _, isIndex := common.ParseArchivePackJobIndexName(object.name); cca.extractArchivePacks && isIndex {
+			return nil
+		}
+
+		if packer != nil && shouldSendToSte {
+			pack, taken, err := packer.add(transfer, jobPartOrder.SourceRoot.ValueLocal(), srcRelPath, dstRelPath)
+			if err != nil {
+				return err
+			} else if taken {
+				if pack != nil {
+					return send(*pack)
+				}
+				return nil
+			}
+		}
+
 		if cca.dryrunMode && shouldSendToSte {
 			glcm.Dryrun(func(format common.OutputFormat) string {
 				src := common.GenerateFullPath(cca.Source.Value, srcRelPath)
+				if object.sourceURL != "" {
+					src = urlForLogging(object.sourceURL)
+				}
 				dst := common.GenerateFullPath(cca.Destination.Value, dstRelPath)
 
 				switch format {
@@ -360,15 +439,44 @@ func (cca *CookedCopyCmdArgs) initEnumerator(jobPartOrder common.CopyJobPartOrde
 		}
 
 		if shouldSendToSte {
-			return addTransfer(&jobPartOrder, transfer, cca)
+			return send(transfer)
 		}
 		return nil
 	}
 	finalizer := func() error {
+		if packer != nil {
+			if pack, err := packer.flush(); err != nil {
+				return err
+			} else if pack != nil {
+				if err = send(*pack); err != nil {
+					return err
+				}
+			}
+		}
+		if err := orderer.release(func(transfer common.CopyTransfer) error {
+			return addTransfer(&jobPartOrder, transfer, cca)
+		}); err != nil {
+			return err
+		}
+		if packer != nil {
+			// the index goes last, once the packs it points to are on their way
+			if index, err := packer.finishIndex(); err != nil {
+				return err
+			} else if index != nil {
+				if err = addTransfer(&jobPartOrder, *index, cca); err != nil {
+					return err
+				}
+			}
+			if packer.packCount > 0 {
+				message := fmt.Sprintf("%d small files will be uploaded in %d archive packs", packer.packedFiles, packer.packCount)
+				glcm.Info(message)
+				common.LogToJobLogWithPrefix(message, common.LogInfo)
+			}
+		}
 		return dispatchFinalPart(&jobPartOrder, cca)
 	}
 
-	return NewCopyEnumerator(traverser, filters, processor, finalizer), nil
+	return NewCopyEnumerator(traverser, filters, cca.scanProgress.tracking(processor), finalizer), nil
 }
 
 // This is condensed down into an individual function as we don't end up reusing the destination traverser at all.
@@ -654,6 +762,11 @@ func (cca *CookedCopyCmdArgs) MakeEscapedRelativePath(source bool, dstIsDir bool
 		return "\x00"
 	}
 
+	// an object from a list of URLs has no source root to be relative to
+	if source && object.sourceURL != "" {
+		return object.sourceURL
+	}
+
 	// source is a EXACT path to the file
 	if object.isSingleSourceFile() {
 		// If we're finding an object from the source, it returns "" if it's already got it.
@@ -692,6 +805,10 @@ func (cca *CookedCopyCmdArgs) MakeEscapedRelativePath(source bool, dstIsDir bool
 		// The source SAS has already been removed. No need to convert it to a URL or whatever.
 		// Save to a directory
 		rootDir := filepath.Base(cca.Source.Value)
+		if cca.liveSource != "" {
+			// name the root after the live dataset, not the snapshot directory we're reading it through
+			rootDir = filepath.Base(cca.liveSource)
+		}
 
 		/* In windows, when a user tries to copy whole volume (eg. D:\),  the upload destination
 		will contains "//"" in the files/directories names because of rootDir = "\" prefix.
@@ -23,6 +23,17 @@ func (cooked *CookedCopyCmdArgs) processArgs() (err error) {
 		common.LogPathFolder = ""
 	}
 
+	if err = registerJobRetention(); err != nil {
+		return err
+	}
+	if err = startTransferReport(cooked.transferReportPath, cooked.transferReportFormat); err != nil {
+		return err
+	}
+	if err = startNotifications("copy", cooked.Source.Value, cooked.Destination.Value, cooked.notifyWebhook, cooked.notifyEmail); err != nil {
+		return err
+	}
+	setUpHooks("copy", cooked.Source.Value, cooked.Destination.Value, cooked.preHook, cooked.postHook)
+
 	cooked.putBlobSize, err = blockSizeInBytes(cooked.PutBlobSizeMB)
 	if err != nil {
 		return err
@@ -152,18 +163,25 @@ func (cooked *CookedCopyCmdArgs) processArgs() (err error) {
 	if cooked.CpkOptions.CpkScopeInfo != "" || cooked.CpkOptions.CpkInfo {
 		// We only support transfer from source encrypted by user key when user wishes to download.
 		// Due to service limitation, S2S transfer is not supported for source encrypted by user key.
-		if cooked.FromTo.IsDownload() || cooked.FromTo.IsDelete() {
-			glcm.Info("Client Provided Key (CPK) for encryption/decryption is provided for download or delete scenario. " +
+		if cooked.FromTo.IsDownload() || cooked.FromTo.IsDelete() || cooked.FromTo.IsSetProperties() {
+			glcm.Info("Client Provided Key (CPK) for encryption/decryption is provided for download, delete or set-properties scenario. " +
 				"Assuming source is encrypted.")
 			cooked.CpkOptions.IsSourceEncrypted = true
+		} else if cooked.FromTo.IsS2S() {
+			glcm.Info(CpkS2SDestinationOnlyMsg)
 		}
 
 		// TODO: Remove these warnings once service starts supporting it
-		if cooked.blockBlobTier != common.EBlockBlobTier.None() || cooked.pageBlobTier != common.EPageBlobTier.None() {
+		if !cooked.FromTo.IsSetProperties() &&
+			(cooked.blockBlobTier != common.EBlockBlobTier.None() || cooked.pageBlobTier != common.EPageBlobTier.None()) {
 			glcm.Info("Tier is provided by user explicitly. Ignoring it because Azure Service currently does" +
 				" not support setting tier when client provided keys are involved.")
 		}
 	}
+	// an encryption scope is a key by name that only applies to what is written
+	if cooked.encryptionScope != "" {
+		cooked.CpkOptions.CpkScopeInfo = cooked.encryptionScope
+	}
 
 	if cooked.preserveInfo && !cooked.preservePermissions.IsTruthy() {
 		if common.IsNFSCopy() {
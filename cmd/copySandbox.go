@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+// openSandbox gets --sandbox ready, before the job's clients are made: from then on, connections are made through
+// Casper, and the source and AzCopy's own folders are opened beneath the directories that are opened for them now.
+// The scan runs outside the sandbox, since it needs to follow the tree wherever the source's directories are.
+func (cca *CookedCopyCmdArgs) openSandbox() (err error) {
+	if cca.credentialInfo.CredentialType.IsAzureOAuth() {
+		// a token is refreshed through the cache it was saved in, and the identity libraries' own connections
+		return errors.New("the --" + common.SandboxFlagName + " flag needs a SAS or shared key for the destination, rather than an OAuth token")
+	}
+
+	dirs := []string{sandboxSourceDir(cca.Source.ValueLocal()), common.AzcopyJobPlanFolder}
+	if common.LogPathFolder != "" {
+		dirs = append(dirs, common.LogPathFolder)
+	}
+	if cca.sandboxed, err = common.OpenSandbox(dirs...); err != nil {
+		return err
+	}
+
+	// the notification webhook goes over the default transport, as the clients that aren't the job's do
+	dial := common.NewDialer(net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	http.DefaultTransport.(*http.Transport).DialContext = dial
+	return nil
+}
+
+// enterSandbox enters the sandbox once the scan is over and every part of the job has been ordered, and only then
+// lets their transfers start
+func (cca *CookedCopyCmdArgs) enterSandbox() error {
+	if cca.sandboxed == nil {
+		return nil
+	}
+	if err := cca.sandboxed.Enter(); err != nil {
+		return err
+	}
+	common.LogToJobLogWithPrefix("Entered the sandbox", common.LogInfo)
+	jobsAdmin.ScheduleHeldJobParts(cca.jobID)
+	return nil
+}
+
+// sandboxSourceDir is the directory that the source is beneath: the source itself if it's a directory, or the one
+// that a file, or the files matched by a wildcard, are in
+func sandboxSourceDir(source string) string {
+	if i := strings.Index(source, "*"); i >= 0 {
+		return filepath.Dir(source[:i])
+	}
+	if fi, err := os.Stat(source); err == nil && fi.IsDir() {
+		return source
+	}
+	return filepath.Dir(source)
+}
@@ -19,11 +19,38 @@ func (cooked *CookedCopyCmdArgs) validate() (err error) {
 		return err
 	}
 
+	if err = validateOneFileSystem(cooked.OneFileSystem, cooked.FromTo); err != nil {
+		return err
+	}
+
+	if err = validateExcludeNodump(cooked.excludeNodump, cooked.FromTo); err != nil {
+		return err
+	}
+
 	allowAutoDecompress := cooked.FromTo == common.EFromTo.BlobLocal() || cooked.FromTo == common.EFromTo.FileLocal() || cooked.FromTo == common.EFromTo.FileNFSLocal()
 	if cooked.autoDecompress && !allowAutoDecompress {
 		return errors.New("automatic decompression is only supported for downloads from Blob and Azure Files") // as at Sept 2019, our ADLS Gen 2 Swagger does not include content-encoding for directory (path) listings so we can't support it there
 	}
 
+	if err = validateArchivePacks(cooked); err != nil {
+		return err
+	}
+	if err = validateArchiveExtraction(cooked.untar, cooked.unzip, cooked.FromTo); err != nil {
+		return err
+	}
+	if err = validateUploadCompression(cooked); err != nil {
+		return err
+	}
+	if err = validateBlockDelta(cooked); err != nil {
+		return err
+	}
+	if err = validateClientSideEncryption(cooked); err != nil {
+		return err
+	}
+	if err = validateSandbox(cooked); err != nil {
+		return err
+	}
+
 	cooked.blockSize, err = blockSizeInBytes(cooked.BlockSizeMB)
 	if err != nil {
 		return err
@@ -84,7 +111,11 @@ func (cooked *CookedCopyCmdArgs) validate() (err error) {
 		return errors.New("cannot use both cpk-by-name and cpk-by-value at the same time")
 	}
 
-	if cooked.cpkByName != "" || cooked.cpkByValue {
+	if err = validateEncryptionScope(cooked.encryptionScope, cooked.s2sPreserveEncryptionScope, cooked.cpkByName, cooked.cpkByValue, cooked.FromTo); err != nil {
+		return err
+	}
+
+	if cooked.cpkByName != "" || cooked.cpkByValue || cooked.encryptionScope != "" {
 		destUrl, _ := url.Parse(cooked.Destination.Value)
 		if strings.Contains(destUrl.Host, "dfs.core.windows.net") {
 			return errors.New("client provided keys (CPK) based encryption is only supported with blob endpoints (blob.core.windows.net)")
@@ -107,12 +138,40 @@ func (cooked *CookedCopyCmdArgs) validate() (err error) {
 		if err = validatePreserveOwner(cooked.preserveOwner, cooked.FromTo); err != nil {
 			return err
 		}
+
+		if err = validateIDMapFile(cooked.idmapFile, cooked.preserveOwner, cooked.FromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveACLs(cooked.preserveACLs, cooked.FromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveExtAttrs(cooked.preserveExtAttrs, cooked.FromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveFileFlags(cooked.preserveFileFlags, cooked.FromTo); err != nil {
+			return err
+		}
 	}
 
 	if err = validateBackupMode(cooked.backupMode, cooked.FromTo); err != nil {
 		return err
 	}
 
+	if err = validateDownloadTempDir(cooked.FromTo, cooked.Destination); err != nil {
+		return err
+	}
+
+	if err = validateFromZFSSnapshot(cooked.zfsSnapshotName, cooked.FromTo); err != nil {
+		return err
+	}
+
+	if err = validateFromInventory(cooked); err != nil {
+		return err
+	}
+
 	// check for the flag value relative to fromTo location type
 	// Example1: for Local to Blob, preserve-last-modified-time flag should not be set to true
 	// Example2: for Blob to Local, follow-symlinks, blob-tier flags should not be provided with values.
@@ -217,6 +276,7 @@ func (cooked *CookedCopyCmdArgs) validate() (err error) {
 		common.EFromTo.FileBlob(),
 		common.EFromTo.FileFile(),
 		common.EFromTo.GCPBlob(),
+		common.EFromTo.URLListBlob(),
 		common.EFromTo.FileNFSFileNFS():
 
 		if cooked.preserveLastModifiedTime {
@@ -249,6 +309,12 @@ func (cooked *CookedCopyCmdArgs) validate() (err error) {
 	if err = validateMd5Option(cooked.md5ValidationOption, cooked.FromTo); err != nil {
 		return err
 	}
+	if err = validateFsync(cooked.fsync, cooked.FromTo); err != nil {
+		return err
+	}
+	if err = validateHashAlgorithm(cooked.hashAlgorithm, cooked.putMd5, cooked.FromTo); err != nil {
+		return err
+	}
 	if (len(cooked.IncludeFileAttributes) > 0 || len(cooked.ExcludeFileAttributes) > 0) && cooked.FromTo.From() != common.ELocation.Local() {
 		return errors.New("cannot check file attributes on remote objects")
 	}
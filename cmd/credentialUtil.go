@@ -271,7 +271,8 @@ func checkAuthSafeForTarget(ct common.CredentialType, resource, extraSuffixesAAD
 		}
 
 		// just check with minio. No need to have our own list of S3 domains, since minio effectively
-		// has that list already, we can't talk to anything outside that list because minio won't let us,
+		// has that list already, we can't talk to anything outside that list (other than a service named in
+		// AZCOPY_S3_ENDPOINT) because minio won't let us,
 		// and the parsing of s3 URL is non-trivial.  E.g. can't just look for the ending since
 		// something like https://someApi.execute-api.someRegion.amazonaws.com is AWS but is a customer-
 		// written code, not S3.
@@ -283,7 +284,7 @@ func checkAuthSafeForTarget(ct common.CredentialType, resource, extraSuffixesAAD
 			parts, err := common.NewS3URLParts(*u) // strip any leading bucket name from URL, to get an endpoint we can pass to s3utils
 			if err == nil {
 				u, err := url.Parse("https://" + parts.Endpoint)
-				ok = err == nil && s3utils.IsAmazonEndpoint(*u)
+				ok = err == nil && (s3utils.IsAmazonEndpoint(*u) || parts.IsS3Compatible())
 			}
 		}
 
@@ -356,12 +357,7 @@ func isPublic(ctx context.Context, blobResourceURL string, cpkOptions common.Cpk
 	}
 
 	// This request will not be logged. This can fail, and too many Cx do not like this.
-	clientOptions := ste.NewClientOptions(policy.RetryOptions{
-		MaxRetries:    ste.UploadMaxTries,
-		TryTimeout:    ste.UploadTryTimeout,
-		RetryDelay:    ste.UploadRetryDelay,
-		MaxRetryDelay: ste.UploadMaxRetryDelay,
-	}, policy.TelemetryOptions{
+	clientOptions := ste.NewClientOptions(ste.RetryOptions(), policy.TelemetryOptions{
 		ApplicationID: common.AddUserAgentPrefix(common.UserAgent),
 	}, nil, ste.LogOptions{}, nil, nil)
 
@@ -389,12 +385,7 @@ func isPublic(ctx context.Context, blobResourceURL string, cpkOptions common.Cpk
 // mdAccountNeedsOAuth pings the passed in md account, and checks if we need additional token with Disk-socpe
 func mdAccountNeedsOAuth(ctx context.Context, blobResourceURL string, cpkOptions common.CpkOptions) bool {
 	// This request will not be logged. This can fail, and too many Cx do not like this.
-	clientOptions := ste.NewClientOptions(policy.RetryOptions{
-		MaxRetries:    ste.UploadMaxTries,
-		TryTimeout:    ste.UploadTryTimeout,
-		RetryDelay:    ste.UploadRetryDelay,
-		MaxRetryDelay: ste.UploadMaxRetryDelay,
-	}, policy.TelemetryOptions{
+	clientOptions := ste.NewClientOptions(ste.RetryOptions(), policy.TelemetryOptions{
 		ApplicationID: common.AddUserAgentPrefix(common.UserAgent),
 	}, nil, ste.LogOptions{}, nil, nil)
 
@@ -425,7 +416,9 @@ func doGetCredentialTypeForLocation(ctx context.Context, location common.Locatio
 	err = nil
 
 	switch location {
-	case common.ELocation.Local(), common.ELocation.Benchmark(), common.ELocation.None(), common.ELocation.Pipe():
+	case common.ELocation.Local(), common.ELocation.Benchmark(), common.ELocation.None(), common.ELocation.Pipe(),
+		common.ELocation.Provider(), // providers take care of their own credentials
+		common.ELocation.URLList():  // the URLs in a list are public, or carry their own signatures
 		return common.ECredentialType.Anonymous(), false, nil
 	}
 
@@ -560,6 +553,8 @@ func getCredentialType(ctx context.Context, raw rawFromToInfo, cpkOptions common
 	case raw.fromTo.From().IsRemote() && raw.fromTo.To().IsLocal():
 		// we authenticate to the source.
 		credType, _, err = getCredentialTypeForLocation(ctx, raw.fromTo.From(), raw.source, true, cpkOptions)
+	case raw.fromTo == common.EFromTo.LocalLocal():
+		credType = common.ECredentialType.Anonymous() // nothing to authenticate to
 	default:
 		credType = common.ECredentialType.Anonymous()
 		// Log the FromTo types which getCredentialType hasn't solved, in case of miss-use.
@@ -583,12 +578,7 @@ func createClientOptions(logger common.ILoggerResetable, srcCred *common.ScopedT
 		logOptions.Log = logger.Log
 		logOptions.ShouldLog = logger.ShouldLog
 	}
-	return ste.NewClientOptions(policy.RetryOptions{
-		MaxRetries:    ste.UploadMaxTries,
-		TryTimeout:    ste.UploadTryTimeout,
-		RetryDelay:    ste.UploadRetryDelay,
-		MaxRetryDelay: ste.UploadMaxRetryDelay,
-	}, policy.TelemetryOptions{
+	return ste.NewClientOptions(ste.RetryOptions(), policy.TelemetryOptions{
 		ApplicationID: common.AddUserAgentPrefix(common.UserAgent),
 	}, ste.NewAzcopyHTTPClient(frontEndMaxIdleConnectionsPerHost), logOptions, srcCred, reauthCred)
 }
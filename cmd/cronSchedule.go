@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is when a schedule runs, as the five fields of a crontab(5) line give it: minute, hour, day of the
+// month, month and day of the week. Each field is a bit set of the values it matches.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// as cron does, a day matches if either of the day fields does, unless one of them is *, when both must
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// cronShorthands are the @ forms that cron accepts in place of the five fields
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		shorthand, ok := cronShorthands[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("'%s' isn't one of @yearly, @monthly, @weekly, @daily or @hourly", fields[0])
+		}
+		fields = strings.Fields(shorthand)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("'%s' must have five fields (minute, hour, day of the month, month and day of the week), or be one of @yearly, @monthly, @weekly, @daily or @hourly", expr)
+	}
+
+	c := &cronSchedule{anyDayOfMonth: strings.HasPrefix(fields[2], "*"), anyDayOfWeek: strings.HasPrefix(fields[4], "*")}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if c.dayOfMonth, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of the month: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	// Sunday is both 0 and 7
+	if c.dayOfWeek, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("day of the week: %w", err)
+	}
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek |= 1
+	}
+	return c, nil
+}
+
+// parseCronField parses a comma-separated list of *, values and ranges, each of which may have a /step
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		valueRange, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("'%s' isn't a valid step", stepText)
+			}
+		}
+
+		low, high := min, max
+		if valueRange != "*" {
+			lowText, highText, isRange := strings.Cut(valueRange, "-")
+			var err error
+			if low, err = parseCronValue(lowText, min, max, names); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = parseCronValue(highText, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// as cron does, 5/15 means from 5 to the end, every 15
+				high = max
+			}
+			if high < low {
+				return 0, fmt.Errorf("'%s' is a range that ends before it starts", valueRange)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(text string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("'%s' isn't a value from %d to %d", text, min, max)
+	}
+	return v, nil
+}
+
+// matches says whether the schedule runs in the minute t is in
+func (c *cronSchedule) matches(t time.Time) bool {
+	return c.month&(1<<uint(t.Month())) != 0 && c.dayMatches(t) &&
+		c.hour&(1<<uint(t.Hour())) != 0 && c.minute&(1<<uint(t.Minute())) != 0
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// next is the first minute after after that the schedule runs in, or the zero time if it doesn't in the next five
+// years, as with February 30th. A field that doesn't match skips to the start of the next month, day or hour, rather
+// than trying every minute in between.
+func (c *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
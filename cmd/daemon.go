@@ -0,0 +1,779 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/spf13/cobra"
+)
+
+// daemonConfig is what the daemon reads from its --config file, and rereads on SIGHUP.
+type daemonConfig struct {
+	MaxConcurrentJobs int               // jobs beyond this many wait their turn
+	MaxFinishedJobs   int               // finished jobs beyond this many are forgotten, oldest first
+	Environment       map[string]string // added to the environment of every job
+	Schedules         []daemonSchedule  // jobs that the daemon submits itself, when their schedules say
+}
+
+const defaultMaxFinishedDaemonJobs = 1000
+
+func loadDaemonConfig(path string) (daemonConfig, error) {
+	config := daemonConfig{}
+	if path != "" {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err = json.Unmarshal(buf, &config); err != nil {
+			return config, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	if config.MaxConcurrentJobs <= 0 {
+		config.MaxConcurrentJobs = 1
+	}
+	if config.MaxFinishedJobs <= 0 {
+		config.MaxFinishedJobs = defaultMaxFinishedDaemonJobs
+	}
+	if err := parseDaemonSchedules(config.Schedules); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+type daemonJobState string
+
+const (
+	daemonJobQueued    daemonJobState = "Queued"
+	daemonJobRunning   daemonJobState = "Running"
+	daemonJobSucceeded daemonJobState = "Succeeded"
+	daemonJobFailed    daemonJobState = "Failed"
+	daemonJobCancelled daemonJobState = "Cancelled"
+)
+
+var errNoSuchDaemonJob = errors.New("no such job")
+
+// daemonJob is one command submitted to the daemon. It's numbered by the daemon, since the AzCopy job ID
+// is only chosen once the process running it has started.
+type daemonJob struct {
+	ID        int
+	Args      []string
+	State     daemonJobState
+	PID       int `json:",omitempty"`
+	ExitCode  int
+	Submitted time.Time
+	Started   time.Time `json:",omitempty"`
+	Finished  time.Time `json:",omitempty"`
+	LogFile   string
+	Error     string `json:",omitempty"`
+	JobID     string `json:",omitempty"` // the AzCopy job ID, once the process has reported it
+	Paused    bool   `json:",omitempty"`
+	Schedule  string `json:",omitempty"` // the name of the schedule that submitted the job, if one did
+	// Progress is the latest progress report from the process, the same as 'azcopy jobs show' gives with --output-type=json
+	Progress json.RawMessage `json:",omitempty"`
+
+	cmd       *exec.Cmd
+	cancelled bool
+	updated   chan struct{} // closed when the job changes, for anyone watching it
+}
+
+// notify wakes up anyone watching the job. d.mu must be held.
+func (j *daemonJob) notify() {
+	if j.updated != nil {
+		close(j.updated)
+		j.updated = nil
+	}
+}
+
+func (j *daemonJob) done() bool {
+	return j.State != daemonJobQueued && j.State != daemonJobRunning
+}
+
+type daemonRequest struct {
+	Command  string   // "submit", "status", "pause", "resume", "cancel", "schedules" or "run-schedule"
+	Args     []string // for submit, the AzCopy command line, without "azcopy"
+	Job      int      // for pause, resume and cancel, the daemon's number for the job
+	Schedule string   // for run-schedule, the name of the schedule
+}
+
+type daemonResponse struct {
+	Error     string           `json:",omitempty"`
+	Jobs      []daemonJob      `json:",omitempty"`
+	Schedules []scheduleStatus `json:",omitempty"`
+}
+
+// daemonCommands are the commands that can be submitted; the rest are either interactive or pointless in the background.
+var daemonCommands = map[string]bool{
+	"copy": true, "cp": true, "c": true,
+	"sync":           true,
+	"remove":         true,
+	"rm":             true,
+	"set-properties": true,
+	"jobs":           true,
+}
+
+// daemonForbiddenFlags can't be given to submitted jobs, since they'd have the daemon run commands of the submitter's
+// choosing as its own user. Scheduled jobs, which come from the daemon's own config, may use them.
+var daemonForbiddenFlags = []string{PreHookFlag, PostHookFlag, FromZFSSnapshotFlag}
+
+type azcopyDaemon struct {
+	mu       sync.Mutex
+	config   daemonConfig
+	jobs     []*daemonJob // in the order they were submitted
+	nextID   int
+	running  int
+	stopping bool
+	finished sync.WaitGroup
+
+	// scheduleRuns is what each schedule last did, by name
+	scheduleRuns map[string]*scheduleRun
+
+	logDir string
+	// command makes the process for a job; it's swapped out in tests
+	command func(args []string) *exec.Cmd
+}
+
+func newAzcopyDaemon(config daemonConfig, logDir string) (*azcopyDaemon, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return &azcopyDaemon{
+		config: config,
+		logDir: logDir,
+		command: func(args []string) *exec.Cmd {
+			return exec.Command(exe, args...)
+		},
+	}, nil
+}
+
+func (d *azcopyDaemon) logf(format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	glcm.Info(msg)
+	common.LogToJobLogWithPrefix(msg, common.LogInfo)
+}
+
+func (d *azcopyDaemon) handle(req daemonRequest) daemonResponse {
+	switch req.Command {
+	case "submit":
+		job, err := d.submit(req.Args)
+		if err != nil {
+			return daemonResponse{Error: err.Error()}
+		}
+		return daemonResponse{Jobs: []daemonJob{job}}
+	case "status":
+		return daemonResponse{Jobs: d.status()}
+	case "pause", "resume", "cancel":
+		job, err := d.control(req.Job, req.Command)
+		if err != nil {
+			return daemonResponse{Error: err.Error()}
+		}
+		return daemonResponse{Jobs: []daemonJob{job}}
+	case "schedules":
+		return daemonResponse{Schedules: d.schedules(time.Now())}
+	case "run-schedule":
+		job, err := d.runScheduleNow(req.Schedule)
+		if err != nil {
+			return daemonResponse{Error: err.Error()}
+		}
+		return daemonResponse{Jobs: []daemonJob{job}}
+	default:
+		return daemonResponse{Error: fmt.Sprintf("unknown daemon request %q", req.Command)}
+	}
+}
+
+func (d *azcopyDaemon) submit(args []string) (daemonJob, error) {
+	if len(args) == 0 || !daemonCommands[args[0]] {
+		return daemonJob{}, errors.New("only copy, sync, remove, set-properties and jobs commands can be submitted")
+	}
+	for _, arg := range args[1:] {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if strings.HasPrefix(arg, "--") && slices.Contains(daemonForbiddenFlags, name) {
+			return daemonJob{}, fmt.Errorf("--%s can't be given to a submitted job, since it runs commands as the daemon's user", name)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopping {
+		return daemonJob{}, errors.New("the daemon is shutting down")
+	}
+	return *d.queue(args, ""), nil
+}
+
+// queue adds a job, submitted by the schedule called schedule if that isn't empty, and starts it if it can.
+// A scheduled job's output is added to the schedule's log, rather than going to one of its own. d.mu must be held.
+func (d *azcopyDaemon) queue(args []string, schedule string) *daemonJob {
+	d.forgetFinishedJobs()
+	d.nextID++
+	job := &daemonJob{
+		ID:        d.nextID,
+		Args:      args,
+		State:     daemonJobQueued,
+		Submitted: time.Now(),
+		Schedule:  schedule,
+	}
+	if schedule == "" {
+		job.LogFile = filepath.Join(d.logDir, fmt.Sprintf("daemon-%d.log", job.ID))
+		d.logf("Job %d submitted: %s", job.ID, strings.Join(args, " "))
+	} else {
+		job.LogFile = filepath.Join(d.logDir, "schedule-"+schedule+".log")
+		d.logf("Job %d submitted by schedule %s: %s", job.ID, schedule, strings.Join(args, " "))
+	}
+	d.jobs = append(d.jobs, job)
+
+	d.startQueuedJobs()
+	return job
+}
+
+func (d *azcopyDaemon) status() []daemonJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]daemonJob, len(d.jobs))
+	for i, j := range d.jobs {
+		result[i] = *j
+	}
+	return result
+}
+
+// watch returns the job numbered id, and a channel that is closed when it next changes, or nil if it has already finished.
+func (d *azcopyDaemon) watch(id int) (daemonJob, <-chan struct{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job, err := d.job(id)
+	if err != nil {
+		return daemonJob{}, nil, err
+	}
+	if job.done() {
+		return *job, nil, nil
+	}
+	if job.updated == nil {
+		job.updated = make(chan struct{})
+	}
+	return *job, job.updated, nil
+}
+
+// job finds the job numbered id. d.mu must be held.
+func (d *azcopyDaemon) job(id int) (*daemonJob, error) {
+	i := sort.Search(len(d.jobs), func(i int) bool { return d.jobs[i].ID >= id })
+	if i == len(d.jobs) || d.jobs[i].ID != id {
+		return nil, fmt.Errorf("%w: %d", errNoSuchDaemonJob, id)
+	}
+	return d.jobs[i], nil
+}
+
+// forgetFinishedJobs drops the oldest finished jobs beyond MaxFinishedJobs, so that a daemon that runs for months
+// doesn't hold on to every job it has ever run. Their log files are left where they are. d.mu must be held.
+func (d *azcopyDaemon) forgetFinishedJobs() {
+	limit := d.config.MaxFinishedJobs
+	if limit <= 0 {
+		limit = defaultMaxFinishedDaemonJobs
+	}
+	finished := 0
+	for _, job := range d.jobs {
+		if job.done() {
+			finished++
+		}
+	}
+	if finished <= limit {
+		return
+	}
+
+	kept := d.jobs[:0]
+	for _, job := range d.jobs {
+		if job.done() && finished > limit {
+			finished--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	clear(d.jobs[len(kept):])
+	d.jobs = kept
+}
+
+// control pauses, resumes or cancels a job. Pausing and resuming need the signals that AzCopy
+// listens for, so they are only possible once the job has reported its first progress.
+func (d *azcopyDaemon) control(id int, action string) (daemonJob, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job, err := d.job(id)
+	if err != nil {
+		return daemonJob{}, err
+	}
+
+	switch {
+	case action != "pause" && action != "resume" && action != "cancel":
+		return *job, fmt.Errorf("unknown action %q", action)
+	case action == "cancel" && job.State == daemonJobQueued:
+		job.State, job.Finished = daemonJobCancelled, time.Now()
+	case job.State != daemonJobRunning:
+		return *job, fmt.Errorf("job %d is not running", id)
+	case action == "cancel":
+		if err = interruptProcess(job.cmd.Process); err == nil {
+			job.cancelled = true
+		}
+	case job.Progress == nil:
+		return *job, fmt.Errorf("job %d has not started transferring yet", id)
+	case action == "pause":
+		if err = pauseProcess(job.cmd.Process); err == nil {
+			job.Paused = true
+		}
+	default:
+		if err = resumeProcess(job.cmd.Process); err == nil {
+			job.Paused = false
+		}
+	}
+	if err != nil {
+		return *job, fmt.Errorf("failed to %s job %d: %w", action, id, err)
+	}
+
+	d.logf("Job %d: %s requested", id, action)
+	job.notify()
+	return *job, nil
+}
+
+// startQueuedJobs starts waiting jobs, oldest first, until MaxConcurrentJobs are running. d.mu must be held.
+func (d *azcopyDaemon) startQueuedJobs() {
+	for _, job := range d.jobs {
+		if d.stopping || d.running >= d.config.MaxConcurrentJobs {
+			return
+		}
+		if job.State == daemonJobQueued {
+			d.start(job)
+		}
+	}
+}
+
+// start runs job in a process of its own. d.mu must be held.
+func (d *azcopyDaemon) start(job *daemonJob) {
+	job.Started = time.Now()
+
+	logFile, err := os.OpenFile(job.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		d.fail(job, err)
+		return
+	}
+	if job.Schedule != "" {
+		// a schedule's runs all go to the same log, so mark where each starts
+		_, _ = fmt.Fprintf(logFile, "=== Job %d, started %s ===\n", job.ID, job.Started.Format(time.RFC3339))
+	}
+
+	// the JSON output is what the daemon learns the job ID and progress from
+	args := job.Args
+	if !hasOutputTypeFlag(args) {
+		args = append(args[:len(args):len(args)], "--output-type=json")
+	}
+	cmd := d.command(args)
+	cmd.Stdout, cmd.Stderr = &daemonJobOutput{d: d, job: job, w: logFile}, logFile
+	cmd.Env = os.Environ()
+	for k, v := range d.config.Environment {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if err = cmd.Start(); err != nil {
+		_ = logFile.Close()
+		d.fail(job, err)
+		return
+	}
+
+	job.cmd, job.PID, job.State = cmd, cmd.Process.Pid, daemonJobRunning
+	job.notify()
+	d.running++
+	d.finished.Add(1)
+	d.logf("Job %d started as process %d", job.ID, job.PID)
+
+	go func() {
+		defer d.finished.Done()
+		err := cmd.Wait()
+		_ = logFile.Close()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		job.Finished, job.cmd, job.Paused = time.Now(), nil, false
+		job.ExitCode = cmd.ProcessState.ExitCode()
+		if job.cancelled {
+			job.State = daemonJobCancelled
+		} else if err == nil {
+			job.State = daemonJobSucceeded
+		} else {
+			job.State, job.Error = daemonJobFailed, err.Error()
+		}
+		job.notify()
+		d.running--
+		d.logf("Job %d finished: %s (exit code %d)", job.ID, job.State, job.ExitCode)
+		d.forgetFinishedJobs()
+		d.startQueuedJobs()
+	}()
+}
+
+func hasOutputTypeFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == "--output-type" || strings.HasPrefix(arg, "--output-type=") {
+			return true
+		}
+	}
+	return false
+}
+
+// daemonJobOutput writes a job's output to its log file, and picks the job ID and progress out of it on the way.
+type daemonJobOutput struct {
+	d    *azcopyDaemon
+	job  *daemonJob
+	w    io.Writer
+	line []byte
+}
+
+// maxDaemonOutputLine is the longest line that is looked at; longer ones are only logged
+const maxDaemonOutputLine = 1024 * 1024
+
+func (o *daemonJobOutput) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	for _, b := range p[:n] {
+		if b != '\n' {
+			if len(o.line) < maxDaemonOutputLine {
+				o.line = append(o.line, b)
+			}
+			continue
+		}
+		o.parse(o.line)
+		o.line = o.line[:0]
+	}
+	return n, err
+}
+
+func (o *daemonJobOutput) parse(line []byte) {
+	var msg common.JsonOutputTemplate
+	if json.Unmarshal(line, &msg) != nil {
+		return
+	}
+
+	switch msg.MessageType {
+	case common.EOutputMessageType.Init().String():
+		var init common.InitMsgJsonTemplate
+		if json.Unmarshal([]byte(msg.MessageContent), &init) != nil || init.IsCleanupJob {
+			return
+		}
+		o.d.mu.Lock()
+		if o.job.JobID == "" {
+			o.job.JobID = init.JobID
+			o.job.notify()
+		}
+		o.d.mu.Unlock()
+	case common.EOutputMessageType.Progress().String(), common.EOutputMessageType.EndOfJob().String():
+		if !json.Valid([]byte(msg.MessageContent)) {
+			return
+		}
+		o.d.mu.Lock()
+		o.job.Progress = json.RawMessage(msg.MessageContent)
+		o.job.notify()
+		o.d.mu.Unlock()
+	}
+}
+
+func (d *azcopyDaemon) fail(job *daemonJob, err error) {
+	job.State, job.Error, job.Finished = daemonJobFailed, err.Error(), time.Now()
+	job.notify()
+	d.logf("Job %d could not be started: %s", job.ID, err)
+}
+
+// reload swaps in a new config. Running jobs keep the environment they started with.
+func (d *azcopyDaemon) reload(config daemonConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = config
+	d.startQueuedJobs()
+}
+
+// stop stops new jobs from starting, interrupts the running ones so that they cancel cleanly, and waits for them.
+func (d *azcopyDaemon) stop() {
+	d.mu.Lock()
+	d.stopping = true
+	for _, job := range d.jobs {
+		if job.cmd != nil {
+			_ = interruptProcess(job.cmd.Process)
+		}
+	}
+	d.mu.Unlock()
+	d.finished.Wait()
+}
+
+// serve answers one request per connection until l is closed.
+func (d *azcopyDaemon) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var req daemonRequest
+			resp := daemonResponse{}
+			if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+				resp.Error = "invalid request: " + err.Error()
+			} else {
+				resp = d.handle(req)
+			}
+			_ = json.NewEncoder(conn).Encode(resp)
+		}()
+	}
+}
+
+// callDaemon sends req to the daemon listening on socketPath.
+func callDaemon(socketPath string, req daemonRequest) (daemonResponse, error) {
+	var resp daemonResponse
+	conn, err := net.DialTimeout("unix", socketPath, 10*time.Second)
+	if err != nil {
+		return resp, fmt.Errorf("cannot reach the AzCopy daemon at %s: %w", socketPath, err)
+	}
+	defer conn.Close()
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// defaultDaemonPath is where the daemon keeps its socket or pidfile: /var/run for root, as rc.d expects, or the log folder for anyone else.
+func defaultDaemonPath(ext string) string {
+	if os.Geteuid() == 0 {
+		return "/var/run/azcopy." + ext
+	}
+	return filepath.Join(common.LogPathFolder, "azcopy-daemon."+ext)
+}
+
+type rawDaemonCmdArgs struct {
+	socket     string
+	pidfile    string
+	config     string
+	listen     string
+	tlsCert    string
+	tlsKey     string
+	foreground bool
+}
+
+func (raw rawDaemonCmdArgs) run() error {
+	if raw.socket == "" {
+		raw.socket = defaultDaemonPath("sock")
+	}
+	if raw.pidfile == "" {
+		raw.pidfile = defaultDaemonPath("pid")
+	}
+
+	config, err := loadDaemonConfig(raw.config)
+	if err != nil {
+		return err
+	}
+
+	if !raw.foreground {
+		pid, err := detachDaemon(append(os.Args[1:], "--foreground"))
+		if err != nil {
+			return fmt.Errorf("failed to start the daemon: %w", err)
+		}
+		glcm.Info(fmt.Sprintf("AzCopy daemon started as process %d", pid))
+		return nil
+	}
+
+	pidfile, err := lockPidfile(raw.pidfile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(raw.pidfile)
+		_ = pidfile.Close()
+	}()
+
+	// a socket left behind by a daemon that didn't get to clean up would stop us listening; the pidfile lock says it's not in use
+	_ = os.Remove(raw.socket)
+	oldMask := setUmask(0077)
+	listener, err := net.Listen("unix", raw.socket)
+	setUmask(oldMask)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(raw.socket)
+
+	d, err := newAzcopyDaemon(config, common.LogPathFolder)
+	if err != nil {
+		return err
+	}
+	go d.serve(listener)
+	d.logf("AzCopy daemon listening on %s", raw.socket)
+
+	stopScheduling := make(chan struct{})
+	go d.runSchedules(stopScheduling)
+
+	if raw.listen != "" {
+		token := common.GetEnvironmentVariable(common.EEnvironmentVariable.DaemonAPIToken())
+		apiListener, err := listenAPI(raw.listen, token, raw.tlsCert, raw.tlsKey)
+		if err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("cannot serve the API on %s: %w", raw.listen, err)
+		}
+		if path, ok := strings.CutPrefix(raw.listen, "unix:"); ok {
+			defer os.Remove(path)
+		}
+		api := d.serveAPI(apiListener, token)
+		defer api.Close()
+		d.logf("AzCopy daemon serving its API on %s", raw.listen)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append(daemonReloadSignals, os.Interrupt, syscall.SIGTERM)...)
+	for sig := range signals {
+		if sig == os.Interrupt || sig == syscall.SIGTERM {
+			break
+		}
+
+		if raw.config == "" {
+			d.logf("Nothing to reload, since no --config was given")
+		} else if newConfig, err := loadDaemonConfig(raw.config); err != nil {
+			d.logf("Keeping the current config, since it could not be reloaded: %s", err)
+		} else {
+			d.reload(newConfig)
+			d.logf("Config reloaded from %s", raw.config)
+		}
+	}
+
+	d.logf("Shutting down; waiting for running jobs to stop")
+	close(stopScheduling)
+	_ = listener.Close()
+	d.stop()
+	return nil
+}
+
+func init() {
+	raw := rawDaemonCmdArgs{}
+
+	daemonCmd := &cobra.Command{
+		Use:     "daemon",
+		Short:   daemonCmdShortDescription,
+		Long:    daemonCmdLongDescription,
+		Example: daemonCmdExample,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := raw.run(); err != nil {
+				glcm.Error("daemon failed: " + err.Error())
+			}
+			glcm.Exit(nil, common.EExitCode.Success())
+		},
+	}
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.PersistentFlags().StringVar(&raw.socket, "socket", "",
+		"Path of the Unix domain socket that jobs are submitted on. Defaults to /var/run/azcopy.sock for root, "+
+			"and azcopy-daemon.sock in the log folder for anyone else.")
+	daemonCmd.Flags().StringVar(&raw.pidfile, "pidfile", "",
+		"Path of the pidfile. Defaults to /var/run/azcopy.pid for root, and azcopy-daemon.pid in the log folder for anyone else.")
+	daemonCmd.Flags().StringVar(&raw.config, "config", "", "Path of a JSON config file, reread on SIGHUP.")
+	daemonCmd.Flags().StringVar(&raw.listen, "listen", "",
+		"Also serve a REST API on this address: host:port, or unix:/path/to/socket. "+
+			"A TCP address needs AZCOPY_DAEMON_API_TOKEN set, and clients must send it as a bearer token. "+
+			"Unless --tls-cert and --tls-key are given, only loopback addresses are allowed.")
+	daemonCmd.Flags().StringVar(&raw.tlsCert, "tls-cert", "",
+		"Path of a PEM certificate (chain) to serve the --listen API over TLS with.")
+	daemonCmd.Flags().StringVar(&raw.tlsKey, "tls-key", "", "Path of the PEM private key for --tls-cert.")
+	daemonCmd.Flags().BoolVar(&raw.foreground, "foreground", false,
+		"Don't detach from the terminal. Use this when running under daemon(8) or another supervisor.")
+
+	socketPath := func() string {
+		if raw.socket == "" {
+			return defaultDaemonPath("sock")
+		}
+		return raw.socket
+	}
+
+	daemonCmd.AddCommand(&cobra.Command{
+		Use:   "submit [command] [args]",
+		Short: daemonSubmitCmdShortDescription,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := callDaemon(socketPath(), daemonRequest{Command: "submit", Args: args})
+			if err != nil {
+				glcm.Error("failed to submit the job: " + err.Error())
+			}
+			job := resp.Jobs[0]
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					buf, _ := json.Marshal(job)
+					return string(buf)
+				}
+				return fmt.Sprintf("Submitted as daemon job %d; its output goes to %s", job.ID, job.LogFile)
+			}, common.EExitCode.Success())
+		},
+	})
+
+	daemonCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: daemonStatusCmdShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := callDaemon(socketPath(), daemonRequest{Command: "status"})
+			if err != nil {
+				glcm.Error("failed to get the daemon's status: " + err.Error())
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					buf, _ := json.Marshal(resp.Jobs)
+					return string(buf)
+				}
+				if len(resp.Jobs) == 0 {
+					return "The daemon has no jobs"
+				}
+				var sb strings.Builder
+				for _, job := range resp.Jobs {
+					sb.WriteString(fmt.Sprintf("%d\t%s\t%s\n", job.ID, job.State, strings.Join(job.Args, " ")))
+				}
+				return strings.TrimSuffix(sb.String(), "\n")
+			}, common.EExitCode.Success())
+		},
+	})
+
+	for _, action := range []string{"pause", "resume", "cancel"} {
+		daemonCmd.AddCommand(&cobra.Command{
+			Use:   action + " [job number]",
+			Short: fmt.Sprintf(daemonControlCmdShortDescription, strings.ToUpper(action[:1])+action[1:]),
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				id, err := strconv.Atoi(args[0])
+				if err != nil {
+					glcm.Error("the job number must be the one 'azcopy daemon status' shows")
+				}
+				resp, err := callDaemon(socketPath(), daemonRequest{Command: action, Job: id})
+				if err != nil {
+					glcm.Error(fmt.Sprintf("failed to %s the job: %s", action, err))
+				}
+				job := resp.Jobs[0]
+				glcm.Exit(func(format common.OutputFormat) string {
+					if format == common.EOutputFormat.Json() {
+						buf, _ := json.Marshal(job)
+						return string(buf)
+					}
+					return fmt.Sprintf("Asked daemon job %d to %s", job.ID, action)
+				}, common.EExitCode.Success())
+			},
+		})
+	}
+}
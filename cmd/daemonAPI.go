@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiHandler serves the daemon's REST API, for services that orchestrate transfers without shelling out:
+//
+//	GET  /v1/jobs                  lists the jobs, optionally only those with ?state=
+//	POST /v1/jobs                  submits a job, given {"Args": ["copy", ...]}
+//	GET  /v1/jobs/{id}             gets a job
+//	GET  /v1/jobs/{id}/progress    streams the job as newline-delimited JSON whenever it changes, until it finishes
+//	POST /v1/jobs/{id}/pause       pauses a running job
+//	POST /v1/jobs/{id}/resume      resumes a paused job
+//	POST /v1/jobs/{id}/cancel      cancels a job, letting transfers in progress finish
+//	GET  /v1/schedules             lists the schedules, with when they last and next run
+//	POST /v1/schedules/{name}/run  runs a schedule now, unless its last run hasn't finished
+//
+// If token isn't empty, every request must carry it as a bearer token.
+func (d *azcopyDaemon) apiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/jobs", d.apiListJobs)
+	mux.HandleFunc("POST /v1/jobs", d.apiSubmitJob)
+	mux.HandleFunc("GET /v1/jobs/{id}", d.apiGetJob)
+	mux.HandleFunc("GET /v1/jobs/{id}/progress", d.apiStreamJob)
+	mux.HandleFunc("POST /v1/jobs/{id}/{action}", d.apiControlJob)
+	mux.HandleFunc("GET /v1/schedules", d.apiListSchedules)
+	mux.HandleFunc("POST /v1/schedules/{name}/run", d.apiRunSchedule)
+	if token == "" {
+		return mux
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, errors.New("a valid bearer token is required"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (d *azcopyDaemon) apiListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := d.status()
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := make([]daemonJob, 0, len(jobs))
+		for _, job := range jobs {
+			if strings.EqualFold(string(job.State), state) {
+				filtered = append(filtered, job)
+			}
+		}
+		jobs = filtered
+	}
+	writeAPIResponse(w, http.StatusOK, jobs)
+}
+
+func (d *azcopyDaemon) apiSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req daemonRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	job, err := d.submit(req.Args)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/v1/jobs/%d", job.ID))
+	writeAPIResponse(w, http.StatusCreated, job)
+}
+
+func (d *azcopyDaemon) apiGetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := apiJobID(r)
+	if err == nil {
+		var job daemonJob
+		if job, _, err = d.watch(id); err == nil {
+			writeAPIResponse(w, http.StatusOK, job)
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, err)
+}
+
+func (d *azcopyDaemon) apiStreamJob(w http.ResponseWriter, r *http.Request) {
+	id, err := apiJobID(r)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	job, updated, err := d.watch(id)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		if enc.Encode(job) != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if updated == nil {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+		if job, updated, err = d.watch(id); err != nil {
+			return
+		}
+	}
+}
+
+func (d *azcopyDaemon) apiControlJob(w http.ResponseWriter, r *http.Request) {
+	id, err := apiJobID(r)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+	job, err := d.control(id, r.PathValue("action"))
+	switch {
+	case errors.Is(err, errNoSuchDaemonJob):
+		writeAPIError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeAPIError(w, http.StatusConflict, err)
+	default:
+		writeAPIResponse(w, http.StatusOK, job)
+	}
+}
+
+func (d *azcopyDaemon) apiListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeAPIResponse(w, http.StatusOK, d.schedules(time.Now()))
+}
+
+func (d *azcopyDaemon) apiRunSchedule(w http.ResponseWriter, r *http.Request) {
+	job, err := d.runScheduleNow(r.PathValue("name"))
+	switch {
+	case errors.Is(err, errNoSuchSchedule):
+		writeAPIError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeAPIError(w, http.StatusConflict, err)
+	default:
+		w.Header().Set("Location", fmt.Sprintf("/v1/jobs/%d", job.ID))
+		writeAPIResponse(w, http.StatusCreated, job)
+	}
+}
+
+func apiJobID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", errNoSuchDaemonJob, r.PathValue("id"))
+	}
+	return id, nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, daemonResponse{Error: err.Error()})
+}
+
+// listenAPI listens on addr, which is either host:port or unix:/path/to/socket.
+// Over TCP, anyone on the machine (or further) could connect, so a token must be set. And since the token and
+// the job arguments (which may carry SAS tokens) would otherwise cross the network in the clear, a non-loopback
+// address is refused unless a certificate and key are given to serve it over TLS.
+func listenAPI(addr, token, certFile, keyFile string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		_ = os.Remove(path)
+		oldMask := setUmask(0077)
+		defer setUmask(oldMask)
+		return net.Listen("unix", path)
+	}
+	if token == "" {
+		return nil, errors.New("the daemon can only listen on a TCP address if AZCOPY_DAEMON_API_TOKEN is set")
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be given together")
+	}
+
+	if certFile == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if !isLoopbackHost(host) {
+			return nil, fmt.Errorf("%s is not a loopback address, so the API can only be served on it over TLS (see --tls-cert and --tls-key)", addr)
+		}
+		return net.Listen("tcp", addr)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}), nil
+}
+
+// isLoopbackHost says whether host only reaches this machine. An empty host means every interface, so it doesn't.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveAPI serves the REST API on l until the returned server is closed.
+func (d *azcopyDaemon) serveAPI(l net.Listener, token string) *http.Server {
+	server := &http.Server{Handler: d.apiHandler(token), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(l)
+	}()
+	return server
+}
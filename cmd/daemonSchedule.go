@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// daemonSchedule is a job that the daemon submits itself, whenever its schedule says, so that regular transfers don't
+// need cron and a lockfile script around 'azcopy daemon submit'.
+type daemonSchedule struct {
+	Name     string   // names the schedule's log, schedule-<name>.log
+	Schedule string   // as in crontab(5), in local time: "30 2 * * *" or "@daily", for example
+	Args     []string // the AzCopy command line, as for 'azcopy daemon submit'
+
+	cron *cronSchedule
+}
+
+var errNoSuchSchedule = errors.New("no such schedule")
+
+var validScheduleName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// parseDaemonSchedules checks the schedules of a config, and parses their cron expressions
+func parseDaemonSchedules(schedules []daemonSchedule) error {
+	names := map[string]bool{}
+	for i := range schedules {
+		s := &schedules[i]
+		if !validScheduleName.MatchString(s.Name) {
+			return fmt.Errorf("schedule %d: '%s' isn't a valid name; use letters, digits, '.', '-' and '_'", i+1, s.Name)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("there is more than one schedule called %s", s.Name)
+		}
+		names[s.Name] = true
+
+		if len(s.Args) == 0 || !daemonCommands[s.Args[0]] {
+			return fmt.Errorf("schedule %s: only copy, sync, remove, set-properties and jobs commands can be scheduled", s.Name)
+		}
+		var err error
+		if s.cron, err = parseCronSchedule(s.Schedule); err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// scheduleRun is what a schedule has done since the daemon started
+type scheduleRun struct {
+	last    *daemonJob // the job from its last run
+	skipped int        // runs that were skipped, since the one before hadn't finished
+}
+
+// scheduleStatus is what 'azcopy schedule list' shows of a schedule
+type scheduleStatus struct {
+	Name        string
+	Schedule    string
+	Args        []string
+	NextRun     time.Time      `json:",omitzero"`
+	LastJob     int            `json:",omitempty"` // the daemon's number for the job from the last run
+	LastRun     time.Time      `json:",omitzero"`
+	LastState   daemonJobState `json:",omitempty"`
+	SkippedRuns int            `json:",omitempty"`
+}
+
+// runSchedules submits the jobs of the schedules that are due at the start of each minute, as cron does, until stop
+// is closed. A minute that the machine sleeps through is missed, as it is by cron.
+func (d *azcopyDaemon) runSchedules(stop <-chan struct{}) {
+	for {
+		next := time.Now().Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Until(next)):
+		}
+		d.runDueSchedules(next)
+	}
+}
+
+func (d *azcopyDaemon) runDueSchedules(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.config.Schedules {
+		if s.cron.matches(t) {
+			_, _ = d.runSchedule(s)
+		}
+	}
+}
+
+// runScheduleNow runs the schedule called name without waiting for it to be due
+func (d *azcopyDaemon) runScheduleNow(name string) (daemonJob, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.config.Schedules {
+		if s.Name == name {
+			return d.runSchedule(s)
+		}
+	}
+	return daemonJob{}, fmt.Errorf("%w: %s", errNoSuchSchedule, name)
+}
+
+// runSchedule submits a run of s, unless the job from its last run is still queued or running, so that a run that
+// takes longer than the schedule allows isn't overlapped by the next. d.mu must be held.
+func (d *azcopyDaemon) runSchedule(s daemonSchedule) (daemonJob, error) {
+	if d.stopping {
+		return daemonJob{}, errors.New("the daemon is shutting down")
+	}
+	if d.scheduleRuns == nil {
+		d.scheduleRuns = map[string]*scheduleRun{}
+	}
+	run := d.scheduleRuns[s.Name]
+	if run == nil {
+		run = &scheduleRun{}
+		d.scheduleRuns[s.Name] = run
+	}
+
+	if run.last != nil && !run.last.done() {
+		run.skipped++
+		d.logf("Schedule %s skipped a run, since job %d from its last run hasn't finished", s.Name, run.last.ID)
+		return *run.last, fmt.Errorf("job %d from the last run of schedule %s hasn't finished", run.last.ID, s.Name)
+	}
+	run.last = d.queue(s.Args, s.Name)
+	return *run.last, nil
+}
+
+// schedules is the state of each schedule, and when it next runs after now
+func (d *azcopyDaemon) schedules(now time.Time) []scheduleStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]scheduleStatus, 0, len(d.config.Schedules))
+	for _, s := range d.config.Schedules {
+		status := scheduleStatus{Name: s.Name, Schedule: s.Schedule, Args: s.Args, NextRun: s.cron.next(now)}
+		if run := d.scheduleRuns[s.Name]; run != nil {
+			status.SkippedRuns = run.skipped
+			if run.last != nil {
+				status.LastJob, status.LastRun, status.LastState = run.last.ID, run.last.Submitted, run.last.State
+			}
+		}
+		result = append(result, status)
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+var daemonReloadSignals = []os.Signal{syscall.SIGHUP}
+
+// detachDaemon starts the daemon again, with args, in a session of its own with no terminal, and returns its pid.
+func detachDaemon(args []string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = devNull, devNull, devNull
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err = cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// lockPidfile writes our pid to path and holds a lock on it until the returned file is closed, the same way pidfile(3) does,
+// so that rc.d and a second daemon can both tell whether we're still running.
+func lockPidfile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("another AzCopy daemon is already running with pidfile %s", path)
+		}
+		return nil, err
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func setUmask(mask int) int {
+	return syscall.Umask(mask)
+}
+
+func interruptProcess(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
+
+func pauseProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGUSR1)
+}
+
+func resumeProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGUSR2)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+var daemonReloadSignals []os.Signal
+
+var errDaemonNotSupported = errors.New("the AzCopy daemon is not supported on Windows; use a scheduled task instead")
+
+func detachDaemon([]string) (int, error) {
+	return 0, errDaemonNotSupported
+}
+
+func lockPidfile(string) (*os.File, error) {
+	return nil, errDaemonNotSupported
+}
+
+func setUmask(int) int {
+	return 0
+}
+
+func interruptProcess(p *os.Process) error {
+	return p.Kill() // Windows can't deliver an interrupt to another process
+}
+
+func pauseProcess(*os.Process) error {
+	return errDaemonNotSupported
+}
+
+func resumeProcess(*os.Process) error {
+	return errDaemonNotSupported
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/lease"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// how long the lease on a container that's synced to with --lock-destination lasts, and how often it's renewed; a sync
+// that's killed without releasing its lease keeps others out until the lease runs out
+const (
+	destinationLeaseDuration = 60 * time.Second
+	destinationLeaseRenewal  = 20 * time.Second
+)
+
+// takeDestinationLock takes, with --lock-destination, a lock on the destination that's held until AzCopy exits, so that
+// a second sync to the same destination, such as one started on a schedule before the last has finished, fails instead
+// of running alongside it, and each deleting what the other has just copied. The lock is advisory: only syncs with
+// --lock-destination take it.
+//
+// Blob and Data Lake destinations are locked with a lease on their container, which keeps out syncs from any machine,
+// but also syncs to any other path in the same container. Other destinations are locked with a file in the job plan
+// folder, which keeps out only syncs that share the folder, as those run by the same user on the same machine do.
+func (cca *cookedSyncCmdArgs) takeDestinationLock(ctx context.Context) error {
+	if !cca.lockDestination {
+		return nil
+	}
+
+	var release func()
+	var err error
+	switch cca.fromTo.To() {
+	case common.ELocation.Blob(), common.ELocation.BlobFS():
+		release, err = leaseDestinationContainer(ctx, cca.fromTo.To(), cca.destination, cca.cpkOptions)
+	case common.ELocation.Local():
+		var dst string
+		if dst, err = filepath.Abs(cca.destination.ValueLocal()); err == nil {
+			release, err = lockDestinationFile(dst)
+		}
+	default:
+		// the SAS isn't part of the name, since it may be renewed between one sync and the next
+		release, err = lockDestinationFile(cca.destination.Value)
+	}
+	if err != nil {
+		return err
+	}
+	glcm.RegisterCloseFunc(release)
+	return nil
+}
+
+func destinationLockPath(destination string) string {
+	sum := sha256.Sum256([]byte(destination))
+	return filepath.Join(common.AzcopyJobPlanFolder, "locks", hex.EncodeToString(sum[:])+".lock")
+}
+
+// lockDestinationFile locks the lock file of destination, and writes our pid to it, so that whoever finds it locked can
+// tell which process holds it. The file is left behind when the lock is released, since removing it would let a sync
+// that had opened it, but not yet locked it, lock a file that others can no longer find.
+func lockDestinationFile(destination string) (func(), error) {
+	path := destinationLockPath(destination)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = common.TryLockFile(f); err != nil {
+		_ = f.Close()
+		if errors.Is(err, common.ErrFileLocked) {
+			return nil, fmt.Errorf("another sync to %s is already running (see %s for its pid)", destination, path)
+		}
+		return nil, fmt.Errorf("couldn't lock %s: %w", path, err)
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() { _ = f.Close() }, nil
+}
+
+// leaseDestinationContainer leases the container of destination, and renews the lease until it's released
+func leaseDestinationContainer(ctx context.Context, location common.Location, destination common.ResourceString, cpkOptions common.CpkOptions) (func(), error) {
+	credInfo, _, err := GetCredentialInfoForLocation(ctx, location, destination, false, cpkOptions)
+	if err != nil {
+		return nil, err
+	}
+	var reauthTok *common.ScopedAuthenticator
+	if at, ok := credInfo.OAuthTokenInfo.TokenCredential.(common.AuthenticateToken); ok {
+		reauthTok = (*common.ScopedAuthenticator)(common.NewScopedCredential(at, common.ECredentialType.OAuthToken()))
+	}
+	options := createClientOptions(common.AzcopyCurrentJobLogger, nil, reauthTok)
+	sc, err := common.GetServiceClientForLocation(location, destination, credInfo.CredentialType, credInfo.OAuthTokenInfo.TokenCredential, &options, nil)
+	if err != nil {
+		return nil, err
+	}
+	bsc, err := sc.BlobServiceClient()
+	if err != nil {
+		return nil, err
+	}
+	containerName, err := GetContainerName(destination.Value, location)
+	if err != nil {
+		return nil, err
+	}
+	leaseClient, err := lease.NewContainerClient(bsc.NewContainerClient(containerName), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = leaseClient.AcquireLease(ctx, int32(destinationLeaseDuration/time.Second), nil)
+	if bloberror.HasCode(err, bloberror.LeaseAlreadyPresent) {
+		return nil, fmt.Errorf("another sync to container '%s' is already running, or was stopped less than %v ago (the container is leased)",
+			containerName, destinationLeaseDuration)
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't lease the destination container '%s': %w", containerName, err)
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(destinationLeaseRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := leaseClient.RenewLease(ctx, nil); err != nil {
+					glcm.Warn(fmt.Sprintf("Couldn't renew the lease on the destination container '%s', so another sync to it could start: %s", containerName, err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		if _, err := leaseClient.ReleaseLease(ctx, nil); err != nil {
+			glcm.Warn(fmt.Sprintf("Couldn't release the lease on the destination container '%s', which runs out in %v: %s", containerName, destinationLeaseDuration, err))
+		}
+	}, nil
+}
@@ -0,0 +1,306 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rawDiffCmdArgs struct {
+	// obtained from arguments
+	src    string
+	dst    string
+	fromTo string
+
+	recursive    bool
+	compareHash  string
+	include      string
+	exclude      string
+	excludePath  string
+	includeRegex string
+	excludeRegex string
+	report       string
+}
+
+type cookedDiffCmdArgs struct {
+	source      common.ResourceString
+	destination common.ResourceString
+	fromTo      common.FromTo
+
+	recursive     bool
+	compareHash   common.SyncHashType
+	preferSMBTime bool
+
+	includePatterns []string
+	excludePatterns []string
+	excludePaths    []string
+	includeRegex    []string
+	excludeRegex    []string
+
+	reportPath string
+}
+
+func (raw rawDiffCmdArgs) cook() (cooked cookedDiffCmdArgs, err error) {
+	cooked.fromTo, err = ValidateFromTo(raw.src, raw.dst, raw.fromTo)
+	if err != nil {
+		return cooked, err
+	}
+	if cooked.fromTo == common.EFromTo.Unknown() || cooked.fromTo == common.EFromTo.LocalLocal() || cooked.fromTo.IsSetProperties() || cooked.fromTo.IsDelete() {
+		return cooked, fmt.Errorf("source '%s' / destination '%s' combination '%s' can't be compared", raw.src, raw.dst, cooked.fromTo)
+	}
+	if cooked.source, err = splitHashedResourceString(raw.src, cooked.fromTo.From()); err != nil {
+		return cooked, err
+	}
+	if cooked.destination, err = splitHashedResourceString(raw.dst, cooked.fromTo.To()); err != nil {
+		return cooked, err
+	}
+
+	cooked.recursive = raw.recursive
+	if err = cooked.compareHash.Parse(raw.compareHash); err != nil {
+		return cooked, err
+	}
+	// as sync does, SMB last write times are compared where both sides have them
+	cooked.preferSMBTime = areBothLocationsSMBAware(cooked.fromTo)
+
+	cooked.includePatterns = parsePatterns(raw.include)
+	cooked.excludePatterns = parsePatterns(raw.exclude)
+	cooked.excludePaths = parsePatterns(raw.excludePath)
+	cooked.includeRegex = parsePatterns(raw.includeRegex)
+	cooked.excludeRegex = parsePatterns(raw.excludeRegex)
+	cooked.reportPath = raw.report
+	return cooked, nil
+}
+
+// how a file differs between the source and the destination
+const (
+	diffOnlyInSource      = "OnlyInSource"
+	diffOnlyInDestination = "OnlyInDestination"
+	diffModified          = "Modified"
+)
+
+type DiffEntry struct {
+	Path   string
+	Size   int64 // of the file at the source, unless it is only at the destination
+	Status string
+}
+
+func (e DiffEntry) String() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Path)
+}
+
+// DiffReport is what is written to --report
+type DiffReport struct {
+	Source      string
+	Destination string
+	CompareHash string
+
+	OnlyInSource      int
+	OnlyInDestination int
+	Modified          int
+
+	Entries []DiffEntry
+}
+
+func (r *DiffReport) record(status string) objectProcessor {
+	return func(object StoredObject) error {
+		switch status {
+		case diffOnlyInSource:
+			r.OnlyInSource++
+		case diffOnlyInDestination:
+			r.OnlyInDestination++
+		case diffModified:
+			r.Modified++
+		}
+		r.Entries = append(r.Entries, DiffEntry{Path: object.relativePath, Size: object.size, Status: status})
+		return nil
+	}
+}
+
+func (r *DiffReport) String() string {
+	if r.OnlyInSource+r.OnlyInDestination+r.Modified == 0 {
+		return "The source and destination are in sync."
+	}
+	return fmt.Sprintf("Files only at the source: %d\nFiles only at the destination: %d\nFiles modified: %d",
+		r.OnlyInSource, r.OnlyInDestination, r.Modified)
+}
+
+func (cooked cookedDiffCmdArgs) newTraverser(ctx context.Context, location common.Location, resource common.ResourceString) (ResourceTraverser, error) {
+	credentialInfo, err := getVerifyCredentialInfo(ctx, location, resource)
+	if err != nil {
+		return nil, err
+	}
+	return InitResourceTraverser(resource, location, ctx, InitResourceTraverserOptions{
+		Credential: &credentialInfo,
+
+		SyncHashType: cooked.compareHash,
+
+		Recursive:               cooked.recursive,
+		GetPropertiesInFrontend: true,
+		HardlinkHandling:        common.EHardlinkHandlingType.Follow(),
+	})
+}
+
+// process enumerates the source and the destination, and writes the differences between them to the report
+func (cooked cookedDiffCmdArgs) process() (*DiffReport, error) {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+
+	sourceTraverser, err := cooked.newTraverser(ctx, cooked.fromTo.From(), cooked.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the source traverser: %w", err)
+	}
+	destinationTraverser, err := cooked.newTraverser(ctx, cooked.fromTo.To(), cooked.destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the destination traverser: %w", err)
+	}
+
+	report, err := cooked.diff(sourceTraverser, destinationTraverser)
+	if err != nil {
+		return nil, err
+	}
+	if cooked.reportPath != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(cooked.reportPath, b, 0644); err != nil {
+			return nil, fmt.Errorf("writing the report: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// diff enumerates the source, then the destination, and compares them as sync would, without transferring anything.
+// A file that sync would overwrite is modified.
+func (cooked cookedDiffCmdArgs) diff(sourceTraverser, destinationTraverser ResourceTraverser) (*DiffReport, error) {
+	report := &DiffReport{Source: cooked.source.Value, Destination: cooked.destination.Value, CompareHash: cooked.compareHash.String()}
+
+	// only files are compared, since not every location has folders
+	filters := []ObjectFilter{&verifyFileFilter{}}
+	filters = append(filters, buildIncludeFilters(cooked.includePatterns)...)
+	filters = append(filters, buildExcludeFilters(cooked.excludePatterns, false)...)
+	filters = append(filters, buildExcludeFilters(cooked.excludePaths, true)...)
+	filters = append(filters, buildRegexFilters(cooked.includeRegex, true)...)
+	filters = append(filters, buildRegexFilters(cooked.excludeRegex, false)...)
+
+	// the source is indexed first, so that what is left in the index once the destination is enumerated is only at the source
+	indexer := newObjectIndexer()
+	indexer.isDestinationCaseInsensitive = IsDestinationCaseInsensitive(cooked.fromTo)
+	comparator := newSyncDestinationComparator(indexer, report.record(diffModified), report.record(diffOnlyInDestination),
+		cooked.compareHash, cooked.preferSMBTime, false)
+	enumerator := newSyncEnumerator(sourceTraverser, destinationTraverser, indexer, filters, comparator.processIfNecessary, func() error {
+		return indexer.traverse(report.record(diffOnlyInSource), filters)
+	})
+	if err := enumerator.enumerate(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Path < report.Entries[j].Path })
+	return report, nil
+}
+
+func init() {
+	raw := rawDiffCmdArgs{}
+
+	diffCmd := &cobra.Command{
+		Use:     "diff [source] [destination]",
+		Short:   diffCmdShortDescription,
+		Long:    diffCmdLongDescription,
+		Example: diffCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("diff command requires both the source and the destination")
+			}
+			raw.src = args[0]
+			raw.dst = args[1]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			azcopyScanningLogger = common.NewJobLogger(Client.CurrentJobID, LogLevel, common.LogPathFolder, "-scanning")
+			azcopyScanningLogger.OpenLog()
+			glcm.RegisterCloseFunc(func() {
+				azcopyScanningLogger.CloseLog()
+			})
+
+			report, err := cooked.process()
+			if err != nil {
+				glcm.Error(err.Error() + getErrorCodeUrl(err))
+				return
+			}
+			for _, e := range report.Entries {
+				glcm.Output(func(format common.OutputFormat) string {
+					if format == common.EOutputFormat.Json() {
+						jsonOutput, err := json.Marshal(e)
+						common.PanicIfErr(err)
+						return string(jsonOutput)
+					}
+					return e.String()
+				}, common.EOutputMessageType.ListObject())
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					summary := *report
+					summary.Entries = nil
+					jsonOutput, err := json.Marshal(summary)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return report.String()
+			}, common.EExitCode.Success())
+		},
+	}
+
+	addComparisonFlags(diffCmd, &raw)
+	diffCmd.PersistentFlags().StringVar(&raw.report, "report", "", "Write the differences, in JSON, to this file.")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+// addComparisonFlags adds the flags that say which files are compared, and how, to cmd. Commands that compare a source
+// with a destination as sync does, such as diff and estimate, share them.
+func addComparisonFlags(cmd *cobra.Command, raw *rawDiffCmdArgs) {
+	cmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "", "Optionally specifies the source destination combination. For Example: LocalBlob, BlobLocal, BlobBlob")
+	cmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true, "True by default, look into sub-directories recursively when comparing directories.")
+	cmd.PersistentFlags().StringVar(&raw.compareHash, "compare-hash", "None",
+		"Compare files by their hashes instead of their last modified times, as sync does. "+
+			"\n Available options: None, MD5 (default 'None').")
+	cmd.PersistentFlags().StringVar(&raw.include, "include-pattern", "", "Include only files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	cmd.PersistentFlags().StringVar(&raw.exclude, "exclude-pattern", "", "Exclude files where the name matches the pattern list. For example: *.jpg;*.pdf;exactName")
+	cmd.PersistentFlags().StringVar(&raw.excludePath, "exclude-path", "", "Exclude these paths when comparing. "+
+		"\n This option does not support wildcard characters (*). Checks relative path prefix. For example: myFolder;myFolder/subDirName/file.pdf")
+	cmd.PersistentFlags().StringVar(&raw.includeRegex, "include-regex", "", "Include only the relative path of the files that match with the regular expressions. Separate regular expressions with ';'.")
+	cmd.PersistentFlags().StringVar(&raw.excludeRegex, "exclude-regex", "", "Exclude the relative path of the files that match with the regular expressions. Separate regular expressions with ';'.")
+}
@@ -0,0 +1,305 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rawDuCmdArgs struct {
+	// obtained from argument
+	src      string
+	location string
+
+	depth            int
+	includeVersions  bool
+	includeSnapshots bool
+	machineReadable  bool
+}
+
+type cookedDuCmdArgs struct {
+	resource common.ResourceString
+	location common.Location
+
+	depth            int
+	includeVersions  bool
+	includeSnapshots bool
+	machineReadable  bool
+}
+
+func (raw rawDuCmdArgs) cook() (cooked cookedDuCmdArgs, err error) {
+	if cooked.location, err = ValidateArgumentLocation(raw.src, raw.location); err != nil {
+		return cooked, err
+	}
+	switch cooked.location {
+	case common.ELocation.Local(), common.ELocation.Blob(), common.ELocation.File(), common.ELocation.FileNFS(), common.ELocation.BlobFS():
+	default:
+		return cooked, fmt.Errorf("azcopy only supports local directories and Azure resources for du i.e. Local, Blob, File, BlobFS")
+	}
+	if cooked.resource, err = splitHashedResourceString(raw.src, cooked.location); err != nil {
+		return cooked, err
+	}
+
+	if raw.depth < 0 {
+		return cooked, errors.New("--depth can't be negative")
+	}
+	if (raw.includeVersions || raw.includeSnapshots) && cooked.location != common.ELocation.Blob() {
+		return cooked, errors.New("versions and snapshots can only be included for Blob storage (blob.core.windows.net)")
+	}
+	cooked.depth = raw.depth
+	cooked.includeVersions = raw.includeVersions
+	cooked.includeSnapshots = raw.includeSnapshots
+	cooked.machineReadable = raw.machineReadable
+	return cooked, nil
+}
+
+type DuUsage struct {
+	Files int64
+	Bytes int64
+}
+
+func (u *DuUsage) add(size int64) {
+	u.Files++
+	u.Bytes += size
+}
+
+type DuPrefix struct {
+	Prefix string
+	DuUsage
+}
+
+// DuReport is the space used at a location, in total and by each prefix
+type DuReport struct {
+	Location string
+
+	// Current doesn't count previous versions or snapshots, which are counted apart, if they were listed
+	Current          DuUsage
+	PreviousVersions *DuUsage `json:",omitempty"`
+	Snapshots        *DuUsage `json:",omitempty"`
+	Total            DuUsage
+
+	// of everything counted, by the directory (or virtual directory) it is in, to --depth levels
+	Prefixes []DuPrefix
+}
+
+func (r *DuReport) text(machineReadable bool) string {
+	var sb strings.Builder
+	line := func(u DuUsage, name string) {
+		fmt.Fprintf(&sb, "%12s  %10d  %s\n", sizeToString(u.Bytes, machineReadable), u.Files, name)
+	}
+	for _, p := range r.Prefixes {
+		line(p.DuUsage, common.Iff(p.Prefix == "", "./", p.Prefix))
+	}
+	if r.PreviousVersions != nil || r.Snapshots != nil {
+		line(r.Current, "(current)")
+		if r.PreviousVersions != nil {
+			line(*r.PreviousVersions, "(previous versions)")
+		}
+		if r.Snapshots != nil {
+			line(*r.Snapshots, "(snapshots)")
+		}
+	}
+	line(r.Total, "total")
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// duCounter adds up the objects at a location
+type duCounter struct {
+	depth    int
+	report   DuReport
+	prefixes map[string]*DuUsage
+
+	// the latest version of each blob, which is its current version, and so isn't a previous version
+	latestVersions map[string]StoredObject
+}
+
+func newDuCounter(cooked cookedDuCmdArgs) *duCounter {
+	c := &duCounter{
+		depth:          cooked.depth,
+		report:         DuReport{Location: cooked.resource.Value},
+		prefixes:       make(map[string]*DuUsage),
+		latestVersions: make(map[string]StoredObject),
+	}
+	if cooked.includeVersions {
+		c.report.PreviousVersions = &DuUsage{}
+	}
+	if cooked.includeSnapshots {
+		c.report.Snapshots = &DuUsage{}
+	}
+	return c
+}
+
+// duPrefix is the directory that path is in, cut to depth levels, with a trailing slash, or "" if it is at the top
+func duPrefix(path string, depth int) string {
+	segments := strings.Split(path, common.AZCOPY_PATH_SEPARATOR_STRING)
+	segments = segments[:len(segments)-1] // the name
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	if len(segments) == 0 {
+		return ""
+	}
+	return strings.Join(segments, common.AZCOPY_PATH_SEPARATOR_STRING) + common.AZCOPY_PATH_SEPARATOR_STRING
+}
+
+func (c *duCounter) add(path string, object StoredObject) {
+	c.report.Total.add(object.size)
+	if c.depth > 0 {
+		prefix := duPrefix(path, c.depth)
+		if c.prefixes[prefix] == nil {
+			c.prefixes[prefix] = &DuUsage{}
+		}
+		c.prefixes[prefix].add(object.size)
+	}
+
+	switch {
+	case object.blobSnapshotID != "":
+		c.report.Snapshots.add(object.size)
+	case object.blobVersionID != "":
+		// every version is counted as a previous version, until finish takes the latest of each out
+		c.report.PreviousVersions.add(object.size)
+		if latest, ok := c.latestVersions[path]; !ok || isLaterVersion(object.blobVersionID, latest.blobVersionID) {
+			c.latestVersions[path] = object
+		}
+	default:
+		c.report.Current.add(object.size)
+	}
+}
+
+func isLaterVersion(versionID, than string) bool {
+	a, _ := time.Parse(versionIdTimeFormat, versionID)
+	b, _ := time.Parse(versionIdTimeFormat, than)
+	return a.After(b)
+}
+
+func (c *duCounter) finish() *DuReport {
+	for _, latest := range c.latestVersions {
+		c.report.PreviousVersions.Files--
+		c.report.PreviousVersions.Bytes -= latest.size
+		c.report.Current.add(latest.size)
+	}
+	for prefix, usage := range c.prefixes {
+		c.report.Prefixes = append(c.report.Prefixes, DuPrefix{Prefix: prefix, DuUsage: *usage})
+	}
+	sort.Slice(c.report.Prefixes, func(i, j int) bool { return c.report.Prefixes[i].Prefix < c.report.Prefixes[j].Prefix })
+	return &c.report
+}
+
+func (cooked cookedDuCmdArgs) process() (*DuReport, error) {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+
+	level, err := DetermineLocationLevel(cooked.resource.Value, cooked.location, true)
+	if err != nil {
+		return nil, err
+	}
+	credentialInfo, err := getVerifyCredentialInfo(ctx, cooked.location, cooked.resource)
+	if err != nil {
+		return nil, err
+	}
+	traverser, err := InitResourceTraverser(cooked.resource, cooked.location, ctx, InitResourceTraverserOptions{
+		Credential: &credentialInfo,
+
+		Recursive:               true,
+		GetPropertiesInFrontend: true,
+
+		ListVersions:     cooked.includeVersions,
+		ListSnapshots:    cooked.includeSnapshots,
+		HardlinkHandling: common.EHardlinkHandlingType.Follow(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize traverser: %s", err.Error())
+	}
+
+	c := newDuCounter(cooked)
+	err = traverser.Traverse(noPreProccessor, func(object StoredObject) error {
+		c.add(getPath(object.ContainerName, object.relativePath, level, object.entityType), object)
+		return nil
+	}, []ObjectFilter{&verifyFileFilter{}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to traverse the location: %s", err.Error())
+	}
+	return c.finish(), nil
+}
+
+func init() {
+	raw := rawDuCmdArgs{}
+
+	duCmd := &cobra.Command{
+		Use:     "du [location]",
+		Short:   duCmdShortDescription,
+		Long:    duCmdLongDescription,
+		Example: duCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("du command requires the directory or container to add up")
+			}
+			raw.src = args[0]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			azcopyScanningLogger = common.NewJobLogger(Client.CurrentJobID, LogLevel, common.LogPathFolder, "-scanning")
+			azcopyScanningLogger.OpenLog()
+			glcm.RegisterCloseFunc(func() {
+				azcopyScanningLogger.CloseLog()
+			})
+
+			report, err := cooked.process()
+			if err != nil {
+				glcm.Error(err.Error() + getErrorCodeUrl(err))
+				return
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(report)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return report.text(cooked.machineReadable)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	duCmd.PersistentFlags().StringVar(&raw.location, "location", "", "Optionally specifies the location. For Example: Local, Blob, File, BlobFS")
+	duCmd.PersistentFlags().IntVar(&raw.depth, "depth", 1, "How many levels of directories (or virtual directories) to break the usage down by. "+
+		"\n 0 shows only the total. Default is 1.")
+	duCmd.PersistentFlags().BoolVar(&raw.includeVersions, "include-versions", false, "False by default. Include the previous versions of blobs, counting them apart from current blobs.")
+	duCmd.PersistentFlags().BoolVar(&raw.includeSnapshots, "include-snapshots", false, "False by default. Include the snapshots of blobs, counting them apart from current blobs.")
+	duCmd.PersistentFlags().BoolVar(&raw.machineReadable, "machine-readable", false, "False by default. Shows sizes in bytes.")
+
+	rootCmd.AddCommand(duCmd)
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+type rawEstimateCmdArgs struct {
+	rawDiffCmdArgs
+
+	bandwidthMbps   float64
+	machineReadable bool
+}
+
+type cookedEstimateCmdArgs struct {
+	cookedDiffCmdArgs
+
+	bandwidthMbps   float64
+	machineReadable bool
+}
+
+func (raw rawEstimateCmdArgs) cook() (cooked cookedEstimateCmdArgs, err error) {
+	if cooked.cookedDiffCmdArgs, err = raw.rawDiffCmdArgs.cook(); err != nil {
+		return cooked, err
+	}
+	if raw.bandwidthMbps < 0 {
+		return cooked, errors.New("--bandwidth-mbps can't be negative")
+	}
+	cooked.bandwidthMbps = raw.bandwidthMbps
+	cooked.machineReadable = raw.machineReadable
+	return cooked, nil
+}
+
+// EstimateReport says what syncing the source to the destination would do, and how long it would take
+type EstimateReport struct {
+	Source      string
+	Destination string
+
+	// the files that are only at the source, or more recent there
+	FilesToTransfer int64
+	BytesToTransfer int64
+
+	// the files that are only at the destination, which are deleted with --delete-destination
+	FilesToDelete int64
+	BytesToDelete int64
+
+	// how long the bytes take to transfer at --bandwidth-mbps, if it was given. The time taken to start each file isn't counted.
+	BandwidthMbps    float64 `json:",omitempty"`
+	EstimatedSeconds int64   `json:",omitempty"`
+}
+
+func newEstimateReport(diff *DiffReport, bandwidthMbps float64) *EstimateReport {
+	r := &EstimateReport{Source: diff.Source, Destination: diff.Destination, BandwidthMbps: bandwidthMbps}
+	for _, e := range diff.Entries {
+		if e.Status == diffOnlyInDestination {
+			r.FilesToDelete++
+			r.BytesToDelete += e.Size
+		} else {
+			r.FilesToTransfer++
+			r.BytesToTransfer += e.Size
+		}
+	}
+	if bandwidthMbps > 0 {
+		r.EstimatedSeconds = int64(math.Ceil(float64(r.BytesToTransfer) * 8 / (bandwidthMbps * 1000 * 1000)))
+	}
+	return r
+}
+
+func (r *EstimateReport) text(machineReadable bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Files to transfer: %d (%s)\n", r.FilesToTransfer, sizeToString(r.BytesToTransfer, machineReadable))
+	fmt.Fprintf(&sb, "Files to delete, with --delete-destination: %d (%s)", r.FilesToDelete, sizeToString(r.BytesToDelete, machineReadable))
+	if r.BandwidthMbps > 0 {
+		fmt.Fprintf(&sb, "\nEstimated time at %g Mbps: %v", r.BandwidthMbps, time.Duration(r.EstimatedSeconds)*time.Second)
+	}
+	return sb.String()
+}
+
+func init() {
+	raw := rawEstimateCmdArgs{}
+
+	estimateCmd := &cobra.Command{
+		Use:     "estimate [source] [destination]",
+		Short:   estimateCmdShortDescription,
+		Long:    estimateCmdLongDescription,
+		Example: estimateCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("estimate command requires both the source and the destination")
+			}
+			raw.src = args[0]
+			raw.dst = args[1]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			azcopyScanningLogger = common.NewJobLogger(Client.CurrentJobID, LogLevel, common.LogPathFolder, "-scanning")
+			azcopyScanningLogger.OpenLog()
+			glcm.RegisterCloseFunc(func() {
+				azcopyScanningLogger.CloseLog()
+			})
+
+			diff, err := cooked.process()
+			if err != nil {
+				glcm.Error(err.Error() + getErrorCodeUrl(err))
+				return
+			}
+			report := newEstimateReport(diff, cooked.bandwidthMbps)
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(report)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return report.text(cooked.machineReadable)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	addComparisonFlags(estimateCmd, &raw.rawDiffCmdArgs)
+	estimateCmd.PersistentFlags().Float64Var(&raw.bandwidthMbps, "bandwidth-mbps", 0,
+		"The bandwidth, in megabits per second, to estimate how long the transfer takes at. Defaults to not estimating the time.")
+	estimateCmd.PersistentFlags().BoolVar(&raw.machineReadable, "machine-readable", false, "False by default. Shows sizes in bytes.")
+
+	rootCmd.AddCommand(estimateCmd)
+}
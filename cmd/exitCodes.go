@@ -0,0 +1,64 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// jobExitCode is the exit code of a job that has finished with summary, which is Success unless something went wrong
+func jobExitCode(summary common.ListJobSummaryResponse) common.ExitCode {
+	switch {
+	case summary.TransfersFailedAuth > 0:
+		// a transfer that isn't authorized cancels the job, so this has to come before cancellation
+		return common.EExitCode.AuthFailure()
+	case summary.JobStatus == common.EJobStatus.Cancelled() || summary.JobStatus == common.EJobStatus.Cancelling():
+		return common.EExitCode.Cancelled()
+	case summary.TransfersFailed == 0:
+		return common.EExitCode.Success()
+	case summary.TransfersFailedNetwork == summary.TransfersFailed:
+		return common.EExitCode.NetworkFailure()
+	case summary.TransfersCompleted > 0:
+		return common.EExitCode.CompletedWithErrors()
+	default:
+		return common.EExitCode.Error()
+	}
+}
+
+// errorExitCode is the exit code of a command that failed with err before its job started (or without one)
+func errorExitCode(err error) common.ExitCode {
+	var respErr *azcore.ResponseError
+	switch {
+	case errors.Is(err, NothingScheduledError) || errors.Is(err, ErrNothingToRemove):
+		return common.EExitCode.NothingToDo()
+	case errors.As(err, &respErr) && (respErr.StatusCode == http.StatusUnauthorized || respErr.StatusCode == http.StatusForbidden):
+		return common.EExitCode.AuthFailure()
+	case common.IsNetworkError(err):
+		return common.EExitCode.NetworkFailure()
+	default:
+		return common.EExitCode.Error()
+	}
+}
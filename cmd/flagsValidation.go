@@ -232,6 +232,21 @@ func validateSymlinkFlag(followSymlinks, preserveSymlinks bool) error {
 
 func validateHardlinksFlag(option common.HardlinkHandlingType, fromTo common.FromTo) error {
 
+	if option == common.EHardlinkHandlingType.Preserve() {
+		if common.IsNFSCopy() {
+			return fmt.Errorf("The --hardlinks=preserve option is not yet supported for NFS copies.")
+		}
+		switch fromTo {
+		case common.EFromTo.LocalBlob(), common.EFromTo.BlobLocal(), common.EFromTo.LocalLocal():
+			if runtime.GOOS == "windows" {
+				return fmt.Errorf("The --hardlinks=preserve option is not supported on Windows.")
+			}
+		case common.EFromTo.BlobBlob():
+		default:
+			return fmt.Errorf("The --hardlinks=preserve option is only supported when uploading to, downloading from, or copying between Blob storage, or copying between local directories.")
+		}
+	}
+
 	// Validate for Download: Only allowed when downloading from an NFS share to a Linux filesystem
 	if common.IsNFSCopy() {
 		if runtime.GOOS == "linux" && fromTo.IsDownload() && (fromTo.From() != common.ELocation.FileNFS()) {
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+type hardlinkKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkTracker remembers the first name seen for each multiply-linked inode, so that with --hardlinks=preserve
+// only that one is sent with its content, and the others just refer back to it.
+type hardlinkTracker struct {
+	mu        sync.Mutex
+	firstSeen map[hardlinkKey]string
+}
+
+func newHardlinkTracker() *hardlinkTracker {
+	return &hardlinkTracker{firstSeen: make(map[hardlinkKey]string)}
+}
+
+// classify returns the entity type to enumerate fileInfo as, and for all but the first name of an inode, the metadata
+// pointing at that first name. relPath uses / as its separator.
+func (h *hardlinkTracker) classify(fileInfo os.FileInfo, relPath string) (common.EntityType, common.Metadata) {
+	if !IsHardlink(fileInfo) {
+		return common.EEntityType.File(), noMetadata
+	}
+	key, ok := getHardlinkKey(fileInfo)
+	if !ok {
+		return common.EEntityType.File(), noMetadata
+	}
+
+	h.mu.Lock()
+	first, seen := h.firstSeen[key]
+	if !seen {
+		h.firstSeen[key] = relPath
+	}
+	h.mu.Unlock()
+
+	if !seen {
+		return common.EEntityType.File(), noMetadata
+	}
+
+	target, err := common.EncodeHardlinkTarget(relPath, first)
+	if err != nil {
+		WarnStdoutAndScanningLog("Copying hard link " + relPath + " as a regular file: " + err.Error())
+		return common.EEntityType.File(), noMetadata
+	}
+	return common.EEntityType.Hardlink(), common.Metadata{common.POSIXHardlinkMeta: &target}
+}
+
+// deferredHardlinks collects the links found while downloading with --hardlinks=preserve. They are made once the job is
+// done, since until then there is no telling whether the file they link to has arrived. The links are also sent to the STE
+// as transfers, so that they are in the plan file, and a resumed job makes them too (see createPlannedHardlinks).
+type deferredHardlinks struct {
+	// root is the local destination of the job. Neither links nor their targets may be outside it.
+	root string
+
+	mu    sync.Mutex
+	links []deferredHardlink
+}
+
+func newDeferredHardlinks(root string) *deferredHardlinks {
+	return &deferredHardlinks{root: root}
+}
+
+// hardlinkStandInOnce keeps the warning about stand-ins downloaded without --hardlinks=preserve to one per run.
+var hardlinkStandInOnce sync.Once
+
+// errHardlinkExists leaves an existing destination alone, as a file transfer would be skipped when --overwrite isn't true.
+var errHardlinkExists = errors.New("destination already exists")
+
+type deferredHardlink struct {
+	link   string
+	target string
+}
+
+// add records a link at the local path link, given the POSIXHardlinkMeta value stored for it. Values that point
+// outside the destination are refused.
+func (d *deferredHardlinks) add(link string, value string) error {
+	if !common.IsPathWithin(d.root, link) {
+		return fmt.Errorf("hard link %s is outside the destination %s", link, d.root)
+	}
+	target, err := common.ResolveHardlinkTarget(d.root, link, value)
+	if err != nil {
+		return fmt.Errorf("%s metadata of %s: %w", common.POSIXHardlinkMeta, link, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.links = append(d.links, deferredHardlink{link: link, target: target})
+	return nil
+}
+
+// create makes the recorded links. Links whose target didn't arrive, e.g. because it was filtered out, are reported and left out.
+func (d *deferredHardlinks) create(overwrite common.OverwriteOption) (created int, failed int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, l := range d.links {
+		err := l.create(d.root, overwrite)
+		if errors.Is(err, errHardlinkExists) {
+			continue
+		} else if err != nil {
+			WarnStdoutAndScanningLog(fmt.Sprintf("Failed to create hard link %s to %s: %s", l.link, l.target, err))
+			failed++
+			continue
+		}
+		created++
+	}
+	d.links = nil
+	return created, failed
+}
+
+func (l deferredHardlink) create(root string, overwrite common.OverwriteOption) error {
+	if fi, err := os.Lstat(l.link); err == nil {
+		if target, err := os.Lstat(l.target); err == nil && os.SameFile(fi, target) {
+			return errHardlinkExists // made already, by an earlier attempt at the job
+		}
+		if overwrite != common.EOverwriteOption.True() {
+			return errHardlinkExists
+		}
+		if err = os.Remove(l.link); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(l.link), os.ModePerm); err != nil {
+		return err
+	}
+
+	// The paths were checked when the links were recorded, but the downloaded tree may hold symlinks that lead
+	// elsewhere, so check again where they really are.
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	realTarget, err := filepath.EvalSymlinks(l.target)
+	if err != nil {
+		return err
+	}
+	realLinkDir, err := filepath.EvalSymlinks(filepath.Dir(l.link))
+	if err != nil {
+		return err
+	}
+	if !common.IsPathWithin(realRoot, realTarget) || !common.IsPathWithin(realRoot, realLinkDir) {
+		return fmt.Errorf("the link or its target resolves to outside the destination %s", root)
+	}
+
+	return os.Link(realTarget, filepath.Join(realLinkDir, filepath.Base(l.link)))
+}
+
+// createPlannedHardlinks makes the links in the plan of a job that was resumed, once it is done.
+func createPlannedHardlinks(jobID common.JobID, root string) (created int, failed int) {
+	planned, overwrite := jobsAdmin.ListJobHardlinks(jobID)
+	if len(planned) == 0 {
+		return 0, 0
+	}
+	d := newDeferredHardlinks(root)
+	for _, l := range planned {
+		if err := d.add(l.Destination, l.Target); err != nil {
+			WarnStdoutAndScanningLog("Skipping hard link: " + err.Error())
+			failed++
+		}
+	}
+	c, f := d.create(overwrite)
+	return c, failed + f
+}
@@ -0,0 +1,222 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rawHashCmdArgs struct {
+	// obtained from argument
+	src      string
+	location string
+
+	recursive     bool
+	hashAlgorithm string
+	format        string
+	output        string
+}
+
+type cookedHashCmdArgs struct {
+	resource common.ResourceString
+	location common.Location
+
+	recursive  bool
+	hashType   common.ContentHashType
+	format     string
+	outputPath string
+}
+
+func (raw rawHashCmdArgs) cook() (cooked cookedHashCmdArgs, err error) {
+	if cooked.location, err = ValidateArgumentLocation(raw.src, raw.location); err != nil {
+		return cooked, err
+	}
+	switch cooked.location {
+	case common.ELocation.Local(), common.ELocation.Blob(), common.ELocation.File(), common.ELocation.FileNFS(), common.ELocation.BlobFS():
+	default:
+		return cooked, fmt.Errorf("azcopy only supports local directories and Azure resources for hashing i.e. Local, Blob, File, BlobFS")
+	}
+	if cooked.resource, err = splitHashedResourceString(raw.src, cooked.location); err != nil {
+		return cooked, err
+	}
+
+	cooked.recursive = raw.recursive
+	if err = cooked.hashType.Parse(raw.hashAlgorithm); err != nil {
+		return cooked, err
+	}
+	cooked.format = strings.ToLower(raw.format)
+	if _, err = formatManifestEntry(cooked.format, cooked.hashType, hashManifestEntry{}); err != nil {
+		return cooked, err
+	}
+	cooked.outputPath = raw.output
+	return cooked, nil
+}
+
+// hashFiles enumerates the files at the location and hashes each, returning the entries of the manifest, in order of
+// path, and the number of files left out of it, since there is no hash of them
+func (cooked cookedHashCmdArgs) hashFiles(ctx context.Context, h *contentHasher) (entries []hashManifestEntry, skipped int, err error) {
+	traverser, err := newHashingTraverser(ctx, cooked.location, cooked.resource, cooked.recursive)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to initialize the traverser: %w", err)
+	}
+
+	skip := func(path, why string) {
+		skipped++
+		if azcopyScanningLogger != nil {
+			azcopyScanningLogger.Log(common.LogWarning, fmt.Sprintf("%s: left out of the manifest, since %s", path, why))
+		}
+	}
+	err = traverser.Traverse(noPreProccessor, func(object StoredObject) error {
+		path := manifestPath(object)
+		c := h.content(cooked.location, cooked.resource, object)
+		if c.unverifiable != "" {
+			skip(path, c.unverifiable)
+			return nil
+		}
+		if err := h.hash(&c); err != nil {
+			skip(path, "reading it failed: "+err.Error())
+			return nil
+		}
+		if len(c.hash) == 0 {
+			skip(path, fmt.Sprintf("there is no %s hash recorded of it", cooked.hashType))
+			return nil
+		}
+		entries = append(entries, hashManifestEntry{Path: path, Size: c.size, Hash: c.hash})
+		return nil
+	}, []ObjectFilter{&verifyFileFilter{}})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to traverse the location: %w", err)
+	}
+
+	// so that the same tree makes the same manifest
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, skipped, nil
+}
+
+// writeManifest writes the entries to w, a line each
+func (cooked cookedHashCmdArgs) writeManifest(w io.Writer, entries []hashManifestEntry) error {
+	for _, e := range entries {
+		line, err := formatManifestEntry(cooked.format, cooked.hashType, e)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cooked cookedHashCmdArgs) process() (skipped int, err error) {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	entries, skipped, err := cooked.hashFiles(ctx, newContentHasher(cooked.hashType))
+	if err != nil {
+		return 0, err
+	}
+
+	if cooked.outputPath != "" {
+		f, err := os.Create(cooked.outputPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create the manifest: %w", err)
+		}
+		if err = cooked.writeManifest(f, entries); err != nil {
+			_ = f.Close()
+			return 0, fmt.Errorf("failed to write the manifest: %w", err)
+		}
+		return skipped, f.Close()
+	}
+
+	for _, e := range entries {
+		line, err := formatManifestEntry(cooked.format, cooked.hashType, e)
+		if err != nil {
+			return 0, err
+		}
+		glcm.Output(func(format common.OutputFormat) string {
+			return line
+		}, common.EOutputMessageType.ListObject())
+	}
+	return skipped, nil
+}
+
+func init() {
+	raw := rawHashCmdArgs{}
+
+	hashCmd := &cobra.Command{
+		Use:     "hash [location]",
+		Short:   hashCmdShortDescription,
+		Long:    hashCmdLongDescription,
+		Example: hashCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("hash command requires the directory or container to hash")
+			}
+			raw.src = args[0]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			azcopyScanningLogger = common.NewJobLogger(Client.CurrentJobID, LogLevel, common.LogPathFolder, "-scanning")
+			azcopyScanningLogger.OpenLog()
+			glcm.RegisterCloseFunc(func() {
+				azcopyScanningLogger.CloseLog()
+			})
+
+			skipped, err := cooked.process()
+			if err != nil {
+				glcm.Error(err.Error() + getErrorCodeUrl(err))
+				return
+			}
+			if skipped > 0 {
+				glcm.Error(fmt.Sprintf("%d files were left out of the manifest, since there is no %s hash of them. See the log for which.", skipped, cooked.hashType))
+				return
+			}
+			glcm.Exit(nil, common.EExitCode.Success())
+		},
+	}
+
+	hashCmd.PersistentFlags().StringVar(&raw.location, "location", "", "Optionally specifies the location. For Example: Local, Blob, File, BlobFS")
+	hashCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true, "True by default, look into sub-directories recursively.")
+	hashCmd.PersistentFlags().StringVar(&raw.hashAlgorithm, "hash-algorithm", common.EContentHashType.SHA256().String(),
+		"The hash to write. "+
+			"\n Available options: MD5, SHA256, CRC64 (default 'SHA256'). The hash of a remote file is the one recorded by --put-md5.")
+	hashCmd.PersistentFlags().StringVar(&raw.format, "format", manifestFormatGNU,
+		"The format of the manifest. "+
+			"\n Available options: "+strings.Join(validManifestFormats(), ", ")+" (default 'gnu', as sha256sum writes).")
+	hashCmd.PersistentFlags().StringVar(&raw.output, "output", "", "Write the manifest to this file, instead of to the standard output.")
+
+	rootCmd.AddCommand(hashCmd)
+}
@@ -0,0 +1,193 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// the formats that hash manifests are written in
+const (
+	// <hash>  <path>, as written by sha256sum and md5sum
+	manifestFormatGNU = "gnu"
+	// <ALGORITHM> (<path>) = <hash>, as written by sha256 and md5 on FreeBSD, and by sha256sum --tag
+	manifestFormatBSD = "bsd"
+	// a JSON object per line, which has the size of the file too
+	manifestFormatJSON = "json"
+)
+
+func validManifestFormats() []string {
+	return []string{manifestFormatGNU, manifestFormatBSD, manifestFormatJSON}
+}
+
+type hashManifestEntry struct {
+	Path string
+	Size int64 // -1 if the manifest doesn't record it
+	Hash []byte
+}
+
+// jsonManifestEntry is a line of a JSON manifest
+type jsonManifestEntry struct {
+	Path      string
+	Size      int64
+	Algorithm string
+	Hash      string // in hex, as in the other formats
+}
+
+// manifestPath is the path of an object in a manifest: its path relative to the root, or its name if it is the root
+func manifestPath(object StoredObject) string {
+	if object.relativePath == "" {
+		return object.name
+	}
+	return object.relativePath
+}
+
+// formatManifestEntry formats an entry as a line of a manifest, without the line feed
+func formatManifestEntry(format string, hashType common.ContentHashType, e hashManifestEntry) (string, error) {
+	hash := hex.EncodeToString(e.Hash)
+	switch format {
+	case manifestFormatGNU:
+		// as coreutils does, a path that would break the line is escaped, and the line marked as escaped
+		if strings.ContainsAny(e.Path, "\\\n\r") {
+			return `\` + hash + "  " + escapeManifestPath(e.Path), nil
+		}
+		return hash + "  " + e.Path, nil
+	case manifestFormatBSD:
+		if strings.ContainsAny(e.Path, "\\\n\r") {
+			return `\` + hashType.String() + " (" + escapeManifestPath(e.Path) + ") = " + hash, nil
+		}
+		return hashType.String() + " (" + e.Path + ") = " + hash, nil
+	case manifestFormatJSON:
+		b, err := json.Marshal(jsonManifestEntry{Path: e.Path, Size: e.Size, Algorithm: hashType.String(), Hash: hash})
+		return string(b), err
+	default:
+		return "", fmt.Errorf("'%s' is not a manifest format. Use one of: %s", format, strings.Join(validManifestFormats(), ", "))
+	}
+}
+
+var manifestPathEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+var manifestPathUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+func escapeManifestPath(path string) string {
+	return manifestPathEscaper.Replace(path)
+}
+
+// readHashManifest reads a manifest in any of the formats. Its hashes must be of hashType, which is checked where the
+// format records it.
+func readHashManifest(r io.Reader, hashType common.ContentHashType) ([]hashManifestEntry, error) {
+	var entries []hashManifestEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e, err := parseManifestLine(line, hashType)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the manifest: %w", lineNumber, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// manifestHashType returns the type of a manifest's hashes, which is named in BSD and JSON manifests, and is told by
+// the length of the hashes in GNU ones. A manifest without any is taken to be of SHA256, as azcopy hash writes.
+func manifestHashType(r io.Reader) (common.ContentHashType, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		_, algorithm, hash, err := splitManifestLine(line)
+		if err != nil {
+			return 0, fmt.Errorf("line %d of the manifest: %w", lineNumber, err)
+		}
+		var hashType common.ContentHashType
+		if algorithm != "" {
+			if err = hashType.Parse(algorithm); err != nil {
+				return 0, fmt.Errorf("line %d of the manifest: %s isn't a hash that can be verified", lineNumber, algorithm)
+			}
+			return hashType, nil
+		}
+		for _, hashType = range []common.ContentHashType{common.EContentHashType.MD5(), common.EContentHashType.SHA256(), common.EContentHashType.CRC64()} {
+			if len(hash) == 2*hashType.NewHasher().Size() {
+				return hashType, nil
+			}
+		}
+		return 0, fmt.Errorf("line %d of the manifest: '%s' isn't an MD5, SHA256 or CRC64 hash", lineNumber, hash)
+	}
+	return common.EContentHashType.SHA256(), scanner.Err()
+}
+
+func parseManifestLine(line string, hashType common.ContentHashType) (hashManifestEntry, error) {
+	e, algorithm, hash, err := splitManifestLine(line)
+	if err != nil {
+		return e, err
+	}
+	if algorithm != "" && !strings.EqualFold(algorithm, hashType.String()) {
+		return e, fmt.Errorf("the hash is %s, not %s", algorithm, hashType)
+	}
+	if e.Hash, err = hex.DecodeString(hash); err != nil || len(e.Hash) != hashType.NewHasher().Size() {
+		return e, fmt.Errorf("'%s' isn't a %s hash", hash, hashType)
+	}
+	return e, nil
+}
+
+// splitManifestLine returns the path and size of a line's entry, the algorithm of its hash if the format names it,
+// and its hash, in hex
+func splitManifestLine(line string) (e hashManifestEntry, algorithm, hash string, err error) {
+	e.Size = -1
+	if strings.HasPrefix(line, "{") {
+		var j jsonManifestEntry
+		if err = json.Unmarshal([]byte(line), &j); err != nil {
+			return e, "", "", err
+		}
+		return hashManifestEntry{Path: j.Path, Size: j.Size}, j.Algorithm, j.Hash, nil
+	}
+
+	escaped := strings.HasPrefix(line, `\`)
+	line = strings.TrimPrefix(line, `\`)
+	if a, rest, ok := strings.Cut(line, " ("); ok && !strings.Contains(a, " ") && strings.Contains(rest, ") = ") {
+		// BSD: the path may contain ") = " itself, so the hash is what follows the last of them
+		i := strings.LastIndex(rest, ") = ")
+		e.Path, algorithm, hash = rest[:i], a, rest[i+len(") = "):]
+	} else if h, path, ok := strings.Cut(line, " "); ok && len(path) > 0 && (path[0] == ' ' || path[0] == '*') {
+		// GNU: the hash, a space, and then a space for text mode or an asterisk for binary mode
+		hash, e.Path = h, path[1:]
+	} else {
+		return e, "", "", fmt.Errorf("'%s' isn't a line of a GNU, BSD or JSON manifest", line)
+	}
+	if escaped {
+		e.Path = manifestPathUnescaper.Replace(e.Path)
+	}
+	return e, algorithm, hash, nil
+}
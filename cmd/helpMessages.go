@@ -13,6 +13,17 @@ AzCopy is a command line tool that moves data into and out of Azure Storage.
 To report issues or to learn more about the tool, go to github.com/Azure/azure-storage-azcopy.
 
 The general format of the commands is: 'azcopy [command] [arguments] --[flag-name]=[flag-value]'.
+
+The exit code says how the command went, so that scripts can act on it without reading the output:
+  0  Success.
+  1  Failure, for any reason that doesn't have a code of its own.
+  3  Interrupted by SIGINT or SIGTERM, with the job left to be finished by 'azcopy jobs resume' (only when
+     AZCOPY_SHUTDOWN_GRACE_PERIOD is set).
+  4  Completed with errors: some transfers failed, but others were done.
+  5  Nothing to do: no files matched. Sync exits with 0 when the source and destination are already in sync.
+  6  Cancelled.
+  7  Authentication or authorization failed (401 or 403) at the source or the destination.
+  8  The network failed: the service couldn't be reached, or every transfer that failed was failed by the network.
 `
 
 // ===================================== COPY COMMAND ===================================== //
@@ -33,6 +44,8 @@ Copies source data to a destination location. The supported directions and forms
   - Azure Files NFS (Microsoft Entra ID or SAS) -> Azure Files NFS (Microsoft Entra ID or SAS)
   - AWS S3 (Access Key) -> Azure Block Blob (Microsoft Entra ID or SAS)
   - Google Cloud Storage (Service Account Key) -> Azure Block Blob (Microsoft Entra ID or SAS)
+  - WebDAV, e.g. Nextcloud or ownCloud (basic authentication) -> Azure Blob (Microsoft Entra ID or SAS)
+  - A list of http or https URLs (public, or presigned) -> Azure Blob (Microsoft Entra ID or SAS)
 
 Please refer to the examples for more information.
 
@@ -60,6 +73,10 @@ Same as above, but this time also compute MD5 hash of the file content and save
 
   - azcopy cp "/path/to/file.txt" "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --put-md5
 
+Same as above, but with a SHA-256 hash instead, saved in the blob's azcopy_content_sha256 metadata (downloads with --hash-algorithm=SHA256 check it):
+
+  - azcopy cp "/path/to/file.txt" "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --put-md5 --hash-algorithm=SHA256
+
 Upload a single file by using a SAS token:
 
   - azcopy cp "/path/to/file.txt" "https://[account].blob.core.windows.net/[container]/[path/to/blob]?[SAS]"
@@ -92,6 +109,34 @@ Upload files and directories by using a SAS token and wildcard (*) characters:
   - azcopy cp "/path/*foo/*bar*" "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" 
 	--recursive=true
 
+Upload an entire directory, packing the files smaller than 64 KiB into tar archive blobs of up to 256 MiB each:
+
+  - azcopy cp "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" 
+	--recursive=true --pack-small-files-kb=64
+
+Upload an entire directory of logs, compressing them with gzip as they are uploaded:
+
+  - azcopy cp "/path/to/logs" "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" 
+	--recursive=true --compress=gzip
+
+Upload a VM image again, sending only the blocks that have changed since it was last uploaded with --block-delta:
+
+  - azcopy cp "/path/to/disk.img" "https://[account].blob.core.windows.net/[container]/disk.img?[SAS]" --block-delta
+
+Upload a database dump again over a slow link, splitting it into blocks where its content says, so that rows inserted since the last upload don't change the blocks after them:
+
+  - azcopy cp "/path/to/dump.sql" "https://[account].blob.core.windows.net/[container]/dump.sql?[SAS]" --content-defined-blocks
+
+Upload an entire directory, encrypting each file on the client with a key of its own, wrapped by a Key Vault key:
+
+  - azcopy cp "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" 
+	--recursive=true --client-side-encryption-key="https://[vault].vault.azure.net/keys/[key]"
+
+Download blobs that were encrypted on the client with a key file, decrypting them as they are downloaded:
+
+  - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" "/path/to/dir" 
+	--recursive=true --client-side-encryption-key="/path/to/key"
+
 Upload files and directories to Azure Storage account and set the query-string encoded tags on the blob. 
 
 	- To set tags {key = "bla bla", val = "foo"} and {key = "bla bla 2", val = "bar"}, use the following syntax :
@@ -127,6 +172,15 @@ Download an entire directory by using a SAS token:
   - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" "/path/to/dir" 
 	--recursive=true
 
+Download an entire directory that was uploaded with --pack-small-files-kb, extracting the packed files as they are downloaded:
+
+  - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" "/path/to/dir" 
+	--recursive=true --extract-packs
+
+Download a tar archive, extracting its files into a directory as it is downloaded, rather than saving the archive:
+
+  - azcopy cp "https://[account].blob.core.windows.net/[container]/[path/to/archive.tar.gz]?[SAS]" "/path/to/dir" --untar
+
 A note about using a wildcard character (*) in URLs:
 
 There's only two supported ways to use a wildcard character in a URL. 
@@ -211,12 +265,37 @@ Make sure to set the environment variable AWS_ACCESS_KEY_ID and AWS_SECRET_ACCES
 
   - azcopy cp "https://s3.amazonaws.com/[bucket*name]/" "https://[destaccount].blob.core.windows.net?[SAS]" --recursive=true
 
+Copy a bucket from an S3-compatible service such as MinIO or Ceph RGW. Set AZCOPY_S3_ENDPOINT to its address, and AZCOPY_S3_REGION,
+AZCOPY_S3_ADDRESSING_STYLE or AZCOPY_S3_SIGNATURE_VERSION if it needs them. The service must be reachable from Azure Storage, 
+which fetches each object from it.
+
+  - AZCOPY_S3_ENDPOINT=http://minio.example.com:9000 azcopy cp "http://minio.example.com:9000/[bucket]/" "https://[destaccount].blob.core.windows.net/[container]?[SAS]" --recursive=true
+
+Copy a folder from a WebDAV server such as Nextcloud or ownCloud. Use webdavs:// for a server reached over https, and 
+webdav:// for one reached over http. Set AZCOPY_WEBDAV_USERNAME and AZCOPY_WEBDAV_PASSWORD to log in. AzCopy reads each 
+file from the server itself, so the server doesn't need to be reachable from Azure Storage.
+
+  - azcopy cp "webdavs://cloud.example.com/remote.php/dav/files/[user]/[folder]/" "https://[destaccount].blob.core.windows.net/[container]?[SAS]" --recursive=true
+
+Copy a dataset published as a list of http or https URLs, one per line, such as public or presigned URLs. Azure Storage
+reads each URL itself, so they must be reachable from it. Each file is named after the path of its URL, unless a name 
+follows the URL after a tab.
+
+  - azcopy cp --list-of-urls "/path/to/urls.txt" "https://[destaccount].blob.core.windows.net/[container]/[path/to/directory]?[SAS]"
+
 Copy blobs from one blob storage to another and preserve the tags from source. 
 To preserve tags, use the following syntax:
   	
   - azcopy cp "https://[account].blob.core.windows.net/[source_container]/[path/to/directory]?[SAS]" 
 	"https://[account].blob.core.windows.net/[destination_container]/[path/to/directory]?[SAS]" --s2s-preserve-blob-tags=true
 
+Copy blobs from one blob storage to another, encrypting each with the encryption scope of its source, 
+or with the scope "[scope]" where its source has none:
+
+  - azcopy cp "https://[srcaccount].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" 
+	"https://[destaccount].blob.core.windows.net/[container]/[path/to/directory]?[SAS]" --recursive=true 
+	--s2s-preserve-encryption-scope=true --encryption-scope="[scope]"
+
 Transfer files and directories to Azure Storage account and set the given query-string encoded tags on the blob. 
 
 	- To set tags {key = "bla bla", val = "foo"} and {key = "bla bla 2", val = "bar"}, use the following syntax :
@@ -294,12 +373,19 @@ const showJobsCmdLongDescription = `
 If you provide only a job ID, and not a flag, then this command returns the progress summary only.
 The byte counts and percent complete that appears when you run this command reflect only files that are completed in the job. 
 They don't reflect partially completed files.
-If you set the with-status flag, then only the list of transfers associated with the given status appear.`
+If you set the with-status flag, then only the list of transfers associated with the given status appear.
+If you set the live flag, then the AzCopy process that is running the job is asked instead, over the Unix domain socket 
+[job ID].sock in the plan folder, so progress includes files in flight and throughput, and keeps being shown until the job is done. 
+Other tools can ask the same socket over HTTP: GET /v1/progress for the progress, and GET /v1/transfers?status=[status] for the transfers.`
 
 const resumeJobsCmdShortDescription = "Resume the existing job with the given job ID."
 
 const resumeJobsCmdLongDescription = `
-Resume the existing job with the given job ID.`
+Resume the existing job with the given job ID.
+
+By default, every transfer that didn't succeed is retried. Use --failed-only to retry only the transfers that failed, and 
+--include and --exclude to retry only some of them, by the name or path of their source. The transfers that aren't 
+retried are left as they were, so a later resume can still retry them.`
 
 const removeJobsCmdShortDescription = "Remove all files associated with the given job ID."
 
@@ -313,9 +399,44 @@ const removeJobsCmdExample = "  azcopy jobs rm e52247de-0323-b14d-4cc8-76e0be2e2
 const cleanJobsCmdShortDescription = "Remove all log and plan files for all jobs"
 
 const cleanJobsCmdLongDescription = `
-Note that you can customize the location where log and plan files are saved. See the env command to learn more.`
+Note that you can customize the location where log and plan files are saved. See the env command to learn more.
+
+To keep some jobs, give a retention limit: --older-than removes the jobs that started longer ago than an age, --keep-last 
+removes all but the newest jobs, and --max-size removes the oldest jobs until the rest take up no more than a size. A job 
+is removed if any of the limits given says so, and jobs that are still in progress are never removed by them.
+
+The same limits can be applied automatically, each time a copy or sync job is done, by setting 
+AZCOPY_JOB_RETENTION_OLDER_THAN, AZCOPY_JOB_RETENTION_KEEP_LAST and AZCOPY_JOB_RETENTION_MAX_SIZE.`
+
+const cleanJobsCmdExample = `  azcopy jobs clean --with-status=completed
+  azcopy jobs clean --older-than=30d --max-size=10G`
+
+const exportJobsCmdShortDescription = "Export the plan of the given job ID to JSON or CSV"
 
-const cleanJobsCmdExample = "  azcopy jobs clean --with-status=completed"
+const exportJobsCmdLongDescription = `
+Export everything the plan files of the given job record about it: the command it was run with, where it copies from 
+and to, and, for every transfer, its source and destination, whether it is a file or folder, its status and error 
+code, the size and last modified time of its source, and how much of it was kept to carry on from when the job was 
+last stopped. This lets you see exactly what a job set out to do and what it has done, without reading the plan files.
+
+JSON exports the job as a single document, and CSV has a line per transfer. Sources and destinations leave out any 
+SAS the job was given.`
+
+const exportJobsCmdExample = `  azcopy jobs export e52247de-0323-b14d-4cc8-76e0be2e2d44
+  azcopy jobs export e52247de-0323-b14d-4cc8-76e0be2e2d44 --format=csv --output=transfers.csv`
+
+const historyJobsCmdShortDescription = "Show the jobs that have finished, with their statistics"
+
+const historyJobsCmdLongDescription = `
+Show the copy, sync and resumed jobs that have finished, newest first, with how long they took, how many transfers 
+completed, failed and were skipped, how many bytes were transferred, and the command and flags they were run with.
+
+Each job is added to the job history as it finishes, which is kept in the file history.jsonl in the plan folder, 
+as a line of JSON for each job, so that it can be read by other tools as well. Removing jobs with 'azcopy jobs clean' 
+doesn't remove them from the history. Use --output-type=json to get the jobs as JSON, for reporting.`
+
+const historyJobsCmdExample = `  azcopy jobs history --since=7d --with-status=CompletedWithErrors
+  azcopy jobs history --command=sync --last=10 --output-type=json`
 
 // ===================================== LIST COMMAND ===================================== //
 const listCmdShortDescription = "List the entities in a given resource"
@@ -326,7 +447,11 @@ Microsoft Entra ID authorization for Files is currently not supported; please us
 
 const listCmdExample = "azcopy list [containerURL] --properties [semicolon(;) separated list of attributes " +
 	"(LastModifiedTime, VersionId, BlobType, BlobAccessTier, ContentType, ContentEncoding, ContentMD5, LeaseState, LeaseDuration, LeaseStatus) " +
-	"enclosed in double quotes (\")]"
+	"enclosed in double quotes (\")]\n\n" +
+	"Show the virtual directory hierarchy of a container, with the size and file count of each directory:\n\n" +
+	"  - azcopy list [containerURL] --output-format tree\n\n" +
+	"List every blob, with its versions and snapshots and all their properties, as CSV:\n\n" +
+	"  - azcopy list [containerURL] --properties all --include-versions --include-snapshots --output-format csv"
 
 // ===================================== LOGIN COMMAND ===================================== //
 const loginCmdShortDescription = "Log in to Microsoft Entra ID to access Azure Storage resources."
@@ -701,6 +826,9 @@ Change metadata of all files in a directory to {key = "abc", val = "def"} and {k
 Clear all existing metadata of blob:
 	- azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --metadata=clear
 
+Change metadata of a blob encrypted with a client provided key, whose key and its hash are in CPK_ENCRYPTION_KEY and CPK_ENCRYPTION_KEY_SHA256:
+	- azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --metadata=abc=def --cpk-by-value
+
 Clear all existing metadata from all files:
  - azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --recursive --metadata=clear
 
@@ -712,3 +840,227 @@ Clear all existing blob-tags of blob:
 	- azcopy set-properties "https://[account].blob.core.windows.net/[container]/[path/to/blob]" --blob-tags=clear
 	- While setting tags on the blobs, there are additional permissions('t' for tags) in SAS without which the service will give authorization error back.
 `
+
+// ===================================== DAEMON COMMAND ===================================== //
+const daemonCmdShortDescription = "Runs AzCopy as a background service that accepts jobs over a local socket"
+
+const daemonCmdLongDescription = `Runs AzCopy as a background service. Jobs are submitted to it over a Unix domain socket with 'azcopy daemon submit', 
+and each runs as its own AzCopy process, with its output written to daemon-<number>.log in the log folder.
+
+Unless --foreground is given, the daemon detaches from the terminal. The pidfile is locked for as long as the daemon runs, 
+so a second daemon with the same pidfile refuses to start. 
+
+Signals:
+  - SIGHUP rereads the --config file.
+  - SIGTERM or SIGINT stops accepting jobs, cancels the running ones (they can be picked up later with 'azcopy jobs resume'), and exits once they have stopped.
+
+The config file is JSON, with these optional fields:
+  - MaxConcurrentJobs: how many jobs run at once; the rest wait their turn. Defaults to 1.
+  - MaxFinishedJobs: how many finished jobs the daemon remembers; older ones are forgotten, though their logs are kept. Defaults to 1000.
+  - Environment: variables to add to the environment of every job, for example AZCOPY_AUTO_LOGIN_TYPE.
+  - Schedules: jobs for the daemon to submit itself, on schedules like cron's. See 'azcopy schedule --help'.
+
+With --listen, the daemon also serves a REST API, so that other services can orchestrate transfers without shelling out:
+  - GET /v1/jobs lists the jobs (add ?state=Running, for example, to filter them), and POST /v1/jobs submits one, given {"Args": ["copy", ...]}.
+  - GET /v1/jobs/{id} gets a job, including its AzCopy job ID and latest progress, and GET /v1/jobs/{id}/progress streams it as 
+    newline-delimited JSON until it finishes.
+  - POST /v1/jobs/{id}/pause, /resume and /cancel control a job. Cancelling lets transfers in progress finish first.
+  - GET /v1/schedules lists the schedules, and POST /v1/schedules/{name}/run runs one now.
+A TCP address needs AZCOPY_DAEMON_API_TOKEN set. It must be a loopback address, unless --tls-cert and --tls-key are given
+to serve the API over TLS.
+Jobs run with --output-type=json, unless they give an output type of their own, since that's where their progress comes from.
+
+The socket is only accessible to the user running the daemon, since anyone who can submit a job can use its credentials.
+Submitted jobs can't use --pre-hook, --post-hook or --from-zfs-snapshot, since those run commands as the daemon's user;
+scheduled jobs can.`
+
+const daemonCmdExample = `Start the daemon under daemon(8), as an rc.d script would:
+  - daemon -r -P /var/run/azcopy_daemon.pid azcopy daemon --foreground --pidfile /var/run/azcopy.pid --config /usr/local/etc/azcopy.json
+
+Submit a job (flags for the job go after --):
+  - azcopy daemon submit -- sync "/data" "https://[account].blob.core.windows.net/[container]" --recursive
+
+Show what the daemon is doing:
+  - azcopy daemon status
+
+Serve the REST API on a local port, and submit a job to it:
+  - AZCOPY_DAEMON_API_TOKEN=[token] azcopy daemon --listen 127.0.0.1:8733
+  - curl -H "Authorization: Bearer [token]" -d '{"Args": ["copy", "/data", "https://[account].blob.core.windows.net/[container]", "--recursive"]}' http://127.0.0.1:8733/v1/jobs`
+
+const daemonSubmitCmdShortDescription = "Submits a job to a running AzCopy daemon"
+
+const daemonStatusCmdShortDescription = "Lists the jobs a running AzCopy daemon has been given"
+
+const daemonControlCmdShortDescription = "%ss a job that a running AzCopy daemon has been given"
+
+// ===================================== SCHEDULE COMMAND ===================================== //
+const scheduleCmdShortDescription = "Shows and runs the jobs that an AzCopy daemon runs on a schedule"
+
+const scheduleCmdLongDescription = `Shows and runs the jobs that an AzCopy daemon runs on a schedule. 
+
+Schedules are saved in the Schedules field of the daemon's --config file, and reread with it on SIGHUP. Each has:
+  - Name: what the schedule is called. Its jobs' output is added to schedule-<name>.log in the log folder.
+  - Schedule: when it runs, as the five fields of a crontab(5) line (minute, hour, day of the month, month and day of the week), 
+    in local time. Lists (1,15), ranges (1-5), steps (*/10) and names (mon, jan) are allowed, as are @yearly, @monthly, 
+    @weekly, @daily and @hourly.
+  - Args: the AzCopy command line to run, as for 'azcopy daemon submit'.
+
+A schedule's job doesn't overlap its last one: if the last is still queued or running when the schedule is next due, 
+that run is skipped. Runs that are missed because the daemon wasn't running, or the machine was asleep, aren't made up.`
+
+const scheduleCmdExample = `Run a sync every night at 02:30, and a copy every 15 minutes during working hours on weekdays, from /usr/local/etc/azcopy.json:
+  {
+    "Schedules": [
+      {"Name": "nightly", "Schedule": "30 2 * * *", "Args": ["sync", "/data", "https://[account].blob.core.windows.net/[container]", "--recursive"]},
+      {"Name": "inbox", "Schedule": "*/15 9-17 * * mon-fri", "Args": ["copy", "/inbox/*", "https://[account].blob.core.windows.net/inbox"]}
+    ]
+  }
+
+Show the schedules, and when they last ran and next run:
+  - azcopy schedule list
+
+Run a schedule now:
+  - azcopy schedule run nightly
+
+Check when a schedule would run:
+  - azcopy schedule next "0 3 1-7 * sun"`
+
+const scheduleListCmdShortDescription = "Lists the schedules of a running AzCopy daemon, and when they last and next run"
+
+const scheduleRunCmdShortDescription = "Runs a schedule of a running AzCopy daemon now"
+
+const scheduleNextCmdShortDescription = "Shows when a schedule would next run"
+
+// ===================================== VERIFY COMMAND ===================================== //
+const verifyCmdShortDescription = "Verify that the files at a destination match those at the source"
+
+const verifyCmdLongDescription = `
+Verify that each file at the source is at the destination, with the same size and hash, and report any that aren't.
+It doesn't transfer anything, so it can be run after a copy or sync has finished, to check what it did.
+
+Remote files are compared by the hash recorded of them: the Content-MD5 property for MD5, which azcopy's --put-md5 sets
+(as do most other tools), and the metadata that --put-md5 records with --hash-algorithm SHA256 or CRC64. Local files are
+read to hash them. Blobs that azcopy compressed as it uploaded them are compared by what they decompress to.
+
+The report written with --report lists every file at the source, and what was found for it at the destination. With
+--signing-key, it is signed, so that it can be shown later not to have been changed; the signature is written beside it,
+with .sig appended to its name, and can be checked with OpenSSL.
+
+The command exits with a failure if anything other than a file that matched is found, apart from files whose hashes
+differ when --check-hash is LogOnly, and files without a hash to compare unless --check-hash is FailIfDifferentOrMissing.
+Files that are only at the destination are counted, but are not a failure.
+
+With --manifest, the destination is verified against a manifest written by azcopy hash (or by sha256sum, md5sum, or
+FreeBSD's sha256), instead of against a source, so only the destination is given. Unless --hash-algorithm says
+otherwise, files are compared by the hash that the manifest is of.`
+
+const verifyCmdExample = `
+Verify an upload:
+
+  - azcopy verify "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]?[SAS]"
+
+Verify a copy between containers by SHA-256 hashes, failing for any file without one, and write a signed report:
+
+  - openssl genpkey -algorithm ed25519 -out key.pem
+  - azcopy verify "https://[srcaccount].blob.core.windows.net/[container]?[SAS]" "https://[destaccount].blob.core.windows.net/[container]?[SAS]" --hash-algorithm=SHA256 --check-hash=FailIfDifferentOrMissing --report=report.json --signing-key=key.pem
+  - openssl pkey -in key.pem -pubout -out public.pem
+  - openssl pkeyutl -verify -pubin -inkey public.pem -rawin -in report.json -sigfile report.json.sig
+
+Verify a container against a manifest of SHA-256 hashes:
+
+  - azcopy verify "https://[account].blob.core.windows.net/[container]?[SAS]" --manifest=manifest.sha256
+`
+
+// ===================================== HASH COMMAND ===================================== //
+const hashCmdShortDescription = "Write a manifest of the hashes of the files at a location"
+
+const hashCmdLongDescription = `
+Write a manifest of the files in a local directory or a container (or a path within it), with the hash of each.
+
+Local files are read to hash them. Remote files aren't downloaded: their hash is the one recorded of them, in the
+Content-MD5 property for MD5, or in the metadata that --put-md5 records with --hash-algorithm SHA256 or CRC64. Remote
+files without one are left out of the manifest, logged, and make the command exit with a failure.
+
+The manifest is written in one of these formats:
+  - gnu: "<hash>  <path>", as written and checked by sha256sum and md5sum
+  - bsd: "<ALGORITHM> (<path>) = <hash>", as written by sha256 and md5 on FreeBSD, and by sha256sum --tag
+  - json: an object per line, with the path, size, algorithm and hash of each file
+
+Paths are relative to the location, so the manifest can be checked against a copy of it, with azcopy verify --manifest,
+or with "sha256sum -c" from the directory it was copied to.`
+
+const hashCmdExample = `
+Write a manifest of the SHA-256 hashes of a directory:
+
+  - azcopy hash "/path/to/dir" --output=manifest.sha256
+
+Write a manifest of the MD5 hashes of a container, as recorded by --put-md5, in the BSD format:
+
+  - azcopy hash "https://[account].blob.core.windows.net/[container]?[SAS]" --hash-algorithm=MD5 --format=bsd
+`
+
+// ===================================== DIFF COMMAND ===================================== //
+const diffCmdShortDescription = "Show the differences between a source and a destination, without transferring anything"
+
+const diffCmdLongDescription = `
+Compare the files at the source with those at the destination, as sync does, and show what differs:
+  - OnlyInSource: files at the source that aren't at the destination
+  - OnlyInDestination: files at the destination that aren't at the source
+  - Modified: files at both that sync would transfer, since the source is more recent (or, with --compare-hash MD5,
+    since their hashes differ)
+
+Nothing is transferred or deleted. With --report, the differences are also written to a file, in JSON.`
+
+const diffCmdExample = `
+Show how a directory differs from a container:
+
+  - azcopy diff "/path/to/dir" "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]?[SAS]"
+
+Compare two containers by their MD5 hashes, and write the differences to a file:
+
+  - azcopy diff "https://[srcaccount].blob.core.windows.net/[container]?[SAS]" "https://[destaccount].blob.core.windows.net/[container]?[SAS]" --compare-hash=MD5 --report=diff.json
+`
+
+// ===================================== ESTIMATE COMMAND ===================================== //
+const estimateCmdShortDescription = "Estimate what syncing a source to a destination would transfer, and how long it would take"
+
+const estimateCmdLongDescription = `
+Compare the files at the source with those at the destination, as sync (and diff) do, and add up:
+  - the files to transfer: those that aren't at the destination, or are more recent at the source (or, with
+    --compare-hash MD5, whose hashes differ)
+  - the files to delete: those that are only at the destination, which sync deletes with --delete-destination
+
+With --bandwidth-mbps, it also estimates how long the transfer takes at that bandwidth, not counting the time taken to
+start each file. Nothing is transferred or deleted, so it can be used to check filters, or to plan a maintenance window.`
+
+const estimateCmdExample = `
+Estimate how long syncing a directory to a container takes at 500 Mbps:
+
+  - azcopy estimate "/path/to/dir" "https://[account].blob.core.windows.net/[container]?[SAS]" --bandwidth-mbps=500
+
+Check what an include pattern leaves in, with sizes in bytes, as JSON:
+
+  - azcopy estimate "/path/to/dir" "https://[account].blob.core.windows.net/[container]?[SAS]" --include-pattern="*.log" --machine-readable --output-type=json
+`
+
+// ===================================== DU COMMAND ===================================== //
+const duCmdShortDescription = "Show how much space the files at a location use"
+
+const duCmdLongDescription = `
+Add up the number and size of the files in a local directory, a container (or a virtual directory within it), a file
+share, or a whole account, and break them down by the directories (or virtual directories) they are in, to --depth
+levels. Nothing is read but the listing, so it can be used to estimate what a transfer will move, or what it costs to
+store what is there.
+
+With --include-versions and --include-snapshots, the previous versions and snapshots of blobs, which are charged for as
+well, are counted too: they are shown apart from the current blobs, and in the breakdown and total.`
+
+const duCmdExample = `
+Show how much space a container uses, by its top-level virtual directories:
+
+  - azcopy du "https://[account].blob.core.windows.net/[container]?[SAS]"
+
+Show how much space a virtual directory uses, two levels down, including the previous versions and snapshots of its blobs:
+
+  - azcopy du "https://[account].blob.core.windows.net/[container]/[path/to/virtual/dir]?[SAS]" --depth=2 --include-versions --include-snapshots
+`
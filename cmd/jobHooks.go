@@ -0,0 +1,133 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// jobHooks are the commands that --pre-hook and --post-hook run, through the shell, before the job and after it
+type jobHooks struct {
+	command     string
+	source      string
+	destination string
+	pre         string
+	post        string
+}
+
+// hooks is nil unless --pre-hook or --post-hook was given
+var hooks *jobHooks
+
+// setUpHooks has the pre hook run by runPreHook, and the post hook once the job is done
+func setUpHooks(command, source, destination, pre, post string) {
+	if pre == "" && post == "" {
+		return
+	}
+	hooks = &jobHooks{command: command, source: source, destination: destination, pre: pre, post: post}
+}
+
+// runPreHook runs the pre hook, if there is one, before the job's source is scanned. The job isn't started if it fails.
+func runPreHook() error {
+	if hooks == nil || hooks.pre == "" {
+		return nil
+	}
+	if err := runHook("pre", hooks.pre, hooks.environment(nil)); err != nil {
+		return fmt.Errorf("the --%s command failed, so the job wasn't started: %w", PreHookFlag, err)
+	}
+	return nil
+}
+
+// runPost runs the post hook, if there is one, for the job that finished with n. Its failure doesn't fail the job,
+// which has already finished.
+func (h *jobHooks) runPost(n jobNotification) {
+	if h.post == "" {
+		return
+	}
+	if err := runHook("post", h.post, h.environment(&n)); err != nil {
+		glcm.Warn(fmt.Sprintf("The --%s command failed: %s", PostHookFlag, err))
+	}
+}
+
+// environment is what the hooks are told about the job, which is only its ID and what it is from and to until it has
+// finished with outcome
+func (h *jobHooks) environment(outcome *jobNotification) []string {
+	env := []string{
+		"AZCOPY_JOB_ID=" + Client.CurrentJobID.String(),
+		"AZCOPY_COMMAND=" + h.command,
+		"AZCOPY_SOURCE=" + h.source,
+		"AZCOPY_DESTINATION=" + h.destination,
+	}
+	if outcome == nil {
+		return env
+	}
+	return append(env,
+		"AZCOPY_JOB_STATUS="+outcome.Status,
+		"AZCOPY_EXIT_CODE="+strconv.Itoa(int(outcome.ExitCode)),
+		"AZCOPY_ERROR="+outcome.Error,
+		"AZCOPY_TRANSFERS_TOTAL="+strconv.FormatUint(uint64(outcome.TotalTransfers), 10),
+		"AZCOPY_TRANSFERS_COMPLETED="+strconv.FormatUint(uint64(outcome.TransfersCompleted), 10),
+		"AZCOPY_TRANSFERS_FAILED="+strconv.FormatUint(uint64(outcome.TransfersFailed), 10),
+		"AZCOPY_TRANSFERS_SKIPPED="+strconv.FormatUint(uint64(outcome.TransfersSkipped), 10),
+		"AZCOPY_BYTES_TRANSFERRED="+strconv.FormatUint(outcome.BytesTransferred, 10),
+		"AZCOPY_DURATION_SECONDS="+strconv.FormatFloat(outcome.DurationSeconds, 'f', 0, 64))
+}
+
+// runHook runs command through the shell, with env added to our environment. Its output goes to the job log rather
+// than to ours, which may be JSON.
+func runHook(name, command string, env []string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if output != "" {
+		common.LogToJobLogWithPrefix(fmt.Sprintf("Output of the %s hook:\n%s", name, output), common.LogInfo)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && output != "" {
+			return fmt.Errorf("%w: %s", err, lastLine(output))
+		}
+		return err
+	}
+	return nil
+}
+
+// lastLine is the last line of s, which is where a failing command usually says why
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[i+1:])
+	}
+	return s
+}
@@ -23,6 +23,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-storage-azcopy/v10/azcopy"
 	"github.com/spf13/cobra"
@@ -35,6 +39,9 @@ var JobsCleanupSuccessMsg = "Successfully removed all jobs."
 func init() {
 	type JobsCleanReq struct {
 		withStatus string
+		olderThan  string
+		keepLast   int
+		maxSize    string
 	}
 
 	commandLineInput := JobsCleanReq{}
@@ -59,9 +66,18 @@ func init() {
 				glcm.Error(fmt.Sprintf("Failed to parse --with-status due to error: %s.", err))
 			}
 
-			err = handleCleanJobsCommand(withStatus)
+			retention, err := parseRetentionPolicy(commandLineInput.olderThan, commandLineInput.keepLast, commandLineInput.maxSize)
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+
+			err = handleCleanJobsCommand(withStatus, retention)
 			if err == nil {
-				if withStatus == common.EJobStatus.All() {
+				if retention.IsSet() {
+					glcm.Exit(func(format common.OutputFormat) string {
+						return "Successfully removed the jobs outside the retention limits."
+					}, common.EExitCode.Success())
+				} else if withStatus == common.EJobStatus.All() {
 					glcm.Exit(func(format common.OutputFormat) string {
 						return "Successfully removed all jobs."
 					}, common.EExitCode.Success())
@@ -83,15 +99,83 @@ func init() {
 		"Only remove the jobs with the specified status. Available values include: "+
 			"\n All, Cancelled, Failed, Completed,"+
 			" CompletedWithErrors, CompletedWithSkipped, CompletedWithErrorsAndSkipped")
+	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.olderThan, "older-than", "",
+		"Only remove the jobs that started longer ago than this, e.g. 30d or 12h.")
+	jobsCleanCmd.PersistentFlags().IntVar(&commandLineInput.keepLast, "keep-last", 0,
+		"Only remove the jobs other than the newest this many.")
+	jobsCleanCmd.PersistentFlags().StringVar(&commandLineInput.maxSize, "max-size", "",
+		"Only remove the oldest jobs, until the plan and log files of the rest take up no more than this, given as "+sizeStringDescription+".")
+}
+
+// parseJobAge reads how long ago a job started, e.g. 12h, or 30d, since that's what job ages are usually thought of in
+func parseJobAge(s string) (age time.Duration, err error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n float64
+		n, err = strconv.ParseFloat(days, 64)
+		age = time.Duration(n * float64(24*time.Hour))
+	} else {
+		age, err = time.ParseDuration(s)
+	}
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("'%s' is not an age. Give one such as 30d or 12h", s)
+	}
+	return age, nil
+}
+
+// parseRetentionPolicy reads the limits of a retention policy, any of which may be empty (or 0) to leave it unset
+func parseRetentionPolicy(olderThan string, keepLast int, maxSize string) (policy azcopy.RetentionPolicy, err error) {
+	if olderThan != "" {
+		if policy.OlderThan, err = parseJobAge(olderThan); err != nil {
+			return policy, err
+		}
+	}
+	if keepLast < 0 {
+		return policy, errors.New("the number of jobs to keep can't be negative")
+	}
+	policy.KeepLast = keepLast
+	if maxSize != "" {
+		if policy.MaxTotalBytes, err = ParseSizeString(maxSize, "the maximum size of the jobs' files"); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+// registerJobRetention arranges for the retention policy in the environment, if there is one, to be applied once the
+// current job is done, so that the plan and log folders don't grow without end
+func registerJobRetention() error {
+	keepLast := 0
+	if raw := common.GetEnvironmentVariable(common.EEnvironmentVariable.JobRetentionKeepLast()); raw != "" {
+		var err error
+		if keepLast, err = strconv.Atoi(raw); err != nil {
+			return fmt.Errorf("%s must be a number of jobs", common.EEnvironmentVariable.JobRetentionKeepLast().Name)
+		}
+	}
+	retention, err := parseRetentionPolicy(
+		common.GetEnvironmentVariable(common.EEnvironmentVariable.JobRetentionOlderThan()),
+		keepLast,
+		common.GetEnvironmentVariable(common.EEnvironmentVariable.JobRetentionMaxSize()))
+	if err != nil {
+		return fmt.Errorf("the job retention policy in the environment is invalid: %w", err)
+	}
+	if !retention.IsSet() {
+		return nil
+	}
+
+	glcm.RegisterCloseFunc(func() {
+		// the job is done by now, so a failure to tidy up after others shouldn't fail it. The next job will try again.
+		_, _ = Client.CleanJobs(azcopy.CleanJobsOptions{Retention: retention})
+	})
+	return nil
 }
 
-func handleCleanJobsCommand(givenStatus common.JobStatus) error {
-	result, err := Client.CleanJobs(azcopy.CleanJobsOptions{WithStatus: to.Ptr(givenStatus)})
+func handleCleanJobsCommand(givenStatus common.JobStatus, retention azcopy.RetentionPolicy) error {
+	result, err := Client.CleanJobs(azcopy.CleanJobsOptions{WithStatus: to.Ptr(givenStatus), Retention: retention})
 	if err != nil {
 		return err
 	}
 
-	if givenStatus == common.EJobStatus.All() {
+	if givenStatus == common.EJobStatus.All() && !retention.IsSet() {
 		glcm.Info(fmt.Sprintf("Removed %v files.", result.Count))
 	} else {
 		for _, job := range result.Jobs {
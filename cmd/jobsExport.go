@@ -0,0 +1,135 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/azcopy"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/spf13/cobra"
+)
+
+// the formats that a job's plan can be exported in
+const (
+	jobExportFormatJSON = "json"
+	jobExportFormatCSV  = "csv"
+)
+
+var jobExportCSVHeader = []string{"PartNumber", "TransferIndex", "Source", "Destination", "EntityType", "Status",
+	"ErrorCode", "SourceSize", "LastModifiedTime", "SavedOffset", "BlockSize"}
+
+// formatJobPlanExport renders an exported plan: as a single JSON document, or as CSV with a line per transfer
+func formatJobPlanExport(plan azcopy.ExportJobPlanResponse, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case jobExportFormatJSON:
+		b, err := json.MarshalIndent(plan, "", "  ")
+		return string(b), err
+	case jobExportFormatCSV:
+		var sb strings.Builder
+		sb.WriteString(toCSVLine(jobExportCSVHeader) + "\n")
+		for _, t := range plan.Transfers {
+			lastModified := ""
+			if !t.LastModifiedTime.IsZero() {
+				lastModified = t.LastModifiedTime.Format(time.RFC3339Nano)
+			}
+			sb.WriteString(toCSVLine([]string{
+				strconv.FormatUint(uint64(t.PartNumber), 10),
+				strconv.FormatUint(uint64(t.TransferIndex), 10),
+				t.Source,
+				t.Destination,
+				t.EntityType,
+				t.Status,
+				strconv.FormatInt(int64(t.ErrorCode), 10),
+				strconv.FormatInt(t.SourceSize, 10),
+				lastModified,
+				strconv.FormatInt(t.SavedOffset, 10),
+				strconv.FormatInt(t.BlockSize, 10),
+			}) + "\n")
+		}
+		return sb.String(), nil
+	default:
+		return "", fmt.Errorf("'%s' is not an export format. Use %s or %s", format, jobExportFormatJSON, jobExportFormatCSV)
+	}
+}
+
+func init() {
+	type JobsExportReq struct {
+		JobID  common.JobID
+		Format string
+		Output string
+	}
+
+	commandLineInput := JobsExportReq{}
+
+	// dump a job's plan files in a form that can be read without AzCopy
+	jobsExportCmd := &cobra.Command{
+		Use:     "export [jobID]",
+		Short:   exportJobsCmdShortDescription,
+		Long:    exportJobsCmdLongDescription,
+		Example: exportJobsCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("export job command requires the JobID")
+			}
+			// Parse the JobId
+			jobId, err := common.ParseJobID(args[0])
+			if err != nil {
+				return errors.New("invalid jobId given " + args[0])
+			}
+			commandLineInput.JobID = jobId
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			plan, err := Client.ExportJobPlan(azcopy.ExportJobPlanOptions{JobID: commandLineInput.JobID})
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			export, err := formatJobPlanExport(plan, commandLineInput.Format)
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+
+			if commandLineInput.Output == "" {
+				glcm.Exit(func(format common.OutputFormat) string {
+					return strings.TrimSuffix(export, "\n")
+				}, common.EExitCode.Success())
+			}
+			if err := os.WriteFile(commandLineInput.Output, []byte(export), 0644); err != nil {
+				glcm.Error(fmt.Sprintf("failed to write the export: %s", err))
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				return fmt.Sprintf("Exported %d transfers of job %s to %s.", len(plan.Transfers), commandLineInput.JobID, commandLineInput.Output)
+			}, common.EExitCode.Success())
+		},
+	}
+
+	jobsCmd.AddCommand(jobsExportCmd)
+
+	jobsExportCmd.PersistentFlags().StringVar(&commandLineInput.Format, "format", jobExportFormatJSON, "The format to export the plan in: json, or csv with a line per transfer.")
+	jobsExportCmd.PersistentFlags().StringVar(&commandLineInput.Output, "output", "", "The file to write the export to, instead of the standard output.")
+}
@@ -0,0 +1,181 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-storage-azcopy/v10/azcopy"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	type JobsHistoryReq struct {
+		withStatus string
+		since      string
+		command    string
+		last       int
+	}
+
+	commandLineInput := JobsHistoryReq{}
+
+	jobsHistoryCmd := &cobra.Command{
+		Use:     "history",
+		Short:   historyJobsCmdShortDescription,
+		Long:    historyJobsCmdLongDescription,
+		Example: historyJobsCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return errors.New("history command does not accept arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			withStatus := common.EJobStatus
+			if err := withStatus.Parse(commandLineInput.withStatus); err != nil {
+				glcm.Error(fmt.Sprintf("Failed to parse --with-status due to error: %s.", err))
+			}
+			since, err := parseHistorySince(commandLineInput.since, time.Now())
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+
+			resp, err := Client.JobHistory(azcopy.JobHistoryOptions{
+				WithStatus: to.Ptr(withStatus),
+				Since:      since,
+				Command:    commandLineInput.command,
+				Last:       commandLineInput.last,
+			})
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			printJobHistory(resp)
+		},
+	}
+
+	jobsCmd.AddCommand(jobsHistoryCmd)
+
+	jobsHistoryCmd.PersistentFlags().StringVar(&commandLineInput.withStatus, "with-status", "All",
+		"Only show the jobs that finished with the specified status. Available values include: "+
+			"\n All, Cancelled, Failed, Completed,"+
+			" CompletedWithErrors, CompletedWithSkipped, CompletedWithErrorsAndSkipped")
+	jobsHistoryCmd.PersistentFlags().StringVar(&commandLineInput.since, "since", "",
+		"Only show the jobs that started since then, given as an age, e.g. 7d or 12h, or a date, e.g. 2024-05-01 or 2024-05-01T12:00:00Z.")
+	jobsHistoryCmd.PersistentFlags().StringVar(&commandLineInput.command, "command", "",
+		"Only show the jobs whose command starts with this, e.g. sync, or \"jobs resume\".")
+	jobsHistoryCmd.PersistentFlags().IntVar(&commandLineInput.last, "last", 0,
+		"Only show the newest this many of the jobs.")
+}
+
+// parseHistorySince reads the time that jobs must have started since, which is either an age or a date
+func parseHistorySince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	age, err := parseJobAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither an age, such as 7d, nor a date, such as 2024-05-01", s)
+	}
+	return now.Add(-age), nil
+}
+
+func printJobHistory(resp azcopy.JobHistoryResponse) {
+	glcm.Exit(func(format common.OutputFormat) string {
+		if format == common.EOutputFormat.Json() {
+			jsonOutput, err := json.Marshal(resp.Jobs)
+			common.PanicIfErr(err)
+			return string(jsonOutput)
+		}
+
+		var sb strings.Builder
+		sb.WriteString("Job History\n")
+		for _, job := range resp.Jobs {
+			sb.WriteString(fmt.Sprintf("JobId: %s\nStart Time: %s\nDuration: %s\nStatus: %s\n"+
+				"Transfers: %d Completed, %d Failed, %d Skipped, %d Total\nBytes Transferred: %d\nCommand: %s\n\n",
+				job.JobID,
+				job.StartTime.Local().Format(time.RFC850),
+				time.Duration(job.DurationSeconds*float64(time.Second)).Round(time.Second),
+				job.Status,
+				job.TransfersCompleted, job.TransfersFailed, job.TransfersSkipped, job.TotalTransfers,
+				job.BytesTransferred,
+				job.Command))
+		}
+		return sb.String()
+	}, common.EExitCode.Success())
+}
+
+// recordJobHistory adds the job that has just finished to the job history
+func recordJobHistory(summary common.ListJobSummaryResponse, fromTo common.FromTo, startTime time.Time) {
+	endTime := time.Now()
+	err := jobsAdmin.RecordJobHistory(jobsAdmin.JobHistoryRecord{
+		JobID:              summary.JobID,
+		Command:            strings.TrimSpace(copyHandlerUtil{}.ConstructCommandStringFromArgs()),
+		Flags:              flagNames(os.Args[1:]),
+		FromTo:             fromTo.String(),
+		Status:             summary.JobStatus.String(),
+		StartTime:          startTime.UTC(),
+		EndTime:            endTime.UTC(),
+		DurationSeconds:    endTime.Sub(startTime).Seconds(),
+		TotalTransfers:     summary.TotalTransfers,
+		TransfersCompleted: summary.TransfersCompleted,
+		TransfersFailed:    summary.TransfersFailed,
+		TransfersSkipped:   summary.TransfersSkipped,
+		BytesTransferred:   summary.TotalBytesTransferred,
+		BytesExpected:      summary.TotalBytesExpected,
+	})
+	if err != nil {
+		glcm.Warn(fmt.Sprintf("Failed to add the job to the job history: %s", err))
+	}
+}
+
+// flagNames returns the names of the flags in the arguments, without their values, which could be long or sensitive
+func flagNames(args []string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -52,6 +52,13 @@ type resumeJobController struct {
 
 	// used to calculate job summary
 	jobStartTime time.Time
+
+	// set once we start shutting the job down cleanly, so that it exits with EExitCode.Interrupted (see drainJob)
+	interrupted bool
+
+	// where the job copies to, for making the hard links in its plan once it is done
+	fromTo      common.FromTo
+	destination string
 }
 
 // wraps call to lifecycle manager to wait for the job to complete
@@ -70,6 +77,7 @@ func (cca *resumeJobController) waitUntilJobCompletion(blocking bool) {
 	cca.jobStartTime = time.Now()
 	cca.intervalStartTime = time.Now()
 	cca.intervalBytesTransferred = 0
+	startLiveProgress(cca.jobID)
 
 	// hand over control to the lifecycle manager if blocking
 	if blocking {
@@ -146,9 +154,20 @@ func (cca *resumeJobController) ReportProgressOrExit(lcm common.LifecycleMgr) (t
 	})
 
 	if jobDone {
-		exitCode := common.EExitCode.Success()
-		if summary.TransfersFailed > 0 {
-			exitCode = common.EExitCode.Error()
+		recordJobHistory(summary, cca.fromTo, cca.jobStartTime)
+		finishTransferReport()
+
+		exitCode := jobExitCode(summary)
+		exitCode = interruptedExitCode(cca.jobID, summary.JobStatus, cca.interrupted, exitCode)
+
+		if cca.fromTo.To() == common.ELocation.Local() && summary.JobStatus != common.EJobStatus.Cancelled() && summary.JobStatus != common.EJobStatus.Cancelling() {
+			created, failed := createPlannedHardlinks(cca.jobID, cca.destination)
+			if created > 0 {
+				glcm.Info(fmt.Sprintf("Created %d hard links", created))
+			}
+			if failed > 0 {
+				exitCode = common.EExitCode.Error()
+			}
 		}
 
 		lcm.Exit(func(format common.OutputFormat) string {
@@ -224,17 +243,23 @@ func init() {
 		Run: func(cmd *cobra.Command, args []string) {
 			err := resumeCmdArgs.process()
 			if err != nil {
-				glcm.Error(fmt.Sprintf("failed to perform resume command due to error: %s", err.Error()))
+				glcm.ErrorWithExitCode(fmt.Sprintf("failed to perform resume command due to error: %s", err.Error()), errorExitCode(err))
 			}
 			glcm.Exit(nil, common.EExitCode.Success())
 		},
 	}
 
 	jobsCmd.AddCommand(resumeCmd)
-	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.includeTransfer, "include", "", "Filter: Include only these failed transfer(s) when resuming the job. "+
-		"Files should be separated by ';'.")
-	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.excludeTransfer, "exclude", "", "Filter: Exclude these failed transfer(s) when resuming the job. "+
-		"Files should be separated by ';'.")
+	resumeCmd.PersistentFlags().BoolVar(&resumeCmdArgs.failedOnly, "failed-only", false, "Retry only the transfers that failed, "+
+		"leaving those that were skipped, cancelled or not yet done for a later resume.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.includeTransfer, "include", "", "Filter: Retry only the transfers whose source matches these patterns when resuming the job. "+
+		"Patterns should be separated by ';', and match the name of the source, or its path relative to the job's source if they have a '/'. Wildcards (*, ?) are allowed.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.excludeTransfer, "exclude", "", "Filter: Don't retry the transfers whose source matches these patterns when resuming the job. "+
+		"Patterns should be separated by ';', as for --include.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.transferReport, TransferReportFlag, "", "Write a line about each transfer "+
+		"that is retried to this file as it finishes: its source and destination, status, size, start and end time, number of retries and, if it failed, why.")
+	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.transferReportFormat, TransferReportFormatFlag, ste.TransferReportFormatCSV,
+		"The format of the --"+TransferReportFlag+" file: csv (the default), or json for a JSON object per line.")
 	// oauth options
 	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.SourceSAS, "source-sas", "", "Source SAS token of the source for a given Job ID.")
 	resumeCmd.PersistentFlags().StringVar(&resumeCmdArgs.DestinationSAS, "destination-sas", "", "Destination SAS token of the destination for a given Job ID.")
@@ -242,9 +267,13 @@ func init() {
 
 type resumeCmdArgs struct {
 	jobID           string
+	failedOnly      bool
 	includeTransfer string
 	excludeTransfer string
 
+	transferReport       string
+	transferReportFormat string
+
 	SourceSAS      string
 	DestinationSAS string
 }
@@ -362,36 +391,19 @@ func (rca resumeCmdArgs) process() error {
 		common.LogPathFolder = ""
 	}
 
-	includeTransfer := make(map[string]int)
-	excludeTransfer := make(map[string]int)
-
-	// If the transfer has been provided with the include, parse the transfer list.
-	if len(rca.includeTransfer) > 0 {
-		// Split the Include Transfer using ';'
-		transfers := strings.Split(rca.includeTransfer, ";")
-		for index := range transfers {
-			if len(transfers[index]) == 0 {
-				// If the transfer provided is empty
-				// skip the transfer
-				// This is to handle the misplaced ';'
-				continue
-			}
-			includeTransfer[transfers[index]] = index
-		}
+	if err = startTransferReport(rca.transferReport, rca.transferReportFormat); err != nil {
+		return err
 	}
-	// If the transfer has been provided with the exclude, parse the transfer list.
-	if len(rca.excludeTransfer) > 0 {
-		// Split the Exclude Transfer using ';'
-		transfers := strings.Split(rca.excludeTransfer, ";")
-		for index := range transfers {
-			if len(transfers[index]) == 0 {
-				// If the transfer provided is empty
-				// skip the transfer
-				// This is to handle the misplaced ';'
-				continue
+
+	// the transfers to retry are given as patterns separated by ';', which may have misplaced ';'s
+	splitPatterns := func(list string) []string {
+		var patterns []string
+		for _, pattern := range strings.Split(list, ";") {
+			if pattern != "" {
+				patterns = append(patterns, pattern)
 			}
-			excludeTransfer[transfers[index]] = index
 		}
+		return patterns
 	}
 
 	// Get fromTo info, so we can decide what's the proper credential type to use.
@@ -407,6 +419,13 @@ func (rca resumeCmdArgs) process() error {
 		return errors.New("resuming benchmark jobs is not supported")
 	}
 
+	// a job whose scan was interrupted is carried on by copy, which picks the scan up where it left off
+	if state, err := readScanCheckpoint(jobID); err != nil {
+		return err
+	} else if state != nil && !jobsAdmin.JobCompletelyOrdered(jobID) {
+		return continueCopyScan(jobID, *state, rca.SourceSAS, rca.DestinationSAS)
+	}
+
 	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
 	// Initialize credential info.
 	credentialInfo := common.CredentialInfo{}
@@ -444,15 +463,16 @@ func (rca resumeCmdArgs) process() error {
 		SrcServiceClient: srcServiceClient,
 		DstServiceClient: dstServiceClient,
 		CredentialInfo:   credentialInfo,
-		IncludeTransfer:  includeTransfer,
-		ExcludeTransfer:  excludeTransfer,
+		FailedOnly:       rca.failedOnly,
+		IncludeTransfer:  splitPatterns(rca.includeTransfer),
+		ExcludeTransfer:  splitPatterns(rca.excludeTransfer),
 	})
 
 	if !resumeJobResponse.CancelledPauseResumed {
 		glcm.Error(resumeJobResponse.ErrorMsg)
 	}
 
-	controller := resumeJobController{jobID: jobID}
+	controller := resumeJobController{jobID: jobID, fromTo: getJobFromToResponse.FromTo, destination: getJobFromToResponse.Destination}
 	controller.waitUntilJobCompletion(true)
 
 	return nil
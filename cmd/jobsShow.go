@@ -24,7 +24,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Azure/azure-storage-azcopy/v10/azcopy"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"net/url"
 	"strings"
+	"time"
 
 	"encoding/json"
 
@@ -35,6 +38,7 @@ import (
 type ListReq struct {
 	JobID    common.JobID
 	OfStatus string
+	Live     bool
 }
 
 func init() {
@@ -58,7 +62,9 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			if commandLineInput.OfStatus == "" {
+			if commandLineInput.Live {
+				showLiveJob(commandLineInput)
+			} else if commandLineInput.OfStatus == "" {
 				resp, err := Client.GetJobSummary(azcopy.GetJobSummaryOptions{JobID: commandLineInput.JobID})
 				if err != nil {
 					glcm.Error(err.Error())
@@ -87,6 +93,64 @@ func init() {
 	// filters
 	shJob.PersistentFlags().StringVar(&commandLineInput.OfStatus, "with-status", "", "List only the transfers of job with the specified status. "+
 		"\n Available values include: All, Started, Success, Failed.")
+	shJob.PersistentFlags().BoolVar(&commandLineInput.Live, "live", false, "Ask the AzCopy process that is running the job, "+
+		"so that progress includes files in flight, with throughput, and keep showing it until the job is done.")
+}
+
+// showLiveJob asks the process that is running the job, rather than reading its plan files, and never returns.
+func showLiveJob(req ListReq) {
+	if req.OfStatus != "" {
+		var status common.TransferStatus
+		if err := status.Parse(req.OfStatus); err != nil {
+			glcm.Error(fmt.Sprintf("cannot parse the given Transfer Status %s", req.OfStatus))
+		}
+		var resp common.ListJobTransfersResponse
+		if err := queryLiveProgress(req.JobID, "/v1/transfers?status="+url.QueryEscape(status.String()), &resp); err != nil {
+			glcm.Error(err.Error())
+		}
+		PrintJobTransfers(resp)
+	}
+
+	for polled := false; ; polled = true {
+		var progress liveProgress
+		if err := queryLiveProgress(req.JobID, "/v1/progress", &progress); err != nil {
+			if !polled {
+				glcm.Error(err.Error())
+			}
+			// the process has gone since we last asked, so what it last wrote to the plan files is final
+			resp, err := Client.GetJobSummary(azcopy.GetJobSummaryOptions{JobID: req.JobID})
+			if err != nil {
+				glcm.Error(err.Error())
+			}
+			PrintJobProgressSummary(common.ListJobSummaryResponse(resp))
+		}
+		if progress.JobStatus.IsJobDone() {
+			PrintJobProgressSummary(progress.ListJobSummaryResponse)
+		}
+
+		glcm.Progress(func(format common.OutputFormat) string {
+			if format == common.EOutputFormat.Json() {
+				buf, err := json.Marshal(progress)
+				common.PanicIfErr(err)
+				return string(buf)
+			}
+			state := ""
+			if progress.Paused {
+				state = " (paused)"
+			} else if progress.Draining {
+				state = " (shutting down)"
+			} else if !progress.CompleteJobOrdered {
+				state = " (scanning...)"
+			}
+			return fmt.Sprintf("%.1f %%, %v Done, %v Failed, %v Pending, %v Skipped, %v Total%s, Throughput (Mb/s): %v",
+				progress.PercentComplete,
+				progress.TransfersCompleted,
+				progress.TransfersFailed,
+				progress.TotalTransfers-(progress.TransfersCompleted+progress.TransfersFailed+progress.TransfersSkipped),
+				progress.TransfersSkipped, progress.TotalTransfers, state, jobsAdmin.ToFixed(progress.ThroughputMbps, 4))
+		})
+		time.Sleep(2 * time.Second)
+	}
 }
 
 // PrintJobTransfers prints the response of listOrder command when list Order command requested the list of specific transfer of an existing job
@@ -23,9 +23,11 @@ package cmd
 import (
 	"context"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -50,6 +52,14 @@ type rawListCmdArgs struct {
 	RunningTally    bool
 	MegaUnits       bool
 	trailingDot     string
+	outputFormat    string
+
+	includeVersions  bool
+	includeSnapshots bool
+	resumeListing    bool
+
+	cpkInfo      bool
+	cpkScopeInfo string
 }
 
 type validProperty string
@@ -66,11 +76,28 @@ const (
 	LeaseDuration    validProperty = "LeaseDuration"
 	LeaseStatus      validProperty = "LeaseStatus"
 	ArchiveStatus    validProperty = "ArchiveStatus"
+	SnapshotId       validProperty = "SnapshotId"
+	Metadata         validProperty = "Metadata"
+	Tags             validProperty = "Tags"
+
+	// lists every property but VersionId and SnapshotId, which list the versions and snapshots of blobs
+	allProperties = "all"
 
 	versionIdTimeFormat    = "2006-01-02T15:04:05.9999999Z"
 	LastModifiedTimeFormat = "2006-01-02 15:04:05 +0000 GMT"
 )
 
+// the ways that list lays out what it lists
+const (
+	listOutputFormatFlat = "flat"
+	listOutputFormatTree = "tree"
+	listOutputFormatCSV  = "csv"
+)
+
+func validListOutputFormats() []string {
+	return []string{listOutputFormatFlat, listOutputFormatTree, listOutputFormatCSV}
+}
+
 // containsProperty checks if the property array contains a valid property
 func containsProperty(properties []validProperty, prop validProperty) bool {
 	for _, item := range properties {
@@ -84,7 +111,8 @@ func containsProperty(properties []validProperty, prop validProperty) bool {
 // validProperties returns an array of possible values for the validProperty const type.
 func validProperties() []validProperty {
 	return []validProperty{LastModifiedTime, VersionId, BlobType, BlobAccessTier,
-		ContentType, ContentEncoding, ContentMD5, LeaseState, LeaseDuration, LeaseStatus, ArchiveStatus}
+		ContentType, ContentEncoding, ContentMD5, LeaseState, LeaseDuration, LeaseStatus, ArchiveStatus,
+		SnapshotId, Metadata, Tags}
 }
 
 // validPropertiesString returns an array of valid properties in string array.
@@ -101,6 +129,14 @@ func (raw rawListCmdArgs) parseProperties() []validProperty {
 	if raw.Properties != "" {
 		listProperties := strings.Split(raw.Properties, ";")
 		for _, p := range listProperties {
+			if strings.EqualFold(p, allProperties) {
+				for _, vp := range validProperties() {
+					if vp != VersionId && vp != SnapshotId && !containsProperty(parsedProperties, vp) {
+						parsedProperties = append(parsedProperties, vp)
+					}
+				}
+				continue
+			}
 			for _, vp := range validProperties() {
 				// check for empty string and also ignore the case
 				if len(p) != 0 && strings.EqualFold(string(vp), p) {
@@ -146,6 +182,43 @@ func (raw rawListCmdArgs) cook() (cookedListCmdArgs, error) {
 		return cooked, err
 	}
 	cooked.properties = raw.parseProperties()
+	if (raw.includeVersions || raw.includeSnapshots) && cooked.location != common.ELocation.Blob() {
+		return cooked, errors.New("versions and snapshots can only be listed for Blob storage (blob.core.windows.net)")
+	}
+	// versions and snapshots are listed with the IDs that tell them apart
+	if raw.includeVersions && !containsProperty(cooked.properties, VersionId) {
+		cooked.properties = append(cooked.properties, VersionId)
+	}
+	if raw.includeSnapshots && !containsProperty(cooked.properties, SnapshotId) {
+		cooked.properties = append(cooked.properties, SnapshotId)
+	}
+	if raw.resumeListing && cooked.location != common.ELocation.Blob() && cooked.location != common.ELocation.BlobFS() {
+		return cooked, errors.New("only listings of Blob storage (blob.core.windows.net or dfs.core.windows.net) can be resumed")
+	}
+	cooked.resumeListing = raw.resumeListing
+
+	switch cooked.outputFormat = strings.ToLower(raw.outputFormat); cooked.outputFormat {
+	case "", listOutputFormatFlat, listOutputFormatCSV:
+	case listOutputFormatTree:
+		if len(cooked.properties) > 0 {
+			return cooked, errors.New("properties are only listed with --output-format flat, since the tree shows only sizes")
+		}
+	default:
+		return cooked, fmt.Errorf("'%s' is not a list output format. Use one of: %s", raw.outputFormat, strings.Join(validListOutputFormats(), ", "))
+	}
+
+	if raw.cpkScopeInfo != "" && raw.cpkInfo {
+		return cooked, errors.New("cannot use both cpk-by-name and cpk-by-value at the same time")
+	}
+	if (raw.cpkScopeInfo != "" || raw.cpkInfo) && cooked.location != common.ELocation.Blob() {
+		return cooked, errors.New("client provided keys (CPK) are only supported when listing blob endpoints (blob.core.windows.net)")
+	}
+	// what is listed is read with the key, so it is the key the blobs are encrypted with
+	cooked.cpkOptions = common.CpkOptions{
+		CpkScopeInfo:      raw.cpkScopeInfo,
+		CpkInfo:           raw.cpkInfo,
+		IsSourceEncrypted: raw.cpkScopeInfo != "" || raw.cpkInfo,
+	}
 
 	return cooked, nil
 }
@@ -159,6 +232,9 @@ type cookedListCmdArgs struct {
 	RunningTally    bool
 	MegaUnits       bool
 	trailingDot     common.TrailingDotOption
+	cpkOptions      common.CpkOptions
+	outputFormat    string
+	resumeListing   bool
 }
 
 var raw rawListCmdArgs
@@ -204,8 +280,18 @@ func init() {
 	listContainerCmd.PersistentFlags().BoolVar(&raw.MachineReadable, "machine-readable", false, "False by default. Lists file sizes in bytes.")
 	listContainerCmd.PersistentFlags().BoolVar(&raw.RunningTally, "running-tally", false, "False by default. Counts the total number of files and their sizes.")
 	listContainerCmd.PersistentFlags().BoolVar(&raw.MegaUnits, "mega-units", false, "False by default. Displays units in orders of 1000, not 1024.")
+	listContainerCmd.PersistentFlags().StringVar(&raw.outputFormat, "output-format", listOutputFormatFlat, "How to lay out the list. "+
+		"\n Available options: "+strings.Join(validListOutputFormats(), ", ")+". "+
+		"\n 'flat' lists each path on a line; 'tree' shows the (virtual) directory hierarchy, with the size and file count of each directory; "+
+		"\n 'csv' lists each path on a line of comma-separated values, with the properties in the order given, after a header line.")
+	listContainerCmd.PersistentFlags().BoolVar(&raw.includeVersions, "include-versions", false, "False by default. List the previous versions of blobs too, with their version IDs.")
+	listContainerCmd.PersistentFlags().BoolVar(&raw.includeSnapshots, "include-snapshots", false, "False by default. List the snapshots of blobs too, with their snapshot IDs.")
+	listContainerCmd.PersistentFlags().BoolVar(&raw.resumeListing, "resume-listing", false, "False by default. Record how far the listing has got, "+
+		"\n and if a listing of the same container (or virtual directory) was interrupted, carry on from where it stopped instead of starting over. "+
+		"\n The listing is done serially, and the page of blobs being listed when it was interrupted is listed again. "+
+		"\n The running tally, and the tree, only count what is listed this time.")
 	listContainerCmd.PersistentFlags().StringVar(&raw.Properties, "properties", "", "Properties to be displayed in list output. "+
-		"\n Possible properties include: "+strings.Join(validPropertiesString(), ", ")+". "+
+		"\n Possible properties include: "+strings.Join(validPropertiesString(), ", ")+", and 'all' for every property but VersionId and SnapshotId. "+
 		"\n Delimiter (;) should be used to separate multiple values of properties (i.e. 'LastModifiedTime;VersionId;BlobType').")
 	listContainerCmd.PersistentFlags().StringVar(&raw.trailingDot, "trailing-dot", "", "'Enable' by default to treat file share related operations in a safe manner. "+
 		"\n Available options: "+strings.Join(common.ValidTrailingDotOptions(), ", ")+". "+
@@ -214,6 +300,13 @@ func init() {
 		"\n If this flag is set to 'Disable' and AzCopy encounters a trailing dot file, it will warn customers in the scanning log but will not attempt to abort the operation."+
 		"\n If the destination does not support trailing dot files (Windows or Blob Storage), "+
 		"\n AzCopy will fail if the trailing dot file is the root of the transfer and skip any trailing dot paths encountered during enumeration.")
+	listContainerCmd.PersistentFlags().StringVar(&raw.cpkScopeInfo, "cpk-by-name", "", "Client provided key by name let clients making requests against Azure Blob storage "+
+		"\n an option to provide an encryption key on a per-request basis. "+
+		"\n Provided key name will be used to read the properties of blobs encrypted with it.")
+	listContainerCmd.PersistentFlags().BoolVar(&raw.cpkInfo, "cpk-by-value", false, "False by default. "+
+		"\n Client provided key by value let clients making requests against "+
+		"\n Azure Blob storage an option to provide an encryption key on a per-request basis. "+
+		"\n Provided key and its hash will be fetched from environment variables (CPK_ENCRYPTION_KEY and CPK_ENCRYPTION_KEY_SHA256 must be set).")
 
 	rootCmd.AddCommand(listContainerCmd)
 }
@@ -239,7 +332,7 @@ func (cooked cookedListCmdArgs) handleListContainerCommand() (err error) {
 	}
 
 	// isSource is rather misnomer for canBePublic. We can list public containers, and hence isSource=true
-	if credentialInfo, _, err = GetCredentialInfoForLocation(ctx, cooked.location, source, true, common.CpkOptions{}); err != nil {
+	if credentialInfo, _, err = GetCredentialInfoForLocation(ctx, cooked.location, source, true, cooked.cpkOptions); err != nil {
 		return fmt.Errorf("failed to obtain credential info: %s", err.Error())
 	} else if credentialInfo.CredentialType.IsAzureOAuth() {
 		uotm := GetUserOAuthTokenManagerInstance()
@@ -253,6 +346,14 @@ func (cooked cookedListCmdArgs) handleListContainerCommand() (err error) {
 	// check if user wants to get version id
 	getVersionId := containsProperty(cooked.properties, VersionId)
 
+	var checkpoint *listingCheckpoint
+	if cooked.resumeListing {
+		if level == ELocationLevel.Service() {
+			return errors.New("only listings of a container, or a virtual directory within it, can be resumed")
+		}
+		checkpoint = newListingCheckpoint(source)
+	}
+
 	traverser, err := InitResourceTraverser(source, cooked.location, ctx, InitResourceTraverserOptions{
 		Credential: &credentialInfo,
 
@@ -262,7 +363,12 @@ func (cooked cookedListCmdArgs) handleListContainerCommand() (err error) {
 		GetPropertiesInFrontend: true,
 
 		ListVersions:     getVersionId,
+		ListSnapshots:    containsProperty(cooked.properties, SnapshotId),
+		PreserveBlobTags: containsProperty(cooked.properties, Tags),
 		HardlinkHandling: common.EHardlinkHandlingType.Follow(),
+		CpkOptions:       cooked.cpkOptions,
+
+		ListingCheckpoint: checkpoint,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize traverser: %s", err.Error())
@@ -277,13 +383,35 @@ func (cooked cookedListCmdArgs) handleListContainerCommand() (err error) {
 	}
 	objectVer := make(map[string]versionIdObject)
 
+	var tree *listTreeNode
+	switch cooked.outputFormat {
+	case listOutputFormatTree:
+		tree = newListTree()
+	case listOutputFormatCSV:
+		header := []string{"Path"}
+		for _, property := range cooked.properties {
+			header = append(header, string(property))
+		}
+		header = append(header, "ContentLength")
+		glcm.Output(func(format common.OutputFormat) string {
+			return toCSVLine(header)
+		}, common.EOutputMessageType.ListObject())
+	}
+
 	processor := func(object StoredObject) error {
+		if tree != nil {
+			tree.add(getPath(object.ContainerName, object.relativePath, level, object.entityType), object.size)
+			return nil
+		}
+
 		lo := cooked.newListObject(object, level)
 		glcm.Output(func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
 				jsonOutput, err := json.Marshal(lo)
 				common.PanicIfErr(err)
 				return string(jsonOutput)
+			} else if cooked.outputFormat == listOutputFormatCSV {
+				return toCSVLine(lo.csvRecord)
 			} else {
 				return lo.String()
 			}
@@ -333,6 +461,18 @@ func (cooked cookedListCmdArgs) handleListContainerCommand() (err error) {
 		return fmt.Errorf("failed to traverse container: %s", err.Error())
 	}
 
+	if tree != nil {
+		glcm.Output(func(format common.OutputFormat) string {
+			if format == common.EOutputFormat.Json() {
+				jsonOutput, err := json.Marshal(tree)
+				common.PanicIfErr(err)
+				return string(jsonOutput)
+			}
+			return tree.render(cooked.MachineReadable)
+		}, common.EOutputMessageType.ListObject())
+		fileCount, sizeCount = tree.Files, tree.Size
+	}
+
 	if cooked.RunningTally {
 		ls := cooked.newListSummary(fileCount, sizeCount)
 		glcm.Output(func(format common.OutputFormat) string {
@@ -363,10 +503,14 @@ type AzCopyListObject struct {
 	LeaseStatus      lease.StatusType   `json:"LeaseStatus,omitempty"`
 	LeaseDuration    lease.DurationType `json:"LeaseDuration,omitempty"`
 	ArchiveStatus    blob.ArchiveStatus `json:"ArchiveStatus,omitempty"`
+	SnapshotId       string             `json:"SnapshotId,omitempty"`
+	Metadata         common.Metadata    `json:"Metadata,omitempty"`
+	Tags             common.BlobTags    `json:"Tags,omitempty"`
 
 	ContentLength string `json:"ContentLength"` // This is a string to support machine-readable
 
 	StringEncoding string `json:"-"` // this is stored as part of the list object to avoid looping over the properties array twice
+	csvRecord      []string
 }
 
 func (l AzCopyListObject) String() string {
@@ -386,49 +530,84 @@ func (cooked cookedListCmdArgs) newListObject(object StoredObject, level Locatio
 	builder.WriteString(lo.Path + "; ")
 
 	for _, property := range cooked.properties {
-		propertyStr := string(property)
+		var value string
 		switch property {
 		case LastModifiedTime:
 			lo.LastModifiedTime = to.Ptr(object.lastModifiedTime)
-			builder.WriteString(propertyStr + ": " + lo.LastModifiedTime.String() + "; ")
+			value = lo.LastModifiedTime.String()
 		case VersionId:
 			lo.VersionId = object.blobVersionID
-			builder.WriteString(propertyStr + ": " + lo.VersionId + "; ")
+			value = lo.VersionId
 		case BlobType:
 			lo.BlobType = object.blobType
-			builder.WriteString(propertyStr + ": " + string(lo.BlobType) + "; ")
+			value = string(lo.BlobType)
 		case BlobAccessTier:
 			lo.BlobAccessTier = object.blobAccessTier
-			builder.WriteString(propertyStr + ": " + string(lo.BlobAccessTier) + "; ")
+			value = string(lo.BlobAccessTier)
 		case ContentType:
 			lo.ContentType = object.contentType
-			builder.WriteString(propertyStr + ": " + lo.ContentType + "; ")
+			value = lo.ContentType
 		case ContentEncoding:
 			lo.ContentEncoding = object.contentEncoding
-			builder.WriteString(propertyStr + ": " + lo.ContentEncoding + "; ")
+			value = lo.ContentEncoding
 		case ContentMD5:
 			lo.ContentMD5 = object.md5
-			builder.WriteString(propertyStr + ": " + base64.StdEncoding.EncodeToString(lo.ContentMD5) + "; ")
+			value = base64.StdEncoding.EncodeToString(lo.ContentMD5)
 		case LeaseState:
 			lo.LeaseState = object.leaseState
-			builder.WriteString(propertyStr + ": " + string(lo.LeaseState) + "; ")
+			value = string(lo.LeaseState)
 		case LeaseStatus:
 			lo.LeaseStatus = object.leaseStatus
-			builder.WriteString(propertyStr + ": " + string(lo.LeaseStatus) + "; ")
+			value = string(lo.LeaseStatus)
 		case LeaseDuration:
 			lo.LeaseDuration = object.leaseDuration
-			builder.WriteString(propertyStr + ": " + string(lo.LeaseDuration) + "; ")
+			value = string(lo.LeaseDuration)
 		case ArchiveStatus:
 			lo.ArchiveStatus = object.archiveStatus
-			builder.WriteString(propertyStr + ": " + string(lo.ArchiveStatus) + "; ")
+			value = string(lo.ArchiveStatus)
+		case SnapshotId:
+			lo.SnapshotId = object.blobSnapshotID
+			value = lo.SnapshotId
+		case Metadata:
+			lo.Metadata = object.Metadata
+			value = metadataToString(object.Metadata)
+		case Tags:
+			lo.Tags = object.blobTags
+			value = object.blobTags.ToString()
 		}
+		builder.WriteString(string(property) + ": " + value + "; ")
+		lo.csvRecord = append(lo.csvRecord, value)
 	}
 	builder.WriteString("Content Length: " + lo.ContentLength)
 	lo.StringEncoding = builder.String()
+	lo.csvRecord = append(append([]string{lo.Path}, lo.csvRecord...), lo.ContentLength)
 
 	return lo
 }
 
+// metadataToString formats metadata as --metadata takes it, in order of key
+func metadataToString(metadata common.Metadata) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+common.IffNotNil(metadata[k], ""))
+	}
+	return strings.Join(pairs, ";")
+}
+
+// toCSVLine quotes fields as RFC 4180 does, and joins them into a line, without the line feed
+func toCSVLine(fields []string) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	_ = w.Write(fields) // a strings.Builder doesn't fail
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
 type AzCopyListSummary struct {
 	FileCount     string `json:"FileCount"`
 	TotalFileSize string `json:"TotalFileSize"`
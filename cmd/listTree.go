@@ -0,0 +1,106 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// listTreeNode is a file, or a (virtual) directory with the size and number of the files beneath it
+type listTreeNode struct {
+	Name      string
+	Directory bool            `json:",omitempty"`
+	Size      int64           // of the file, or of all the files beneath the directory
+	Files     int64           `json:",omitempty"` // beneath the directory
+	Children  []*listTreeNode `json:",omitempty"`
+
+	children map[string]*listTreeNode
+}
+
+// newListTree makes the root of a tree, which is the location being listed
+func newListTree() *listTreeNode {
+	return &listTreeNode{Name: ".", Directory: true, children: make(map[string]*listTreeNode)}
+}
+
+// add puts the object at path, which is a folder if it ends in a separator, into the tree, creating the directories
+// above it, and adding its size to them
+func (n *listTreeNode) add(path string, size int64) {
+	isFolder := strings.HasSuffix(path, common.AZCOPY_PATH_SEPARATOR_STRING)
+	segments := strings.Split(strings.Trim(path, common.AZCOPY_PATH_SEPARATOR_STRING), common.AZCOPY_PATH_SEPARATOR_STRING)
+	if segments[0] == "" {
+		// the root itself
+		return
+	}
+
+	node := n
+	for i, name := range segments {
+		if !isFolder {
+			node.Size += size
+			node.Files++
+		}
+		child, ok := node.children[name]
+		if !ok {
+			child = &listTreeNode{Name: name}
+			node.children[name] = child
+			node.Children = append(node.Children, child)
+		}
+		if i < len(segments)-1 || isFolder {
+			if !child.Directory {
+				child.Directory = true
+				child.children = make(map[string]*listTreeNode)
+			}
+		} else {
+			child.Size = size
+		}
+		node = child
+	}
+}
+
+// render draws the tree, as the tree command does, with the size of each file and the size and file count of each
+// directory
+func (n *listTreeNode) render(machineReadable bool) string {
+	var sb strings.Builder
+	sb.WriteString(n.label(machineReadable))
+	n.renderChildren(&sb, "", machineReadable)
+	return sb.String()
+}
+
+func (n *listTreeNode) renderChildren(sb *strings.Builder, indent string, machineReadable bool) {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for i, child := range n.Children {
+		last := i == len(n.Children)-1
+		sb.WriteString("\n" + indent + common.Iff(last, "└── ", "├── ") + child.label(machineReadable))
+		if child.Directory {
+			child.renderChildren(sb, indent+common.Iff(last, "    ", "│   "), machineReadable)
+		}
+	}
+}
+
+func (n *listTreeNode) label(machineReadable bool) string {
+	if n.Directory {
+		return fmt.Sprintf("%s/ (%d files, %s)", n.Name, n.Files, sizeToString(n.Size, machineReadable))
+	}
+	return fmt.Sprintf("%s (%s)", n.Name, sizeToString(n.Size, machineReadable))
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -135,3 +136,52 @@ func TestSizeToString(t *testing.T) {
 		a.Equal(v.expectedOutput, output)
 	}
 }
+
+func TestListTree(t *testing.T) {
+	a := assert.New(t)
+	tree := newListTree()
+	tree.add("", 0) // the root folder
+	tree.add("dir/", 0)
+	tree.add("dir/b.txt", 2)
+	tree.add("dir/a.txt", 1)
+	tree.add("dir/sub/c.txt", 4)
+	tree.add("empty/", 0)
+	tree.add("top.txt", 8)
+
+	a.Equal(int64(4), tree.Files)
+	a.Equal(int64(15), tree.Size)
+	a.Equal(`./ (4 files, 15)
+├── dir/ (3 files, 7)
+│   ├── a.txt (1)
+│   ├── b.txt (2)
+│   └── sub/ (1 files, 4)
+│       └── c.txt (4)
+├── empty/ (0 files, 0)
+└── top.txt (8)`, tree.render(true))
+}
+
+func TestListAllProperties(t *testing.T) {
+	a := assert.New(t)
+	properties := rawListCmdArgs{Properties: "ContentType;all"}.parseProperties()
+	a.Equal(ContentType, properties[0])
+	a.Len(properties, len(validProperties())-2)
+	a.False(containsProperty(properties, VersionId))
+	a.False(containsProperty(properties, SnapshotId))
+	a.True(containsProperty(properties, Metadata))
+}
+
+func TestListObjectCSV(t *testing.T) {
+	a := assert.New(t)
+	lister := cookedListCmdArgs{MachineReadable: true, properties: []validProperty{ContentType, Metadata, Tags}}
+	lo := lister.newListObject(StoredObject{
+		relativePath: "dir/a, b.txt",
+		size:         5,
+		contentType:  "text/plain",
+		Metadata:     common.Metadata{"b": to.Ptr("2"), "a": to.Ptr("1")},
+		blobTags:     common.BlobTags{"project": "x"},
+	}, ELocationLevel.Container())
+
+	a.Equal([]string{"dir/a, b.txt", "text/plain", "a=1;b=2", "project=x", "5"}, lo.csvRecord)
+	a.Equal(`"dir/a, b.txt",text/plain,a=1;b=2,project=x,5`, toCSVLine(lo.csvRecord))
+	a.Equal("dir/a, b.txt; ContentType: text/plain; Metadata: a=1;b=2; Tags: project=x; Content Length: 5", lo.String())
+}
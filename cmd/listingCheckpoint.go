@@ -0,0 +1,96 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// listingCheckpoint records how far a listing of a container has got, as the marker that continues it, so that a
+// listing that was interrupted can carry on from there instead of starting over. Since the marker is saved once each
+// page has been processed, the page that was being processed when the listing was interrupted is listed again.
+type listingCheckpoint struct {
+	path string
+}
+
+// the content of the checkpoint file
+type listingCheckpointState struct {
+	// what was being listed, since a marker only continues the listing it came from
+	Listing string
+	Marker  string
+}
+
+// newListingCheckpoint returns the checkpoint of listings of resource, which is kept beside the job plan files
+func newListingCheckpoint(resource common.ResourceString) *listingCheckpoint {
+	// the SAS isn't part of the name, since it may be renewed between one attempt and the next
+	sum := sha256.Sum256([]byte(resource.Value + "?" + resource.ExtraQuery))
+	return &listingCheckpoint{path: filepath.Join(common.AzcopyJobPlanFolder, "listings", hex.EncodeToString(sum[:])+".json")}
+}
+
+// marker returns the marker to continue the listing from, or nil if it should start at the beginning
+func (c *listingCheckpoint) marker(listing string) (*string, error) {
+	content, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var state listingCheckpointState
+	if err = json.Unmarshal(content, &state); err != nil || state.Listing != listing || state.Marker == "" {
+		// a checkpoint of some other listing (or a broken one) is no use, so the listing starts over
+		return nil, nil
+	}
+	return &state.Marker, nil
+}
+
+// save records that the listing has got as far as marker. A nil marker means that it has finished.
+func (c *listingCheckpoint) save(listing string, marker *string) error {
+	if marker == nil || *marker == "" {
+		return c.clear()
+	}
+	content, err := json.Marshal(listingCheckpointState{Listing: listing, Marker: *marker})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return err
+	}
+	// written aside and renamed, so that an interruption can't leave half a checkpoint
+	temp := c.path + ".tmp"
+	if err = os.WriteFile(temp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.path)
+}
+
+func (c *listingCheckpoint) clear() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
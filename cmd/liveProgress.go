@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+// liveProgressSocket is where a running job answers progress queries. It sits next to the job's plan files,
+// so that 'azcopy jobs show --live' can find it from the job ID alone.
+func liveProgressSocket(jobID common.JobID) string {
+	return filepath.Join(common.AzcopyJobPlanFolder, jobID.String()+".sock")
+}
+
+// liveProgress is the answer to GET /v1/progress: the job summary, as 'azcopy jobs show' gives it, and what only the running process knows.
+type liveProgress struct {
+	common.ListJobSummaryResponse
+	ElapsedSeconds        float64
+	ThroughputMbps        float64 // since the previous query, or over the last second if that was more recent
+	AverageThroughputMbps float64
+	Paused                bool
+	Draining              bool
+}
+
+// liveProgressServer answers progress queries for one job, over HTTP:
+//
+//	GET /v1/progress                 the job's progress and throughput
+//	GET /v1/transfers?status=Failed  the job's transfers and their states; all of them if no status is given
+type liveProgressServer struct {
+	jobID   common.JobID
+	started time.Time
+
+	mu           sync.Mutex
+	sampledAt    time.Time
+	sampledBytes uint64
+	throughput   float64
+
+	// summary and transfers are swapped out in tests
+	summary   func(common.JobID) common.ListJobSummaryResponse
+	transfers func(common.ListJobTransfersRequest) common.ListJobTransfersResponse
+}
+
+func newLiveProgressServer(jobID common.JobID) *liveProgressServer {
+	now := time.Now()
+	return &liveProgressServer{
+		jobID:     jobID,
+		started:   now,
+		sampledAt: now,
+		summary:   jobsAdmin.GetJobSummary,
+		transfers: jobsAdmin.ListJobTransfers,
+	}
+}
+
+func (s *liveProgressServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/progress", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIResponse(w, http.StatusOK, s.progress())
+	})
+	mux.HandleFunc("GET /v1/transfers", func(w http.ResponseWriter, r *http.Request) {
+		status := common.ETransferStatus.All()
+		if q := r.URL.Query().Get("status"); q != "" {
+			if err := status.Parse(q); err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid transfer status %q", q))
+				return
+			}
+		}
+		writeAPIResponse(w, http.StatusOK, s.transfers(common.ListJobTransfersRequest{JobID: s.jobID, OfStatus: status}))
+	})
+	return mux
+}
+
+func (s *liveProgressServer) progress() liveProgress {
+	summary := s.summary(s.jobID)
+	now := time.Now()
+	toMbps := func(bytes uint64, elapsed time.Duration) float64 {
+		return common.Iff(elapsed > 0, float64(bytes)*8/base10Mega/elapsed.Seconds(), 0)
+	}
+
+	s.mu.Lock()
+	if elapsed := now.Sub(s.sampledAt); elapsed >= time.Second && summary.BytesOverWire >= s.sampledBytes {
+		s.throughput = toMbps(summary.BytesOverWire-s.sampledBytes, elapsed)
+		s.sampledAt, s.sampledBytes = now, summary.BytesOverWire
+	}
+	throughput := s.throughput
+	s.mu.Unlock()
+
+	paused, draining := jobTransferState(s.jobID)
+	return liveProgress{
+		ListJobSummaryResponse: summary,
+		ElapsedSeconds:         now.Sub(s.started).Seconds(),
+		ThroughputMbps:         throughput,
+		AverageThroughputMbps:  toMbps(summary.BytesOverWire, now.Sub(s.started)),
+		Paused:                 paused,
+		Draining:               draining,
+	}
+}
+
+var liveProgressState struct {
+	sync.Mutex
+	server     *http.Server
+	socket     string
+	registered bool
+}
+
+// startLiveProgress opens the progress socket for jobID, in place of any earlier job's, since a process runs
+// one job at a time. A job still runs if the socket can't be opened; it just can't be watched.
+func startLiveProgress(jobID common.JobID) {
+	if common.AzcopyJobPlanFolder == "" {
+		return
+	}
+	socket := liveProgressSocket(jobID)
+	_ = os.Remove(socket)
+	// the transfers it lists are only the business of the user running the job, so the socket is created
+	// inaccessible to anyone else, rather than chmod'ed afterwards, which would leave a moment to connect in
+	oldMask := setUmask(0077)
+	listener, err := net.Listen("unix", socket)
+	setUmask(oldMask)
+	if err != nil {
+		common.LogToJobLogWithPrefix("Progress queries can't be answered, since the progress socket can't be opened: "+err.Error(), common.LogWarning)
+		return
+	}
+
+	server := &http.Server{Handler: newLiveProgressServer(jobID).handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	liveProgressState.Lock()
+	defer liveProgressState.Unlock()
+	stopLiveProgressLocked()
+	liveProgressState.server, liveProgressState.socket = server, socket
+	if !liveProgressState.registered {
+		liveProgressState.registered = true
+		glcm.RegisterCloseFunc(func() {
+			liveProgressState.Lock()
+			defer liveProgressState.Unlock()
+			stopLiveProgressLocked()
+		})
+	}
+}
+
+func stopLiveProgressLocked() {
+	if liveProgressState.server != nil {
+		_ = liveProgressState.server.Close()
+		_ = os.Remove(liveProgressState.socket)
+		liveProgressState.server = nil
+	}
+}
+
+var errJobNotLive = errors.New("the job isn't running, or is being run by a version of AzCopy that doesn't answer progress queries")
+
+// queryLiveProgress asks the process running jobID for path, and decodes the answer into result.
+func queryLiveProgress(jobID common.JobID, path string, result any) error {
+	socket := liveProgressSocket(jobID)
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}},
+	}
+	resp, err := client.Get("http://azcopy" + path)
+	if err != nil {
+		return errJobNotLive
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure daemonResponse
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("progress query failed: %s", failure.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
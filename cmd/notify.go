@@ -0,0 +1,308 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// how long we wait for the webhook, or for the mail server, before giving up on notifying it
+const notificationTimeout = 30 * time.Second
+
+// jobNotification is what --notify-webhook and --notify-email are sent when a job finishes, or the command fails
+type jobNotification struct {
+	JobID              common.JobID
+	Command            string
+	Source             string
+	Destination        string
+	Host               string
+	Status             string
+	ExitCode           common.ExitCode
+	Error              string `json:",omitempty"`
+	TotalTransfers     uint32
+	TransfersCompleted uint32
+	TransfersFailed    uint32
+	TransfersSkipped   uint32
+	BytesTransferred   uint64
+	StartTime          time.Time `json:",omitzero"`
+	EndTime            time.Time
+	DurationSeconds    float64
+}
+
+func (n jobNotification) subject() string {
+	return fmt.Sprintf("AzCopy %s on %s: %s", n.Command, n.Host, n.Status)
+}
+
+// text is the body of the email
+func (n jobNotification) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job %s (azcopy %s) on %s finished with status %s, exit code %d.\r\n\r\n", n.JobID, n.Command, n.Host, n.Status, n.ExitCode)
+	if n.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\r\n\r\n", n.Error)
+	}
+	fmt.Fprintf(&b, "Source: %s\r\nDestination: %s\r\n", n.Source, n.Destination)
+	fmt.Fprintf(&b, "Transfers: %d total, %d completed, %d failed, %d skipped\r\n",
+		n.TotalTransfers, n.TransfersCompleted, n.TransfersFailed, n.TransfersSkipped)
+	fmt.Fprintf(&b, "Bytes transferred: %d\r\n", n.BytesTransferred)
+	if !n.StartTime.IsZero() {
+		fmt.Fprintf(&b, "Started: %s\r\n", n.StartTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "Finished: %s\r\nDuration: %s\r\n", n.EndTime.Format(time.RFC3339),
+		time.Duration(n.DurationSeconds*float64(time.Second)).Round(time.Second))
+	return b.String()
+}
+
+type smtpSettings struct {
+	server   string
+	username string
+	password string
+	from     string
+}
+
+// jobNotifier sends the notifications of a command's jobs
+type jobNotifier struct {
+	command     string
+	source      string
+	destination string
+	webhook     string
+	emailTo     []string
+	smtp        smtpSettings
+}
+
+// notifier is nil unless --notify-webhook or --notify-email was given
+var notifier *jobNotifier
+
+// startNotifications has the jobs of command, from source to destination, notified to webhook and emailed to the
+// addresses in emailTo (separated by commas), when they finish
+func startNotifications(command, source, destination, webhook, emailTo string) error {
+	if webhook == "" && emailTo == "" {
+		return nil
+	}
+	n := &jobNotifier{command: command, source: source, destination: destination, webhook: webhook}
+
+	if webhook != "" {
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("--%s must be an http or https URL", NotifyWebhookFlag)
+		}
+	}
+
+	if emailTo != "" {
+		addresses, err := mail.ParseAddressList(emailTo)
+		if err != nil {
+			return fmt.Errorf("--%s must be a list of email addresses, separated by commas: %w", NotifyEmailFlag, err)
+		}
+		for _, a := range addresses {
+			n.emailTo = append(n.emailTo, a.Address)
+		}
+
+		n.smtp = smtpSettings{
+			server:   common.GetEnvironmentVariable(common.EEnvironmentVariable.SMTPServer()),
+			username: common.GetEnvironmentVariable(common.EEnvironmentVariable.SMTPUsername()),
+			password: common.GetEnvironmentVariable(common.EEnvironmentVariable.SMTPPassword()),
+			from:     common.GetEnvironmentVariable(common.EEnvironmentVariable.SMTPFrom()),
+		}
+		if _, _, err := net.SplitHostPort(n.smtp.server); err != nil {
+			return fmt.Errorf("--%s needs the mail server, as host:port, in %s", NotifyEmailFlag, common.EEnvironmentVariable.SMTPServer().Name)
+		}
+		if n.smtp.from == "" {
+			n.smtp.from = "azcopy@" + hostName()
+		} else if from, err := mail.ParseAddress(n.smtp.from); err != nil {
+			return fmt.Errorf("%s isn't an email address: %w", common.EEnvironmentVariable.SMTPFrom().Name, err)
+		} else {
+			n.smtp.from = from.Address
+		}
+	}
+
+	notifier = n
+	return nil
+}
+
+func hostName() string {
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "localhost"
+}
+
+// notifyJobDone runs the post hook, and sends the notifications, of the job with summary, which has finished with
+// exitCode
+func notifyJobDone(summary common.ListJobSummaryResponse, startTime time.Time, exitCode common.ExitCode) {
+	endTime := time.Now()
+	n := jobNotification{
+		JobID:              summary.JobID,
+		Status:             summary.JobStatus.String(),
+		ExitCode:           exitCode,
+		TotalTransfers:     summary.TotalTransfers,
+		TransfersCompleted: summary.TransfersCompleted,
+		TransfersFailed:    summary.TransfersFailed,
+		TransfersSkipped:   summary.TransfersSkipped,
+		BytesTransferred:   summary.TotalBytesTransferred,
+		EndTime:            endTime.UTC(),
+	}
+	if !startTime.IsZero() { // it is zero for a sync that had nothing to do
+		n.StartTime = startTime.UTC()
+		n.DurationSeconds = endTime.Sub(startTime).Seconds()
+	}
+	notifyOutcome(n)
+}
+
+// notifyCommandFailed is notifyJobDone, for a command that failed with err before its job could finish
+func notifyCommandFailed(err error, exitCode common.ExitCode) {
+	notifyOutcome(jobNotification{
+		JobID:    Client.CurrentJobID,
+		Status:   common.EJobStatus.Failed().String(),
+		ExitCode: exitCode,
+		Error:    err.Error(),
+		EndTime:  time.Now().UTC(),
+	})
+}
+
+func notifyOutcome(n jobNotification) {
+	// the hook goes first, since it may well be what makes the job's outcome final, e.g. by releasing a snapshot
+	if hooks != nil {
+		hooks.runPost(n)
+	}
+	if notifier != nil {
+		notifier.send(n)
+	}
+}
+
+// send sends n to the webhook and the email addresses. A notification that can't be sent doesn't fail the job.
+func (j *jobNotifier) send(n jobNotification) {
+	n.Command, n.Source, n.Destination, n.Host = j.command, j.source, j.destination, hostName()
+
+	if j.webhook != "" {
+		if err := postNotification(j.webhook, n); err != nil {
+			glcm.Warn(fmt.Sprintf("Failed to notify the webhook of the job's outcome: %s", err))
+		}
+	}
+	if len(j.emailTo) > 0 {
+		if err := emailNotification(j.smtp, j.emailTo, n); err != nil {
+			glcm.Warn(fmt.Sprintf("Failed to email the job's outcome: %s", err))
+		}
+	}
+}
+
+// postNotification POSTs n, as JSON, to webhook
+func postNotification(webhook string, n jobNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", common.UserAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the error has the URL in it, which may well have a secret in it too
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// formatNotificationEmail is the message that emails n from, to
+func formatNotificationEmail(from string, to []string, n jobNotification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", n.EndTime.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(n.text())
+	return b.Bytes()
+}
+
+// emailNotification emails n to the addresses in to, through the mail server in s. It is smtp.SendMail, but with a
+// timeout, so that an unresponsive server can't keep us from exiting.
+func emailNotification(s smtpSettings, to []string, n jobNotification) error {
+	host, _, _ := net.SplitHostPort(s.server)
+	conn, err := net.DialTimeout("tcp", s.server, notificationTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(notificationTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err = c.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(formatNotificationEmail(s.from, to, n)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
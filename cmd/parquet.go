@@ -0,0 +1,696 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// thriftStruct is a struct read with the Thrift compact protocol, which Parquet's metadata is written in, by field ID.
+// Integers of every size are read as int64, doubles as float64, binaries as []byte, lists and sets as []any, and
+// structs as thriftStruct; maps, which Parquet's metadata doesn't have, are skipped.
+type thriftStruct map[int16]any
+
+func (s thriftStruct) int(id int16, fallback int64) int64 {
+	if v, ok := s[id].(int64); ok {
+		return v
+	}
+	return fallback
+}
+
+func (s thriftStruct) string(id int16) string {
+	b, _ := s[id].([]byte)
+	return string(b)
+}
+
+func (s thriftStruct) list(id int16) []any {
+	l, _ := s[id].([]any)
+	return l
+}
+
+func (s thriftStruct) child(id int16) thriftStruct {
+	c, _ := s[id].(thriftStruct)
+	return c
+}
+
+// thriftMaxDepth is how deeply structs and lists may be nested in Parquet's metadata, which needs only a few levels
+const thriftMaxDepth = 64
+
+type thriftReader struct {
+	r     *bytes.Reader
+	depth int // of the struct or list being read
+}
+
+func (t thriftReader) binary() ([]byte, error) {
+	n, err := binary.ReadUvarint(t.r)
+	if err != nil {
+		return nil, err
+	} else if n > uint64(t.r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(t.r, buf)
+	return buf, err
+}
+
+func (t thriftReader) value(typ byte) (any, error) {
+	switch typ {
+	case 1, 2: // true and false, in a struct's field header; a byte of their own in a list
+		return typ == 1, nil
+	case 3:
+		b, err := t.r.ReadByte()
+		return int64(int8(b)), err
+	case 4, 5, 6:
+		return binary.ReadVarint(t.r) // zig-zag, as Go's are
+	case 7:
+		var buf [8]byte
+		_, err := io.ReadFull(t.r, buf[:])
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), err
+	case 8:
+		return t.binary()
+	case 9, 10:
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n, elem := uint64(header>>4), header&0x0f
+		if n == 15 {
+			if n, err = binary.ReadUvarint(t.r); err != nil {
+				return nil, err
+			}
+		}
+		if n > uint64(t.r.Len()) {
+			return nil, io.ErrUnexpectedEOF // each element takes a byte at least
+		}
+		if t.depth >= thriftMaxDepth {
+			return nil, errors.New("the Thrift struct is nested too deeply")
+		}
+		items := make([]any, 0, n)
+		for ; n > 0; n-- {
+			var v any
+			if elem == 1 || elem == 2 {
+				var b byte
+				b, err = t.r.ReadByte()
+				v = b == 1
+			} else {
+				v, err = thriftReader{t.r, t.depth + 1}.value(elem)
+			}
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case 11:
+		n, err := binary.ReadUvarint(t.r)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		types, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		} else if n > uint64(t.r.Len()) {
+			return nil, io.ErrUnexpectedEOF // each entry takes a byte at least, but for one of bools to bools
+		}
+		for ; n > 0; n-- {
+			if _, err = t.value(types >> 4); err != nil {
+				return nil, err
+			}
+			if _, err = t.value(types & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case 12:
+		if t.depth >= thriftMaxDepth {
+			return nil, errors.New("the Thrift struct is nested too deeply")
+		}
+		return thriftReader{t.r, t.depth + 1}.structure()
+	}
+	return nil, fmt.Errorf("unknown Thrift type %d", typ)
+}
+
+func (t thriftReader) structure() (thriftStruct, error) {
+	s := thriftStruct{}
+	var id int16
+	for {
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == 0 {
+			return s, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			long, err := binary.ReadVarint(t.r)
+			if err != nil {
+				return nil, err
+			}
+			id = int16(long)
+		}
+		if s[id], err = t.value(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Parquet's physical types, and the other enums of its metadata that are read here
+const (
+	parquetBoolean = iota
+	parquetInt32
+	parquetInt64
+	parquetInt96
+	parquetFloat
+	parquetDouble
+	parquetByteArray
+	parquetFixedLenByteArray
+)
+
+const (
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+
+	parquetPlain          = 0
+	parquetPlainDict      = 2
+	parquetRLE            = 3
+	parquetRLEDictionary  = 8
+	parquetRepeated       = 2
+	parquetUncompressed   = 0
+	parquetSnappy         = 1
+	parquetGzip           = 2
+	parquetTimestampMilli = 9
+	parquetTimestampMicro = 10
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetMaxPageValues bounds how many values the pages being read, one of each column, may have between them.
+// Pages of nulls, or of a value from the dictionary over and over, take a few bytes for however many values they have,
+// so their number can't be checked against the file's size; writers put 20,000 or so in a page.
+const parquetMaxPageValues = 4 * 1024 * 1024
+
+// parquetColumn is a column of a Parquet file that isn't nested in a group, which are the ones that are read
+type parquetColumn struct {
+	name       string
+	typ        int64
+	typeLength int
+	optional   bool
+	timeUnit   time.Duration // of an INT64 that's a timestamp
+	chunk      int           // the index of its chunk in a row group
+}
+
+// readParquet calls fn with each row of a Parquet file, as a map of the names of its columns to their values. Columns
+// that are nested, or repeated, are left out, as are those that are null in the row. Byte arrays are read as strings,
+// and timestamps as times in UTC. Files that aren't compressed, or are compressed with Snappy or gzip, can be read.
+// A file that's damaged returns an error, which may be after fn has been called with the rows before the damage.
+func readParquet(r io.ReaderAt, size int64, fn func(row map[string]any) error) error {
+	if size < 12 {
+		return errors.New("not a Parquet file")
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return err
+	}
+	footerLength := int64(binary.LittleEndian.Uint32(tail))
+	if !bytes.Equal(tail[4:], parquetMagic) || footerLength > size-12 {
+		return errors.New("not a Parquet file")
+	}
+	footer := make([]byte, footerLength)
+	if _, err := r.ReadAt(footer, size-8-footerLength); err != nil {
+		return err
+	}
+	meta, err := thriftReader{r: bytes.NewReader(footer)}.structure()
+	if err != nil {
+		return fmt.Errorf("the Parquet file's metadata can't be read: %w", err)
+	}
+	columns, err := parquetColumns(meta.list(2))
+	if err != nil {
+		return err
+	} else if len(columns) == 0 {
+		return errors.New("the Parquet file has no columns that can be read")
+	}
+	maxPageValues := max(parquetMaxPageValues/len(columns), 1)
+
+	for _, g := range meta.list(4) {
+		group, _ := g.(thriftStruct)
+		chunks := group.list(1)
+		rows := group.int(3, 0)
+		pages := make([]*parquetPages, len(columns))
+		for i, c := range columns {
+			if c.chunk >= len(chunks) {
+				return fmt.Errorf("the Parquet file has no data for column %s", c.name)
+			}
+			chunk, _ := chunks[c.chunk].(thriftStruct)
+			if pages[i], err = c.pages(r, size, chunk.child(3), maxPageValues); err != nil {
+				return fmt.Errorf("column %s: %w", c.name, err)
+			}
+			if pages[i].remaining != rows {
+				return fmt.Errorf("column %s has %d values for %d rows", c.name, pages[i].remaining, rows)
+			}
+		}
+		for n := int64(0); n < rows; n++ {
+			row := make(map[string]any, len(columns))
+			for i, c := range columns {
+				v, err := pages[i].next()
+				if err != nil {
+					return fmt.Errorf("column %s: %w", c.name, err)
+				}
+				if v != nil {
+					row[c.name] = v
+				}
+			}
+			if err = fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parquetColumns finds the columns in a file's schema, which lists its elements depth first
+func parquetColumns(schema []any) ([]parquetColumn, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("the Parquet file has no schema")
+	}
+	var columns []parquetColumn
+	chunk := 0
+	// skip moves past the element at i, and everything in it, counting the chunks of its columns
+	skip := func(i int) int {
+		for elements := 1; elements > 0 && i < len(schema); elements-- {
+			el, _ := schema[i].(thriftStruct)
+			if children := el.int(5, 0); children > 0 {
+				elements += int(min(children, int64(len(schema))))
+			} else {
+				chunk++
+			}
+			i++
+		}
+		return i
+	}
+
+	root, _ := schema[0].(thriftStruct)
+	i := 1
+	for n := root.int(5, 0); n > 0 && i < len(schema); n-- {
+		el, _ := schema[i].(thriftStruct)
+		if el.int(5, 0) > 0 || el.int(3, 0) == parquetRepeated {
+			i = skip(i)
+			continue
+		}
+		c := parquetColumn{
+			name:       el.string(4),
+			typ:        el.int(1, -1),
+			typeLength: int(min(max(el.int(2, 0), 0), math.MaxInt32)),
+			optional:   el.int(3, 0) != 0,
+			chunk:      chunk,
+		}
+		switch el.int(6, -1) {
+		case parquetTimestampMilli:
+			c.timeUnit = time.Millisecond
+		case parquetTimestampMicro:
+			c.timeUnit = time.Microsecond
+		}
+		if ts := el.child(10).child(8); ts != nil {
+			switch unit := ts.child(2); {
+			case unit.child(1) != nil:
+				c.timeUnit = time.Millisecond
+			case unit.child(2) != nil:
+				c.timeUnit = time.Microsecond
+			case unit.child(3) != nil:
+				c.timeUnit = time.Nanosecond
+			}
+		}
+		columns = append(columns, c)
+		chunk++
+		i++
+	}
+	return columns, nil
+}
+
+// parquetPages reads the values of a column in a row group, a page at a time
+type parquetPages struct {
+	c             parquetColumn
+	pages         thriftReader
+	codec         int64
+	maxPageValues int
+	dictionary    []any
+	values        []any // of the page being read, with nil for each null
+	remaining     int64 // values, in the pages that haven't been read yet and in values
+}
+
+// pages reads the column's chunk in a row group, whose values are then read with next
+func (c parquetColumn) pages(r io.ReaderAt, size int64, meta thriftStruct, maxPageValues int) (*parquetPages, error) {
+	start := meta.int(9, 0)
+	if dict := meta.int(11, 0); dict > 0 && dict < start {
+		start = dict
+	}
+	length := meta.int(7, 0)
+	if start < 0 || length < 0 || start > size || length > size-start || length > math.MaxInt32 {
+		return nil, errors.New("the column chunk's metadata is damaged")
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+	return &parquetPages{
+		c:             c,
+		pages:         thriftReader{r: bytes.NewReader(buf)},
+		codec:         meta.int(4, parquetUncompressed),
+		maxPageValues: maxPageValues,
+		remaining:     meta.int(5, 0),
+	}, nil
+}
+
+// next returns the next value of the column, or nil if it's null
+func (p *parquetPages) next() (any, error) {
+	for len(p.values) == 0 {
+		if p.remaining <= 0 {
+			return nil, errors.New("the column has fewer values than the row group has rows")
+		}
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+	}
+	v := p.values[0]
+	p.values = p.values[1:]
+	p.remaining--
+	return v, nil
+}
+
+// read reads the next page of the column, which for a dictionary page leaves values empty
+func (p *parquetPages) read() error {
+	header, err := p.pages.structure()
+	if err != nil {
+		return err
+	}
+	compressedSize, size := header.int(3, 0), header.int(2, 0)
+	if compressedSize < 0 || compressedSize > int64(p.pages.r.Len()) || size < 0 || size > math.MaxInt32 {
+		return io.ErrUnexpectedEOF
+	}
+	page := make([]byte, compressedSize)
+	if _, err = io.ReadFull(p.pages.r, page); err != nil {
+		return err
+	}
+	// the values of a data page must all be in the column chunk, and all of them held at once
+	n := func(n int64) (int, error) {
+		if n < 0 || n > p.remaining || n > int64(p.maxPageValues) {
+			return 0, fmt.Errorf("a page of %d values is damaged, or too big to read", n)
+		}
+		return int(n), nil
+	}
+
+	switch header.int(1, -1) {
+	case parquetDictionaryPage:
+		if page, err = parquetDecompress(p.codec, page, int(size)); err != nil {
+			return err
+		}
+		p.dictionary, err = p.c.plain(page, header.child(7).int(1, 0))
+		return err
+	case parquetDataPage:
+		if page, err = parquetDecompress(p.codec, page, int(size)); err != nil {
+			return err
+		}
+		h := header.child(5)
+		n, err := n(h.int(1, 0))
+		if err != nil {
+			return err
+		}
+		defined := page[:0]
+		if p.c.optional {
+			if h.int(3, parquetRLE) != parquetRLE {
+				return errors.New("the column's definition levels aren't RLE encoded")
+			}
+			if len(page) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			levels := int64(binary.LittleEndian.Uint32(page))
+			if levels > int64(len(page)-4) {
+				return io.ErrUnexpectedEOF
+			}
+			defined, page = page[4:4+levels], page[4+levels:]
+		}
+		p.values, err = p.c.page(h.int(2, parquetPlain), n, defined, page, p.dictionary)
+		return err
+	case parquetDataPageV2:
+		h := header.child(8)
+		n, err := n(h.int(1, 0))
+		if err != nil {
+			return err
+		}
+		repLength, defLength := h.int(6, 0), h.int(5, 0)
+		if repLength < 0 || defLength < 0 || repLength > int64(len(page)) || defLength > int64(len(page))-repLength {
+			return io.ErrUnexpectedEOF
+		}
+		defined, data := page[repLength:repLength+defLength], page[repLength+defLength:]
+		if compressed, ok := h[7].(bool); !ok || compressed {
+			if data, err = parquetDecompress(p.codec, data, int(size-repLength-defLength)); err != nil {
+				return err
+			}
+		}
+		if !p.c.optional {
+			defined = defined[:0]
+		}
+		p.values, err = p.c.page(h.int(4, parquetPlain), n, defined, data, p.dictionary)
+		return err
+	}
+	return nil // an index page, or one of a kind that's newer than this
+}
+
+// page returns the n values of a data page, whose definition levels, if the column is optional, are in defined
+func (c parquetColumn) page(encoding int64, n int, defined, data []byte, dictionary []any) ([]any, error) {
+	present := n
+	var levels []int
+	if c.optional {
+		var err error
+		if levels, err = decodeRLEHybrid(defined, 1, n); err != nil {
+			return nil, err
+		}
+		present = 0
+		for _, l := range levels {
+			if l > 1 {
+				return nil, fmt.Errorf("a definition level of %d is out of range", l)
+			}
+			present += l
+		}
+	}
+
+	var got []any
+	switch encoding {
+	case parquetPlain:
+		var err error
+		if got, err = c.plain(data, int64(present)); err != nil {
+			return nil, err
+		}
+	case parquetPlainDict, parquetRLEDictionary:
+		if len(data) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		indices, err := decodeRLEHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return nil, err
+		}
+		got = make([]any, present)
+		for i, index := range indices {
+			if index >= len(dictionary) {
+				return nil, errors.New("a dictionary index is out of range")
+			}
+			got[i] = dictionary[index]
+		}
+	default:
+		return nil, fmt.Errorf("the Parquet encoding %d isn't supported", encoding)
+	}
+
+	if levels == nil {
+		return got, nil
+	}
+	values := make([]any, 0, n)
+	for _, l := range levels {
+		if l == 0 {
+			values = append(values, nil)
+		} else {
+			values, got = append(values, got[0]), got[1:]
+		}
+	}
+	return values, nil
+}
+
+// plain reads n values of the column's type that are PLAIN encoded. Since each takes a bit at least, there can't be
+// more than eight for each byte of data.
+func (c parquetColumn) plain(data []byte, n int64) ([]any, error) {
+	if n < 0 || n > int64(len(data))*8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	values := make([]any, 0, n)
+	take := func(size int) ([]byte, error) {
+		if size < 0 || size > len(data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := data[:size]
+		data = data[size:]
+		return b, nil
+	}
+	for i := 0; i < int(n); i++ {
+		var v any
+		switch c.typ {
+		case parquetBoolean:
+			if i/8 >= len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v = data[i/8]>>(i%8)&1 == 1
+		case parquetInt32, parquetFloat:
+			b, err := take(4)
+			if err != nil {
+				return nil, err
+			}
+			bits := binary.LittleEndian.Uint32(b)
+			if c.typ == parquetFloat {
+				v = float64(math.Float32frombits(bits))
+			} else {
+				v = int64(int32(bits))
+			}
+		case parquetInt64, parquetDouble:
+			b, err := take(8)
+			if err != nil {
+				return nil, err
+			}
+			bits := binary.LittleEndian.Uint64(b)
+			if c.typ == parquetDouble {
+				v = math.Float64frombits(bits)
+			} else if c.timeUnit != 0 {
+				v = time.Unix(0, int64(bits)*int64(c.timeUnit)).UTC()
+			} else {
+				v = int64(bits)
+			}
+		case parquetInt96:
+			// a timestamp, as nanoseconds into a Julian day
+			b, err := take(12)
+			if err != nil {
+				return nil, err
+			}
+			nanos := int64(binary.LittleEndian.Uint64(b))
+			day := int64(binary.LittleEndian.Uint32(b[8:]))
+			const unixEpochJulianDay = 2440588
+			v = time.Unix((day-unixEpochJulianDay)*24*60*60, nanos).UTC()
+		case parquetByteArray:
+			l, err := take(4)
+			if err != nil {
+				return nil, err
+			}
+			length := binary.LittleEndian.Uint32(l)
+			if uint64(length) > uint64(len(data)) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			b, _ := take(int(length))
+			v = string(b)
+		case parquetFixedLenByteArray:
+			b, err := take(c.typeLength)
+			if err != nil {
+				return nil, err
+			}
+			v = append([]byte(nil), b...)
+		default:
+			return nil, fmt.Errorf("the Parquet type %d isn't supported", c.typ)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// decodeRLEHybrid reads n values of the given width in bits that are encoded with Parquet's mix of run lengths and
+// bit packing
+func decodeRLEHybrid(data []byte, bitWidth int, n int) ([]int, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("a bit width of %d is out of range", bitWidth)
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("%d values can't be read", n)
+	}
+	values := make([]int, 0, n)
+	r := bytes.NewReader(data)
+	for len(values) < n {
+		header, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if header&1 == 0 {
+			// a run of one value, which takes as many bytes as it needs
+			run := int(min(header>>1, uint64(n-len(values))))
+			var v int
+			for i := 0; i < (bitWidth+7)/8; i++ {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				v |= int(b) << (8 * i)
+			}
+			for ; run > 0; run-- {
+				values = append(values, v)
+			}
+			continue
+		}
+		// groups of eight values, packed least significant bit first, which are all in data, and of which only those
+		// up to the nth are read
+		groups := min(header>>1, uint64(n-len(values)+7)/8)
+		if bitWidth > 0 && header>>1 > uint64(r.Len())/uint64(bitWidth) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		packed := make([]byte, int(header>>1)*bitWidth)
+		if _, err = io.ReadFull(r, packed); err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(groups)*8 && len(values) < n; i++ {
+			var v int
+			for b := 0; b < bitWidth; b++ {
+				bit := i*bitWidth + b
+				v |= int(packed[bit/8]>>(bit%8)&1) << b
+			}
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// parquetDecompress decompresses a page, which is size bytes once it is
+func parquetDecompress(codec int64, data []byte, size int) ([]byte, error) {
+	var out []byte
+	switch codec {
+	case parquetUncompressed:
+		out = data
+	case parquetSnappy:
+		// a copy takes two bytes at least, for up to 11 of what's decompressed, and three for up to 64
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if n != size || n > 22*len(data) {
+			return nil, errors.New("the Snappy block is damaged: it isn't the length it says")
+		}
+		if out, err = snappy.Decode(nil, data); err != nil {
+			return nil, err
+		}
+	case parquetGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if out, err = io.ReadAll(io.LimitReader(gz, int64(size)+1)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("the Parquet compression codec %d isn't supported", codec)
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("the page is %d bytes, rather than the %d it says", len(out), size)
+	}
+	return out, nil
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -56,6 +57,25 @@ func DetermineLocationLevel(location string, locationType common.Location, sourc
 		}
 	case common.ELocation.Benchmark():
 		return ELocationLevel.Object(), nil // we always benchmark to a subfolder, not the container root
+	case common.ELocation.URLList():
+		return ELocationLevel.Container(), nil // the list stands for a folder of the objects it names
+
+	// Providers have no services either, so they get the same treatment as local folders and files.
+	case common.ELocation.Provider():
+		if strings.Contains(location, "*") || strings.HasSuffix(location, "/") {
+			return ELocationLevel.Container(), nil
+		}
+		if !source {
+			return ELocationLevel.Object(), nil
+		}
+		p, u, err := common.GetProvider(location)
+		if err != nil {
+			return ELocationLevel.Object(), err
+		}
+		if obj, err := p.Properties(context.TODO(), u); err == nil && obj.IsDirectory {
+			return ELocationLevel.Container(), nil
+		}
+		return ELocationLevel.Object(), nil
 
 	case common.ELocation.Blob(),
 		common.ELocation.File(),
@@ -106,6 +126,10 @@ func GetResourceRoot(resource string, location common.Location) (resourceBase st
 		return resource, nil
 	case common.ELocation.Local():
 		return cleanLocalPath(getPathBeforeFirstWildcard(resource)), nil
+	case common.ELocation.Provider():
+		return getPathBeforeFirstWildcard(resource), nil
+	case common.ELocation.URLList():
+		return "", nil // each URL in the list is a full URL, with no root in common
 
 	//noinspection GoNilness
 	case common.ELocation.Blob():
@@ -226,8 +250,10 @@ func splitAuthTokenFromResource(resource string, location common.Location) (reso
 
 		*baseURL = common.URLExtension{URL: *baseURL}.URLWithPlusDecodedInPath()
 		return baseURL.String(), "", nil
-	case common.ELocation.GCP():
+	case common.ELocation.GCP(), common.ELocation.Provider(): // providers take care of their own credentials
 		return resource, "", nil
+	case common.ELocation.URLList():
+		return cleanLocalPath(resource), "", nil // the URLs in the list carry their own SAS or presigned signatures
 	case common.ELocation.Benchmark(), // cover for benchmark as we generate data for that
 		common.ELocation.Unknown(), // cover for unknown as we treat that as garbage
 		common.ELocation.None():
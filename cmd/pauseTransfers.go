@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+// pauseJob stops jobID's transfers from picking up new work, and flushes its plan files so that, if we're killed while
+// paused, "jobs resume" carries on from where we stopped
+func pauseJob(lcm common.LifecycleMgr, jobID common.JobID) {
+	jm, ok := jobMgr(jobID)
+	if !ok || !jm.TransferGate().Pause() {
+		return
+	}
+
+	msg := "Transfers paused. Chunks that are already in flight will finish; send SIGUSR2 to resume."
+	if err := jm.FlushPlans(); err != nil {
+		jm.Log(common.LogWarning, "Failed to flush the plan files while pausing: "+err.Error())
+		lcm.Warn("Failed to flush the plan files while pausing: " + err.Error())
+	}
+	jm.Log(common.LogWarning, msg)
+	lcm.Info(msg)
+}
+
+func resumeJob(lcm common.LifecycleMgr, jobID common.JobID) {
+	jm, ok := jobMgr(jobID)
+	if !ok || !jm.TransferGate().Resume() {
+		return
+	}
+
+	msg := "Transfers resumed."
+	jm.Log(common.LogWarning, msg)
+	lcm.Info(msg)
+}
+
+// jobTransferState reports whether jobID's transfers are paused, or draining because we're shutting down
+func jobTransferState(jobID common.JobID) (paused bool, draining bool) {
+	if jm, ok := jobMgr(jobID); ok {
+		return jm.TransferGate().Paused(), jm.TransferGate().Draining()
+	}
+	return false, false
+}
+
+func jobMgr(jobID common.JobID) (ste.IJobMgr, bool) {
+	if jobsAdmin.JobsAdmin == nil {
+		return nil, false
+	}
+	return jobsAdmin.JobsAdmin.JobMgr(jobID)
+}
+
+func (cca *CookedCopyCmdArgs) Pause(lcm common.LifecycleMgr)    { pauseJob(lcm, cca.jobID) }
+func (cca *CookedCopyCmdArgs) Resume(lcm common.LifecycleMgr)   { resumeJob(lcm, cca.jobID) }
+func (cca *cookedSyncCmdArgs) Pause(lcm common.LifecycleMgr)    { pauseJob(lcm, cca.jobID) }
+func (cca *cookedSyncCmdArgs) Resume(lcm common.LifecycleMgr)   { resumeJob(lcm, cca.jobID) }
+func (cca *resumeJobController) Pause(lcm common.LifecycleMgr)  { pauseJob(lcm, cca.jobID) }
+func (cca *resumeJobController) Resume(lcm common.LifecycleMgr) { resumeJob(lcm, cca.jobID) }
+
+// a watcher's pause outlasts the round it was sent during, so no further rounds are started until it is resumed
+func (w *syncWatcher) Pause(lcm common.LifecycleMgr) {
+	w.mu.Lock()
+	wasPaused := w.paused
+	w.paused = true
+	round := w.round
+	w.mu.Unlock()
+
+	if round != nil {
+		round.Pause(lcm)
+	} else if !wasPaused {
+		lcm.Info("Transfers paused; send SIGUSR2 to resume.")
+	}
+}
+
+func (w *syncWatcher) Resume(lcm common.LifecycleMgr) {
+	w.mu.Lock()
+	wasPaused := w.paused
+	w.paused = false
+	w.unpaused.Broadcast()
+	round := w.round
+	w.mu.Unlock()
+
+	if round != nil {
+		round.Resume(lcm)
+	} else if wasPaused {
+		lcm.Info("Transfers resumed.")
+	}
+}
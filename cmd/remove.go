@@ -90,7 +90,7 @@ func init() {
 			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
 			err = cooked.process()
 			if err != nil {
-				glcm.Error("failed to perform remove command due to error: " + err.Error() + getErrorCodeUrl(err))
+				glcm.ErrorWithExitCode("failed to perform remove command due to error: "+err.Error()+getErrorCodeUrl(err), errorExitCode(err))
 			}
 
 			if cooked.dryrunMode {
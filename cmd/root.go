@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -48,11 +49,22 @@ import (
 var outputFormatRaw string
 var outputVerbosityRaw string
 var logVerbosityRaw string
+var logTargetRaw string
+var logFormatRaw string
+var traceEndpoint string
+var syslogOptions common.SyslogOptions
 var cancelFromStdin bool
 var OutputFormat common.OutputFormat
 var OutputLevel common.OutputVerbosity
 var LogLevel common.LogLevel
 var CapMbps float64
+var capBurstMB float64
+var bandwidthScheduleRaw string
+var bandwidthSchedule *common.BandwidthSchedule
+var accountLimitsRaw string
+var caBundle, clientCert, clientKey string
+var sourceIP, networkInterface, ipVersion string
+var hostOverrides []string
 var SkipVersionCheck bool
 
 // It's not pretty that this one is read directly by credential util.
@@ -65,6 +77,12 @@ var azcopyAwaitAllowOpenFiles bool
 var azcopyScanningLogger common.ILoggerResetable
 var isPipeDownload bool
 var retryStatusCodes string
+var retrySettings = ste.DefaultRetrySettings()
+var circuitBreakerSettings = ste.DefaultCircuitBreakerSettings()
+var diskSpaceSettings = ste.DefaultDiskSpaceSettings()
+var minFreeSpaceRaw string
+var onLowFreeSpace string
+var memoryLimitRaw string
 var debugMemoryProfile string
 
 // It would be preferable if this was a local variable, since it just gets altered and shot off to the STE
@@ -110,6 +128,30 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("failed to parse requested retry status code list: %w", err)
 		}
 		ste.RetryStatusCodes = rsc
+		if err = ste.SetRetrySettings(retrySettings); err != nil {
+			return fmt.Errorf("invalid retry settings: %w", err)
+		}
+		if err = ste.SetCircuitBreakerSettings(circuitBreakerSettings); err != nil {
+			return fmt.Errorf("invalid circuit breaker settings: %w", err)
+		}
+		if minFreeSpaceRaw != "" {
+			minFree, err := ParseSizeString(minFreeSpaceRaw, "--min-free-space")
+			if err != nil {
+				return err
+			}
+			diskSpaceSettings.MinFree = uint64(minFree)
+		}
+		switch strings.ToLower(onLowFreeSpace) {
+		case "pause":
+			diskSpaceSettings.Fail = false
+		case "fail":
+			diskSpaceSettings.Fail = true
+		default:
+			return fmt.Errorf("invalid --on-low-free-space '%s': use pause or fail", onLowFreeSpace)
+		}
+		if err = ste.SetDiskSpaceSettings(diskSpaceSettings); err != nil {
+			return fmt.Errorf("invalid free space settings: %w", err)
+		}
 
 		glcm.E2EEnableAwaitAllowOpenFiles(azcopyAwaitAllowOpenFiles)
 		if azcopyAwaitContinue {
@@ -131,6 +173,58 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
+		var logTarget common.LogTarget
+		if err = logTarget.Parse(logTargetRaw); err != nil {
+			return fmt.Errorf("invalid value '%s' for --log-target: expected file, syslog or both", logTargetRaw)
+		}
+		if err = common.SetLogTarget(logTarget, syslogOptions); err != nil {
+			return err
+		}
+
+		var logFormat common.LogFormat
+		if err = logFormat.Parse(logFormatRaw); err != nil {
+			return fmt.Errorf("invalid value '%s' for --log-format: expected text or json", logFormatRaw)
+		}
+		common.SetLogFormat(logFormat)
+
+		if err = enableTracing(); err != nil {
+			return err
+		}
+
+		if bandwidthScheduleRaw != "" {
+			if bandwidthSchedule, err = common.ParseBandwidthSchedule(bandwidthScheduleRaw); err != nil {
+				return fmt.Errorf("invalid --bandwidth-schedule: %w", err)
+			}
+		}
+		if memoryLimitRaw != "" {
+			limit, err := ParseSizeString(memoryLimitRaw, "--memory-limit")
+			if err != nil {
+				return err
+			}
+			if err = common.SetMemoryLimit(limit); err != nil {
+				return fmt.Errorf("invalid --memory-limit: %w", err)
+			}
+		}
+		if accountLimitsRaw != "" {
+			limits, err := ste.ParseAccountLimits(accountLimitsRaw)
+			if err != nil {
+				return fmt.Errorf("invalid --account-limits: %w", err)
+			}
+			ste.EnableAccountLimits(limits)
+		}
+		if err = common.LoadClientTLS(caBundle, clientCert, clientKey); err != nil {
+			return err
+		}
+		if err = common.SetIPVersion(ipVersion); err != nil {
+			return fmt.Errorf("invalid --ip-version: %w", err)
+		}
+		if err = common.BindSourceAddress(sourceIP, networkInterface); err != nil {
+			return err
+		}
+		if err = common.SetHostOverrides(hostOverrides); err != nil {
+			return fmt.Errorf("invalid --resolve: %w", err)
+		}
+
 		// If the command is for resuming a job with a specific JobID,
 		// use the provided JobID to resume the job; otherwise, create a new JobID.
 		var resumeJobID common.JobID
@@ -143,6 +237,12 @@ var rootCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+		} else if cmd.Use == "copy [source] [destination]" && cmd.Flags().Changed(ContinueJobFlag) {
+			// jobs resume is carrying on the job's scan
+			continueJob, _ := cmd.Flags().GetString(ContinueJobFlag)
+			if resumeJobID, err = common.ParseJobID(continueJob); err != nil {
+				return fmt.Errorf("invalid --%s: %w", ContinueJobFlag, err)
+			}
 		}
 
 		// Check if we are downloading to Pipe so we can bypass version check and not write it to stdout, customer is
@@ -202,7 +302,7 @@ var rootCmd = &cobra.Command{
 
 func Initialize(resumeJobID common.JobID, isBench bool, shouldWarn bool) (err error) {
 	jobsAdmin.BenchmarkResults = isBench
-	Client, err = azcopy.NewClient(azcopy.ClientOptions{CapMbps: CapMbps})
+	Client, err = azcopy.NewClient(azcopy.ClientOptions{CapMbps: CapMbps, CapBurstMB: capBurstMB, BandwidthSchedule: bandwidthSchedule})
 	if err != nil {
 		return err
 	}
@@ -306,6 +406,41 @@ func init() {
 		"Caps the transfer rate, in megabits per second. "+
 			"\n Moment-by-moment throughput might vary slightly from the cap."+
 			"\n If this option is set to zero, or it is omitted, the throughput isn't capped.")
+	rootCmd.PersistentFlags().Float64Var(&capBurstMB, "cap-burst-mb", 0,
+		"How many megabytes may be transferred at once, above --cap-mbps, after a quiet spell. "+
+			"\n The default is a second's worth at the cap.")
+	rootCmd.PersistentFlags().StringVar(&bandwidthScheduleRaw, "bandwidth-schedule", "",
+		"Caps the transfer rate differently at different times of the week, in local time, changing the cap as the job runs. "+
+			"\n Rules are separated by semicolons, and each is '[days] HH:MM-HH:MM rate', with the rate in megabits per second, or 'unlimited'. "+
+			"\n For example, 'mon-fri 08:00-18:00 50; sat,sun 10:00-16:00 200' caps it at 50 Mbps during working hours on weekdays. "+
+			"\n Outside the rules, --cap-mbps applies, so transfers aren't capped unless it's given too.")
+	rootCmd.PersistentFlags().StringVar(&accountLimitsRaw, "account-limits", "",
+		"Caps the requests to particular storage accounts, so that a job can't trip the limits of an account it shares with production. "+
+			"\n Limits are separated by semicolons, and each is an account name followed by requests=N (requests in progress at once), mbps=N, or both. "+
+			"\n For example, 'prodaccount requests=16 mbps=200; backupaccount mbps=500'. "+
+			"\n The bytes of server-side copies aren't counted, since they don't pass through AzCopy, but their requests are.")
+	rootCmd.PersistentFlags().StringVar(&caBundle, "ca-bundle", "",
+		"A PEM file of CA certificates to trust as well as the system's, as for a TLS-inspecting proxy or a private endpoint with its own CA.")
+	rootCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "",
+		"A PEM file of the client certificate to present to servers and proxies that require mutual TLS. "+
+			"\n Its private key may be in the same file, or given with --client-key.")
+	rootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "",
+		"A PEM file of the private key of --client-cert, if it isn't in the same file.")
+	rootCmd.PersistentFlags().StringVar(&sourceIP, "source-ip", "",
+		"The local IP address to make connections from, so that a multi-homed machine's transfers go out on a particular network or VLAN. "+
+			"\n Give several, separated by commas, to make each connection from the next in turn, spreading the transfer across the uplinks they're on. "+
+			"\n Connections are only made to addresses of the same family as them. On FreeBSD, which picks the route by destination, "+
+			"\n each network also needs a route that leaves from its address, as with setfib or a routing rule.")
+	rootCmd.PersistentFlags().StringVar(&networkInterface, "interface", "",
+		"The network interface, such as em1 or vlan20, to make connections from, by its IPv4 address, or its IPv6 one if it has none. "+
+			"\n Give several, separated by commas, to take them in turn, as with --source-ip. It can't be given with --source-ip.")
+	rootCmd.PersistentFlags().StringVar(&ipVersion, "ip-version", "auto",
+		"The IP version to connect over: 4, 6, or auto for either, preferring IPv6 where both work. "+
+			"\n Use 4 where IPv6 routes to the storage endpoints are broken, so that each connection doesn't wait for IPv6 to time out first.")
+	rootCmd.PersistentFlags().StringArrayVar(&hostOverrides, "resolve", nil,
+		"Sends the connections to a host to the given address, whatever DNS says, as curl's --resolve does, for private endpoints whose DNS isn't right on this machine. "+
+			"\n It's host:address, for any port, or host:port:address, and may be given more than once. Several addresses, separated by commas, are tried in turn. "+
+			"\n For example, --resolve myaccount.blob.core.windows.net:10.1.2.3. Certificates are still checked against the host name.")
 	rootCmd.PersistentFlags().StringVar(&outputFormatRaw, "output-type", "text",
 		"Format of the command's output. The choices include: text, json. "+
 			"\n The default value is 'text'.")
@@ -315,6 +450,21 @@ func init() {
 		"Define the log verbosity for the log file, "+
 			"\n available levels: DEBUG(detailed trace), INFO(all requests/responses), WARNING(slow responses),"+
 			"\n ERROR(only failed requests), and NONE(no output logs). (default 'INFO').")
+	rootCmd.PersistentFlags().StringVar(&logTargetRaw, "log-target", "file",
+		"Where to write the log: file (the log file in the log location), syslog, or both.")
+	rootCmd.PersistentFlags().StringVar(&logFormatRaw, "log-format", "text",
+		"Format of the log: text, or json to write each line as a JSON object with the fields time, level, jobId, log and msg.")
+	rootCmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "",
+		"OpenTelemetry collector to send traces of each job, scan, file and chunk to, over OTLP/HTTP (e.g. http://localhost:4318)."+
+			"\n Defaults to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT; tracing is off if none of them is set.")
+	rootCmd.PersistentFlags().StringVar(&syslogOptions.Facility, "syslog-facility", "user",
+		"Syslog facility to log with when --log-target is syslog or both, e.g. user, daemon or local0.")
+	rootCmd.PersistentFlags().StringVar(&syslogOptions.Address, "syslog-address", "",
+		"Syslog daemon to log to when --log-target is syslog or both. Leave empty for the local one,"+
+			"\n or give a socket path (e.g. /var/run/log), or udp://host[:port] or tcp://host[:port] for a remote one.")
+	rootCmd.PersistentFlags().StringVar(&syslogOptions.Severities, "syslog-severity", "",
+		"Comma-separated overrides of the syslog severity used for each log level, e.g. 'INFO=notice,WARNING=err'."+
+			"\n By default ERROR logs as err, WARNING as warning, INFO as info and DEBUG as debug.")
 
 	rootCmd.PersistentFlags().StringVar(&TrustedSuffixes, trustedSuffixesNameAAD, "",
 		"\nSpecifies additional domain suffixes where Azure Active Directory login tokens may be sent.  \nThe default is '"+
@@ -342,10 +492,55 @@ func init() {
 	// reserved for partner teams
 	_ = rootCmd.PersistentFlags().MarkHidden("cancel-from-stdin")
 
-	// special flags to be used in case of unexpected service errors.
 	rootCmd.PersistentFlags().StringVar(&retryStatusCodes, "retry-status-codes", "",
-		"Comma-separated list of HTTP status codes to retry on. (default '408;429;500;502;503;504')")
-	_ = rootCmd.PersistentFlags().MarkHidden("retry-status-codes")
+		"Changes which HTTP status codes are retried, as well as 408, 429, 500, 502, 503 and 504. "+
+			"\n Codes are separated by semicolons, and each may be followed by a colon and the storage error codes to retry it for, separated by commas. "+
+			"\n A negative code stops that code, or its storage error codes, from being retried. "+
+			"\n For example, '409: OperationNotAllowedInCurrentState; -500: InternalError; -504'.")
+	rootCmd.PersistentFlags().IntVar(&retrySettings.MaxRetries, "retry-count", retrySettings.MaxRetries,
+		"How many times a failed request is retried. For S3, this is the only retry setting that applies.")
+	rootCmd.PersistentFlags().DurationVar(&retrySettings.RetryDelay, "retry-delay", retrySettings.RetryDelay,
+		"How long to wait before the first retry of a request. Each retry after it waits twice as long as the one before, up to --retry-max-delay.")
+	rootCmd.PersistentFlags().DurationVar(&retrySettings.MaxRetryDelay, "retry-max-delay", retrySettings.MaxRetryDelay,
+		"The longest wait between retries. A server that asks for a longer wait with Retry-After isn't retried.")
+	rootCmd.PersistentFlags().Float64Var(&retrySettings.Jitter, "retry-jitter", retrySettings.Jitter,
+		"How far each wait between retries may be from its backoff, at random, as a fraction of it, from 0 to 1, "+
+			"\n so that requests that failed together don't all retry together.")
+	rootCmd.PersistentFlags().DurationVar(&retrySettings.TryTimeout, "retry-try-timeout", 0,
+		"How long each try of a request may take, including reading its response. "+
+			"\n Defaults to AZCOPY_REQUEST_TRY_TIMEOUT, in minutes, or 15 minutes.")
+	rootCmd.PersistentFlags().DurationVar(&retrySettings.Deadline, "retry-deadline", 0,
+		"How long after a request is first tried it may still be retried, whatever --retry-count says. "+
+			"\n Defaults to no limit.")
+	rootCmd.PersistentFlags().IntVar(&retrySettings.Budget, "retry-budget", 0,
+		"How many retries all the requests of one file's transfer may make between them, "+
+			"\n after which its failing request fails the transfer without waiting out --retry-count. Defaults to no limit.")
+	rootCmd.PersistentFlags().DurationVar(&retrySettings.StallTimeout, "stall-timeout", retrySettings.StallTimeout,
+		"How long a request's connection may move no data before the request is cancelled and retried on a new connection, "+
+			"\n without waiting for --retry-try-timeout. 0 turns this off.")
+	rootCmd.PersistentFlags().Float64Var(&circuitBreakerSettings.FailureRate, "circuit-breaker-failure-rate", 0,
+		"Stops starting new transfers for --circuit-breaker-cooldown when this fraction, from 0 to 1, of the last --circuit-breaker-window transfers failed, "+
+			"\n as they do once a SAS expires, rather than failing every file that's left. Transfers then start again, "+
+			"\n and if the first of them to finish fails too, it stops them again. Defaults to 0, which turns it off.")
+	rootCmd.PersistentFlags().IntVar(&circuitBreakerSettings.Window, "circuit-breaker-window", circuitBreakerSettings.Window,
+		"How many of the most recent transfers --circuit-breaker-failure-rate counts.")
+	rootCmd.PersistentFlags().DurationVar(&circuitBreakerSettings.Cooldown, "circuit-breaker-cooldown", circuitBreakerSettings.Cooldown,
+		"How long the circuit breaker stops new transfers from starting for.")
+	rootCmd.PersistentFlags().StringVar(&minFreeSpaceRaw, "min-free-space", "",
+		"How much space downloads leave free on the file system they're saved to, such as 500M or 20G. "+
+			"\n A file that would leave less isn't started, and free space is checked again every --free-space-check-interval "+
+			"\n while downloading, rather than every file that's left failing once the disk is full. Defaults to not checking.")
+	rootCmd.PersistentFlags().StringVar(&onLowFreeSpace, "on-low-free-space", "pause",
+		"What happens when a download would leave less than --min-free-space free: 'pause' holds the job's transfers "+
+			"\n back until space has been freed, and 'fail' cancels the job, to be resumed later.")
+	rootCmd.PersistentFlags().DurationVar(&diskSpaceSettings.Interval, "free-space-check-interval", diskSpaceSettings.Interval,
+		"How often --min-free-space is checked while downloading, and while waiting for space to be freed.")
+
+	rootCmd.PersistentFlags().StringVar(&memoryLimitRaw, "memory-limit", "",
+		"Caps the memory that transfers hold, in their buffers and plan files together, such as 512M or 2G. "+
+			"\n When it's reached, no more chunks are read or downloaded until some have been written or sent, "+
+			"\n rather than the process running out of memory. Defaults to no cap, beyond AZCOPY_BUFFER_GB.")
+
 	rootCmd.PersistentFlags().StringVar(&debugMemoryProfile, "memory-profile", "", "Export pprof memory profile")
 	_ = rootCmd.PersistentFlags().MarkHidden("memory-profile")
 }
@@ -448,3 +643,31 @@ func getGitHubLatestRemoteVersion() (*Version, error) {
 	return getGitHubLatestRemoteVersionWithURL(apiEndpoint)
 
 }
+
+// enableTracing turns on trace export if a collector has been given, and arranges for outstanding spans to be sent
+// when we exit
+func enableTracing() error {
+	endpoint := traceEndpoint
+	for _, env := range []common.EnvironmentVariable{common.EEnvironmentVariable.OtelExporterTracesEndpoint(), common.EEnvironmentVariable.OtelExporterEndpoint()} {
+		if endpoint == "" {
+			endpoint = common.GetEnvironmentVariable(env)
+		}
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	headers, err := ste.ParseOTLPHeaders(common.GetEnvironmentVariable(common.EEnvironmentVariable.OtelExporterHeaders()))
+	if err != nil {
+		return err
+	}
+	if err = ste.EnableTracing(endpoint, headers); err != nil {
+		return err
+	}
+	glcm.RegisterCloseFunc(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = ste.ShutdownTracing(ctx)
+	})
+	return nil
+}
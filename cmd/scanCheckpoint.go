@@ -0,0 +1,500 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// scanCheckpointMaxDirs is how many directories a scan checkpoint may name before it's given up on, since it's
+// rewritten for each part of the job
+const scanCheckpointMaxDirs = 100000
+
+// scanCheckpoint records how far the scan of a copy's source has got, beside the job's plan files, so that when the job
+// is interrupted before its scan has finished, jobs resume can carry the scan on from there instead of starting it
+// over. It's rewritten each time a part of the job is sent, and records only what is in the parts sent so far: the
+// directories of a local source whose contents, and everything beneath them, are in the job, which aren't read again;
+// the directories whose own entries are in the job, whose subdirectories are still to be read; and the marker that
+// continues a flat listing of a Blob container. Whatever else was found before the interruption is found again, and
+// left out by comparing it with the sources in the plan.
+type scanCheckpoint struct {
+	mu        sync.Mutex
+	path      string
+	state     scanCheckpointState
+	used      bool // whether the source's traverser records its progress here; if none does, nothing is written
+	off       bool // given up on
+	continued bool // whether the scan carries on from an earlier attempt at it
+
+	// directories are named by their path relative to the source, with / between the names, and "" for the source
+	dirs      map[string]*scanCheckpointDir // those being read, with subdirectories still to complete
+	completed map[string]bool
+	listed    map[string]bool
+
+	// sources of transfers in the job that may be found again, which are left out when they are
+	ordered map[string]bool
+}
+
+type scanCheckpointDir struct {
+	listed            bool     // whether its entries have all been found
+	pending           int      // how many of its subdirectories have still to complete
+	completedChildren []string // which are in completed, until it completes too
+}
+
+// the content of the checkpoint file
+type scanCheckpointState struct {
+	copyCommand // what jobs resume runs copy with, to carry on the scan
+
+	Parts     common.PartNumber // how many parts of the job had been sent
+	Completed []string
+	Listed    []string
+
+	Listing    string
+	Marker     string
+	RescanFrom common.PartNumber // the first part that may hold what's listed after the marker
+}
+
+func scanCheckpointPath(jobID common.JobID) string {
+	return filepath.Join(common.AzcopyJobPlanFolder, jobID.String()+".scan")
+}
+
+// newScanCheckpoint returns the checkpoint of the given job's scan, which records that the job was run as command
+func newScanCheckpoint(jobID common.JobID, command copyCommand) *scanCheckpoint {
+	return &scanCheckpoint{
+		path:      scanCheckpointPath(jobID),
+		state:     scanCheckpointState{copyCommand: command},
+		dirs:      map[string]*scanCheckpointDir{},
+		completed: map[string]bool{},
+		listed:    map[string]bool{},
+		ordered:   map[string]bool{},
+	}
+}
+
+// readScanCheckpoint returns what the checkpoint of the given job records, or nil if it has none
+func readScanCheckpoint(jobID common.JobID) (*scanCheckpointState, error) {
+	content, err := os.ReadFile(scanCheckpointPath(jobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var state scanCheckpointState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("the record of how far the scan of job %s got is damaged: %w", jobID, err)
+	}
+	return &state, nil
+}
+
+// continueFrom has the scan carry on from where the checkpoint of an earlier attempt at it left off, and returns how
+// many parts the job has. listSources gives the sources of the transfers in the parts of the job from the given part
+// on, and how many parts there are.
+func (c *scanCheckpoint) continueFrom(state scanCheckpointState, listSources func(fromPart common.PartNumber, add func(source string)) common.PartNumber) common.PartNumber {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.continued = true
+	for _, dir := range state.Completed {
+		c.completed[dir] = true
+	}
+	for _, dir := range state.Listed {
+		c.listed[dir] = true
+	}
+	c.state.Listing, c.state.Marker, c.state.RescanFrom = state.Listing, state.Marker, state.RescanFrom
+	c.state.Parts = listSources(state.RescanFrom, func(source string) {
+		// what's skipped can't be found again
+		if source != "" && !c.found(strings.TrimPrefix(source, common.AZCOPY_PATH_SEPARATOR_STRING)) {
+			c.ordered[source] = true
+		}
+	})
+	return c.state.Parts
+}
+
+// found reports whether relativePath was found before the scan was interrupted, which it was if its directory's
+// entries were all found, or if it's beneath a directory that everything beneath was found in
+func (c *scanCheckpoint) found(relativePath string) bool {
+	dir := parentDir(relativePath)
+	if c.listed[dir] {
+		return true
+	}
+	for ; !c.completed[dir]; dir = parentDir(dir) {
+		if dir == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// claim records that the source's traverser records its progress in the checkpoint
+func (c *scanCheckpoint) claim() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used = true
+}
+
+// skips reports whether what the scan found at relativePath went into the job before it was interrupted
+func (c *scanCheckpoint) skips(relativePath string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if relativePath == "" {
+		// the source itself is the first thing found
+		return c.continued
+	}
+	return c.found(relativePath)
+}
+
+// descend reports whether the directory at relativePath is to be read, which it isn't if everything in it is in the job
+func (c *scanCheckpoint) descend(relativePath string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.completed[relativePath] {
+		return true
+	}
+	parent := c.dir(parentDir(relativePath))
+	parent.completedChildren = append(parent.completedChildren, relativePath)
+	return false
+}
+
+// dirDone records that all the entries of the directory at relativePath have been found, and that it has subdirs
+// subdirectories to be read
+func (c *scanCheckpoint) dirDone(relativePath string, subdirs int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.dir(relativePath)
+	d.listed = true
+	d.pending += subdirs
+	c.listed[relativePath] = true
+	c.complete(relativePath)
+}
+
+func (c *scanCheckpoint) dir(relativePath string) *scanCheckpointDir {
+	d, ok := c.dirs[relativePath]
+	if !ok {
+		d = &scanCheckpointDir{}
+		c.dirs[relativePath] = d
+	}
+	return d
+}
+
+// complete moves the directory at relativePath, and then each of its parents in turn, to completed, if everything
+// beneath it has been found. Since a completed directory isn't read again, the completed directories in it needn't be
+// recorded any longer.
+func (c *scanCheckpoint) complete(relativePath string) {
+	for {
+		d := c.dirs[relativePath]
+		if d == nil || !d.listed || d.pending > 0 {
+			return
+		}
+		delete(c.dirs, relativePath)
+		for _, child := range d.completedChildren {
+			delete(c.completed, child)
+		}
+		delete(c.listed, relativePath)
+		c.completed[relativePath] = true
+		if relativePath == "" {
+			return
+		}
+
+		parent := parentDir(relativePath)
+		p := c.dir(parent)
+		p.pending--
+		p.completedChildren = append(p.completedChildren, relativePath)
+		relativePath = parent
+	}
+}
+
+func parentDir(relativePath string) string {
+	if parent := path.Dir(relativePath); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+// marker returns the marker to carry on a flat listing from, or nil if it should start at the beginning
+func (c *scanCheckpoint) marker(listing string) (*string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used = true
+	if c.state.Listing != "" && c.state.Listing != listing {
+		return nil, errors.New("the source can't be listed the way it was when the job was started")
+	}
+	if c.state.Marker == "" {
+		return nil, nil
+	}
+	marker := c.state.Marker
+	return &marker, nil
+}
+
+// save records that a flat listing has processed everything before marker
+func (c *scanCheckpoint) save(listing string, marker *string) error {
+	if marker == nil || *marker == "" {
+		// the checkpoint goes once the final part has been sent
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// what's listed after it goes into the part that's being filled now
+	c.state.Listing, c.state.Marker, c.state.RescanFrom = listing, *marker, c.state.Parts
+	return nil
+}
+
+// alreadyOrdered reports whether a transfer with the given source went into the job before the scan was interrupted
+func (c *scanCheckpoint) alreadyOrdered(source string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.ordered[source] {
+		return false
+	}
+	delete(c.ordered, source) // it's only found once
+	return true
+}
+
+// commit records that the first parts parts of the job have been sent, along with everything found before them. The
+// checkpoint is only a help to jobs resume, so if it can't be written, it's given up on rather than failing the job.
+func (c *scanCheckpoint) commit(parts common.PartNumber) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.Parts = parts
+	if !c.used || c.off {
+		return
+	}
+	if len(c.completed)+len(c.listed) > scanCheckpointMaxDirs {
+		c.giveUp(fmt.Sprintf("it has more than %d directories on the go", scanCheckpointMaxDirs))
+		return
+	}
+
+	c.state.Completed = sortedKeys(c.completed)
+	c.state.Listed = sortedKeys(c.listed)
+	if err := c.write(); err != nil {
+		c.giveUp(err.Error())
+	}
+}
+
+func (c *scanCheckpoint) write() error {
+	content, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	// written aside and renamed, so that an interruption can't leave half a checkpoint
+	temp := c.path + ".tmp"
+	if err = os.WriteFile(temp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.path)
+}
+
+func (c *scanCheckpoint) giveUp(why string) {
+	c.off = true
+	c.remove()
+	common.LogToJobLogWithPrefix("No longer recording how far the scan has got, since "+why+". If the job is interrupted "+
+		"before the scan finishes, it can't be resumed; run it again instead, with --overwrite=ifSourceNewer or false to skip what has been copied.", common.LogWarning)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// finish removes the checkpoint, once the final part of the job has been sent
+func (c *scanCheckpoint) finish() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.off = true
+	c.remove()
+}
+
+func (c *scanCheckpoint) remove() {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		common.LogToJobLogWithPrefix("Couldn't remove the record of how far the scan got: "+err.Error(), common.LogWarning)
+	}
+}
+
+// copyCommand is how copy was run, so that it can be run again. Neither the source nor the destination has its SAS.
+type copyCommand struct {
+	Args        []string // the flags it was given
+	Source      common.ResourceString
+	Destination common.ResourceString
+}
+
+// unrecordedCopyFlags aren't given to copy when it's run again. Resuming a job doesn't run its hooks or send its
+// notifications, so carrying on its scan doesn't either; and a webhook's URL may be a secret, as a SAS is.
+var unrecordedCopyFlags = []string{ContinueJobFlag, PreHookFlag, PostHookFlag, NotifyWebhookFlag, NotifyEmailFlag}
+
+// recordCopyCommand records how copy was run, with the flags given to cmd and the given source and destination
+func recordCopyCommand(cmd *cobra.Command, src, dst string, fromTo common.FromTo) copyCommand {
+	var command copyCommand
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if slices.Contains(unrecordedCopyFlags, f.Name) {
+			return
+		}
+		if f.Name == FromInventoryFlag {
+			// the manifest's SAS isn't kept, as the source's isn't; once resumed, the manifest is read with the source's
+			if rs, err := SplitResourceString(f.Value.String(), common.ELocation.Blob()); err == nil {
+				rs.SAS = ""
+				if manifest, err := rs.String(); err == nil {
+					command.Args = append(command.Args, "--"+f.Name+"="+manifest)
+				}
+			}
+			return
+		}
+		if values, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range values.GetSlice() {
+				command.Args = append(command.Args, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		command.Args = append(command.Args, "--"+f.Name+"="+f.Value.String())
+	})
+
+	location := func(arg string, loc common.Location) common.ResourceString {
+		if loc.IsLocal() {
+			// jobs resume may be run from another directory
+			if abs, err := filepath.Abs(arg); err == nil {
+				arg = abs
+			}
+		}
+		rs, err := SplitResourceString(arg, loc)
+		if err != nil {
+			return common.ResourceString{Value: arg}
+		}
+		rs.SAS = ""
+		return rs
+	}
+	command.Source, command.Destination = location(src, fromTo.From()), location(dst, fromTo.To())
+	return command
+}
+
+// commandLine is the arguments to run copy with again, with the given SAS on its source and destination
+func (c copyCommand) commandLine(sourceSAS, destinationSAS string) ([]string, error) {
+	withSAS := func(rs common.ResourceString, sas string) (string, error) {
+		rs.SAS = sas
+		if rs.SAS == "" && rs.ExtraQuery == "" {
+			return rs.Value, nil
+		}
+		return rs.String()
+	}
+	src, err := withSAS(c.Source, sourceSAS)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := withSAS(c.Destination, destinationSAS)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"copy", src, dst}, c.Args...), nil
+}
+
+// scanCheckpointable reports whether a copy's scan can record how far it has got. It can't when transfers are held
+// back or packed together, since what has been found isn't then in the parts sent; nor when what it finds isn't all
+// beneath the source, as with symlinks that are followed and lists of files; nor when the source is a snapshot taken
+// for the job, which a resumed job wouldn't see.
+func (cca *CookedCopyCmdArgs) scanCheckpointable() bool {
+	return !cca.dryrunMode &&
+		cca.order == common.ETransferOrder.AsScanned() &&
+		cca.packFilesSmallerThan == 0 &&
+		!cca.SymlinkHandling.Follow() &&
+		cca.hardlinks != common.EHardlinkHandlingType.Preserve() &&
+		cca.ListOfFilesChannel == nil && cca.ListOfVersionIDsChannel == nil &&
+		cca.liveSource == "" &&
+		cca.followupJobArgs == nil && !cca.isCleanupJob
+}
+
+// startScanCheckpoint has the job's scan record how far it has got, if it can. With --continue-job, it resumes the job
+// that was given, and has its scan carry on from where the job's checkpoint left off.
+func (cca *CookedCopyCmdArgs) startScanCheckpoint(order *common.CopyJobPartOrderRequest) error {
+	if !cca.scanCheckpointable() {
+		if cca.continueJob {
+			return fmt.Errorf("the scan of job %s can't be carried on with these options", cca.jobID)
+		}
+		return nil
+	}
+	cca.scanCheckpoint = newScanCheckpoint(cca.jobID, cca.command)
+	if !cca.continueJob {
+		return nil
+	}
+
+	state, err := readScanCheckpoint(cca.jobID)
+	if err != nil {
+		return err
+	} else if state == nil {
+		return fmt.Errorf("job %s has no record of how far its scan got", cca.jobID)
+	}
+	resp := jobsAdmin.ResumeJobOrder(common.ResumeJobRequest{
+		JobID:            cca.jobID,
+		SourceSAS:        cca.Source.SAS,
+		DestinationSAS:   cca.Destination.SAS,
+		SrcServiceClient: order.SrcServiceClient,
+		DstServiceClient: order.DstServiceClient,
+		CredentialInfo:   cca.credentialInfo,
+		ContinueScan:     true,
+	})
+	if !resp.CancelledPauseResumed {
+		return errors.New(resp.ErrorMsg)
+	}
+
+	order.PartNum = cca.scanCheckpoint.continueFrom(*state, func(fromPart common.PartNumber, add func(string)) common.PartNumber {
+		return jobsAdmin.ListJobSources(cca.jobID, fromPart, add)
+	})
+	message := fmt.Sprintf("Carrying on the scan of job %s from where it was interrupted, after %d parts of the job", cca.jobID, order.PartNum)
+	glcm.Info(message)
+	common.LogToJobLogWithPrefix(message, common.LogInfo)
+
+	// the parts already in the job are under way
+	cca.waitUntilJobCompletion(false)
+	return nil
+}
+
+// continueCopyScan runs copy again to carry on the interrupted scan of the given job, with the given SAS on its source
+// and destination, and exits as copy does
+func continueCopyScan(jobID common.JobID, state scanCheckpointState, sourceSAS, destinationSAS string) error {
+	args, err := state.commandLine(sourceSAS, destinationSAS)
+	if err != nil {
+		return err
+	}
+	azcopy, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	glcm.Info(fmt.Sprintf("The scan of job %s was interrupted, so it's carried on by running copy again", jobID))
+
+	cmd := exec.Command(azcopy, append(args, "--"+ContinueJobFlag+"="+jobID.String())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		glcm.Exit(nil, common.ExitCode(exitErr.ExitCode()))
+	} else if err != nil {
+		return err
+	}
+	glcm.Exit(nil, common.EExitCode.Success())
+	return nil
+}
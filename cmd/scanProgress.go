@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// scanProgressInterval is how often the scan's progress is shown before the job's own progress takes over
+const scanProgressInterval = 2 * time.Second
+
+// scanProgressMaxPathLength is how much of the current path is shown on the console, so that the progress still fits
+// on one line
+const scanProgressMaxPathLength = 60
+
+// scanProgress counts what a job's scan finds, so that the long scan of a large tree isn't silent. Its methods may be
+// called on a nil scanProgress, and do nothing.
+type scanProgress struct {
+	atomicFiles   uint64
+	atomicFolders uint64
+	currentPath   atomic.Pointer[string]
+
+	mu          sync.Mutex // guards what the rates are measured from
+	lastFiles   uint64
+	lastFolders uint64
+	lastTime    time.Time
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newScanProgress() *scanProgress {
+	return &scanProgress{lastTime: time.Now(), done: make(chan struct{})}
+}
+
+// found counts a file or folder that the traverser has found, before filtering
+func (p *scanProgress) found(entityType common.EntityType) {
+	if p == nil {
+		return
+	}
+	if entityType == common.EEntityType.Folder() {
+		atomic.AddUint64(&p.atomicFolders, 1)
+	} else {
+		atomic.AddUint64(&p.atomicFiles, 1)
+	}
+}
+
+// tracking has processor record where the scan has got to, as each object reaches it
+func (p *scanProgress) tracking(processor objectProcessor) objectProcessor {
+	if p == nil {
+		return processor
+	}
+	return func(object StoredObject) error {
+		path := object.relativePath
+		p.currentPath.Store(&path)
+		return processor(object)
+	}
+}
+
+// report is the scan's progress, with the rates measured since the previous report
+func (p *scanProgress) report(now time.Time) common.ScanProgress {
+	r := common.ScanProgress{
+		FilesFound:   atomic.LoadUint64(&p.atomicFiles),
+		FoldersFound: atomic.LoadUint64(&p.atomicFolders),
+	}
+	if path := p.currentPath.Load(); path != nil {
+		r.CurrentPath = *path
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elapsed := now.Sub(p.lastTime).Seconds(); elapsed > 0 {
+		r.FilesPerSecond = float64(r.FilesFound-p.lastFiles) / elapsed
+		r.FoldersPerSecond = float64(r.FoldersFound-p.lastFolders) / elapsed
+	}
+	p.lastFiles, p.lastFolders, p.lastTime = r.FilesFound, r.FoldersFound, now
+	return r
+}
+
+// startReporting shows the scan's progress every scanProgressInterval until stopReporting is called, for the time
+// before the first part of the job has been sent and the job's own progress can be shown
+func (p *scanProgress) startReporting(lcm common.LifecycleMgr, jobID common.JobID) {
+	if p == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(scanProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case now := <-ticker.C:
+				r := p.report(now)
+				lcm.Progress(func(format common.OutputFormat) string {
+					if format == common.EOutputFormat.Json() {
+						// in the form of the job's progress, which has only just started
+						jsonOutput, err := json.Marshal(common.ListJobSummaryResponse{JobID: jobID, JobStatus: common.EJobStatus.InProgress(), ScanProgress: &r})
+						common.PanicIfErr(err)
+						return string(jsonOutput)
+					}
+					return "Scanning: " + describeScanProgress(r)
+				})
+			}
+		}
+	}()
+}
+
+func (p *scanProgress) stopReporting() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.done) })
+}
+
+// describeScanProgress is the scan's progress in a line of the console's progress
+func describeScanProgress(r common.ScanProgress) string {
+	return fmt.Sprintf("%v files and %v folders found, %s", r.FilesFound, r.FoldersFound, describeScanRate(r))
+}
+
+// describeScanRate is how quickly the scan is finding files, and where it's got to
+func describeScanRate(r common.ScanProgress) string {
+	s := fmt.Sprintf("%.0f files/s", r.FilesPerSecond)
+	if r.CurrentPath != "" {
+		path := []rune(r.CurrentPath)
+		if len(path) > scanProgressMaxPathLength {
+			path = append([]rune("..."), path[len(path)-scanProgressMaxPathLength+3:]...)
+		}
+		s += ", at " + string(path)
+	}
+	return s
+}
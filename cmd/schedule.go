@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/spf13/cobra"
+)
+
+// formatScheduleStatus is a line of 'azcopy schedule list'
+func formatScheduleStatus(s scheduleStatus) string {
+	next := "never"
+	if !s.NextRun.IsZero() {
+		next = s.NextRun.Format(time.RFC3339)
+	}
+	last := "not run yet"
+	if s.LastJob != 0 {
+		last = fmt.Sprintf("job %d at %s: %s", s.LastJob, s.LastRun.Format(time.RFC3339), s.LastState)
+	}
+	if s.SkippedRuns > 0 {
+		last += fmt.Sprintf(" (%d runs skipped)", s.SkippedRuns)
+	}
+	return fmt.Sprintf("%s\t%s\tnext %s\tlast %s\t%s", s.Name, s.Schedule, next, last, strings.Join(s.Args, " "))
+}
+
+// nextCronRuns is the next count times that expr runs after now
+func nextCronRuns(expr string, now time.Time, count int) ([]time.Time, error) {
+	c, err := parseCronSchedule(expr)
+	if err != nil {
+		return nil, err
+	}
+	var runs []time.Time
+	for t := c.next(now); !t.IsZero() && len(runs) < count; t = c.next(t) {
+		runs = append(runs, t)
+	}
+	return runs, nil
+}
+
+func init() {
+	var socket string
+	var count int
+
+	scheduleCmd := &cobra.Command{
+		Use:     "schedule",
+		Short:   scheduleCmdShortDescription,
+		Long:    scheduleCmdLongDescription,
+		Example: scheduleCmdExample,
+	}
+	rootCmd.AddCommand(scheduleCmd)
+
+	scheduleCmd.PersistentFlags().StringVar(&socket, "socket", "",
+		"Path of the daemon's socket. Defaults to /var/run/azcopy.sock for root, and azcopy-daemon.sock in the log folder for anyone else.")
+
+	socketPath := func() string {
+		if socket == "" {
+			return defaultDaemonPath("sock")
+		}
+		return socket
+	}
+
+	scheduleCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: scheduleListCmdShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := callDaemon(socketPath(), daemonRequest{Command: "schedules"})
+			if err != nil {
+				glcm.Error("failed to get the daemon's schedules: " + err.Error())
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					buf, _ := json.Marshal(resp.Schedules)
+					return string(buf)
+				}
+				if len(resp.Schedules) == 0 {
+					return "The daemon has no schedules"
+				}
+				lines := make([]string, len(resp.Schedules))
+				for i, s := range resp.Schedules {
+					lines[i] = formatScheduleStatus(s)
+				}
+				return strings.Join(lines, "\n")
+			}, common.EExitCode.Success())
+		},
+	})
+
+	scheduleCmd.AddCommand(&cobra.Command{
+		Use:   "run [schedule name]",
+		Short: scheduleRunCmdShortDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := callDaemon(socketPath(), daemonRequest{Command: "run-schedule", Schedule: args[0]})
+			if err != nil {
+				glcm.Error(fmt.Sprintf("failed to run schedule %s: %s", args[0], err))
+			}
+			job := resp.Jobs[0]
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					buf, _ := json.Marshal(job)
+					return string(buf)
+				}
+				return fmt.Sprintf("Submitted as daemon job %d; its output goes to %s", job.ID, job.LogFile)
+			}, common.EExitCode.Success())
+		},
+	})
+
+	nextCmd := &cobra.Command{
+		Use:   "next [schedule]",
+		Short: scheduleNextCmdShortDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runs, err := nextCronRuns(args[0], time.Now(), count)
+			if err != nil {
+				glcm.Error("invalid schedule: " + err.Error())
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					buf, _ := json.Marshal(runs)
+					return string(buf)
+				}
+				if len(runs) == 0 {
+					return "The schedule never runs"
+				}
+				lines := make([]string, len(runs))
+				for i, t := range runs {
+					lines[i] = t.Format("Mon " + time.RFC3339)
+				}
+				return strings.Join(lines, "\n")
+			}, common.EExitCode.Success())
+		},
+	}
+	nextCmd.Flags().IntVar(&count, "count", 5, "How many runs to show.")
+	scheduleCmd.AddCommand(nextCmd)
+}
@@ -147,7 +147,7 @@ func init() {
 			err = cooked.process()
 
 			if err != nil {
-				glcm.Error("failed to perform set-properties command due to error: " + err.Error())
+				glcm.ErrorWithExitCode("failed to perform set-properties command due to error: "+err.Error(), errorExitCode(err))
 			}
 
 			if cooked.dryrunMode {
@@ -192,4 +192,12 @@ func init() {
 		"\n If this flag is set to 'Disable' and AzCopy encounters a trailing dot file, it will warn customers in the scanning log but will not attempt to abort the operation."+
 		"\n If the destination does not support trailing dot files (Windows or Blob Storage), "+
 		"\n AzCopy will fail if the trailing dot file is the root of the transfer and skip any trailing dot paths encountered during enumeration.")
+	// Metadata of a blob encrypted with a customer provided key is encrypted too, so it can only be set with the key.
+	setPropCmd.PersistentFlags().StringVar(&raw.cpkScopeInfo, "cpk-by-name", "", "Client provided key by name let clients making requests against Azure Blob storage "+
+		"\n an option to provide an encryption key on a per-request basis. "+
+		"\n Provided key name will be used to set the metadata of blobs encrypted with it.")
+	setPropCmd.PersistentFlags().BoolVar(&raw.cpkInfo, "cpk-by-value", false, "False by default. "+
+		"\n Client provided key by value let clients making requests against "+
+		"\n Azure Blob storage an option to provide an encryption key on a per-request basis. "+
+		"\n Provided key and its hash will be fetched from environment variables (CPK_ENCRYPTION_KEY and CPK_ENCRYPTION_KEY_SHA256 must be set).")
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+// drainJob stops jobID from starting new transfers, so that the ones in flight can finish before the job is cancelled.
+// There's no point in that unless the job can be resumed afterwards, i.e. if its enumeration is complete.
+// It is only called if the user has opted in, by setting AZCOPY_SHUTDOWN_GRACE_PERIOD; otherwise the job is cancelled
+// straight away, as it always was.
+func drainJob(jobID common.JobID, resumable bool) bool {
+	jm, ok := jobMgr(jobID)
+	if !resumable || !ok {
+		return false
+	}
+
+	jm.TransferGate().Drain()
+	jm.Log(common.LogWarning, "Shutdown requested. No new transfers will be started, and those in progress will be cancelled if they don't finish in time.")
+	return true
+}
+
+func jobDrained(jobID common.JobID) bool {
+	jm, ok := jobsAdmin.JobsAdmin.JobMgr(jobID)
+	return !ok || jm.TransfersInFlight() == 0
+}
+
+// interruptedExitCode returns EExitCode.Interrupted, after flushing the job's plan files (and so the offsets of any
+// partly saved downloads), if the job was cancelled after being drained. Otherwise it returns exitCode unchanged.
+func interruptedExitCode(jobID common.JobID, status common.JobStatus, interrupted bool, exitCode common.ExitCode) common.ExitCode {
+	if !interrupted || (status != common.EJobStatus.Cancelled() && status != common.EJobStatus.Cancelling()) {
+		return exitCode
+	}
+
+	if jm, ok := jobsAdmin.JobsAdmin.JobMgr(jobID); ok {
+		if err := jm.FlushPlans(); err != nil {
+			jm.Log(common.LogError, "Failed to flush the plan files while shutting down: "+err.Error())
+			return exitCode
+		}
+		jm.Log(common.LogWarning, "Job was shut down cleanly. Run 'azcopy jobs resume "+jobID.String()+"' to finish it.")
+	}
+	return common.EExitCode.Interrupted()
+}
+
+func (cca *CookedCopyCmdArgs) Drain(lcm common.LifecycleMgr) bool {
+	cca.interrupted = drainJob(cca.jobID, cca.isEnumerationComplete)
+	return cca.interrupted
+}
+func (cca *CookedCopyCmdArgs) Drained() bool { return jobDrained(cca.jobID) }
+
+func (cca *cookedSyncCmdArgs) Drain(lcm common.LifecycleMgr) bool {
+	cca.interrupted = drainJob(cca.jobID, cca.isEnumerationComplete)
+	return cca.interrupted
+}
+func (cca *cookedSyncCmdArgs) Drained() bool { return jobDrained(cca.jobID) }
+
+func (cca *resumeJobController) Drain(lcm common.LifecycleMgr) bool {
+	cca.interrupted = drainJob(cca.jobID, true)
+	return cca.interrupted
+}
+func (cca *resumeJobController) Drained() bool { return jobDrained(cca.jobID) }
+
+// once the watcher is draining, it won't start another round
+func (w *syncWatcher) Drain(lcm common.LifecycleMgr) bool {
+	w.mu.Lock()
+	w.stopping = true
+	w.unpaused.Broadcast()
+	round := w.round
+	w.mu.Unlock()
+
+	return round != nil && round.Drain(lcm)
+}
+
+func (w *syncWatcher) Drained() bool {
+	w.mu.Lock()
+	round := w.round
+	w.mu.Unlock()
+
+	return round == nil || round.Drained()
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// specialFileReport names the FIFOs, sockets and device nodes a job left out, for its summary.
+// They are counted separately, in atomicSkippedSpecialFileCount, since only the first common.MaxListedSpecialFiles are kept here.
+type specialFileReport struct {
+	mu    sync.Mutex
+	files []common.SkippedSpecialFile
+}
+
+func (r *specialFileReport) add(path, kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.files) < common.MaxListedSpecialFiles {
+		r.files = append(r.files, common.SkippedSpecialFile{Path: path, Kind: kind})
+	}
+}
+
+func (r *specialFileReport) list() []common.SkippedSpecialFile {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]common.SkippedSpecialFile(nil), r.files...)
+}
+
+// maxSpecialFilesOnScreen is how many skipped special files the text summary names; the JSON summary has them all
+const maxSpecialFilesOnScreen = 20
+
+// formatSpecialFiles lists skipped special files under the count in the text summary.
+func formatSpecialFiles(files []common.SkippedSpecialFile, skipped uint32) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, f := range files {
+		if i == maxSpecialFilesOnScreen {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n    %s (%s)", f.Path, f.Kind))
+	}
+	if shown := min(len(files), maxSpecialFilesOnScreen); uint32(shown) < skipped {
+		sb.WriteString(fmt.Sprintf("\n    ...and %d more", skipped-uint32(shown)))
+	}
+	return sb.String()
+}
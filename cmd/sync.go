@@ -67,11 +67,29 @@ type rawSyncCmdArgs struct {
 	preserveOwner           bool
 	preserveSMBInfo         bool
 	preservePOSIXProperties bool
+	preserveACLs            bool
+	preserveExtAttrs        bool
+	preserveFileFlags       bool
 	followSymlinks          bool
 	preserveSymlinks        bool
+	symlinks                string
+	oneFileSystem           bool
+	excludeNodump           bool
+	specialFiles            string
+	order                   string
+	traversal               string
 	backupMode              bool
+	preallocate             bool
+	bypassCache             string
+	aioWrites               bool
+	lockFiles               bool
+	prefetchChunks          uint32
+	mmapUploads             bool
+	scanConcurrency         uint32
 	putMd5                  bool
 	md5ValidationOption     string
+	fsync                   string
+	hashAlgorithm           string
 	includeRoot             bool
 	// this flag indicates the user agreement with respect to deleting the extra files at the destination
 	// which do not exists at source. With this flag turned on/off, users will not be asked for permission.
@@ -95,15 +113,39 @@ type rawSyncCmdArgs struct {
 	// Key is present in AzureKeyVault and Azure KeyVault is linked with storage account.
 	// Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data
 	cpkScopeInfo string
+	// Encryption scope that uploaded or copied blobs are encrypted with
+	encryptionScope string
+	// Opt-in flag to encrypt copied blobs with the encryption scope of their source
+	s2sPreserveEncryptionScope bool
 	// dry run mode bool
 	dryrun      bool
 	trailingDot string
 
+	transferReport       string
+	transferReportFormat string
+	notifyWebhook        string
+	notifyEmail          string
+	preHook              string
+	postHook             string
+
 	// when specified, AzCopy deletes the destination blob that has uncommitted blocks, not just the uncommitted blocks
 	deleteDestinationFileIfNecessary bool
 	// Opt-in flag to persist additional properties to Azure Files
 	preserveInfo bool
 	hardlinks    string
+
+	// keep running after the initial sync, pushing local changes as they happen
+	watch          bool
+	watchDebounce  time.Duration
+	watchBatchSize int
+
+	lockDestination bool
+	syncStateCache  string
+	changeFeed      bool
+	fromInventory   string
+	blockDelta      bool
+	// split files into blocks where their content says, for --block-delta, which it implies
+	contentDefinedBlocks bool
 }
 
 // it is assume that the given url has the SAS stripped, and safe to print
@@ -123,19 +165,45 @@ func validateURLIsNotServiceLevel(url string, location common.Location) error {
 func (raw rawSyncCmdArgs) toOptions() (cooked cookedSyncCmdArgs, err error) {
 	cooked = cookedSyncCmdArgs{
 		dryrunMode:                       raw.dryrun,
+		transferReportPath:               raw.transferReport,
+		transferReportFormat:             raw.transferReportFormat,
+		notifyWebhook:                    raw.notifyWebhook,
+		notifyEmail:                      raw.notifyEmail,
+		preHook:                          raw.preHook,
+		postHook:                         raw.postHook,
 		blockSizeMB:                      raw.blockSizeMB,
 		putBlobSizeMB:                    raw.putBlobSizeMB,
 		recursive:                        raw.recursive,
 		forceIfReadOnly:                  raw.forceIfReadOnly,
 		backupMode:                       raw.backupMode,
+		preallocate:                      raw.preallocate,
+		bypassCache:                      raw.bypassCache,
+		aioWrites:                        raw.aioWrites,
+		lockFiles:                        raw.lockFiles,
+		prefetchChunks:                   raw.prefetchChunks,
+		mmapUploads:                      raw.mmapUploads,
+		scanConcurrency:                  raw.scanConcurrency,
+		oneFileSystem:                    raw.oneFileSystem,
+		excludeNodump:                    raw.excludeNodump,
+		skippedSpecialFiles:              &specialFileReport{},
 		putMd5:                           raw.putMd5,
 		s2sPreserveBlobTags:              raw.s2sPreserveBlobTags,
 		cpkByName:                        raw.cpkScopeInfo,
 		cpkByValue:                       raw.cpkInfo,
+		encryptionScope:                  raw.encryptionScope,
+		s2sPreserveEncryptionScope:       raw.s2sPreserveEncryptionScope,
 		mirrorMode:                       raw.mirrorMode,
 		deleteDestinationFileIfNecessary: raw.deleteDestinationFileIfNecessary,
 		includeDirectoryStubs:            raw.includeDirectoryStubs,
 		includeRoot:                      raw.includeRoot,
+		watch:                            raw.watch,
+		watchDebounce:                    raw.watchDebounce,
+		watchBatchSize:                   raw.watchBatchSize,
+		lockDestination:                  raw.lockDestination,
+		changeFeed:                       raw.changeFeed,
+		fromInventory:                    raw.fromInventory,
+		blockDelta:                       raw.blockDelta || raw.contentDefinedBlocks,
+		contentDefinedBlocks:             raw.contentDefinedBlocks,
 	}
 	err = cooked.trailingDot.Parse(raw.trailingDot)
 	if err != nil {
@@ -160,6 +228,8 @@ func (raw rawSyncCmdArgs) toOptions() (cooked cookedSyncCmdArgs, err error) {
 		common.PanicIfErr(err)
 		cooked.source, err = SplitResourceString(raw.src, cooked.fromTo.From())
 		common.PanicIfErr(err)
+	case common.EFromTo.LocalLocal():
+		// both sides are set below
 	default:
 		return cooked, fmt.Errorf("source '%s' / destination '%s' combination '%s' not supported for sync command ", raw.src, raw.dst, cooked.fromTo)
 	}
@@ -167,11 +237,12 @@ func (raw rawSyncCmdArgs) toOptions() (cooked cookedSyncCmdArgs, err error) {
 	// Do this check separately so we don't end up with a bunch of code duplication when new src/dstn are added
 	if cooked.fromTo.From() == common.ELocation.Local() {
 		cooked.source = common.ResourceString{Value: common.ToExtendedPath(cleanLocalPath(raw.src))}
-	} else if cooked.fromTo.To() == common.ELocation.Local() {
+	}
+	if cooked.fromTo.To() == common.ELocation.Local() {
 		cooked.destination = common.ResourceString{Value: common.ToExtendedPath(cleanLocalPath(raw.dst))}
 	}
 
-	if err = cooked.symlinkHandling.Determine(raw.followSymlinks, raw.preserveSymlinks); err != nil {
+	if err = cooked.symlinkHandling.Determine(raw.followSymlinks, raw.preserveSymlinks, raw.symlinks); err != nil {
 		return cooked, err
 	}
 
@@ -210,9 +281,31 @@ func (raw rawSyncCmdArgs) toOptions() (cooked cookedSyncCmdArgs, err error) {
 	} else {
 		cooked.preserveInfo = raw.preserveInfo && areBothLocationsSMBAware(cooked.fromTo)
 		cooked.preservePOSIXProperties = raw.preservePOSIXProperties
+		cooked.preserveACLs = raw.preserveACLs
+		cooked.preserveExtAttrs = raw.preserveExtAttrs
+		cooked.preserveFileFlags = raw.preserveFileFlags
 		cooked.preservePermissions = common.NewPreservePermissionsOption(raw.preservePermissions,
 			raw.preserveOwner,
 			cooked.fromTo)
+		if err = cooked.hardlinks.Parse(raw.hardlinks); err != nil {
+			return cooked, err
+		}
+	}
+
+	if err = cooked.specialFiles.Parse(raw.specialFiles); err != nil {
+		return cooked, err
+	}
+
+	if err = cooked.order.Parse(raw.order); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use as-scanned, smallest-first or largest-first", OrderFlag, raw.order)
+	}
+
+	if err = cooked.traversal.Parse(raw.traversal); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use auto, breadth-first or depth-first", TraversalFlag, raw.traversal)
+	}
+
+	if err = cooked.syncStateCache.Parse(raw.syncStateCache); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use none, use or refresh", SyncStateCacheFlag, raw.syncStateCache)
 	}
 
 	if err = cooked.compareHash.Parse(raw.compareHash); err != nil {
@@ -234,6 +327,14 @@ func (raw rawSyncCmdArgs) toOptions() (cooked cookedSyncCmdArgs, err error) {
 	if err != nil {
 		return cooked, err
 	}
+	if err = cooked.fsync.Parse(raw.fsync); err != nil {
+		return cooked, fmt.Errorf("invalid --%s value '%s': use per-file, per-chunk, interval:N or off", FsyncFlag, raw.fsync)
+	}
+	if raw.hashAlgorithm != "" {
+		if err = cooked.hashAlgorithm.Parse(raw.hashAlgorithm); err != nil {
+			return cooked, err
+		}
+	}
 
 	if cooked.fromTo.IsS2S() {
 		cooked.preserveAccessTier = raw.s2sPreserveAccessTier
@@ -279,10 +380,75 @@ func (cooked *cookedSyncCmdArgs) validate() (err error) {
 		return err
 	}
 
+	if err = validateOneFileSystem(cooked.oneFileSystem, cooked.fromTo); err != nil {
+		return err
+	}
+
+	if err = validateExcludeNodump(cooked.excludeNodump, cooked.fromTo); err != nil {
+		return err
+	}
+
 	if err = validateBackupMode(cooked.backupMode, cooked.fromTo); err != nil {
 		return err
 	}
 
+	if err = validateDownloadTempDir(cooked.fromTo, cooked.destination); err != nil {
+		return err
+	}
+
+	if cooked.watch {
+		if cooked.fromTo.From() != common.ELocation.Local() {
+			return errors.New("--watch is only supported when syncing from a local directory")
+		}
+		if cooked.dryrunMode {
+			return errors.New("--watch cannot be combined with --dry-run")
+		}
+		if cooked.watchBatchSize <= 0 {
+			return errors.New("--watch-batch-size must be greater than zero")
+		}
+	}
+
+	if cooked.syncStateCache != common.ESyncStateCache.None() {
+		if !cooked.fromTo.IsUpload() {
+			return fmt.Errorf("--%s is only supported when syncing from a local directory", SyncStateCacheFlag)
+		}
+		if cooked.watch {
+			return fmt.Errorf("--%s cannot be combined with --watch", SyncStateCacheFlag)
+		}
+		if cooked.compareHash != common.ESyncHashType.None() {
+			return fmt.Errorf("--%s cannot be combined with --compare-hash, since the hashes at the destination aren't recorded", SyncStateCacheFlag)
+		}
+	}
+
+	if cooked.changeFeed {
+		if cooked.fromTo.From() != common.ELocation.Blob() {
+			return fmt.Errorf("--%s is only supported when syncing from Blob storage", ChangeFeedFlag)
+		}
+		if cooked.watch {
+			return fmt.Errorf("--%s cannot be combined with --watch", ChangeFeedFlag)
+		}
+	}
+
+	if cooked.fromInventory != "" {
+		if cooked.fromTo.From() != common.ELocation.Blob() {
+			return fmt.Errorf("--%s is only supported when syncing from Blob storage", FromInventoryFlag)
+		}
+		if cooked.deleteDestination != common.EDeleteDestination.False() {
+			return fmt.Errorf("--%s cannot be combined with --delete-destination, since blobs made since the inventory would be deleted from the destination", FromInventoryFlag)
+		}
+		if cooked.changeFeed {
+			return fmt.Errorf("--%s cannot be combined with --%s", FromInventoryFlag, ChangeFeedFlag)
+		}
+		if cooked.s2sPreserveBlobTags {
+			return fmt.Errorf("--%s cannot be combined with --s2s-preserve-blob-tags, since blob tags aren't read from the inventory", FromInventoryFlag)
+		}
+	}
+
+	if cooked.blockDelta && cooked.fromTo != common.EFromTo.LocalBlob() {
+		return fmt.Errorf("--%s is only supported when syncing from a local directory to Blob storage",
+			common.Iff(cooked.contentDefinedBlocks, ContentDefinedBlocksFlag, BlockDeltaFlag))
+	}
+
 	// NFS/SMB validation
 	if common.IsNFSCopy() {
 		if err := performNFSSpecificValidation(
@@ -296,6 +462,27 @@ func (cooked *cookedSyncCmdArgs) validate() (err error) {
 			cooked.preservePOSIXProperties, cooked.hardlinks); err != nil {
 			return err
 		}
+
+		if err = validateSymlinkHandlingMode(cooked.symlinkHandling, cooked.fromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveACLs(cooked.preserveACLs, cooked.fromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveExtAttrs(cooked.preserveExtAttrs, cooked.fromTo); err != nil {
+			return err
+		}
+
+		if err = validatePreserveFileFlags(cooked.preserveFileFlags, cooked.fromTo); err != nil {
+			return err
+		}
+
+		// the links a download finds are only made by copy, once its job is done
+		if cooked.hardlinks == common.EHardlinkHandlingType.Preserve() && cooked.fromTo != common.EFromTo.LocalBlob() {
+			return errors.New("sync only supports --hardlinks=preserve when uploading to Blob storage; use copy to download hard links")
+		}
 	}
 
 	if err = validatePutMd5(cooked.putMd5, cooked.fromTo); err != nil {
@@ -305,6 +492,16 @@ func (cooked *cookedSyncCmdArgs) validate() (err error) {
 	if err = validateMd5Option(cooked.md5ValidationOption, cooked.fromTo); err != nil {
 		return err
 	}
+	if err = validateFsync(cooked.fsync, cooked.fromTo); err != nil {
+		return err
+	}
+
+	if err = validateHashAlgorithm(cooked.hashAlgorithm, cooked.putMd5, cooked.fromTo); err != nil {
+		return err
+	}
+	if cooked.hashAlgorithm != common.EContentHashType.MD5() && cooked.compareHash == common.ESyncHashType.MD5() {
+		return errors.New("--compare-hash MD5 compares the MD5 hashes in Content-MD5, so it can't be used with another --hash-algorithm")
+	}
 
 	// Check if user has provided `s2s-preserve-blob-tags` flag.
 	// If yes, we have to ensure that both source and destination must be blob storage.
@@ -317,6 +514,10 @@ func (cooked *cookedSyncCmdArgs) validate() (err error) {
 		return errors.New("cannot use both cpk-by-name and cpk-by-value at the same time")
 	}
 
+	if err = validateEncryptionScope(cooked.encryptionScope, cooked.s2sPreserveEncryptionScope, cooked.cpkByName, cooked.cpkByValue, cooked.fromTo); err != nil {
+		return err
+	}
+
 	if OutputLevel == common.EOutputVerbosity.Quiet() || OutputLevel == common.EOutputVerbosity.Essential() {
 		if cooked.deleteDestination == common.EDeleteDestination.Prompt() {
 			err = fmt.Errorf("cannot set output level '%s' with delete-destination option '%s'", OutputLevel.String(), cooked.deleteDestination.String())
@@ -344,6 +545,17 @@ func (cooked *cookedSyncCmdArgs) processArgs() (err error) {
 		common.LogPathFolder = ""
 	}
 
+	if err = registerJobRetention(); err != nil {
+		return err
+	}
+	if err = startTransferReport(cooked.transferReportPath, cooked.transferReportFormat); err != nil {
+		return err
+	}
+	if err = startNotifications("sync", cooked.source.Value, cooked.destination.Value, cooked.notifyWebhook, cooked.notifyEmail); err != nil {
+		return err
+	}
+	setUpHooks("sync", cooked.source.Value, cooked.destination.Value, cooked.preHook, cooked.postHook)
+
 	// display a warning message to console and job log file if there is a sync operation being performed from local to file share.
 	// Reference : https://learn.microsoft.com/en-us/azure/storage/common/storage-use-azcopy-files#synchronize-files
 	if cooked.fromTo == common.EFromTo.LocalFile() {
@@ -391,6 +603,12 @@ func (cooked *cookedSyncCmdArgs) processArgs() (err error) {
 		glcm.Info("Client Provided Key for encryption/decryption is provided for download scenario. " +
 			"Assuming source is encrypted.")
 		cooked.cpkOptions.IsSourceEncrypted = true
+	} else if cooked.fromTo.IsS2S() && (cooked.cpkOptions.CpkScopeInfo != "" || cooked.cpkOptions.CpkInfo) {
+		glcm.Info(CpkS2SDestinationOnlyMsg)
+	}
+	// an encryption scope is a key by name that only applies to what is written
+	if cooked.encryptionScope != "" {
+		cooked.cpkOptions.CpkScopeInfo = cooked.encryptionScope
 	}
 
 	return nil
@@ -414,6 +632,9 @@ type cookedSyncCmdArgs struct {
 	// deletion count keeps track of how many extra files from the destination were removed
 	atomicDeletionCount uint32
 
+	// what the scans of the source and destination have found so far, with how quickly they're finding it
+	scanProgress *scanProgress
+
 	source                  common.ResourceString
 	destination             common.ResourceString
 	fromTo                  common.FromTo
@@ -423,6 +644,8 @@ type cookedSyncCmdArgs struct {
 	// filters
 	recursive             bool
 	symlinkHandling       common.SymlinkHandlingType
+	oneFileSystem         bool
+	excludeNodump         bool
 	includePatterns       []string
 	excludePatterns       []string
 	excludePaths          []string
@@ -436,12 +659,24 @@ type cookedSyncCmdArgs struct {
 	preservePermissions     common.PreservePermissionsOption
 	preserveInfo            bool
 	preservePOSIXProperties bool
+	preserveACLs            bool
+	preserveExtAttrs        bool
+	preserveFileFlags       bool
 	putMd5                  bool
 	md5ValidationOption     common.HashValidationOption
+	hashAlgorithm           common.ContentHashType
+	fsync                   common.FsyncPolicy
 	blockSize               int64
 	putBlobSize             int64
 	forceIfReadOnly         bool
 	backupMode              bool
+	preallocate             bool
+	bypassCache             string
+	aioWrites               bool
+	lockFiles               bool
+	prefetchChunks          uint32
+	mmapUploads             bool
+	scanConcurrency         uint32
 	includeDirectoryStubs   bool
 	includeRoot             bool
 
@@ -466,6 +701,9 @@ type cookedSyncCmdArgs struct {
 	// this is set to true once the final part has been dispatched
 	isEnumerationComplete bool
 
+	// set once we start shutting the job down cleanly, so that it exits with EExitCode.Interrupted (see drainJob)
+	interrupted bool
+
 	// this flag indicates the user agreement with respect to deleting the extra files at the destination
 	// which do not exists at source. With this flag turned on/off, users will not be asked for permission.
 	// otherwise the user is prompted to make a decision
@@ -482,15 +720,60 @@ type cookedSyncCmdArgs struct {
 	dryrunMode  bool
 	trailingDot common.TrailingDotOption
 
+	// file to write a line to for each transfer, in transferReportFormat (see transferReport.go)
+	transferReportPath   string
+	transferReportFormat string
+
+	// where to send the outcome of each job (see notify.go)
+	notifyWebhook string
+	notifyEmail   string
+
+	// commands to run before and after each job (see jobHooks.go)
+	preHook  string
+	postHook string
+
 	deleteDestinationFileIfNecessary bool
 	hardlinks                        common.HardlinkHandlingType
 	atomicSkippedSymlinkCount        uint32
 	atomicSkippedSpecialFileCount    uint32
+	atomicSkippedNodumpCount         uint32
+	specialFiles                     common.SpecialFileHandlingType
+	order                            common.TransferOrder
+	traversal                        common.TraversalOrder
+	skippedSpecialFiles              *specialFileReport
 
 	blockSizeMB   float64
 	putBlobSizeMB float64
 	cpkByName     string
 	cpkByValue    bool
+
+	encryptionScope            string
+	s2sPreserveEncryptionScope bool
+
+	watch          bool
+	watchDebounce  time.Duration
+	watchBatchSize int
+	// set on each job run by `sync --watch`, which takes over when the job finishes instead of exiting
+	watcher *syncWatcher
+
+	// keep other syncs to the destination out while this one runs (see destinationLock.go)
+	lockDestination bool
+
+	syncStateCache common.SyncStateCache
+	// the record of the state of the destination, kept with --sync-state-cache (see syncStateCache.go)
+	syncState *syncStateCache
+
+	changeFeed bool
+	// where the last sync left the source's change feed, with --change-feed (see blobChangeFeed.go)
+	changeFeedState *changeFeedSync
+
+	// the manifest of the blob inventory to list the source from, with --from-inventory (see blobInventory.go)
+	fromInventory string
+
+	// upload only the blocks of each file that the destination blob doesn't have already
+	blockDelta bool
+	// and split files into blocks where their content says, instead of every blockSize bytes
+	contentDefinedBlocks bool
 }
 
 func (cca *cookedSyncCmdArgs) incrementDeletionCount() {
@@ -537,6 +820,7 @@ func (cca *cookedSyncCmdArgs) waitUntilJobCompletion(blocking bool) {
 	cca.jobStartTime = time.Now()
 	cca.intervalStartTime = time.Now()
 	cca.intervalBytesTransferred = 0
+	startLiveProgress(cca.jobID)
 
 	// hand over control to the lifecycle manager if blocking
 	if blocking {
@@ -576,6 +860,7 @@ func (cca *cookedSyncCmdArgs) Cancel(lcm common.LifecycleMgr) {
 type scanningProgressJsonTemplate struct {
 	FilesScannedAtSource      uint64
 	FilesScannedAtDestination uint64
+	common.ScanProgress
 }
 
 func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, throughput float64) {
@@ -583,11 +868,16 @@ func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, th
 	lcm.Progress(func(format common.OutputFormat) string {
 		srcScanned := atomic.LoadUint64(&cca.atomicSourceFilesScanned)
 		dstScanned := atomic.LoadUint64(&cca.atomicDestinationFilesScanned)
+		var scan common.ScanProgress
+		if cca.scanProgress != nil {
+			scan = cca.scanProgress.report(time.Now())
+		}
 
 		if format == common.EOutputFormat.Json() {
 			jsonOutputTemplate := scanningProgressJsonTemplate{
 				FilesScannedAtSource:      srcScanned,
 				FilesScannedAtDestination: dstScanned,
+				ScanProgress:              scan,
 			}
 			outputString, err := json.Marshal(jsonOutputTemplate)
 			common.PanicIfErr(err)
@@ -599,8 +889,8 @@ func (cca *cookedSyncCmdArgs) reportScanningProgress(lcm common.LifecycleMgr, th
 		if cca.firstPartOrdered() {
 			throughputString = fmt.Sprintf(", 2-sec Throughput (Mb/s): %v", jobsAdmin.ToFixed(throughput, 4))
 		}
-		return fmt.Sprintf("%v Files Scanned at Source, %v Files Scanned at Destination%s",
-			srcScanned, dstScanned, throughputString)
+		return fmt.Sprintf("%v Files Scanned at Source, %v Files Scanned at Destination, %s%s",
+			srcScanned, dstScanned, describeScanRate(scan), throughputString)
 	})
 }
 
@@ -660,15 +950,20 @@ func (cca *cookedSyncCmdArgs) ReportProgressOrExit(lcm common.LifecycleMgr) (tot
 	})
 
 	if jobDone {
-		exitCode := common.EExitCode.Success()
-		if summary.TransfersFailed > 0 || summary.JobStatus == common.EJobStatus.Cancelled() || summary.JobStatus == common.EJobStatus.Cancelling() {
-			exitCode = common.EExitCode.Error()
-		}
+		exitCode := jobExitCode(summary)
+		exitCode = interruptedExitCode(cca.jobID, summary.JobStatus, cca.interrupted, exitCode)
 
 		summary.SkippedSymlinkCount = atomic.LoadUint32(&cca.atomicSkippedSymlinkCount)
 		summary.SkippedSpecialFileCount = atomic.LoadUint32(&cca.atomicSkippedSpecialFileCount)
+		summary.SkippedNodumpCount = atomic.LoadUint32(&cca.atomicSkippedNodumpCount)
+		summary.SkippedSpecialFiles = cca.skippedSpecialFiles.list()
 
-		lcm.Exit(func(format common.OutputFormat) string {
+		recordJobHistory(summary, cca.fromTo, cca.jobStartTime)
+		finishTransferReport()
+		cca.syncState.save(summary.JobStatus == common.EJobStatus.Completed())
+		cca.changeFeedState.save(summary.JobStatus == common.EJobStatus.Completed())
+
+		builder := func(format common.OutputFormat) string {
 			if format == common.EOutputFormat.Json() {
 				return cca.getJsonOfSyncJobSummary(summary)
 			}
@@ -687,10 +982,10 @@ Number of Copy Transfers Completed: %v
 Number of Copy Transfers Failed: %v
 Number of Deletions at Destination: %v
 Number of Symbolic Links Skipped: %v
-Number of Special Files Skipped: %v
+Number of Special Files Skipped: %v%s
 Number of Hardlinks Converted: %v
 Total Number of Bytes Transferred: %v
-Total Number of Bytes Enumerated: %v
+Total Number of Bytes Enumerated: %v%s
 Final Job Status: %v%s%s
 `,
 				summary.JobID.String(),
@@ -705,9 +1000,11 @@ Final Job Status: %v%s%s
 				cca.atomicDeletionCount,
 				summary.SkippedSymlinkCount,
 				summary.SkippedSpecialFileCount,
+				formatSpecialFiles(summary.SkippedSpecialFiles, summary.SkippedSpecialFileCount)+formatNodumpStats(cca.excludeNodump, summary.SkippedNodumpCount),
 				summary.HardlinksConvertedCount,
 				summary.TotalBytesTransferred,
 				summary.TotalBytesEnumerated,
+				formatRetryAfterStats(summary.RetryAfterCount, summary.ThrottledMilliseconds)+formatStallStats(summary.StallCount, summary.StalledMilliseconds),
 				summary.JobStatus,
 				screenStats,
 				formatPerfAdvice(summary.PerformanceAdvice))
@@ -718,7 +1015,15 @@ Final Job Status: %v%s%s
 			}
 
 			return output
-		}, exitCode)
+		}
+
+		notifyJobDone(summary, cca.jobStartTime, exitCode)
+		if cca.watcher != nil {
+			lcm.Exit(builder, common.EExitCode.NoExit()) // leave the app running to watch for more changes
+			cca.watcher.roundFinished(exitCode)
+		} else {
+			lcm.Exit(builder, exitCode)
+		}
 	}
 
 	return
@@ -727,10 +1032,29 @@ Final Job Status: %v%s%s
 func (cca *cookedSyncCmdArgs) process() (err error) {
 	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
 
+	if err = runPreHook(); err != nil {
+		return err
+	}
+
 	err = common.SetBackupMode(cca.backupMode, cca.fromTo)
 	if err != nil {
 		return err
 	}
+	common.SetPreallocateFiles(cca.preallocate)
+	if err = common.SetCacheBypass(cca.bypassCache); err != nil {
+		return err
+	}
+	if err = common.SetAIOWrites(cca.aioWrites); err != nil {
+		return err
+	}
+	if err = common.SetLockFiles(cca.lockFiles); err != nil {
+		return err
+	}
+	common.SetPrefetchChunks(cca.prefetchChunks)
+	if err = common.SetMmapUploads(cca.mmapUploads); err != nil {
+		return err
+	}
+	setTraversalOrder(cca.traversal)
 
 	if err := common.VerifyIsURLResolvable(cca.source.Value); cca.fromTo.From().IsRemote() && err != nil {
 		return fmt.Errorf("failed to resolve source: %w", err)
@@ -793,7 +1117,9 @@ func (cca *cookedSyncCmdArgs) process() (err error) {
 	}
 
 	// trigger the enumeration
+	endScan := ste.TraceScan(cca.jobID)
 	err = enumerator.enumerate()
+	endScan(err)
 	if err != nil {
 		return err
 	}
@@ -837,9 +1163,18 @@ func init() {
 			}
 
 			cooked.commandString = copyHandlerUtil{}.ConstructCommandStringFromArgs()
-			err = cooked.process()
+			// the lock is taken once, so that it's held across all the jobs of --watch
+			ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+			if err = cooked.takeDestinationLock(ctx); err == nil {
+				if cooked.watch {
+					err = cooked.runWatch()
+				} else {
+					err = cooked.process()
+				}
+			}
 			if err != nil {
-				glcm.Error("Cannot perform sync due to error: " + err.Error() + getErrorCodeUrl(err))
+				notifyCommandFailed(err, errorExitCode(err))
+				glcm.ErrorWithExitCode("Cannot perform sync due to error: "+err.Error()+getErrorCodeUrl(err), errorExitCode(err))
 			}
 			if cooked.dryrunMode {
 				glcm.Exit(nil, common.EExitCode.Success())
@@ -853,6 +1188,40 @@ func init() {
 	syncCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true,
 		"True by default, look into sub-directories recursively when syncing between directories. (default true).")
 
+	syncCmd.PersistentFlags().StringVar(&raw.symlinks, SymlinksFlag, "",
+		"Skip by default. How symbolic links are handled: 'skip' leaves them out, 'follow' syncs whatever they point to, "+
+			"and 'preserve' stores the link target in an empty blob (marked in its metadata) and recreates the link on download.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.oneFileSystem, OneFileSystemFlag, false,
+		"False by default. Don't descend into directories that are on a different file system than a local source, "+
+			"such as NFS mounts, nullfs mounts or mounted media. The mount points themselves are still included.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.excludeNodump, ExcludeNodumpFlag, false,
+		"False by default. Skips local files and folders that have the nodump flag set (see chflags(1)), along with everything inside those folders, "+
+			"the same way dump(8) does. The number skipped is shown in the job summary. Currently only supported on FreeBSD.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.traversal, TraversalFlag, "auto",
+		"Specifies the order in which the directories of the source are read when scanning it, and so the order in which their files are synced. "+
+			"\n 'auto' (default) reads them level by level, which spreads transfers across the tree, until a great many are waiting, and then goes depth first to save memory. "+
+			"\n 'breadth-first' always reads them level by level, finishing each level of the tree before starting the one below it; the list of directories waiting can get large. "+
+			"\n 'depth-first' reads a subdirectory, and everything in it, before its siblings, so that whole subdirectories are complete at the destination sooner, "+
+			"which helps anything reading the destination while the job runs. With --"+ScanConcurrencyFlag+" above 1, several subdirectories are read at once. "+
+			"\n Has no effect on flat listings of Blob containers, which are always in the order of the names.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.order, OrderFlag, "as-scanned",
+		"Specifies the order in which files are synced. "+
+			"\n 'as-scanned' (default) starts each file as soon as it is found to need syncing. "+
+			"\n 'smallest-first' starts the smallest files first, to get as many files done as soon as possible. "+
+			"\n 'largest-first' starts the largest files first, so that the longest transfers aren't left until last. "+
+			"\n Either of the last two waits until both sides have been compared before any file starts, and holds the list of them in memory.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.specialFiles, SpecialFilesFlag, "warn",
+		"Specifies what to do with named pipes, sockets and device nodes found in a local source, none of which are synced. "+
+			"\n 'warn' (default) skips each one with a warning. "+
+			"\n 'skip' skips them without a warning. "+
+			"\n 'fail' stops the sync at the first one. "+
+			"\n Skipped special files are counted and listed in the job summary. Azure Files NFS transfers always skip them with a warning.")
+
 	syncCmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "",
 		"Source-to-destination combination. Required for NFS transfers; optional for SMB."+
 			"Examples: LocalBlob, BlobLocal, LocalFileSMB, FileSMBLocal, BlobFile, FileBlob, LocaFileNFS, "+
@@ -887,7 +1256,57 @@ func init() {
 			"share and for Linux when copying to Azure Files NFS share. ")
 
 	syncCmd.PersistentFlags().BoolVar(&raw.preservePOSIXProperties, "preserve-posix-properties", false,
-		"False by default. 'Preserves' property info gleaned from stat or statx into object metadata.")
+		"False by default. 'Preserves' property info gleaned from stat or statx into object metadata. "+
+			"When the Blob account has a hierarchical namespace, the owner, group and mode are also set as its x-ms-owner, x-ms-group and x-ms-permissions, "+
+			"and on download are applied back with chown and chmod (ownership only when running as root, and only for numeric IDs).")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.preserveACLs, PreserveACLsFlag, false,
+		"False by default. Preserves POSIX.1e or NFSv4 ACLs in object metadata on upload, and reapplies them on download. "+
+			"Currently only supported on FreeBSD.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.preserveExtAttrs, PreserveExtAttrsFlag, false,
+		"False by default. Preserves user and system namespace extended attributes in object metadata on upload, and restores them on download. "+
+			"Attributes larger than 1KiB, or beyond 4KiB in total, are skipped and listed in the job log. Currently only supported on FreeBSD.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.preserveFileFlags, PreserveFileFlagsFlag, false,
+		"False by default. Preserves BSD file flags, such as uchg, uappnd and nodump, in object metadata on upload, and restores them on download. "+
+			"System flags (schg, sappnd, ...) can only be restored when running as root. Currently only supported on FreeBSD.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.preallocate, common.PreallocateFlagName, true,
+		"True by default. Reserves disk space for each downloaded file before writing it, so that a full disk is reported "+
+			"straight away and files are less fragmented. Falls back to creating sparse files on file systems that can't preallocate, such as ZFS. "+
+			"On FreeBSD, runs of zeros in the downloaded data are skipped rather than written, and only become holes in files that aren't preallocated, "+
+			"so page blobs (usually mostly-empty disk images) are never preallocated. Set to false to always create sparse files.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.bypassCache, common.CacheBypassFlagName, common.CacheBypassNone,
+		"None by default. Keeps the files being uploaded from evicting everything else in the page cache or ZFS ARC. "+
+			"'dontneed' advises the kernel to drop each chunk once it has been read; 'direct' also opens files with O_DIRECT on FreeBSD. "+
+			"Useful for large backups of busy servers.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.aioWrites, common.AIOWritesFlagName, false,
+		"False by default. Writes downloaded files with kernel AIO, so that disk writes overlap with network reads "+
+			"without tying up a thread per file. Only supported on 64-bit FreeBSD.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.lockFiles, common.LockFilesFlagName, false,
+		"False by default. Locks each file while it's being downloaded, with flock(2), so that other programs that lock files "+
+			"before reading them, such as indexers and web servers, don't read it half-written, and so that a second AzCopy with this flag "+
+			"downloading to the same file fails instead of writing over this one. Files are downloaded to a temporary name unless "+
+			common.EEnvironmentVariable.DownloadToTempPath().Name+" is false, so it matters most when that is set. Not supported on Windows.")
+
+	syncCmd.PersistentFlags().Uint32Var(&raw.prefetchChunks, common.PrefetchChunksFlagName, 0,
+		"0 by default, which leaves it to "+common.EEnvironmentVariable.BufferGB().Name+". How many chunks of each file may be downloaded "+
+			"ahead of the point it has been written up to. Raise it to keep a fast link with high latency busy, or lower it "+
+			"to stop one slow file from using up the memory that others could use.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.mmapUploads, common.MmapUploadsFlagName, false,
+		"False by default. Uploads files of 8 MiB and more from memory mappings of them, sending each chunk straight from the mapping "+
+			"rather than reading it into a buffer first, which saves a copy and memory for large files. Can't be used with --"+common.CacheBypassFlagName+". "+
+			"Not supported on Windows.")
+
+	syncCmd.PersistentFlags().Uint32Var(&raw.scanConcurrency, ScanConcurrencyFlag, 0,
+		"How many directories to read at once when scanning the source and destination, in place of the "+common.EEnvironmentVariable.EnumerationPoolSize().Name+" environment variable, "+
+			"which defaults to 16. Raise it to find files sooner in trees of millions of files on file systems, such as ZFS, that serve many readers at once well. "+
+			"Also applies to scans of Blob and Azure Files.")
 
 	// TODO: enable when we support local <-> File
 	syncCmd.PersistentFlags().BoolVar(&raw.forceIfReadOnly, "force-if-read-only", false, "False by default. "+
@@ -898,7 +1317,7 @@ func init() {
 
 	syncCmd.PersistentFlags().Float64Var(&raw.blockSizeMB, "block-size-mb", 0,
 		"Use this block size (specified in MiB) when uploading to Azure Storage or downloading from Azure Storage. "+
-			"\n Default is automatically calculated based on file size. Decimal fractions are allowed (For example: 0.25).")
+			"\n Default is chosen for each file, from its size, the measured throughput and the available memory. Decimal fractions are allowed (For example: 0.25).")
 
 	syncCmd.PersistentFlags().Float64Var(&raw.putBlobSizeMB, "put-blob-size-mb", 0,
 		"Use this size (specified in MiB) as a threshold to determine whether to upload a blob as a single PUT request"+
@@ -944,6 +1363,18 @@ func init() {
 			"\n This option is only available when downloading. "+
 			"\n Available values include: NoCheck, LogOnly, FailIfDifferent, FailIfDifferentOrMissing. (default 'FailIfDifferent').")
 
+	syncCmd.PersistentFlags().StringVar(&raw.fsync, FsyncFlag, "off",
+		"When downloading, when to sync files to disk: "+
+			"\n per-file syncs each file once it's complete, before it's renamed into place, and then the directory it's in, so that a file that has been saved is kept if the machine goes down. "+
+			"\n interval:N also syncs a file every N seconds while it's being written, so that no more of it than that is lost, and less is left for the file system to write out at once. "+
+			"\n per-chunk also syncs a file after every chunk, which is the safest, and slowest. "+
+			"\n off, the default, leaves it to the operating system, which keeps the fastest downloads but may lose the last of them if the machine goes down.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.hashAlgorithm, "hash-algorithm", common.EContentHashType.MD5().String(),
+		"The hash that put-md5 creates and check-md5 validates. "+
+			"\n Available values include: MD5, SHA256, CRC64 (default 'MD5'). MD5 is saved as the Content-MD5 property, and the others as metadata of the blob. "+
+			"\n Hashes other than MD5 can only be created when uploading to Blob storage.")
+
 	syncCmd.PersistentFlags().BoolVar(&raw.s2sPreserveAccessTier, "s2s-preserve-access-tier", true,
 		"Preserve access tier during service to service copy. "+
 			"\n Please refer to [Azure Blob storage: hot, cool, and archive access tiers](https://docs.microsoft.com/azure/storage/blobs/storage-blob-storage-tiers) to ensure destination storage account supports setting access tier. "+
@@ -967,6 +1398,14 @@ func init() {
 			"\n to provide an encryption key on a per-request basis. "+
 			"\n Provided key and its hash will be fetched from environment variables (CPK_ENCRYPTION_KEY and CPK_ENCRYPTION_KEY_SHA256 must be set).")
 
+	syncCmd.PersistentFlags().StringVar(&raw.encryptionScope, "encryption-scope", "",
+		"Encrypt the blobs that are uploaded or copied with this encryption scope of the destination account.")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.s2sPreserveEncryptionScope, "s2s-preserve-encryption-scope", false,
+		"False by default. Encrypt blobs copied from one blob storage to another with the encryption scope of their source. "+
+			"\n The destination account must have encryption scopes of the same names. "+
+			"\n Blobs whose source has no encryption scope are encrypted with --encryption-scope, if given.")
+
 	syncCmd.PersistentFlags().BoolVar(&raw.mirrorMode, "mirror-mode", false,
 		"Disable last-modified-time based comparison and "+
 			"\n overwrites the conflicting files and blobs at the destination if this flag is set to true. "+
@@ -976,6 +1415,32 @@ func init() {
 		"False by default. Prints the path of files that would be copied or removed by the sync command. "+
 			"\n This flag does not copy or remove the actual files.")
 
+	syncCmd.PersistentFlags().StringVar(&raw.transferReport, TransferReportFlag, "",
+		"Write a line about each transfer to this file as it finishes: its source and destination, status, size, start and end time, "+
+			"number of retries and, if it failed, why. With --watch, every round's transfers are added to it.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.transferReportFormat, TransferReportFormatFlag, ste.TransferReportFormatCSV,
+		"The format of the --"+TransferReportFlag+" file: csv (the default), or json for a JSON object per line.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.notifyWebhook, NotifyWebhookFlag, "",
+		"POST the outcome of the job to this URL, as JSON, once it finishes or fails: its status and exit code, the number of transfers "+
+			"completed, failed and skipped, the bytes transferred and how long it took. With --watch, every round is posted.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.notifyEmail, NotifyEmailFlag, "",
+		"Email the outcome of the job, as --"+NotifyWebhookFlag+" posts it, to these addresses (separated by commas), "+
+			"through the mail server in AZCOPY_SMTP_SERVER.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.preHook, PreHookFlag, "",
+		"Run this command, through the shell, before the source is scanned, e.g. to mount a dataset or take a snapshot of it. "+
+			"The job isn't started if the command fails. AZCOPY_JOB_ID, AZCOPY_COMMAND, AZCOPY_SOURCE and AZCOPY_DESTINATION are set in its environment. "+
+			"With --watch, it is run before every round.")
+
+	syncCmd.PersistentFlags().StringVar(&raw.postHook, PostHookFlag, "",
+		"Run this command, through the shell, once the job has finished or failed, e.g. to unmount a dataset or destroy a snapshot. "+
+			"As well as what the --"+PreHookFlag+" command is given, AZCOPY_JOB_STATUS, AZCOPY_EXIT_CODE, AZCOPY_ERROR, AZCOPY_TRANSFERS_TOTAL, "+
+			"AZCOPY_TRANSFERS_COMPLETED, AZCOPY_TRANSFERS_FAILED, AZCOPY_TRANSFERS_SKIPPED, AZCOPY_BYTES_TRANSFERRED and AZCOPY_DURATION_SECONDS are set in its environment. "+
+			"The output of both commands goes to the job log. With --watch, it is run after every round.")
+
 	syncCmd.PersistentFlags().StringVar(&raw.trailingDot, "trailing-dot", "",
 		"'Enable' by default to treat file share related operations in a safe manner."+
 			"\n  Available options: "+strings.Join(common.ValidTrailingDotOptions(), ", ")+". "+
@@ -1031,4 +1496,49 @@ func init() {
 		"Follow by default. Preserve hardlinks for NFS resources. "+
 			"\n This flag is only applicable when the source is Azure NFS file share or the destination is NFS file share. "+
 			"\n Available options: skip, preserve, follow (default 'follow').")
+
+	syncCmd.PersistentFlags().BoolVar(&raw.watch, "watch", false,
+		"False by default. After the initial sync, keep running and push local changes to the destination as they happen, "+
+			"syncing only the directories that changed. Only supported on FreeBSD, when the source is local. "+
+			"\n Every watched file and directory holds an open file descriptor, so very large trees may need kern.maxfilesperproc raised.")
+	syncCmd.PersistentFlags().DurationVar(&raw.watchDebounce, "watch-debounce", defaultSyncWatchDebounce,
+		"How long the tree must be quiet before a batch of changes is pushed, when --watch is used.")
+	syncCmd.PersistentFlags().IntVar(&raw.watchBatchSize, "watch-batch-size", defaultSyncWatchBatchSize,
+		"The maximum number of changed directories collected before a batch is pushed without waiting for the tree to settle, when --watch is used.")
+	syncCmd.PersistentFlags().BoolVar(&raw.lockDestination, LockDestinationFlag, false,
+		"False by default. Holds a lock on the destination while the sync runs, so that another sync to it with this flag, "+
+			"such as one started on a schedule before the last has finished, fails instead of running alongside it and deleting what it copies. "+
+			"\n Blob and Data Lake destinations are locked by leasing their container, which keeps out syncs from other machines too, "+
+			"but also syncs to other paths in the same container, and any other use of the container's lease; "+
+			"a sync that's killed keeps the container leased for up to a minute. "+
+			"Other destinations are locked with a file in the job plan folder, which keeps out only syncs that share that folder.")
+	syncCmd.PersistentFlags().StringVar(&raw.syncStateCache, SyncStateCacheFlag, "none",
+		"Keeps a record of the state that each sync of an upload leaves the destination in, beside the job plan files, "+
+			"so that the next sync compares the source with the record instead of scanning the destination. "+
+			"\n Use 'use' to compare with the record when there is one, and 'refresh' to scan the destination and record it afresh. "+
+			"The record is only kept after a sync that didn't fail any transfers, and only used by a sync with the same filters and options. "+
+			"Changes made to the destination by anything but sync aren't in the record, so use 'refresh' after there have been any.")
+	syncCmd.PersistentFlags().BoolVar(&raw.changeFeed, ChangeFeedFlag, false,
+		"False by default. Compares only what the change feed of the source's storage account says has changed since the last sync "+
+			"of the source to the destination with this flag, instead of the whole source and destination. The first sync compares everything. "+
+			"\n The change feed must be enabled on the account, and the source authorized to read the $blobchangefeed container, as with OAuth or an account SAS. "+
+			"Changes reach the change feed about an hour after they're made, so the latest are left for the next sync; and changes older than "+
+			"the change feed's retention period are lost, so syncs must be run more often than that.")
+	syncCmd.PersistentFlags().StringVar(&raw.fromInventory, FromInventoryFlag, "",
+		"Lists the source from the report of a blob inventory run, given by the URL of its manifest, instead of listing it live, "+
+			"which saves hours on containers of billions of blobs. The report is read with the source's credentials, unless the URL has a SAS for the inventory's container. "+
+			"\n Blobs are compared as they were when the inventory was taken, so those made since aren't synced, and downloads of those changed since fail. "+
+			"The inventory's rule must list blobs, in CSV or Parquet, with the Last-Modified and Content-Length fields, and the Content-MD5 field for --compare-hash.")
+	syncCmd.PersistentFlags().BoolVar(&raw.blockDelta, BlockDeltaFlag, false,
+		"False by default. Uploads only the blocks of each changed file that its blob doesn't have, and commits them with those it has. "+
+			"Saves most of the upload of large files that change in part, such as VM images and database dumps. "+
+			"\n Blocks are named by the SHA-256 of their content, so the first sync with this flag sends every block. "+
+			"Blocks only match if they start at the same place, so give the same --block-size-mb each time, or none, which picks a block size from the size of the file alone. "+
+			"Files no bigger than --put-blob-size-mb are uploaded whole.")
+	syncCmd.PersistentFlags().BoolVar(&raw.contentDefinedBlocks, ContentDefinedBlocksFlag, false,
+		"False by default. Implies --"+BlockDeltaFlag+", and splits each file into blocks at places chosen by its content, as rsync does, "+
+			"instead of every --block-size-mb, so that bytes inserted into or removed from a file only change the blocks they fall in, "+
+			"and the blocks after them aren't uploaded again. Worth it for very large files over slow links. "+
+			"\n Each file is read twice: once to find its blocks, and once to upload them. Blocks are --block-size-mb on average, "+
+			"from a quarter of that to four times it.")
 }
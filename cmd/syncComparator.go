@@ -80,15 +80,16 @@ func newSyncDestinationComparator(i *objectIndexer, copyScheduler, cleaner objec
 // if file x from the destination exists at the source, then we'd only transfer it if it is considered stale compared to its counterpart at the source
 // if file x does not exist at the source, then it is considered extra, and will be deleted
 func (f *syncDestinationComparator) processIfNecessary(destinationObject StoredObject) error {
-	sourceObjectInMap, present := f.sourceIndex.indexMap[destinationObject.relativePath]
+	key := destinationObject.relativePath
+	sourceObjectInMap, present := f.sourceIndex.indexMap[key]
 	if !present && f.sourceIndex.isDestinationCaseInsensitive {
-		lcRelativePath := strings.ToLower(destinationObject.relativePath)
-		sourceObjectInMap, present = f.sourceIndex.indexMap[lcRelativePath]
+		key = strings.ToLower(destinationObject.relativePath)
+		sourceObjectInMap, present = f.sourceIndex.indexMap[key]
 	}
 
 	// if the destinationObject is present at source and stale, we transfer the up-to-date version from source
 	if present {
-		defer delete(f.sourceIndex.indexMap, destinationObject.relativePath)
+		defer delete(f.sourceIndex.indexMap, key)
 
 		if f.disableComparison {
 			syncComparatorLog(sourceObjectInMap.relativePath, syncStatusOverwritten, syncOverwriteReasonNewerHash, false)
@@ -27,6 +27,7 @@ import (
 	"runtime"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
@@ -38,6 +39,15 @@ import (
 // -------------------------------------- Implemented Enumerators -------------------------------------- \\
 
 func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *syncEnumerator, err error) {
+	cca.scanProgress = newScanProgress()
+
+	// taken out before the scan starts, so that what's uploaded from here on is newer than the record says
+	if cca.syncState, err = newSyncStateCache(cca); err != nil {
+		return nil, err
+	}
+	if cca.changeFeedState, err = newChangeFeedSync(cca); err != nil {
+		return nil, err
+	}
 
 	srcCredInfo, _, err := GetCredentialInfoForLocation(ctx, cca.fromTo.From(), cca.source, true, cca.cpkOptions)
 
@@ -59,16 +69,16 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 
 	includeDirStubs := (cca.fromTo.From().SupportsHnsACLs() && cca.fromTo.To().SupportsHnsACLs() && cca.preservePermissions.IsTruthy()) || cca.includeDirectoryStubs
 
-	// TODO: enable symlink support in a future release after evaluating the implications
 	// TODO: Consider passing an errorChannel so that enumeration errors during sync can be conveyed to the caller.
 	// GetProperties is enabled by default as sync supports both upload and download.
 	// This property only supports Files and S3 at the moment, but provided that Files sync is coming soon, enable to avoid stepping on Files sync work
 	dest := cca.fromTo.To()
-	sourceTraverser, err := InitResourceTraverser(cca.source, cca.fromTo.From(), ctx, InitResourceTraverserOptions{
+	srcOptions := InitResourceTraverserOptions{
 		DestResourceType: &dest,
 
 		Credential: &srcCredInfo,
 		IncrementEnumeration: func(entityType common.EntityType) {
+			cca.scanProgress.found(entityType)
 			if entityType == common.EEntityType.File() {
 				atomic.AddUint64(&cca.atomicSourceFilesScanned, 1)
 			}
@@ -91,8 +101,22 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		GetPropertiesInFrontend: true,
 		IncludeDirectoryStubs:   includeDirStubs,
 		PreserveBlobTags:        cca.s2sPreserveBlobTags,
+		SymlinkHandling:         cca.symlinkHandling,
+		OneFileSystem:           cca.oneFileSystem,
+		ExcludeNodump:           cca.excludeNodump,
 		HardlinkHandling:        cca.hardlinks,
-	})
+		IncrementNodumpSkipped: func() {
+			atomic.AddUint32(&cca.atomicSkippedNodumpCount, 1)
+		},
+		SpecialFileHandling: cca.specialFiles,
+		ReportSpecialFile: func(path, kind string) {
+			atomic.AddUint32(&cca.atomicSkippedSpecialFileCount, 1)
+			cca.skippedSpecialFiles.add(path, kind)
+		},
+		InventoryManifest: cca.fromInventory,
+		ScanConcurrency:   int(cca.scanConcurrency),
+	}
+	sourceTraverser, err := InitResourceTraverser(cca.source, cca.fromTo.From(), ctx, srcOptions)
 
 	if err != nil {
 		return nil, err
@@ -105,18 +129,23 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		return nil, err
 	}
 
+	// the destination is what the key encrypts, so what is already there is read with it
+	dstCpkOptions := cca.cpkOptions
+	dstCpkOptions.IsSourceEncrypted = cca.cpkOptions.CpkInfo || cca.cpkOptions.CpkScopeInfo != ""
+
 	// TODO: enable symlink support in a future release after evaluating the implications
 	// GetProperties is enabled by default as sync supports both upload and download.
 	// This property only supports Files and S3 at the moment, but provided that Files sync is coming soon, enable to avoid stepping on Files sync work
-	destinationTraverser, err := InitResourceTraverser(cca.destination, cca.fromTo.To(), ctx, InitResourceTraverserOptions{
+	dstOptions := InitResourceTraverserOptions{
 		Credential: &dstCredInfo,
 		IncrementEnumeration: func(entityType common.EntityType) {
+			cca.scanProgress.found(entityType)
 			if entityType == common.EEntityType.File() {
 				atomic.AddUint64(&cca.atomicDestinationFilesScanned, 1)
 			}
 		},
 
-		CpkOptions: cca.cpkOptions,
+		CpkOptions: dstCpkOptions,
 
 		SyncHashType:        cca.compareHash,
 		PreservePermissions: cca.preservePermissions,
@@ -127,7 +156,9 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		IncludeDirectoryStubs:   includeDirStubs,
 		PreserveBlobTags:        cca.s2sPreserveBlobTags,
 		HardlinkHandling:        common.EHardlinkHandlingType.Follow(),
-	})
+		ScanConcurrency:         int(cca.scanConcurrency),
+	}
+	destinationTraverser, err := InitResourceTraverser(cca.destination, cca.fromTo.To(), ctx, dstOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -202,7 +233,7 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 
 	// decide our folder transfer strategy
 	// sync always acts like stripTopDir=true, but if we intend to persist the root, we must tell NewFolderPropertyOption stripTopDir=false.
-	fpo, folderMessage := NewFolderPropertyOption(cca.fromTo, cca.recursive, !cca.includeRoot, filters, cca.preserveInfo, cca.preservePermissions.IsTruthy(), false, strings.EqualFold(cca.destination.Value, common.Dev_Null), cca.includeDirectoryStubs)
+	fpo, folderMessage := NewFolderPropertyOption(cca.fromTo, cca.recursive, !cca.includeRoot, filters, cca.preserveInfo, cca.preservePermissions.IsTruthy(), cca.preserveACLs || cca.preserveExtAttrs || cca.preserveFileFlags, strings.EqualFold(cca.destination.Value, common.Dev_Null), cca.includeDirectoryStubs)
 	if !cca.dryrunMode {
 		glcm.Info(folderMessage)
 	}
@@ -227,9 +258,13 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 			PreserveLastModifiedTime:         cca.preserveInfo, // true by default for sync so that future syncs have this information available
 			PutMd5:                           cca.putMd5,
 			MD5ValidationOption:              cca.md5ValidationOption,
+			ContentHashType:                  cca.hashAlgorithm,
+			Fsync:                            cca.fsync,
 			BlockSizeInBytes:                 cca.blockSize,
 			PutBlobSizeInBytes:               cca.putBlobSize,
 			DeleteDestinationFileIfNecessary: cca.deleteDestinationFileIfNecessary,
+			BlockDelta:                       cca.blockDelta,
+			ContentDefinedBlocks:             cca.contentDefinedBlocks,
 		},
 		ForceWrite:                     common.EOverwriteOption.True(), // once we decide to transfer for a sync operation, we overwrite the destination regardless
 		ForceIfReadOnly:                cca.forceIfReadOnly,
@@ -237,12 +272,16 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		PreservePermissions:            cca.preservePermissions,
 		PreserveInfo:                   cca.preserveInfo,
 		PreservePOSIXProperties:        cca.preservePOSIXProperties,
+		PreserveACLs:                   cca.preserveACLs,
+		PreserveExtAttrs:               cca.preserveExtAttrs,
+		PreserveFileFlags:              cca.preserveFileFlags,
 		S2SSourceChangeValidation:      true,
 		DestLengthValidation:           true,
 		S2SGetPropertiesInBackend:      true,
 		S2SInvalidMetadataHandleOption: common.EInvalidMetadataHandleOption.RenameIfInvalid(),
 		CpkOptions:                     cca.cpkOptions,
 		S2SPreserveBlobTags:            cca.s2sPreserveBlobTags,
+		S2SPreserveEncryptionScope:     cca.s2sPreserveEncryptionScope,
 
 		S2SSourceCredentialType: cca.s2sSourceCredentialType,
 		FileAttributes: common.FileTransferAttributes{
@@ -312,6 +351,18 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		return nil, err
 	}
 
+	// with --change-feed, only the paths that the change feed has changes to since the last sync are compared
+	if cca.changeFeedState != nil {
+		paths, err := cca.changeFeedState.changedPaths(ctx, copyJobTemplate.SrcServiceClient)
+		if err != nil {
+			return nil, err
+		}
+		if paths != nil {
+			sourceTraverser = newChangedPathsTraverser(cca.source, cca.fromTo.From(), ctx, srcOptions, paths)
+			destinationTraverser = newChangedPathsTraverser(cca.destination, cca.fromTo.To(), ctx, dstOptions, paths)
+		}
+	}
+
 	transferScheduler := newSyncTransferProcessor(cca, NumOfFilesPerDispatchJobPart, fpo, copyJobTemplate)
 
 	// set up the comparator so that the source/destination can be compared
@@ -328,16 +379,19 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 		if err != nil {
 			return nil, fmt.Errorf("unable to instantiate destination cleaner due to: %s", err.Error())
 		}
-		destCleanerFunc := newFpoAwareProcessor(fpo, destinationCleaner.removeImmediately)
+		destinationCleaner.notDeleted = cca.syncState.keep
+		destCleanerFunc := newFpoAwareProcessor(fpo, cca.syncState.recordingDeletions(destinationCleaner.removeImmediately))
+		scheduleCopy := cca.syncState.recordingTransfers(transferScheduler.scheduleCopyTransfer)
 
 		// when uploading, we can delete remote objects immediately, because as we traverse the remote location
 		// we ALREADY have available a complete map of everything that exists locally
 		// so as soon as we see a remote destination object we can know whether it exists in the local source
 
-		comparator = newSyncDestinationComparator(indexer, transferScheduler.scheduleCopyTransfer, destCleanerFunc, cca.compareHash, cca.preserveInfo, cca.mirrorMode).processIfNecessary
+		comparator = newSyncDestinationComparator(indexer, scheduleCopy, destCleanerFunc, cca.compareHash, cca.preserveInfo, cca.mirrorMode).processIfNecessary
+		comparator = cca.syncState.recordingDestination(comparator)
 		finalize = func() error {
 			// schedule every local file that doesn't exist at the destination
-			err = indexer.traverse(transferScheduler.scheduleCopyTransfer, filters)
+			err = indexer.traverse(scheduleCopy, filters)
 			if err != nil {
 				return err
 			}
@@ -353,7 +407,9 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 			return nil
 		}
 
-		return newSyncEnumerator(sourceTraverser, destinationTraverser, indexer, filters, comparator, finalize), nil
+		e := newSyncEnumerator(sourceTraverser, cca.syncState.traverser(destinationTraverser), indexer, filters, comparator, finalize)
+		e.progress = cca.scanProgress
+		return e, nil
 	default:
 		indexer.isDestinationCaseInsensitive = IsDestinationCaseInsensitive(cca.fromTo)
 		// in all other cases (download and S2S), the destination is scanned/indexed first
@@ -394,7 +450,9 @@ func (cca *cookedSyncCmdArgs) initEnumerator(ctx context.Context) (enumerator *s
 			return nil
 		}
 
-		return newSyncEnumerator(destinationTraverser, sourceTraverser, indexer, filters, comparator, finalize), nil
+		e := newSyncEnumerator(destinationTraverser, sourceTraverser, indexer, filters, comparator, finalize)
+		e.progress = cca.scanProgress
+		return e, nil
 	}
 }
 
@@ -408,16 +466,27 @@ func IsDestinationCaseInsensitive(fromTo common.FromTo) bool {
 }
 
 func quitIfInSync(transferJobInitiated, anyDestinationFileDeleted bool, cca *cookedSyncCmdArgs) {
-	if !transferJobInitiated && !anyDestinationFileDeleted {
+	exit := func(builder common.OutputBuilder) {
 		cca.reportScanningProgress(glcm, 0)
-		glcm.Exit(func(format common.OutputFormat) string {
+		cca.syncState.save(true)
+		cca.changeFeedState.save(true)
+		notifyJobDone(common.ListJobSummaryResponse{JobID: cca.jobID, JobStatus: common.EJobStatus.Completed()}, time.Time{}, common.EExitCode.Success())
+		if cca.watcher != nil {
+			glcm.Exit(builder, common.EExitCode.NoExit()) // sync --watch carries on with the next round
+			cca.watcher.roundFinished(common.EExitCode.Success())
+			return
+		}
+		glcm.Exit(builder, common.EExitCode.Success())
+	}
+
+	if !transferJobInitiated && !anyDestinationFileDeleted {
+		exit(func(format common.OutputFormat) string {
 			return "The source and destination are already in sync."
-		}, common.EExitCode.Success())
+		})
 	} else if !transferJobInitiated && anyDestinationFileDeleted {
 		// some files were deleted but no transfer scheduled
-		cca.reportScanningProgress(glcm, 0)
-		glcm.Exit(func(format common.OutputFormat) string {
+		exit(func(format common.OutputFormat) string {
 			return "The source and destination are now in sync."
-		}, common.EExitCode.Success())
+		})
 	}
 }
@@ -44,8 +44,10 @@ func newSyncTransferProcessor(cca *cookedSyncCmdArgs,
 
 	// note that the source and destination, along with the template are given to the generic processor's constructor
 	// this means that given an object with a relative path, this processor already knows how to schedule the right kind of transfers
-	return newCopyTransferProcessor(copyJobTemplate, numOfTransfersPerPart, cca.source, cca.destination,
+	p := newCopyTransferProcessor(copyJobTemplate, numOfTransfersPerPart, cca.source, cca.destination,
 		reportFirstPart, reportFinalPart, cca.preserveAccessTier, cca.dryrunMode)
+	p.orderer = newTransferOrderer(cca.order)
+	return p
 }
 
 // base for delete processors targeting different resources
@@ -70,6 +72,9 @@ type interactiveDeleteProcessor struct {
 	// count the deletions that happened
 	incrementDeletionCount func()
 
+	// given the objects that are left where they are, since they weren't to be deleted or couldn't be
+	notDeleted objectProcessor
+
 	// dryrunMode
 	dryrunMode bool
 }
@@ -80,6 +85,9 @@ func (d *interactiveDeleteProcessor) removeImmediately(object StoredObject) (err
 	}
 
 	if !d.shouldDelete {
+		if d.notDeleted != nil {
+			return d.notDeleted(object)
+		}
 		return nil
 	}
 
@@ -116,6 +124,9 @@ func (d *interactiveDeleteProcessor) removeImmediately(object StoredObject) (err
 		if azcopyScanningLogger != nil {
 			azcopyScanningLogger.Log(common.LogError, msg+": "+err.Error())
 		}
+		if d.notDeleted != nil {
+			_ = d.notDeleted(object)
+		}
 	}
 
 	if d.incrementDeletionCount != nil {
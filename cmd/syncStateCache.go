@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const syncStateVersion = 1
+
+// syncStateCache is the record, kept with --sync-state-cache, of the state that a sync left the destination of an
+// upload in, so that the next sync can compare the source with it in place of scanning the destination. Objects that
+// are found at the destination, or compared with in the record, are recorded as they were; files that are uploaded are
+// recorded as last modified when the scan began, since what's uploaded is newer than that at the destination, and what
+// has changed in the source since then is newer than that too; and what's deleted is left out.
+//
+// The record is taken out of the cache when the sync starts, and put back only if the sync finishes without failing any
+// transfer, so a sync that fails or is interrupted is followed by one that scans the destination. Changes made to the
+// destination other than by sync aren't seen, so the record is only to be used while nothing else writes to it, or is
+// refreshed when something has.
+type syncStateCache struct {
+	mu            sync.Mutex
+	path          string
+	scope         string
+	started       time.Time
+	preferSMBTime bool
+	dryrun        bool
+
+	loaded   []syncStateEntry // the record that the destination is compared with, if one was taken out
+	syncedAt time.Time        // when the sync that made it finished
+	entries  map[string]syncStateEntry
+}
+
+// the first line of a record, which is followed by an entry for each object at the destination
+type syncStateHeader struct {
+	Version  int
+	Scope    string
+	SyncedAt time.Time
+}
+
+type syncStateEntry struct {
+	Path            string    `json:"p"`
+	EntityType      uint8     `json:"t"`
+	LastModified    time.Time `json:"m"`
+	SMBLastModified time.Time `json:"s"`
+	Size            int64     `json:"z"`
+}
+
+// what a record is only good for; one made by a sync with other filters, for instance, has no entries for what they
+// left out, which the next sync might find
+type syncStateScope struct {
+	FromTo                string
+	Recursive             bool
+	IncludePatterns       []string
+	ExcludePatterns       []string
+	ExcludePaths          []string
+	IncludeFileAttributes []string
+	ExcludeFileAttributes []string
+	IncludeRegex          []string
+	ExcludeRegex          []string
+	IncludeDirectoryStubs bool
+	IncludeRoot           bool
+	PreservePermissions   bool
+	PreserveInfo          bool
+	Symlinks              string
+	Hardlinks             string
+	TrailingDot           string
+}
+
+func syncStatePath(source, destination common.ResourceString) string {
+	// the SAS isn't part of the name, since it may be renewed between one sync and the next
+	sum := sha256.Sum256([]byte(source.Value + "\n" + destination.Value + "?" + destination.ExtraQuery))
+	return filepath.Join(common.AzcopyJobPlanFolder, "syncstate", hex.EncodeToString(sum[:])+".jsonl.gz")
+}
+
+// newSyncStateCache returns the cache of the state of the sync's destination, with the record in it taken out unless
+// the destination is to be scanned, or nil if the sync doesn't keep one
+func newSyncStateCache(cca *cookedSyncCmdArgs) (*syncStateCache, error) {
+	if cca.syncStateCache == common.ESyncStateCache.None() {
+		return nil, nil
+	}
+	scope, err := json.Marshal(syncStateScope{
+		FromTo:                cca.fromTo.String(),
+		Recursive:             cca.recursive,
+		IncludePatterns:       cca.includePatterns,
+		ExcludePatterns:       cca.excludePatterns,
+		ExcludePaths:          cca.excludePaths,
+		IncludeFileAttributes: cca.includeFileAttributes,
+		ExcludeFileAttributes: cca.excludeFileAttributes,
+		IncludeRegex:          cca.includeRegex,
+		ExcludeRegex:          cca.excludeRegex,
+		IncludeDirectoryStubs: cca.includeDirectoryStubs,
+		IncludeRoot:           cca.includeRoot,
+		PreservePermissions:   cca.preservePermissions.IsTruthy(),
+		PreserveInfo:          cca.preserveInfo,
+		Symlinks:              cca.symlinkHandling.String(),
+		Hardlinks:             cca.hardlinks.String(),
+		TrailingDot:           cca.trailingDot.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &syncStateCache{
+		path:          syncStatePath(cca.source, cca.destination),
+		scope:         string(scope),
+		started:       time.Now(),
+		preferSMBTime: cca.preserveInfo,
+		dryrun:        cca.dryrunMode,
+		entries:       map[string]syncStateEntry{},
+	}
+	if err = c.takeOut(cca.syncStateCache == common.ESyncStateCache.Use()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// takeOut removes the record from the cache, having read it if use is set. A dry run leaves it where it is.
+func (c *syncStateCache) takeOut(use bool) error {
+	if use {
+		if err := c.read(); err != nil {
+			common.LogToJobLogWithPrefix("The destination is scanned, since its recorded state can't be used: "+err.Error(), common.LogWarning)
+			c.loaded = nil
+		}
+	}
+	if c.dryrun {
+		return nil
+	}
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("couldn't take out the recorded state of the destination: %w", err)
+	}
+	return nil
+}
+
+func (c *syncStateCache) read() error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(gz))
+
+	var header syncStateHeader
+	if err = dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version != syncStateVersion || header.Scope != c.scope {
+		return errors.New("it was recorded by a sync with other options")
+	}
+	loaded := []syncStateEntry{}
+	for {
+		var e syncStateEntry
+		if err = dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		loaded = append(loaded, e)
+	}
+	c.loaded, c.syncedAt = loaded, header.SyncedAt
+	return nil
+}
+
+// traverser is what the destination is compared with: the record, if one was taken out, or else dest
+func (c *syncStateCache) traverser(dest ResourceTraverser) ResourceTraverser {
+	if c == nil || c.loaded == nil {
+		return dest
+	}
+	message := fmt.Sprintf("Comparing the source with the state the destination was left in by the sync at %s, instead of scanning the destination",
+		c.syncedAt.Format(time.RFC3339))
+	glcm.Info(message)
+	common.LogToJobLogWithPrefix(message, common.LogInfo)
+	return &syncStateTraverser{ResourceTraverser: dest, entries: c.loaded}
+}
+
+func (c *syncStateCache) record(e syncStateEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[e.Path] = e
+}
+
+func entryOf(obj StoredObject) syncStateEntry {
+	return syncStateEntry{
+		Path:            obj.relativePath,
+		EntityType:      uint8(obj.entityType),
+		LastModified:    obj.lastModifiedTime,
+		SMBLastModified: obj.smbLastModifiedTime,
+		Size:            obj.size,
+	}
+}
+
+// recordingDestination records each object at the destination, as it was, before passing it on to comparator
+func (c *syncStateCache) recordingDestination(comparator objectProcessor) objectProcessor {
+	if c == nil {
+		return comparator
+	}
+	return func(obj StoredObject) error {
+		c.record(entryOf(obj))
+		return comparator(obj)
+	}
+}
+
+// recordingTransfers records what each transfer given to scheduler leaves at the destination
+func (c *syncStateCache) recordingTransfers(scheduler objectProcessor) objectProcessor {
+	if c == nil {
+		return scheduler
+	}
+	return func(obj StoredObject) error {
+		e := entryOf(obj)
+		e.LastModified = c.started
+		if !c.preferSMBTime {
+			// the time it's given at the destination is only the source's when it's preserved
+			e.SMBLastModified = time.Time{}
+		}
+		c.record(e)
+		return scheduler(obj)
+	}
+}
+
+// recordingDeletions leaves each object given to deleter out of the record; keep puts back those that are left
+func (c *syncStateCache) recordingDeletions(deleter objectProcessor) objectProcessor {
+	if c == nil {
+		return deleter
+	}
+	return func(obj StoredObject) error {
+		c.mu.Lock()
+		delete(c.entries, obj.relativePath)
+		c.mu.Unlock()
+		return deleter(obj)
+	}
+}
+
+// keep records that an object is left at the destination, since it wasn't to be deleted, or couldn't be
+func (c *syncStateCache) keep(obj StoredObject) error {
+	if c != nil {
+		c.record(entryOf(obj))
+	}
+	return nil
+}
+
+// save puts the record back in the cache, if the sync has succeeded. Since the record only saves time, it isn't an
+// error if it can't be saved; the next sync scans the destination.
+func (c *syncStateCache) save(succeeded bool) {
+	if c == nil || c.dryrun {
+		return
+	}
+	if !succeeded {
+		common.LogToJobLogWithPrefix("The state of the destination isn't recorded, since not every transfer succeeded, so the next sync will scan it", common.LogWarning)
+		return
+	}
+	if err := c.write(); err != nil {
+		common.LogToJobLogWithPrefix("Couldn't record the state of the destination, so the next sync will scan it: "+err.Error(), common.LogWarning)
+	}
+}
+
+func (c *syncStateCache) write() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return err
+	}
+	// written aside and renamed, so that an interruption can't leave half a record
+	temp := c.path + ".tmp"
+	f, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	buf := bufio.NewWriter(f)
+	gz := gzip.NewWriter(buf)
+	enc := json.NewEncoder(gz)
+	err = enc.Encode(syncStateHeader{Version: syncStateVersion, Scope: c.scope, SyncedAt: time.Now()})
+	for _, e := range c.entries {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(e)
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, c.path)
+}
+
+// syncStateTraverser gives the objects in the record of the destination in place of the destination's own
+type syncStateTraverser struct {
+	ResourceTraverser // the destination's, which says whether it's a directory
+	entries           []syncStateEntry
+}
+
+func (t *syncStateTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	for _, e := range t.entries {
+		obj := newStoredObject(preprocessor, path.Base(e.Path), e.Path, common.EntityType(e.EntityType), e.LastModified, e.Size,
+			noContentProps, noBlobProps, noMetadata, "")
+		obj.smbLastModifiedTime = e.SMBLastModified
+		err := processIfPassedFilters(filters, obj, processor)
+		if _, err = getProcessingError(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
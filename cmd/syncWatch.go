@@ -0,0 +1,325 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const (
+	defaultSyncWatchDebounce  = 2 * time.Second
+	defaultSyncWatchBatchSize = 100
+)
+
+// syncWatchEvent names a directory, relative to the watched root, whose contents need to be synced again.
+// recursive is set when the change may have affected anything below it too (e.g. a new or removed subdirectory).
+type syncWatchEvent struct {
+	relDir    string
+	recursive bool
+}
+
+// syncTreeWatcher reports changes under a local directory tree. The kqueue implementation lives in syncWatch_freebsd.go.
+type syncTreeWatcher interface {
+	Events() <-chan syncWatchEvent
+	Errors() <-chan error
+	// Err says why Events was closed, if the watcher gave up by itself rather than being closed.
+	Err() error
+	Close() error
+}
+
+// syncWatcher runs `sync --watch`. After an initial full sync, each batch of changed directories is synced
+// as its own, much smaller, sync job, so the tree is never rescanned as a whole.
+// It stands in for the per-job WorkController for the lifetime of the process, handing progress reporting to
+// whichever round is running, because progress reporting can only be initiated once.
+type syncWatcher struct {
+	template  cookedSyncCmdArgs
+	tree      syncTreeWatcher
+	debounce  time.Duration
+	batchSize int
+
+	mu        sync.Mutex
+	round     *cookedSyncCmdArgs
+	roundDone chan common.ExitCode
+	rounds    uint64
+	lastRound time.Time
+	stopping  bool
+
+	// while paused, no new rounds are started. unpaused is signalled on resume, and when we start stopping.
+	paused   bool
+	unpaused *sync.Cond
+}
+
+func (cca *cookedSyncCmdArgs) runWatch() error {
+	tree, err := newSyncTreeWatcher(cca.source.ValueLocal(), cca.recursive)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", cca.source.ValueLocal(), err)
+	}
+
+	w := &syncWatcher{
+		template:  *cca,
+		tree:      tree,
+		debounce:  cca.watchDebounce,
+		batchSize: cca.watchBatchSize,
+	}
+	w.unpaused = sync.NewCond(&w.mu)
+	glcm.InitiateProgressReporting(w)
+
+	go w.loop()
+	return nil
+}
+
+func (w *syncWatcher) loop() {
+	// the first round is a normal, full sync, so that we start from a known state
+	if code := w.runRound(syncWatchEvent{relDir: "", recursive: w.template.recursive}); w.shouldStop(code) {
+		return
+	}
+	glcm.Info(fmt.Sprintf("Watching %s for changes. Changes are pushed %v after they settle.", w.template.source.ValueLocal(), w.debounce))
+
+	pending := make(map[string]bool)
+	var settled <-chan time.Time
+
+	flush := func() bool {
+		for _, ev := range coalesceSyncWatchEvents(pending) {
+			if w.shouldStop(w.runRound(w.nearestExisting(ev))) {
+				return false
+			}
+		}
+		pending = make(map[string]bool)
+		settled = nil
+		return true
+	}
+
+	for {
+		select {
+		case ev, ok := <-w.tree.Events():
+			if !ok {
+				if err := w.tree.Err(); err != nil {
+					// e.g. the root was removed; there's nothing left to keep in sync, and whoever runs us should hear about it
+					glcm.Error("Stopped watching for changes: " + err.Error())
+					return
+				}
+				glcm.Exit(nil, common.EExitCode.Success())
+				return
+			}
+			pending[ev.relDir] = pending[ev.relDir] || ev.recursive
+			settled = time.After(w.debounce)
+
+			if len(pending) >= w.batchSize && !flush() {
+				return
+			}
+		case err := <-w.tree.Errors():
+			glcm.Warn("Watch error: " + err.Error())
+		case <-settled:
+			if !flush() {
+				return
+			}
+		}
+	}
+}
+
+// nearestExisting widens an event for a directory that has since disappeared to its closest surviving ancestor,
+// so that the deletion is picked up there.
+func (w *syncWatcher) nearestExisting(ev syncWatchEvent) syncWatchEvent {
+	root := w.template.source.ValueLocal()
+	for ev.relDir != "" {
+		if fi, err := os.Stat(filepath.Join(root, ev.relDir)); err == nil && fi.IsDir() {
+			break
+		}
+		ev.relDir = syncWatchParent(ev.relDir)
+		ev.recursive = true
+	}
+	return ev
+}
+
+// runRound runs a single sync job for one directory, and blocks until it has finished.
+func (w *syncWatcher) runRound(ev syncWatchEvent) common.ExitCode {
+	round := w.template.newWatchRound(ev)
+	round.watcher = w
+
+	done := make(chan common.ExitCode, 1)
+	w.mu.Lock()
+	for w.paused && !w.stopping {
+		w.unpaused.Wait()
+	}
+	if w.stopping {
+		w.mu.Unlock()
+		return common.EExitCode.Success()
+	}
+	w.round = round
+	w.roundDone = done
+	w.mu.Unlock()
+
+	if err := round.process(); err != nil {
+		w.mu.Lock()
+		w.round = nil
+		w.mu.Unlock()
+		glcm.Warn(fmt.Sprintf("Cannot sync changes in '%s': %s", round.source.ValueLocal(), err.Error()))
+		notifyCommandFailed(err, errorExitCode(err))
+		return common.EExitCode.Error()
+	}
+
+	return <-done
+}
+
+// roundFinished is called by the round's ReportProgressOrExit, after it has printed its summary.
+func (w *syncWatcher) roundFinished(exitCode common.ExitCode) {
+	w.mu.Lock()
+	done := w.roundDone
+	w.round = nil
+	w.roundDone = nil
+	w.rounds++
+	w.lastRound = time.Now()
+	w.mu.Unlock()
+
+	if done != nil {
+		done <- exitCode
+	}
+}
+
+func (w *syncWatcher) shouldStop(exitCode common.ExitCode) bool {
+	w.mu.Lock()
+	stopping := w.stopping
+	w.mu.Unlock()
+
+	if stopping {
+		_ = w.tree.Close()
+		glcm.Exit(nil, exitCode)
+		return true
+	}
+	return false
+}
+
+func (w *syncWatcher) Cancel(lcm common.LifecycleMgr) {
+	w.mu.Lock()
+	w.stopping = true
+	w.unpaused.Broadcast()
+	round := w.round
+	w.mu.Unlock()
+
+	if round != nil {
+		round.Cancel(lcm) // we'll exit once the round has wound down
+		return
+	}
+
+	_ = w.tree.Close()
+	lcm.Exit(nil, common.EExitCode.Success())
+}
+
+func (w *syncWatcher) ReportProgressOrExit(lcm common.LifecycleMgr) (totalKnownCount uint32) {
+	w.mu.Lock()
+	round := w.round
+	rounds, lastRound := w.rounds, w.lastRound
+	w.mu.Unlock()
+
+	if round != nil {
+		return round.ReportProgressOrExit(lcm)
+	}
+
+	lcm.Progress(func(format common.OutputFormat) string {
+		if format == common.EOutputFormat.Json() {
+			return fmt.Sprintf(`{"Watching":%q,"SyncsCompleted":%d}`, w.template.source.ValueLocal(), rounds)
+		}
+		if rounds == 0 {
+			return "Watching for changes..."
+		}
+		return fmt.Sprintf("Watching for changes. %d syncs completed, last at %s", rounds, lastRound.Format(time.Kitchen))
+	})
+	return 0
+}
+
+// newWatchRound derives the arguments for syncing one directory from the user's original arguments.
+func (cca *cookedSyncCmdArgs) newWatchRound(ev syncWatchEvent) *cookedSyncCmdArgs {
+	round := &cookedSyncCmdArgs{}
+	*round = *cca
+
+	round.jobID = common.NewJobID()
+	round.recursive = ev.recursive && cca.recursive
+	round.atomicSourceFilesScanned = 0
+	round.atomicDestinationFilesScanned = 0
+	round.atomicScanningStatus = 0
+	round.atomicFirstPartOrdered = 0
+	round.atomicDeletionCount = 0
+	round.atomicSkippedSymlinkCount = 0
+	round.atomicSkippedSpecialFileCount = 0
+	round.atomicSkippedNodumpCount = 0
+	round.skippedSpecialFiles = &specialFileReport{}
+	round.isEnumerationComplete = false
+
+	if ev.relDir != "" {
+		round.source = cca.source.CloneWithValue(common.GenerateFullPath(cca.source.ValueLocal(), ev.relDir))
+
+		if cca.fromTo.To() == common.ELocation.Local() {
+			round.destination = cca.destination.CloneWithValue(common.GenerateFullPath(cca.destination.ValueLocal(), ev.relDir))
+			return round
+		}
+
+		segments := strings.Split(filepath.ToSlash(ev.relDir), "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		round.destination = cca.destination.CloneWithValue(strings.TrimSuffix(cca.destination.Value, "/") + "/" + strings.Join(segments, "/"))
+	}
+
+	return round
+}
+
+// coalesceSyncWatchEvents drops directories already covered by a recursive sync of one of their ancestors,
+// and orders the rest so parents are synced before their children.
+func coalesceSyncWatchEvents(pending map[string]bool) []syncWatchEvent {
+	dirs := make([]string, 0, len(pending))
+	for d := range pending {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+
+	var out []syncWatchEvent
+	for _, d := range dirs {
+		covered := false
+		for p := d; p != ""; {
+			p = syncWatchParent(p)
+			if pending[p] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, syncWatchEvent{relDir: d, recursive: pending[d]})
+		}
+	}
+	return out
+}
+
+func syncWatchParent(relDir string) string {
+	p := filepath.Dir(relDir)
+	if p == "." || p == string(filepath.Separator) {
+		return ""
+	}
+	return p
+}
@@ -0,0 +1,273 @@
+//go:build freebsd
+
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const kqueueWatchFlags = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB | unix.NOTE_DELETE | unix.NOTE_RENAME | unix.NOTE_REVOKE
+
+// kqueueTreeWatcher watches a tree with EVFILT_VNODE. kqueue has no recursive mode, so every directory and
+// regular file holds an open descriptor; large trees may need kern.maxfilesperproc raised accordingly.
+// Directory events tell us about entries being added, removed or renamed, file events about content changes.
+type kqueueTreeWatcher struct {
+	root      string
+	recursive bool
+	kq        int
+
+	// only touched by the run goroutine once it has started
+	paths map[int]string // fd -> path relative to root
+	fds   map[string]int // path relative to root -> fd
+	dirs  map[int]bool
+
+	events    chan syncWatchEvent
+	errors    chan error
+	err       error // why run gave up, set before events is closed
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newSyncTreeWatcher(root string, recursive bool) (syncTreeWatcher, error) {
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(kq)
+
+	w := &kqueueTreeWatcher{
+		root:      root,
+		recursive: recursive,
+		kq:        kq,
+		paths:     make(map[int]string),
+		fds:       make(map[string]int),
+		dirs:      make(map[int]bool),
+		events:    make(chan syncWatchEvent, 1024),
+		errors:    make(chan error, 16),
+		closed:    make(chan struct{}),
+	}
+
+	if err = w.watchTree(""); err != nil {
+		w.closeAll()
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *kqueueTreeWatcher) Events() <-chan syncWatchEvent {
+	return w.events
+}
+
+func (w *kqueueTreeWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *kqueueTreeWatcher) Err() error {
+	return w.err
+}
+
+// Close stops the watcher. Closing the kqueue is what wakes the run goroutine up.
+func (w *kqueueTreeWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		err = unix.Close(w.kq)
+	})
+	return err
+}
+
+func (w *kqueueTreeWatcher) run() {
+	defer close(w.events)
+	defer w.closeAll()
+
+	buf := make([]unix.Kevent_t, 64)
+	for {
+		n, err := unix.Kevent(w.kq, nil, buf, nil)
+		if err != nil {
+			select {
+			case <-w.closed:
+				return
+			default:
+			}
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			w.err = err
+			return
+		}
+
+		for _, ev := range buf[:n] {
+			w.handle(ev)
+		}
+	}
+}
+
+func (w *kqueueTreeWatcher) handle(ev unix.Kevent_t) {
+	fd := int(ev.Ident)
+	rel, ok := w.paths[fd]
+	if !ok {
+		return // already unwatched
+	}
+	gone := ev.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME|unix.NOTE_REVOKE) != 0
+
+	if !w.dirs[fd] {
+		if gone {
+			w.unwatch(rel)
+		}
+		w.emit(syncWatchEvent{relDir: syncWatchParent(rel)})
+		return
+	}
+
+	if gone {
+		w.unwatch(rel)
+		if rel == "" {
+			w.err = errors.New("the watched directory was removed or renamed")
+			_ = w.Close()
+			return
+		}
+		w.emit(syncWatchEvent{relDir: syncWatchParent(rel), recursive: true})
+		return
+	}
+
+	if ev.Fflags&unix.NOTE_WRITE != 0 {
+		w.watchNewEntries(rel)
+	}
+	w.emit(syncWatchEvent{relDir: rel})
+}
+
+// watchNewEntries picks up whatever has appeared in a directory since we last looked.
+// New subdirectories get a recursive sync of their own, since they may have arrived fully populated (e.g. by mv).
+func (w *kqueueTreeWatcher) watchNewEntries(rel string) {
+	entries, err := os.ReadDir(filepath.Join(w.root, rel))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.reportError(err)
+		}
+		return
+	}
+
+	for _, e := range entries {
+		child := filepath.Join(rel, e.Name())
+		if _, ok := w.fds[child]; ok {
+			continue
+		}
+
+		if e.IsDir() {
+			if !w.recursive {
+				continue
+			}
+			if err := w.watchTree(child); err != nil {
+				w.reportError(err)
+			}
+			w.emit(syncWatchEvent{relDir: child, recursive: true})
+		} else if e.Type().IsRegular() {
+			if err := w.watch(child, false); err != nil {
+				w.reportError(err)
+			}
+		}
+	}
+}
+
+func (w *kqueueTreeWatcher) watchTree(rel string) error {
+	return filepath.WalkDir(filepath.Join(w.root, rel), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		r, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		if r == "." {
+			r = ""
+		}
+
+		switch {
+		case d.IsDir():
+			if err = w.watch(r, true); err != nil {
+				return err
+			}
+			if r != "" && !w.recursive {
+				return filepath.SkipDir
+			}
+		case d.Type().IsRegular():
+			return w.watch(r, false)
+		}
+		return nil // symlinks and special files aren't watched; sync handles them according to its own flags
+	})
+}
+
+func (w *kqueueTreeWatcher) watch(rel string, isDir bool) error {
+	if _, ok := w.fds[rel]; ok {
+		return nil
+	}
+
+	fd, err := unix.Open(filepath.Join(w.root, rel), unix.O_RDONLY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil // gone again already
+		}
+		return err
+	}
+
+	var change unix.Kevent_t
+	unix.SetKevent(&change, fd, unix.EVFILT_VNODE, unix.EV_ADD|unix.EV_CLEAR)
+	change.Fflags = kqueueWatchFlags
+	if _, err = unix.Kevent(w.kq, []unix.Kevent_t{change}, nil, nil); err != nil {
+		_ = unix.Close(fd)
+		return err
+	}
+
+	w.paths[fd] = rel
+	w.fds[rel] = fd
+	w.dirs[fd] = isDir
+	return nil
+}
+
+// unwatch forgets a path, and everything under it if it was a directory. Closing the descriptor removes its knote.
+func (w *kqueueTreeWatcher) unwatch(rel string) {
+	prefix := rel + string(filepath.Separator)
+	for p, fd := range w.fds {
+		if p == rel || rel == "" || strings.HasPrefix(p, prefix) {
+			_ = unix.Close(fd)
+			delete(w.fds, p)
+			delete(w.paths, fd)
+			delete(w.dirs, fd)
+		}
+	}
+}
+
+func (w *kqueueTreeWatcher) closeAll() {
+	for fd := range w.paths {
+		_ = unix.Close(fd)
+	}
+	w.paths = map[int]string{}
+	w.fds = map[string]int{}
+	w.dirs = map[int]bool{}
+}
+
+func (w *kqueueTreeWatcher) emit(ev syncWatchEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.closed:
+	}
+}
+
+func (w *kqueueTreeWatcher) reportError(err error) {
+	select {
+	case w.errors <- err:
+	default: // don't stall watching just because nobody is draining errors
+	}
+}
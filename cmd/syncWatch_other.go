@@ -0,0 +1,9 @@
+//go:build !freebsd
+
+package cmd
+
+import "errors"
+
+func newSyncTreeWatcher(root string, recursive bool) (syncTreeWatcher, error) {
+	return nil, errors.New("sync --watch is only supported on FreeBSD")
+}
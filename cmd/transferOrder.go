@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// transferOrderer holds a job's transfers back until scanning has finished, and then gives them out in the order that
+// --order asks for. The STE starts the transfers of each part in turn, in the order they're in, so sorting them is all
+// it takes; but nothing starts until the last file has been found, and every transfer is held in memory until then.
+type transferOrderer struct {
+	order     common.TransferOrder
+	transfers []common.CopyTransfer
+}
+
+func newTransferOrderer(order common.TransferOrder) *transferOrderer {
+	if order == common.ETransferOrder.AsScanned() {
+		return nil
+	}
+	return &transferOrderer{order: order}
+}
+
+// hold keeps the transfer until release, and returns false if transfers are to be sent as they are found
+func (o *transferOrderer) hold(transfer common.CopyTransfer) bool {
+	if o == nil {
+		return false
+	}
+	o.transfers = append(o.transfers, transfer)
+	return true
+}
+
+// release gives the transfers that were held to send, in order. Files of the same size keep the order they were found
+// in, which puts them in the order of their paths for most sources.
+func (o *transferOrderer) release(send func(common.CopyTransfer) error) error {
+	if o == nil {
+		return nil
+	}
+	transfers := o.transfers
+	o.transfers = nil
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if o.order == common.ETransferOrder.LargestFirst() {
+			return transfers[i].SourceSize > transfers[j].SourceSize
+		}
+		return transfers[i].SourceSize < transfers[j].SourceSize
+	})
+	for _, t := range transfers {
+		if err := send(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
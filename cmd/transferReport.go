@@ -0,0 +1,53 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+// startTransferReport has a line written to the file at path, in format, for each transfer of the jobs that this
+// command runs. There is no report if path is empty.
+func startTransferReport(path, format string) error {
+	if format != ste.TransferReportFormatCSV && format != ste.TransferReportFormatJSON {
+		return fmt.Errorf("--%s must be %s or %s", TransferReportFormatFlag, ste.TransferReportFormatCSV, ste.TransferReportFormatJSON)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := ste.EnableTransferReport(path, format); err != nil {
+		return err
+	}
+	glcm.RegisterCloseFunc(func() {
+		// it was flushed when the last job finished, so there's nothing left to report a failure about
+		_ = ste.CloseTransferReport()
+	})
+	return nil
+}
+
+// finishTransferReport writes the rest of the transfer report for a job that has just finished
+func finishTransferReport() {
+	if err := ste.FlushTransferReport(); err != nil {
+		glcm.Warn(fmt.Sprintf("The transfer report is incomplete: %s", err))
+	}
+}
@@ -211,6 +211,9 @@ func InferArgumentLocation(arg string) common.Location {
 	if arg == pipeLocation {
 		return common.ELocation.Pipe()
 	}
+	if p, _ := common.LookupProvider(arg); p != nil {
+		return common.ELocation.Provider()
+	}
 	if startsWith(arg, "http") {
 		// Let's try to parse the argument as a URL
 		u, err := url.Parse(arg)
@@ -218,6 +221,9 @@ func InferArgumentLocation(arg string) common.Location {
 		if err == nil && u.Scheme != "" && u.Host != "" {
 			// Is the argument a URL to blob storage?
 			switch host := strings.ToLower(u.Host); true {
+			// an S3-compatible service the user has told us about, which may well be on an IP address
+			case common.IsS3CompatibleHost(host):
+				return common.ELocation.S3()
 			// Azure Stack does not have the core.windows.net
 			case strings.Contains(host, ".blob"):
 				return common.ELocation.Blob()
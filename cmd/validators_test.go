@@ -65,3 +65,20 @@ func TestInferArgumentLocation(t *testing.T) {
 		a.Equal(v.expectedLocation, loc)
   }
 }
+
+func TestInferArgumentLocationS3Compatible(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(common.ELocation.Unknown(), InferArgumentLocation("http://10.0.0.5:9000/bucket/key"))
+
+	t.Setenv(common.EEnvironmentVariable.S3Endpoint().Name, "http://10.0.0.5:9000")
+	a.Equal(common.ELocation.S3(), InferArgumentLocation("http://10.0.0.5:9000/bucket/key"))
+	a.Equal(common.ELocation.Unknown(), InferArgumentLocation("http://10.0.0.6:9000/bucket/key"))
+}
+
+func TestValidateFromToLocalLocal(t *testing.T) {
+	a := assert.New(t)
+
+	fromTo, err := ValidateFromTo(t.TempDir(), t.TempDir(), "")
+	a.NoError(err)
+	a.Equal(common.EFromTo.LocalLocal(), fromTo)
+}
@@ -0,0 +1,603 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+type rawVerifyCmdArgs struct {
+	// obtained from arguments
+	src    string
+	dst    string
+	fromTo string
+
+	recursive     bool
+	hashAlgorithm string
+	checkHash     string
+	report        string
+	signingKey    string
+	manifest      string
+}
+
+type cookedVerifyCmdArgs struct {
+	source      common.ResourceString
+	destination common.ResourceString
+	fromTo      common.FromTo
+
+	// set instead of the source when the destination is verified against a manifest, which azcopy hash writes
+	manifestPath        string
+	destinationLocation common.Location
+
+	recursive      bool
+	hashType       common.ContentHashType
+	hashValidation common.HashValidationOption
+	reportPath     string
+	signer         *common.ReportSigner
+}
+
+func (raw rawVerifyCmdArgs) cook() (cooked cookedVerifyCmdArgs, err error) {
+	if raw.manifest != "" {
+		// the only argument is the destination
+		if cooked.destinationLocation, err = ValidateArgumentLocation(raw.dst, raw.fromTo); err != nil {
+			return cooked, err
+		}
+		if cooked.destination, err = splitHashedResourceString(raw.dst, cooked.destinationLocation); err != nil {
+			return cooked, err
+		}
+		cooked.manifestPath = raw.manifest
+	} else {
+		cooked.fromTo, err = ValidateFromTo(raw.src, raw.dst, raw.fromTo)
+		if err != nil {
+			return cooked, err
+		}
+		if cooked.fromTo == common.EFromTo.Unknown() || cooked.fromTo == common.EFromTo.LocalLocal() || cooked.fromTo.IsSetProperties() || cooked.fromTo.IsDelete() {
+			return cooked, fmt.Errorf("source '%s' / destination '%s' combination '%s' not supported for verify command ", raw.src, raw.dst, cooked.fromTo)
+		}
+		if cooked.source, err = splitHashedResourceString(raw.src, cooked.fromTo.From()); err != nil {
+			return cooked, err
+		}
+		if cooked.destination, err = splitHashedResourceString(raw.dst, cooked.fromTo.To()); err != nil {
+			return cooked, err
+		}
+		cooked.destinationLocation = cooked.fromTo.To()
+	}
+
+	cooked.recursive = raw.recursive
+	if raw.hashAlgorithm == "" && raw.manifest != "" {
+		// whatever the manifest is of, which for one that azcopy hash wrote is SHA256 unless it was told otherwise
+		f, err := os.Open(raw.manifest)
+		if err != nil {
+			return cooked, fmt.Errorf("failed to open the manifest: %w", err)
+		}
+		cooked.hashType, err = manifestHashType(f)
+		_ = f.Close()
+		if err != nil {
+			return cooked, fmt.Errorf("failed to read the manifest: %w", err)
+		}
+	} else if err = cooked.hashType.Parse(common.Iff(raw.hashAlgorithm == "", common.EContentHashType.MD5().String(), raw.hashAlgorithm)); err != nil {
+		return cooked, err
+	}
+	if err = cooked.hashValidation.Parse(raw.checkHash); err != nil {
+		return cooked, err
+	}
+
+	if raw.signingKey != "" && raw.report == "" {
+		return cooked, errors.New("--signing-key signs the report, so --report must say where to write it")
+	}
+	cooked.reportPath = raw.report
+	if raw.signingKey != "" {
+		if cooked.signer, err = common.NewReportSigner(raw.signingKey); err != nil {
+			return cooked, err
+		}
+	}
+	return cooked, nil
+}
+
+// the outcome of verifying a file
+const (
+	verifyMatched              = "Matched"
+	verifyDifferent            = "Different"
+	verifyMissingAtDestination = "MissingAtDestination"
+	verifyHashMissing          = "HashMissing"
+	verifyUnverifiable         = "Unverifiable"
+)
+
+type VerifyEntry struct {
+	Path   string
+	Size   int64
+	Status string
+	Detail string `json:",omitempty"`
+
+	hashesDiffer bool // only the hashes differ, which LogOnly doesn't fail verification for
+}
+
+// VerifyReport is what is written to --report, and signed with --signing-key
+type VerifyReport struct {
+	Source         string
+	Destination    string
+	HashAlgorithm  string
+	HashValidation string
+	StartTime      time.Time
+	EndTime        time.Time
+
+	FilesMatched           int
+	FilesDifferent         int
+	FilesMissing           int
+	HashesMissing          int
+	FilesUnverifiable      int
+	FilesOnlyAtDestination int
+
+	// Passed is true if nothing was found that the hash validation option treats as a failure
+	Passed bool
+
+	Entries []VerifyEntry
+}
+
+// verifiableContent is what a file is compared by: its size, and its hash of the type being compared, if there is one
+type verifiableContent struct {
+	size         int64 // -1 if unknown, as it is in manifests that don't record sizes
+	hash         []byte
+	localPath    string // set for local files, which are hashed only if there's a hash to compare with
+	unverifiable string // why the file can't be compared, if it can't be
+}
+
+// contentHasher describes files by their size and their hash of one type, for verify and hash
+type contentHasher struct {
+	hashType common.ContentHashType
+
+	// reads a local file to hash it; replaced in tests
+	openLocal func(path string) (io.ReadCloser, error)
+}
+
+func newContentHasher(hashType common.ContentHashType) *contentHasher {
+	return &contentHasher{
+		hashType:  hashType,
+		openLocal: func(path string) (io.ReadCloser, error) { return os.Open(path) },
+	}
+}
+
+type verifier struct {
+	*contentHasher
+	cooked cookedVerifyCmdArgs
+	report VerifyReport
+}
+
+func newVerifier(cooked cookedVerifyCmdArgs) *verifier {
+	source := cooked.source.Value
+	if cooked.manifestPath != "" {
+		source = cooked.manifestPath
+	}
+	return &verifier{
+		contentHasher: newContentHasher(cooked.hashType),
+		cooked:        cooked,
+		report: VerifyReport{
+			Source:         source,
+			Destination:    cooked.destination.Value,
+			HashAlgorithm:  cooked.hashType.String(),
+			HashValidation: cooked.hashValidation.String(),
+			StartTime:      time.Now().UTC(),
+		},
+	}
+}
+
+// content describes an object at the given location. Remote objects are described by their properties, and by the hash
+// the service (or AzCopy's upload) recorded of them, since re-reading them would be a download.
+func (h *contentHasher) content(location common.Location, root common.ResourceString, object StoredObject) verifiableContent {
+	if location == common.ELocation.Local() {
+		return verifiableContent{size: object.size, localPath: common.GenerateFullPath(root.ValueLocal(), object.relativePath)}
+	}
+
+	c := verifiableContent{size: object.size}
+	if _, encrypted := common.TryReadMetadata(object.Metadata, common.ClientSideEncryptionMeta); encrypted {
+		c.unverifiable = "it is encrypted on the client, so it would have to be downloaded to be compared"
+		return c
+	}
+	if rawSize, compressed := common.TryReadMetadata(object.Metadata, common.UncompressedSizeMeta); compressed && rawSize != nil {
+		// it was compressed as AzCopy uploaded it, so it is compared by what it decompresses to
+		size, err := strconv.ParseInt(*rawSize, 10, 64)
+		if err != nil {
+			c.unverifiable = "its uncompressed size is recorded wrongly"
+			return c
+		}
+		c.size = size
+		if h.hashType == common.EContentHashType.MD5() {
+			if rawMd5, ok := common.TryReadMetadata(object.Metadata, common.UncompressedMD5Meta); ok && rawMd5 != nil {
+				c.hash, _ = base64.StdEncoding.DecodeString(*rawMd5)
+			}
+		}
+		return c
+	}
+
+	if h.hashType == common.EContentHashType.MD5() {
+		c.hash = object.md5
+	} else {
+		c.hash = h.hashType.ReadFromMetadata(object.Metadata)
+	}
+	return c
+}
+
+// hash fills in the hash of a local file, by reading it
+func (h *contentHasher) hash(c *verifiableContent) error {
+	if c.localPath == "" || len(c.hash) > 0 {
+		return nil
+	}
+	f, err := h.openLocal(c.localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hasher := h.hashType.NewHasher()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return err
+	}
+	c.hash = hasher.Sum(nil)
+	return nil
+}
+
+// compare verifies that a file at the destination matches the one at the source
+func (v *verifier) compare(path string, src, dst verifiableContent) VerifyEntry {
+	entry := VerifyEntry{Path: path, Size: common.Iff(src.size >= 0, src.size, dst.size)}
+	switch {
+	case src.unverifiable != "":
+		entry.Status, entry.Detail = verifyUnverifiable, "the source can't be compared, since "+src.unverifiable
+		return entry
+	case dst.unverifiable != "":
+		entry.Status, entry.Detail = verifyUnverifiable, "the destination can't be compared, since "+dst.unverifiable
+		return entry
+	case src.size >= 0 && src.size != dst.size:
+		entry.Status, entry.Detail = verifyDifferent, fmt.Sprintf("the source is %d bytes, and the destination %d", src.size, dst.size)
+		return entry
+	case v.cooked.hashValidation == common.EHashValidationOption.NoCheck():
+		entry.Status = verifyMatched
+		return entry
+	}
+
+	// a local file is only worth reading if there's a hash to compare it with
+	if (src.localPath == "" && len(src.hash) == 0) || (dst.localPath == "" && len(dst.hash) == 0) {
+		entry.Status, entry.Detail = verifyHashMissing, fmt.Sprintf("there is no %s hash to compare with", v.cooked.hashType)
+		return entry
+	}
+	for _, c := range []*verifiableContent{&src, &dst} {
+		if err := v.hash(c); err != nil {
+			entry.Status, entry.Detail = verifyUnverifiable, "reading the file failed: "+err.Error()
+			return entry
+		}
+	}
+	if !bytes.Equal(src.hash, dst.hash) {
+		entry.Status, entry.Detail = verifyDifferent, fmt.Sprintf("the %s hashes differ", v.cooked.hashType)
+		entry.hashesDiffer = true
+		return entry
+	}
+	entry.Status = verifyMatched
+	return entry
+}
+
+func (v *verifier) record(entry VerifyEntry) {
+	switch entry.Status {
+	case verifyMatched:
+		v.report.FilesMatched++
+	case verifyDifferent:
+		v.report.FilesDifferent++
+	case verifyMissingAtDestination:
+		v.report.FilesMissing++
+	case verifyHashMissing:
+		v.report.HashesMissing++
+	case verifyUnverifiable:
+		v.report.FilesUnverifiable++
+	}
+	if entry.Status != verifyMatched && azcopyScanningLogger != nil {
+		azcopyScanningLogger.Log(common.LogWarning, fmt.Sprintf("%s: %s %s", entry.Path, entry.Status, entry.Detail))
+	}
+	v.report.Entries = append(v.report.Entries, entry)
+}
+
+// finish sorts the entries, so that the same trees make the same report, and decides whether verification passed
+func (v *verifier) finish() {
+	sort.Slice(v.report.Entries, func(i, j int) bool { return v.report.Entries[i].Path < v.report.Entries[j].Path })
+	v.report.EndTime = time.Now().UTC()
+
+	r := &v.report
+	r.Passed = r.FilesMissing == 0 && r.FilesUnverifiable == 0
+	switch v.cooked.hashValidation {
+	case common.EHashValidationOption.LogOnly():
+		// hashes that differ are reported, but don't fail verification; sizes that differ still do
+		r.Passed = r.Passed && !hasSizeDifference(r.Entries)
+	case common.EHashValidationOption.FailIfDifferentOrMissing():
+		r.Passed = r.Passed && r.FilesDifferent == 0 && r.HashesMissing == 0
+	default:
+		r.Passed = r.Passed && r.FilesDifferent == 0
+	}
+}
+
+func hasSizeDifference(entries []VerifyEntry) bool {
+	for _, e := range entries {
+		if e.Status == verifyDifferent && !e.hashesDiffer {
+			return true
+		}
+	}
+	return false
+}
+
+func getVerifyCredentialInfo(ctx context.Context, location common.Location, resource common.ResourceString) (common.CredentialInfo, error) {
+	credentialInfo, _, err := GetCredentialInfoForLocation(ctx, location, resource, true, common.CpkOptions{})
+	if err != nil {
+		return credentialInfo, fmt.Errorf("failed to obtain credential info: %s", err.Error())
+	}
+	if credentialInfo.CredentialType.IsAzureOAuth() {
+		tokenInfo, err := GetUserOAuthTokenManagerInstance().GetTokenInfo(ctx)
+		if err != nil {
+			return credentialInfo, err
+		}
+		credentialInfo.OAuthTokenInfo = *tokenInfo
+	}
+	return credentialInfo, nil
+}
+
+// splitHashedResourceString splits a location that verify or hash reads, which may be local
+func splitHashedResourceString(raw string, location common.Location) (common.ResourceString, error) {
+	if location == common.ELocation.Local() {
+		return common.ResourceString{Value: common.ToExtendedPath(cleanLocalPath(raw))}, nil
+	}
+	return SplitResourceString(raw, location)
+}
+
+// newHashingTraverser enumerates the files at a location whose hashes verify or hash reads
+func newHashingTraverser(ctx context.Context, location common.Location, resource common.ResourceString, recursive bool) (ResourceTraverser, error) {
+	credentialInfo, err := getVerifyCredentialInfo(ctx, location, resource)
+	if err != nil {
+		return nil, err
+	}
+	return InitResourceTraverser(resource, location, ctx, InitResourceTraverserOptions{
+		Credential: &credentialInfo,
+
+		Recursive:               recursive,
+		GetPropertiesInFrontend: true,
+		HardlinkHandling:        common.EHardlinkHandlingType.Follow(),
+	})
+}
+
+// verifiedSource is a file at the source, or in the manifest, waiting for its counterpart at the destination
+type verifiedSource struct {
+	path    string
+	content verifiableContent
+}
+
+// sources reads what the destination is verified against: the files at the source, or the entries of the manifest
+func (cooked cookedVerifyCmdArgs) sources(ctx context.Context, v *verifier, caseInsensitive bool) (map[string]verifiedSource, error) {
+	sources := make(map[string]verifiedSource)
+	key := func(path string) string {
+		if caseInsensitive {
+			return strings.ToLower(path)
+		}
+		return path
+	}
+
+	if cooked.manifestPath != "" {
+		f, err := os.Open(cooked.manifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the manifest: %w", err)
+		}
+		defer f.Close()
+		entries, err := readHashManifest(f, cooked.hashType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the manifest: %w", err)
+		}
+		for _, e := range entries {
+			sources[key(e.Path)] = verifiedSource{path: e.Path, content: verifiableContent{size: e.Size, hash: e.Hash}}
+		}
+		return sources, nil
+	}
+
+	sourceTraverser, err := newHashingTraverser(ctx, cooked.fromTo.From(), cooked.source, cooked.recursive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the source traverser: %w", err)
+	}
+	err = sourceTraverser.Traverse(noPreProccessor, func(src StoredObject) error {
+		sources[key(src.relativePath)] = verifiedSource{path: src.relativePath, content: v.content(cooked.fromTo.From(), cooked.source, src)}
+		return nil
+	}, []ObjectFilter{&verifyFileFilter{}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to traverse the source: %w", err)
+	}
+	return sources, nil
+}
+
+// process enumerates the source (or reads the manifest), then the destination, and compares each file at the source
+// with the one of the same path at the destination
+func (cooked cookedVerifyCmdArgs) process() (*VerifyReport, error) {
+	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	v := newVerifier(cooked)
+
+	caseInsensitive := IsDestinationCaseInsensitive(cooked.fromTo)
+	sources, err := cooked.sources(ctx, v, caseInsensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	destinationTraverser, err := newHashingTraverser(ctx, cooked.destinationLocation, cooked.destination, cooked.recursive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the destination traverser: %w", err)
+	}
+	err = destinationTraverser.Traverse(noPreProccessor, func(dst StoredObject) error {
+		key := dst.relativePath
+		if cooked.manifestPath != "" {
+			// a manifest of a single file names it
+			key = manifestPath(dst)
+		}
+		if caseInsensitive {
+			key = strings.ToLower(key)
+		}
+		src, ok := sources[key]
+		if !ok {
+			v.report.FilesOnlyAtDestination++
+			return nil
+		}
+		delete(sources, key)
+		v.record(v.compare(src.path, src.content, v.content(cooked.destinationLocation, cooked.destination, dst)))
+		return nil
+	}, []ObjectFilter{&verifyFileFilter{}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to traverse the destination: %w", err)
+	}
+
+	for _, src := range sources {
+		v.record(VerifyEntry{Path: src.path, Size: src.content.size, Status: verifyMissingAtDestination})
+	}
+	v.finish()
+
+	if cooked.reportPath != "" {
+		report, err := json.MarshalIndent(v.report, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err = common.WriteSignedReport(cooked.reportPath, report, cooked.signer); err != nil {
+			return nil, fmt.Errorf("writing the report: %w", err)
+		}
+	}
+	return &v.report, nil
+}
+
+// verifyFileFilter passes only files, since folders have no content to verify
+type verifyFileFilter struct{}
+
+func (f *verifyFileFilter) DoesSupportThisOS() (msg string, supported bool) {
+	return "", true
+}
+
+func (f *verifyFileFilter) AppliesOnlyToFiles() bool {
+	return false
+}
+
+func (f *verifyFileFilter) DoesPass(storedObject StoredObject) bool {
+	return storedObject.entityType == common.EEntityType.File()
+}
+
+func (r *VerifyReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Files matched: %d\n", r.FilesMatched)
+	fmt.Fprintf(&sb, "Files different: %d\n", r.FilesDifferent)
+	fmt.Fprintf(&sb, "Files missing at the destination: %d\n", r.FilesMissing)
+	fmt.Fprintf(&sb, "Files without a %s hash to compare: %d\n", r.HashAlgorithm, r.HashesMissing)
+	fmt.Fprintf(&sb, "Files that couldn't be compared: %d\n", r.FilesUnverifiable)
+	fmt.Fprintf(&sb, "Files only at the destination (not verified): %d\n", r.FilesOnlyAtDestination)
+	for _, e := range r.Entries {
+		if e.Status != verifyMatched {
+			fmt.Fprintf(&sb, "  %s: %s. %s\n", e.Status, e.Path, e.Detail)
+		}
+	}
+	if r.Passed {
+		sb.WriteString("Verification passed")
+	} else {
+		sb.WriteString("Verification FAILED")
+	}
+	return sb.String()
+}
+
+func init() {
+	raw := rawVerifyCmdArgs{}
+
+	verifyCmd := &cobra.Command{
+		Use:     "verify [source] [destination]",
+		Short:   verifyCmdShortDescription,
+		Long:    verifyCmdLongDescription,
+		Example: verifyCmdExample,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if raw.manifest != "" {
+				// the manifest stands in for the source
+				if len(args) != 1 {
+					return errors.New("with --manifest, verify command requires only the destination")
+				}
+				raw.dst = args[0]
+				return nil
+			}
+			if len(args) != 2 {
+				return errors.New("verify command requires both the source and the destination")
+			}
+			raw.src = args[0]
+			raw.dst = args[1]
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cooked, err := raw.cook()
+			if err != nil {
+				glcm.Error("failed to parse user input due to error: " + err.Error())
+				return
+			}
+
+			azcopyScanningLogger = common.NewJobLogger(Client.CurrentJobID, LogLevel, common.LogPathFolder, "-scanning")
+			azcopyScanningLogger.OpenLog()
+			glcm.RegisterCloseFunc(func() {
+				azcopyScanningLogger.CloseLog()
+			})
+
+			report, err := cooked.process()
+			if err != nil {
+				glcm.Error(err.Error() + getErrorCodeUrl(err))
+				return
+			}
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(report)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
+				}
+				return report.String()
+			}, common.Iff(report.Passed, common.EExitCode.Success(), common.EExitCode.Error()))
+		},
+	}
+
+	verifyCmd.PersistentFlags().StringVar(&raw.fromTo, "from-to", "", "Optionally specifies the source destination combination. For Example: LocalBlob, BlobLocal, BlobBlob. "+
+		"\n With --manifest, specifies the location of the destination instead. For Example: Local, Blob")
+	verifyCmd.PersistentFlags().BoolVar(&raw.recursive, "recursive", true, "True by default, look into sub-directories recursively when verifying between directories.")
+	verifyCmd.PersistentFlags().StringVar(&raw.hashAlgorithm, "hash-algorithm", "",
+		"The hash to compare files by. "+
+			"\n Available options: MD5, SHA256, CRC64 (default 'MD5', or with --manifest, the hash that the manifest is of). The hash of a remote file is the one recorded by --put-md5, "+
+			"\n in its Content-MD5 property for MD5 and in its metadata for the others. Local files are read to hash them.")
+	verifyCmd.PersistentFlags().StringVar(&raw.checkHash, "check-hash", common.DefaultHashValidationOption.String(),
+		"Specifies how strictly hashes should be compared. "+
+			"\n Available options: NoCheck (sizes only), LogOnly, FailIfDifferent, FailIfDifferentOrMissing (default 'FailIfDifferent').")
+	verifyCmd.PersistentFlags().StringVar(&raw.manifest, "manifest", "",
+		"Verify the destination against this manifest, as written by azcopy hash or sha256sum, instead of against a source. "+
+			"\n If --hash-algorithm is given, the manifest must be of that hash.")
+	verifyCmd.PersistentFlags().StringVar(&raw.report, "report", "", "Write a report of every file verified, in JSON, to this file.")
+	verifyCmd.PersistentFlags().StringVar(&raw.signingKey, "signing-key", "",
+		"Sign the report with the private key in this PEM file (Ed25519, RSA or ECDSA), writing the signature to the report's path with .sig appended. "+
+			"\n The signature can be checked with OpenSSL.")
+
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -90,11 +90,17 @@ type StoredObject struct {
 	blobTags       common.BlobTags
 	blobSnapshotID string
 	blobDeleted    bool
+	// encryption scope, only included by blob traverser.
+	blobEncryptionScope string
 
 	// Lease information
 	leaseState    lease.StateType
 	leaseStatus   lease.StatusType
 	leaseDuration lease.DurationType
+
+	// the full URL of an object from a list of URLs, which doesn't share a root with the others.
+	// Its relativePath is only where it goes at the destination.
+	sourceURL string
 }
 
 func (s *StoredObject) isMoreRecentThan(storedObject2 StoredObject, preferSMBTime bool) bool {
@@ -141,7 +147,7 @@ func (s *StoredObject) isCompatibleWithEntitySettings(fpo common.FolderPropertyO
 	} else if s.entityType == common.EEntityType.Symlink() {
 		return sht == common.ESymlinkHandlingType.Preserve()
 	} else if s.entityType == common.EEntityType.Hardlink() {
-		return pho == common.EHardlinkHandlingType.Follow()
+		return pho == common.EHardlinkHandlingType.Follow() || pho == common.EHardlinkHandlingType.Preserve()
 	} else if s.entityType == common.EEntityType.Other() {
 		return false
 	} else {
@@ -206,6 +212,8 @@ func (s *StoredObject) ToNewCopyTransfer(steWillAutoDecompress bool,
 		BlobVersionID:      s.blobVersionID,
 		BlobTags:           s.blobTags,
 		BlobSnapshotID:     s.blobSnapshotID,
+
+		BlobEncryptionScope: s.blobEncryptionScope,
 	}
 
 	if preserveBlobTier {
@@ -374,10 +382,24 @@ type InitResourceTraverserOptions struct {
 	IncludeDirectoryStubs   bool // Blob, BlobFS
 	PreserveBlobTags        bool // Blob, BlobFS
 	StripTopDir             bool // Local
+	OneFileSystem           bool // Local
+	ExcludeNodump           bool // Local
+
+	IncrementNodumpSkipped func() // Local, with ExcludeNodump
+
+	SpecialFileHandling common.SpecialFileHandlingType // Local
+	ReportSpecialFile   func(path, kind string)        // Local, for each special file skipped
 
 	ExcludeContainers []string // Blob account
 	ListVersions      bool     // Blob
+	ListSnapshots     bool     // Blob
 	HardlinkHandling  common.HardlinkHandlingType
+
+	ListingCheckpoint *listingCheckpoint // Blob container; continues an interrupted listing
+	ScanCheckpoint    *scanCheckpoint    // Local, Blob container; records how far a copy's scan has got, and carries on an interrupted one
+	InventoryManifest string             // Blob container; lists it from the blob inventory with this manifest instead (see blobInventory.go)
+
+	ScanConcurrency int // Local, Blob container, Files share; how many directories are read at once, or 0 for EnumerationParallelism
 }
 
 func (o *InitResourceTraverserOptions) PerformChecks() error {
@@ -388,6 +410,15 @@ func (o *InitResourceTraverserOptions) PerformChecks() error {
 	return nil
 }
 
+// scanParallelism is how many directories a traverser reads at once: ScanConcurrency, from --scan-concurrency, if it's
+// given, or else EnumerationParallelism
+func (o *InitResourceTraverserOptions) scanParallelism() int {
+	if o.ScanConcurrency > 0 {
+		return o.ScanConcurrency
+	}
+	return EnumerationParallelism
+}
+
 func InitResourceTraverser(resource common.ResourceString, resourceLocation common.Location, ctx context.Context, opts InitResourceTraverserOptions) (ResourceTraverser, error) {
 	if ctx == nil {
 		return nil, errors.New("a valid context must be supplied to create a traverser")
@@ -510,6 +541,11 @@ func InitResourceTraverser(resource common.ResourceString, resourceLocation comm
 				return nil, errors.New(accountTraversalInherentlyRecursiveError)
 			}
 			output = newBlobAccountTraverser(bsc, containerName, ctx, opts)
+		} else if opts.InventoryManifest != "" {
+			output, err = newBlobInventoryTraverser(r, bsc, ctx, opts, &blob.ClientOptions{ClientOptions: options})
+			if err != nil {
+				return nil, err
+			}
 		} else if opts.ListOfVersionIDs != nil {
 			output = newBlobVersionsTraverser(r, bsc, ctx, opts)
 		} else {
@@ -673,6 +709,16 @@ func InitResourceTraverser(resource common.ResourceString, resourceLocation comm
 			}
 		}
 
+	case common.ELocation.Provider():
+		output, err = newProviderTraverser(resource, ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+	case common.ELocation.URLList():
+		output, err = newURLListTraverser(resource.ValueLocal(), ctx, opts)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errors.New("could not choose a traverser from currently available traversers")
 	}
@@ -751,6 +797,9 @@ type syncEnumerator struct {
 
 	// a finalizer that is always called if the enumeration finishes properly
 	finalize func() error
+
+	// records where the scans of both sides have got to, if it's set
+	progress *scanProgress
 }
 
 func newSyncEnumerator(primaryTraverser, secondaryTraverser ResourceTraverser, indexer *objectIndexer,
@@ -765,21 +814,30 @@ func newSyncEnumerator(primaryTraverser, secondaryTraverser ResourceTraverser, i
 	}
 }
 
+// isMissingResourceError reports whether err says that what was to be scanned doesn't exist
+func isMissingResourceError(err error) bool {
+	switch {
+	case err == nil: // don't do any error checking
+		return false
+	case fileerror.HasCode(err, fileerror.ResourceNotFound),
+		datalakeerror.HasCode(err, datalakeerror.ResourceNotFound),
+		bloberror.HasCode(err, bloberror.BlobNotFound),
+		strings.Contains(err.Error(), "The system cannot find the"),
+		errors.Is(err, os.ErrNotExist):
+		return true
+	}
+	return false
+}
+
 func (e *syncEnumerator) enumerate() (err error) {
 	handleAcceptableErrors := func() {
-		switch {
-		case err == nil: // don't do any error checking
-		case fileerror.HasCode(err, fileerror.ResourceNotFound),
-			datalakeerror.HasCode(err, datalakeerror.ResourceNotFound),
-			bloberror.HasCode(err, bloberror.BlobNotFound),
-			strings.Contains(err.Error(), "The system cannot find the"),
-			errors.Is(err, os.ErrNotExist):
+		if isMissingResourceError(err) {
 			err = nil // Oh no! Oh well. We'll create it later.
 		}
 	}
 
 	// enumerate the primary resource and build lookup map
-	err = e.primaryTraverser.Traverse(noPreProccessor, e.objectIndexer.store, e.filters)
+	err = e.primaryTraverser.Traverse(noPreProccessor, e.progress.tracking(e.objectIndexer.store), e.filters)
 	handleAcceptableErrors()
 	if err != nil {
 		return err
@@ -789,7 +847,7 @@ func (e *syncEnumerator) enumerate() (err error) {
 	// they will be passed to the object comparator
 	// which can process given objects based on what's already indexed
 	// note: transferring can start while scanning is ongoing
-	err = e.secondaryTraverser.Traverse(noPreProccessor, e.objectComparator, e.filters)
+	err = e.secondaryTraverser.Traverse(noPreProccessor, e.progress.tracking(e.objectComparator), e.filters)
 	handleAcceptableErrors()
 	if err != nil {
 		return
@@ -21,6 +21,8 @@
 package cmd
 
 import (
+	"encoding/base64"
+	"net/http"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
@@ -335,3 +337,47 @@ func (a shareDirectoryPropertiesAdapter) LinkCount() int64 {
 func (a shareDirectoryPropertiesAdapter) FileID() string {
 	return common.IffNotNil(a.GetPropertiesResponse.ID, "")
 }
+
+// providerObjectPropertiesAdapter adapts a ProviderObject to the contentPropsProvider interface
+type providerObjectPropertiesAdapter struct {
+	emptyPropertiesAdapter
+	obj common.ProviderObject
+}
+
+func (a providerObjectPropertiesAdapter) ContentType() string {
+	return a.obj.ContentType
+}
+
+func (a providerObjectPropertiesAdapter) ContentMD5() []byte {
+	return a.obj.ContentMD5
+}
+
+// httpHeaderPropertiesAdapter adapts the headers of an http(s) response to the contentPropsProvider interface
+type httpHeaderPropertiesAdapter struct {
+	header http.Header
+}
+
+func (a httpHeaderPropertiesAdapter) CacheControl() string {
+	return a.header.Get("Cache-Control")
+}
+
+func (a httpHeaderPropertiesAdapter) ContentDisposition() string {
+	return a.header.Get("Content-Disposition")
+}
+
+func (a httpHeaderPropertiesAdapter) ContentEncoding() string {
+	return a.header.Get("Content-Encoding")
+}
+
+func (a httpHeaderPropertiesAdapter) ContentLanguage() string {
+	return a.header.Get("Content-Language")
+}
+
+func (a httpHeaderPropertiesAdapter) ContentType() string {
+	return a.header.Get("Content-Type")
+}
+
+func (a httpHeaderPropertiesAdapter) ContentMD5() []byte {
+	md5, _ := base64.StdEncoding.DecodeString(a.header.Get("Content-MD5"))
+	return md5
+}
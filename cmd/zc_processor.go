@@ -49,6 +49,9 @@ type copyTransferProcessor struct {
 	symlinkHandlingType    common.SymlinkHandlingType
 	dryrunMode             bool
 	hardlinkHandlingType   common.HardlinkHandlingType
+
+	// holds the transfers back until the final part, to send them in order; nil to send them as they come
+	orderer *transferOrderer
 }
 
 func newCopyTransferProcessor(copyJobTemplate *common.CopyJobPartOrderRequest, numOfTransfersPerPart int, source, destination common.ResourceString, reportFirstPartDispatched func(bool), reportFinalPartDispatched func(), preserveAccessTier, dryrunMode bool) *copyTransferProcessor {
@@ -275,6 +278,14 @@ func (s *copyTransferProcessor) scheduleCopyTransfer(storedObject StoredObject)
 		return nil
 	}
 
+	if s.orderer.hold(copyTransfer) {
+		return nil
+	}
+	return s.addToPart(copyTransfer)
+}
+
+// addToPart adds a transfer to the part being gathered, sending the part to the STE first if it is full
+func (s *copyTransferProcessor) addToPart(copyTransfer common.CopyTransfer) error {
 	if len(s.copyJobTemplate.Transfers.List) == s.numOfTransfersPerPart {
 		resp := s.sendPartToSte()
 
@@ -311,6 +322,10 @@ var NothingScheduledError = errors.New("no transfers were scheduled because no f
 var FinalPartCreatedMessage = "Final job part has been created"
 
 func (s *copyTransferProcessor) dispatchFinalPart() (copyJobInitiated bool, err error) {
+	if err = s.orderer.release(s.addToPart); err != nil {
+		return false, err
+	}
+
 	var resp common.CopyJobPartOrderResponse
 	s.copyJobTemplate.IsFinalPart = true
 	resp = s.sendPartToSte()
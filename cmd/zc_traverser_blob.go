@@ -62,6 +62,21 @@ type blobTraverser struct {
 	include common.BlobTraverserIncludeOption
 
 	isDFS bool
+
+	// if set, the listing is serial, and records how far it got, so that it can carry on from there if interrupted
+	listingCheckpoint listingMarkers
+
+	// how many virtual directories are listed at once, when listing in parallel
+	parallelism int
+}
+
+// listingMarkers keeps the marker that a flat listing has got to, to carry it on from there (see listingCheckpoint and
+// scanCheckpoint)
+type listingMarkers interface {
+	// marker returns the marker to continue the listing from, or nil if it should start at the beginning
+	marker(listing string) (*string, error)
+	// save records that everything before marker has been processed. A nil marker means that the listing has finished.
+	save(listing string, marker *string) error
 }
 
 var NonErrorDirectoryStubOverlappable = errors.New("The directory stub exists, and can overlap.")
@@ -211,8 +226,8 @@ func (t *blobTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 		// Don't error out unless it's a CPK error just yet
 		// If it's a CPK error, we know it's a single blob and that we can't get the properties on it anyway.
 		if respErr.ErrorCode == string(bloberror.BlobUsesCustomerSpecifiedEncryption) {
-			return errors.New("this blob uses customer provided encryption keys (CPK). At the moment, AzCopy does not support CPK-encrypted blobs. " +
-				"If you wish to make use of this blob, we recommend using one of the Azure Storage SDKs")
+			return errors.New("this blob uses customer provided encryption keys (CPK). Provide its key with --cpk-by-value or --cpk-by-name to access it. " +
+				"Blobs encrypted with a customer provided key can't be the source of a service-to-service copy")
 		}
 		if respErr.RawResponse == nil {
 			return fmt.Errorf("cannot list files due to reason %s", respErr)
@@ -255,6 +270,7 @@ func (t *blobTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 			blobPropsAdapter.Metadata,
 			blobURLParts.ContainerName,
 		)
+		storedObject.blobEncryptionScope = common.IffNotNil(blobProperties.EncryptionScope, "")
 
 		if t.s2sPreserveSourceTags {
 			blobTagsMap, err := t.getBlobTags()
@@ -388,6 +404,7 @@ func (t *blobTraverser) parallelList(containerClient *container.Client, containe
 								pbPropAdapter.Metadata,
 								containerName,
 							)
+							storedObject.blobEncryptionScope = common.IffNotNil(pResp.EncryptionScope, "")
 
 							if t.s2sPreserveSourceTags {
 								tResp, err := blobClient.GetTags(t.ctx, nil)
@@ -467,7 +484,7 @@ func (t *blobTraverser) parallelList(containerClient *container.Client, containe
 	// initiate parallel scanning, starting at the root path
 	workerContext, cancelWorkers := context.WithCancel(t.ctx)
 	defer cancelWorkers()
-	cCrawled := parallel.Crawl(workerContext, searchPrefix+extraSearchPrefix, enumerateOneDir, EnumerationParallelism)
+	cCrawled := parallel.CrawlInOrder(workerContext, searchPrefix+extraSearchPrefix, enumerateOneDir, t.parallelism, EnumerationTraversal)
 
 	for x := range cCrawled {
 		item, workerError := x.Item()
@@ -496,6 +513,8 @@ func getEntityType(metadata map[string]*string) common.EntityType {
 		return common.EEntityType.Folder()
 	} else if symlinkValue, isSymlink := common.TryReadMetadata(metadata, common.POSIXSymlinkMeta); isSymlink && symlinkValue != nil && strings.ToLower(*symlinkValue) == "true" {
 		return common.EEntityType.Symlink()
+	} else if _, isHardlink := common.TryReadMetadata(metadata, common.POSIXHardlinkMeta); isHardlink {
+		return common.EEntityType.Hardlink()
 	}
 	return common.EEntityType.File()
 }
@@ -521,8 +540,12 @@ func (t *blobTraverser) createStoredObjectForBlob(preprocessor objectMorpher, bl
 	)
 
 	object.blobDeleted = common.IffNotNil(blobInfo.Deleted, false)
+	object.blobEncryptionScope = common.IffNotNil(blobInfo.Properties.EncryptionScope, "")
 	if t.include.Deleted() && t.include.Snapshots() {
 		object.blobSnapshotID = common.IffNotNil(blobInfo.Snapshot, "")
+	} else if t.include.Snapshots() && common.IffNotNil(blobInfo.Snapshot, "") != "" {
+		// snapshots listed for themselves, rather than to be deleted
+		object.blobSnapshotID = *blobInfo.Snapshot
 	} else if t.include.Versions() && blobInfo.VersionID != nil {
 		object.blobVersionID = common.IffNotNil(blobInfo.VersionID, "")
 	}
@@ -540,9 +563,24 @@ func (t *blobTraverser) serialList(containerClient *container.Client, containerN
 	// see the TO DO in GetEnumerationPreFilter if/when we make this more directory-aware
 	// TODO optimize for the case where recursive is off
 	prefix := searchPrefix + extraSearchPrefix
+	include := container.ListBlobsInclude{Metadata: true, Tags: t.s2sPreserveSourceTags, Deleted: t.include.Deleted(), Snapshots: t.include.Snapshots(), Versions: t.include.Versions()}
+
+	var marker *string
+	listing := fmt.Sprintf("%s %s %+v", containerName, prefix, include)
+	if t.listingCheckpoint != nil {
+		var err error
+		if marker, err = t.listingCheckpoint.marker(listing); err != nil {
+			return fmt.Errorf("cannot read the listing checkpoint: %w", err)
+		}
+		if marker != nil && azcopyScanningLogger != nil {
+			azcopyScanningLogger.Log(common.LogInfo, "Carrying on with an interrupted listing from marker "+*marker)
+		}
+	}
+
 	pager := containerClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
 		Prefix:  &prefix,
-		Include: container.ListBlobsInclude{Metadata: true, Tags: t.s2sPreserveSourceTags, Deleted: t.include.Deleted(), Snapshots: t.include.Snapshots(), Versions: t.include.Versions()},
+		Include: include,
+		Marker:  marker,
 	})
 	for pager.More() {
 		resp, err := pager.NextPage(t.ctx)
@@ -588,6 +626,12 @@ func (t *blobTraverser) serialList(containerClient *container.Client, containerN
 				return processErr
 			}
 		}
+
+		if t.listingCheckpoint != nil {
+			if err = t.listingCheckpoint.save(listing, resp.NextMarker); err != nil {
+				return fmt.Errorf("cannot save the listing checkpoint: %w", err)
+			}
+		}
 	}
 
 	return nil
@@ -610,6 +654,15 @@ func newBlobTraverser(rawURL string, serviceClient *service.Client, ctx context.
 		cpkOptions:                  opts.CpkOptions,
 		preservePermissions:         opts.PreservePermissions,
 		isDFS:                       common.DerefOrZero(common.FirstOrZero(blobOpts).isDFS),
+		parallelism:                 opts.scanParallelism(),
+	}
+	if opts.ListSnapshots {
+		t.include = t.include.Add(common.EBlobTraverserIncludeOption.Snapshots())
+	}
+	if opts.ListingCheckpoint != nil {
+		// a marker continues a flat listing, but there's no one marker for a hierarchical listing done in parallel
+		t.listingCheckpoint = opts.ListingCheckpoint
+		t.parallelListing = false
 	}
 
 	disableHierarchicalScanning := strings.ToLower(common.GetEnvironmentVariable(common.EEnvironmentVariable.DisableHierarchicalScanning()))
@@ -624,6 +677,12 @@ func newBlobTraverser(rawURL string, serviceClient *service.Client, ctx context.
 		// TODO log to frontend log that parallel listing was disabled, once the frontend log PR is merged
 		t.parallelListing = false
 	}
+
+	// unlike the list command's checkpoint, a copy's doesn't make the listing serial, since that would slow down every
+	// scan of a large container for the sake of the few that are interrupted
+	if opts.ScanCheckpoint != nil && t.listingCheckpoint == nil && !t.parallelListing {
+		t.listingCheckpoint = opts.ScanCheckpoint
+	}
 	return
 }
 
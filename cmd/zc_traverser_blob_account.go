@@ -121,6 +121,7 @@ func (t *blobAccountTraverser) Traverse(preprocessor objectMorpher, processor ob
 			IncludeDirectoryStubs: t.opts.IncludeDirectoryStubs,
 			PreserveBlobTags:      t.opts.PreserveBlobTags,
 			PreservePermissions:   t.opts.PreservePermissions,
+			ScanConcurrency:       t.opts.ScanConcurrency,
 		}, t.blobOpts...)
 
 		preprocessorForThisChild := preprocessor.FollowedBy(newContainerDecorator(v))
@@ -103,6 +103,7 @@ func (t *blobVersionsTraverser) Traverse(preprocessor objectMorpher, processor o
 			blobURLParts.ContainerName,
 		)
 		storedObject.blobVersionID = versionID
+		storedObject.blobEncryptionScope = common.IffNotNil(blobProperties.EncryptionScope, "")
 
 		if t.incrementEnumerationCounter != nil {
 			t.incrementEnumerationCounter(common.EEntityType.File())
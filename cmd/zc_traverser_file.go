@@ -55,6 +55,7 @@ type fileTraverser struct {
 	trailingDot                 common.TrailingDotOption
 	destination                 *common.Location
 	hardlinkHandling            common.HardlinkHandlingType
+	parallelism                 int // how many directories are read at once
 }
 
 func createShareClientFromServiceClient(fileURLParts file.URLParts, client *service.Client) (*share.Client, error) {
@@ -415,11 +416,11 @@ func (t *fileTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 	// run the actual enumeration.
 	// First part is a parallel directory crawl
 	// Second part is parallel conversion of the directories and files to stored objects. This is necessary because the conversion to stored object may hit the network and therefore be slow if not parallelized
-	parallelism := EnumerationParallelism // for Azure Files we'll run two pools of this size, one for crawl and one for transform
+	parallelism := t.parallelism // for Azure Files we'll run two pools of this size, one for crawl and one for transform
 
 	workerContext, cancelWorkers := context.WithCancel(t.ctx)
 
-	cCrawled := parallel.Crawl(workerContext, directoryClient, enumerateOneDir, parallelism)
+	cCrawled := parallel.CrawlInOrder(workerContext, directoryClient, enumerateOneDir, parallelism, EnumerationTraversal)
 
 	cTransformed := parallel.Transform(workerContext, cCrawled, convertToStoredObject, parallelism)
 
@@ -462,6 +463,7 @@ func newFileTraverser(rawURL string, serviceClient *service.Client, ctx context.
 		trailingDot:                 opts.TrailingDotOption,
 		destination:                 opts.DestResourceType,
 		hardlinkHandling:            opts.HardlinkHandling,
+		parallelism:                 opts.scanParallelism(),
 	}
 	return
 }
@@ -56,6 +56,99 @@ type localTraverser struct {
 	// receives fullPath entries and manages hashing of files lacking metadata.
 	hashTargetChannel chan string
 	hardlinkHandling  common.HardlinkHandlingType
+	// set when hard links are preserved, to pick out the names after the first for each inode
+	hardlinks *hardlinkTracker
+	// don't descend into directories on other file systems than fullPath
+	oneFileSystem bool
+	// skip files and folders flagged UF_NODUMP, and everything beneath those folders
+	excludeNodump          bool
+	incrementNodumpSkipped func()
+	// what to do on meeting a FIFO, socket or device node, which are never transferred
+	specialFiles      common.SpecialFileHandlingType
+	reportSpecialFile func(path, kind string)
+	// records how far a recursive scan has got, and what an interrupted one had already found (see scanCheckpoint.go)
+	scanCheckpoint *scanCheckpoint
+	// how many directories are read at once
+	parallelism int
+}
+
+// relativePath is the path of something found beneath the traverser's root, relative to the root
+func (t *localTraverser) relativePath(fullPath string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cleanLocalPath(fullPath), cleanLocalPath(t.fullPath)), common.DeterminePathSeparator(t.fullPath))
+}
+
+// checkpointPath is what a scan checkpoint calls something found beneath the traverser's root
+func (t *localTraverser) checkpointPath(fullPath string) string {
+	return strings.ReplaceAll(t.relativePath(fullPath), common.DeterminePathSeparator(t.fullPath), common.AZCOPY_PATH_SEPARATOR_STRING)
+}
+
+// isNodump reports whether fileInfo carries the UF_NODUMP flag and is to be skipped.
+func (t *localTraverser) isNodump(fileInfo os.FileInfo) bool {
+	if !t.excludeNodump {
+		return false
+	}
+	flags, ok := common.FileFlagsOf(fileInfo)
+	if !ok || flags&common.UF_NODUMP == 0 {
+		return false
+	}
+	if t.incrementNodumpSkipped != nil {
+		t.incrementNodumpSkipped()
+	}
+	return true
+}
+
+// isSpecialFile reports whether fileInfo is a FIFO, socket or device node, and so is to be skipped.
+// Under --special-files=fail it returns an error instead, which ends the enumeration.
+func (t *localTraverser) isSpecialFile(filePath string, fileInfo os.FileInfo) (bool, error) {
+	kind := common.SpecialFileKind(fileInfo.Mode())
+	if kind == "" {
+		return false, nil
+	}
+
+	switch t.specialFiles {
+	case common.ESpecialFileHandlingType.Fail():
+		return true, fmt.Errorf("cannot transfer %s because it is a %s (--%s=%s)", filePath, kind, SpecialFilesFlag, t.specialFiles)
+	case common.ESpecialFileHandlingType.Warn():
+		WarnStdoutAndScanningLog(fmt.Sprintf("Skipping %s at %s because special files have no content to transfer", kind, filePath))
+	}
+	if t.reportSpecialFile != nil {
+		t.reportSpecialFile(filePath, kind)
+	}
+	return true, nil
+}
+
+// descendFilter decides which folders the recursive walk goes into, or returns nil if it goes into all of them.
+// The folders it rejects are still passed to processFile; only their contents are left out.
+func (t *localTraverser) descendFilter() (parallel.DirFilter, error) {
+	if !t.oneFileSystem && !t.excludeNodump {
+		return nil, nil
+	}
+
+	// With oneFileSystem, directories on any other device than the root's are reported, but not descended into.
+	rootDev, checkDev := uint64(0), false
+	if t.oneFileSystem {
+		rootInfo, err := os.Stat(t.fullPath)
+		if err != nil {
+			return nil, err
+		}
+		rootDev, checkDev = getDeviceID(rootInfo)
+	}
+
+	return func(dirPath string, dirInfo os.FileInfo) bool {
+		if checkDev {
+			if dev, ok := getDeviceID(dirInfo); ok && dev != rootDev {
+				WarnStdoutAndScanningLog(fmt.Sprintf("Not descending into %s because it is on a different file system (--%s)", dirPath, OneFileSystemFlag))
+				return false
+			}
+		}
+		if t.excludeNodump {
+			// the folder itself is counted when processFile sees it
+			if flags, ok := common.FileFlagsOf(dirInfo); ok && flags&common.UF_NODUMP != 0 {
+				return false
+			}
+		}
+		return true
+	}, nil
 }
 
 func (t *localTraverser) IsDirectory(bool) (bool, error) {
@@ -203,13 +296,17 @@ func writeToErrorChannel(errorChannel chan<- ErrorFileInfo, err ErrorFileInfo) {
 // Separate this from the traverser for two purposes:
 // 1) Cleaner code
 // 2) Easier to test individually than to test the entire traverser.
+// dirDone, if it isn't nil, is told of each directory that has been read completely (see parallel.WalkFiltered).
 func WalkWithSymlinks(appCtx context.Context,
 	fullPath string,
 	walkFunc filepath.WalkFunc,
 	symlinkHandling common.SymlinkHandlingType,
 	errorChannel chan<- ErrorFileInfo,
 	hardlinkHandling common.HardlinkHandlingType,
-	incrementEnumerationCounter enumerationCounterFunc) (err error) {
+	incrementEnumerationCounter enumerationCounterFunc,
+	parallelism int,
+	descend parallel.DirFilter,
+	dirDone parallel.DirDoneFunc) (err error) {
 
 	// We want to re-queue symlinks up in their evaluated form because filepath.Walk doesn't evaluate them for us.
 	// So, what is the plan of attack?
@@ -239,7 +336,7 @@ func WalkWithSymlinks(appCtx context.Context,
 		walkQueue = walkQueue[1:]
 		// walk contents of this queueItem in parallel
 		// (for simplicity of coding, we don't parallelize across multiple queueItems)
-		parallel.Walk(appCtx, queueItem.fullPath, EnumerationParallelism, EnumerationParallelStatFiles, func(filePath string, fileInfo os.FileInfo, fileError error) error {
+		parallel.WalkFiltered(appCtx, queueItem.fullPath, parallelism, EnumerationParallelStatFiles, descend, EnumerationTraversal, func(filePath string, fileInfo os.FileInfo, fileError error) error {
 			if fileError != nil {
 				WarnStdoutAndScanningLog(fmt.Sprintf("Accessing '%s' failed with error: %s", filePath, fileError.Error()))
 				writeToErrorChannel(errorChannel, ErrorFileInfo{FilePath: filePath, FileInfo: fileInfo, ErrorMsg: fileError})
@@ -339,10 +436,12 @@ func WalkWithSymlinks(appCtx context.Context,
 						if !skipped { // Don't go any deeper (or record it) if we skipped it.
 							seenPaths.Record(common.ToExtendedPath(result))
 							seenPaths.Record(common.ToExtendedPath(slPath)) // Note we've seen the symlink as well. We shouldn't ever have issues if we _don't_ do this because we'll just catch it by symlink result
-							walkQueue = append(walkQueue, walkItem{
-								fullPath:     result,
-								relativeBase: computedRelativePath,
-							})
+							if descend == nil || descend(result, rStat) {
+								walkQueue = append(walkQueue, walkItem{
+									fullPath:     result,
+									relativeBase: computedRelativePath,
+								})
+							}
 						}
 						// enumerate the FOLDER now (since its presence in seenDirs will prevent its properties getting enumerated later)
 						return err
@@ -406,7 +505,7 @@ func WalkWithSymlinks(appCtx context.Context,
 					return nil
 				}
 			}
-		})
+		}, dirDone)
 	}
 
 	return
@@ -698,6 +797,8 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 				}
 				return nil
 			}
+		} else if special, err := t.isSpecialFile(t.fullPath, singleFileInfo); special {
+			return finalizer(err)
 		}
 
 		if t.incrementEnumerationCounter != nil {
@@ -754,22 +855,34 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 					}
 				}
 
-				relPath := strings.TrimPrefix(strings.TrimPrefix(cleanLocalPath(filePath), cleanLocalPath(t.fullPath)), common.DeterminePathSeparator(t.fullPath))
+				relPath := t.relativePath(filePath)
+				// it went into the job before the scan was interrupted
+				if t.scanCheckpoint.skips(t.checkpointPath(filePath)) {
+					return nil
+				}
+				// the root was named explicitly, so it is never skipped for nodump
+				if relPath != "" && t.isNodump(fileInfo) {
+					return nil
+				}
+				if special, err := t.isSpecialFile(filePath, fileInfo); special {
+					return err
+				}
 				if t.symlinkHandling.None() && fileInfo.Mode()&os.ModeSymlink != 0 {
 					WarnStdoutAndScanningLog(fmt.Sprintf("Skipping over symlink at %s because symlinks are not handled (--follow-symlinks or --preserve-symlinks)", common.GenerateFullPath(t.fullPath, relPath)))
 					return nil
 				}
+				relPath = strings.ReplaceAll(relPath, common.DeterminePathSeparator(t.fullPath), common.AZCOPY_PATH_SEPARATOR_STRING) // Consolidate relative paths to the azcopy path separator for sync
 
 				if t.incrementEnumerationCounter != nil {
 					t.incrementEnumerationCounter(entityType)
 				}
 
 				// This is an exception to the rule. We don't strip the error here, because WalkWithSymlinks catches it.
-				return processIfPassedFilters(filters,
+				return t.processIfPassedFilters(filters,
 					newStoredObject(
 						preprocessor,
 						fileInfo.Name(),
-						strings.ReplaceAll(relPath, common.DeterminePathSeparator(t.fullPath), common.AZCOPY_PATH_SEPARATOR_STRING), // Consolidate relative paths to the azcopy path separator for sync
+						relPath,
 						entityType,
 						fileInfo.ModTime(), // get this for both files and folders, since sync needs it for both.
 						fileInfo.Size(),
@@ -778,12 +891,31 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 						noMetadata,
 						"", // Local has no such thing as containers
 					),
+					fileInfo,
 					hashingProcessor, // hashingProcessor handles the mutex wrapper
 				)
 			}
 
 			// note: Walk includes root, so no need here to separately create StoredObject for root (as we do for other folder-aware sources)
-			return finalizer(WalkWithSymlinks(t.appCtx, t.fullPath, processFile, t.symlinkHandling, t.errorChannel, t.hardlinkHandling, t.incrementEnumerationCounter))
+			descend, err := t.descendFilter()
+			if err != nil {
+				return finalizer(err)
+			}
+
+			// what's followed through symlinks isn't beneath the root, so its progress can't be recorded
+			var dirDone parallel.DirDoneFunc
+			if cp := t.scanCheckpoint; cp != nil && !t.symlinkHandling.Follow() {
+				cp.claim()
+				inner := descend
+				descend = func(dirPath string, dirInfo os.FileInfo) bool {
+					return cp.descend(t.checkpointPath(dirPath)) && (inner == nil || inner(dirPath, dirInfo))
+				}
+				dirDone = func(dirPath string, subdirs int) {
+					cp.dirDone(t.checkpointPath(dirPath), subdirs)
+				}
+			}
+
+			return finalizer(WalkWithSymlinks(t.appCtx, t.fullPath, processFile, t.symlinkHandling, t.errorChannel, t.hardlinkHandling, t.incrementEnumerationCounter, t.parallelism, descend, dirDone))
 		} else {
 			// if recursive is off, we only need to scan the files immediately under the fullPath
 			// We don't transfer any directory properties here, not even the root. (Because the root's
@@ -852,11 +984,22 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 					// it doesn't make sense to transfer directory properties when not recurring
 				}
 
+				if t.isNodump(fileInfo) {
+					continue
+				}
+
+				if special, err := t.isSpecialFile(common.GenerateFullPath(t.fullPath, relativePath), fileInfo); special {
+					if err != nil {
+						return finalizer(err)
+					}
+					continue
+				}
+
 				if t.incrementEnumerationCounter != nil {
 					t.incrementEnumerationCounter(common.EEntityType.File())
 				}
 
-				err := processIfPassedFilters(filters,
+				err := t.processIfPassedFilters(filters,
 					newStoredObject(
 						preprocessor,
 						entry.Name(),
@@ -869,6 +1012,7 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 						noMetadata,
 						"", // Local has no such thing as containers
 					),
+					fileInfo,
 					hashingProcessor, // hashingProcessor handles the mutex wrapper
 				)
 				_, err = getProcessingError(err)
@@ -882,6 +1026,22 @@ func (t *localTraverser) Traverse(preprocessor objectMorpher, processor objectPr
 	return finalizer(err)
 }
 
+// processIfPassedFilters is processIfPassedFilters, with hard links classified only once the filters have passed, so that
+// a name that is filtered out never becomes the one that the other names of its file link to.
+func (t *localTraverser) processIfPassedFilters(filters []ObjectFilter, storedObject StoredObject, fileInfo os.FileInfo, processor objectProcessor) error {
+	if !passedFilters(filters, storedObject) {
+		return ignoredError
+	}
+	if t.hardlinks != nil && storedObject.entityType == common.EEntityType.File() {
+		if entityType, metadata := t.hardlinks.classify(fileInfo, storedObject.relativePath); metadata != nil {
+			storedObject.entityType = entityType
+			storedObject.Metadata = metadata
+			storedObject.size = 0 // only the link itself is sent
+		}
+	}
+	return processor(storedObject)
+}
+
 func newLocalTraverser(fullPath string, ctx context.Context, opts InitResourceTraverserOptions) (*localTraverser, error) {
 	var hashAdapter common.HashDataAdapter
 	if opts.SyncHashType != common.ESyncHashType.None() { // Only initialize the hash adapter should we need it.
@@ -903,6 +1063,16 @@ func newLocalTraverser(fullPath string, ctx context.Context, opts InitResourceTr
 		hashAdapter:                 hashAdapter,
 		stripTopDir:                 opts.StripTopDir,
 		hardlinkHandling:            opts.HardlinkHandling,
+		oneFileSystem:               opts.OneFileSystem,
+		excludeNodump:               opts.ExcludeNodump,
+		incrementNodumpSkipped:      opts.IncrementNodumpSkipped,
+		specialFiles:                opts.SpecialFileHandling,
+		reportSpecialFile:           opts.ReportSpecialFile,
+		scanCheckpoint:              opts.ScanCheckpoint,
+		parallelism:                 opts.scanParallelism(),
+	}
+	if opts.HardlinkHandling == common.EHardlinkHandlingType.Preserve() && !common.IsNFSCopy() {
+		traverser.hardlinks = newHardlinkTracker()
 	}
 	return &traverser, nil
 }
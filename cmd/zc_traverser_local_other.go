@@ -26,6 +26,24 @@ func IsHardlink(fileInfo os.FileInfo) bool {
 	return stat.Nlink > 1 && !fileInfo.IsDir()
 }
 
+// getHardlinkKey identifies the inode behind fileInfo, so that other names for it can be recognised.
+func getHardlinkKey(fileInfo os.FileInfo) (hardlinkKey, bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return hardlinkKey{}, false
+	}
+	return hardlinkKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
+
+// getDeviceID returns the device of the file system that fileInfo lives on, for --one-file-system.
+func getDeviceID(fileInfo os.FileInfo) (uint64, bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
+
 // IsRegularFile checks if the given os.FileInfo represents a regular file.
 // Returns true if the file is regular (not a directory, symlink, or special file).
 func IsRegularFile(info os.FileInfo) bool {
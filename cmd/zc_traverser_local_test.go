@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestCleanLocalPath(t *testing.T) {
@@ -47,4 +49,57 @@ func TestCleanLocalPathForWindows(t *testing.T) {
 	for orig, expected := range testCases {
 		a.Equal(expected, cleanLocalPath(orig))
 	}
-}
\ No newline at end of file
+}
+type modeOnlyFileInfo os.FileMode
+
+func (m modeOnlyFileInfo) Name() string       { return "x" }
+func (m modeOnlyFileInfo) Size() int64        { return 0 }
+func (m modeOnlyFileInfo) Mode() os.FileMode  { return os.FileMode(m) }
+func (m modeOnlyFileInfo) ModTime() time.Time { return time.Time{} }
+func (m modeOnlyFileInfo) IsDir() bool        { return os.FileMode(m).IsDir() }
+func (m modeOnlyFileInfo) Sys() any           { return nil }
+
+func TestLocalTraverserSpecialFilePolicy(t *testing.T) {
+	a := assert.New(t)
+	// special files are warned of through glcm, which another test may have left with its channels closed
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	report := &specialFileReport{}
+	traverser := &localTraverser{reportSpecialFile: report.add}
+
+	for _, policy := range []common.SpecialFileHandlingType{common.ESpecialFileHandlingType.Warn(), common.ESpecialFileHandlingType.Skip()} {
+		traverser.specialFiles = policy
+
+		special, err := traverser.isSpecialFile("/src/file", modeOnlyFileInfo(0644))
+		a.False(special)
+		a.NoError(err)
+
+		special, err = traverser.isSpecialFile("/src/dir", modeOnlyFileInfo(os.ModeDir|0755))
+		a.False(special)
+		a.NoError(err)
+
+		special, err = traverser.isSpecialFile("/src/fifo", modeOnlyFileInfo(os.ModeNamedPipe|0644))
+		a.True(special)
+		a.NoError(err)
+	}
+	a.Equal([]common.SkippedSpecialFile{{Path: "/src/fifo", Kind: "named pipe"}, {Path: "/src/fifo", Kind: "named pipe"}}, report.list())
+
+	traverser.specialFiles = common.ESpecialFileHandlingType.Fail()
+	special, err := traverser.isSpecialFile("/src/tty", modeOnlyFileInfo(os.ModeDevice|os.ModeCharDevice|0620))
+	a.True(special)
+	a.ErrorContains(err, "character device")
+	a.Len(report.list(), 2) // failing isn't skipping
+}
+
+func TestFormatSpecialFiles(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("", formatSpecialFiles(nil, 0))
+
+	files := make([]common.SkippedSpecialFile, maxSpecialFilesOnScreen+1)
+	for i := range files {
+		files[i] = common.SkippedSpecialFile{Path: "p", Kind: "socket"}
+	}
+	a.Contains(formatSpecialFiles(files[:1], 1), "\n    p (socket)")
+	a.NotContains(formatSpecialFiles(files[:1], 1), "more")
+	a.Contains(formatSpecialFiles(files, 30), "...and 10 more")
+}
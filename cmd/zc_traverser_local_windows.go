@@ -75,6 +75,15 @@ func IsHardlink(fileInfo os.FileInfo) bool {
 	return false
 }
 
+func getHardlinkKey(fileInfo os.FileInfo) (hardlinkKey, bool) {
+	return hardlinkKey{}, false
+}
+
+// TODO: Add support for --one-file-system on Windows later (volume serial numbers)
+func getDeviceID(fileInfo os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
 // TODO: Add support for this on Windows later
 func IsRegularFile(info os.FileInfo) bool {
 	return info.Mode().IsRegular()
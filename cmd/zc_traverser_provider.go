@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// providerTraverser enumerates a location that is served by a registered common.Provider.
+type providerTraverser struct {
+	provider  common.Provider
+	root      *url.URL
+	ctx       context.Context
+	recursive bool
+
+	incrementEnumerationCounter enumerationCounterFunc
+}
+
+func newProviderTraverser(resource common.ResourceString, ctx context.Context, opts InitResourceTraverserOptions) (*providerTraverser, error) {
+	rawURL, err := resource.String()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(rawURL, "*") {
+		return nil, errors.New("wildcards aren't supported in provider URLs; use --include-pattern instead")
+	}
+	p, u, err := common.GetProvider(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &providerTraverser{
+		provider:                    p,
+		root:                        u,
+		ctx:                         ctx,
+		recursive:                   opts.Recursive,
+		incrementEnumerationCounter: opts.IncrementEnumeration,
+	}, nil
+}
+
+func (t *providerTraverser) IsDirectory(isSource bool) (bool, error) {
+	if strings.HasSuffix(t.root.Path, "/") {
+		return true, nil
+	}
+	obj, err := t.provider.Properties(t.ctx, t.root)
+	if err != nil {
+		// a destination that doesn't exist yet will be an object
+		return false, common.Iff(isSource, err, nil)
+	}
+	return obj.IsDirectory, nil
+}
+
+func (t *providerTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	return t.provider.Enumerate(t.ctx, t.root, t.recursive, func(obj common.ProviderObject) error {
+		if obj.IsDirectory {
+			return nil // providers aren't folder-aware, so only the objects in folders are copied
+		}
+
+		name := path.Base(obj.RelativePath)
+		if obj.RelativePath == "" {
+			name = path.Base(t.root.Path)
+		}
+		var metadata common.Metadata
+		if len(obj.Metadata) > 0 {
+			metadata = common.Metadata{}
+			for k, v := range obj.Metadata {
+				metadata[k] = &v
+			}
+		}
+
+		storedObject := newStoredObject(
+			preprocessor,
+			name,
+			obj.RelativePath,
+			common.EEntityType.File(),
+			obj.LastModified,
+			obj.Size,
+			providerObjectPropertiesAdapter{obj: obj},
+			noBlobProps,
+			metadata,
+			"")
+		if t.incrementEnumerationCounter != nil {
+			t.incrementEnumerationCounter(common.EEntityType.File())
+		}
+
+		_, err := getProcessingError(processIfPassedFilters(filters, storedObject, processor))
+		return err
+	})
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
+)
+
+// urlListTraverser enumerates the http(s) URLs listed in a file, one per line, such as the presigned or public URLs a
+// dataset is published as. The destination service reads each one itself, as it does for S3 and GCP sources.
+// A line may give the destination name of its URL after a tab; otherwise the URL's path is used.
+// Blank lines, and lines starting with #, are ignored.
+type urlListTraverser struct {
+	listPath string
+	ctx      context.Context
+	client   *http.Client
+
+	incrementEnumerationCounter enumerationCounterFunc
+}
+
+// how many URLs we look up at once, to find their sizes
+const urlListParallelism = 32
+
+func newURLListTraverser(listPath string, ctx context.Context, opts InitResourceTraverserOptions) (*urlListTraverser, error) {
+	if _, err := os.Stat(listPath); err != nil {
+		return nil, fmt.Errorf("cannot read the list of URLs: %w", err)
+	}
+
+	return &urlListTraverser{
+		listPath:                    listPath,
+		ctx:                         ctx,
+		client:                      ste.NewAzcopyHTTPClient(urlListParallelism),
+		incrementEnumerationCounter: opts.IncrementEnumeration,
+	}, nil
+}
+
+// IsDirectory is always true, since the list stands for a folder of the objects it names
+func (t *urlListTraverser) IsDirectory(isSource bool) (bool, error) {
+	return true, nil
+}
+
+// urlForLogging drops the query of a URL, since that is where presigned URLs carry their signatures
+func urlForLogging(rawURL string) string {
+	withoutQuery, _, _ := strings.Cut(rawURL, "?")
+	return withoutQuery
+}
+
+type urlListEntry struct {
+	line    int
+	rawURL  string
+	name    string
+	obj     StoredObject
+	lookErr error
+}
+
+// parseURLListLine returns the URL on a line of a URL list, and the name it is to be copied to, or an empty URL
+// if the line has none
+func parseURLListLine(line string) (rawURL, name string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", nil
+	}
+
+	rawURL, name, _ = strings.Cut(line, "\t")
+	rawURL, name = strings.TrimSpace(rawURL), strings.TrimSpace(name)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", fmt.Errorf("%q is not an http or https URL", urlForLogging(rawURL))
+	}
+
+	if name == "" && !strings.HasSuffix(u.Path, "/") {
+		name = u.Path
+	}
+	name = strings.Trim(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	if name == "" {
+		return "", "", fmt.Errorf("%q doesn't name a file; give its destination name after a tab",
+			urlForLogging(rawURL))
+	}
+	return rawURL, name, nil
+}
+
+func (t *urlListTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	f, err := os.Open(t.listPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+
+	entries := make(chan *urlListEntry, urlListParallelism)
+	looked := make(chan *urlListEntry, urlListParallelism)
+	var parseErr error
+
+	go func() {
+		defer close(entries)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024) // presigned URLs can be long
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			rawURL, name, err := parseURLListLine(scanner.Text())
+			if err != nil {
+				parseErr = fmt.Errorf("line %d of %s: %w", lineNumber, t.listPath, err)
+				return
+			} else if rawURL == "" {
+				continue
+			}
+			select {
+			case entries <- &urlListEntry{line: lineNumber, rawURL: rawURL, name: name}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		parseErr = scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < urlListParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range entries {
+				e.obj, e.lookErr = t.lookUp(ctx, preprocessor, e)
+				select {
+				case looked <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(looked)
+	}()
+
+	names := map[string]int{} // the line each destination name came from
+	for e := range looked {
+		if e.lookErr != nil {
+			WarnStdoutAndScanningLog(fmt.Sprintf("Skipping line %d of the list of URLs: %v", e.line, e.lookErr))
+			continue
+		}
+		if line, ok := names[e.name]; ok {
+			return fmt.Errorf("lines %d and %d of %s would both be copied to %q; give one of them a different name after a tab",
+				min(line, e.line), max(line, e.line), t.listPath, e.name)
+		}
+		names[e.name] = e.line
+
+		if t.incrementEnumerationCounter != nil {
+			t.incrementEnumerationCounter(common.EEntityType.File())
+		}
+		if _, err := getProcessingError(processIfPassedFilters(filters, e.obj, processor)); err != nil {
+			return err
+		}
+	}
+	return parseErr
+}
+
+// lookUp finds the size and properties of a URL. It asks for the first byte, rather than making a HEAD request,
+// since presigned URLs are often only signed for GET.
+func (t *urlListTraverser) lookUp(ctx context.Context, preprocessor objectMorpher, e *urlListEntry) (StoredObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.rawURL, nil)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	req.Header.Set("User-Agent", common.UserAgent)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // which doesn't repeat the URL
+		}
+		return StoredObject{}, fmt.Errorf("%s: %w", urlForLogging(e.rawURL), err)
+	}
+	_ = resp.Body.Close() // we only wanted the headers
+
+	var size int64
+	switch resp.StatusCode {
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable: // the latter for an empty file
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return StoredObject{}, fmt.Errorf("%s didn't give its size (Content-Range %q)",
+				urlForLogging(e.rawURL), resp.Header.Get("Content-Range"))
+		}
+	case http.StatusOK:
+		if resp.ContentLength < 0 {
+			return StoredObject{}, fmt.Errorf("%s didn't give its size", urlForLogging(e.rawURL))
+		}
+		size = resp.ContentLength
+	default:
+		return StoredObject{}, fmt.Errorf("%s: %s", urlForLogging(e.rawURL), resp.Status)
+	}
+
+	lmt, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	obj := newStoredObject(
+		preprocessor,
+		path.Base(e.name),
+		e.name,
+		common.EEntityType.File(),
+		lmt,
+		size,
+		httpHeaderPropertiesAdapter{header: resp.Header},
+		noBlobProps,
+		nil,
+		"")
+	obj.sourceURL = e.rawURL
+	return obj, nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+)
+
+// zfsAutoSnapshot asks us to take (and afterwards destroy) a snapshot of our own, rather than use an existing one.
+const zfsAutoSnapshot = "auto"
+
+// zfsSnapshot describes a snapshot of the dataset containing a local source.
+// Snapshots are read through the dataset's .zfs/snapshot directory, which ZFS exposes whether or not snapdir=visible.
+type zfsSnapshot struct {
+	dataset    string
+	mountpoint string
+	name       string
+	created    bool // true if we took it, and so should destroy it
+}
+
+func (s *zfsSnapshot) root() string {
+	return filepath.Join(s.mountpoint, ".zfs", "snapshot", s.name)
+}
+
+// pathFor maps a path in the live dataset to the same path inside the snapshot.
+func (s *zfsSnapshot) pathFor(livePath string) (string, error) {
+	rel, err := filepath.Rel(s.mountpoint, livePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside ZFS dataset %s (mounted at %s)", livePath, s.dataset, s.mountpoint)
+	}
+	return filepath.Join(s.root(), rel), nil
+}
+
+// findZFSDataset returns the dataset holding path. `zfs list` resolves a path to its containing file system for us.
+func findZFSDataset(path string) (dataset, mountpoint string, err error) {
+	out, err := exec.Command("zfs", "list", "-H", "-o", "name,mountpoint", path).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", "", fmt.Errorf("%s does not appear to be on a ZFS dataset: %s", path, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", "", fmt.Errorf("could not run zfs: %w", err)
+	}
+
+	fields := strings.Split(strings.TrimSpace(string(out)), "\t")
+	if len(fields) != 2 || !filepath.IsAbs(fields[1]) {
+		return "", "", fmt.Errorf("dataset containing %s is not mounted", path)
+	}
+	return fields[0], fields[1], nil
+}
+
+// switchToZFSSnapshot points the source at a snapshot of the dataset it lives on, so that a live file system is
+// uploaded as a consistent point-in-time copy. cca.liveSource keeps the original path, so that names at the destination
+// are those of the live dataset rather than of the snapshot directory.
+func (cca *CookedCopyCmdArgs) switchToZFSSnapshot() error {
+	live, err := filepath.Abs(cca.Source.ValueLocal())
+	if err != nil {
+		return err
+	}
+
+	// wildcards are only allowed in the last element of a local source
+	dir := live
+	if strings.Contains(filepath.Base(live), "*") {
+		dir = filepath.Dir(live)
+	}
+
+	dataset, mountpoint, err := findZFSDataset(dir)
+	if err != nil {
+		return err
+	}
+
+	snap := &zfsSnapshot{dataset: dataset, mountpoint: mountpoint, name: cca.zfsSnapshotName}
+	if snap.name == zfsAutoSnapshot {
+		snap.name = "azcopy-" + cca.jobID.String()
+		if out, err := exec.Command("zfs", "snapshot", dataset+"@"+snap.name).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create snapshot %s@%s: %s", dataset, snap.name, strings.TrimSpace(string(out)))
+		}
+		snap.created = true
+		glcm.Info(fmt.Sprintf("Created ZFS snapshot %s@%s", dataset, snap.name))
+	}
+
+	if _, err = os.Stat(snap.root()); err != nil {
+		return fmt.Errorf("cannot read snapshot %s@%s: %w", dataset, snap.name, err)
+	}
+
+	snapPath, err := snap.pathFor(live)
+	if err != nil {
+		return err
+	}
+
+	cca.liveSource = live
+	cca.Source = cca.Source.CloneWithValue(snapPath)
+	cca.zfsSnapshot = snap
+	return nil
+}
+
+// releaseZFSSnapshot destroys a snapshot we created, once the job no longer needs it.
+// If the job did not fully succeed the snapshot is kept, because resuming the job reads from it.
+func (cca *CookedCopyCmdArgs) releaseZFSSnapshot(jobSucceeded bool) {
+	snap := cca.zfsSnapshot
+	if snap == nil || !snap.created {
+		return
+	}
+
+	full := snap.dataset + "@" + snap.name
+	if !jobSucceeded {
+		glcm.Info(fmt.Sprintf("Keeping ZFS snapshot %s so that the job can be resumed. Destroy it with 'zfs destroy %s' when it is no longer needed.", full, full))
+		return
+	}
+
+	if out, err := exec.Command("zfs", "destroy", full).CombinedOutput(); err != nil {
+		glcm.Warn(fmt.Sprintf("Failed to destroy ZFS snapshot %s: %s", full, strings.TrimSpace(string(out))))
+	} else if jobMan, exists := jobsAdmin.JobsAdmin.JobMgr(cca.jobID); exists {
+		jobMan.Log(common.LogInfo, "Destroyed ZFS snapshot "+full)
+	}
+}
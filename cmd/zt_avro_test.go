@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// avroFile makes an Avro object container file with the given schema, codec and blocks of encoded objects
+func avroFile(schema, codec string, blocks ...[][]byte) []byte {
+	var out bytes.Buffer
+	long := func(buf *bytes.Buffer, v int64) {
+		buf.Write(binary.AppendVarint(nil, v))
+	}
+	str := func(buf *bytes.Buffer, s string) {
+		long(buf, int64(len(s)))
+		buf.WriteString(s)
+	}
+	sync := []byte("0123456789abcdef")
+
+	out.WriteString("Obj\x01")
+	long(&out, 2)
+	str(&out, "avro.schema")
+	str(&out, schema)
+	str(&out, "avro.codec")
+	str(&out, codec)
+	long(&out, 0)
+	out.Write(sync)
+	for _, objects := range blocks {
+		var block bytes.Buffer
+		for _, o := range objects {
+			block.Write(o)
+		}
+		data := block.Bytes()
+		if codec == "deflate" {
+			var compressed bytes.Buffer
+			w, _ := flate.NewWriter(&compressed, flate.DefaultCompression)
+			_, _ = w.Write(data)
+			_ = w.Close()
+			data = compressed.Bytes()
+		}
+		long(&out, int64(len(objects)))
+		long(&out, int64(len(data)))
+		out.Write(data)
+		out.Write(sync)
+	}
+	return out.Bytes()
+}
+
+func TestReadAvroContainer(t *testing.T) {
+	a := assert.New(t)
+	schema := `{"type": "record", "name": "Event", "namespace": "test", "fields": [
+		{"name": "subject", "type": "string"},
+		{"name": "size", "type": {"type": "long", "logicalType": "bytes"}},
+		{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["created", "deleted"]}},
+		{"name": "data", "type": ["null", {"type": "map", "values": "string"}]},
+		{"name": "tags", "type": {"type": "array", "items": "test.Kind"}}
+	]}`
+	object := func(subject string, size int64, kind int64, data map[string]string, tags ...int64) []byte {
+		var buf bytes.Buffer
+		put := func(v int64) { buf.Write(binary.AppendVarint(nil, v)) }
+		put(int64(len(subject)))
+		buf.WriteString(subject)
+		put(size)
+		put(kind)
+		if data == nil {
+			put(0)
+		} else {
+			put(1)
+			put(int64(len(data)))
+			for k, v := range data {
+				put(int64(len(k)))
+				buf.WriteString(k)
+				put(int64(len(v)))
+				buf.WriteString(v)
+			}
+			put(0)
+		}
+		if len(tags) > 0 {
+			put(-int64(len(tags))) // with the block's size, which is skipped
+			put(int64(len(tags)))
+			for _, tag := range tags {
+				put(tag)
+			}
+		}
+		put(0)
+		return buf.Bytes()
+	}
+
+	for _, codec := range []string{"null", "deflate"} {
+		file := avroFile(schema, codec,
+			[][]byte{object("a", 1, 0, nil), object("b", -5, 1, map[string]string{"url": "u"}, 1, 0)},
+			[][]byte{object("c", 1<<40, 0, map[string]string{})})
+
+		var read []any
+		a.NoError(readAvroContainer(bytes.NewReader(file), func(v any) error {
+			read = append(read, v)
+			return nil
+		}))
+		a.Equal([]any{
+			map[string]any{"subject": "a", "size": int64(1), "kind": "created", "data": nil, "tags": []any{}},
+			map[string]any{"subject": "b", "size": int64(-5), "kind": "deleted", "data": map[string]any{"url": "u"}, "tags": []any{"deleted", "created"}},
+			map[string]any{"subject": "c", "size": int64(1 << 40), "kind": "created", "data": map[string]any{}, "tags": []any{}},
+		}, read, codec)
+
+		damaged := bytes.Clone(file)
+		damaged[len(damaged)-1] = 'x'
+		a.Error(readAvroContainer(bytes.NewReader(damaged), func(any) error { return nil }))
+	}
+
+	a.Error(readAvroContainer(bytes.NewReader([]byte("not avro")), func(any) error { return nil }))
+	a.Error(readAvroContainer(bytes.NewReader(avroFile(`"string"`, "snappy")), func(any) error { return nil }))
+}
+
+func TestReadAvroContainerRefusesDamagedLengths(t *testing.T) {
+	a := assert.New(t)
+	varint := func(v ...int64) []byte {
+		var b []byte
+		for _, x := range v {
+			b = binary.AppendVarint(b, x)
+		}
+		return b
+	}
+	read := func(file []byte) error {
+		return readAvroContainer(bytes.NewReader(file), func(any) error { return nil })
+	}
+
+	// lengths that are negative, or far more than there is, fail rather than being allocated
+	for _, length := range []int64{-1, 1 << 40, math.MaxInt64} {
+		a.Error(read(avroFile(`"string"`, "null", [][]byte{varint(length)})), "a string of %d bytes", length)
+		a.Error(read(avroFile(`{"type": "array", "items": "long"}`, "null", [][]byte{varint(length)})), "an array of %d items", length)
+		a.Error(read(append([]byte("Obj\x01"), varint(1, length)...)), "a header whose key is %d bytes", length)
+
+		file := avroFile(`"long"`, "null", [][]byte{varint(1)})
+		block := bytes.LastIndex(file, varint(1, 1, 1)) // the block's count, length and object
+		damaged := append(append(bytes.Clone(file[:block]), varint(1, length)...), file[block+2:]...)
+		a.Error(read(damaged), "a block of %d bytes", length)
+		damaged = append(append(bytes.Clone(file[:block]), varint(length, 1)...), file[block+2:]...)
+		a.Error(read(damaged), "a block of %d objects", length)
+	}
+
+	a.Error(read(avroFile(`{"type": "fixed", "name": "F", "size": -1}`, "null", [][]byte{nil})))
+	a.Error(read(avroFile(`{"type": "fixed", "name": "F", "size": 1099511627776}`, "null", [][]byte{{0}})))
+
+	// a record that holds itself is never done with
+	a.Error(read(avroFile(`{"type": "record", "name": "R", "fields": [{"name": "r", "type": "R"}]}`, "null", [][]byte{{0}})))
+}
+
+func FuzzReadAvroContainer(f *testing.F) {
+	f.Add(avroFile(`{"type": "record", "name": "R", "fields": [{"name": "s", "type": "string"}, {"name": "m", "type": {"type": "map", "values": "long"}}]}`,
+		"null", [][]byte{{2, 'a', 0}}))
+	f.Add(avroFile(`{"type": "array", "items": "bytes"}`, "deflate", [][]byte{{2, 2, 'b', 0}}))
+	f.Fuzz(func(t *testing.T, file []byte) {
+		_ = readAvroContainer(bytes.NewReader(file), func(any) error { return nil })
+	})
+}
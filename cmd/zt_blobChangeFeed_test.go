@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeFeedChanges(t *testing.T) {
+	a := assert.New(t)
+	event := func(eventType, name string, data map[string]any) any {
+		return map[string]any{
+			"eventType": eventType,
+			"subject":   "/blobServices/default/containers/photos/blobs/" + name,
+			"data":      data,
+		}
+	}
+
+	changes := changeFeedChanges{}
+	for _, e := range []any{
+		event("BlobCreated", "2024/a.jpg", nil),
+		event("BlobDeleted", "2024/b.jpg", nil),
+		event("BlobCreated", "2023/c.jpg", nil),
+		event("DirectoryDeleted", "2024/old", nil),
+		event("BlobCreated", "2024/old/d.jpg", nil),
+		event("DirectoryRenamed", "2024/from", map[string]any{"destinationUrl": "https://account.blob.core.windows.net/photos/2024/to"}),
+		event("BlobCreated", "2024", nil),
+		map[string]any{"eventType": "BlobCreated", "subject": "/blobServices/default/containers/videos/blobs/2024/e.mp4"},
+	} {
+		changes.add(e, "photos", "2024/")
+	}
+
+	a.Equal([]string{"", "a.jpg", "b.jpg", "from", "old", "to"}, changes.paths(),
+		"what's outside the source is left out, as is what's in a directory that has changed")
+}
+
+func TestChangeFeedSegmentTime(t *testing.T) {
+	a := assert.New(t)
+	begins, ok := changeFeedSegmentTime("idx/segments/2019/02/22/1810/meta.json")
+	a.True(ok)
+	a.Equal(time.Date(2019, 2, 22, 18, 10, 0, 0, time.UTC), begins)
+
+	_, ok = changeFeedSegmentTime("idx/segments/2019/02/22/1810/other.json")
+	a.False(ok)
+	_, ok = changeFeedSegmentTime("log/00/2019/02/22/1810/00000.avro")
+	a.False(ok)
+}
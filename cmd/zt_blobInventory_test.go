@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+const inventoryCSV = `Name,Last-Modified,Content-Length,Content-MD5,Content-Type,BlobType,AccessTier,hdi_isfolder,Metadata,VersionId,IsCurrentVersion,Deleted
+other/dir/a.txt,2024-05-01T12:00:00.0000000Z,1,,,BlockBlob,Hot,,,,,
+data/dir/a.txt,2024-05-01T12:00:00.0000000Z,10,1B2M2Y8AsgTpgAmY7PhCfg==,text/plain,BlockBlob,Cool,,"{""owner"": ""me""}",v2,true,
+data/dir/a.txt,2024-04-01T12:00:00.0000000Z,9,,,BlockBlob,Cool,,,v1,false,
+data/dir/sub,2024-05-01T12:00:00.0000000Z,0,,,BlockBlob,Hot,true,,,,
+data/dir/sub/b.log,"Wed, 01 May 2024 12:00:00 GMT",20,,,AppendBlob,,,,,,
+data/dir/gone.txt,2024-05-01T12:00:00.0000000Z,5,,,BlockBlob,Hot,,,,,true
+data/dirt.txt,2024-05-01T12:00:00.0000000Z,5,,,BlockBlob,Hot,,,,,
+`
+
+func inventoryTraverser(prefix string, recursive, includeDirStubs bool) *blobInventoryTraverser {
+	return &blobInventoryTraverser{
+		container:       "data",
+		prefix:          prefix,
+		recursive:       recursive,
+		includeDirStubs: includeDirStubs,
+		rows: func(fn func(inventoryRow) error) error {
+			return readInventoryCSV(strings.NewReader(inventoryCSV), fn)
+		},
+	}
+}
+
+func listInventory(a *assert.Assertions, t *blobInventoryTraverser) map[string]StoredObject {
+	found := map[string]StoredObject{}
+	a.NoError(t.Traverse(noPreProccessor, func(obj StoredObject) error {
+		found[obj.relativePath] = obj
+		return nil
+	}, nil))
+	return found
+}
+
+func TestBlobInventoryTraverserListsTheSource(t *testing.T) {
+	a := assert.New(t)
+
+	found := listInventory(a, inventoryTraverser("dir/", true, false))
+	a.Len(found, 2, "other containers, old versions, deleted blobs, folders and blobs outside the directory aren't listed")
+
+	file := found["a.txt"]
+	a.Equal("a.txt", file.name)
+	a.Equal("data", file.ContainerName)
+	a.Equal(int64(10), file.size)
+	a.True(file.lastModifiedTime.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+	a.Len(file.md5, 16)
+	a.Equal("text/plain", file.contentType)
+	a.EqualValues("BlockBlob", file.blobType)
+	a.EqualValues("Cool", file.blobAccessTier)
+	a.Equal("me", *file.Metadata["owner"])
+
+	log := found["sub/b.log"]
+	a.EqualValues("AppendBlob", log.blobType)
+	a.True(log.lastModifiedTime.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+
+	found = listInventory(a, inventoryTraverser("dir/", false, true))
+	a.Len(found, 2)
+	a.Contains(found, "a.txt")
+	a.Equal(common.EEntityType.Folder(), found["sub"].entityType, "folders are listed when directory stubs are")
+
+	found = listInventory(a, inventoryTraverser("", true, false))
+	a.Len(found, 3)
+	a.Contains(found, "dirt.txt")
+}
+
+func TestBlobInventoryTraverserReportsUnreadableRows(t *testing.T) {
+	a := assert.New(t)
+	traverser := inventoryTraverser("", true, false)
+	traverser.rows = func(fn func(inventoryRow) error) error {
+		return readInventoryCSV(strings.NewReader("Name,Last-Modified,Content-Length\ndata/a,yesterday,1\n"), fn)
+	}
+	err := traverser.Traverse(noPreProccessor, func(StoredObject) error { return nil }, nil)
+	a.ErrorContains(err, "data/a")
+}
+
+func TestBlobInventoryCoversTheSource(t *testing.T) {
+	a := assert.New(t)
+	manifest := func(status string, prefixes ...string) *blobInventoryManifest {
+		m := &blobInventoryManifest{}
+		a.NoError(json.Unmarshal([]byte(`{
+			"destinationContainer": "inventory",
+			"files": [{"blob": "2024/05/01/12-00-00/rule/rule_1.csv", "size": 1024}],
+			"inventoryCompletionTime": "2024-05-01T12:30:00Z",
+			"ruleDefinition": {
+				"filters": {"blobTypes": ["blockBlob", "appendBlob"], "includeBlobVersions": false},
+				"format": "csv",
+				"objectType": "blob",
+				"schemaFields": ["Name", "Creation-Time", "Last-Modified", "Content-Length", "Content-MD5"]
+			},
+			"status": "Succeeded"
+		}`), m))
+		m.Status = status
+		m.RuleDefinition.Filters.PrefixMatch = prefixes
+		return m
+	}
+	traverser := inventoryTraverser("dir/", true, false)
+
+	a.NoError(traverser.checkCovers(manifest("Succeeded")))
+	a.Equal("2024/05/01/12-00-00/rule/rule_1.csv", manifest("Succeeded").Files[0].Blob)
+	a.NoError(traverser.checkCovers(manifest("Succeeded", "other/", "data/d")))
+	a.Error(traverser.checkCovers(manifest("Succeeded", "data/dir/sub/")), "the source is wider than the inventory")
+	a.Error(traverser.checkCovers(manifest("Failed")))
+
+	excluded := manifest("Succeeded")
+	excluded.RuleDefinition.Filters.ExcludePrefix = []string{"data/"}
+	a.Error(traverser.checkCovers(excluded))
+
+	versions := manifest("Succeeded")
+	versions.RuleDefinition.Filters.IncludeBlobVersions = true
+	a.Error(traverser.checkCovers(versions), "the current versions can't be told from the others")
+
+	parquet := manifest("Succeeded")
+	parquet.RuleDefinition.Format = "Parquet"
+	parquet.RuleDefinition.SchemaFields = []string{"Name", "Last-Modified"}
+	a.ErrorContains(traverser.checkCovers(parquet), "Content-Length")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxFlagCooks(t *testing.T) {
+	a := assert.New(t)
+	dst := "https://account.blob.core.windows.net/container"
+
+	raw := getDefaultCopyRawInput(t.TempDir(), dst)
+	raw.sandbox = true
+	cooked, err := raw.cook()
+	a.NoError(err)
+	a.True(cooked.sandbox)
+
+	// the sandbox only covers uploads
+	raw = getDefaultCopyRawInput(dst, t.TempDir())
+	raw.sandbox = true
+	_, err = raw.cook()
+	a.EqualError(err, SandboxIncompatibilityMsg)
+
+	raw = getDefaultCopyRawInput(t.TempDir(), dst)
+	raw.sandbox = true
+	raw.followSymlinks = true
+	_, err = raw.cook()
+	a.Error(err)
+
+	raw = getDefaultCopyRawInput(t.TempDir(), dst)
+	raw.sandbox = true
+	raw.postHook = "true"
+	_, err = raw.cook()
+	a.Error(err)
+}
+
+func TestSandboxSourceDir(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	a.NoError(os.WriteFile(file, nil, 0644))
+
+	a.Equal(dir, sandboxSourceDir(dir))
+	a.Equal(dir, sandboxSourceDir(file))
+	a.Equal(dir, sandboxSourceDir(filepath.Join(dir, "*.txt")))
+	a.Equal(dir, sandboxSourceDir(filepath.Join(dir, "sub*", "x")))
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	a := assert.New(t)
+
+	for _, expr := range []string{"* * * * *", "*/15 9-17 * * mon-fri", "0 0 1,15 jan,jul *", "5/20 * * * 7", "@daily", "@Hourly"} {
+		_, err := parseCronSchedule(expr)
+		a.NoError(err, expr)
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "* * * foo *", "@fortnightly"} {
+		_, err := parseCronSchedule(expr)
+		a.Error(err, expr)
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	a := assert.New(t)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.Local)
+	}
+
+	c, err := parseCronSchedule("*/15 9-17 * * mon-fri")
+	a.NoError(err)
+	a.True(c.matches(at(time.October, 16, 9, 45)))  // a Friday
+	a.False(c.matches(at(time.October, 16, 9, 50))) // not on a quarter hour
+	a.False(c.matches(at(time.October, 16, 18, 0))) // after hours
+	a.False(c.matches(at(time.October, 17, 10, 0))) // a Saturday
+
+	// with both days restricted, either will do
+	c, err = parseCronSchedule("0 3 1 * sun")
+	a.NoError(err)
+	a.True(c.matches(at(time.October, 1, 3, 0)))  // a Thursday, but the 1st
+	a.True(c.matches(at(time.October, 18, 3, 0))) // a Sunday
+	a.False(c.matches(at(time.October, 19, 3, 0)))
+
+	// and with one of them *, both must
+	c, err = parseCronSchedule("0 3 * * 7")
+	a.NoError(err)
+	a.True(c.matches(at(time.October, 18, 3, 0)))
+	a.False(c.matches(at(time.October, 1, 3, 0)))
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2026, time.October, 16, 14, 7, 30, 0, time.UTC)
+
+	c, err := parseCronSchedule("30 2 * * *")
+	a.NoError(err)
+	a.Equal(time.Date(2026, time.October, 17, 2, 30, 0, 0, time.UTC), c.next(now))
+
+	c, err = parseCronSchedule("7 14 * * *")
+	a.NoError(err)
+	a.Equal(time.Date(2026, time.October, 17, 14, 7, 0, 0, time.UTC), c.next(now), "the minute it's in has started already")
+
+	c, err = parseCronSchedule("@yearly")
+	a.NoError(err)
+	a.Equal(time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), c.next(now))
+
+	c, err = parseCronSchedule("0 0 29 2 *")
+	a.NoError(err)
+	a.Equal(time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC), c.next(now))
+
+	c, err = parseCronSchedule("0 0 30 2 *")
+	a.NoError(err)
+	a.True(c.next(now).IsZero())
+
+	runs, err := nextCronRuns("*/20 * * * *", now, 3)
+	a.NoError(err)
+	a.Equal([]time.Time{
+		time.Date(2026, time.October, 16, 14, 20, 0, 0, time.UTC),
+		time.Date(2026, time.October, 16, 14, 40, 0, 0, time.UTC),
+		time.Date(2026, time.October, 16, 15, 0, 0, 0, time.UTC),
+	}, runs)
+}
@@ -0,0 +1,322 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonRunsQueuedJobsInTurn(t *testing.T) {
+	a := assert.New(t)
+	// the daemon logs to glcm, which another test may have left with its channels closed
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	d := &azcopyDaemon{
+		config: daemonConfig{MaxConcurrentJobs: 1, Environment: map[string]string{"AZCOPY_DAEMON_TEST": "3"}},
+		logDir: t.TempDir(),
+		command: func(args []string) *exec.Cmd {
+			return exec.Command("sh", "-c", args[1])
+		},
+	}
+
+	_, err := d.submit([]string{"daemon", "--foreground"})
+	a.Error(err)
+
+	first, err := d.submit([]string{"sync", "sleep 0.2"})
+	a.NoError(err)
+	a.Equal(daemonJobRunning, first.State)
+	second, err := d.submit([]string{"sync", "echo hello; exit $AZCOPY_DAEMON_TEST"})
+	a.NoError(err)
+	a.Equal(daemonJobQueued, second.State)
+
+	d.finished.Wait()
+	jobs := d.status()
+	a.Len(jobs, 2)
+	a.Equal(daemonJobSucceeded, jobs[0].State)
+	a.Equal(daemonJobFailed, jobs[1].State)
+	a.Equal(3, jobs[1].ExitCode)
+	a.False(jobs[1].Started.Before(jobs[0].Finished))
+
+	output, err := os.ReadFile(jobs[1].LogFile)
+	a.NoError(err)
+	a.Equal("hello\n", string(output))
+}
+
+func TestDaemonSocketRequests(t *testing.T) {
+	a := assert.New(t)
+	// the daemon logs to glcm, which another test may have left with its channels closed
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	dir, err := os.MkdirTemp("", "azd") // socket paths are limited to about 100 bytes
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "d.sock")
+	l, err := net.Listen("unix", socket)
+	a.NoError(err)
+	defer l.Close()
+
+	d := &azcopyDaemon{config: daemonConfig{MaxConcurrentJobs: 1}, logDir: dir, command: func(args []string) *exec.Cmd {
+		return exec.Command("true")
+	}}
+	go d.serve(l)
+
+	resp, err := callDaemon(socket, daemonRequest{Command: "submit", Args: []string{"copy", "a", "b"}})
+	a.NoError(err)
+	a.Equal(1, resp.Jobs[0].ID)
+
+	_, err = callDaemon(socket, daemonRequest{Command: "submit", Args: []string{"login"}})
+	a.Error(err)
+
+	d.finished.Wait()
+	resp, err = callDaemon(socket, daemonRequest{Command: "status"})
+	a.NoError(err)
+	a.Len(resp.Jobs, 1)
+	a.Equal([]string{"copy", "a", "b"}, resp.Jobs[0].Args)
+}
+
+func TestDaemonAPI(t *testing.T) {
+	a := assert.New(t)
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	d := &azcopyDaemon{config: daemonConfig{MaxConcurrentJobs: 1}, logDir: t.TempDir(), command: func(args []string) *exec.Cmd {
+		return exec.Command("sh", "-c", args[1])
+	}}
+	server := httptest.NewServer(d.apiHandler("secret"))
+	defer server.Close()
+
+	call := func(method, path, body string) (*http.Response, daemonJob) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		a.NoError(err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		a.NoError(err)
+		defer resp.Body.Close()
+		var job daemonJob
+		_ = json.NewDecoder(resp.Body).Decode(&job)
+		return resp, job
+	}
+
+	resp, err := http.Get(server.URL + "/v1/jobs")
+	a.NoError(err)
+	a.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	// stands in for an AzCopy job, which reports its job ID and progress as JSON, and listens for the pause signals
+	script := `trap '' USR1 USR2; trap 'exit 3' INT
+printf '%s\n' '{"MessageType":"Init","MessageContent":"{\"JobID\":\"abc\"}"}'
+printf '%s\n' '{"MessageType":"Progress","MessageContent":"{\"PercentComplete\":50}"}'
+while :; do sleep 0.1; done`
+	body, _ := json.Marshal(daemonRequest{Args: []string{"copy", script}})
+	resp, job := call(http.MethodPost, "/v1/jobs", string(body))
+	a.Equal(http.StatusCreated, resp.StatusCode)
+	a.Equal("/v1/jobs/1", resp.Header.Get("Location"))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/jobs/1/progress", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	stream, err := (&http.Client{Timeout: time.Minute}).Do(req)
+	a.NoError(err)
+	defer stream.Body.Close()
+	lines := bufio.NewScanner(stream.Body)
+	for lines.Scan() {
+		a.NoError(json.Unmarshal(lines.Bytes(), &job))
+		if job.Progress != nil {
+			break
+		}
+	}
+	a.Equal("abc", job.JobID)
+	a.JSONEq(`{"PercentComplete":50}`, string(job.Progress))
+
+	resp, job = call(http.MethodPost, "/v1/jobs/1/pause", "")
+	a.Equal(http.StatusOK, resp.StatusCode)
+	a.True(job.Paused)
+	_, job = call(http.MethodPost, "/v1/jobs/1/resume", "")
+	a.False(job.Paused)
+	resp, _ = call(http.MethodPost, "/v1/jobs/1/rewind", "")
+	a.Equal(http.StatusConflict, resp.StatusCode)
+	resp, _ = call(http.MethodPost, "/v1/jobs/1/cancel", "")
+	a.Equal(http.StatusOK, resp.StatusCode)
+
+	// the stream ends once the job has
+	for lines.Scan() {
+		a.NoError(json.Unmarshal(lines.Bytes(), &job))
+	}
+	a.Equal(daemonJobCancelled, job.State)
+	a.Equal(3, job.ExitCode)
+
+	resp, _ = call(http.MethodGet, "/v1/jobs/2", "")
+	a.Equal(http.StatusNotFound, resp.StatusCode)
+	resp, _ = call(http.MethodGet, "/v1/jobs?state=cancelled", "")
+	a.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestDaemonRejectsJobsThatRunCommands(t *testing.T) {
+	a := assert.New(t)
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	d := &azcopyDaemon{config: daemonConfig{MaxConcurrentJobs: 1}, logDir: t.TempDir(), command: func(args []string) *exec.Cmd {
+		return exec.Command("true")
+	}}
+
+	for _, args := range [][]string{
+		{"copy", "a", "b", "--pre-hook", "touch /tmp/x"},
+		{"sync", "a", "b", "--post-hook=touch /tmp/x"},
+		{"copy", "a", "b", "--from-zfs-snapshot"},
+	} {
+		_, err := d.submit(args)
+		a.ErrorContains(err, "runs commands", args)
+	}
+	a.Empty(d.status())
+
+	_, err := d.submit([]string{"copy", "a", "b", "--pre-hookish"})
+	a.NoError(err)
+	d.finished.Wait()
+}
+
+func TestDaemonForgetsOldFinishedJobs(t *testing.T) {
+	a := assert.New(t)
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	d := &azcopyDaemon{config: daemonConfig{MaxConcurrentJobs: 1, MaxFinishedJobs: 2}, logDir: t.TempDir(), command: func(args []string) *exec.Cmd {
+		return exec.Command("true")
+	}}
+
+	for i := 0; i < 4; i++ {
+		_, err := d.submit([]string{"copy", "a", "b"})
+		a.NoError(err)
+		d.finished.Wait()
+	}
+	jobs := d.status()
+	a.Len(jobs, 2)
+	a.Equal(3, jobs[0].ID)
+	a.Equal(4, jobs[1].ID)
+
+	_, _, err := d.watch(1)
+	a.ErrorIs(err, errNoSuchDaemonJob)
+	job, _, err := d.watch(4)
+	a.NoError(err)
+	a.Equal(daemonJobSucceeded, job.State)
+
+	// numbers aren't reused, so a client holding on to an old one can't be handed someone else's job
+	job, err = d.submit([]string{"copy", "a", "b"})
+	a.NoError(err)
+	a.Equal(5, job.ID)
+	d.finished.Wait()
+}
+
+func TestDaemonAPIListenNeedsTLSOffLoopback(t *testing.T) {
+	a := assert.New(t)
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+
+	_, err := listenAPI("127.0.0.1:0", "", "", "")
+	a.Error(err)
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0"} {
+		l, err := listenAPI(addr, "secret", "", "")
+		if a.NoError(err, addr) {
+			l.Close()
+		}
+	}
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.0.2.1:0", "example.com:0"} {
+		_, err = listenAPI(addr, "secret", "", "")
+		a.Error(err, addr)
+	}
+
+	// given a certificate, any address will do, and the API is served over TLS
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	a.NoError(err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	a.NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	a.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600))
+
+	_, err = listenAPI("0.0.0.0:0", "secret", certFile, "")
+	a.Error(err)
+	l, err := listenAPI("0.0.0.0:0", "secret", certFile, keyFile)
+	a.NoError(err)
+	defer l.Close()
+
+	d := &azcopyDaemon{config: daemonConfig{MaxConcurrentJobs: 1}, logDir: t.TempDir()}
+	api := d.serveAPI(l, "secret")
+	defer api.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	req, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:"+port+"/v1/jobs", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := server.Client().Do(req)
+	if a.NoError(err) {
+		resp.Body.Close()
+		a.Equal(http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestDaemonSchedulesDontOverlap(t *testing.T) {
+	a := assert.New(t)
+	mockedRPC := interceptor{}
+	mockedRPC.init()
+	config := daemonConfig{MaxConcurrentJobs: 2, Schedules: []daemonSchedule{
+		{Name: "nightly", Schedule: "30 2 * * *", Args: []string{"sync", "sleep 0.3; echo synced"}},
+	}}
+	a.NoError(parseDaemonSchedules(config.Schedules))
+	d := &azcopyDaemon{config: config, logDir: t.TempDir(), command: func(args []string) *exec.Cmd {
+		return exec.Command("sh", "-c", args[1])
+	}}
+
+	d.runDueSchedules(time.Date(2026, time.October, 16, 2, 29, 0, 0, time.Local))
+	a.Empty(d.status())
+
+	d.runDueSchedules(time.Date(2026, time.October, 16, 2, 30, 0, 0, time.Local))
+	jobs := d.status()
+	a.Len(jobs, 1)
+	a.Equal("nightly", jobs[0].Schedule)
+
+	// the first run is still going, so this one is skipped
+	_, err := d.runScheduleNow("nightly")
+	a.Error(err)
+	_, err = d.runScheduleNow("weekly")
+	a.ErrorIs(err, errNoSuchSchedule)
+
+	d.finished.Wait()
+	second, err := d.runScheduleNow("nightly")
+	a.NoError(err)
+	d.finished.Wait()
+
+	schedules := d.schedules(time.Date(2026, time.October, 16, 12, 0, 0, 0, time.Local))
+	a.Len(schedules, 1)
+	a.Equal(second.ID, schedules[0].LastJob)
+	a.Equal(daemonJobSucceeded, schedules[0].LastState)
+	a.Equal(1, schedules[0].SkippedRuns)
+	a.Equal(time.Date(2026, time.October, 17, 2, 30, 0, 0, time.Local), schedules[0].NextRun)
+
+	log, err := os.ReadFile(filepath.Join(d.logDir, "schedule-nightly.log"))
+	a.NoError(err)
+	a.Equal(2, strings.Count(string(log), "synced\n"))
+	a.Contains(string(log), "=== Job 1, started ")
+}
+
+func TestParseDaemonSchedules(t *testing.T) {
+	a := assert.New(t)
+	a.Error(parseDaemonSchedules([]daemonSchedule{{Name: "../etc", Schedule: "@daily", Args: []string{"sync", "a", "b"}}}))
+	a.Error(parseDaemonSchedules([]daemonSchedule{{Name: "a", Schedule: "@daily", Args: []string{"login"}}}))
+	a.Error(parseDaemonSchedules([]daemonSchedule{{Name: "a", Schedule: "daily", Args: []string{"sync", "a", "b"}}}))
+	a.Error(parseDaemonSchedules([]daemonSchedule{
+		{Name: "a", Schedule: "@daily", Args: []string{"sync", "a", "b"}},
+		{Name: "a", Schedule: "@hourly", Args: []string{"copy", "a", "b"}},
+	}))
+}
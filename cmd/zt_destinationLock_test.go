@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationLockFile(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	release, err := lockDestinationFile("/data/backup")
+	a.NoError(err)
+	pid, err := os.ReadFile(destinationLockPath("/data/backup"))
+	a.NoError(err)
+	a.Equal(strconv.Itoa(os.Getpid())+"\n", string(pid))
+
+	// a second sync to the same destination is kept out, but not one to another
+	_, err = lockDestinationFile("/data/backup")
+	a.ErrorContains(err, "another sync to /data/backup is already running")
+	other, err := lockDestinationFile("/data/other")
+	a.NoError(err)
+	other()
+
+	// until the first has finished
+	release()
+	release, err = lockDestinationFile("/data/backup")
+	a.NoError(err)
+	release()
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// storedObjectTraverser enumerates the objects it was made with
+type storedObjectTraverser []StoredObject
+
+func (t storedObjectTraverser) Traverse(preprocessor objectMorpher, processor objectProcessor, filters []ObjectFilter) error {
+	for _, object := range t {
+		_, err := getProcessingError(processIfPassedFilters(filters, object, processor))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t storedObjectTraverser) IsDirectory(isSource bool) (bool, error) {
+	return true, nil
+}
+
+func TestDiffAppliesSyncComparison(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	source := storedObjectTraverser{
+		{name: "same", relativePath: "same", lastModifiedTime: now, size: 1},
+		{name: "newer", relativePath: "dir/newer", lastModifiedTime: now, size: 2},
+		{name: "new", relativePath: "dir/new", lastModifiedTime: now, size: 3},
+		{name: "dir", relativePath: "dir", entityType: common.EEntityType.Folder()},
+	}
+	destination := storedObjectTraverser{
+		{name: "same", relativePath: "same", lastModifiedTime: now.Add(time.Hour), size: 1},
+		{name: "newer", relativePath: "dir/newer", lastModifiedTime: now.Add(-time.Hour), size: 2},
+		{name: "old", relativePath: "old", lastModifiedTime: now, size: 4},
+	}
+
+	report, err := cookedDiffCmdArgs{compareHash: common.ESyncHashType.None()}.diff(source, destination)
+	a.NoError(err)
+	a.Equal([]DiffEntry{
+		{Path: "dir/new", Size: 3, Status: diffOnlyInSource},
+		{Path: "dir/newer", Size: 2, Status: diffModified},
+		{Path: "old", Size: 4, Status: diffOnlyInDestination},
+	}, report.Entries)
+	a.Equal(1, report.OnlyInSource)
+	a.Equal(1, report.OnlyInDestination)
+	a.Equal(1, report.Modified)
+
+	// with hashes compared, a file is modified if they differ, whichever is newer
+	source[0].md5, destination[0].md5 = []byte{'s'}, []byte{'d'}
+	source[1].md5, destination[1].md5 = []byte{'h'}, []byte{'h'}
+	report, err = cookedDiffCmdArgs{compareHash: common.ESyncHashType.MD5()}.diff(source, destination)
+	a.NoError(err)
+	a.Equal(1, report.Modified)
+	a.Equal("same", report.Entries[2].Path)
+}
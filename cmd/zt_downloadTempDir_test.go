@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDownloadTempDir(t *testing.T) {
+	a := assert.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("file systems aren't told apart on Windows")
+	}
+	dir := t.TempDir()
+	dst := common.ResourceString{Value: filepath.Join(dir, "dst", "not", "made", "yet")}
+	remote := common.ResourceString{Value: "https://account.blob.core.windows.net/container", SAS: "sig=x"}
+
+	a.NoError(validateDownloadTempDir(common.EFromTo.BlobLocal(), dst), "without a temporary directory there's nothing to check")
+
+	t.Setenv(common.EEnvironmentVariable.DownloadTempDir().Name, filepath.Join(dir, "temp"))
+	a.NoError(validateDownloadTempDir(common.EFromTo.BlobLocal(), dst))
+	a.NoError(validateDownloadTempDir(common.EFromTo.LocalBlob(), remote))
+
+	// the destination's file system is that of the closest of its parents that exists
+	fi, err := os.Stat("/proc")
+	if err != nil || !fi.IsDir() {
+		t.Skip("no /proc to stand for another file system")
+	}
+	t.Setenv(common.EEnvironmentVariable.DownloadTempDir().Name, "/proc")
+	a.Error(validateDownloadTempDir(common.EFromTo.BlobLocal(), dst))
+	a.Error(validateDownloadTempDir(common.EFromTo.LocalLocal(), dst))
+	a.NoError(validateDownloadTempDir(common.EFromTo.BlobLocal(), common.ResourceString{Value: common.Dev_Null}))
+
+	t.Setenv(common.EEnvironmentVariable.DownloadToTempPath().Name, "false")
+	a.NoError(validateDownloadTempDir(common.EFromTo.BlobLocal(), dst), "files aren't downloaded to the temporary directory")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuPrefix(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("", duPrefix("a.txt", 1))
+	a.Equal("dir/", duPrefix("dir/a.txt", 1))
+	a.Equal("dir/", duPrefix("dir/sub/a.txt", 1))
+	a.Equal("dir/sub/", duPrefix("dir/sub/a.txt", 2))
+	a.Equal("dir/sub/", duPrefix("dir/sub/a.txt", 5))
+}
+
+func TestDuCountsVersionsAndSnapshotsApart(t *testing.T) {
+	a := assert.New(t)
+	c := newDuCounter(cookedDuCmdArgs{
+		resource:         common.ResourceString{Value: "https://account.blob.core.windows.net/container"},
+		depth:            1,
+		includeVersions:  true,
+		includeSnapshots: true,
+	})
+	c.add("top.txt", StoredObject{size: 1})
+	c.add("dir/a.txt", StoredObject{size: 10, blobVersionID: "2024-01-01T00:00:00.0000000Z"})
+	c.add("dir/a.txt", StoredObject{size: 20, blobVersionID: "2024-02-01T00:00:00.0000000Z"})
+	c.add("dir/sub/b.txt", StoredObject{size: 100, blobSnapshotID: "2024-03-01T00:00:00.0000000Z"})
+	r := c.finish()
+
+	// the latest version of a blob is its current version
+	a.Equal(DuUsage{Files: 2, Bytes: 21}, r.Current)
+	a.Equal(DuUsage{Files: 1, Bytes: 10}, *r.PreviousVersions)
+	a.Equal(DuUsage{Files: 1, Bytes: 100}, *r.Snapshots)
+	a.Equal(DuUsage{Files: 4, Bytes: 131}, r.Total)
+	a.Equal([]DuPrefix{
+		{Prefix: "", DuUsage: DuUsage{Files: 1, Bytes: 1}},
+		{Prefix: "dir/", DuUsage: DuUsage{Files: 3, Bytes: 130}},
+	}, r.Prefixes)
+}
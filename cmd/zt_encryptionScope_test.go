@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEncryptionScope(t *testing.T) {
+	a := assert.New(t)
+
+	a.NoError(validateEncryptionScope("", false, "", true, common.EFromTo.BlobLocal()))
+	a.NoError(validateEncryptionScope("scope", false, "", false, common.EFromTo.LocalBlob()))
+	a.NoError(validateEncryptionScope("scope", true, "", false, common.EFromTo.BlobBlob()))
+	a.NoError(validateEncryptionScope("", true, "other", false, common.EFromTo.BlobBlob()))
+
+	// only what is written to Blob storage can be encrypted with a scope
+	a.EqualError(validateEncryptionScope("scope", false, "", false, common.EFromTo.BlobLocal()), EncryptionScopeIncompatibilityMsg)
+	a.EqualError(validateEncryptionScope("scope", false, "", false, common.EFromTo.LocalFile()), EncryptionScopeIncompatibilityMsg)
+	a.EqualError(validateEncryptionScope("", true, "", false, common.EFromTo.FileBlob()), PreserveEncryptionScopeIncompatibilityMsg)
+
+	// and not with a key by name, which is the same thing, or by value
+	a.ErrorContains(validateEncryptionScope("scope", false, "other", false, common.EFromTo.LocalBlob()), "cpk-by-name")
+	a.ErrorContains(validateEncryptionScope("scope", false, "", true, common.EFromTo.LocalBlob()), "cpk-by-value")
+	a.ErrorContains(validateEncryptionScope("", true, "", true, common.EFromTo.BlobBlob()), "cpk-by-value")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateAddsUpTheDifferences(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	source := storedObjectTraverser{
+		{name: "same", relativePath: "same", lastModifiedTime: now, size: 1},
+		{name: "newer", relativePath: "newer", lastModifiedTime: now, size: 20 * 1000 * 1000},
+		{name: "new", relativePath: "new", lastModifiedTime: now, size: 5 * 1000 * 1000},
+	}
+	destination := storedObjectTraverser{
+		{name: "same", relativePath: "same", lastModifiedTime: now.Add(time.Hour), size: 1},
+		{name: "newer", relativePath: "newer", lastModifiedTime: now.Add(-time.Hour), size: 2},
+		{name: "old", relativePath: "old", lastModifiedTime: now, size: 4},
+	}
+	diff, err := cookedDiffCmdArgs{compareHash: common.ESyncHashType.None()}.diff(source, destination)
+	a.NoError(err)
+
+	report := newEstimateReport(diff, 100)
+	a.Equal(int64(2), report.FilesToTransfer)
+	a.Equal(int64(25*1000*1000), report.BytesToTransfer, "modified files are counted at their size at the source")
+	a.Equal(int64(1), report.FilesToDelete)
+	a.Equal(int64(4), report.BytesToDelete)
+	a.Equal(int64(2), report.EstimatedSeconds, "25 MB at 100 Mbps")
+	a.Equal("Files to transfer: 2 (25000000)\nFiles to delete, with --delete-destination: 1 (4)\nEstimated time at 100 Mbps: 2s", report.text(true))
+
+	report = newEstimateReport(diff, 0)
+	a.Zero(report.EstimatedSeconds)
+	a.NotContains(report.text(true), "Estimated time")
+}
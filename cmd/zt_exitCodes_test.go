@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestJobExitCode(t *testing.T) {
+	a := assert.New(t)
+	done := common.EJobStatus.Completed()
+
+	a.Equal(common.EExitCode.Success(), jobExitCode(common.ListJobSummaryResponse{JobStatus: done, TransfersCompleted: 10}))
+	a.Equal(common.EExitCode.CompletedWithErrors(), jobExitCode(common.ListJobSummaryResponse{
+		JobStatus: common.EJobStatus.CompletedWithErrors(), TransfersCompleted: 9, TransfersFailed: 1}))
+	a.Equal(common.EExitCode.Error(), jobExitCode(common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Failed(), TransfersFailed: 10}))
+
+	// failures that are all of one kind are reported as that kind
+	a.Equal(common.EExitCode.NetworkFailure(), jobExitCode(common.ListJobSummaryResponse{
+		JobStatus: common.EJobStatus.CompletedWithErrors(), TransfersCompleted: 9, TransfersFailed: 1, TransfersFailedNetwork: 1}))
+	a.Equal(common.EExitCode.CompletedWithErrors(), jobExitCode(common.ListJobSummaryResponse{
+		JobStatus: common.EJobStatus.CompletedWithErrors(), TransfersCompleted: 8, TransfersFailed: 2, TransfersFailedNetwork: 1}))
+
+	// an authorization failure cancels the job, but is what needs fixing
+	a.Equal(common.EExitCode.AuthFailure(), jobExitCode(common.ListJobSummaryResponse{
+		JobStatus: common.EJobStatus.Cancelled(), TransfersFailed: 1, TransfersFailedAuth: 1}))
+	a.Equal(common.EExitCode.Cancelled(), jobExitCode(common.ListJobSummaryResponse{JobStatus: common.EJobStatus.Cancelling()}))
+}
+
+func TestErrorExitCode(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(common.EExitCode.NothingToDo(), errorExitCode(NothingScheduledError))
+	a.Equal(common.EExitCode.NothingToDo(), errorExitCode(ErrNothingToRemove))
+	a.Equal(common.EExitCode.AuthFailure(), errorExitCode(fmt.Errorf("cannot start job due to error %w",
+		&azcore.ResponseError{StatusCode: http.StatusForbidden, ErrorCode: "AuthorizationPermissionMismatch"})))
+	a.Equal(common.EExitCode.Error(), errorExitCode(&azcore.ResponseError{StatusCode: http.StatusNotFound}))
+
+	a.Equal(common.EExitCode.NetworkFailure(), errorExitCode(&url.Error{Op: "Get", URL: "https://account.blob.core.windows.net",
+		Err: &net.DNSError{Err: "no such host", Name: "account.blob.core.windows.net", IsNotFound: true}}))
+	a.Equal(common.EExitCode.NetworkFailure(), errorExitCode(fmt.Errorf("reading: %w", syscall.ECONNRESET)))
+	a.Equal(common.EExitCode.Error(), errorExitCode(&url.Error{Op: "Get", URL: "https://account.blob.core.windows.net", Err: context.Canceled}))
+	a.Equal(common.EExitCode.Error(), errorExitCode(&os.PathError{Op: "open", Path: "/data/a", Err: syscall.EACCES}))
+	a.Equal(common.EExitCode.Error(), errorExitCode(errors.New("something else")))
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestFsyncFlagDefaultCooks(t *testing.T) {
+	a := assert.New(t)
+	dst := "https://account.blob.core.windows.net/container"
+
+	copyCmd, _, err := rootCmd.Find([]string{"copy"})
+	a.NoError(err)
+	rawCopy := getDefaultCopyRawInput(t.TempDir(), dst)
+	rawCopy.fsync = copyCmd.PersistentFlags().Lookup(FsyncFlag).DefValue
+	cookedCopy, err := rawCopy.cook()
+	a.NoError(err)
+	a.Equal(common.FsyncPolicy{}, cookedCopy.fsync)
+
+	syncCmd, _, err := rootCmd.Find([]string{"sync"})
+	a.NoError(err)
+	rawSync := getDefaultSyncRawInput(t.TempDir(), dst)
+	rawSync.fsync = syncCmd.PersistentFlags().Lookup(FsyncFlag).DefValue
+	cookedSync, err := rawSync.cook()
+	a.NoError(err)
+	a.Equal(common.FsyncPolicy{}, cookedSync.fsync)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardlinksPreservedRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not tracked on Windows")
+	}
+
+	src := t.TempDir()
+	a.NoError(os.MkdirAll(filepath.Join(src, "a"), os.ModePerm))
+	a.NoError(os.MkdirAll(filepath.Join(src, "b", "c"), os.ModePerm))
+	a.NoError(os.WriteFile(filepath.Join(src, "a", "first"), []byte("content"), 0644))
+	a.NoError(os.Link(filepath.Join(src, "a", "first"), filepath.Join(src, "b", "c", "second")))
+
+	tracker := newHardlinkTracker()
+	fi, err := os.Stat(filepath.Join(src, "a", "first"))
+	a.NoError(err)
+	entityType, meta := tracker.classify(fi, "a/first")
+	a.Equal(common.EEntityType.File(), entityType)
+	a.Nil(meta)
+
+	fi, err = os.Stat(filepath.Join(src, "b", "c", "second"))
+	a.NoError(err)
+	entityType, meta = tracker.classify(fi, "b/c/second")
+	a.Equal(common.EEntityType.Hardlink(), entityType)
+	a.Equal("..%2F..%2Fa%2Ffirst", *meta[common.POSIXHardlinkMeta])
+
+	// downloaded somewhere else entirely, the link still finds its target
+	dst := t.TempDir()
+	a.NoError(os.MkdirAll(filepath.Join(dst, "a"), os.ModePerm))
+	a.NoError(os.WriteFile(filepath.Join(dst, "a", "first"), []byte("content"), 0644))
+
+	links := newDeferredHardlinks(dst)
+	a.NoError(links.add(filepath.Join(dst, "b", "c", "second"), *meta[common.POSIXHardlinkMeta]))
+	created, failed := links.create(common.EOverwriteOption.True())
+	a.Equal(1, created)
+	a.Equal(0, failed)
+
+	first, err := os.Stat(filepath.Join(dst, "a", "first"))
+	a.NoError(err)
+	second, err := os.Stat(filepath.Join(dst, "b", "c", "second"))
+	a.NoError(err)
+	a.True(os.SameFile(first, second))
+}
+
+func TestHardlinkTargetsOutsideDestinationRefused(t *testing.T) {
+	a := assert.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not tracked on Windows")
+	}
+
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	a.NoError(os.WriteFile(secret, []byte("secret"), 0600))
+
+	dst := t.TempDir()
+	links := newDeferredHardlinks(dst)
+	a.Error(links.add(filepath.Join(dst, "link"), "..%2F..%2F..%2F..%2F..%2Fetc%2Fshadow"))
+	a.Error(links.add(filepath.Join(dst, "link"), "%2Fetc%2Fshadow"))
+	rel, err := filepath.Rel(dst, secret)
+	a.NoError(err)
+	a.Error(links.add(filepath.Join(dst, "link"), filepath.ToSlash(rel)))
+	a.Error(links.add(filepath.Join(outside, "link"), "secret"))
+
+	// a symlink in the downloaded tree can't be used to get around the check
+	a.NoError(os.Symlink(outside, filepath.Join(dst, "escape")))
+	a.NoError(links.add(filepath.Join(dst, "link"), "escape%2Fsecret"))
+	created, failed := links.create(common.EOverwriteOption.True())
+	a.Equal(0, created)
+	a.Equal(1, failed)
+	_, err = os.Lstat(filepath.Join(dst, "link"))
+	a.True(os.IsNotExist(err))
+}
+
+func TestHardlinksClassifiedAfterFilters(t *testing.T) {
+	a := assert.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not tracked on Windows")
+	}
+
+	src := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(src, "excluded"), []byte("content"), 0644))
+	a.NoError(os.Link(filepath.Join(src, "excluded"), filepath.Join(src, "included")))
+
+	traverser := &localTraverser{hardlinks: newHardlinkTracker()}
+	filters := buildExcludeFilters([]string{"excluded"}, false)
+
+	var processed []StoredObject
+	processor := func(o StoredObject) error {
+		processed = append(processed, o)
+		return nil
+	}
+	for _, name := range []string{"excluded", "included"} {
+		fi, err := os.Stat(filepath.Join(src, name))
+		a.NoError(err)
+		o := StoredObject{name: name, relativePath: name, entityType: common.EEntityType.File(), size: fi.Size()}
+		_ = traverser.processIfPassedFilters(filters, o, fi, processor)
+	}
+
+	// the excluded name never counts as the first one, so the included one is sent with its content
+	a.Len(processed, 1)
+	a.Equal("included", processed[0].relativePath)
+	a.Equal(common.EEntityType.File(), processed[0].entityType)
+	a.Equal(int64(len("content")), processed[0].size)
+}
+
+func TestHardlinksPreservedWhenEnumeratingCopy(t *testing.T) {
+	a := assert.New(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are not tracked on Windows")
+	}
+
+	src := t.TempDir()
+	a.NoError(os.MkdirAll(filepath.Join(src, "a", "b"), os.ModePerm))
+	a.NoError(os.WriteFile(filepath.Join(src, "a", "f1"), []byte("content"), 0644))
+	a.NoError(os.Link(filepath.Join(src, "a", "f1"), filepath.Join(src, "a", "b", "hl")))
+
+	mockedRPC := interceptor{}
+	jobsAdmin.ExecuteNewCopyJobPartOrder = func(order common.CopyJobPartOrderRequest) common.CopyJobPartOrderResponse {
+		return mockedRPC.intercept(order)
+	}
+	mockedRPC.init()
+
+	raw := getDefaultCopyRawInput(src, t.TempDir())
+	raw.recursive = true
+	raw.hardlinks = common.EHardlinkHandlingType.Preserve().String()
+
+	runCopyAndVerify(a, raw, func(err error) {
+		a.NoError(err)
+
+		entityTypes := map[string]common.EntityType{}
+		for _, transfer := range mockedRPC.transfers {
+			entityTypes[filepath.Base(transfer.Source)] = transfer.EntityType
+		}
+		// the tree is walked in order, so a/b/hl is the second name of the inode, and only refers back to a/f1
+		a.Equal(common.EEntityType.File(), entityTypes["f1"])
+		a.Equal(common.EEntityType.Hardlink(), entityTypes["hl"])
+	})
+}
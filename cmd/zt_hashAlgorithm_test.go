@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHashAlgorithm(t *testing.T) {
+	a := assert.New(t)
+	sha256 := common.EContentHashType.SHA256()
+
+	a.NoError(validateHashAlgorithm(common.EContentHashType.MD5(), true, common.EFromTo.LocalFile()))
+	a.NoError(validateHashAlgorithm(sha256, true, common.EFromTo.LocalBlob()))
+	a.NoError(validateHashAlgorithm(sha256, false, common.EFromTo.BlobLocal()))
+	a.NoError(validateHashAlgorithm(common.EContentHashType.CRC64(), false, common.EFromTo.FileLocal()))
+
+	// other hashes are recorded in metadata, which is only done for blobs, and only when put-md5 asks for them
+	a.EqualError(validateHashAlgorithm(sha256, true, common.EFromTo.LocalFile()), HashAlgorithmIncompatibilityMsg)
+	a.EqualError(validateHashAlgorithm(sha256, false, common.EFromTo.LocalBlob()), HashAlgorithmIncompatibilityMsg)
+	a.EqualError(validateHashAlgorithm(sha256, true, common.EFromTo.BlobBlob()), HashAlgorithmIncompatibilityMsg)
+}
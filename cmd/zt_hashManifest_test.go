@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashManifestRoundTrips(t *testing.T) {
+	a := assert.New(t)
+	sum := sha256.Sum256([]byte("some data"))
+	entries := []hashManifestEntry{
+		{Path: "dir/a file", Size: 9, Hash: sum[:]},
+		{Path: `odd\name` + "\nwith a line feed", Size: 9, Hash: sum[:]},
+		{Path: "dir/(b) = c", Size: 9, Hash: sum[:]},
+	}
+
+	for _, format := range validManifestFormats() {
+		cooked := cookedHashCmdArgs{format: format, hashType: common.EContentHashType.SHA256()}
+		var manifest bytes.Buffer
+		a.NoError(cooked.writeManifest(&manifest, entries))
+		a.Equal(len(entries), strings.Count(manifest.String(), "\n"), format)
+
+		read, err := readHashManifest(&manifest, common.EContentHashType.SHA256())
+		a.NoError(err, format)
+		a.Len(read, len(entries), format)
+		for i, e := range read {
+			a.Equal(entries[i].Path, e.Path, format)
+			a.Equal(entries[i].Hash, e.Hash, format)
+			// only JSON manifests record sizes
+			a.Equal(common.Iff(format == manifestFormatJSON, int64(9), int64(-1)), e.Size, format)
+		}
+	}
+}
+
+func TestReadHashManifestOfOtherTools(t *testing.T) {
+	a := assert.New(t)
+	// as written by md5sum in binary mode, and by FreeBSD's md5
+	manifest := "d41d8cd98f00b204e9800998ecf8427e *empty\r\n\nMD5 (dir/empty) = d41d8cd98f00b204e9800998ecf8427e\n"
+	read, err := readHashManifest(strings.NewReader(manifest), common.EContentHashType.MD5())
+	a.NoError(err)
+	a.Len(read, 2)
+	a.Equal("empty", read[0].Path)
+	a.Equal("dir/empty", read[1].Path)
+
+	// hashes must be of the type being read
+	_, err = readHashManifest(strings.NewReader(manifest), common.EContentHashType.SHA256())
+	a.Error(err)
+	_, err = readHashManifest(strings.NewReader("SHA256 (a) = d41d8cd98f00b204e9800998ecf8427e\n"), common.EContentHashType.MD5())
+	a.Error(err)
+	_, err = readHashManifest(strings.NewReader("not a manifest\n"), common.EContentHashType.MD5())
+	a.Error(err)
+}
+
+func TestHashFilesUsesRecordedAndLocalHashes(t *testing.T) {
+	a := assert.New(t)
+	data := []byte("some data")
+	sum := sha256.Sum256(data)
+	h := newContentHasher(common.EContentHashType.SHA256())
+	h.openLocal = func(path string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	local := h.content(common.ELocation.Local(), common.ResourceString{Value: "/dir"}, StoredObject{relativePath: "a", size: int64(len(data))})
+	a.NoError(h.hash(&local))
+	a.Equal(sum[:], local.hash)
+
+	// a blob whose hash wasn't recorded has none, since it isn't downloaded to hash it
+	remote := h.content(common.ELocation.Blob(), common.ResourceString{}, StoredObject{relativePath: "a", size: int64(len(data))})
+	a.NoError(h.hash(&remote))
+	a.Empty(remote.hash)
+
+	a.Equal("a", manifestPath(StoredObject{relativePath: "a", name: "b"}))
+	a.Equal("b", manifestPath(StoredObject{name: "b"}))
+}
+
+// flagDefault returns the default of a command's flag, as it's run without it
+func flagDefault(command, flag string) string {
+	c, _, err := rootCmd.Find([]string{command})
+	common.PanicIfErr(err)
+	return c.PersistentFlags().Lookup(flag).DefValue
+}
+
+func TestHashManifestVerifies(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	a.NoError(os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	a.NoError(os.WriteFile(filepath.Join(dir, "a"), []byte("some data"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(dir, "sub", "b"), []byte("other data"), 0644))
+
+	for _, format := range validManifestFormats() {
+		// as azcopy hash and azcopy verify --manifest are run with no more than they need
+		manifest := filepath.Join(t.TempDir(), "manifest")
+		hash, err := rawHashCmdArgs{src: dir, recursive: true, hashAlgorithm: flagDefault("hash", "hash-algorithm"), format: format, output: manifest}.cook()
+		a.NoError(err, format)
+		skipped, err := hash.process()
+		a.NoError(err, format)
+		a.Zero(skipped, format)
+
+		raw := rawVerifyCmdArgs{dst: dir, recursive: true, manifest: manifest,
+			hashAlgorithm: flagDefault("verify", "hash-algorithm"), checkHash: flagDefault("verify", "check-hash")}
+		verify, err := raw.cook()
+		a.NoError(err, format)
+		a.Equal(hash.hashType, verify.hashType, format)
+		report, err := verify.process()
+		a.NoError(err, format)
+		a.True(report.Passed, format)
+		a.Equal(2, report.FilesMatched, format)
+	}
+
+	// GNU manifests don't name their hash, which is told by its length
+	for _, hashType := range []common.ContentHashType{common.EContentHashType.MD5(), common.EContentHashType.SHA256(), common.EContentHashType.CRC64()} {
+		line := strings.Repeat("0", 2*hashType.NewHasher().Size()) + "  a\n"
+		read, err := manifestHashType(strings.NewReader("\n" + line))
+		a.NoError(err)
+		a.Equal(hashType, read)
+	}
+	_, err := manifestHashType(strings.NewReader("0123  a\n"))
+	a.Error(err)
+}
@@ -116,6 +116,9 @@ func (m *mockedLifecycleManager) Error(msg string) {
 	default:
 	}
 }
+func (m *mockedLifecycleManager) ErrorWithExitCode(msg string, _ common.ExitCode) {
+	m.Error(msg)
+}
 func (*mockedLifecycleManager) SurrenderControl()                               {}
 func (*mockedLifecycleManager) RegisterCloseFunc(func())                        {}
 func (mockedLifecycleManager) AllowReinitiateProgressReporting()                {}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestHookEnvironment(t *testing.T) {
+	a := assert.New(t)
+	h := &jobHooks{command: "sync", source: "/tank/data", destination: "https://account.blob.core.windows.net/c"}
+
+	env := h.environment(nil)
+	a.Contains(env, "AZCOPY_COMMAND=sync")
+	a.Contains(env, "AZCOPY_SOURCE=/tank/data")
+	for _, v := range env {
+		a.False(strings.HasPrefix(v, "AZCOPY_JOB_STATUS="), "the status isn't known before the job")
+	}
+
+	env = h.environment(&jobNotification{Status: "CompletedWithErrors", ExitCode: common.EExitCode.CompletedWithErrors(),
+		TotalTransfers: 10, TransfersFailed: 2, BytesTransferred: 4096, DurationSeconds: 61.7})
+	a.Contains(env, "AZCOPY_JOB_STATUS=CompletedWithErrors")
+	a.Contains(env, "AZCOPY_EXIT_CODE=4")
+	a.Contains(env, "AZCOPY_TRANSFERS_TOTAL=10")
+	a.Contains(env, "AZCOPY_TRANSFERS_FAILED=2")
+	a.Contains(env, "AZCOPY_BYTES_TRANSFERRED=4096")
+	a.Contains(env, "AZCOPY_DURATION_SECONDS=62")
+}
+
+func TestRunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks here are written for sh")
+	}
+	a := assert.New(t)
+
+	a.NoError(runHook("pre", `test "$AZCOPY_COMMAND" = copy`, []string{"AZCOPY_COMMAND=copy"}))
+
+	err := runHook("pre", `echo starting; echo "cannot snapshot $AZCOPY_SOURCE" >&2; exit 3`, []string{"AZCOPY_SOURCE=tank/data"})
+	a.Error(err)
+	a.Contains(err.Error(), "exit status 3")
+	a.Contains(err.Error(), "cannot snapshot tank/data")
+	a.NotContains(err.Error(), "starting")
+}
+
+func TestLastLine(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("one", lastLine("one"))
+	a.Equal("three", lastLine("one\ntwo\n three"))
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	policy, err := parseRetentionPolicy("", 0, "")
+	a.NoError(err)
+	a.False(policy.IsSet())
+
+	policy, err = parseRetentionPolicy("30d", 5, "10G")
+	a.NoError(err)
+	a.Equal(30*24*time.Hour, policy.OlderThan)
+	a.Equal(5, policy.KeepLast)
+	a.Equal(int64(10)<<30, policy.MaxTotalBytes)
+
+	policy, err = parseRetentionPolicy("1.5d", 0, "")
+	a.NoError(err)
+	a.Equal(36*time.Hour, policy.OlderThan)
+
+	policy, err = parseRetentionPolicy("12h", 0, "")
+	a.NoError(err)
+	a.Equal(12*time.Hour, policy.OlderThan)
+
+	for _, age := range []string{"d", "-1d", "30", "soon"} {
+		_, err = parseRetentionPolicy(age, 0, "")
+		a.Error(err, age)
+	}
+	_, err = parseRetentionPolicy("", -1, "")
+	a.Error(err)
+	_, err = parseRetentionPolicy("", 0, "10")
+	a.Error(err)
+}
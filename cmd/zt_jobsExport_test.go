@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/azcopy"
+	"github.com/Azure/azure-storage-azcopy/v10/jobsAdmin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatJobPlanExport(t *testing.T) {
+	a := assert.New(t)
+
+	plan := azcopy.ExportJobPlanResponse{
+		FromTo:          "LocalBlob",
+		JobStatus:       "Completed",
+		SourceRoot:      "/data",
+		DestinationRoot: "https://account.blob.core.windows.net/container",
+		Parts:           1,
+		Transfers: []jobsAdmin.ExportedTransfer{
+			{TransferIndex: 0, Source: "/data/a,b.txt", Destination: "https://account.blob.core.windows.net/container/a,b.txt",
+				EntityType: "File", Status: "Success", SourceSize: 10, LastModifiedTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+				BlockSize: 8388608},
+			{TransferIndex: 1, Source: "/data/dir", Destination: "https://account.blob.core.windows.net/container/dir",
+				EntityType: "Folder", Status: "Failed", ErrorCode: 403},
+		},
+	}
+
+	csv, err := formatJobPlanExport(plan, "csv")
+	a.NoError(err)
+	a.Equal("PartNumber,TransferIndex,Source,Destination,EntityType,Status,ErrorCode,SourceSize,LastModifiedTime,SavedOffset,BlockSize\n"+
+		`0,0,"/data/a,b.txt","https://account.blob.core.windows.net/container/a,b.txt",File,Success,0,10,2024-05-01T12:00:00Z,0,8388608`+"\n"+
+		"0,1,/data/dir,https://account.blob.core.windows.net/container/dir,Folder,Failed,403,0,,0,0\n", csv)
+
+	out, err := formatJobPlanExport(plan, "JSON")
+	a.NoError(err)
+	var roundTripped azcopy.ExportJobPlanResponse
+	a.NoError(json.Unmarshal([]byte(out), &roundTripped))
+	a.Equal(plan, roundTripped)
+	a.NotContains(out, `"LastModifiedTime": "0001`) // a time that isn't known is left out
+
+	_, err = formatJobPlanExport(plan, "xml")
+	a.Error(err)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagNames(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal([]string{"recursive", "include-pattern", "s2s-preserve-access-tier"},
+		flagNames([]string{"copy", "/data", "https://account.blob.core.windows.net/c?sig=secret", "--recursive",
+			"--include-pattern=*.txt", "--s2s-preserve-access-tier", "false", "--recursive=true"}))
+	a.Equal([]string{}, flagNames([]string{"copy", "a", "b", "--", "--not-a-flag"}))
+}
+
+func TestParseHistorySince(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseHistorySince("", now)
+	a.NoError(err)
+	a.True(since.IsZero())
+
+	since, err = parseHistorySince("7d", now)
+	a.NoError(err)
+	a.Equal(now.Add(-7*24*time.Hour), since)
+
+	since, err = parseHistorySince("2024-05-01T06:00:00Z", now)
+	a.NoError(err)
+	a.Equal(time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC), since)
+
+	since, err = parseHistorySince("2024-05-01", now)
+	a.NoError(err)
+	a.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), since)
+
+	_, err = parseHistorySince("last week", now)
+	a.Error(err)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListingCheckpoint(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	resource := common.ResourceString{Value: "https://account.blob.core.windows.net/container", SAS: "sig=a"}
+	c := newListingCheckpoint(resource)
+
+	marker, err := c.marker("container prefix/")
+	a.NoError(err)
+	a.Nil(marker)
+
+	a.NoError(c.save("container prefix/", to.Ptr("page2")))
+
+	// a renewed SAS doesn't change which checkpoint is read
+	resource.SAS = "sig=b"
+	marker, err = newListingCheckpoint(resource).marker("container prefix/")
+	a.NoError(err)
+	a.Equal("page2", *marker)
+
+	// but a different listing of the container starts over
+	marker, err = c.marker("container other/")
+	a.NoError(err)
+	a.Nil(marker)
+
+	// and once the listing finishes, so does the next
+	a.NoError(c.save("container prefix/", nil))
+	marker, err = c.marker("container prefix/")
+	a.NoError(err)
+	a.Nil(marker)
+}
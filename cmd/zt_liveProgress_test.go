@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveProgressQueries(t *testing.T) {
+	a := assert.New(t)
+	dir, err := os.MkdirTemp("", "azl") // socket paths are limited to about 100 bytes
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+	oldPlanFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = dir
+	defer func() { common.AzcopyJobPlanFolder = oldPlanFolder }()
+
+	jobID := common.NewJobID()
+	err = queryLiveProgress(jobID, "/v1/progress", &liveProgress{})
+	a.Equal(errJobNotLive, err)
+
+	s := newLiveProgressServer(jobID)
+	s.summary = func(id common.JobID) common.ListJobSummaryResponse {
+		return common.ListJobSummaryResponse{JobID: id, TotalTransfers: 2, TransfersCompleted: 1, BytesOverWire: 1000}
+	}
+	s.transfers = func(r common.ListJobTransfersRequest) common.ListJobTransfersResponse {
+		details := []common.TransferDetail{
+			{Src: "a", TransferStatus: common.ETransferStatus.Success()},
+			{Src: "b", TransferStatus: common.ETransferStatus.Started()},
+		}
+		if r.OfStatus == common.ETransferStatus.Started() {
+			details = details[1:]
+		}
+		return common.ListJobTransfersResponse{JobID: r.JobID, Details: details}
+	}
+	l, err := net.Listen("unix", liveProgressSocket(jobID))
+	a.NoError(err)
+	server := &http.Server{Handler: s.handler()}
+	go func() {
+		_ = server.Serve(l)
+	}()
+	defer server.Close()
+
+	var progress liveProgress
+	a.NoError(queryLiveProgress(jobID, "/v1/progress", &progress))
+	a.Equal(jobID, progress.JobID)
+	a.EqualValues(1, progress.TransfersCompleted)
+	a.Greater(progress.AverageThroughputMbps, 0.0)
+
+	var transfers common.ListJobTransfersResponse
+	a.NoError(queryLiveProgress(jobID, "/v1/transfers", &transfers))
+	a.Len(transfers.Details, 2)
+	a.NoError(queryLiveProgress(jobID, "/v1/transfers?status=Started", &transfers))
+	a.Len(transfers.Details, 1)
+	a.Equal("b", transfers.Details[0].Src)
+	a.Error(queryLiveProgress(jobID, "/v1/transfers?status=sideways", &transfers))
+}
+
+func TestLiveProgressSocketIsPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket permissions are a Unix matter")
+	}
+	a := assert.New(t)
+	dir, err := os.MkdirTemp("", "azl")
+	a.NoError(err)
+	defer os.RemoveAll(dir)
+	oldPlanFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = dir
+	defer func() { common.AzcopyJobPlanFolder = oldPlanFolder }()
+
+	jobID := common.NewJobID()
+	startLiveProgress(jobID)
+	defer func() {
+		liveProgressState.Lock()
+		defer liveProgressState.Unlock()
+		stopLiveProgressLocked()
+	}()
+
+	info, err := os.Stat(liveProgressSocket(jobID))
+	a.NoError(err)
+	a.Zero(info.Mode().Perm() & 0077)
+}
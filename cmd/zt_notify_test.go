@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestStartNotifications(t *testing.T) {
+	a := assert.New(t)
+	defer func() { notifier = nil }()
+
+	a.NoError(startNotifications("copy", "/data", "https://account.blob.core.windows.net/c", "", ""))
+	a.Nil(notifier)
+
+	a.Error(startNotifications("copy", "/data", "https://account.blob.core.windows.net/c", "ftp://example.com/hook", ""))
+	a.Error(startNotifications("copy", "/data", "https://account.blob.core.windows.net/c", "", "not an address"))
+
+	// email needs a mail server
+	t.Setenv(common.EEnvironmentVariable.SMTPServer().Name, "")
+	a.Error(startNotifications("copy", "/data", "https://account.blob.core.windows.net/c", "", "ops@example.com"))
+
+	t.Setenv(common.EEnvironmentVariable.SMTPServer().Name, "mail.example.com:587")
+	t.Setenv(common.EEnvironmentVariable.SMTPFrom().Name, "Backups <backups@example.com>")
+	a.NoError(startNotifications("sync", "/data", "https://account.blob.core.windows.net/c", "https://example.com/hook",
+		"ops@example.com, Someone Else <someone@example.com>"))
+	a.Equal([]string{"ops@example.com", "someone@example.com"}, notifier.emailTo)
+	a.Equal("backups@example.com", notifier.smtp.from)
+	a.Equal("mail.example.com:587", notifier.smtp.server)
+}
+
+func TestPostNotification(t *testing.T) {
+	a := assert.New(t)
+
+	var received jobNotification
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		if strings.HasSuffix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	n := jobNotification{
+		JobID:              common.NewJobID(),
+		Command:            "sync",
+		Status:             common.EJobStatus.CompletedWithErrors().String(),
+		ExitCode:           common.EExitCode.CompletedWithErrors(),
+		TransfersCompleted: 9,
+		TransfersFailed:    1,
+		EndTime:            time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	a.NoError(postNotification(srv.URL+"/hook", n))
+	a.Equal("application/json", contentType)
+	a.Equal(n, received)
+
+	err := postNotification(srv.URL+"/broken", n)
+	a.Error(err)
+	a.Contains(err.Error(), "500")
+}
+
+func TestFormatNotificationEmail(t *testing.T) {
+	a := assert.New(t)
+
+	n := jobNotification{
+		JobID:       common.NewJobID(),
+		Command:     "copy",
+		Source:      "/data",
+		Destination: "https://account.blob.core.windows.net/c",
+		Host:        "backup1",
+		Status:      common.EJobStatus.Failed().String(),
+		ExitCode:    common.EExitCode.AuthFailure(),
+		Error:       "403 This request is not authorized to perform this operation.",
+		EndTime:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	email := string(formatNotificationEmail("azcopy@backup1", []string{"ops@example.com", "someone@example.com"}, n))
+	headers, body, ok := strings.Cut(email, "\r\n\r\n")
+	a.True(ok)
+	a.Contains(headers, "To: ops@example.com, someone@example.com\r\n")
+	a.Contains(headers, "Subject: AzCopy copy on backup1: Failed\r\n")
+	a.Contains(body, "exit code 7")
+	a.Contains(body, "Error: 403 This request is not authorized")
+	a.NotContains(body, "Started:") // it failed before its job started
+}
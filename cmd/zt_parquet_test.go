@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+// thriftField is a field of a struct to write with the Thrift compact protocol. Its value is an int32, an int64, a
+// bool, a string, a []thriftField for a struct, or a []any for a list of one of those.
+type thriftField struct {
+	id int16
+	v  any
+}
+
+func thriftType(v any) byte {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+		return 2
+	case int32:
+		return 5
+	case int64:
+		return 6
+	case string:
+		return 8
+	case []any:
+		return 9
+	}
+	return 12
+}
+
+func writeThriftValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case int32:
+		b.Write(binary.AppendVarint(nil, int64(v)))
+	case int64:
+		b.Write(binary.AppendVarint(nil, v))
+	case string:
+		b.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		b.WriteString(v)
+	case []any:
+		b.WriteByte(byte(len(v))<<4 | thriftType(v[0]))
+		for _, item := range v {
+			writeThriftValue(b, item)
+		}
+	case []thriftField:
+		var last int16
+		for _, f := range v {
+			b.WriteByte(byte(f.id-last)<<4 | thriftType(f.v))
+			writeThriftValue(b, f.v)
+			last = f.id
+		}
+		b.WriteByte(0)
+	}
+}
+
+func thrift(fields ...thriftField) []byte {
+	var b bytes.Buffer
+	writeThriftValue(&b, fields)
+	return b.Bytes()
+}
+
+// snappyLiteral compresses data with Snappy, as a single literal
+func snappyLiteral(data []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(data)))
+	out = append(out, 60<<2, byte(len(data)-1))
+	return append(out, data...)
+}
+
+var parquetTestTimes = []time.Time{
+	time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	time.Date(2024, 5, 2, 12, 0, 0, 1000, time.UTC),
+	time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC),
+}
+
+// parquetTestFile returns a Parquet file of three rows, the second of which has no name
+func parquetTestFile() []byte {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	// Name is optional, and dictionary encoded, in pages compressed with Snappy
+	plainString := func(values ...string) []byte {
+		var b []byte
+		for _, v := range values {
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(v)))
+			b = append(b, v...)
+		}
+		return b
+	}
+	nameStart := int64(file.Len())
+	dictionary := snappyLiteral(plainString("c/a", "c/b"))
+	file.Write(thrift(thriftField{1, int32(parquetDictionaryPage)}, thriftField{2, int32(14)}, thriftField{3, int32(len(dictionary))},
+		thriftField{7, []thriftField{{1, int32(2)}, {2, int32(parquetPlain)}}}))
+	file.Write(dictionary)
+	nameData := int64(file.Len())
+	// definition levels 1, 0, 1, bit packed, then dictionary indices 1 and 0, one bit wide
+	data := snappyLiteral([]byte{2, 0, 0, 0, 3, 0b101, 1, 3, 0b01})
+	file.Write(thrift(thriftField{1, int32(parquetDataPage)}, thriftField{2, int32(9)}, thriftField{3, int32(len(data))},
+		thriftField{5, []thriftField{{1, int32(3)}, {2, int32(parquetRLEDictionary)}, {3, int32(parquetRLE)}, {4, int32(parquetRLE)}}}))
+	file.Write(data)
+	nameLength := int64(file.Len()) - nameStart
+
+	// Last-Modified is required, and a timestamp in microseconds, in a second version data page
+	modifiedStart := int64(file.Len())
+	var values []byte
+	for _, v := range parquetTestTimes {
+		values = binary.LittleEndian.AppendUint64(values, uint64(v.UnixMicro()))
+	}
+	file.Write(thrift(thriftField{1, int32(parquetDataPageV2)}, thriftField{2, int32(len(values))}, thriftField{3, int32(len(values))},
+		thriftField{8, []thriftField{{1, int32(3)}, {2, int32(0)}, {3, int32(3)}, {4, int32(parquetPlain)}, {5, int32(0)}, {6, int32(0)}, {7, false}}}))
+	file.Write(values)
+	modifiedLength := int64(file.Len()) - modifiedStart
+
+	chunk := func(typ int32, codec int32, dictionaryOffset, dataOffset, length int64) []thriftField {
+		meta := []thriftField{{1, typ}, {4, codec}, {5, int64(3)}, {7, length}, {9, dataOffset}}
+		if dictionaryOffset != 0 {
+			meta = append(meta, thriftField{11, dictionaryOffset})
+		}
+		return []thriftField{{2, dataOffset}, {3, meta}}
+	}
+	footer := thrift(
+		thriftField{1, int32(1)},
+		thriftField{2, []any{
+			[]thriftField{{4, "schema"}, {5, int32(3)}},
+			[]thriftField{{1, int32(parquetByteArray)}, {3, int32(1)}, {4, "Name"}},
+			// a group, which isn't read, between the columns that are
+			[]thriftField{{3, int32(1)}, {4, "Tags"}, {5, int32(1)}},
+			[]thriftField{{1, int32(parquetByteArray)}, {3, int32(1)}, {4, "key"}},
+			[]thriftField{{1, int32(parquetInt64)}, {3, int32(0)}, {4, "Last-Modified"},
+				{10, []thriftField{{8, []thriftField{{1, true}, {2, []thriftField{{2, []thriftField{}}}}}}}}},
+		}},
+		thriftField{3, int64(3)},
+		thriftField{4, []any{[]thriftField{
+			{1, []any{
+				chunk(parquetByteArray, parquetSnappy, nameStart, nameData, nameLength),
+				chunk(parquetByteArray, parquetUncompressed, 0, 4, 0),
+				chunk(parquetInt64, parquetUncompressed, 0, modifiedStart, modifiedLength),
+			}},
+			{3, int64(3)},
+		}}},
+	)
+	file.Write(footer)
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	file.WriteString("PAR1")
+	return file.Bytes()
+}
+
+func readAllParquet(file []byte) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := readParquet(bytes.NewReader(file), int64(len(file)), func(row map[string]any) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func TestReadParquet(t *testing.T) {
+	a := assert.New(t)
+	rows, err := readAllParquet(parquetTestFile())
+	a.NoError(err)
+	a.Equal([]map[string]any{
+		{"Name": "c/b", "Last-Modified": parquetTestTimes[0]},
+		{"Last-Modified": parquetTestTimes[1]},
+		{"Name": "c/a", "Last-Modified": parquetTestTimes[2]},
+	}, rows)
+
+	_, err = readAllParquet([]byte("not a parquet file"))
+	a.Error(err)
+}
+
+func TestReadParquetDamaged(t *testing.T) {
+	a := assert.New(t)
+	file := parquetTestFile()
+
+	// the metadata says it's longer than the file
+	damaged := bytes.Clone(file)
+	binary.LittleEndian.PutUint32(damaged[len(damaged)-8:], 0xffffffff)
+	_, err := readAllParquet(damaged)
+	a.Error(err)
+
+	// cut short, with the metadata moved to the end
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-footerLength:]
+	for _, n := range []int{4, 20, 60} {
+		_, err = readAllParquet(append(bytes.Clone(file[:n]), footer...))
+		a.Error(err, "cut to %d bytes", n)
+	}
+
+	// each byte set to each of a few values, of which those that are lengths and counts are much bigger than the file
+	for i := 4; i < len(file)-8; i++ {
+		for _, b := range []byte{0, 0x7f, 0x80, 0xff} {
+			damaged = bytes.Clone(file)
+			damaged[i] = b
+			_, _ = readAllParquet(damaged)
+		}
+	}
+
+	// a struct nested in itself, over and over
+	nested := bytes.Repeat([]byte{0x1c}, 100_000)
+	damaged = append([]byte("PAR1"), nested...)
+	damaged = binary.LittleEndian.AppendUint32(damaged, uint32(len(nested)))
+	_, err = readAllParquet(append(damaged, "PAR1"...))
+	a.Error(err)
+}
+
+func FuzzReadParquet(f *testing.F) {
+	f.Add(parquetTestFile())
+	f.Fuzz(func(t *testing.T, file []byte) {
+		_, _ = readAllParquet(file)
+	})
+}
+
+func TestParquetDecompress(t *testing.T) {
+	a := assert.New(t)
+	page := bytes.Repeat([]byte("abcd"), 100)
+	out, err := parquetDecompress(parquetSnappy, snappy.Encode(nil, page), len(page))
+	a.NoError(err)
+	a.Equal(page, out)
+
+	_, err = parquetDecompress(parquetSnappy, snappy.Encode(nil, page), len(page)+1)
+	a.Error(err, "a different length than the page header says")
+	_, err = parquetDecompress(parquetSnappy, []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0}, 1<<32-1)
+	a.Error(err, "longer than a block of its length can be")
+	_, err = parquetDecompress(parquetSnappy, []byte{9, 3 << 2, 'a', 'b', 'c', 'd'}, 9)
+	a.Error(err, "shorter than it says")
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write(page)
+	a.NoError(w.Close())
+	out, err = parquetDecompress(parquetGzip, gz.Bytes(), len(page))
+	a.NoError(err)
+	a.Equal(page, out)
+	_, err = parquetDecompress(parquetGzip, gz.Bytes(), 10)
+	a.Error(err, "longer than the page header says")
+}
+
+func TestDecodeRLEHybrid(t *testing.T) {
+	a := assert.New(t)
+	// a run of five 3s, two bytes wide, then a bit packed group of 0 to 7, three bits wide
+	values, err := decodeRLEHybrid([]byte{5 << 1, 3, 0}, 9, 5)
+	a.NoError(err)
+	a.Equal([]int{3, 3, 3, 3, 3}, values)
+
+	values, err = decodeRLEHybrid([]byte{1<<1 | 1, 0b10001000, 0b11000110, 0b11111010}, 3, 6)
+	a.NoError(err)
+	a.Equal([]int{0, 1, 2, 3, 4, 5}, values)
+
+	// more groups than there are bytes, including so many that their size overflows
+	_, err = decodeRLEHybrid([]byte{9<<1 | 1, 0, 0, 0}, 3, 6)
+	a.Error(err)
+	_, err = decodeRLEHybrid(binary.AppendUvarint(nil, 1<<62|1), 4, 6)
+	a.Error(err)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanCheckpointCarriesOnADirectoryWalk(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	jobID := common.NewJobID()
+	command := copyCommand{Args: []string{"--recursive=true"}, Source: common.ResourceString{Value: "/data"}}
+
+	// the source has a and b in it, and a has x in it. The walk is interrupted after b has been read, but before
+	// anything found in b has been sent.
+	first := newScanCheckpoint(jobID, command)
+	first.claim()
+	first.dirDone("", 2)
+	first.dirDone("a", 1)
+	first.dirDone("a/x", 0)
+	first.commit(3)
+	first.dirDone("b", 0)
+
+	state, err := readScanCheckpoint(jobID)
+	a.NoError(err)
+	a.NotNil(state)
+	a.Equal(command, state.copyCommand)
+	a.Equal(common.PartNumber(3), state.Parts)
+	a.Equal([]string{"a"}, state.Completed, "a is recorded in place of a/x, now that everything in it has been found")
+	a.Equal([]string{""}, state.Listed)
+
+	second := newScanCheckpoint(jobID, command)
+	parts := second.continueFrom(*state, func(fromPart common.PartNumber, add func(string)) common.PartNumber {
+		a.Equal(common.PartNumber(0), fromPart)
+		for _, source := range []string{"", "/f", "/a", "/a/x", "/a/x/g", "/b", "/b/h"} {
+			add(source)
+		}
+		return 3
+	})
+	a.Equal(common.PartNumber(3), parts)
+
+	a.True(second.skips(""))
+	a.True(second.skips("f"))
+	a.True(second.skips("b"), "b itself was found in the source, which had been read")
+	a.False(second.skips("b/h"))
+	a.False(second.descend("a"))
+	a.True(second.descend("b"))
+
+	a.True(second.alreadyOrdered("/b/h"))
+	a.False(second.alreadyOrdered("/b/h"), "it's only found once")
+	a.False(second.alreadyOrdered("/b/i"))
+	a.False(second.alreadyOrdered("/a/x/g"), "what's in a isn't found again, so it isn't held on to")
+
+	second.claim()
+	second.dirDone("", 1)
+	second.dirDone("b", 0)
+	a.Equal(map[string]bool{"": true}, second.completed)
+	a.Empty(second.listed)
+	a.Empty(second.dirs)
+
+	second.finish()
+	state, err = readScanCheckpoint(jobID)
+	a.NoError(err)
+	a.Nil(state)
+}
+
+func TestScanCheckpointCarriesOnAListing(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	jobID := common.NewJobID()
+	markers := func(m string) *string { return &m }
+
+	first := newScanCheckpoint(jobID, copyCommand{})
+	marker, err := first.marker("container prefix/")
+	a.NoError(err)
+	a.Nil(marker)
+	a.NoError(first.save("container prefix/", markers("m1")))
+	first.commit(1)
+	first.commit(2)
+	a.NoError(first.save("container prefix/", markers("m2")))
+	first.commit(3)
+	a.NoError(first.save("container prefix/", nil))
+
+	state, err := readScanCheckpoint(jobID)
+	a.NoError(err)
+	a.Equal("m2", state.Marker)
+	a.Equal(common.PartNumber(2), state.RescanFrom, "what's listed after m2 may be in the third part")
+
+	second := newScanCheckpoint(jobID, copyCommand{})
+	second.continueFrom(*state, func(fromPart common.PartNumber, add func(string)) common.PartNumber {
+		a.Equal(common.PartNumber(2), fromPart)
+		add("/blob")
+		return 3
+	})
+	marker, err = second.marker("container prefix/")
+	a.NoError(err)
+	a.Equal("m2", *marker)
+	a.True(second.alreadyOrdered("/blob"))
+
+	_, err = second.marker("container other/")
+	a.Error(err)
+}
+
+func TestScanCheckpointIsOnlyWrittenIfTheScanUsesIt(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	jobID := common.NewJobID()
+
+	cp := newScanCheckpoint(jobID, copyCommand{})
+	cp.commit(1)
+	state, err := readScanCheckpoint(jobID)
+	a.NoError(err)
+	a.Nil(state)
+}
+
+func TestCopyCommandLine(t *testing.T) {
+	a := assert.New(t)
+	command := copyCommand{
+		Args:        []string{"--recursive=true"},
+		Source:      common.ResourceString{Value: "/data"},
+		Destination: common.ResourceString{Value: "https://account.blob.core.windows.net/container", ExtraQuery: "a=b"},
+	}
+
+	args, err := command.commandLine("", "?sig=secret")
+	a.NoError(err)
+	a.Equal([]string{"copy", "/data", "https://account.blob.core.windows.net/container?sig=secret&a=b", "--recursive=true"}, args)
+
+	args, err = command.commandLine("", "")
+	a.NoError(err)
+	a.Equal("https://account.blob.core.windows.net/container?a=b", args[2])
+}
+
+func TestRecordCopyCommandLeavesOutHooksAndNotifications(t *testing.T) {
+	a := assert.New(t)
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("recursive", false, "")
+	for _, name := range []string{PreHookFlag, PostHookFlag, NotifyWebhookFlag, NotifyEmailFlag} {
+		cmd.Flags().String(name, "", "")
+	}
+	a.NoError(cmd.Flags().Parse([]string{"--recursive", "--pre-hook=zfs snapshot tank@pre", "--post-hook=true",
+		"--notify-webhook=https://hooks.slack.com/services/T0/B0/secret", "--notify-email=ops@example.com"}))
+
+	command := recordCopyCommand(cmd, "/data", "https://account.blob.core.windows.net/container", common.EFromTo.LocalBlob())
+	a.Equal([]string{"--recursive=true"}, command.Args)
+}
+
+func TestScanCheckpointIsOnlyReadableByItsOwner(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	cp := newScanCheckpoint(common.NewJobID(), copyCommand{})
+	cp.claim()
+	cp.commit(1)
+
+	info, err := os.Stat(cp.path)
+	a.NoError(err)
+	if os.PathSeparator == '/' {
+		a.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+}
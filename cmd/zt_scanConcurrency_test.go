@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestScanConcurrencyReachesTraversers(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	raw := getDefaultCopyRawInput(dir, "https://account.blob.core.windows.net/container")
+	raw.scanConcurrency = 7
+	cooked, err := raw.cook()
+	a.NoError(err)
+	a.EqualValues(7, cooked.scanConcurrency)
+
+	anonymous := &common.CredentialInfo{CredentialType: common.ECredentialType.Anonymous()}
+	for _, c := range []struct {
+		resource string
+		location common.Location
+	}{
+		{dir, common.ELocation.Local()},
+		{"https://account.blob.core.windows.net/container/dir/", common.ELocation.Blob()},
+		{"https://account.file.core.windows.net/share/dir/", common.ELocation.File()},
+	} {
+		resource, err := SplitResourceString(c.resource, c.location)
+		a.NoError(err, c.resource)
+		for scanConcurrency, expected := range map[int]int{7: 7, 0: EnumerationParallelism} {
+			traverser, err := InitResourceTraverser(resource, c.location, context.Background(), InitResourceTraverserOptions{
+				Credential:      anonymous,
+				Recursive:       true,
+				ScanConcurrency: scanConcurrency,
+			})
+			if !a.NoError(err, c.resource) {
+				continue
+			}
+			switch traverser := traverser.(type) {
+			case *localTraverser:
+				a.Equal(expected, traverser.parallelism, c.resource)
+			case *blobTraverser:
+				a.Equal(expected, traverser.parallelism, c.resource)
+			case *fileTraverser:
+				a.Equal(expected, traverser.parallelism, c.resource)
+			default:
+				a.Fail("unexpected traverser", "%T for %s", traverser, c.resource)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanProgress(t *testing.T) {
+	a := assert.New(t)
+	p := newScanProgress()
+	start := p.lastTime
+
+	processed := 0
+	processor := p.tracking(func(object StoredObject) error {
+		processed++
+		return nil
+	})
+	for i := 0; i < 10; i++ {
+		p.found(common.EEntityType.File())
+	}
+	p.found(common.EEntityType.Folder())
+	a.NoError(processor(StoredObject{relativePath: "photos/2019"}))
+	a.NoError(processor(StoredObject{relativePath: "photos/2019/img.jpg"}))
+	a.Equal(2, processed)
+
+	r := p.report(start.Add(2 * time.Second))
+	a.Equal(common.ScanProgress{FilesFound: 10, FoldersFound: 1, FilesPerSecond: 5, FoldersPerSecond: 0.5, CurrentPath: "photos/2019/img.jpg"}, r)
+	a.Equal("10 files and 1 folders found, 5 files/s, at photos/2019/img.jpg", describeScanProgress(r))
+
+	// the rates are of what's been found since the last report
+	p.found(common.EEntityType.File())
+	r = p.report(start.Add(3 * time.Second))
+	a.Equal(uint64(11), r.FilesFound)
+	a.Equal(float64(1), r.FilesPerSecond)
+	a.Equal(float64(0), r.FoldersPerSecond)
+}
+
+func TestScanProgressShortensLongPaths(t *testing.T) {
+	a := assert.New(t)
+	r := common.ScanProgress{CurrentPath: strings.Repeat("d/", 50) + "file"}
+	s := describeScanRate(r)
+	a.True(strings.HasSuffix(s, "d/d/file"))
+	a.Len([]rune(s[strings.Index(s, "at ")+3:]), scanProgressMaxPathLength)
+	a.Contains(s, "at ...")
+}
+
+func TestScanProgressIsOptional(t *testing.T) {
+	var p *scanProgress
+	p.found(common.EEntityType.File())
+	p.stopReporting()
+	called := false
+	a := assert.New(t)
+	a.NoError(p.tracking(func(StoredObject) error { called = true; return nil })(StoredObject{}))
+	a.True(called)
+}
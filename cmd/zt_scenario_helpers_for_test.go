@@ -52,6 +52,7 @@ import (
 	"github.com/minio/minio-go"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/Azure/azure-storage-azcopy/v10/ste"
 )
 
 const defaultFileSize = 1024
@@ -970,6 +971,13 @@ func getDefaultSyncRawInput(sra, dst string) rawSyncCmdArgs {
 		md5ValidationOption:  common.DefaultHashValidationOption.String(),
 		compareHash:          common.ESyncHashType.None().String(),
 		localHashStorageMode: common.EHashStorageMode.Default().String(),
+		hardlinks:            common.DefaultHardlinkHandlingType.String(),
+		specialFiles:         "warn",
+		order:                "as-scanned",
+		traversal:            "auto",
+		syncStateCache:       "none",
+		fsync:                "off",
+		transferReportFormat: ste.TransferReportFormatCSV,
 	}
 }
 
@@ -984,6 +992,13 @@ func getDefaultCopyRawInput(src string, dst string) rawCopyCmdArgs {
 		s2sInvalidMetadataHandleOption: defaultS2SInvalideMetadataHandleOption.String(),
 		forceWrite:                     common.EOverwriteOption.True().String(),
 		preserveOwner:                  common.PreserveOwnerDefault,
+		hardlinks:                      common.DefaultHardlinkHandlingType.String(),
+		specialFiles:                   "warn",
+		order:                          "as-scanned",
+		traversal:                      "auto",
+		fsync:                          "off",
+		bypassCache:                    common.CacheBypassNone,
+		transferReportFormat:           ste.TransferReportFormatCSV,
 		asSubdir:                       true,
 	}
 }
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncStateCacheRecordsWhatTheSyncLeaves(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	cca := &cookedSyncCmdArgs{
+		fromTo:         common.EFromTo.LocalBlob(),
+		source:         common.ResourceString{Value: "/data"},
+		destination:    common.ResourceString{Value: "https://account.blob.core.windows.net/container", SAS: "sig=a"},
+		recursive:      true,
+		syncStateCache: common.ESyncStateCache.Use(),
+	}
+	lmt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	file := func(relativePath string) StoredObject {
+		return StoredObject{relativePath: relativePath, entityType: common.EEntityType.File(), lastModifiedTime: lmt, size: 1}
+	}
+	noop := func(StoredObject) error { return nil }
+
+	first, err := newSyncStateCache(cca)
+	a.NoError(err)
+	a.Nil(first.loaded, "the first sync has no record to use")
+
+	// the destination has old, gone and stuck, and gone and stuck aren't in the source, but stuck can't be deleted
+	compare := first.recordingDestination(noop)
+	deleter := first.recordingDeletions(func(obj StoredObject) error {
+		if obj.relativePath == "stuck" {
+			return first.keep(obj)
+		}
+		return nil
+	})
+	for _, path := range []string{"old", "gone", "stuck"} {
+		a.NoError(compare(file(path)))
+	}
+	a.NoError(deleter(file("gone")))
+	a.NoError(deleter(file("stuck")))
+	a.NoError(first.recordingTransfers(noop)(file("new")))
+	first.save(true)
+
+	cca.destination.SAS = "sig=b"
+	second, err := newSyncStateCache(cca)
+	a.NoError(err)
+	recorded := map[string]syncStateEntry{}
+	for _, e := range second.loaded {
+		recorded[e.Path] = e
+	}
+	a.Len(recorded, 3)
+	a.True(recorded["old"].LastModified.Equal(lmt))
+	a.True(recorded["stuck"].LastModified.Equal(lmt))
+	a.True(recorded["new"].LastModified.Equal(first.started), "what's uploaded is newer than when the scan began")
+	a.Equal(uint8(common.EEntityType.File()), recorded["new"].EntityType)
+
+	_, err = os.Stat(second.path)
+	a.True(os.IsNotExist(err), "the record is taken out while the sync runs")
+	second.save(false)
+	_, err = os.Stat(second.path)
+	a.True(os.IsNotExist(err), "a sync that fails leaves no record")
+}
+
+func TestSyncStateCacheIsOnlyUsedWithTheSameOptions(t *testing.T) {
+	a := assert.New(t)
+	common.AzcopyJobPlanFolder = t.TempDir()
+	cca := &cookedSyncCmdArgs{
+		fromTo:         common.EFromTo.LocalBlob(),
+		source:         common.ResourceString{Value: "/data"},
+		destination:    common.ResourceString{Value: "https://account.blob.core.windows.net/container"},
+		syncStateCache: common.ESyncStateCache.Refresh(),
+	}
+
+	first, err := newSyncStateCache(cca)
+	a.NoError(err)
+	first.save(true)
+
+	cca.syncStateCache = common.ESyncStateCache.Use()
+	cca.excludePatterns = []string{"*.tmp"}
+	second, err := newSyncStateCache(cca)
+	a.NoError(err)
+	a.Nil(second.loaded, "what the filters left out before isn't in the record")
+	second.save(true)
+
+	third, err := newSyncStateCache(cca)
+	a.NoError(err)
+	a.NotNil(third.loaded)
+	a.Empty(third.loaded)
+
+	cca.syncStateCache = common.ESyncStateCache.Refresh()
+	fourth, err := newSyncStateCache(cca)
+	a.NoError(err)
+	a.Nil(fourth.loaded, "the destination is scanned again")
+}
@@ -0,0 +1,49 @@
+//go:build freebsd
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncTreeWatcherGivesUpWhenRootIsRemoved(t *testing.T) {
+	a := assert.New(t)
+	root := filepath.Join(t.TempDir(), "watched")
+	a.NoError(os.Mkdir(root, 0700))
+
+	tree, err := newSyncTreeWatcher(root, true)
+	a.NoError(err)
+	defer tree.Close()
+
+	a.NoError(os.Rename(root, root+".old"))
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-tree.Events():
+			if ok {
+				continue
+			}
+			// otherwise sync --watch would exit as if it had been asked to stop
+			a.Error(tree.Err())
+			return
+		case <-timeout:
+			a.FailNow("the watcher didn't stop when its root was renamed")
+		}
+	}
+}
+
+func TestSyncTreeWatcherClosedWithoutError(t *testing.T) {
+	a := assert.New(t)
+	tree, err := newSyncTreeWatcher(t.TempDir(), true)
+	a.NoError(err)
+	a.NoError(tree.Close())
+
+	for range tree.Events() {
+	}
+	a.NoError(tree.Err())
+}
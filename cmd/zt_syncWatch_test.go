@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesceSyncWatchEvents(t *testing.T) {
+	a := assert.New(t)
+
+	pending := map[string]bool{
+		"a":                          true,
+		filepath.Join("a", "b"):      false,
+		filepath.Join("a", "b", "c"): true,
+		"d":                          false,
+		filepath.Join("d", "e"):      false,
+	}
+
+	a.Equal([]syncWatchEvent{
+		{relDir: "a", recursive: true},
+		{relDir: "d", recursive: false},
+		{relDir: filepath.Join("d", "e"), recursive: false},
+	}, coalesceSyncWatchEvents(pending))
+
+	// a non-recursive root doesn't cover anything below it
+	a.Len(coalesceSyncWatchEvents(map[string]bool{"": false, "x": false}), 2)
+}
+
+func TestNewWatchRoundTargetsSubdirectory(t *testing.T) {
+	a := assert.New(t)
+
+	cca := &cookedSyncCmdArgs{
+		source:      common.ResourceString{Value: filepath.Join("root", "dir")},
+		destination: common.ResourceString{Value: "https://acct.blob.core.windows.net/container/prefix/", SAS: "sv=x"},
+		recursive:   true,
+	}
+	cca.atomicSourceFilesScanned = 10
+
+	round := cca.newWatchRound(syncWatchEvent{relDir: filepath.Join("sub", "with space"), recursive: false})
+	a.Equal(filepath.Join("root", "dir", "sub", "with space"), round.source.Value)
+	a.Equal("https://acct.blob.core.windows.net/container/prefix/sub/with%20space", round.destination.Value)
+	a.Equal("sv=x", round.destination.SAS)
+	a.False(round.recursive)
+	a.Zero(round.atomicSourceFilesScanned)
+	a.NotEqual(cca.jobID, round.jobID)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferOrderer(t *testing.T) {
+	a := assert.New(t)
+	scanned := []common.CopyTransfer{
+		{Source: "/b", SourceSize: 100}, {Source: "/a", SourceSize: 5}, {Source: "/dir", SourceSize: 0},
+		{Source: "/c", SourceSize: 100}, {Source: "/d", SourceSize: 1 << 40},
+	}
+	released := func(order string) []string {
+		var to common.TransferOrder
+		a.NoError(to.Parse(order))
+		o := newTransferOrderer(to)
+		var sent []string
+		for _, transfer := range scanned {
+			if !o.hold(transfer) {
+				sent = append(sent, transfer.Source)
+			}
+		}
+		a.NoError(o.release(func(transfer common.CopyTransfer) error {
+			sent = append(sent, transfer.Source)
+			return nil
+		}))
+		return sent
+	}
+
+	a.Equal([]string{"/b", "/a", "/dir", "/c", "/d"}, released("as-scanned"))
+	a.Equal([]string{"/dir", "/a", "/b", "/c", "/d"}, released("smallest-first"), "files of the same size stay in the order they were found")
+	a.Equal([]string{"/d", "/b", "/c", "/a", "/dir"}, released("LargestFirst"))
+
+	var to common.TransferOrder
+	a.Error(to.Parse("random"))
+}
+
+func TestTransferOrdererStopsAtAnError(t *testing.T) {
+	a := assert.New(t)
+	o := newTransferOrderer(common.ETransferOrder.SmallestFirst())
+	o.hold(common.CopyTransfer{Source: "/a", SourceSize: 1})
+	o.hold(common.CopyTransfer{Source: "/b", SourceSize: 2})
+
+	sent := 0
+	err := o.release(func(transfer common.CopyTransfer) error {
+		sent++
+		return errors.New("the part couldn't be sent")
+	})
+	a.Error(err)
+	a.Equal(1, sent)
+}
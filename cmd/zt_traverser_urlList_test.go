@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestParseURLListLine(t *testing.T) {
+	a := assert.New(t)
+
+	for _, tc := range []struct {
+		line, url, name string
+		bad             bool
+	}{
+		{line: "", url: "", name: ""},
+		{line: "   ", url: "", name: ""},
+		{line: "# a comment", url: "", name: ""},
+		{line: "https://data.example.org/pub/2024/a.csv?X-Amz-Signature=abc", url: "https://data.example.org/pub/2024/a.csv?X-Amz-Signature=abc", name: "pub/2024/a.csv"},
+		{line: "  http://data.example.org/b%20c.txt  ", url: "http://data.example.org/b%20c.txt", name: "b c.txt"},
+		{line: "https://data.example.org/download?id=7\tdatasets/seven.parquet", url: "https://data.example.org/download?id=7", name: "datasets/seven.parquet"},
+		{line: "https://data.example.org/a/../../etc/passwd", url: "https://data.example.org/a/../../etc/passwd", name: "etc/passwd"},
+		{line: "https://data.example.org/dir/", bad: true},
+		{line: "https://data.example.org", bad: true},
+		{line: "ftp://data.example.org/a.csv", bad: true},
+		{line: "/local/path/a.csv", bad: true},
+	} {
+		rawURL, name, err := parseURLListLine(tc.line)
+		if tc.bad {
+			a.Error(err, tc.line)
+			continue
+		}
+		a.NoError(err, tc.line)
+		a.Equal(tc.url, rawURL, tc.line)
+		a.Equal(tc.name, name, tc.line)
+	}
+
+	// signatures stay out of errors
+	_, _, err := parseURLListLine("https://data.example.org/dir/?sig=secret")
+	a.Error(err)
+	a.NotContains(err.Error(), "secret")
+}
+
+func TestURLListTraverser(t *testing.T) {
+	a := assert.New(t)
+
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"/pub/a.csv":     "1,2,3\n4,5,6\n",
+		"/pub/empty.txt": "",
+		"/download":      "by id",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok || r.Method != http.MethodGet { // presigned URLs are only signed for GET
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		http.ServeContent(w, r, "", modTime, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	list := filepath.Join(t.TempDir(), "urls.txt")
+	a.NoError(os.WriteFile(list, []byte(strings.Join([]string{
+		"# the dataset",
+		server.URL + "/pub/a.csv?X-Amz-Signature=abc",
+		server.URL + "/pub/empty.txt",
+		"",
+		server.URL + "/download?id=7\tby-id.txt",
+		server.URL + "/pub/missing.csv",
+	}, "\n")), 0644))
+
+	traverser, err := newURLListTraverser(list, context.Background(), InitResourceTraverserOptions{})
+	a.NoError(err)
+	isDir, err := traverser.IsDirectory(true)
+	a.NoError(err)
+	a.True(isDir)
+
+	found := map[string]StoredObject{}
+	err = traverser.Traverse(noPreProccessor, func(obj StoredObject) error {
+		found[obj.relativePath] = obj
+		return nil
+	}, nil)
+	a.NoError(err)
+
+	// the missing one is skipped
+	a.Len(found, 3)
+	csv := found["pub/a.csv"]
+	a.Equal("a.csv", csv.name)
+	a.Equal(int64(12), csv.size)
+	a.Equal("text/csv", csv.contentType)
+	a.True(modTime.Equal(csv.lastModifiedTime))
+	a.Equal(server.URL+"/pub/a.csv?X-Amz-Signature=abc", csv.sourceURL)
+
+	a.Equal(int64(0), found["pub/empty.txt"].size)
+	a.Equal(int64(5), found["by-id.txt"].size)
+	a.Equal(server.URL+"/download?id=7", found["by-id.txt"].sourceURL)
+
+	// objects from a URL list carry their own source
+	cca := CookedCopyCmdArgs{FromTo: common.EFromTo.URLListBlob(), StripTopDir: true}
+	a.Equal(csv.sourceURL, cca.MakeEscapedRelativePath(true, true, true, csv))
+	a.Equal("/pub/a.csv", cca.MakeEscapedRelativePath(false, true, true, csv))
+}
+
+func TestURLListTraverserRefusesClashingNames(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("data"))
+	}))
+	defer server.Close()
+
+	list := filepath.Join(t.TempDir(), "urls.txt")
+	a.NoError(os.WriteFile(list, []byte(server.URL+"/a/data.bin\n"+server.URL+"/b/data.bin\tdata\n"+server.URL+"/data\n"), 0644))
+
+	traverser, err := newURLListTraverser(list, context.Background(), InitResourceTraverserOptions{})
+	a.NoError(err)
+	err = traverser.Traverse(noPreProccessor, func(StoredObject) error { return nil }, nil)
+	a.Error(err)
+	a.Contains(err.Error(), "lines 2 and 3")
+}
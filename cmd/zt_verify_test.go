@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestVerifier(hashType common.ContentHashType, validation common.HashValidationOption, files map[string][]byte) *verifier {
+	v := newVerifier(cookedVerifyCmdArgs{
+		fromTo:         common.EFromTo.LocalBlob(),
+		source:         common.ResourceString{Value: "/src"},
+		hashType:       hashType,
+		hashValidation: validation,
+	})
+	v.openLocal = func(path string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(files[path])), nil
+	}
+	return v
+}
+
+func TestVerifyComparesSizesAndHashes(t *testing.T) {
+	a := assert.New(t)
+	data := []byte("some data")
+	sum := md5.Sum(data)
+	v := newTestVerifier(common.EContentHashType.MD5(), common.EHashValidationOption.FailIfDifferent(), map[string][]byte{"/src/a": data})
+
+	local := v.content(common.ELocation.Local(), v.cooked.source, StoredObject{relativePath: "a", size: int64(len(data))})
+	a.Equal("/src/a", local.localPath)
+
+	remote := v.content(common.ELocation.Blob(), common.ResourceString{}, StoredObject{relativePath: "a", size: int64(len(data)), md5: sum[:]})
+	a.Equal(verifyMatched, v.compare("a", local, remote).Status)
+
+	other := md5.Sum([]byte("other data"))
+	remote.hash = other[:]
+	entry := v.compare("a", local, remote)
+	a.Equal(verifyDifferent, entry.Status)
+	a.True(entry.hashesDiffer)
+
+	remote.size++
+	entry = v.compare("a", local, remote)
+	a.Equal(verifyDifferent, entry.Status)
+	a.False(entry.hashesDiffer)
+
+	// a file without a hash isn't read, since there's nothing to compare it with
+	v.openLocal = nil
+	a.Equal(verifyHashMissing, v.compare("a", local, verifiableContent{size: int64(len(data))}).Status)
+}
+
+func TestVerifyReadsHashesFromMetadata(t *testing.T) {
+	a := assert.New(t)
+	data := []byte("some data")
+	sum := sha256.Sum256(data)
+	v := newTestVerifier(common.EContentHashType.SHA256(), common.EHashValidationOption.FailIfDifferent(), map[string][]byte{"/src/a": data})
+	local := v.content(common.ELocation.Local(), v.cooked.source, StoredObject{relativePath: "a", size: int64(len(data))})
+
+	remote := v.content(common.ELocation.Blob(), common.ResourceString{}, StoredObject{size: int64(len(data)),
+		Metadata: common.Metadata{common.ContentSHA256Meta: to.Ptr(base64.StdEncoding.EncodeToString(sum[:]))}})
+	a.Equal(verifyMatched, v.compare("a", local, remote).Status)
+
+	// a blob compressed on upload is compared by what it decompresses to, whose hash is only recorded in MD5
+	compressed := v.content(common.ELocation.Blob(), common.ResourceString{}, StoredObject{size: 5,
+		Metadata: common.Metadata{common.UncompressedSizeMeta: to.Ptr(strconv.Itoa(len(data)))}})
+	a.Equal(int64(len(data)), compressed.size)
+	a.Equal(verifyHashMissing, v.compare("a", local, compressed).Status)
+
+	encrypted := v.content(common.ELocation.Blob(), common.ResourceString{}, StoredObject{Metadata: common.Metadata{common.ClientSideEncryptionMeta: to.Ptr("{}")}})
+	a.Equal(verifyUnverifiable, v.compare("a", local, encrypted).Status)
+}
+
+func TestVerifyPassesByHashValidationOption(t *testing.T) {
+	a := assert.New(t)
+	passes := func(validation common.HashValidationOption, entries ...VerifyEntry) bool {
+		v := newTestVerifier(common.EContentHashType.MD5(), validation, nil)
+		for _, e := range entries {
+			v.record(e)
+		}
+		v.finish()
+		return v.report.Passed
+	}
+	matched := VerifyEntry{Path: "b", Status: verifyMatched}
+	hashMissing := VerifyEntry{Path: "c", Status: verifyHashMissing}
+	hashesDiffer := VerifyEntry{Path: "d", Status: verifyDifferent, hashesDiffer: true}
+	missing := VerifyEntry{Path: "e", Status: verifyMissingAtDestination}
+
+	a.True(passes(common.EHashValidationOption.FailIfDifferent(), matched, hashMissing))
+	a.False(passes(common.EHashValidationOption.FailIfDifferentOrMissing(), matched, hashMissing))
+	a.False(passes(common.EHashValidationOption.FailIfDifferent(), matched, hashesDiffer))
+	a.True(passes(common.EHashValidationOption.LogOnly(), matched, hashesDiffer))
+	a.False(passes(common.EHashValidationOption.LogOnly(), missing))
+}
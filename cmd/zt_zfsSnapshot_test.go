@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZFSSnapshotPathMapping(t *testing.T) {
+	a := assert.New(t)
+
+	snap := &zfsSnapshot{dataset: "tank/home", mountpoint: "/home", name: "nightly"}
+
+	p, err := snap.pathFor("/home/alice/docs")
+	a.NoError(err)
+	a.Equal(filepath.Join("/home", ".zfs", "snapshot", "nightly", "alice", "docs"), p)
+
+	p, err = snap.pathFor("/home")
+	a.NoError(err)
+	a.Equal(filepath.Join("/home", ".zfs", "snapshot", "nightly"), p)
+
+	_, err = snap.pathFor("/homes/bob")
+	a.Error(err)
+}
+
+func TestValidateFromZFSSnapshot(t *testing.T) {
+	a := assert.New(t)
+
+	a.NoError(validateFromZFSSnapshot("", common.EFromTo.BlobLocal()))
+	a.Error(validateFromZFSSnapshot("auto", common.EFromTo.BlobLocal()))
+	a.Error(validateFromZFSSnapshot("tank/home@nightly", common.EFromTo.LocalBlob()))
+}
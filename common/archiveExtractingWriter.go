@@ -0,0 +1,191 @@
+package common
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ArchiveExtractOptions say how NewArchiveExtractingWriter treats what it extracts
+type ArchiveExtractOptions struct {
+	Overwrite bool // replace files that already exist, rather than leave them as they are
+	SkipIndex bool // leave out the index of an archive pack
+}
+
+type archiveExtractingWriter struct {
+	pipeWriter  *io.PipeWriter
+	workerError chan error
+}
+
+// NewArchiveExtractingWriter returns a WriteCloser which extracts the archive written to it into dir, as it is written,
+// so that the archive itself is never saved. Only files and folders are extracted. Members whose names would take
+// them outside dir are refused.
+func NewArchiveExtractingWriter(format ArchiveFormat, dir string, options ArchiveExtractOptions) io.WriteCloser {
+	preader, pwriter := io.Pipe()
+
+	t := &archiveExtractingWriter{
+		pipeWriter:  pwriter,
+		workerError: make(chan error, 1),
+	}
+
+	go t.worker(format, dir, options, preader)
+
+	return t
+}
+
+func (t *archiveExtractingWriter) worker(format ArchiveFormat, dir string, options ArchiveExtractOptions, preader *io.PipeReader) {
+	var err error
+	defer func() {
+		_ = preader.CloseWithError(err) // so that writes fail, rather than wait for a worker that has gone
+		t.workerError <- err
+	}()
+
+	b := decompressingWriterBufferPool.RentSlice(decompressingWriterCopyBufferSize)
+	defer decompressingWriterBufferPool.ReturnSlice(b)
+
+	x := archiveExtractor{dir: dir, options: options, buffer: b}
+	switch format {
+	case EArchiveFormat.Tar():
+		err = x.extractTar(preader)
+	case EArchiveFormat.TarGzip():
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(preader); err == nil {
+			err = x.extractTar(gz)
+		}
+	case EArchiveFormat.Zip():
+		// the decompressor reads no further than the end of each file's data, given a ByteReader
+		err = x.extractZip(bufio.NewReader(preader))
+	default:
+		err = fmt.Errorf("archives of type %s can't be extracted", format)
+	}
+	if err != nil {
+		return
+	}
+
+	// read whatever follows the end of the archive, so that it can all be written
+	_, err = io.CopyBuffer(io.Discard, preader, b)
+}
+
+// archiveExtractor extracts the members of an archive, one by one
+type archiveExtractor struct {
+	dir     string
+	options ArchiveExtractOptions
+	buffer  []byte
+}
+
+func (x *archiveExtractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = x.extractFolder(h.Name)
+		case tar.TypeReg:
+			err = x.extractFile(h.Name, h.ModTime, tr)
+		default:
+			// links, devices and the like are left out
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// target is where a member of the archive is extracted to
+func (x *archiveExtractor) target(name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("refusing to extract %q, which is outside the destination folder", name)
+	}
+	return filepath.Join(x.dir, filepath.FromSlash(name)), nil
+}
+
+func (x *archiveExtractor) extractFolder(name string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, os.ModePerm)
+}
+
+// extractFile writes a file from the archive. Its content must be read from r even if it is skipped, in case the
+// archive's reader needs it to be.
+func (x *archiveExtractor) extractFile(name string, modTime time.Time, r io.Reader) error {
+	if x.options.SkipIndex && name == ArchivePackIndexName {
+		_, err := io.CopyBuffer(io.Discard, r, x.buffer)
+		return err
+	}
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | Iff(x.options.Overwrite, os.O_TRUNC, os.O_EXCL)
+	f, err := OSOpenFile(target, flags, DEFAULT_FILE_PERM)
+	if errors.Is(err, os.ErrExist) {
+		_, err = io.CopyBuffer(io.Discard, r, x.buffer)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	_, err = io.CopyBuffer(f, r, x.buffer)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("extracting %s: %w", name, err)
+	}
+	if !modTime.IsZero() {
+		_ = os.Chtimes(target, time.Now(), modTime)
+	}
+	return nil
+}
+
+// Write passes a slice of the archive to the worker, which extracts it
+func (t *archiveExtractingWriter) Write(p []byte) (n int, err error) {
+	n, writeErr := t.pipeWriter.Write(p)
+
+	// report the worker's error in preference to the pipe's, since it says what went wrong
+	select {
+	case workerErr := <-t.workerError:
+		if workerErr == nil {
+			return n, errors.New("archive extraction worker exited early")
+		}
+		t.workerError <- workerErr // for Close to report too
+		return n, errors.New("error extracting archive: " + workerErr.Error())
+	default:
+	}
+
+	return n, writeErr
+}
+
+func (t *archiveExtractingWriter) Close() error {
+	if err := t.pipeWriter.Close(); err != nil {
+		return err
+	}
+
+	select {
+	case workerErr := <-t.workerError:
+		if workerErr == nil {
+			return nil
+		}
+		return errors.New("error extracting archive: " + workerErr.Error())
+	case <-time.After(time.Minute * 15):
+		return errors.New("timed out closing archive extraction worker")
+	}
+}
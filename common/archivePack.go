@@ -0,0 +1,353 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An archive pack is a tar archive of many small files, which is uploaded as a single blob. Each blob costs at least
+// one round trip however small it is, so packing is much faster than uploading millions of tiny files one by one.
+//
+// The archive is never written to disk. Its layout is worked out from the sizes of its members, and each part of it is
+// read from the file it comes from, when it is wanted. The first member is an index, named ArchivePackIndexName, which
+// is a JSON array giving the name, offset and size of every file in the archive, so that a single file can be read
+// from the blob with a ranged GET.
+//
+// A job that uploads packs also uploads a job index, named ArchivePackJobIndexName, beside them. It has a line of JSON,
+// an ArchivePackJobIndexEntry, for every file in every pack, so that any of them can be found without reading the
+// start of each pack in turn.
+
+const ArchivePackIndexName = "azcopy-pack-index.json"
+
+const tarBlockSize = 512
+
+var archivePackNameRegex = regexp.MustCompile(`^azcopy-pack-([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})-([0-9]+)\.tar$`)
+var archivePackJobIndexNameRegex = regexp.MustCompile(`^azcopy-pack-([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})-index\.jsonl$`)
+
+// ArchivePackName is the name of the n'th pack uploaded by a job
+func ArchivePackName(jobID JobID, n uint32) string {
+	return fmt.Sprintf("azcopy-pack-%s-%d.tar", jobID, n)
+}
+
+// ParseArchivePackName returns the job and number of a pack from its name, or false if it isn't the name of one
+func ParseArchivePackName(name string) (jobID JobID, n uint32, ok bool) {
+	m := archivePackNameRegex.FindStringSubmatch(name)
+	if m == nil {
+		return JobID{}, 0, false
+	}
+	jobID, err := ParseJobID(m[1])
+	if err != nil {
+		return JobID{}, 0, false
+	}
+	n64, err := strconv.ParseUint(m[2], 10, 32)
+	if err != nil {
+		return JobID{}, 0, false
+	}
+	return jobID, uint32(n64), true
+}
+
+// ArchivePackJobIndexName is the name of the index of all the packs uploaded by a job
+func ArchivePackJobIndexName(jobID JobID) string {
+	return fmt.Sprintf("azcopy-pack-%s-index.jsonl", jobID)
+}
+
+// ParseArchivePackJobIndexName returns the job whose index this is, or false if it isn't the name of one
+func ParseArchivePackJobIndexName(name string) (jobID JobID, ok bool) {
+	m := archivePackJobIndexNameRegex.FindStringSubmatch(name)
+	if m == nil {
+		return JobID{}, false
+	}
+	jobID, err := ParseJobID(m[1])
+	return jobID, err == nil
+}
+
+// ArchivePackJobIndexEntry is the line of a job index for one file: the pack it's in, and where it is in the pack
+type ArchivePackJobIndexEntry struct {
+	Name   string `json:"name"`
+	Pack   string `json:"pack"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// ArchivePackMember is a file that is stored in a pack
+type ArchivePackMember struct {
+	Source  string    // the file's full local path
+	Name    string    // its path in the archive, with forward slashes
+	Size    int64     // its size when it was scanned
+	ModTime time.Time // and its modification time
+}
+
+func (m ArchivePackMember) header() *tar.Header {
+	return &tar.Header{Typeflag: tar.TypeReg, Name: m.Name, Size: m.Size, Mode: 0644, ModTime: m.ModTime}
+}
+
+// ArchivePack is the layout of a pack
+type ArchivePack struct {
+	Members []ArchivePackMember
+
+	parts     []archivePackPart // the index, then one for each member
+	indexSize int64
+	size      int64
+}
+
+// archivePackPart is where a member's header starts in the archive, and how long it is. The member's content follows
+// the header, padded to a whole number of blocks.
+type archivePackPart struct {
+	offset     int64
+	headerSize int64
+}
+
+// NewArchivePack lays out an archive of the given files, in the order given
+func NewArchivePack(members []ArchivePackMember) (*ArchivePack, error) {
+	p := &ArchivePack{Members: members}
+	return p, p.layOut()
+}
+
+// LoadArchivePack reads the list of files in a pack from a file written by Save
+func LoadArchivePack(path string) (*ArchivePack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var members []ArchivePackMember
+	if err = json.NewDecoder(f).Decode(&members); err != nil {
+		return nil, fmt.Errorf("reading the list of files in the archive pack %s: %w", path, err)
+	}
+	return NewArchivePack(members)
+}
+
+// Save writes the list of files in the pack to a file, so that the pack can be read again if its job is resumed
+func (p *ArchivePack) Save(path string) error {
+	buf, err := json.Marshal(p.Members)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, DEFAULT_FILE_PERM)
+}
+
+// Size is the size of the archive
+func (p *ArchivePack) Size() int64 {
+	return p.size
+}
+
+// MemberOffset is where the content of the i'th member starts in the archive
+func (p *ArchivePack) MemberOffset(i int) int64 {
+	part := p.parts[i+1]
+	return part.offset + part.headerSize
+}
+
+// JobIndexEntries are the lines of the job index for the files in the pack, which is called name
+func (p *ArchivePack) JobIndexEntries(name string) []ArchivePackJobIndexEntry {
+	entries := make([]ArchivePackJobIndexEntry, len(p.Members))
+	for i, m := range p.Members {
+		entries[i] = ArchivePackJobIndexEntry{Name: m.Name, Pack: name, Offset: p.MemberOffset(i), Size: m.Size}
+	}
+	return entries
+}
+
+// ModTime is the latest modification time of the pack's members, which stands as the pack's own
+func (p *ArchivePack) ModTime() time.Time {
+	var latest time.Time
+	for _, m := range p.Members {
+		if m.ModTime.After(latest) {
+			latest = m.ModTime
+		}
+	}
+	return latest
+}
+
+// FreshModTime checks that the pack's members haven't changed since they were scanned, and returns ModTime if so.
+// A change to a member's size would change the layout of the whole archive.
+func (p *ArchivePack) FreshModTime() (time.Time, error) {
+	for _, m := range p.Members {
+		fi, err := OSStat(m.Source)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.Size() != m.Size || !fi.ModTime().Equal(m.ModTime) {
+			return time.Time{}, fmt.Errorf("%s has changed since it was scanned", m.Source)
+		}
+	}
+	return p.ModTime(), nil
+}
+
+// indexEntry is the line of the index for one file. The numbers are padded to a fixed width, so that the size of the
+// index, and with it the offset of every file, can be worked out before the offsets are known.
+func indexEntry(name string, offset, size int64, last bool) []byte {
+	quoted, _ := json.Marshal(name)
+	return []byte(fmt.Sprintf(`{"name":%s,"offset":%20d,"size":%20d}%s`+"\n", quoted, offset, size, Iff(last, "", ",")))
+}
+
+func (p *ArchivePack) indexHeader(size int64) *tar.Header {
+	return &tar.Header{Typeflag: tar.TypeReg, Name: ArchivePackIndexName, Size: size, Mode: 0644, ModTime: p.ModTime()}
+}
+
+// index builds the index, once the archive has been laid out
+func (p *ArchivePack) index() []byte {
+	buf := bytes.NewBufferString("[\n")
+	for i, m := range p.Members {
+		buf.Write(indexEntry(m.Name, p.MemberOffset(i), m.Size, i == len(p.Members)-1))
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes()
+}
+
+// tarHeader returns a header as the tar package writes it. That may be several blocks long, for long or non-ASCII names.
+func tarHeader(h *tar.Header) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tar.NewWriter(&buf).WriteHeader(h); err != nil { // not closed, since that would write the end of the archive
+		return nil, fmt.Errorf("can't pack %s: %w", h.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func padToBlock(size int64) int64 {
+	return (size + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
+
+func (p *ArchivePack) header(part int) (*tar.Header, error) {
+	if part == 0 {
+		return p.indexHeader(p.indexSize), nil
+	}
+	return p.Members[part-1].header(), nil
+}
+
+func (p *ArchivePack) layOut() error {
+	if len(p.Members) == 0 {
+		return errors.New("an archive pack must have at least one file")
+	}
+
+	p.indexSize = int64(len("[\n]\n"))
+	for i, m := range p.Members {
+		p.indexSize += int64(len(indexEntry(m.Name, 0, 0, i == len(p.Members)-1)))
+	}
+
+	p.parts = make([]archivePackPart, 0, len(p.Members)+1)
+	offset := int64(0)
+	add := func(h *tar.Header) error {
+		header, err := tarHeader(h)
+		if err != nil {
+			return err
+		}
+		p.parts = append(p.parts, archivePackPart{offset: offset, headerSize: int64(len(header))})
+		offset += int64(len(header)) + padToBlock(h.Size)
+		return nil
+	}
+
+	if err := add(p.indexHeader(p.indexSize)); err != nil {
+		return err
+	}
+	for _, m := range p.Members {
+		if err := add(m.header()); err != nil {
+			return err
+		}
+	}
+	p.size = offset + 2*tarBlockSize // the end of the archive is marked by two empty blocks
+	return nil
+}
+
+// Open returns a reader for the archive
+func (p *ArchivePack) Open() CloseableReaderAt {
+	return &archivePackReader{pack: p}
+}
+
+type archivePackReader struct {
+	pack *ArchivePack
+
+	indexOnce sync.Once
+	index     []byte
+}
+
+var zeroBlock [tarBlockSize]byte
+
+func (r *archivePackReader) ReadAt(b []byte, off int64) (n int, err error) {
+	p := r.pack
+	for n < len(b) && off < p.size {
+		// the part off is in, which is the last to start at or before it
+		i := sort.Search(len(p.parts), func(i int) bool { return p.parts[i].offset > off }) - 1
+		read, err := r.readPart(i, b[n:], off)
+		n += read
+		off += int64(read)
+		if err != nil {
+			return n, err
+		}
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readPart reads what it can of b, from off in part i
+func (r *archivePackReader) readPart(i int, b []byte, off int64) (int, error) {
+	p := r.pack
+	part := p.parts[i]
+	end := p.size
+	if i+1 < len(p.parts) {
+		end = p.parts[i+1].offset
+	}
+	h, err := p.header(i)
+	if err != nil {
+		return 0, err
+	}
+
+	rel := off - part.offset
+	switch {
+	case rel < part.headerSize:
+		header, err := tarHeader(h)
+		if err != nil {
+			return 0, err
+		}
+		return copy(b, header[rel:]), nil
+
+	case rel < part.headerSize+h.Size:
+		rel -= part.headerSize
+		want := min(int64(len(b)), h.Size-rel)
+		if i == 0 {
+			r.indexOnce.Do(func() { r.index = p.index() })
+			return copy(b[:want], r.index[rel:]), nil
+		}
+		return readMember(p.Members[i-1], b[:want], rel)
+
+	default:
+		// the padding after the content, or the end of the archive
+		zeroes := min(int64(len(b)), end-off)
+		for j := int64(0); j < zeroes; {
+			j += int64(copy(b[j:zeroes], zeroBlock[:]))
+		}
+		return int(zeroes), nil
+	}
+}
+
+func readMember(m ArchivePackMember, b []byte, off int64) (int, error) {
+	f, err := OpenLocalSourceFile(m.Source)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := f.ReadAt(b, off)
+	if n < len(b) {
+		if err == nil || err == io.EOF {
+			err = fmt.Errorf("%s is smaller than when it was scanned", m.Source)
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+func (r *archivePackReader) Close() error {
+	return nil
+}
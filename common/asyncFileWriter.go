@@ -0,0 +1,29 @@
+package common
+
+import (
+	"errors"
+)
+
+const AIOWritesFlagName = "aio-writes"
+
+// asyncFileWriter writes at explicit offsets without waiting for the data to reach the disk,
+// so that the chunkedFileWriter can carry on sorting and hashing while the kernel does the IO.
+type asyncFileWriter interface {
+	// WriteAt queues a write of data at off. done is called, possibly from another goroutine, once data is no longer needed.
+	// If WriteAt returns an error, done is not called.
+	WriteAt(data []byte, off int64, done func()) error
+
+	// Wait blocks until every queued write has finished, and returns the first error among them.
+	Wait() error
+}
+
+// aioWrites selects the kernel AIO path for downloads. It's a global for the same reason as preallocateFiles.
+var aioWrites = false
+
+func SetAIOWrites(enable bool) error {
+	if enable && !aioSupported {
+		return errors.New("the --" + AIOWritesFlagName + " flag is only supported on 64-bit FreeBSD")
+	}
+	aioWrites = enable
+	return nil
+}
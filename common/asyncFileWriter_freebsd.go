@@ -0,0 +1,199 @@
+//go:build freebsd
+
+package common
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The aiocb layout below is the LP64 one; 32-bit platforms just use the synchronous path.
+const aioSupported = strconv.IntSize == 64
+
+// aiocb mirrors struct aiocb from aio.h, on 64-bit platforms.
+type aiocb struct {
+	fildes       int32
+	_            int32
+	offset       int64
+	buf          uintptr
+	nbytes       uintptr
+	_            [2]int32
+	_            uintptr
+	lioOpcode    int32
+	reqprio      int32
+	privStatus   int
+	privError    int
+	privKernInfo uintptr
+	sigevent     [80]byte // SIGEV_NONE, since completions are collected with aio_waitcomplete
+}
+
+const maxAIOInFlightPerFile = 16
+
+type aioOp struct {
+	cb   aiocb
+	data []byte // keeps the buffer alive, and in place, until the kernel is done with it
+	done func(n int, err error)
+}
+
+// aioFileWriter submits writes with aio_write(2). Completions for the whole process are collected by aioReaper.
+type aioFileWriter struct {
+	f        *os.File
+	fd       int32
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+
+	errMu    sync.Mutex
+	firstErr error
+	fallback bool // AIO turned out not to be available, so write synchronously
+}
+
+func newAsyncFileWriter(f *os.File) asyncFileWriter {
+	if !aioSupported {
+		return nil
+	}
+	return &aioFileWriter{f: f, fd: int32(f.Fd()), inFlight: make(chan struct{}, maxAIOInFlightPerFile)}
+}
+
+func (w *aioFileWriter) err() error {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return w.firstErr
+}
+
+func (w *aioFileWriter) setErr(err error) {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	if w.firstErr == nil {
+		w.firstErr = err
+	}
+}
+
+func (w *aioFileWriter) WriteAt(data []byte, off int64, done func()) error {
+	if err := w.err(); err != nil {
+		return err
+	}
+	if w.fallback || len(data) == 0 {
+		if _, err := w.f.WriteAt(data, off); err != nil {
+			return err
+		}
+		done()
+		return nil
+	}
+
+	w.inFlight <- struct{}{}
+	op := &aioOp{data: data}
+	op.cb.fildes = w.fd
+	op.cb.offset = off
+	op.cb.buf = uintptr(unsafe.Pointer(&data[0]))
+	op.cb.nbytes = uintptr(len(data))
+	op.done = func(n int, err error) {
+		if err == nil && n != len(data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			w.setErr(err)
+		}
+		done()
+		<-w.inFlight
+		w.wg.Done()
+	}
+
+	w.wg.Add(1)
+	err := reaper.submit(op)
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EOPNOTSUPP) {
+		// aio(4) not loaded, or not allowed on this file system
+		<-w.inFlight
+		w.wg.Done()
+		w.fallback = true
+		return w.WriteAt(data, off, done)
+	} else if err != nil {
+		<-w.inFlight
+		w.wg.Done()
+		return err
+	}
+	return nil
+}
+
+func (w *aioFileWriter) Wait() error {
+	w.wg.Wait()
+	return w.err()
+}
+
+// aioReaper owns the goroutine calling aio_waitcomplete(2), which returns whichever request in the process
+// finished first, so it can't be left to each file's writer. The goroutine exits once nothing is pending,
+// and the next submit starts another.
+type aioReaper struct {
+	mu      sync.Mutex
+	pending map[uintptr]*aioOp
+	running bool
+}
+
+var reaper = &aioReaper{pending: make(map[uintptr]*aioOp)}
+
+func (r *aioReaper) submit(op *aioOp) error {
+	key := uintptr(unsafe.Pointer(&op.cb))
+	r.mu.Lock()
+	r.pending[key] = op
+	if !r.running {
+		r.running = true
+		go r.run()
+	}
+	r.mu.Unlock()
+
+	for {
+		_, _, errno := unix.Syscall(unix.SYS_AIO_WRITE, key, 0, 0)
+		if errno == unix.EAGAIN {
+			// the system-wide or per-process queue is full; give the reaper a moment to drain it
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if errno != 0 {
+			r.mu.Lock()
+			delete(r.pending, key)
+			r.mu.Unlock()
+			return errno
+		}
+		return nil
+	}
+}
+
+func (r *aioReaper) run() {
+	// the timeout lets the loop notice that a request it was waiting for was never submitted after all
+	timeout := unix.NsecToTimespec(int64(time.Second))
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.running = false
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+
+		var key uintptr
+		n, _, errno := unix.Syscall(unix.SYS_AIO_WAITCOMPLETE, uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&timeout)), 0)
+		if key == 0 {
+			continue // timed out (EAGAIN) or interrupted (EINTR) before anything completed
+		}
+
+		r.mu.Lock()
+		op, ok := r.pending[key]
+		delete(r.pending, key)
+		r.mu.Unlock()
+		if !ok {
+			continue // not one of ours
+		}
+
+		var err error
+		if errno != 0 {
+			err = errno
+		}
+		op.done(int(n), err)
+	}
+}
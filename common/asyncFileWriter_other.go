@@ -0,0 +1,13 @@
+//go:build !freebsd
+
+package common
+
+import (
+	"os"
+)
+
+const aioSupported = false
+
+func newAsyncFileWriter(f *os.File) asyncFileWriter {
+	return nil
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthSchedule caps the transfer rate differently at different times of the week, so that a long-running job
+// can yield bandwidth during business hours, for example. It's a list of rules, separated by semicolons, of the form
+//
+//	[days] HH:MM-HH:MM rate
+//
+// where days is a comma-separated list of days and ranges of days (mon-fri, sat,sun), and every day if it's left
+// out; and rate is in megabits per second, or "unlimited". A rule whose end is before its start runs past midnight
+// into the next day. The first rule that covers a time gives its cap; outside them all, --cap-mbps does.
+type BandwidthSchedule struct {
+	rules []bandwidthRule
+}
+
+type bandwidthRule struct {
+	days       [7]bool // by time.Weekday; for a rule that runs past midnight, the days it starts on
+	start, end time.Duration
+	mbps       float64 // 0 for no cap
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func ParseBandwidthSchedule(s string) (*BandwidthSchedule, error) {
+	schedule := &BandwidthSchedule{}
+	for _, text := range strings.Split(s, ";") {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		rule, err := parseBandwidthRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", strings.TrimSpace(text), err)
+		}
+		schedule.rules = append(schedule.rules, rule)
+	}
+	if len(schedule.rules) == 0 {
+		return nil, fmt.Errorf("the bandwidth schedule '%s' has no rules", s)
+	}
+	return schedule, nil
+}
+
+func parseBandwidthRule(text string) (bandwidthRule, error) {
+	rule := bandwidthRule{}
+	fields := strings.Fields(text)
+	switch len(fields) {
+	case 2:
+		rule.days = [7]bool{true, true, true, true, true, true, true}
+	case 3:
+		var err error
+		if rule.days, err = parseWeekdays(fields[0]); err != nil {
+			return rule, err
+		}
+		fields = fields[1:]
+	default:
+		return rule, fmt.Errorf("a rule must be [days] HH:MM-HH:MM rate, as in 'mon-fri 08:00-18:00 50'")
+	}
+
+	startText, endText, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return rule, fmt.Errorf("'%s' isn't a range of times, such as 08:00-18:00", fields[0])
+	}
+	var err error
+	if rule.start, err = parseTimeOfDay(startText); err != nil {
+		return rule, err
+	}
+	if rule.end, err = parseTimeOfDay(endText); err != nil {
+		return rule, err
+	}
+	if rule.start == rule.end {
+		return rule, fmt.Errorf("'%s' starts and ends at the same time; use 00:00-24:00 for the whole day", fields[0])
+	}
+
+	rate := strings.TrimSuffix(strings.ToLower(fields[1]), "mbps")
+	if rate != "unlimited" {
+		if rule.mbps, err = strconv.ParseFloat(rate, 64); err != nil || rule.mbps < 0 {
+			return rule, fmt.Errorf("'%s' isn't a rate in megabits per second, or 'unlimited'", fields[1])
+		}
+	}
+	return rule, nil
+}
+
+func parseWeekdays(text string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(strings.ToLower(text), ",") {
+		firstText, lastText, isRange := strings.Cut(part, "-")
+		first, ok := weekdayNames[firstText]
+		if !ok {
+			return days, fmt.Errorf("'%s' isn't a day; use sun, mon, tue, wed, thu, fri or sat", firstText)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[lastText]; !ok {
+				return days, fmt.Errorf("'%s' isn't a day; use sun, mon, tue, wed, thu, fri or sat", lastText)
+			}
+		}
+		// a range may run through the end of the week, as fri-mon does
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeOfDay(text string) (time.Duration, error) {
+	hoursText, minutesText, ok := strings.Cut(text, ":")
+	hours, hoursErr := strconv.Atoi(hoursText)
+	minutes, minutesErr := strconv.Atoi(minutesText)
+	if !ok || hoursErr != nil || minutesErr != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("'%s' isn't a time of day from 00:00 to 24:00", text)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// covers says whether the rule applies at t
+func (r bandwidthRule) covers(t time.Time) bool {
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if r.start < r.end {
+		return r.days[t.Weekday()] && sinceMidnight >= r.start && sinceMidnight < r.end
+	}
+	// it runs past midnight, so it covers the end of the days it starts on, and the start of the days after them
+	return (r.days[t.Weekday()] && sinceMidnight >= r.start) || (r.days[(t.Weekday()+6)%7] && sinceMidnight < r.end)
+}
+
+// MbpsAt is the cap at t, in megabits per second, with 0 for no cap. The second result is false if no rule covers
+// t, when the cap is whatever it would have been without the schedule.
+func (s *BandwidthSchedule) MbpsAt(t time.Time) (float64, bool) {
+	for _, r := range s.rules {
+		if r.covers(t) {
+			return r.mbps, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const CacheBypassFlagName = "bypass-cache"
+
+// Values for --bypass-cache
+const (
+	CacheBypassNone     = "none"     // read through the page cache (or ZFS ARC) as normal
+	CacheBypassDontNeed = "dontneed" // read normally, but tell the kernel each chunk won't be needed again
+	CacheBypassDirect   = "direct"   // also open with O_DIRECT, where the platform supports it for unaligned reads
+)
+
+// cacheBypass says how local source files are read. Like preallocateFiles, it's about the local machine, not any one transfer.
+var cacheBypass = CacheBypassNone
+
+func SetCacheBypass(mode string) error {
+	switch mode = strings.ToLower(mode); mode {
+	case CacheBypassNone, CacheBypassDontNeed, CacheBypassDirect:
+		cacheBypass = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid --%s value %q: must be one of %s, %s or %s", CacheBypassFlagName, mode, CacheBypassNone, CacheBypassDontNeed, CacheBypassDirect)
+	}
+}
+
+// OpenLocalSourceFile opens a file for upload, honouring --bypass-cache and --mmap-uploads.
+func OpenLocalSourceFile(path string) (CloseableReaderAt, error) {
+	if cacheBypass == CacheBypassNone {
+		f, err := SandboxOpenFile(path, os.O_RDONLY, 0)
+		if err != nil || !mmapUploads {
+			return f, err
+		}
+		if m := mapSourceFile(f); m != nil {
+			return m, nil
+		}
+		return f, nil
+	}
+
+	f, err := openForReading(path, cacheBypass == CacheBypassDirect)
+	if err != nil {
+		return nil, err
+	}
+	return CacheBypassingFile{f}, nil
+}
+
+// CacheBypassingFile drops each range from the cache once it has been read, so that a big backup doesn't push out
+// everything else the machine has cached.
+type CacheBypassingFile struct {
+	*os.File
+}
+
+func (f CacheBypassingFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	if n > 0 {
+		f.DropFromCache(off, int64(n))
+	}
+	return n, err
+}
+
+// DropFromCache advises the kernel that the given range won't be read again. It's only advice, so failures are ignored.
+func (f CacheBypassingFile) DropFromCache(off, length int64) {
+	_ = adviseDontNeed(f.File, off, length)
+}
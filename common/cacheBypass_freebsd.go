@@ -0,0 +1,38 @@
+//go:build freebsd
+
+package common
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const posixFadvDontNeed = 4 // POSIX_FADV_DONTNEED from sys/fcntl.h
+
+// openForReading opens path read-only. FreeBSD takes O_DIRECT as a hint rather than demanding aligned buffers,
+// so it is safe with our ReadAt calls: UFS reads around the buffer cache, and ZFS either bypasses the ARC or ignores it.
+func openForReading(path string, direct bool) (*os.File, error) {
+	flags := os.O_RDONLY
+	if direct {
+		flags |= unix.O_DIRECT
+	}
+	return SandboxOpenFile(path, flags, 0)
+}
+
+// adviseDontNeed calls posix_fadvise(2), which, like posix_fallocate, returns its error number rather than setting errno.
+func adviseDontNeed(f *os.File, off, length int64) error {
+	if strconv.IntSize != 64 {
+		return unix.ENOSYS // see posixFallocate
+	}
+
+	r1, _, errno := unix.Syscall6(unix.SYS_POSIX_FADVISE, f.Fd(), uintptr(off), uintptr(length), posixFadvDontNeed, 0, 0)
+	if errno == 0 {
+		errno = unix.Errno(r1)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openForReading opens path read-only. Linux fails O_DIRECT reads whose buffer, offset or length isn't aligned, and
+// though the slice pool's chunk buffers are, the last chunk of a file rarely ends on a boundary, so "direct" gets the
+// same treatment as "dontneed" here.
+func openForReading(path string, direct bool) (*os.File, error) {
+	return os.Open(path)
+}
+
+func adviseDontNeed(f *os.File, off, length int64) error {
+	return unix.Fadvise(int(f.Fd()), off, length, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,15 @@
+//go:build !freebsd && !linux
+
+package common
+
+import (
+	"os"
+)
+
+func openForReading(path string, direct bool) (*os.File, error) {
+	return os.Open(path)
+}
+
+func adviseDontNeed(f *os.File, off, length int64) error {
+	return nil
+}
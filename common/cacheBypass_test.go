@@ -0,0 +1,31 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheBypassReadsWholeFile(t *testing.T) {
+	a := assert.New(t)
+	defer func() { _ = SetCacheBypass(CacheBypassNone) }()
+
+	a.Error(SetCacheBypass("sometimes"))
+
+	path := filepath.Join(t.TempDir(), "source")
+	a.NoError(os.WriteFile(path, []byte("0123456789"), 0644))
+
+	for _, mode := range []string{CacheBypassNone, CacheBypassDontNeed, CacheBypassDirect} {
+		a.NoError(SetCacheBypass(mode))
+
+		f, err := OpenLocalSourceFile(path)
+		a.NoError(err)
+		buf := make([]byte, 4)
+		n, err := f.ReadAt(buf, 3)
+		a.NoError(err)
+		a.Equal("3456", string(buf[:n]), mode)
+		a.NoError(f.Close())
+	}
+}
@@ -57,12 +57,19 @@ type CacheLimiter interface {
 type cacheLimiter struct {
 	value int64
 	limit int64
+
+	memoryLimited bool // whether it counts bytes in RAM, which the memory limit applies to as well
 }
 
 func NewCacheLimiter(limit int64) CacheLimiter {
 	return &cacheLimiter{limit: limit}
 }
 
+// NewMemoryCacheLimiter limits the bytes of chunks in RAM, to limit, and to what the memory limit leaves room for
+func NewMemoryCacheLimiter(limit int64) CacheLimiter {
+	return &cacheLimiter{limit: limit, memoryLimited: true}
+}
+
 // TryAddBytes tries to add a memory allocation within the limit.  Returns true if it could be (and was) added
 func (c *cacheLimiter) TryAdd(count int64, useRelaxedLimit bool) (added bool) {
 	lim := c.limit
@@ -74,7 +81,11 @@ func (c *cacheLimiter) TryAdd(count int64, useRelaxedLimit bool) (added bool) {
 		lim = c.StrictLimit()
 	}
 
-	if atomic.AddInt64(&c.value, count) <= lim {
+	value := atomic.AddInt64(&c.value, count)
+	if value <= lim && (!c.memoryLimited || c.withinMemoryLimit(value, count, strict)) {
+		if c.memoryLimited {
+			bufferMemory.Add(count)
+		}
 		return true
 	}
 	// else, we are over the limit, so immediately subtract back what we've added, and return false
@@ -82,6 +93,16 @@ func (c *cacheLimiter) TryAdd(count int64, useRelaxedLimit bool) (added bool) {
 	return false
 }
 
+// withinMemoryLimit applies the memory limit as TryAdd applies the limiter's own, with the same room held back from
+// the strict limit
+func (c *cacheLimiter) withinMemoryLimit(value, count int64, strict bool) bool {
+	fraction := float32(1)
+	if strict {
+		fraction = cacheLimiterStrictLimitPercentage
+	}
+	return withinMemoryLimit(value, count, fraction)
+}
+
 /// WaitUntilAddBytes blocks until it completes a successful call to TryAddBytes
 func (c *cacheLimiter) WaitUntilAdd(ctx context.Context, count int64, useRelaxedLimit Predicate) error {
 	for {
@@ -109,6 +130,9 @@ func (c *cacheLimiter) WaitUntilAdd(ctx context.Context, count int64, useRelaxed
 func (c *cacheLimiter) Remove(count int64) {
 	negativeDelta := -count
 	atomic.AddInt64(&c.value, negativeDelta)
+	if c.memoryLimited {
+		bufferMemory.Add(negativeDelta)
+	}
 }
 
 func (c *cacheLimiter) Limit() int64 {
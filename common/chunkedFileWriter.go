@@ -22,11 +22,11 @@ package common
 
 import (
 	"context"
-	"crypto/md5"
 	"errors"
 	"hash"
 	"io"
 	"math"
+	"os"
 	"sync/atomic"
 	"time"
 )
@@ -34,7 +34,8 @@ import (
 // Used to write all the chunks to a disk file
 type ChunkedFileWriter interface {
 
-	// WaitToScheduleChunk blocks until enough RAM is available to handle the given chunk, then it
+	// WaitToScheduleChunk blocks until enough RAM is available to handle the given chunk, and, with --prefetch-chunks,
+	// until it is close enough to the part of the file that has been saved, then it
 	// "reserves" that amount of RAM in the CacheLimiter and returns.
 	WaitToScheduleChunk(ctx context.Context, id ChunkID, chunkSize int64) error
 
@@ -52,6 +53,21 @@ type ChunkedFileWriter interface {
 
 	// MaxRetryPerDownloadBody returns the maximum number of retries that will be done for the download of a single chunk body
 	MaxRetryPerDownloadBody() int
+
+	// SavedOffset returns how many bytes at the start of the file are known to have been saved. Call it after Flush,
+	// e.g. to find out where a cancelled download could carry on from.
+	SavedOffset() int64
+
+	// CheckpointEvery asks for checkpoint to be called each time at least interval more bytes at the start of the file
+	// have been saved, with how many are. The file is synced first, so that they stay saved even if the machine goes
+	// down. Call it before enqueuing any chunks. It does nothing for files that can't be synced, or that are written
+	// with kernel AIO, which only counts chunks as saved at the end.
+	CheckpointEvery(interval int64, checkpoint func(savedOffset int64))
+
+	// SyncWhileSaving has the file synced as chunks are saved: after each one, with EFsyncMode.PerChunk(), or at most
+	// every policy.Interval, with EFsyncMode.Interval(). Syncing the complete file is up to the caller. Call it before
+	// enqueuing any chunks. Like CheckpointEvery, it does nothing for files that are wrapped, or written with kernel AIO.
+	SyncWhileSaving(policy FsyncPolicy)
 }
 
 type chunkedFileWriter struct {
@@ -60,6 +76,13 @@ type chunkedFileWriter struct {
 	// refer to: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
 	currentReservedCapacity int64
 
+	// how far from the start of the file has been saved, without gaps
+	atomicSavedOffset int64
+
+	// how far from the start of the file has been handed over to be written. Chunks are only scheduled up to
+	// prefetchChunks beyond it.
+	atomicWrittenOffset int64
+
 	// all time received count for this instance
 	totalChunkReceiveMilliseconds int64
 	totalReceivedChunkCount       int32
@@ -72,6 +95,17 @@ type chunkedFileWriter struct {
 	// the file we are writing to (type as interface to somewhat abstract away io.File - e.g. for unit testing)
 	file io.WriteCloser
 
+	// set if all-zero ranges can be skipped over rather than written, leaving holes in the file.
+	// That's only safe because the file has already been created at its final size (see CreateFileOfSizeWithWriteThroughOption)
+	holeSeeker io.Seeker
+
+	// set when writes go through kernel AIO (see --aio-writes), in which case a chunk's buffer is only released
+	// once its write has completed, rather than as soon as it has been handed over
+	async asyncFileWriter
+
+	// set when contiguous chunks are saved together with pwritev(2), straight from their buffers, rather than one at a time
+	vectored *os.File
+
 	// pool of byte slices (to avoid constant GC)
 	slicePool ByteSlicePooler
 
@@ -84,6 +118,11 @@ type chunkedFileWriter struct {
 	// file chunks that have arrived and not been sorted yet
 	newUnorderedChunks chan fileChunk
 
+	// the most chunks that are scheduled ahead of atomicWrittenOffset, or 0 if only RAM limits them.
+	// writtenOffsetMoved is signalled whenever atomicWrittenOffset moves on.
+	prefetchChunks     uint32
+	writtenOffsetMoved chan struct{}
+
 	// used for completion
 	successMd5      chan []byte
 	chunkWriterDone chan bool
@@ -93,9 +132,28 @@ type chunkedFileWriter struct {
 
 	// how will hashes be validated?
 	md5ValidationOption HashValidationOption
+	hashType            ContentHashType
 
 	sourceMd5Exists bool
 
+	// set when carrying on with a file whose start was saved by an earlier run. It's only read if we need to hash it.
+	savedPrefix *io.SectionReader
+
+	// how far chunks have been handed over to the kernel for writing, when writing asynchronously.
+	// They don't count as saved until the kernel tells us that they have been.
+	queuedOffset int64
+
+	// set by CheckpointEvery. Only the worker routine touches lastCheckpoint, which is the saved offset last reported.
+	syncer             interface{ Sync() error }
+	checkpoint         func(savedOffset int64)
+	checkpointInterval int64
+	lastCheckpoint     int64
+
+	// set by SyncWhileSaving. Only the worker routine touches lastSync.
+	syncFile   *os.File
+	syncPolicy FsyncPolicy
+	lastSync   time.Time
+
 	err error // This field should be set only by workerRoutine
 }
 
@@ -104,7 +162,27 @@ type fileChunk struct {
 	data []byte
 }
 
-func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, sourceMd5Exists bool) ChunkedFileWriter {
+func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, hashType ContentHashType, sourceMd5Exists bool) ChunkedFileWriter {
+	w := newChunkedFileWriter(slicePool, cacheLimiter, chunkLogger, file, numChunks, maxBodyRetries, md5ValidationOption, hashType, sourceMd5Exists)
+	go w.workerRoutine(ctx)
+	return w
+}
+
+// ResumeChunkedFileWriter is like NewChunkedFileWriter, but for a file whose first savedPrefix.Size() bytes were saved
+// before the job was shut down. file must already be positioned just after them, and the first chunk enqueued must start
+// there. savedPrefix is read back only if we need the MD5 hash of the whole file.
+func ResumeChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, savedPrefix *io.SectionReader, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, hashType ContentHashType, sourceMd5Exists bool) ChunkedFileWriter {
+	w := newChunkedFileWriter(slicePool, cacheLimiter, chunkLogger, file, numChunks, maxBodyRetries, md5ValidationOption, hashType, sourceMd5Exists)
+	w.savedPrefix = savedPrefix
+	w.atomicSavedOffset = savedPrefix.Size()
+	w.queuedOffset = savedPrefix.Size()
+	w.atomicWrittenOffset = savedPrefix.Size()
+	w.lastCheckpoint = savedPrefix.Size()
+	go w.workerRoutine(ctx)
+	return w
+}
+
+func newChunkedFileWriter(slicePool ByteSlicePooler, cacheLimiter CacheLimiter, chunkLogger ChunkStatusLogger, file io.WriteCloser, numChunks uint32, maxBodyRetries int, md5ValidationOption HashValidationOption, hashType ContentHashType, sourceMd5Exists bool) *chunkedFileWriter {
 	// Set max size for buffered channel. The upper limit here is believed to be generous, given worker routine drains it constantly.
 	// Use num chunks in file if lower than the upper limit, to prevent allocating RAM for lots of large channel buffers when dealing with
 	// very large numbers of very small files.
@@ -120,10 +198,24 @@ func NewChunkedFileWriter(ctx context.Context, slicePool ByteSlicePooler, cacheL
 		newUnorderedChunks:      make(chan fileChunk, chanBufferSize),
 		maxRetryPerDownloadBody: maxBodyRetries,
 		md5ValidationOption:     md5ValidationOption,
+		hashType:                hashType,
 		sourceMd5Exists:         sourceMd5Exists,
 		currentReservedCapacity: 0,
+		prefetchChunks:          prefetchChunks,
+		writtenOffsetMoved:      make(chan struct{}, 1),
+	}
+	if f, ok := file.(*os.File); ok && holesSupported {
+		w.holeSeeker = f
+	}
+	if f, ok := file.(*os.File); ok && aioWrites {
+		w.async = newAsyncFileWriter(f)
+	}
+	if f, ok := file.(*os.File); ok && vectoredWrites && w.async == nil {
+		// pwritev needs a file that can be written at an offset, which stdout, for example, may not be
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			w.vectored = f
+		}
 	}
-	go w.workerRoutine(ctx)
 	return w
 }
 
@@ -131,6 +223,16 @@ var ChunkWriterAlreadyFailed = errors.New("chunk Writer already failed")
 
 const maxDesirableActiveChunks = 20 // TODO: can we find a sensible way to remove the hard-coded count threshold here?
 
+const PrefetchChunksFlagName = "prefetch-chunks"
+
+// prefetchChunks is how many chunks of each file are downloaded ahead of the point it has been written up to, or 0 to
+// leave it to the cache limiter. Like preallocateFiles, it's about the local machine (and its link), not any one transfer.
+var prefetchChunks uint32 = 0
+
+func SetPrefetchChunks(chunks uint32) {
+	prefetchChunks = chunks
+}
+
 // Waits until we have enough RAM, within our pre-determined allocation, to accommodate the chunk.
 // After any necessary wait, it updates the count of scheduled-but-unsaved bytes
 // Note: we considered tracking only received-but-unsaved-bytes (i.e. increment the count at time of making the
@@ -140,6 +242,9 @@ const maxDesirableActiveChunks = 20 // TODO: can we find a sensible way to remov
 // from the cache limiter, which is also in this struct.
 func (w *chunkedFileWriter) WaitToScheduleChunk(ctx context.Context, id ChunkID, chunkSize int64) error {
 	w.chunkLogger.LogChunkStatus(id, EWaitReason.RAMToSchedule())
+	if err := w.waitForPrefetchWindow(ctx, id, chunkSize); err != nil {
+		return err
+	}
 	err := w.cacheLimiter.WaitUntilAdd(ctx, chunkSize, w.shouldUseRelaxedRamThreshold)
 	if err == nil {
 		atomic.AddInt64(&w.currentReservedCapacity, chunkSize)
@@ -149,6 +254,28 @@ func (w *chunkedFileWriter) WaitToScheduleChunk(ctx context.Context, id ChunkID,
 	// At this point, the book-keeping of this memory is chunkedFileWriter's responsibility
 }
 
+// waitForPrefetchWindow waits until the chunk starts less than prefetchChunks chunks beyond the part of the file that
+// has been written. Chunks are scheduled in order, by one goroutine per file, so there's only ever one waiting here.
+func (w *chunkedFileWriter) waitForPrefetchWindow(ctx context.Context, id ChunkID, chunkSize int64) error {
+	if w.prefetchChunks == 0 {
+		return nil
+	}
+	window := int64(w.prefetchChunks) * chunkSize
+	for id.OffsetInFile() >= atomic.LoadInt64(&w.atomicWrittenOffset)+window {
+		select {
+		case <-w.writtenOffsetMoved:
+		case <-w.chunkWriterDone:
+			if w.err != nil {
+				return w.err
+			}
+			return ChunkWriterAlreadyFailed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
 // Threadsafe method to enqueue a new chunk for processing
 func (w *chunkedFileWriter) EnqueueChunk(ctx context.Context, id ChunkID, chunkSize int64, chunkContents io.Reader, retryable bool) (err error) {
 	readDone := make(chan struct{})
@@ -241,6 +368,32 @@ func (w *chunkedFileWriter) MaxRetryPerDownloadBody() int {
 	return w.maxRetryPerDownloadBody
 }
 
+func (w *chunkedFileWriter) SavedOffset() int64 {
+	return atomic.LoadInt64(&w.atomicSavedOffset)
+}
+
+func (w *chunkedFileWriter) CheckpointEvery(interval int64, checkpoint func(savedOffset int64)) {
+	syncer, ok := w.file.(interface{ Sync() error })
+	if !ok || w.async != nil {
+		return
+	}
+	// the worker routine only reads these once it has been sent a chunk, which happens after this
+	w.syncer = syncer
+	w.checkpoint = checkpoint
+	w.checkpointInterval = interval
+}
+
+func (w *chunkedFileWriter) SyncWhileSaving(policy FsyncPolicy) {
+	f, ok := w.file.(*os.File)
+	if !ok || w.async != nil || (policy.Mode != EFsyncMode.PerChunk() && policy.Mode != EFsyncMode.Interval()) {
+		return
+	}
+	// as in CheckpointEvery, the worker routine only reads these once it has been sent a chunk
+	w.syncFile = f
+	w.syncPolicy = policy
+	w.lastSync = time.Now()
+}
+
 // Each fileChunkWriter needs exactly one goroutine running this, to service the channel and save the data
 // This routine orders the data sequentially, so that (a) we can get maximum performance without
 // resorting to the likes of SetFileValidData (https://docs.microsoft.com/en-us/windows/desktop/api/fileapi/nf-fileapi-setfilevaliddata)
@@ -248,13 +401,18 @@ func (w *chunkedFileWriter) MaxRetryPerDownloadBody() int {
 func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 	nextOffsetToSave := int64(0)
 	unsavedChunksByFileOffset := make(map[int64]fileChunk)
-	md5Hasher := md5.New()
+	md5Hasher := w.hashType.NewHasher()
 	if w.md5ValidationOption == EHashValidationOption.NoCheck() || !w.sourceMd5Exists {
 		// save CPU time by not even computing a hash, if we don't want to check it, or have nothing to check it against
 		md5Hasher = &nullHasher{}
 	}
 
 	defer func() {
+		// the kernel may still be writing from buffers that belong to the pool
+		if w.async != nil && w.async.Wait() == nil {
+			atomic.StoreInt64(&w.atomicSavedOffset, w.queuedOffset)
+		}
+
 		// cleanup stuff if we abruptly quit
 		for _, chunk := range unsavedChunksByFileOffset {
 			w.cacheLimiter.Remove(int64(chunk.id.length)) // remove this from the tally of scheduled-but-unsaved bytes
@@ -267,6 +425,20 @@ func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 		unsavedChunksByFileOffset = nil
 	}()
 
+	if w.savedPrefix != nil {
+		// the hash has to cover the whole file, including what was saved before (but there's no point reading it back for a nullHasher)
+		if _, hashing := md5Hasher.(*nullHasher); !hashing {
+			if _, err := io.Copy(md5Hasher, w.savedPrefix); err != nil {
+				w.err = err
+				return
+			}
+		}
+		nextOffsetToSave = w.savedPrefix.Size()
+	}
+
+	// fires once chunks have been held back, to be saved with those after them, for as long as they should be
+	var saveHeldChunks <-chan time.Time
+
 	for {
 		var newChunk fileChunk
 		var channelIsOpen bool
@@ -276,11 +448,28 @@ func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 		case newChunk, channelIsOpen = <-w.newUnorderedChunks:
 			if !channelIsOpen {
 				// If channel is closed, we know that flush as been called and we have read everything
-				// So we are finished
+				// So we are finished, once any chunks that were held back are saved
 				// We know there was no error, because if there was an error we would have returned before now
+				if _, err := w.sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset, &nextOffsetToSave, md5Hasher, ctx, true); err != nil {
+					w.err = err
+					return
+				}
+				if w.async != nil {
+					if err := w.async.Wait(); err != nil {
+						w.err = err
+						return
+					}
+				}
 				w.successMd5 <- md5Hasher.Sum(nil)
 				return
 			}
+		case <-saveHeldChunks:
+			saveHeldChunks = nil
+			if _, err := w.sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset, &nextOffsetToSave, md5Hasher, ctx, true); err != nil {
+				w.err = err
+				return
+			}
+			continue
 		case <-ctx.Done(): // If cancelled out in the middle of enqueuing chunks OR processing chunks, they will both cleanly cancel out and we'll get back to here.
 			w.err = ctx.Err()
 			return
@@ -292,38 +481,134 @@ func (w *chunkedFileWriter) workerRoutine(ctx context.Context) {
 
 		// Process all chunks that we can
 		w.setStatusForContiguousAvailableChunks(unsavedChunksByFileOffset, nextOffsetToSave, ctx) // update states of those that have all their prior ones already here
-		err := w.sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset, &nextOffsetToSave, md5Hasher, ctx)
+		held, err := w.sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset, &nextOffsetToSave, md5Hasher, ctx, false)
 		if err != nil {
 			w.err = err
 			return // no point in processing any more after a failure
 		}
+		if !held {
+			saveHeldChunks = nil
+		} else if saveHeldChunks == nil {
+			saveHeldChunks = time.After(maxHoldBack)
+		}
 	}
 }
 
 // Hashes and saves available chunks that are sequential from nextOffsetToSave. Stops and returns as soon as it hits
-// a gap (i.e. the position of a chunk that hasn't arrived yet)
-func (w *chunkedFileWriter) sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset map[int64]fileChunk, nextOffsetToSave *int64, md5Hasher hash.Hash, ctx context.Context) error {
+// a gap (i.e. the position of a chunk that hasn't arrived yet), or, unless force is set, as soon as it comes to chunks
+// that are held back to be saved with those after them, in which case it returns held.
+func (w *chunkedFileWriter) sequentiallyProcessAvailableChunks(unsavedChunksByFileOffset map[int64]fileChunk, nextOffsetToSave *int64, md5Hasher hash.Hash, ctx context.Context, force bool) (held bool, err error) {
 	for {
 		select {
 		case <-ctx.Done():
-			return nil // Break out of the loop if cancelled. Done can be checked multiple times, so it's safe to not error out.
+			return false, nil // Break out of the loop if cancelled. Done can be checked multiple times, so it's safe to not error out.
 		default:
 		}
 
 		// Look for next chunk in sequence
 		nextChunkInSequence, exists := unsavedChunksByFileOffset[*nextOffsetToSave]
 		if !exists {
-			return nil // its not there yet. That's OK.
+			return false, nil // its not there yet. That's OK.
+		}
+		if !force && w.shouldHoldBack(unsavedChunksByFileOffset, *nextOffsetToSave) {
+			return true, nil
 		}
 		delete(unsavedChunksByFileOffset, *nextOffsetToSave)      // remove it
 		*nextOffsetToSave += int64(len(nextChunkInSequence.data)) // update immediately so we won't forget!
 
-		// Save it (hashing exactly what we save)
-		err := w.saveOneChunk(nextChunkInSequence, md5Hasher)
+		// Save it (hashing exactly what we save), along with any that follow it, if they can be saved together
+		if w.vectored != nil {
+			chunks := []fileChunk{nextChunkInSequence}
+			for len(chunks) < maxVectoredWriteChunks {
+				following, exists := unsavedChunksByFileOffset[*nextOffsetToSave]
+				if !exists {
+					break
+				}
+				delete(unsavedChunksByFileOffset, *nextOffsetToSave)
+				*nextOffsetToSave += int64(len(following.data))
+				chunks = append(chunks, following)
+			}
+			err = w.saveChunksVectored(chunks, md5Hasher)
+		} else {
+			err = w.saveOneChunk(nextChunkInSequence, md5Hasher)
+		}
 		if err != nil {
-			return err
+			return false, err
+		}
+		w.movePrefetchWindow(*nextOffsetToSave)
+		if w.async != nil {
+			w.queuedOffset = *nextOffsetToSave
+		} else {
+			atomic.StoreInt64(&w.atomicSavedOffset, *nextOffsetToSave)
+			if err := w.syncIfDue(); err != nil {
+				return false, err
+			}
+			if err := w.checkpointIfDue(*nextOffsetToSave); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// movePrefetchWindow lets more chunks be scheduled, now that the file has been written up to writtenOffset
+func (w *chunkedFileWriter) movePrefetchWindow(writtenOffset int64) {
+	atomic.StoreInt64(&w.atomicWrittenOffset, writtenOffset)
+	select {
+	case w.writtenOffsetMoved <- struct{}{}:
+	default: // the waiter hasn't taken the last signal yet, and will see this offset when it does
+	}
+}
+
+// coalescedWriteSize is how much, at least, is saved at once with pwritev(2), when chunks are smaller than that.
+// Chunks that add up to less are held back for up to maxHoldBack, to be saved with those that follow them, so that
+// a spinning disk, or an NFS mount, isn't given lots of small writes.
+const coalescedWriteSize = 4 * 1024 * 1024
+
+// maxHoldBack is a var so that tests can hold chunks back for longer
+var maxHoldBack = 100 * time.Millisecond
+
+// shouldHoldBack reports whether the contiguous chunks from offset are too small to be worth saving on their own yet.
+// Only the pwritev(2) path holds chunks back, since it's the one that saves them in one call. They're not held when
+// memory is short, or when they fill half the prefetch window, since the chunks they'd be waiting for may not be
+// scheduled until memory is freed, or until they are saved.
+func (w *chunkedFileWriter) shouldHoldBack(unsavedChunksByFileOffset map[int64]fileChunk, offset int64) bool {
+	if w.vectored == nil {
+		return false
+	}
+	var runLength int64
+	var lastChunkSize int64
+	for count := 0; count < maxVectoredWriteChunks; count++ {
+		chunk, exists := unsavedChunksByFileOffset[offset+runLength]
+		if !exists {
+			fillsPrefetchWindow := w.prefetchChunks > 0 && runLength*2 >= int64(w.prefetchChunks)*lastChunkSize
+			return runLength < coalescedWriteSize && !fillsPrefetchWindow && !w.haveMemoryPressure(lastChunkSize)
 		}
+		lastChunkSize = int64(len(chunk.data))
+		runLength += lastChunkSize
+	}
+	return false // as many as are saved together at once
+}
+
+// syncIfDue syncs the file, if SyncWhileSaving asked for it to be synced by now
+func (w *chunkedFileWriter) syncIfDue() error {
+	if w.syncFile == nil || (w.syncPolicy.Mode == EFsyncMode.Interval() && time.Since(w.lastSync) < w.syncPolicy.Interval) {
+		return nil
+	}
+	w.lastSync = time.Now()
+	return Fdatasync(w.syncFile)
+}
+
+// checkpointIfDue syncs the file, and reports how much of it is saved, if enough more has been since it last did
+func (w *chunkedFileWriter) checkpointIfDue(savedOffset int64) error {
+	if w.checkpoint == nil || savedOffset-w.lastCheckpoint < w.checkpointInterval {
+		return nil
 	}
+	if err := w.syncer.Sync(); err != nil {
+		return err
+	}
+	w.lastCheckpoint = savedOffset
+	w.checkpoint(savedOffset)
+	return nil
 }
 
 // Advances the status of chunks which are no longer waiting on missing predecessors, but are instead just waiting on
@@ -346,17 +631,15 @@ func (w *chunkedFileWriter) setStatusForContiguousAvailableChunks(unsavedChunksB
 	}
 }
 
+// maxWriteSize is the most that saveOneChunk writes at once, and the size of the ranges that are checked for holes
+const maxWriteSize = 1024 * 1024
+
 // Saves one chunk to its destination
 func (w *chunkedFileWriter) saveOneChunk(chunk fileChunk, md5Hasher hash.Hash) error {
-	defer func() {
-		w.cacheLimiter.Remove(int64(len(chunk.data))) // remove this from the tally of scheduled-but-unsaved bytes
-		w.slicePool.ReturnSlice(chunk.data)
-		atomic.AddInt32(&w.activeChunkCount, -1)
-		atomic.AddInt64(&w.currentReservedCapacity, -chunk.id.length)
-		w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.ChunkDone()) // this chunk is all finished
-	}()
-
-	const maxWriteSize = 1024 * 1024
+	if w.async != nil {
+		return w.saveOneChunkAsync(chunk, md5Hasher)
+	}
+	defer w.releaseChunk(chunk)
 
 	w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.DiskIO())
 
@@ -369,6 +652,14 @@ func (w *chunkedFileWriter) saveOneChunk(chunk fileChunk, md5Hasher hash.Hash) e
 
 		// always hash exactly what we save
 		md5Hasher.Write(slice)
+
+		if w.holeSeeker != nil && isAllZero(slice) {
+			if _, err := w.holeSeeker.Seek(int64(len(slice)), io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
 		_, err := w.file.Write(slice) // unlike Read, Write must process ALL the data, or have an error.  It can't return "early".
 		if err != nil {
 			return err
@@ -378,6 +669,68 @@ func (w *chunkedFileWriter) saveOneChunk(chunk fileChunk, md5Hasher hash.Hash) e
 	return nil
 }
 
+// saveChunksVectored saves contiguous chunks with as few pwritev(2) calls as it can. As saveOneChunk does, it leaves
+// holes for all-zero ranges, where it can, and those split the writes.
+func (w *chunkedFileWriter) saveChunksVectored(chunks []fileChunk, md5Hasher hash.Hash) error {
+	defer func() {
+		for _, chunk := range chunks {
+			w.releaseChunk(chunk)
+		}
+	}()
+
+	bufs := make([][]byte, 0, len(chunks))
+	offset := chunks[0].id.OffsetInFile() // where bufs go
+	for _, chunk := range chunks {
+		w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.DiskIO())
+		md5Hasher.Write(chunk.data)
+		if w.holeSeeker == nil {
+			bufs = append(bufs, chunk.data)
+			continue
+		}
+
+		for i := 0; i < len(chunk.data); i += maxWriteSize {
+			slice := chunk.data[i:min(i+maxWriteSize, len(chunk.data))]
+			if !isAllZero(slice) {
+				bufs = append(bufs, slice)
+				continue
+			}
+			if err := writeVectoredAt(w.vectored, bufs, offset); err != nil {
+				return err
+			}
+			bufs = bufs[:0]
+			offset = chunk.id.OffsetInFile() + int64(i+len(slice))
+		}
+	}
+	return writeVectoredAt(w.vectored, bufs, offset)
+}
+
+// saveOneChunkAsync queues the chunk with the kernel, and leaves it to be released when the write completes.
+// There's no point chopping it up as saveOneChunk does, since the kernel isn't holding us up while it writes.
+func (w *chunkedFileWriter) saveOneChunkAsync(chunk fileChunk, md5Hasher hash.Hash) error {
+	w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.DiskIO())
+	md5Hasher.Write(chunk.data)
+
+	if w.holeSeeker != nil && isAllZero(chunk.data) {
+		w.releaseChunk(chunk)
+		return nil
+	}
+
+	err := w.async.WriteAt(chunk.data, chunk.id.OffsetInFile(), func() { w.releaseChunk(chunk) })
+	if err != nil {
+		w.releaseChunk(chunk)
+	}
+	return err
+}
+
+// releaseChunk does the book-keeping once a chunk has been saved, and returns its buffer to the pool.
+func (w *chunkedFileWriter) releaseChunk(chunk fileChunk) {
+	w.cacheLimiter.Remove(int64(len(chunk.data))) // remove this from the tally of scheduled-but-unsaved bytes
+	w.slicePool.ReturnSlice(chunk.data)
+	atomic.AddInt32(&w.activeChunkCount, -1)
+	atomic.AddInt64(&w.currentReservedCapacity, -chunk.id.length)
+	w.chunkLogger.LogChunkStatus(chunk.id, EWaitReason.ChunkDone()) // this chunk is all finished
+}
+
 // We use a less strict cache limit
 // if we have relatively few chunks in progress for THIS file. Why? To try to spread
 // the work in progress across a larger number of files, instead of having it
@@ -491,3 +844,12 @@ func (w *chunkedFileWriter) calcSpeedTimeout(speedTimeoutBackoffFactor int) (spe
 	speedTimeout = w.averageDurationPerChunk() * time.Duration(multiplier) * time.Duration(speedTimeoutBackoffFactor)
 	return
 }
+
+func isAllZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,381 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopChunkStatusLogger struct{}
+
+func (nopChunkStatusLogger) LogChunkStatus(id ChunkID, reason WaitReason) {}
+func (nopChunkStatusLogger) IsWaitingOnFinalBodyReads() bool              { return false }
+
+// BenchmarkChunkedFileWriter compares the usual write(2) path with pwritev(2) and --aio-writes. Chunks arrive out of
+// order, as downloads' do, so that some are there to be saved together. The file goes in TMPDIR, so point that
+// at the file system of interest, e.g. TMPDIR=/tank/scratch go test ./common -run XXX -bench ChunkedFileWriter
+func BenchmarkChunkedFileWriter(b *testing.B) {
+	const chunkSize = 8 * 1024 * 1024
+	const numChunks = 32
+
+	modes := []struct {
+		name     string
+		vectored bool
+		aio      bool
+	}{
+		{"write", false, false},
+		{"pwritev", true, false},
+		{"aio", false, true},
+	}
+
+	data := bytes.Repeat([]byte("azcopy!"), chunkSize/7+1)[:chunkSize]
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			if err := SetAIOWrites(mode.aio); err != nil {
+				b.Skip(err)
+			}
+			defer func() { _ = SetAIOWrites(false) }()
+			if mode.vectored && !vectoredWritesSupported {
+				b.Skip("pwritev isn't supported on this platform")
+			}
+			vectoredWrites = mode.vectored
+			defer func() { vectoredWrites = vectoredWritesSupported }()
+
+			ctx := context.Background()
+			pool := NewMultiSizeSlicePool(chunkSize)
+			limiter := NewCacheLimiter(4 * chunkSize * numChunks)
+			path := filepath.Join(b.TempDir(), "bench")
+
+			b.SetBytes(chunkSize * numChunks)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f, err := os.Create(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = f.Truncate(chunkSize * numChunks); err != nil {
+					b.Fatal(err)
+				}
+
+				w := NewChunkedFileWriter(ctx, pool, limiter, nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+				for i := int64(0); i < numChunks; i++ {
+					c := i + 1
+					if i%8 == 7 {
+						c = i - 7 // the first of each eight arrives last
+					}
+					id := NewChunkID(path, c*chunkSize, chunkSize)
+					if err = w.WaitToScheduleChunk(ctx, id, chunkSize); err != nil {
+						b.Fatal(err)
+					}
+					if err = w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(data), false); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err = w.Flush(ctx); err != nil {
+					b.Fatal(err)
+				}
+				if err = f.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkedFileWriterCarriesOnFromSavedOffset(t *testing.T) {
+	a := assert.New(t)
+	const chunkSize = 1024
+	const numChunks = 4
+
+	data := make([]byte, chunkSize*numChunks)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	expectedMd5 := md5.Sum(data)
+
+	pool := NewMultiSizeSlicePool(chunkSize)
+	limiter := NewCacheLimiter(4 * chunkSize * numChunks)
+	path := filepath.Join(t.TempDir(), "partial")
+	f, err := os.Create(path)
+	a.NoError(err)
+	a.NoError(f.Truncate(chunkSize * numChunks))
+
+	enqueue := func(ctx context.Context, w ChunkedFileWriter, c int64) {
+		id := NewChunkID(path, c*chunkSize, chunkSize)
+		a.NoError(w.WaitToScheduleChunk(ctx, id, chunkSize))
+		a.NoError(w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(data[c*chunkSize:(c+1)*chunkSize]), false))
+	}
+
+	// the first run saves two chunks, receives the last one, and is cancelled while still waiting for the third
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewChunkedFileWriter(ctx, pool, limiter, nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.FailIfDifferent(), EContentHashType.MD5(), true)
+	enqueue(ctx, w, 0)
+	enqueue(ctx, w, 1)
+	enqueue(ctx, w, 3)
+	a.Eventually(func() bool { return w.SavedOffset() == 2*chunkSize }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	_, err = w.Flush(ctx)
+	a.Error(err)
+	a.Equal(int64(2*chunkSize), w.SavedOffset()) // the chunk after the gap doesn't count
+	a.NoError(f.Close())
+
+	// the second run carries on from there, and still hashes the whole file
+	f, err = os.OpenFile(path, os.O_RDWR, 0)
+	a.NoError(err)
+	_, err = f.Seek(2*chunkSize, io.SeekStart)
+	a.NoError(err)
+	ctx = context.Background()
+	w = ResumeChunkedFileWriter(ctx, pool, limiter, nopChunkStatusLogger{}, f, io.NewSectionReader(f, 0, 2*chunkSize), 2, 1, EHashValidationOption.FailIfDifferent(), EContentHashType.MD5(), true)
+	a.Equal(int64(2*chunkSize), w.SavedOffset())
+	enqueue(ctx, w, 3)
+	enqueue(ctx, w, 2)
+	md5AsWritten, err := w.Flush(ctx)
+	a.NoError(err)
+	a.Equal(expectedMd5[:], md5AsWritten)
+	a.Equal(int64(numChunks*chunkSize), w.SavedOffset())
+	a.NoError(f.Close())
+
+	saved, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal(data, saved)
+}
+
+func TestChunkedFileWriterCheckpointsAsItSaves(t *testing.T) {
+	a := assert.New(t)
+	const chunkSize = 1024
+	const numChunks = 5
+	vectoredWrites = false // so that chunks are saved one at a time
+	defer func() { vectoredWrites = vectoredWritesSupported }()
+
+	data := make([]byte, chunkSize*numChunks)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(path)
+	a.NoError(err)
+	defer f.Close()
+	a.NoError(f.Truncate(chunkSize * numChunks))
+
+	run := func(w ChunkedFileWriter, chunks ...int64) []int64 {
+		var checkpoints []int64
+		w.CheckpointEvery(2*chunkSize, func(savedOffset int64) {
+			checkpoints = append(checkpoints, savedOffset)
+		})
+		ctx := context.Background()
+		for _, c := range chunks {
+			id := NewChunkID(path, c*chunkSize, chunkSize)
+			a.NoError(w.WaitToScheduleChunk(ctx, id, chunkSize))
+			a.NoError(w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(data[c*chunkSize:(c+1)*chunkSize]), false))
+		}
+		_, err := w.Flush(ctx)
+		a.NoError(err)
+		return checkpoints
+	}
+	pool := NewMultiSizeSlicePool(chunkSize)
+	limiter := NewCacheLimiter(4 * chunkSize * numChunks)
+
+	// chunks that arrive out of order are checkpointed once all those before them are saved too
+	w := NewChunkedFileWriter(context.Background(), pool, limiter, nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+	a.Equal([]int64{2 * chunkSize, 4 * chunkSize}, run(w, 1, 0, 3, 2, 4))
+
+	// a resumed writer counts from where the earlier one got to
+	_, err = f.Seek(chunkSize, io.SeekStart)
+	a.NoError(err)
+	w = ResumeChunkedFileWriter(context.Background(), pool, limiter, nopChunkStatusLogger{}, f, io.NewSectionReader(f, 0, chunkSize), numChunks-1, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+	a.Equal([]int64{3 * chunkSize, 5 * chunkSize}, run(w, 1, 2, 3, 4))
+
+	saved, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal(data, saved)
+}
+
+func TestChunkedFileWriterLimitsPrefetch(t *testing.T) {
+	a := assert.New(t)
+	const chunkSize = 1024
+	const numChunks = 4
+	SetPrefetchChunks(2)
+	defer SetPrefetchChunks(0)
+
+	data := make([]byte, chunkSize*numChunks)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(path)
+	a.NoError(err)
+	defer f.Close()
+	a.NoError(f.Truncate(chunkSize * numChunks))
+	w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(chunkSize), NewCacheLimiter(4*chunkSize*numChunks), nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+	schedule := func(c int64) <-chan error {
+		scheduled := make(chan error, 1)
+		go func() { scheduled <- w.WaitToScheduleChunk(ctx, NewChunkID(path, c*chunkSize, chunkSize), chunkSize) }()
+		return scheduled
+	}
+	enqueue := func(c int64) {
+		a.NoError(w.EnqueueChunk(ctx, NewChunkID(path, c*chunkSize, chunkSize), chunkSize, bytes.NewReader(data[c*chunkSize:(c+1)*chunkSize]), false))
+	}
+	a.NoError(<-schedule(0))
+	a.NoError(<-schedule(1))
+
+	// the third chunk waits until the first has been written, not just downloaded
+	third := schedule(2)
+	enqueue(1)
+	a.Never(func() bool { return len(third) > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+	enqueue(0)
+	a.NoError(<-third)
+	a.NoError(<-schedule(3))
+	enqueue(2)
+	enqueue(3)
+	_, err = w.Flush(ctx)
+	a.NoError(err)
+
+	saved, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal(data, saved)
+
+	// and gives up if the download is cancelled
+	cancelled, cancel := context.WithCancel(ctx)
+	w = NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(chunkSize), NewCacheLimiter(4*chunkSize*numChunks), nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+	cancel()
+	a.ErrorIs(w.WaitToScheduleChunk(cancelled, NewChunkID(path, 2*chunkSize, chunkSize), chunkSize), context.Canceled)
+	_, err = w.Flush(ctx)
+	a.NoError(err)
+}
+
+func TestChunkedFileWriterSavesContiguousChunksTogether(t *testing.T) {
+	a := assert.New(t)
+	const chunkSize = 3 * 1024 * 1024 / 2 // so that holes are looked for in part of a chunk
+	const numChunks = 6
+
+	data := make([]byte, chunkSize*numChunks)
+	for i := range data[:4*chunkSize] {
+		data[i] = byte(i % 251) // the last two chunks are zeros, and may be left as holes
+	}
+	data[5*chunkSize+10] = 1 // except for this
+	expectedMd5 := md5.Sum(data)
+
+	for _, vectored := range []bool{false, true} {
+		if vectored && !vectoredWritesSupported {
+			continue
+		}
+		vectoredWrites = vectored
+
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "file")
+		f, err := os.Create(path)
+		a.NoError(err)
+		a.NoError(f.Truncate(chunkSize * numChunks))
+		w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(chunkSize), NewCacheLimiter(4*chunkSize*numChunks), nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.FailIfDifferent(), EContentHashType.MD5(), true)
+		a.Equal(vectored, w.(*chunkedFileWriter).vectored != nil)
+
+		// the first chunk arrives last, so the rest are all there to be saved with it
+		for _, c := range []int64{1, 2, 3, 4, 5, 0} {
+			id := NewChunkID(path, c*chunkSize, chunkSize)
+			a.NoError(w.WaitToScheduleChunk(ctx, id, chunkSize))
+			a.NoError(w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(data[c*chunkSize:(c+1)*chunkSize]), false))
+		}
+		md5AsWritten, err := w.Flush(ctx)
+		a.NoError(err)
+		a.Equal(expectedMd5[:], md5AsWritten)
+		a.Equal(int64(numChunks*chunkSize), w.SavedOffset())
+		a.NoError(f.Close())
+
+		saved, err := os.ReadFile(path)
+		a.NoError(err)
+		a.Equal(data, saved, "vectored: %v", vectored)
+	}
+	vectoredWrites = vectoredWritesSupported
+}
+
+func TestChunkedFileWriterHoldsBackSmallWrites(t *testing.T) {
+	a := assert.New(t)
+	if !vectoredWritesSupported {
+		t.Skip("pwritev isn't supported on this platform")
+	}
+	const chunkSize = coalescedWriteSize / 4
+	const numChunks = 5
+	maxHoldBack = time.Hour
+	defer func() { maxHoldBack = 100 * time.Millisecond }()
+
+	data := make([]byte, chunkSize*numChunks)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(path)
+	a.NoError(err)
+	defer f.Close()
+	a.NoError(f.Truncate(chunkSize * numChunks))
+	w := NewChunkedFileWriter(ctx, NewMultiSizeSlicePool(chunkSize), NewCacheLimiter(4*chunkSize*numChunks), nopChunkStatusLogger{}, f, numChunks, 1, EHashValidationOption.NoCheck(), EContentHashType.MD5(), false)
+	enqueue := func(c int64) {
+		id := NewChunkID(path, c*chunkSize, chunkSize)
+		a.NoError(w.WaitToScheduleChunk(ctx, id, chunkSize))
+		a.NoError(w.EnqueueChunk(ctx, id, chunkSize, bytes.NewReader(data[c*chunkSize:(c+1)*chunkSize]), false))
+	}
+
+	// chunks that arrive in order are held back until there are enough of them to be worth a write
+	for c := int64(0); c < 3; c++ {
+		enqueue(c)
+	}
+	a.Never(func() bool { return w.SavedOffset() > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+	enqueue(3)
+	a.Eventually(func() bool { return w.SavedOffset() == 4*chunkSize }, 5*time.Second, 10*time.Millisecond)
+
+	// and the last of them are saved when the file is flushed
+	enqueue(4)
+	_, err = w.Flush(ctx)
+	a.NoError(err)
+	a.Equal(int64(numChunks*chunkSize), w.SavedOffset())
+
+	saved, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal(data, saved)
+}
+
+func TestWriteVectoredAt(t *testing.T) {
+	a := assert.New(t)
+	if !vectoredWritesSupported {
+		t.Skip("pwritev isn't supported on this platform")
+	}
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	a.NoError(err)
+	defer f.Close()
+
+	// more buffers than one call can take, some of them empty
+	var bufs [][]byte
+	var expected []byte
+	for i := 0; i < maxIOVectors+500; i++ {
+		b := bytes.Repeat([]byte{byte(i)}, i%7)
+		bufs = append(bufs, b)
+		expected = append(expected, b...)
+	}
+	a.NoError(writeVectoredAt(f, bufs, 100))
+
+	saved, err := os.ReadFile(f.Name())
+	a.NoError(err)
+	a.Equal(append(make([]byte, 100), expected...), saved)
+}
+
+func TestIsAllZero(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(isAllZero(nil))
+	a.True(isAllZero(make([]byte, 4096)))
+
+	b := make([]byte, 4096)
+	b[4095] = 1
+	a.False(isAllZero(b))
+	b[4095] = 0
+	b[0] = 0x80
+	a.False(isAllZero(b))
+}
@@ -0,0 +1,300 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+)
+
+// Blobs encrypted on the client have their encryption recorded in this metadata, in the form that version 2 of the
+// Storage client libraries' client-side encryption records it. Each blob is encrypted with a key of its own, its
+// content encryption key, which is recorded wrapped by the user's key. The blob is split into regions of the same
+// length, each of which is encrypted separately with AES-GCM, and stored as a nonce, the ciphertext and the tag.
+const ClientSideEncryptionMeta = "encryptiondata"
+
+const (
+	clientSideEncryptionProtocol  = "2.0"
+	clientSideEncryptionAlgorithm = "AES_GCM_256"
+	clientSideEncryptionNonceLen  = 12
+	clientSideEncryptionTagLen    = 16
+
+	// ClientSideEncryptionOverhead is how much longer each region is once encrypted
+	ClientSideEncryptionOverhead = clientSideEncryptionNonceLen + clientSideEncryptionTagLen
+
+	// clientSideEncryptionLengthKey is the KeyWrappingMetadata entry recording the length of the blob before it was
+	// encrypted. Each region is authenticated on its own, so without it a blob with whole regions cut from its end
+	// would decrypt, to a shorter file, without complaint. Blobs encrypted by the Storage client libraries don't have it.
+	clientSideEncryptionLengthKey = "AzCopyPlaintextLength"
+)
+
+type ClientSideEncryptionData struct {
+	WrappedContentKey struct {
+		KeyId        string
+		EncryptedKey string // base64
+		Algorithm    string
+	}
+	EncryptionAgent struct {
+		Protocol            string
+		EncryptionAlgorithm string
+	}
+	EncryptedRegionInfo struct {
+		DataLength  int64
+		NonceLength int
+	}
+	KeyWrappingMetadata map[string]string
+}
+
+// wrappedKeyContent is what is wrapped for a content key: the protocol, padded to 8 bytes, then the key. The protocol
+// is wrapped with the key so that it can't be changed without the change being noticed.
+func wrappedKeyContent(key []byte) []byte {
+	content := make([]byte, 8, 8+len(key))
+	copy(content, clientSideEncryptionProtocol)
+	return append(content, key...)
+}
+
+// NewContentEncryptionKey makes a content encryption key for a blob of plaintextLength that is encrypted in regions of
+// regionLength, and returns it with the metadata value that records it, wrapped by wrapper
+func NewContentEncryptionKey(ctx context.Context, wrapper KeyWrapper, regionLength, plaintextLength int64) (key []byte, metadata string, err error) {
+	key = make([]byte, 32)
+	if _, err = rand.Read(key); err != nil {
+		return nil, "", err
+	}
+	wrapped, err := wrapper.WrapKey(ctx, wrappedKeyContent(key))
+	if err != nil {
+		return nil, "", fmt.Errorf("wrapping the blob's encryption key: %w", err)
+	}
+
+	var d ClientSideEncryptionData
+	d.WrappedContentKey.KeyId = wrapper.KeyID()
+	d.WrappedContentKey.EncryptedKey = base64.StdEncoding.EncodeToString(wrapped)
+	d.WrappedContentKey.Algorithm = wrapper.Algorithm()
+	d.EncryptionAgent.Protocol = clientSideEncryptionProtocol
+	d.EncryptionAgent.EncryptionAlgorithm = clientSideEncryptionAlgorithm
+	d.EncryptedRegionInfo.DataLength = regionLength
+	d.EncryptedRegionInfo.NonceLength = clientSideEncryptionNonceLen
+	d.KeyWrappingMetadata = map[string]string{
+		"EncryptionLibrary":           "AzCopy " + AzcopyVersion,
+		clientSideEncryptionLengthKey: strconv.FormatInt(plaintextLength, 10),
+	}
+
+	b, err := json.Marshal(d)
+	return key, string(b), err
+}
+
+// ParseClientSideEncryptionData parses the metadata that records how a blob was encrypted, and checks that it was
+// encrypted in a way that can be decrypted
+func ParseClientSideEncryptionData(metadata string) (*ClientSideEncryptionData, error) {
+	var d ClientSideEncryptionData
+	if err := json.Unmarshal([]byte(metadata), &d); err != nil {
+		return nil, fmt.Errorf("the blob's %s metadata is malformed: %w", ClientSideEncryptionMeta, err)
+	}
+	if d.EncryptionAgent.Protocol != clientSideEncryptionProtocol || d.EncryptionAgent.EncryptionAlgorithm != clientSideEncryptionAlgorithm {
+		return nil, fmt.Errorf("the blob is encrypted with version %s of client-side encryption, using %s, but only version %s using %s can be decrypted",
+			d.EncryptionAgent.Protocol, d.EncryptionAgent.EncryptionAlgorithm, clientSideEncryptionProtocol, clientSideEncryptionAlgorithm)
+	}
+	if d.EncryptedRegionInfo.DataLength <= 0 || d.EncryptedRegionInfo.NonceLength != clientSideEncryptionNonceLen {
+		return nil, errors.New("the blob's encrypted regions are recorded wrongly")
+	}
+	if _, err := d.plaintextLength(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// plaintextLength is the length of the blob before it was encrypted, or -1 if it isn't recorded
+func (d *ClientSideEncryptionData) plaintextLength() (int64, error) {
+	raw, ok := d.KeyWrappingMetadata[clientSideEncryptionLengthKey]
+	if !ok {
+		return -1, nil
+	}
+	length, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || length < 0 {
+		return 0, fmt.Errorf("the blob's %s metadata records its length wrongly", ClientSideEncryptionMeta)
+	}
+	return length, nil
+}
+
+// ContentDecrypter decrypts blobs that were encrypted with a particular content key
+type ContentDecrypter struct {
+	aead            cipher.AEAD
+	regionLength    int64
+	plaintextLength int64 // -1 if not known
+}
+
+// NewContentDecrypter unwraps the key the blob was encrypted with, with wrapper, for the blob to be decrypted with
+func (d *ClientSideEncryptionData) NewContentDecrypter(ctx context.Context, wrapper KeyWrapper) (*ContentDecrypter, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(d.WrappedContentKey.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("the blob's wrapped key is malformed: %w", err)
+	}
+	content, err := wrapper.UnwrapKey(ctx, d.WrappedContentKey.KeyId, d.WrappedContentKey.Algorithm, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) != 8+32 || !bytes.Equal(content[:8], wrappedKeyContent(nil)) {
+		return nil, errors.New("the blob's unwrapped key isn't for this version of client-side encryption")
+	}
+	aead, err := newContentCipher(content[8:])
+	if err != nil {
+		return nil, err
+	}
+	plaintextLength, err := d.plaintextLength()
+	if err != nil {
+		return nil, err
+	}
+	return &ContentDecrypter{aead: aead, regionLength: d.EncryptedRegionInfo.DataLength, plaintextLength: plaintextLength}, nil
+}
+
+func newContentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedSize is how long a file of the given size is once encrypted in regions of regionLength
+func EncryptedSize(size int64, regionLength int64) int64 {
+	regions := (size + regionLength - 1) / regionLength
+	return size + regions*ClientSideEncryptionOverhead
+}
+
+// encryptingChunkReader is a chunk that is encrypted as a region of its own
+type encryptingChunkReader struct {
+	*bytes.Reader
+	encrypted []byte
+	prologue  PrologueState
+}
+
+// NewEncryptingChunkReader encrypts the content of a chunk reader that has been prefetched, as a region, and closes it.
+// The encrypted chunk is kept in RAM, for retries, until the returned reader is closed.
+func NewEncryptingChunkReader(chunk SingleChunkReader, key []byte) (SingleChunkReader, error) {
+	defer chunk.Close()
+	aead, err := newContentCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prologue := chunk.GetPrologueState() // of the content as it is, for its type to be inferred
+	region := make([]byte, clientSideEncryptionNonceLen, int(chunk.Length())+ClientSideEncryptionOverhead)
+	if _, err = rand.Read(region); err != nil {
+		return nil, err
+	}
+	var plaintext bytes.Buffer
+	plaintext.Grow(int(chunk.Length()))
+	if _, err = io.Copy(&plaintext, chunk); err != nil {
+		return nil, err
+	}
+
+	encrypted := aead.Seal(region, region, plaintext.Bytes(), nil)
+	return &encryptingChunkReader{Reader: bytes.NewReader(encrypted), encrypted: encrypted, prologue: prologue}, nil
+}
+
+func (c *encryptingChunkReader) Close() error {
+	return nil
+}
+
+// BlockingPrefetch has nothing to do, since the encrypted chunk is already in RAM
+func (c *encryptingChunkReader) BlockingPrefetch(io.ReaderAt, bool) error {
+	return nil
+}
+
+func (c *encryptingChunkReader) GetPrologueState() PrologueState {
+	return c.prologue
+}
+
+// Length is the length of the chunk once encrypted
+func (c *encryptingChunkReader) Length() int64 {
+	return int64(len(c.encrypted))
+}
+
+func (c *encryptingChunkReader) HasPrefetchedEntirelyZeros() bool {
+	return false // an encrypted chunk never is
+}
+
+// WriteBufferTo hashes the encrypted chunk, which is what is stored
+func (c *encryptingChunkReader) WriteBufferTo(h hash.Hash) {
+	_, _ = h.Write(c.encrypted)
+}
+
+// decryptingWriter decrypts a blob, region by region, as it is written to it in order
+type decryptingWriter struct {
+	dst       io.WriteCloser
+	aead      cipher.AEAD
+	region    []byte
+	regionLen int
+	plaintext []byte
+	regions   int
+	decrypted int64
+	expected  int64 // -1 if not known
+}
+
+// NewDecryptingWriter returns a WriteCloser that decrypts the blob written to it into dst. Each region is checked as it's
+// decrypted, and the length of the whole once it's closed, so a blob that has been tampered with fails.
+func (c *ContentDecrypter) NewDecryptingWriter(dst io.WriteCloser) io.WriteCloser {
+	regionLen := int(c.regionLength) + ClientSideEncryptionOverhead
+	return &decryptingWriter{
+		dst:       dst,
+		aead:      c.aead,
+		region:    make([]byte, 0, regionLen),
+		regionLen: regionLen,
+		plaintext: make([]byte, 0, c.regionLength),
+		expected:  c.plaintextLength,
+	}
+}
+
+func (d *decryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), d.regionLen-len(d.region))
+		d.region = append(d.region, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(d.region) == d.regionLen {
+			if err := d.decryptRegion(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (d *decryptingWriter) decryptRegion() error {
+	if len(d.region) < ClientSideEncryptionOverhead {
+		return errors.New("the encrypted blob is truncated")
+	}
+	nonce, ciphertext := d.region[:clientSideEncryptionNonceLen], d.region[clientSideEncryptionNonceLen:]
+	plaintext, err := d.aead.Open(d.plaintext[:0], nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("region %d of the blob couldn't be decrypted, so the blob has been changed since it was encrypted", d.regions)
+	}
+	d.region = d.region[:0]
+	d.regions++
+	d.decrypted += int64(len(plaintext))
+	_, err = d.dst.Write(plaintext)
+	return err
+}
+
+// Close decrypts the last region, which may be short, checks that the blob was all there, and closes the destination
+func (d *decryptingWriter) Close() error {
+	var err error
+	if len(d.region) > 0 {
+		err = d.decryptRegion()
+	}
+	if err == nil && d.expected >= 0 && d.decrypted != d.expected {
+		err = fmt.Errorf("the blob decrypted to %d bytes, but %d were encrypted, so it has been changed since it was encrypted", d.decrypted, d.expected)
+	}
+	if closeErr := d.dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
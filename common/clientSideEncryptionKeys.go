@@ -0,0 +1,314 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// A KeyWrapper wraps the keys that blobs are encrypted with (their content encryption keys) with a key of the user's,
+// the key encryption key, so that only whoever holds that key can unwrap them
+type KeyWrapper interface {
+	// KeyID identifies the key encryption key, and is recorded with each key it wraps
+	KeyID() string
+
+	// Algorithm is the name of the algorithm keys are wrapped with, and is recorded with each key too
+	Algorithm() string
+
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+
+	// UnwrapKey unwraps a key that was wrapped by the key with the given ID, with the given algorithm. It fails if that
+	// key isn't this wrapper's.
+	UnwrapKey(ctx context.Context, keyID string, algorithm string, wrapped []byte) ([]byte, error)
+}
+
+// IsKeyVaultKey reports whether a key given for client-side encryption is the URL of a Key Vault key, rather than the
+// path of a local key file
+func IsKeyVaultKey(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "https://")
+}
+
+// NewKeyWrapper returns the wrapper for a key given for client-side encryption: either the URL of a Key Vault key,
+// which is signed in to with the credential that getCredential returns, or the path of a local key file
+func NewKeyWrapper(ctx context.Context, key string, getCredential func() (azcore.TokenCredential, error)) (KeyWrapper, error) {
+	if !IsKeyVaultKey(key) {
+		return NewLocalKeyWrapper(key)
+	}
+	cred, err := getCredential()
+	if err != nil {
+		return nil, fmt.Errorf("signing in to Key Vault: %w", err)
+	}
+	return NewKeyVaultKeyWrapper(ctx, key, cred)
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+const localKeyWrapAlgorithm = "A256KW" // RFC 3394 AES key wrap, as JSON Web Algorithms names it
+
+// localKeyWrapper wraps keys with a 256-bit AES key read from a file
+type localKeyWrapper struct {
+	kek []byte
+	id  string
+}
+
+// NewLocalKeyWrapper reads a 256-bit AES key from a file, which holds either the 32 bytes of the key or their base64
+// encoding. The key is identified by a fingerprint of it, which is safe to record since the key can't be derived from it.
+func NewLocalKeyWrapper(path string) (KeyWrapper, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the key file: %w", err)
+	}
+
+	kek := content
+	if len(kek) != 32 {
+		if kek, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(content))); err != nil || len(kek) != 32 {
+			return nil, fmt.Errorf("the key file %s must hold a 256-bit key, either as 32 bytes or in base64", path)
+		}
+	}
+
+	fingerprint := sha256.Sum256(kek)
+	return &localKeyWrapper{kek: kek, id: "local:" + hex.EncodeToString(fingerprint[:8])}, nil
+}
+
+func (w *localKeyWrapper) KeyID() string {
+	return w.id
+}
+
+func (w *localKeyWrapper) Algorithm() string {
+	return localKeyWrapAlgorithm
+}
+
+func (w *localKeyWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return aesKeyWrap(w.kek, key)
+}
+
+func (w *localKeyWrapper) UnwrapKey(_ context.Context, keyID string, algorithm string, wrapped []byte) ([]byte, error) {
+	if keyID != w.id {
+		return nil, fmt.Errorf("the blob's key was wrapped with the key %s, not with the key in the key file (%s)", keyID, w.id)
+	}
+	if algorithm != localKeyWrapAlgorithm {
+		return nil, fmt.Errorf("the blob's key was wrapped with %s, which a key file can't unwrap", algorithm)
+	}
+	return aesKeyUnwrap(w.kek, wrapped)
+}
+
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps a key as RFC 3394 says
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, errors.New("keys to be wrapped must be a multiple of 64 bits long")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	wrapped := make([]byte, 8+len(key))
+	copy(wrapped, aesKeyWrapIV)
+	copy(wrapped[8:], key)
+
+	var b [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], wrapped[:8])
+			copy(b[8:], wrapped[8*i:8*i+8])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(wrapped[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(wrapped[8*i:], b[8:])
+		}
+	}
+	return wrapped, nil
+}
+
+// aesKeyUnwrap unwraps a key that aesKeyWrap wrapped, and checks that it was wrapped with the same key
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("the wrapped key is the wrong length")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	key := make([]byte, len(wrapped))
+	copy(key, wrapped)
+
+	var b [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(key[:8])^t)
+			copy(b[8:], key[8*i:8*i+8])
+			block.Decrypt(b[:], b[:])
+			copy(key[:8], b[:8])
+			copy(key[8*i:], b[8:])
+		}
+	}
+	if !bytes.Equal(key[:8], aesKeyWrapIV) {
+		return nil, errors.New("the key couldn't be unwrapped, so it was wrapped with another key")
+	}
+	return key[8:], nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+const (
+	keyVaultAPIVersion    = "7.4"
+	keyVaultWrapAlgorithm = "RSA-OAEP-256"
+)
+
+// keyVaultKeyWrapper wraps keys with an RSA key in Key Vault (or in a Managed HSM). Wrapping only needs the public key,
+// so it's done here, once the public key has been fetched. Unwrapping needs the private key, which never leaves Key
+// Vault, so each key is sent there to be unwrapped.
+type keyVaultKeyWrapper struct {
+	client    *http.Client
+	cred      azcore.TokenCredential
+	scope     string
+	keyPrefix string // the URL of the key without its version, which the IDs of all its versions start with
+	kid       string // the ID of the key's current version, which wraps keys
+	publicKey *rsa.PublicKey
+}
+
+// NewKeyVaultKeyWrapper fetches the current version of the RSA key at keyURL, which is
+// https://<vault>.vault.azure.net/keys/<name>, optionally followed by /<version>
+func NewKeyVaultKeyWrapper(ctx context.Context, keyURL string, cred azcore.TokenCredential) (KeyWrapper, error) {
+	u, err := url.Parse(keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("the Key Vault key URL is invalid: %w", err)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Scheme != "https" || len(segments) < 2 || len(segments) > 3 || segments[0] != "keys" {
+		return nil, fmt.Errorf("%s isn't the URL of a Key Vault key, which is https://<vault>.vault.azure.net/keys/<name>", keyURL)
+	}
+
+	w := &keyVaultKeyWrapper{
+		client:    newAzcopyHTTPClient(),
+		cred:      cred,
+		scope:     "https://vault.azure.net/.default",
+		keyPrefix: "https://" + u.Host + "/keys/" + segments[1],
+	}
+	if strings.HasSuffix(strings.ToLower(u.Hostname()), ".managedhsm.azure.net") {
+		w.scope = "https://managedhsm.azure.net/.default"
+	}
+
+	var response struct {
+		Key struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"key"`
+	}
+	if err = w.do(ctx, http.MethodGet, "https://"+u.Host+u.Path, nil, &response); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(response.Key.Kty, "RSA") {
+		return nil, fmt.Errorf("the Key Vault key is of type %s, but an RSA key is needed to wrap keys", response.Key.Kty)
+	}
+	n, errN := base64.RawURLEncoding.DecodeString(strings.TrimRight(response.Key.N, "="))
+	e, errE := base64.RawURLEncoding.DecodeString(strings.TrimRight(response.Key.E, "="))
+	if errN != nil || errE != nil || len(e) > 8 {
+		return nil, errors.New("Key Vault returned a malformed public key")
+	}
+	w.kid = response.Key.Kid
+	w.publicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	return w, nil
+}
+
+func (w *keyVaultKeyWrapper) KeyID() string {
+	return w.kid
+}
+
+func (w *keyVaultKeyWrapper) Algorithm() string {
+	return keyVaultWrapAlgorithm
+}
+
+func (w *keyVaultKeyWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, w.publicKey, key, nil)
+}
+
+func (w *keyVaultKeyWrapper) UnwrapKey(ctx context.Context, keyID string, algorithm string, wrapped []byte) ([]byte, error) {
+	// any version of the key will do, since Key Vault keeps them all
+	if !strings.HasPrefix(strings.ToLower(keyID), strings.ToLower(w.keyPrefix)+"/") {
+		return nil, fmt.Errorf("the blob's key was wrapped with the key %s, not with %s", keyID, w.keyPrefix)
+	}
+
+	request := map[string]string{"alg": algorithm, "value": base64.RawURLEncoding.EncodeToString(wrapped)}
+	var response struct {
+		Value string `json:"value"`
+	}
+	if err := w.do(ctx, http.MethodPost, keyID+"/unwrapkey", request, &response); err != nil {
+		return nil, err
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(response.Value, "="))
+}
+
+// do makes a request of Key Vault's REST API, and decodes its response
+func (w *keyVaultKeyWrapper) do(ctx context.Context, method string, target string, body any, response any) error {
+	token, err := w.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{w.scope}})
+	if err != nil {
+		return fmt.Errorf("getting a token for Key Vault: %w", err)
+	}
+
+	var content io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target+"?api-version="+keyVaultAPIVersion, content)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var kvErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(b, &kvErr) == nil && kvErr.Error.Message != "" {
+			return fmt.Errorf("Key Vault refused the request (%s): %s", kvErr.Error.Code, kvErr.Error.Message)
+		}
+		return fmt.Errorf("Key Vault refused the request with status %s", resp.Status)
+	}
+	return json.Unmarshal(b, response)
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// clientTLS is the TLS config of the connections that AzCopy makes, once --ca-bundle or --client-cert has been
+// given, or nil for Go's default
+var clientTLS atomic.Pointer[tls.Config]
+
+// clientTLSDescription says what LoadClientTLS loaded, for the job log
+var clientTLSDescription atomic.Value
+
+// LoadClientTLS makes the connections that are made from then on trust the CA certificates in the PEM file caBundle,
+// as well as the system's, so that a TLS-inspecting proxy or a private endpoint with its own CA can be reached; and
+// present the client certificate in certFile, with its private key from keyFile, to a server that asks for one. The
+// key may be in certFile instead, in which case keyFile is empty. Any of them may be empty, for none.
+func LoadClientTLS(caBundle, certFile, keyFile string) error {
+	if caBundle == "" && certFile == "" && keyFile == "" {
+		return nil
+	}
+	config := &tls.Config{}
+	var description []string
+
+	if caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return fmt.Errorf("couldn't read the CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s has no PEM certificates in it", caBundle)
+		}
+		config.RootCAs = pool
+		description = append(description, fmt.Sprintf("trusting the CAs in %s as well as the system's", caBundle))
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" {
+			return errors.New("a client key was given without its certificate")
+		}
+		if keyFile == "" {
+			keyFile = certFile
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("couldn't load the client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+		description = append(description, fmt.Sprintf("presenting the client certificate in %s", certFile))
+	}
+
+	clientTLS.Store(config)
+	clientTLSDescription.Store(strings.Join(description, "; "))
+	return nil
+}
+
+// ClientTLSConfig is a copy of the TLS config that AzCopy's connections should use, or nil if they should use Go's
+// default
+func ClientTLSConfig() *tls.Config {
+	if config := clientTLS.Load(); config != nil {
+		return config.Clone()
+	}
+	return nil
+}
+
+// ClientTLSDescription says what the TLS config that LoadClientTLS loaded does, or is empty if it hasn't been called
+func ClientTLSDescription() string {
+	description, _ := clientTLSDescription.Load().(string)
+	return description
+}
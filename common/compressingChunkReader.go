@@ -0,0 +1,90 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// A blob that was compressed as it was uploaded records the size and MD5 hash (in base64) of the file it was
+// uploaded from in these metadata, so that what it decompresses to can be checked
+const (
+	UncompressedSizeMeta = "azcopy_uncompressed_size"
+	UncompressedMD5Meta  = "azcopy_uncompressed_md5"
+)
+
+// ParseUploadCompression parses the name of a compression to apply to uploads. Only those whose streams can be
+// concatenated are allowed, since each chunk is compressed separately.
+func ParseUploadCompression(name string) (CompressionType, error) {
+	switch name {
+	case "", "none":
+		return ECompressionType.None(), nil
+	case "gzip":
+		return ECompressionType.GZip(), nil
+	case "zstd":
+		return ECompressionType.Unsupported(), fmt.Errorf("compression with zstd isn't available in this build of AzCopy. Use gzip instead")
+	default:
+		return ECompressionType.Unsupported(), fmt.Errorf("'%s' is not a supported compression. Use gzip", name)
+	}
+}
+
+// compressingChunkReader is a chunk that is compressed as a stream of its own. Gzip streams can be concatenated, so a
+// file whose chunks are compressed one by one, and uploaded as the blocks of a blob, makes a blob that gunzips to
+// the file. That lets each chunk be compressed without waiting for those before it.
+type compressingChunkReader struct {
+	*bytes.Reader
+	compressed []byte
+	prologue   PrologueState
+}
+
+// NewCompressingChunkReader compresses the content of a chunk reader that has been prefetched, and closes it. The
+// compressed chunk is kept in RAM, for retries, until the returned reader is closed.
+func NewCompressingChunkReader(chunk SingleChunkReader, ct CompressionType) (SingleChunkReader, error) {
+	defer chunk.Close()
+	if ct != ECompressionType.GZip() {
+		return nil, fmt.Errorf("chunks can't be compressed with %s", ct)
+	}
+
+	prologue := chunk.GetPrologueState() // of the content as it is, for its type to be inferred
+	var buf bytes.Buffer
+	buf.Grow(int(chunk.Length() / 2))
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gz, chunk); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := buf.Bytes()
+	return &compressingChunkReader{Reader: bytes.NewReader(compressed), compressed: compressed, prologue: prologue}, nil
+}
+
+func (c *compressingChunkReader) Close() error {
+	return nil
+}
+
+// BlockingPrefetch has nothing to do, since the compressed chunk is already in RAM
+func (c *compressingChunkReader) BlockingPrefetch(io.ReaderAt, bool) error {
+	return nil
+}
+
+func (c *compressingChunkReader) GetPrologueState() PrologueState {
+	return c.prologue
+}
+
+// Length is the length of the chunk once compressed
+func (c *compressingChunkReader) Length() int64 {
+	return int64(len(c.compressed))
+}
+
+func (c *compressingChunkReader) HasPrefetchedEntirelyZeros() bool {
+	return false // a compressed chunk never is
+}
+
+// WriteBufferTo hashes the compressed chunk, which is what is stored
+func (c *compressingChunkReader) WriteBufferTo(h hash.Hash) {
+	_, _ = h.Write(c.compressed)
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// gearTable holds the numbers the rolling hash of a ContentDefinedChunker adds for each byte. Chunk boundaries depend
+// on them, so they must never change: they come from SplitMix64, seeded with a constant.
+var gearTable = func() (table [256]uint64) {
+	state := uint64(0x617a636f70792d63) // "azcopy-c"
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// ContentDefinedChunker splits a stream into chunks at places chosen by the bytes just before them, with the gear
+// hash of FastCDC, instead of at fixed offsets. Bytes inserted into or removed from a stream change only the chunks
+// they fall in, and those after them are found again unchanged, so that a delta upload needn't send them.
+type ContentDefinedChunker struct {
+	minSize, averageSize, maxSize int64
+
+	// a boundary is where the hash has these bits all zero: more of them before the average size is reached, and
+	// fewer after it, so that chunk sizes cluster around the average
+	smallMask, largeMask uint64
+}
+
+// NewContentDefinedChunker returns a chunker whose chunks are averageSize bytes, rounded down to a power of two, on
+// average, and never smaller than minSize or bigger than maxSize, except for the last chunk, which may be smaller.
+func NewContentDefinedChunker(averageSize, minSize, maxSize int64) (*ContentDefinedChunker, error) {
+	if averageSize < 64 || minSize <= 0 || minSize > averageSize || maxSize < averageSize {
+		return nil, errors.New("content defined chunks must have 0 < minimum <= average <= maximum size, and an average of 64 bytes or more")
+	}
+	averageBits := 63 - bits.LeadingZeros64(uint64(averageSize))
+	return &ContentDefinedChunker{
+		minSize:     minSize,
+		averageSize: averageSize,
+		maxSize:     maxSize,
+		smallMask:   highBits(averageBits + 2),
+		largeMask:   highBits(averageBits - 2),
+	}, nil
+}
+
+// highBits returns a mask of the n highest bits, which the gear hash has mixed the most bytes into
+func highBits(n int) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+// MaxSize returns the size no chunk is bigger than
+func (c *ContentDefinedChunker) MaxSize() int64 {
+	return c.maxSize
+}
+
+// ChunkEnds reads r to the end, and returns the offset of the end of each of its chunks. An empty stream has none.
+func (c *ContentDefinedChunker) ChunkEnds(ctx context.Context, r io.Reader) ([]int64, error) {
+	var ends []int64
+	var offset, chunkStart int64
+	var hash uint64
+	buf := make([]byte, 1024*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			offset++
+			size := offset - chunkStart
+			if size <= c.minSize {
+				continue
+			}
+			hash = hash<<1 + gearTable[b]
+			mask := c.smallMask
+			if size > c.averageSize {
+				mask = c.largeMask
+			}
+			if hash&mask == 0 || size >= c.maxSize {
+				ends = append(ends, offset)
+				chunkStart = offset
+				hash = 0
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if offset > chunkStart {
+		ends = append(ends, offset)
+	}
+	return ends, nil
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"hash/crc64"
+)
+
+// Hashes other than MD5 are recorded (in base64) in these metadata, since only MD5 has a property of its own
+const (
+	ContentSHA256Meta = "azcopy_content_sha256"
+	ContentCRC64Meta  = "azcopy_content_crc64"
+)
+
+// the polynomial that the Storage service computes its CRC64s with, so that ours are the same as its
+const storageCRC64Polynomial uint64 = 0x9A6C9329AC4BC9B5
+
+var storageCRC64Table = crc64.MakeTable(storageCRC64Polynomial)
+
+// MetadataKey is the metadata that the hash is recorded in, which is empty for MD5, since that's recorded in Content-MD5
+func (ht ContentHashType) MetadataKey() string {
+	switch ht {
+	case EContentHashType.SHA256():
+		return ContentSHA256Meta
+	case EContentHashType.CRC64():
+		return ContentCRC64Meta
+	default:
+		return ""
+	}
+}
+
+// NewHasher returns a hash.Hash that computes this type of hash
+func (ht ContentHashType) NewHasher() hash.Hash {
+	switch ht {
+	case EContentHashType.SHA256():
+		return sha256.New()
+	case EContentHashType.CRC64():
+		return &storageCRC64{}
+	default:
+		return md5.New()
+	}
+}
+
+// ReadFromMetadata returns the hash recorded in metadata, or nil if there isn't one (or it's malformed, in which case
+// it will be treated as missing)
+func (ht ContentHashType) ReadFromMetadata(metadata Metadata) []byte {
+	key := ht.MetadataKey()
+	if key == "" {
+		return nil
+	}
+	raw, ok := TryReadMetadata(metadata, key)
+	if !ok || raw == nil {
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(*raw)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// storageCRC64 is the CRC64 that the Storage service computes. Its sum is little-endian, as the service's is, unlike
+// that of hash/crc64.
+type storageCRC64 struct {
+	crc uint64
+}
+
+func (c *storageCRC64) Write(p []byte) (int, error) {
+	c.crc = crc64.Update(c.crc, storageCRC64Table, p)
+	return len(p), nil
+}
+
+func (c *storageCRC64) Sum(b []byte) []byte {
+	return binary.LittleEndian.AppendUint64(b, c.crc)
+}
+
+func (c *storageCRC64) Reset() {
+	c.crc = 0
+}
+
+func (c *storageCRC64) Size() int {
+	return crc64.Size
+}
+
+func (c *storageCRC64) BlockSize() int {
+	return 1
+}
@@ -22,7 +22,7 @@ package common
 
 // CredCacheOptions contains options could be used in different kinds of cred caches in different platform.
 type CredCacheOptions struct {
-	// Used by credCache in Windows.
+	// Used by credCache in Windows and FreeBSD.
 	DPAPIFilePath string
 
 	// Used by credCacheSegmented in Windows, and keyring in Linux.
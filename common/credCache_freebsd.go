@@ -21,28 +21,55 @@
 package common
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 // CredCache manages credential caches.
-// Use keyring in Linux OS. Session keyring is chosen,
-// the session hooks key should be created since user first login (i.e. by pam).
-// So the session is inherited by processes created from login session.
-// When user logout, the session keyring is recycled.
+// FreeBSD has no session keyring, so the token is kept in a file under the AzCopy folder,
+// sealed with AES-GCM. The key is derived per-user from a random secret that lives next to the token (0600),
+// the caller's UID and the host UUID, so a token file copied to another user or another machine is useless.
 type CredCache struct {
-	keyName string // the Name of key would be cached in keyring, composed with current UID, in case user su
-	lock    sync.Mutex
+	tokenDir string
+	keyName  string // used as additional authenticated data, so caches for different sessions can't be swapped
+	lock     sync.Mutex
+}
+
+const defaultTokenFileName = "accessToken.json"
+const tokenSecretFileName = "accessToken.key"
 
-	isPermSet bool // state used to ensure key has been set permission correctly
+// tokenFileMagic prefixes every sealed token file, so that we can change the format later without guessing.
+const tokenFileMagic = "AZT1"
+const tokenSecretSize = 32
+
+// credCacheUID and credCacheHostUUID are what the key and the file checks are bound to; tests swap them out.
+var credCacheUID = os.Getuid
+var credCacheHostUUID = func() string {
+	// kern.hostuuid is set at boot from /etc/hostid; if it's missing (some jails), the key is still bound to the uid.
+	hostUUID, _ := unix.Sysctl("kern.hostuuid")
+	return hostUUID
 }
 
 // NewCredCache creates a cred cache.
 func NewCredCache(options CredCacheOptions) *CredCache {
-	c := &CredCache{
-		keyName: options.KeyName,
+	return &CredCache{
+		tokenDir: options.DPAPIFilePath,
+		keyName:  options.KeyName,
 	}
-
-	return c
 }
 
 // HasCachedToken returns if there is cached token for current executing user.
@@ -89,22 +116,256 @@ func (c *CredCache) LoadToken() (*OAuthTokenInfo, error) {
 // On the other hand, hanging threads is MUCH easier to detect and devs can fix the bug in code to make sure that the panic doesn't happen in the first place.
 ///////////////////////////////////////////////////////////////////////////////////////////////
 
-// hasCachedTokenInternal returns if there is cached token in session key ring for current login session.
+// hasCachedTokenInternal returns if there is cached token in token manager.
 func (c *CredCache) hasCachedTokenInternal() (bool, error) {
-	return false, nil
+	if _, err := os.Stat(c.tokenFilePath()); err == nil {
+		return true, nil
+	} else {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
 }
 
-// removeCachedTokenInternal deletes the cached token in session key ring.
+// removeCachedTokenInternal deletes the cached token.
+// The per-user secret is left in place, it is not sensitive on its own and other sessions may still be using it.
 func (c *CredCache) removeCachedTokenInternal() error {
+	tokenFilePath := c.tokenFilePath()
+
+	if _, err := os.Stat(tokenFilePath); err == nil {
+		// Cached token file existed
+		err = os.Remove(tokenFilePath)
+		if err != nil { // remove failed
+			return fmt.Errorf("failed to remove cached token file with path %q, %v", tokenFilePath, err)
+		}
+
+		// remove succeeded
+	} else {
+		if !os.IsNotExist(err) { // Failed to stat cached token file
+			return fmt.Errorf("failed to stat cached token file with path %q during removing, %v", tokenFilePath, err)
+		}
+
+		// token doesn't exist
+		return errors.New("no cached token found for current user")
+	}
+
 	return nil
 }
 
-// saveTokenInternal saves an oauth token in session key ring.
+// loadTokenInternal restores a Token object from file cache.
+func (c *CredCache) loadTokenInternal() (*OAuthTokenInfo, error) {
+	tokenFilePath := c.tokenFilePath()
+	if err := checkPrivateFile(tokenFilePath); err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(tokenFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %q during loading token: %v", tokenFilePath, err)
+	}
+
+	key, err := c.deriveKey(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token cache key during loading token: %v", err)
+	}
+
+	decryptedB, err := c.open(key, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt bytes during loading token: %v", err)
+	}
+
+	token, err := jsonToTokenInfo(decryptedB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal token during loading token, %v", err)
+	}
+
+	return token, nil
+}
+
+// saveTokenInternal persists an oauth token on disk.
+// It moves the new file into place so it can safely be used to replace an existing file
+// that maybe accessed by multiple processes.
 func (c *CredCache) saveTokenInternal(token OAuthTokenInfo) error {
+	tokenFilePath := c.tokenFilePath()
+	dir := filepath.Dir(tokenFilePath)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %q to store token in, %v", dir, err)
+	}
+
+	key, err := c.deriveKey(true)
+	if err != nil {
+		return fmt.Errorf("failed to derive token cache key, %v", err)
+	}
+
+	json, err := token.toJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal token, %v", err)
+	}
+
+	b, err := c.seal(key, json)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token, %v", err)
+	}
+
+	// CreateTemp already uses 0600, so the token is never readable by anyone else, not even briefly.
+	newFile, err := os.CreateTemp(dir, "token")
+	if err != nil {
+		return fmt.Errorf("failed to create the temp file to write the token, %v", err)
+	}
+	tempPath := newFile.Name()
+
+	if _, err = newFile.Write(b); err != nil {
+		_ = newFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to encode token to file %q while saving token, %v", tempPath, err)
+	}
+
+	if err := newFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to close temp file %q, %v", tempPath, err)
+	}
+
+	// Atomic replace to avoid multi-writer file corruptions
+	if err := os.Rename(tempPath, tokenFilePath); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to move temporary token to desired output location. src=%q dst=%q, %v", tempPath, tokenFilePath, err)
+	}
+	if err := os.Chmod(tokenFilePath, 0600); err != nil { // read/write for current user
+		return fmt.Errorf("failed to chmod the token file %q, %v", tokenFilePath, err)
+	}
 	return nil
 }
 
-// loadTokenInternal gets an oauth token from session key ring.
-func (c *CredCache) loadTokenInternal() (*OAuthTokenInfo, error) {
-	return nil, nil
+func (c *CredCache) tokenFilePath() string {
+	if cacheFile := GetEnvironmentVariable(EEnvironmentVariable.LoginCacheName()); cacheFile != "" {
+		return path.Join(c.tokenDir, "/", cacheFile)
+	}
+
+	return path.Join(c.tokenDir, "/", defaultTokenFileName)
+}
+
+func (c *CredCache) secretFilePath() string {
+	return path.Join(c.tokenDir, "/", tokenSecretFileName)
+}
+
+// ======================================================================================
+// AES-GCM facilities
+// ======================================================================================
+
+// deriveKey returns the AES-256 key for the current user.
+// When create is true and the per-user secret doesn't exist yet, a new one is generated.
+func (c *CredCache) deriveKey(create bool) ([]byte, error) {
+	secret, err := c.readSecret()
+	if os.IsNotExist(err) && create {
+		secret, err = c.createSecret()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keyFromSecret(secret)
+}
+
+// keyFromSecret binds the per-user secret to the current user and host.
+func keyFromSecret(secret []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, secret, []byte(credCacheHostUUID()), "azcopy/credcache/uid="+strconv.Itoa(credCacheUID()), 32)
+}
+
+func (c *CredCache) readSecret() ([]byte, error) {
+	secretPath := c.secretFilePath()
+	if err := checkPrivateFile(secretPath); err != nil {
+		return nil, err
+	}
+
+	secret, err := os.ReadFile(secretPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) != tokenSecretSize {
+		return nil, fmt.Errorf("token cache secret %q is corrupt, remove it and login again", secretPath)
+	}
+
+	return secret, nil
+}
+
+func (c *CredCache) createSecret() ([]byte, error) {
+	secret := make([]byte, tokenSecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, err
+	}
+
+	// O_EXCL so that if another azcopy raced us to it, we use theirs rather than invalidating their token.
+	f, err := os.OpenFile(c.secretFilePath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return c.readSecret()
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.Write(secret); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return secret, f.Close()
+}
+
+func (c *CredCache) seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(tokenFileMagic), nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(c.keyName)), nil
+}
+
+func (c *CredCache) open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := len(tokenFileMagic) + gcm.NonceSize()
+	if len(data) < headerLen || string(data[:len(tokenFileMagic)]) != tokenFileMagic {
+		return nil, errors.New("unrecognized token file format")
+	}
+
+	nonce := data[len(tokenFileMagic):headerLen]
+	return gcm.Open(nil, nonce, data[headerLen:], []byte(c.keyName))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// checkPrivateFile refuses to use cache files that other users could have read or planted.
+func checkPrivateFile(filePath string) error {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%q is accessible by other users (mode %#o), refusing to use it", filePath, fi.Mode().Perm())
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != credCacheUID() {
+		return fmt.Errorf("%q is not owned by the current user, refusing to use it", filePath)
+	}
+
+	return nil
 }
@@ -0,0 +1,125 @@
+//go:build freebsd
+
+package common
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCredCache(t *testing.T) (*CredCache, OAuthTokenInfo) {
+	t.Setenv(EEnvironmentVariable.LoginCacheName().Name, "")
+	c := NewCredCache(CredCacheOptions{DPAPIFilePath: t.TempDir(), KeyName: "test"})
+	token := OAuthTokenInfo{
+		Token: Token{
+			AccessToken: "mocked_access_token",
+			ExpiresIn:   "0",
+			ExpiresOn:   "0",
+			NotBefore:   "0",
+		},
+		Tenant: "mocked_tenant",
+	}
+	return c, token
+}
+
+func TestCredCacheRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	c, token := newTestCredCache(t)
+
+	has, err := c.HasCachedToken()
+	a.NoError(err)
+	a.False(has)
+
+	a.NoError(c.SaveToken(token))
+	has, err = c.HasCachedToken()
+	a.NoError(err)
+	a.True(has)
+
+	loaded, err := c.LoadToken()
+	a.NoError(err)
+	a.Equal(token.AccessToken, loaded.AccessToken)
+	a.Equal(token.Tenant, loaded.Tenant)
+
+	// the token isn't stored in the clear
+	b, err := os.ReadFile(c.tokenFilePath())
+	a.NoError(err)
+	a.NotContains(string(b), token.AccessToken)
+
+	a.NoError(c.RemoveCachedToken())
+	_, err = c.LoadToken()
+	a.Error(err)
+}
+
+func TestCredCacheBoundToUserAndHost(t *testing.T) {
+	a := assert.New(t)
+	c, token := newTestCredCache(t)
+	a.NoError(c.SaveToken(token))
+	sealed, err := os.ReadFile(c.tokenFilePath())
+	a.NoError(err)
+	secret, err := c.readSecret()
+	a.NoError(err)
+
+	realUID, realHostUUID := credCacheUID, credCacheHostUUID
+	defer func() { credCacheUID, credCacheHostUUID = realUID, realHostUUID }()
+
+	// a token file copied to another machine is useless
+	credCacheHostUUID = func() string { return "00000000-0000-0000-0000-000000000000" }
+	_, err = c.LoadToken()
+	a.Error(err)
+	credCacheHostUUID = realHostUUID
+
+	// as it is to another user, even one given the secret as well
+	credCacheUID = func() int { return realUID() + 1 }
+	key, err := keyFromSecret(secret)
+	a.NoError(err)
+	_, err = c.open(key, sealed)
+	a.Error(err)
+
+	// who won't get that far anyway, since the files aren't theirs
+	_, err = c.LoadToken()
+	a.ErrorContains(err, "not owned by the current user")
+	credCacheUID = realUID
+
+	_, err = c.LoadToken()
+	a.NoError(err)
+}
+
+func TestCredCacheRefusesFilesOthersCouldTouch(t *testing.T) {
+	a := assert.New(t)
+	c, token := newTestCredCache(t)
+	a.NoError(c.SaveToken(token))
+
+	for _, file := range []string{c.tokenFilePath(), c.secretFilePath()} {
+		a.NoError(os.Chmod(file, 0644))
+		_, err := c.LoadToken()
+		a.ErrorContains(err, "accessible by other users", file)
+		a.NoError(os.Chmod(file, 0600))
+	}
+
+	_, err := c.LoadToken()
+	a.NoError(err)
+}
+
+func TestCredCacheRejectsTamperedToken(t *testing.T) {
+	a := assert.New(t)
+	c, token := newTestCredCache(t)
+	a.NoError(c.SaveToken(token))
+
+	sealed, err := os.ReadFile(c.tokenFilePath())
+	a.NoError(err)
+	for _, i := range []int{len(tokenFileMagic) + 1, len(sealed) / 2, len(sealed) - 1} { // nonce, ciphertext and tag
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 1
+		a.NoError(os.WriteFile(c.tokenFilePath(), tampered, 0600))
+		_, err = c.LoadToken()
+		a.Error(err, "byte %d", i)
+	}
+
+	// a token sealed for another session can't be swapped in either
+	other := NewCredCache(CredCacheOptions{DPAPIFilePath: c.tokenDir, KeyName: "other"})
+	a.NoError(other.SaveToken(token))
+	_, err = c.LoadToken()
+	a.Error(err)
+}
@@ -25,7 +25,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"net"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/minio/minio-go"
 	"github.com/minio/minio-go/pkg/credentials"
@@ -72,6 +76,10 @@ func CreateS3Credential(ctx context.Context, credInfo CredentialInfo, options Cr
 		sessionToken := GetEnvironmentVariable(EEnvironmentVariable.AwsSessionToken())
 
 		// create and return s3 credential
+		if isS3CompatibleEndpoint(credInfo.S3CredentialInfo.Endpoint) &&
+			strings.EqualFold(GetEnvironmentVariable(EEnvironmentVariable.S3SignatureVersion()), "v2") {
+			return credentials.NewStaticV2(accessKeyID, secretAccessKey, sessionToken), nil
+		}
 		return credentials.NewStaticV4(accessKeyID, secretAccessKey, sessionToken), nil // S3 uses V4 signature
 	default:
 		options.panicError(fmt.Errorf("invalid state, credential type %v is not supported", credInfo.CredentialType))
@@ -85,14 +93,17 @@ func CreateS3Credential(ctx context.Context, credInfo CredentialInfo, options Cr
 func CreateS3Client(ctx context.Context, credInfo CredentialInfo, option CredentialOpOptions, logger ILogger) (*minio.Client, error) {
 	if credInfo.CredentialType == ECredentialType.S3PublicBucket() {
 		cred := credentials.NewStatic("", "", "", credentials.SignatureAnonymous)
-		return minio.NewWithOptions(credInfo.S3CredentialInfo.Endpoint, &minio.Options{Creds: cred, Secure: true, Region: credInfo.S3CredentialInfo.Region})
+		return newS3Client(credInfo.S3CredentialInfo, cred)
 	}
 	// Support access key
 	credential, err := CreateS3Credential(ctx, credInfo, option)
 	if err != nil {
 		return nil, err
 	}
-	s3Client, err := minio.NewWithCredentials(credInfo.S3CredentialInfo.Endpoint, credential, true, credInfo.S3CredentialInfo.Region)
+	s3Client, err := newS3Client(credInfo.S3CredentialInfo, credential)
+	if err != nil {
+		return nil, err
+	}
 
 	if logger != nil {
 		s3Client.TraceOn(NewS3HTTPTraceLogger(logger, LogDebug))
@@ -100,6 +111,59 @@ func CreateS3Client(ctx context.Context, credInfo CredentialInfo, option Credent
 	return s3Client, err
 }
 
+func isS3CompatibleEndpoint(endpoint string) bool {
+	compatible, _ := S3CompatibleEndpoint()
+	return compatible != "" && strings.EqualFold(endpoint, compatible)
+}
+
+// newS3Client makes a client of the endpoint in info. Its requests go through the transport of newS3Transport, rather
+// than minio's default one, which knows nothing of AzCopy's proxy settings or dialing flags.
+func newS3Client(info S3CredentialInfo, creds *credentials.Credentials) (*minio.Client, error) {
+	s3Client, err := minio.NewWithOptions(info.Endpoint, s3ClientOptions(info, creds))
+	if err != nil {
+		return nil, err
+	}
+	s3Client.SetCustomTransport(newS3Transport())
+	return s3Client, nil
+}
+
+// newS3Transport is minio's default transport, but with the proxy of GlobalProxyLookup, AzCopy's Dialer, and the TLS
+// settings of ClientTLSConfig, as the transports of Azure requests have
+func newS3Transport() *http.Transport {
+	return &http.Transport{
+		Proxy: GlobalProxyLookup,
+		DialContext: NewDialer(net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       ClientTLSConfig(),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableCompression:    true, // objects with a gzip Content-Encoding are copied as they are, not decompressed
+	}
+}
+
+// s3ClientOptions returns how to reach the endpoint in info. AWS is always reached over TLS, and minio picks the bucket
+// addressing style for it; an S3-compatible service is reached however AZCOPY_S3_ENDPOINT and AZCOPY_S3_ADDRESSING_STYLE say.
+func s3ClientOptions(info S3CredentialInfo, creds *credentials.Credentials) *minio.Options {
+	options := &minio.Options{Creds: creds, Secure: true, Region: info.Region}
+	if !isS3CompatibleEndpoint(info.Endpoint) {
+		return options
+	}
+
+	_, options.Secure = S3CompatibleEndpoint()
+	switch strings.ToLower(GetEnvironmentVariable(EEnvironmentVariable.S3AddressingStyle())) {
+	case "path":
+		options.BucketLookup = minio.BucketLookupPath
+	case "virtual":
+		options.BucketLookup = minio.BucketLookupDNS
+	}
+	return options
+}
+
 type S3ClientFactory struct {
 	s3Clients map[S3CredentialInfo]*minio.Client
 	lock      sync.RWMutex
@@ -21,9 +21,13 @@
 package common
 
 import (
+	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"crypto/md5"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"time"
 )
@@ -73,7 +77,11 @@ func (d decompressingWriter) worker(tp CompressionType, preader *io.PipeReader,
 	var dec io.ReadCloser
 
 	defer func() {
-		_ = destination.Close() // always close the destination file before we exit, since its a WriteCloser
+		// always close the destination file before we exit, since its a WriteCloser. Its error counts, since it may
+		// say that what was decompressed isn't what was expected
+		if closeErr := destination.Close(); err == nil {
+			err = closeErr
+		}
 		_ = preader.Close()
 		workerError <- err // send the error AFTER we have closed everything, to avoid race conditions where callers assume all closes are completed when we return
 	}()
@@ -132,3 +140,38 @@ func (d decompressingWriter) Close() error {
 		return errors.New("timed out closing decompression worker")
 	}
 }
+
+type decompressedFileVerifier struct {
+	io.WriteCloser
+	expectedSize int64
+	expectedMD5  []byte
+	size         int64
+	hasher       hash.Hash
+}
+
+// NewDecompressedFileVerifier returns a WriteCloser that passes what is written to it on to destination, and whose
+// Close fails if that wasn't what the file that was compressed held, going by its size and MD5 hash. A nil hash
+// isn't checked.
+func NewDecompressedFileVerifier(destination io.WriteCloser, size int64, md5Hash []byte) io.WriteCloser {
+	return &decompressedFileVerifier{WriteCloser: destination, expectedSize: size, expectedMD5: md5Hash, hasher: md5.New()}
+}
+
+func (v *decompressedFileVerifier) Write(p []byte) (int, error) {
+	n, err := v.WriteCloser.Write(p)
+	v.size += int64(n)
+	v.hasher.Write(p[:n])
+	return n, err
+}
+
+func (v *decompressedFileVerifier) Close() error {
+	if err := v.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if v.size != v.expectedSize {
+		return fmt.Errorf("the file decompressed to %d bytes, but was %d bytes when it was compressed", v.size, v.expectedSize)
+	}
+	if v.expectedMD5 != nil && !bytes.Equal(v.hasher.Sum(nil), v.expectedMD5) {
+		return errors.New("the decompressed file's MD5 hash doesn't match the hash of the file that was compressed")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ipVersion is 4 or 6 once --ip-version has restricted connections to one IP version, or 0 for either
+var ipVersion atomic.Int32
+
+// SetIPVersion restricts the connections that are made from then on to IPv4, for "4", or to IPv6, for "6", so that on
+// a dual-stack network whose routes for one of them are broken, each connection doesn't wait for it to time out
+// first. "auto" or "" lets them use either, preferring IPv6 where both work, as Go does.
+func SetIPVersion(version string) error {
+	switch strings.ToLower(version) {
+	case "", "auto":
+		ipVersion.Store(0)
+	case "4", "ipv4":
+		ipVersion.Store(4)
+	case "6", "ipv6":
+		ipVersion.Store(6)
+	default:
+		return fmt.Errorf("'%s' isn't an IP version; use 4, 6 or auto", version)
+	}
+	return nil
+}
+
+// IPVersion is the IP version that connections are restricted to, 4 or 6, or 0 if they may use either
+func IPVersion() int {
+	return int(ipVersion.Load())
+}
+
+// DialingConfigured says whether --source-ip, --interface, --ip-version, --resolve or --sandbox has changed how
+// connections are made, so that a transport that otherwise uses Go's default dialer needs a Dialer
+func DialingConfigured() bool {
+	sources, overrides := sourceAddresses.Load(), hostOverrides.Load()
+	return (sources != nil && len(*sources) > 0) || IPVersion() != 0 || (overrides != nil && len(*overrides) > 0) ||
+		currentSandbox.Load() != nil
+}
+
+// Dialer makes the connections of AzCopy's transports, from the address of --source-ip or --interface, only over the
+// IP version of --ip-version, and to the addresses of --resolve, through Casper once --sandbox has opened a sandbox. Its
+// net.Dialer gives the rest, such as timeouts.
+type Dialer struct {
+	net.Dialer
+}
+
+func NewDialer(d net.Dialer) *Dialer {
+	return &Dialer{Dialer: d}
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := d.Dialer
+	if dialer.LocalAddr == nil {
+		dialer.LocalAddr = SourceAddr()
+	}
+	host, port, err := net.SplitHostPort(address)
+	addrs := overriddenAddresses(host, port)
+	if err != nil || addrs == nil {
+		return dial(ctx, dialer, ipNetwork(network), address)
+	}
+
+	// the host's connections go to the addresses of --resolve instead, in turn until one of them answers
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = dial(ctx, dialer, ipNetwork(network), net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dial makes a connection with dialer, or through the sandbox's Casper service if one has been opened
+func dial(ctx context.Context, dialer net.Dialer, network, address string) (net.Conn, error) {
+	if s := currentSandbox.Load(); s != nil {
+		return s.net.dial(ctx, network, address, dialer)
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// ipNetwork is network narrowed to the IP version of --ip-version, as tcp4 or tcp6 are of tcp, so that only the
+// addresses of that version are looked up and tried
+func ipNetwork(network string) string {
+	version := IPVersion()
+	if version == 0 {
+		return network
+	}
+	switch network {
+	case "tcp", "udp", "ip":
+		return network + strconv.Itoa(version)
+	}
+	return network
+}
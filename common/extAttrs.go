@@ -0,0 +1,67 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+const POSIXExtAttrsMeta = "posix_extattrs"
+
+var ErrExtAttrsNotSupported = errors.New("extended attributes are not supported on this platform")
+
+const (
+	// MaxExtAttrValueSize caps any single attribute we're willing to carry; larger values are dropped and logged.
+	MaxExtAttrValueSize = 1024
+	// MaxExtAttrsMetadataSize keeps the encoded attributes well inside the 8KiB limit on all metadata for a blob.
+	MaxExtAttrsMetadataSize = 4096
+)
+
+// EncodeExtAttrs packs extended attributes, keyed as "<namespace>.<name>", into a single header-safe metadata value.
+// Attributes are considered in name order so that the ones dropped for exceeding the size limits are predictable.
+func EncodeExtAttrs(attrs map[string][]byte) (value string, dropped []string) {
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	kept := make(map[string][]byte, len(attrs))
+	for _, name := range names {
+		if len(attrs[name]) > MaxExtAttrValueSize {
+			dropped = append(dropped, name)
+			continue
+		}
+
+		kept[name] = attrs[name]
+		if encodedExtAttrsLen(kept) > MaxExtAttrsMetadataSize {
+			delete(kept, name)
+			dropped = append(dropped, name)
+		}
+	}
+
+	if len(kept) == 0 {
+		return "", dropped
+	}
+
+	buf, _ := json.Marshal(kept) // []byte values are base64'd by encoding/json
+	return base64.StdEncoding.EncodeToString(buf), dropped
+}
+
+func encodedExtAttrsLen(attrs map[string][]byte) int {
+	buf, _ := json.Marshal(attrs)
+	return base64.StdEncoding.EncodedLen(len(buf))
+}
+
+// DecodeExtAttrs is the inverse of EncodeExtAttrs.
+func DecodeExtAttrs(value string) (map[string][]byte, error) {
+	buf, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]byte)
+	err = json.Unmarshal(buf, &out)
+	return out, err
+}
@@ -0,0 +1,103 @@
+//go:build freebsd
+
+package common
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var extAttrNamespaces = map[int]string{
+	unix.EXTATTR_NAMESPACE_USER:   "user",
+	unix.EXTATTR_NAMESPACE_SYSTEM: "system",
+}
+
+// GetExtAttrs reads the user and system namespace extended attributes of path, keyed as "<namespace>.<name>".
+// The system namespace is only readable by root; for anyone else it is silently left out.
+func GetExtAttrs(path string) (map[string][]byte, error) {
+	out := make(map[string][]byte)
+
+	for ns, prefix := range extAttrNamespaces {
+		names, err := listExtAttrs(path, ns)
+		if err != nil {
+			if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EOPNOTSUPP) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, name := range names {
+			value, err := getExtAttr(path, ns, name)
+			if err != nil {
+				if errors.Is(err, unix.ENOATTR) {
+					continue // removed while we were looking
+				}
+				return nil, err
+			}
+			out[prefix+"."+name] = value
+		}
+	}
+
+	return out, nil
+}
+
+// SetExtAttr writes a single attribute previously read by GetExtAttrs.
+func SetExtAttr(path, key string, value []byte) error {
+	prefix, name, _ := strings.Cut(key, ".")
+	for ns, p := range extAttrNamespaces {
+		if p != prefix {
+			continue
+		}
+
+		var data uintptr
+		if len(value) > 0 {
+			data = uintptr(unsafe.Pointer(&value[0]))
+		}
+		_, err := unix.ExtattrSetFile(path, ns, name, data, len(value))
+		return err
+	}
+	return errors.New("unknown extended attribute namespace in " + key)
+}
+
+// listExtAttrs decodes the list returned by extattr_list_file(2): each name is preceded by a one-byte length.
+func listExtAttrs(path string, ns int) ([]string, error) {
+	size, err := unix.ExtattrListFile(path, ns, 0, 0)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.ExtattrListFile(path, ns, uintptr(unsafe.Pointer(&buf[0])), len(buf))
+	if err != nil {
+		return nil, err
+	}
+	buf = buf[:size]
+
+	var names []string
+	for len(buf) > 0 {
+		l := int(buf[0])
+		if 1+l > len(buf) {
+			break
+		}
+		names = append(names, string(buf[1:1+l]))
+		buf = buf[1+l:]
+	}
+	return names, nil
+}
+
+func getExtAttr(path string, ns int, name string) ([]byte, error) {
+	size, err := unix.ExtattrGetFile(path, ns, name, 0, 0)
+	if err != nil || size == 0 {
+		return []byte{}, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.ExtattrGetFile(path, ns, name, uintptr(unsafe.Pointer(&buf[0])), len(buf))
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
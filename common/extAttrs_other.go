@@ -0,0 +1,11 @@
+//go:build !freebsd
+
+package common
+
+func GetExtAttrs(path string) (map[string][]byte, error) {
+	return nil, ErrExtAttrsNotSupported
+}
+
+func SetExtAttr(path, key string, value []byte) error {
+	return ErrExtAttrsNotSupported
+}
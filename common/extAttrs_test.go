@@ -0,0 +1,45 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtAttrsRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	attrs := map[string][]byte{
+		"user.mime_type":    []byte("text/plain"),
+		"system.empty":      {},
+		"user.binary\x00ok": {0, 1, 2, 0xff},
+	}
+
+	value, dropped := EncodeExtAttrs(attrs)
+	a.Empty(dropped)
+
+	decoded, err := DecodeExtAttrs(value)
+	a.NoError(err)
+	a.Equal(attrs, decoded)
+}
+
+func TestExtAttrsDropsOversizedValues(t *testing.T) {
+	a := assert.New(t)
+
+	attrs := map[string][]byte{
+		"user.huge": bytes.Repeat([]byte{'x'}, MaxExtAttrValueSize+1),
+	}
+	for i := 0; i < 10; i++ {
+		attrs[fmt.Sprintf("user.a%d", i)] = bytes.Repeat([]byte{'y'}, MaxExtAttrValueSize/2)
+	}
+
+	value, dropped := EncodeExtAttrs(attrs)
+	a.Contains(dropped, "user.huge")
+	a.LessOrEqual(len(value), MaxExtAttrsMetadataSize)
+
+	decoded, err := DecodeExtAttrs(value)
+	a.NoError(err)
+	a.Equal(len(attrs)-len(dropped), len(decoded))
+}
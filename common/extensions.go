@@ -174,3 +174,14 @@ func GenerateBlockBlobBlockID(blockNamePrefix string, index int32) string {
 	blockID := []byte(fmt.Sprintf("%s%05d", blockNamePrefix, index))
 	return base64.StdEncoding.EncodeToString(blockID)
 }
+
+// deltaBlockIDMarker starts the names of blocks named by GenerateDeltaBlockID
+const deltaBlockIDMarker = "AZD1"
+
+// GenerateDeltaBlockID names a block by the SHA-256 of its content, so that a block blob that already has the block
+// needn't be sent it again (see --block-delta). The name is as long as GenerateBlockBlobBlockID's, since all the
+// blocks of a blob must have names of the same length.
+// <4B marker><32B SHA-256 of the block>
+func GenerateDeltaBlockID(sha256 []byte) string {
+	return base64.StdEncoding.EncodeToString(append([]byte(deltaBlockIDMarker), sha256...))
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/stretchr/testify/assert"
@@ -167,3 +168,14 @@ func TestBlockblobBlockIDGeneration(t *testing.T) {
 	}
 
 }
+
+func TestDeltaBlockIDGeneration(t *testing.T) {
+	a := assert.New(t)
+	block := sha256.Sum256([]byte("block"))
+	other := sha256.Sum256([]byte("other block"))
+
+	blockName := GenerateDeltaBlockID(block[:])
+	a.Equal(AZCOPY_BLOCKNAME_LENGTH, len(blockName), "a blob's blocks must all have names of the same length")
+	a.Equal(blockName, GenerateDeltaBlockID(block[:]))
+	a.NotEqual(blockName, GenerateDeltaBlockID(other[:]))
+}
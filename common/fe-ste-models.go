@@ -30,6 +30,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -53,8 +54,8 @@ const (
 	//  we decided that the best option was to leave it as is, and only relax it if user feedback so requires.
 	DEFAULT_FILE_PERM = 0644 // the os package will handle base-10 for us.
 
-	// Since we haven't updated the Go SDKs to handle CPK just yet, we need to detect CPK related errors
-	// and inform the user that we don't support CPK yet.
+	// A CPK-encrypted blob accessed without its key fails with this code, so we detect it
+	// and tell the user how to provide the key.
 	CPK_ERROR_SERVICE_CODE    = "BlobUsesCustomerSpecifiedEncryption"
 	FILE_NOT_FOUND            = "The specified file was not found."
 	EINTR_RETRY_COUNT         = 5
@@ -424,6 +425,31 @@ func (ExitCode) Error() ExitCode   { return ExitCode(1) }
 // NoExit is used as a marker, to suppress the normal exit behaviour
 func (ExitCode) NoExit() ExitCode { return ExitCode(99) }
 
+// Interrupted is returned, instead of Error, when a job was shut down cleanly by SIGTERM or SIGINT after its enumeration
+// completed, so that it can be picked up again with "azcopy jobs resume". That only happens when
+// AZCOPY_SHUTDOWN_GRACE_PERIOD is set.
+func (ExitCode) Interrupted() ExitCode { return ExitCode(3) }
+
+// CompletedWithErrors is returned when some of a job's transfers failed, but others were done, so that it is worth
+// resuming the job to retry the rest. Failures that are all of one kind are reported as that kind instead.
+func (ExitCode) CompletedWithErrors() ExitCode { return ExitCode(4) }
+
+// NothingToDo is returned when no files matched, so that there was nothing to copy, remove or set the properties of.
+// Sync still exits with Success when the source and destination are already in sync, since that is what it is for.
+func (ExitCode) NothingToDo() ExitCode { return ExitCode(5) }
+
+// Cancelled is returned when a job was cancelled, by the user or by "azcopy jobs cancel", rather than shut down to
+// be resumed (see Interrupted)
+func (ExitCode) Cancelled() ExitCode { return ExitCode(6) }
+
+// AuthFailure is returned when we couldn't authenticate, or weren't authorized (401 or 403), to the source or the
+// destination
+func (ExitCode) AuthFailure() ExitCode { return ExitCode(7) }
+
+// NetworkFailure is returned when the service couldn't be reached, or every transfer that failed was failed by the
+// network (connections refused, reset or timed out, or names that didn't resolve)
+func (ExitCode) NetworkFailure() ExitCode { return ExitCode(8) }
+
 // //////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 type LogLevel uint8
 
@@ -601,6 +627,8 @@ func (Location) Benchmark() Location { return Location(7) }
 func (Location) GCP() Location       { return Location(8) }
 func (Location) None() Location      { return Location(9) } // None is used in case we're transferring properties
 func (Location) FileNFS() Location   { return Location(10) }
+func (Location) Provider() Location  { return Location(11) } // storage reached through a registered Provider, chosen by URL scheme
+func (Location) URLList() Location   { return Location(12) } // a file listing http(s) URLs, each read by the destination service itself
 
 func (Location) AzureAccount() Location { return Location(100) } // AzureAccount is never used within AzCopy, and won't be detected, (for now)
 
@@ -637,7 +665,7 @@ func FromToValue(from Location, to Location) FromTo {
 
 func (l Location) IsRemote() bool {
 	switch l {
-	case ELocation.BlobFS(), ELocation.Blob(), ELocation.File(), ELocation.S3(), ELocation.GCP(), ELocation.FileNFS():
+	case ELocation.BlobFS(), ELocation.Blob(), ELocation.File(), ELocation.S3(), ELocation.GCP(), ELocation.FileNFS(), ELocation.Provider(), ELocation.URLList():
 		return true
 	case ELocation.Local(), ELocation.Benchmark(), ELocation.Pipe(), ELocation.Unknown(), ELocation.None():
 		return false
@@ -665,7 +693,7 @@ func (l Location) IsFolderAware() bool {
 	switch l {
 	case ELocation.BlobFS(), ELocation.File(), ELocation.Local(), ELocation.FileNFS():
 		return true
-	case ELocation.Blob(), ELocation.S3(), ELocation.GCP(), ELocation.Benchmark(), ELocation.Pipe(), ELocation.Unknown(), ELocation.None():
+	case ELocation.Blob(), ELocation.S3(), ELocation.GCP(), ELocation.Benchmark(), ELocation.Pipe(), ELocation.Unknown(), ELocation.None(), ELocation.Provider(), ELocation.URLList():
 		return false
 	default:
 		panic("unexpected location, please specify if it is folder-aware")
@@ -705,6 +733,7 @@ func (FromTo) Unknown() FromTo { return FromTo(0) }
 
 func (FromTo) LocalBlob() FromTo      { return FromToValue(ELocation.Local(), ELocation.Blob()) }
 func (FromTo) LocalFile() FromTo      { return FromToValue(ELocation.Local(), ELocation.File()) }
+func (FromTo) LocalLocal() FromTo     { return FromToValue(ELocation.Local(), ELocation.Local()) }
 func (FromTo) BlobLocal() FromTo      { return FromToValue(ELocation.Blob(), ELocation.Local()) }
 func (FromTo) FileLocal() FromTo      { return FromToValue(ELocation.File(), ELocation.Local()) }
 func (FromTo) BlobPipe() FromTo       { return FromToValue(ELocation.Blob(), ELocation.Pipe()) }
@@ -738,6 +767,10 @@ func (FromTo) FileSMBLocal() FromTo   { return FromToValue(ELocation.File(), ELo
 func (FromTo) FileSMBFileSMB() FromTo { return FromToValue(ELocation.File(), ELocation.File()) }
 func (FromTo) FileSMBFileNFS() FromTo { return FromToValue(ELocation.File(), ELocation.FileNFS()) }
 func (FromTo) FileNFSFileSMB() FromTo { return FromToValue(ELocation.FileNFS(), ELocation.File()) }
+func (FromTo) LocalProvider() FromTo  { return FromToValue(ELocation.Local(), ELocation.Provider()) }
+func (FromTo) ProviderLocal() FromTo  { return FromToValue(ELocation.Provider(), ELocation.Local()) }
+func (FromTo) ProviderBlob() FromTo   { return FromToValue(ELocation.Provider(), ELocation.Blob()) }
+func (FromTo) URLListBlob() FromTo    { return FromToValue(ELocation.URLList(), ELocation.Blob()) }
 
 // todo: to we really want these?  Starts to look like a bit of a combinatorial explosion
 func (FromTo) BenchmarkBlob() FromTo {
@@ -1149,6 +1182,30 @@ func (hvo *HashValidationOption) UnmarshalJSON(b []byte) error {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+var EContentHashType = ContentHashType(0)
+
+// ContentHashType is the algorithm that files are hashed with by put-md5, and checked with by check-md5. Only MD5 is
+// stored in the Content-MD5 property; the others are stored in metadata.
+type ContentHashType uint8
+
+func (ContentHashType) MD5() ContentHashType    { return ContentHashType(0) }
+func (ContentHashType) SHA256() ContentHashType { return ContentHashType(1) }
+func (ContentHashType) CRC64() ContentHashType  { return ContentHashType(2) }
+
+func (ht ContentHashType) String() string {
+	return enum.StringInt(ht, reflect.TypeOf(ht))
+}
+
+func (ht *ContentHashType) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(ht), s, true, true)
+	if err == nil {
+		*ht = val.(ContentHashType)
+	}
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 var EInvalidMetadataHandleOption = InvalidMetadataHandleOption(0)
 
 var DefaultInvalidMetadataHandleOption = EInvalidMetadataHandleOption.ExcludeIfInvalid()
@@ -1237,6 +1294,8 @@ type CopyTransfer struct {
 	BlobTags BlobTags
 
 	BlobSnapshotID string
+	// the encryption scope the source blob is encrypted with, for it to be preserved
+	BlobEncryptionScope string
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -1591,6 +1650,11 @@ func (HardlinkHandlingType) Follow() HardlinkHandlingType {
 	return HardlinkHandlingType(0)
 }
 
+// Preserve means send the content of each set of hard links once, and link the rest to it at the destination
+func (HardlinkHandlingType) Preserve() HardlinkHandlingType {
+	return HardlinkHandlingType(1)
+}
+
 func (pho HardlinkHandlingType) String() string {
 	return enum.StringInt(pho, reflect.TypeOf(pho))
 }
@@ -1617,6 +1681,70 @@ func (pho *HardlinkHandlingType) UnmarshalJSON(b []byte) error {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+var ESpecialFileHandlingType = SpecialFileHandlingType(0)
+
+var DefaultSpecialFileHandlingType = ESpecialFileHandlingType.Warn()
+
+// SpecialFileHandlingType decides what becomes of FIFOs, sockets and device nodes found while enumerating a local source.
+// None of them have content to transfer, so they are never sent.
+type SpecialFileHandlingType uint8
+
+// Warn means leave the file out, and say so on screen and in the scanning log
+func (SpecialFileHandlingType) Warn() SpecialFileHandlingType {
+	return SpecialFileHandlingType(0)
+}
+
+// Skip means leave the file out quietly. It is still counted and listed in the job summary.
+func (SpecialFileHandlingType) Skip() SpecialFileHandlingType {
+	return SpecialFileHandlingType(1)
+}
+
+// Fail means stop enumerating, and fail the job
+func (SpecialFileHandlingType) Fail() SpecialFileHandlingType {
+	return SpecialFileHandlingType(2)
+}
+
+func (sfh SpecialFileHandlingType) String() string {
+	return enum.StringInt(sfh, reflect.TypeOf(sfh))
+}
+
+func (sfh *SpecialFileHandlingType) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(sfh), s, true, true)
+	if err == nil {
+		*sfh = val.(SpecialFileHandlingType)
+	}
+	return err
+}
+
+// SpecialFileKind names the kind of special file mode describes, or returns "" for regular files, folders and symlinks.
+func SpecialFileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "block device"
+	case mode&os.ModeIrregular != 0:
+		return "irregular file"
+	default:
+		return ""
+	}
+}
+
+// MaxListedSpecialFiles caps how many skipped special files a job summary names; all of them are counted
+const MaxListedSpecialFiles = 1000
+
+// SkippedSpecialFile is a special file that was left out of a job
+type SkippedSpecialFile struct {
+	Path string
+	Kind string
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 type PerformanceAdvice struct {
 
 	// Code representing the type of the advice
@@ -1695,6 +1823,36 @@ func GetCompressionType(contentEncoding string) (CompressionType, error) {
 
 /////////////////////////////////////////////////////////////////
 
+var EArchiveFormat = ArchiveFormat(0)
+
+type ArchiveFormat uint8
+
+func (ArchiveFormat) None() ArchiveFormat    { return ArchiveFormat(0) }
+func (ArchiveFormat) Tar() ArchiveFormat     { return ArchiveFormat(1) }
+func (ArchiveFormat) TarGzip() ArchiveFormat { return ArchiveFormat(2) }
+func (ArchiveFormat) Zip() ArchiveFormat     { return ArchiveFormat(3) }
+
+func (af ArchiveFormat) String() string {
+	return enum.StringInt(af, reflect.TypeOf(af))
+}
+
+// GetArchiveFormat tells the format of an archive from its name, or returns None if it isn't the name of one
+func GetArchiveFormat(name string) ArchiveFormat {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return EArchiveFormat.Tar()
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return EArchiveFormat.TarGzip()
+	case strings.HasSuffix(name, ".zip"):
+		return EArchiveFormat.Zip()
+	default:
+		return EArchiveFormat.None()
+	}
+}
+
+/////////////////////////////////////////////////////////////////
+
 var EEntityType = EntityType(0)
 
 type EntityType uint8
@@ -1801,7 +1959,7 @@ type CpkOptions struct {
 	// Provided key name will be fetched from Azure Key Vault and will be used to encrypt the data
 	CpkScopeInfo string
 	// flag to check if the source is encrypted by user provided key or not.
-	// True only if user wishes to download source encrypted by user provided key
+	// True only if user wishes to download, list, delete or set properties of a source encrypted by user provided key
 	IsSourceEncrypted bool
 }
 
@@ -1901,7 +2059,7 @@ func (ht SyncHashType) String() string {
 }
 
 // //////////////////////////////////////////////////////////////////////////////
-type SymlinkHandlingType uint8 // SymlinkHandlingType is only utilized internally to avoid having to carry around two contradictory flags. Thus, it doesn't have a parse method.
+type SymlinkHandlingType uint8 // SymlinkHandlingType replaces two contradictory flags. Its None/Follow/Preserve methods clash with the usual enum pattern, so it parses itself.
 
 // for reviewers: This is different than we usually implement enums, but it's something I've found to be more pleasant in personal projects, especially for bitflags. Should we change the pattern to match this in the future?
 
@@ -1917,10 +2075,46 @@ func (sht SymlinkHandlingType) None() bool     { return sht == 0 }
 func (sht SymlinkHandlingType) Follow() bool   { return sht == 1 }
 func (sht SymlinkHandlingType) Preserve() bool { return sht == 2 }
 
-func (sht *SymlinkHandlingType) Determine(Follow, Preserve bool) error {
+func (sht SymlinkHandlingType) String() string {
+	switch sht {
+	case ESymlinkHandlingType.Skip():
+		return "skip"
+	case ESymlinkHandlingType.Follow():
+		return "follow"
+	case ESymlinkHandlingType.Preserve():
+		return "preserve"
+	default:
+		return strconv.Itoa(int(sht))
+	}
+}
+
+func (sht *SymlinkHandlingType) Parse(s string) error {
+	switch strings.ToLower(s) {
+	case "skip":
+		*sht = ESymlinkHandlingType.Skip()
+	case "follow":
+		*sht = ESymlinkHandlingType.Follow()
+	case "preserve":
+		*sht = ESymlinkHandlingType.Preserve()
+	default:
+		return fmt.Errorf("invalid symlink handling mode %q; expected skip, follow or preserve", s)
+	}
+	return nil
+}
+
+// Determine settles the mode from --symlinks, or failing that the older --follow-symlinks and --preserve-symlinks.
+// An empty mode means --symlinks wasn't given.
+func (sht *SymlinkHandlingType) Determine(Follow, Preserve bool, mode string) error {
 	switch {
 	case Follow && Preserve:
 		return errors.New("cannot both follow and preserve symlinks (--preserve-symlinks and --follow-symlinks contradict)")
+	case mode != "":
+		if err := sht.Parse(mode); err != nil {
+			return err
+		}
+		if (Follow && !sht.Follow()) || (Preserve && !sht.Preserve()) {
+			return fmt.Errorf("--symlinks=%s contradicts --follow-symlinks or --preserve-symlinks", sht.String())
+		}
 	case Preserve:
 		*sht = ESymlinkHandlingType.Preserve()
 	case Follow:
@@ -1930,6 +2124,186 @@ func (sht *SymlinkHandlingType) Determine(Follow, Preserve bool) error {
 	return nil
 }
 
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ETransferOrder = TransferOrder(0)
+
+// TransferOrder is the order in which a job's transfers are started
+type TransferOrder uint8
+
+// AsScanned means start each transfer as soon as it's found, in no particular order
+func (TransferOrder) AsScanned() TransferOrder {
+	return TransferOrder(0)
+}
+
+// SmallestFirst means wait until scanning has finished, and then start the smallest files first
+func (TransferOrder) SmallestFirst() TransferOrder {
+	return TransferOrder(1)
+}
+
+// LargestFirst means wait until scanning has finished, and then start the largest files first
+func (TransferOrder) LargestFirst() TransferOrder {
+	return TransferOrder(2)
+}
+
+func (to TransferOrder) String() string {
+	return enum.StringInt(to, reflect.TypeOf(to))
+}
+
+// Parse takes the names with or without their hyphens, as in smallest-first or SmallestFirst
+func (to *TransferOrder) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(to), strings.ReplaceAll(s, "-", ""), true, true)
+	if err == nil {
+		*to = val.(TransferOrder)
+	}
+	return err
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ETraversalOrder = TraversalOrder(0)
+
+// TraversalOrder is the order in which a scan reads the directories it finds
+type TraversalOrder uint8
+
+// Auto means breadth first, which spreads transfers across the tree, until very many directories are waiting to be
+// read, and then depth first, to save memory
+func (TraversalOrder) Auto() TraversalOrder {
+	return TraversalOrder(0)
+}
+
+// BreadthFirst means each level of the tree is read before the one below it
+func (TraversalOrder) BreadthFirst() TraversalOrder {
+	return TraversalOrder(1)
+}
+
+// DepthFirst means a subdirectory, and everything in it, is read before its siblings
+func (TraversalOrder) DepthFirst() TraversalOrder {
+	return TraversalOrder(2)
+}
+
+func (to TraversalOrder) String() string {
+	return enum.StringInt(to, reflect.TypeOf(to))
+}
+
+// Parse takes the names with or without their hyphens, as in depth-first or DepthFirst
+func (to *TraversalOrder) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(to), strings.ReplaceAll(s, "-", ""), true, true)
+	if err == nil {
+		*to = val.(TraversalOrder)
+	}
+	return err
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ESyncStateCache = SyncStateCache(0)
+
+// SyncStateCache is whether sync keeps a record of the state it leaves the destination of an upload in, so that the
+// next sync can compare the source with that instead of scanning the destination
+type SyncStateCache uint8
+
+// None means the destination is scanned, and no record is kept
+func (SyncStateCache) None() SyncStateCache {
+	return SyncStateCache(0)
+}
+
+// Use means the record is compared with in place of the destination, if there is one, and is brought up to date
+func (SyncStateCache) Use() SyncStateCache {
+	return SyncStateCache(1)
+}
+
+// Refresh means the destination is scanned, and the record is made again from what's found
+func (SyncStateCache) Refresh() SyncStateCache {
+	return SyncStateCache(2)
+}
+
+func (c SyncStateCache) String() string {
+	return enum.StringInt(c, reflect.TypeOf(c))
+}
+
+func (c *SyncStateCache) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(c), s, true, true)
+	if err == nil {
+		*c = val.(SyncStateCache)
+	}
+	return err
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var EFsyncMode = FsyncMode(0)
+
+// FsyncMode is when downloaded files are synced to disk. Each mode syncs at least as often as those before it.
+type FsyncMode uint8
+
+// Off means files are never synced, and the operating system writes them out in its own time
+func (FsyncMode) Off() FsyncMode {
+	return FsyncMode(0)
+}
+
+// PerFile means each file is synced once it's complete, and its directory once it's renamed into place
+func (FsyncMode) PerFile() FsyncMode {
+	return FsyncMode(1)
+}
+
+// Interval means a file is also synced every so often while it's written, so that no more than that much of it is
+// lost if the machine goes down
+func (FsyncMode) Interval() FsyncMode {
+	return FsyncMode(2)
+}
+
+// PerChunk means a file is also synced after each chunk is written
+func (FsyncMode) PerChunk() FsyncMode {
+	return FsyncMode(3)
+}
+
+func (m FsyncMode) String() string {
+	return enum.StringInt(m, reflect.TypeOf(m))
+}
+
+// FsyncPolicy is --fsync: when downloaded files, and the directories they are saved in, are synced to disk
+type FsyncPolicy struct {
+	Mode FsyncMode
+	// how often a file is synced while it's written, with EFsyncMode.Interval()
+	Interval time.Duration
+}
+
+// Parse takes per-file, per-chunk, interval:N, where N is a number of seconds, or off. The modes may be given
+// without their hyphens, as in PerFile.
+func (p *FsyncPolicy) Parse(s string) error {
+	name, seconds, hasInterval := strings.Cut(s, ":")
+	val, err := enum.ParseInt(reflect.TypeOf(&p.Mode), strings.ReplaceAll(name, "-", ""), true, true)
+	if err != nil {
+		return err
+	}
+	policy := FsyncPolicy{Mode: val.(FsyncMode)}
+	if hasInterval != (policy.Mode == EFsyncMode.Interval()) {
+		return fmt.Errorf("'%s' isn't an fsync policy: give a number of seconds with interval, as in interval:30, and with nothing else", s)
+	}
+	if hasInterval {
+		n, err := strconv.ParseUint(seconds, 10, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("'%s' isn't a number of seconds, for interval:N", seconds)
+		}
+		policy.Interval = time.Duration(n) * time.Second
+	}
+	*p = policy
+	return nil
+}
+
+func (p FsyncPolicy) String() string {
+	switch p.Mode {
+	case EFsyncMode.PerFile():
+		return "per-file"
+	case EFsyncMode.PerChunk():
+		return "per-chunk"
+	case EFsyncMode.Interval():
+		return fmt.Sprintf("interval:%d", int64(p.Interval/time.Second))
+	}
+	return "off"
+}
+
 // /////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 var oncer = sync.Once{}
@@ -24,6 +24,7 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestEnhanceJobStatusInfo(t *testing.T) {
@@ -185,3 +186,44 @@ func TestMetadataResolveInvalidKeyNegative(t *testing.T) {
 	_, err = mNegative3.ResolveInvalidKey()
 	a.NotNil(err)
 }
+
+func TestSymlinkHandlingDetermine(t *testing.T) {
+	a := assert.New(t)
+
+	var sht common.SymlinkHandlingType
+	a.NoError(sht.Determine(false, false, ""))
+	a.Equal(common.ESymlinkHandlingType.Skip(), sht)
+
+	a.NoError(sht.Determine(true, false, ""))
+	a.Equal(common.ESymlinkHandlingType.Follow(), sht)
+
+	a.NoError(sht.Determine(false, false, "PRESERVE"))
+	a.Equal(common.ESymlinkHandlingType.Preserve(), sht)
+	a.Equal("preserve", sht.String())
+
+	// the older flags may accompany --symlinks, but only when they agree with it
+	a.NoError(sht.Determine(true, false, "follow"))
+	a.Equal(common.ESymlinkHandlingType.Follow(), sht)
+	a.Error(sht.Determine(false, true, "skip"))
+	a.Error(sht.Determine(true, true, ""))
+	a.Error(sht.Determine(false, false, "dereference"))
+}
+
+func TestFsyncPolicyParse(t *testing.T) {
+	a := assert.New(t)
+
+	var p common.FsyncPolicy
+	a.NoError(p.Parse("per-file"))
+	a.Equal(common.FsyncPolicy{Mode: common.EFsyncMode.PerFile()}, p)
+	a.NoError(p.Parse("PerChunk"))
+	a.Equal("per-chunk", p.String())
+	a.NoError(p.Parse("interval:30"))
+	a.Equal(common.FsyncPolicy{Mode: common.EFsyncMode.Interval(), Interval: 30 * time.Second}, p)
+	a.Equal("interval:30", p.String())
+	a.NoError(p.Parse("off"))
+	a.Equal(common.FsyncPolicy{}, p)
+
+	for _, bad := range []string{"interval", "interval:0", "interval:soon", "per-file:30", "always"} {
+		a.Error(p.Parse(bad), bad)
+	}
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const POSIXFileFlagsMeta = "posix_fileflags" // st_flags of the file or folder, as a chflags(1) style list
+
+var ErrFileFlagsNotSupported = errors.New("file flags are not supported on this platform")
+
+// FileFlags mirrors st_flags from sys/stat.h. The UF_* flags may be set by the owner; the SF_* flags only by root,
+// and then only at securelevel 0.
+type FileFlags uint32
+
+const (
+	UF_NODUMP    FileFlags = 0x00000001
+	UF_IMMUTABLE FileFlags = 0x00000002
+	UF_APPEND    FileFlags = 0x00000004
+	UF_OPAQUE    FileFlags = 0x00000008
+	UF_NOUNLINK  FileFlags = 0x00000010
+	UF_SYSTEM    FileFlags = 0x00000080
+	UF_SPARSE    FileFlags = 0x00000100
+	UF_OFFLINE   FileFlags = 0x00000200
+	UF_REPARSE   FileFlags = 0x00000400
+	UF_ARCHIVE   FileFlags = 0x00000800
+	UF_READONLY  FileFlags = 0x00001000
+	UF_HIDDEN    FileFlags = 0x00008000
+	SF_ARCHIVED  FileFlags = 0x00010000
+	SF_IMMUTABLE FileFlags = 0x00020000
+	SF_APPEND    FileFlags = 0x00040000
+	SF_NOUNLINK  FileFlags = 0x00100000
+	SF_SNAPSHOT  FileFlags = 0x00200000
+
+	SF_SETTABLE FileFlags = 0xffff0000
+
+	// FileFlagsBlockingWrites are the flags that stop anything else being done to a file once they're set.
+	FileFlagsBlockingWrites = UF_IMMUTABLE | UF_APPEND | UF_NOUNLINK | SF_IMMUTABLE | SF_APPEND | SF_NOUNLINK
+)
+
+// names as printed by fflagstostr(3), so that the metadata reads the same as `ls -lo`
+var fileFlagNames = []struct {
+	flag FileFlags
+	name string
+}{
+	{SF_APPEND, "sappnd"},
+	{SF_ARCHIVED, "arch"},
+	{SF_IMMUTABLE, "schg"},
+	{SF_NOUNLINK, "sunlnk"},
+	{SF_SNAPSHOT, "snapshot"},
+	{UF_APPEND, "uappnd"},
+	{UF_ARCHIVE, "uarch"},
+	{UF_IMMUTABLE, "uchg"},
+	{UF_NODUMP, "nodump"},
+	{UF_OPAQUE, "opaque"},
+	{UF_OFFLINE, "offline"},
+	{UF_READONLY, "rdonly"},
+	{UF_REPARSE, "reparse"},
+	{UF_SPARSE, "sparse"},
+	{UF_SYSTEM, "system"},
+	{UF_HIDDEN, "hidden"},
+	{UF_NOUNLINK, "uunlnk"},
+}
+
+// String lists the flags by name, comma separated. Bits without a name are kept as a trailing hex number.
+func (f FileFlags) String() string {
+	var names []string
+	for _, n := range fileFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(f)))
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseFileFlags is the inverse of FileFlags.String.
+func ParseFileFlags(s string) (FileFlags, error) {
+	var out FileFlags
+	if s == "" {
+		return 0, nil
+	}
+
+next:
+	for _, name := range strings.Split(s, ",") {
+		for _, n := range fileFlagNames {
+			if n.name == name {
+				out |= n.flag
+				continue next
+			}
+		}
+		if strings.HasPrefix(name, "0x") {
+			v, err := strconv.ParseUint(name[2:], 16, 32)
+			if err == nil {
+				out |= FileFlags(v)
+				continue
+			}
+		}
+		return 0, fmt.Errorf("unknown file flag %q", name)
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+//go:build freebsd
+
+package common
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetFileFlags returns st_flags for path, without following a final symlink.
+func GetFileFlags(path string) (FileFlags, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return 0, err
+	}
+	return FileFlags(st.Flags), nil
+}
+
+// FileFlagsOf returns the st_flags already captured in fileInfo, so that enumeration needn't stat again.
+func FileFlagsOf(fileInfo os.FileInfo) (FileFlags, bool) {
+	st, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return FileFlags(st.Flags), true
+}
+
+// SetFileFlags replaces the flags of path. Clearing or setting SF_* flags fails with EPERM unless we are root.
+func SetFileFlags(path string, flags FileFlags) error {
+	return unix.Chflags(path, int(flags))
+}
@@ -0,0 +1,17 @@
+//go:build !freebsd
+
+package common
+
+import "os"
+
+func GetFileFlags(path string) (FileFlags, error) {
+	return 0, ErrFileFlagsNotSupported
+}
+
+func SetFileFlags(path string, flags FileFlags) error {
+	return ErrFileFlagsNotSupported
+}
+
+func FileFlagsOf(fileInfo os.FileInfo) (FileFlags, bool) {
+	return 0, false
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileFlagsRoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	flags := UF_NODUMP | UF_IMMUTABLE | SF_APPEND | FileFlags(0x40000000)
+	s := flags.String()
+	a.Equal("sappnd,uchg,nodump,0x40000000", s)
+
+	parsed, err := ParseFileFlags(s)
+	a.NoError(err)
+	a.Equal(flags, parsed)
+
+	parsed, err = ParseFileFlags("")
+	a.NoError(err)
+	a.Zero(parsed)
+
+	_, err = ParseFileFlags("uchg,bogus")
+	a.Error(err)
+}
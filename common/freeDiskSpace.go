@@ -0,0 +1,25 @@
+package common
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+)
+
+// FreeDiskSpace returns how many bytes may still be written, without privileges, to the file system that path is on,
+// or would be on. If path doesn't exist yet, as is usual for a download's destination, the nearest directory above it
+// that does is asked instead.
+func FreeDiskSpace(path string) (uint64, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		free, err := freeDiskSpace(path)
+		parent := filepath.Dir(path)
+		if !errors.Is(err, fs.ErrNotExist) || parent == path {
+			return free, err
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package common
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func freeDiskSpace(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// Bavail, rather than Bfree, leaves out the blocks that only root may use
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"os"
+	"runtime"
+)
+
+// SyncFile syncs the data of the file at path to disk. It's opened again for the purpose, so that it needn't be the
+// *os.File it was written through, which may be wrapped in a decompressing or decrypting writer.
+func SyncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0) // Windows won't flush a handle that can't write
+	if err != nil {
+		return err
+	}
+	err = Fdatasync(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// SyncDirectory syncs a directory to disk, so that the names of files just created or renamed in it are kept if
+// the machine goes down. Windows can't sync a directory, and doesn't need to, since NTFS journals the names.
+func SyncDirectory(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
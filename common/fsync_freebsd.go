@@ -0,0 +1,19 @@
+//go:build freebsd
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Fdatasync syncs the data of f to disk, along with only as much of its metadata as is needed to read it back.
+// x/sys/unix has no wrapper for fdatasync(2) on FreeBSD, where it arrived in 11.1.
+func Fdatasync(f *os.File) error {
+	_, _, errno := unix.Syscall(unix.SYS_FDATASYNC, f.Fd(), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
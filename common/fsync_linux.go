@@ -0,0 +1,14 @@
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Fdatasync syncs the data of f to disk, along with only as much of its metadata as is needed to read it back
+func Fdatasync(f *os.File) error {
+	return unix.Fdatasync(int(f.Fd()))
+}
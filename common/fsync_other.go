@@ -0,0 +1,12 @@
+//go:build !freebsd && !linux
+
+package common
+
+import (
+	"os"
+)
+
+// Fdatasync syncs f to disk. There's no fdatasync here, so its metadata is synced too.
+func Fdatasync(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// POSIXHardlinkMeta marks a blob standing in for a hard link. Its value is the path of the linked file,
+// relative to the directory holding the link, so it survives the tree being downloaded somewhere else.
+const POSIXHardlinkMeta = "posix_hardlink"
+
+// EncodeHardlinkTarget returns the metadata value for a link at linkPath to target. Both are relative to the same root
+// and use / as separator. Metadata values must be ASCII, hence the escaping.
+func EncodeHardlinkTarget(linkPath, target string) (string, error) {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(linkPath)), filepath.FromSlash(target))
+	if err != nil {
+		return "", err
+	}
+	return url.PathEscape(filepath.ToSlash(rel)), nil
+}
+
+// DecodeHardlinkTarget is the inverse of EncodeHardlinkTarget, returning the target relative to the link's directory.
+// The value comes from whoever wrote the blob, so absolute targets are refused.
+func DecodeHardlinkTarget(value string) (string, error) {
+	rel, err := url.PathUnescape(value)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.FromSlash(rel)
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" || strings.HasPrefix(rel, string(filepath.Separator)) {
+		return "", errors.New("hard link targets must be relative")
+	}
+	return rel, nil
+}
+
+// ResolveHardlinkTarget returns the path that the link at linkPath, under root, refers to with the given POSIXHardlinkMeta
+// value. Targets outside root are refused, so that a blob can't have a download link to, or copy, any file the user can read.
+func ResolveHardlinkTarget(root, linkPath, value string) (string, error) {
+	rel, err := DecodeHardlinkTarget(value)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(filepath.Dir(linkPath), rel)
+	if !IsPathWithin(root, target) {
+		return "", fmt.Errorf("hard link target %s is outside the destination %s", target, root)
+	}
+	return target, nil
+}
+
+// IsPathWithin reports whether p is root or lies under it, going by the cleaned paths alone.
+func IsPathWithin(root, p string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(p))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HnsSuperuser is the owner ADLS Gen2 reports for paths created by callers that have no identity of their own,
+// such as shared key and SAS clients.
+const HnsSuperuser = "$superuser"
+
+// S_ISVTX is the sticky bit; it is the only one of the setuid/setgid/sticky bits that ADLS Gen2 keeps.
+const S_ISVTX = 0x200
+
+// FormatHnsPermissions renders the permission bits of mode for x-ms-permissions, in the four digit octal form.
+func FormatHnsPermissions(mode uint32) string {
+	return fmt.Sprintf("%04o", mode&(S_ISVTX|0777))
+}
+
+// ParseHnsPermissions reads x-ms-permissions as returned by GetAccessControl, e.g. "rwxr-x---" or "rwxrwxrwT+".
+// A trailing + just says there is an ACL, which isn't part of the mode.
+func ParseHnsPermissions(permissions string) (uint32, error) {
+	if len(permissions) == 4 { // octal, as we send it
+		mode, err := strconv.ParseUint(permissions, 8, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid permissions %q: %w", permissions, err)
+		}
+		return uint32(mode) & (S_ISVTX | 0777), nil
+	}
+
+	symbolic := strings.TrimSuffix(permissions, "+")
+	if len(symbolic) != 9 {
+		return 0, fmt.Errorf("invalid permissions %q", permissions)
+	}
+
+	var mode uint32
+	for i := 0; i < len(symbolic); i++ {
+		c := symbolic[i]
+		bit := uint32(1) << (8 - i)
+		switch {
+		case c == "rwx"[i%3]:
+			mode |= bit
+		case i == 8 && c == 't': // sticky and other-execute
+			mode |= bit | S_ISVTX
+		case i == 8 && c == 'T': // sticky only
+			mode |= S_ISVTX
+		case c != '-':
+			return 0, fmt.Errorf("invalid permissions %q", permissions)
+		}
+	}
+	return mode, nil
+}
+
+// ParseHnsOwner returns the numeric ID in an x-ms-owner or x-ms-group value. Accounts with NFS v3 enabled use
+// UIDs and GIDs; other accounts hold Entra object IDs, which have no local equivalent.
+func ParseHnsOwner(owner string) (uint32, bool) {
+	id, err := strconv.ParseUint(owner, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(id), true
+}
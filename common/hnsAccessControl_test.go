@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatHnsPermissions(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("0755", FormatHnsPermissions(S_IFDIR|0755))
+	a.Equal("1777", FormatHnsPermissions(S_IFDIR|S_ISVTX|0777))
+	a.Equal("0644", FormatHnsPermissions(0x800|0644)) // setuid can't be stored
+}
+
+func TestParseHnsPermissions(t *testing.T) {
+	a := assert.New(t)
+	for in, want := range map[string]uint32{
+		"rwxr-x---":  0750,
+		"rw-r--r--+": 0644,
+		"rwxrwxrwt":  S_ISVTX | 0777,
+		"rwxrwxrwT":  S_ISVTX | 0776,
+		"1777":       S_ISVTX | 0777,
+		"0640":       0640,
+	} {
+		mode, err := ParseHnsPermissions(in)
+		a.NoError(err, in)
+		a.Equal(want, mode, in)
+	}
+
+	for _, in := range []string{"", "rwxr-x", "rwxr-xr-q", "0x12", "wrxr-xr-x"} {
+		_, err := ParseHnsPermissions(in)
+		a.Error(err, in)
+	}
+}
+
+func TestParseHnsOwner(t *testing.T) {
+	a := assert.New(t)
+	id, ok := ParseHnsOwner("1001")
+	a.True(ok)
+	a.Equal(uint32(1001), id)
+
+	_, ok = ParseHnsOwner(HnsSuperuser)
+	a.False(ok)
+	_, ok = ParseHnsOwner("5f5a0a36-23b4-4bb5-9d3a-1c9e1e3f0e2a")
+	a.False(ok)
+}
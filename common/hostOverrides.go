@@ -0,0 +1,101 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// hostOverride sends the connections to a host, on one port or all of them, to addresses that are given instead of
+// those that DNS gives
+type hostOverride struct {
+	host  string // lower case
+	port  string // empty for any port
+	addrs []string
+}
+
+var hostOverrides atomic.Pointer[[]hostOverride]
+
+// SetHostOverrides makes the connections that are made from then on to the hosts in overrides go to the addresses
+// given for them, whatever DNS says, as curl's --resolve does, so that a private endpoint can be reached where
+// split-horizon DNS gives its public address. Each override is host:addr, for any port, or host:port:addr, and may
+// give several addresses, separated by commas, which are tried in turn. IPv6 addresses are in brackets, as in
+// myaccount.blob.core.windows.net:[fd00::5]. Certificates are still checked against the host, not the address.
+func SetHostOverrides(overrides []string) error {
+	var parsed []hostOverride
+	for _, text := range overrides {
+		o, err := parseHostOverride(text)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, o)
+	}
+	hostOverrides.Store(&parsed)
+	return nil
+}
+
+func parseHostOverride(text string) (hostOverride, error) {
+	invalid := fmt.Errorf("'%s' isn't host:address or host:port:address", text)
+	host, rest, ok := strings.Cut(strings.TrimSpace(text), ":")
+	if !ok || host == "" || rest == "" {
+		return hostOverride{}, invalid
+	}
+	o := hostOverride{host: strings.ToLower(host)}
+	// a port is all digits, where an address isn't, unless it's an IPv6 one without its brackets
+	first, _, _ := strings.Cut(rest, ",")
+	if port, addrs, ok := strings.Cut(rest, ":"); ok && port != "" && strings.Trim(port, "0123456789") == "" && net.ParseIP(first) == nil {
+		o.port, rest = port, addrs
+	}
+	for _, a := range strings.Split(rest, ",") {
+		a = strings.TrimSpace(a)
+		if strings.HasPrefix(a, "[") && strings.HasSuffix(a, "]") {
+			a = a[1 : len(a)-1]
+		}
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return hostOverride{}, fmt.Errorf("%w: '%s' isn't an IP address", invalid, a)
+		}
+		o.addrs = append(o.addrs, ip.String())
+	}
+	return o, nil
+}
+
+// overriddenAddresses are the addresses that connections to host:port go to instead of the host's own, or nil if
+// there's no override for it. An override for the port comes before one for any port.
+func overriddenAddresses(host, port string) []string {
+	overrides := hostOverrides.Load()
+	if overrides == nil {
+		return nil
+	}
+	var anyPort []string
+	for _, o := range *overrides {
+		if !strings.EqualFold(o.host, host) {
+			continue
+		}
+		if o.port == port {
+			return o.addrs
+		}
+		if o.port == "" && anyPort == nil {
+			anyPort = o.addrs
+		}
+	}
+	return anyPort
+}
+
+// HostOverridesDescription lists the overrides of SetHostOverrides, for the job log, or is empty if there are none
+func HostOverridesDescription() string {
+	overrides := hostOverrides.Load()
+	if overrides == nil {
+		return ""
+	}
+	var parts []string
+	for _, o := range *overrides {
+		host := o.host
+		if o.port != "" {
+			host += ":" + o.port
+		}
+		parts = append(parts, fmt.Sprintf("%s to %s", host, strings.Join(o.addrs, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const IDMapFileFlagName = "idmap-file"
+
+// IDMap translates the owners and groups recorded on the host a backup was taken from into those of the host
+// it is restored on, for --idmap-file. Anything it has no entry for is left as it was recorded.
+type IDMap struct {
+	users  map[string]uint32
+	groups map[string]uint32
+}
+
+// idMap applies to every download that restores ownership. Like cacheBypass, it's about this machine, not any one transfer.
+var idMap *IDMap
+
+// SetIDMapFile loads the mapping in path, or removes any mapping if path is empty.
+func SetIDMapFile(path string) error {
+	if path == "" {
+		idMap = nil
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := ParseIDMap(f, lookupUID, lookupGID)
+	if err != nil {
+		return fmt.Errorf("invalid --%s %s: %w", IDMapFileFlagName, path, err)
+	}
+	idMap = m
+	return nil
+}
+
+// ParseIDMap reads a mapping made of lines like
+//
+//	user  1001   alice
+//	group wheel  0
+//
+// The second field is the owner or group as the source recorded it: a uid or gid for POSIX properties in metadata,
+// or whatever x-ms-owner or x-ms-group holds on accounts with a hierarchical namespace, such as an Entra object ID.
+// The third is the local uid or gid, or a name to look up with lookupUser or lookupGroup.
+// Blank lines and anything after a # are ignored.
+func ParseIDMap(r io.Reader, lookupUser, lookupGroup func(name string) (uint32, error)) (*IDMap, error) {
+	m := &IDMap{users: map[string]uint32{}, groups: map[string]uint32{}}
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 'user' or 'group', then the source ID, then the local ID", lineNo)
+		}
+
+		var ids map[string]uint32
+		var lookup func(string) (uint32, error)
+		switch strings.ToLower(fields[0]) {
+		case "user", "uid":
+			ids, lookup = m.users, lookupUser
+		case "group", "gid":
+			ids, lookup = m.groups, lookupGroup
+		default:
+			return nil, fmt.Errorf("line %d: unknown mapping type %q", lineNo, fields[0])
+		}
+
+		if _, exists := ids[fields[1]]; exists {
+			return nil, fmt.Errorf("line %d: %s %s is mapped more than once", lineNo, fields[0], fields[1])
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			local, err := lookup(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			id = uint64(local)
+		}
+		ids[fields[1]] = uint32(id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func lookupUID(name string) (uint32, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(id), err
+}
+
+func lookupGID(name string) (uint32, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	return uint32(id), err
+}
+
+// User returns the local uid for an owner recorded at the source. Owners without an entry keep their
+// ID if they have one; ok is false if they don't, since there's no local equivalent.
+func (m *IDMap) User(source string) (id uint32, ok bool) {
+	if m != nil {
+		if id, ok = m.users[source]; ok {
+			return id, true
+		}
+	}
+	return ParseHnsOwner(source)
+}
+
+// Group is User for groups.
+func (m *IDMap) Group(source string) (id uint32, ok bool) {
+	if m != nil {
+		if id, ok = m.groups[source]; ok {
+			return id, true
+		}
+	}
+	return ParseHnsOwner(source)
+}
+
+// MapOwner translates an owner recorded at the source with the --idmap-file in effect, if any.
+func MapOwner(source string) (uint32, bool) {
+	return idMap.User(source)
+}
+
+// MapGroup translates a group recorded at the source with the --idmap-file in effect, if any.
+func MapGroup(source string) (uint32, bool) {
+	return idMap.Group(source)
+}
+
+// MapUID is MapOwner for a numeric uid.
+func MapUID(uid uint32) uint32 {
+	id, _ := MapOwner(strconv.FormatUint(uint64(uid), 10))
+	return id
+}
+
+// MapGID is MapGroup for a numeric gid.
+func MapGID(gid uint32) uint32 {
+	id, _ := MapGroup(strconv.FormatUint(uint64(gid), 10))
+	return id
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIDMap(t *testing.T) {
+	a := assert.New(t)
+	lookup := func(names map[string]uint32) func(string) (uint32, error) {
+		return func(name string) (uint32, error) {
+			if id, ok := names[name]; ok {
+				return id, nil
+			}
+			return 0, errors.New("unknown " + name)
+		}
+	}
+	users := lookup(map[string]uint32{"alice": 1500})
+	groups := lookup(map[string]uint32{"staff": 20})
+
+	m, err := ParseIDMap(strings.NewReader(`
+# from the old file server
+user 1001 alice
+user  1002  2002   # trailing comment
+group 0 staff
+gid 3f2504e0-4f89-11d3-9a0c-0305e82c3301 7
+`), users, groups)
+	a.NoError(err)
+
+	id, ok := m.User("1001")
+	a.True(ok)
+	a.EqualValues(1500, id)
+	id, _ = m.User("1002")
+	a.EqualValues(2002, id)
+	id, _ = m.Group("0")
+	a.EqualValues(20, id)
+	id, _ = m.Group("3f2504e0-4f89-11d3-9a0c-0305e82c3301")
+	a.EqualValues(7, id)
+
+	// unmapped IDs are kept; unmapped names can't be
+	id, ok = m.User("0")
+	a.True(ok)
+	a.EqualValues(0, id)
+	_, ok = m.User("3f2504e0-4f89-11d3-9a0c-0305e82c3301")
+	a.False(ok)
+
+	var none *IDMap
+	id, ok = none.Group("42")
+	a.True(ok)
+	a.EqualValues(42, id)
+
+	for _, bad := range []string{"user 1001", "owner 1 2", "user 1 bob", "user 1 2\nuser 1 3"} {
+		_, err = ParseIDMap(strings.NewReader(bad), users, groups)
+		a.Error(err, bad)
+	}
+}
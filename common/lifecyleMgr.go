@@ -57,6 +57,7 @@ type LifecycleMgr interface {
 	Dryrun(OutputBuilder)                                        // print files for dry run mode
 	Output(OutputBuilder, OutputMessageType)                     // print output for list
 	Error(string)                                                // indicates fatal error, exit after printing, exit code is always Failed (1)
+	ErrorWithExitCode(string, ExitCode)                          // Error, with a more specific exit code
 	Prompt(message string, details PromptDetails) ResponseOption // ask the user a question(after erasing the progress), then return the response
 	SurrenderControl()                                           // give up control, this should never return
 	InitiateProgressReporting(WorkController)                    // start writing progress with another routine
@@ -351,7 +352,11 @@ func (lcm *lifecycleMgr) Output(o OutputBuilder, msgType OutputMessageType) {
 
 // TODO minor: consider merging with Exit
 func (lcm *lifecycleMgr) Error(msg string) {
+	lcm.ErrorWithExitCode(msg, EExitCode.Error())
+}
 
+// ErrorWithExitCode is Error, for failures that have an exit code of their own (see ExitCode)
+func (lcm *lifecycleMgr) ErrorWithExitCode(msg string, exitCode ExitCode) {
 	msg = lcm.logSanitizer.SanitizeLogMessage(msg)
 
 	// Check if need to do memory profiling, and do memory profiling accordingly before azcopy exits.
@@ -363,7 +368,7 @@ func (lcm *lifecycleMgr) Error(msg string) {
 	lcm.msgQueue <- outputMessage{
 		msgContent: msg,
 		msgType:    EOutputMessageType.Error(),
-		exitCode:   EExitCode.Error(),
+		exitCode:   exitCode,
 	}
 
 	// stall forever until the success message is printed and program exits
@@ -465,7 +470,7 @@ func (lcm *lifecycleMgr) processOutputMessage() {
 func (lcm *lifecycleMgr) processNoneOutput(msgToOutput outputMessage) {
 	if msgToOutput.msgType == EOutputMessageType.Error() {
 		lcm.closeFunc()
-		os.Exit(int(EExitCode.Error()))
+		os.Exit(int(msgToOutput.exitCode))
 	} else if msgToOutput.shouldExitProcess() {
 		lcm.closeFunc()
 		os.Exit(int(msgToOutput.exitCode))
@@ -574,6 +579,33 @@ type WorkController interface {
 	ReportProgressOrExit(mgr LifecycleMgr) (totalKnownCount uint32) // print the progress status, optionally exit the application if work is done
 }
 
+// DrainableWorkController is implemented by work controllers that can shut down cleanly, by letting the transfers that
+// are already in flight finish before cancelling the rest
+type DrainableWorkController interface {
+	// Drain stops new transfers from starting. It returns false if there's no point waiting for the ones in flight,
+	// e.g. because the job couldn't be resumed anyway.
+	Drain(mgr LifecycleMgr) bool
+	// Drained reports whether the transfers that were in flight have all finished
+	Drained() bool
+}
+
+// shutdownGracePeriod is how long we wait, once asked to shut down, for transfers in flight to finish
+func shutdownGracePeriod() time.Duration {
+	env := EEnvironmentVariable.ShutdownGracePeriod()
+	seconds, err := strconv.Atoi(GetEnvironmentVariable(env))
+	if err != nil || seconds < 0 {
+		seconds, _ = strconv.Atoi(env.DefaultValue)
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// PausableWorkController is implemented by work controllers whose transfers can be paused and resumed in place,
+// rather than cancelled and resumed later with "jobs resume"
+type PausableWorkController interface {
+	Pause(mgr LifecycleMgr)
+	Resume(mgr LifecycleMgr)
+}
+
 // AllowReinitiateProgressReporting must be called before running an cleanup job, to allow the initiation of that job's
 // progress reporting to begin
 func (lcm *lifecycleMgr) AllowReinitiateProgressReporting() {
@@ -597,7 +629,17 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 		// cancelChannel will be notified when os receives os.Interrupt and os.Kill signals
 		signal.Notify(lcm.cancelChannel, os.Interrupt, syscall.SIGTERM)
 
+		// pauseChannel will be notified when os receives pauseSignal or resumeSignal, if the work can be paused at all
+		pauseChannel := make(chan os.Signal, 1)
+		pausable, canPause := jc.(PausableWorkController)
+		if canPause && pauseSignal != nil {
+			signal.Notify(pauseChannel, pauseSignal, resumeSignal)
+		}
+
 		cancelCalled := false
+		drainable, canDrain := jc.(DrainableWorkController)
+		var drainCheck <-chan time.Time
+		var drainDeadline time.Time
 
 		doCancel := func() {
 			cancelCalled = true
@@ -608,8 +650,32 @@ func (lcm *lifecycleMgr) InitiateProgressReporting(jc WorkController) {
 		for {
 			select {
 			case <-lcm.cancelChannel:
+				if canDrain && !cancelCalled && drainCheck == nil {
+					if grace := shutdownGracePeriod(); grace > 0 && drainable.Drain(lcm) {
+						lcm.Info(fmt.Sprintf("Shutting down: waiting up to %v for transfers in progress to finish, so that they aren't repeated "+
+							"when the job is resumed. Cancel again to stop straight away.", grace))
+						drainDeadline = time.Now().Add(grace)
+						drainCheck = time.NewTicker(time.Second).C
+						continue
+					}
+				}
 				doCancel()
 				continue // to exit on next pass through loop
+			case <-drainCheck:
+				if !cancelCalled && (drainable.Drained() || time.Now().After(drainDeadline)) {
+					doCancel()
+				}
+				continue
+			case sig := <-pauseChannel:
+				if cancelCalled {
+					continue
+				}
+				if sig == pauseSignal {
+					pausable.Pause(lcm)
+				} else {
+					pausable.Resume(lcm)
+				}
+				continue
 			case <-lcm.doneChannel:
 
 				newCount = jc.ReportProgressOrExit(lcm)
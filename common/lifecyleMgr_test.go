@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownGracePeriod(t *testing.T) {
+	a := assert.New(t)
+	name := EEnvironmentVariable.ShutdownGracePeriod().Name
+
+	// by default, we cancel straight away, as we always have
+	t.Setenv(name, "")
+	a.Equal(time.Duration(0), shutdownGracePeriod())
+
+	t.Setenv(name, "0")
+	a.Equal(time.Duration(0), shutdownGracePeriod())
+
+	t.Setenv(name, "15")
+	a.Equal(15*time.Second, shutdownGracePeriod())
+
+	// nonsense falls back to the default
+	t.Setenv(name, "-1")
+	a.Equal(time.Duration(0), shutdownGracePeriod())
+	t.Setenv(name, "soon")
+	a.Equal(time.Duration(0), shutdownGracePeriod())
+}
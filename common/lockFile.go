@@ -0,0 +1,37 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const LockFilesFlagName = "lock-files"
+
+// ErrFileLocked is returned by TryLockFile when another open file, in this process or another, holds a lock on the file
+var ErrFileLocked = errors.New("the file is locked by another process")
+
+// lockFiles makes downloads lock each file while they write it. It's a global for the same reason as aioWrites.
+var lockFiles = false
+
+func SetLockFiles(enable bool) error {
+	if enable && !lockFilesSupported {
+		return errors.New("the --" + LockFilesFlagName + " flag isn't supported on Windows")
+	}
+	lockFiles = enable
+	return nil
+}
+
+// LockFileToWrite locks f, with --lock-files, until it's closed, so that other processes that lock files before reading
+// or writing them leave it alone while it's being downloaded. If another process holds a lock on it already, it fails,
+// rather than the two writing over each other.
+func LockFileToWrite(f *os.File) error {
+	if !lockFiles {
+		return nil
+	}
+	err := TryLockFile(f)
+	if errors.Is(err, ErrFileLocked) {
+		return fmt.Errorf("%s is locked by another process, which may be writing it", f.Name())
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const lockFilesSupported = true
+
+// TryLockFile takes an exclusive lock on the whole of f, with flock(2), without waiting for one that's held already.
+// The lock is held until f is closed.
+func TryLockFile(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return ErrFileLocked
+	}
+	return err
+}
+
+// OpenFileToWrite opens a file that is to be downloaded to, as os.OpenFile does. With --lock-files, it's locked before
+// it's truncated, so that a file that another process has locked is left as it was.
+func OpenFileToWrite(path string, flags int, perm os.FileMode) (*os.File, error) {
+	if !lockFiles {
+		return os.OpenFile(path, flags, perm)
+	}
+	f, err := os.OpenFile(path, flags&^os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err = LockFileToWrite(f); err == nil && flags&os.O_TRUNC != 0 {
+		err = f.Truncate(0)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
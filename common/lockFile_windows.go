@@ -0,0 +1,21 @@
+package common
+
+import (
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const lockFilesSupported = false
+
+// TryLockFile takes an exclusive lock on the whole of f, with LockFileEx, without waiting for one that's held already.
+// The lock is held until f is closed.
+func TryLockFile(f *os.File) error {
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, math.MaxUint32, math.MaxUint32, &windows.Overlapped{})
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return ErrFileLocked
+	}
+	return err
+}
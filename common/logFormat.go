@@ -0,0 +1,76 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/JeffreyRichter/enum/enum"
+)
+
+var ELogFormat = LogFormat(0)
+
+// LogFormat selects how each line of a job log is written
+type LogFormat uint8
+
+func (LogFormat) Text() LogFormat { return LogFormat(0) }
+func (LogFormat) Json() LogFormat { return LogFormat(1) }
+
+func (lf LogFormat) String() string {
+	return enum.StringInt(lf, reflect.TypeOf(lf))
+}
+
+func (lf *LogFormat) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(lf), s, true, true)
+	if err == nil {
+		*lf = val.(LogFormat)
+	}
+	return err
+}
+
+var logFormat = ELogFormat.Text()
+
+// SetLogFormat chooses the format of the lines written by loggers from NewJobLogger
+func SetLogFormat(format LogFormat) {
+	logFormat = format
+}
+
+// jsonLogLine is one line of a log written with --log-format=json. Its fields are part of our interface with log
+// ingestion tools, so they must not be renamed or removed.
+type jsonLogLine struct {
+	Time    string `json:"time"`  // RFC 3339, in UTC
+	Level   string `json:"level"` // fatal, panic, error, warning, info or debug
+	JobID   string `json:"jobId"`
+	Log     string `json:"log,omitempty"` // which of the job's logs this is, e.g. "scanning"; empty for the main one
+	Message string `json:"msg"`
+}
+
+// formatJSONLogLine returns msg as a single line of JSON, with no trailing newline
+func formatJSONLogLine(t time.Time, level LogLevel, jobID JobID, logFileNameSuffix string, msg string) string {
+	line, err := json.Marshal(jsonLogLine{
+		Time:    t.UTC().Format(time.RFC3339Nano),
+		Level:   jsonLogLevel(level),
+		JobID:   jobID.String(),
+		Log:     strings.TrimPrefix(logFileNameSuffix, "-"),
+		Message: msg,
+	})
+	PanicIfErr(err) // can't happen, since it's all strings
+	return string(line)
+}
+
+func jsonLogLevel(level LogLevel) string {
+	switch level {
+	case LogFatal:
+		return "fatal"
+	case LogPanic:
+		return "panic"
+	case LogError:
+		return "error"
+	case LogWarning:
+		return "warning"
+	case LogDebug:
+		return "debug"
+	}
+	return "info"
+}
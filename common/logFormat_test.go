@@ -0,0 +1,57 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatJSONLogLine(t *testing.T) {
+	a := assert.New(t)
+	jobID := NewJobID()
+	at := time.Date(2024, 3, 1, 12, 30, 0, 5, time.FixedZone("CET", 3600))
+
+	line := formatJSONLogLine(at, LogWarning, jobID, "-scanning", "Try=2 \"quoted\"\nsecond line")
+
+	a.Equal(`{"time":"2024-03-01T11:30:00.000000005Z","level":"warning","jobId":"`+jobID.String()+
+		`","log":"scanning","msg":"Try=2 \"quoted\"\nsecond line"}`, line)
+	a.Equal(`{"time":"2024-03-01T11:30:00.000000005Z","level":"info","jobId":"`+jobID.String()+`","msg":"x"}`,
+		formatJSONLogLine(at, LogInfo, jobID, "", "x"))
+}
+
+func TestJobLoggerWritesJSON(t *testing.T) {
+	a := assert.New(t)
+	SetLogFormat(ELogFormat.Json())
+	defer SetLogFormat(ELogFormat.Text())
+
+	dir := t.TempDir()
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, LogInfo, dir, "")
+	logger.OpenLog()
+	logger.Log(LogError, "upload failed\nwith details")
+	logger.Log(LogDebug, "not wanted at this level")
+	logger.CloseLog()
+
+	f, err := os.Open(filepath.Join(dir, jobID.String()+".log"))
+	a.NoError(err)
+	defer f.Close()
+
+	var lines []jsonLogLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line jsonLogLine
+		a.NoError(json.Unmarshal(scanner.Bytes(), &line), scanner.Text())
+		a.Equal(jobID.String(), line.JobID)
+		lines = append(lines, line)
+	}
+
+	a.Len(lines, 5) // 3 lines of version info, our error, and closing
+	a.Equal("error", lines[3].Level)
+	a.Equal("upload failed\nwith details", lines[3].Message)
+	a.Equal("Closing Log", lines[4].Message)
+}
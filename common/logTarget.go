@@ -0,0 +1,93 @@
+package common
+
+import (
+	"reflect"
+
+	"github.com/JeffreyRichter/enum/enum"
+)
+
+var ELogTarget = LogTarget(0)
+
+// LogTarget selects where job logs are written
+type LogTarget uint8
+
+func (LogTarget) File() LogTarget   { return LogTarget(0) }
+func (LogTarget) Syslog() LogTarget { return LogTarget(1) }
+func (LogTarget) Both() LogTarget   { return LogTarget(2) }
+
+func (lt LogTarget) String() string {
+	return enum.StringInt(lt, reflect.TypeOf(lt))
+}
+
+func (lt *LogTarget) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(lt), s, true, true)
+	if err == nil {
+		*lt = val.(LogTarget)
+	}
+	return err
+}
+
+// SyslogOptions says how to reach syslogd when job logs are sent there
+type SyslogOptions struct {
+	// Facility is a syslog facility name, e.g. "user", "daemon" or "local3"
+	Facility string
+	// Address is empty for the local syslogd, a socket path such as /var/run/log, or udp://host:port or tcp://host:port
+	// for a remote one
+	Address string
+	// Severities overrides the syslog severity used for some log levels, e.g. "INFO=notice,WARNING=err"
+	Severities string
+}
+
+var logTarget = ELogTarget.File()
+
+// SetLogTarget makes NewJobLogger send logs to target. It returns an error if syslog options can't be used, or syslogd can't be reached.
+func SetLogTarget(target LogTarget, options SyslogOptions) error {
+	if target != ELogTarget.File() {
+		if err := configureSyslog(options); err != nil {
+			return err
+		}
+	}
+	logTarget = target
+	return nil
+}
+
+// teeLogger writes to all of its loggers, e.g. to both a log file and syslog
+type teeLogger []ILoggerResetable
+
+func (t teeLogger) OpenLog() {
+	for _, l := range t {
+		l.OpenLog()
+	}
+}
+
+func (t teeLogger) MinimumLogLevel() LogLevel {
+	return t[0].MinimumLogLevel()
+}
+
+func (t teeLogger) ShouldLog(level LogLevel) bool {
+	for _, l := range t {
+		if l.ShouldLog(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeLogger) Log(level LogLevel, msg string) {
+	for _, l := range t {
+		l.Log(level, msg)
+	}
+}
+
+// Panic logs err everywhere before the first logger panics
+func (t teeLogger) Panic(err error) {
+	for i := len(t) - 1; i >= 0; i-- {
+		t[i].Panic(err)
+	}
+}
+
+func (t teeLogger) CloseLog() {
+	for _, l := range t {
+		l.CloseLog()
+	}
+}
@@ -101,16 +101,26 @@ type jobLogger struct {
 	logger            *log.Logger    // The Job's logger
 	sanitizer         LogSanitizer
 	logFileNameSuffix string // Used to allow more than 1 log per job, ex: front-end and back-end logs should be separate
+	json              bool   // Whether lines are written as JSON, see SetLogFormat
 }
 
+// NewJobLogger returns a logger for the job that writes to the log file, syslog or both, as chosen by SetLogTarget
 func NewJobLogger(jobID JobID, minimumLevelToLog LogLevel, logFileFolder string, logFileNameSuffix string) ILoggerResetable {
-	return &jobLogger{
+	fileLogger := &jobLogger{
 		jobID:             jobID,
 		minimumLevelToLog: minimumLevelToLog,
 		logFileFolder:     logFileFolder,
 		sanitizer:         NewAzCopyLogSanitizer(),
 		logFileNameSuffix: logFileNameSuffix,
 	}
+
+	switch logTarget {
+	case ELogTarget.Syslog():
+		return NewSysLogger(jobID, minimumLevelToLog, logFileNameSuffix, fileLogger, false)
+	case ELogTarget.Both():
+		return teeLogger{fileLogger, NewSysLogger(jobID, minimumLevelToLog, logFileNameSuffix, fileLogger, true)}
+	}
+	return fileLogger
 }
 
 func (jl *jobLogger) OpenLog() {
@@ -126,6 +136,16 @@ func (jl *jobLogger) OpenLog() {
 	flags := log.LstdFlags | log.LUTC
 	utcMessage := fmt.Sprintf("Log times are in UTC. Local time is %s", time.Now().Format("2 Jan 2006 15:04:05"))
 
+	if logFormat == ELogFormat.Json() {
+		// each line carries its own timestamp, and the JSON has to start at the beginning of the line
+		jl.json = true
+		jl.logger = log.New(jl.file, "", 0)
+		jl.println(LogInfo, "AzcopyVersion "+AzcopyVersion)
+		jl.println(LogInfo, "OS-Environment "+runtime.GOOS)
+		jl.println(LogInfo, "OS-Architecture "+runtime.GOARCH)
+		return
+	}
+
 	jl.logger = log.New(jl.file, "", flags)
 	// Log the Azcopy Version
 	jl.logger.Println("AzcopyVersion ", AzcopyVersion)
@@ -135,6 +155,15 @@ func (jl *jobLogger) OpenLog() {
 	jl.logger.Println(utcMessage)
 }
 
+// println writes msg as a line of the log, in the log's format
+func (jl *jobLogger) println(level LogLevel, msg string) {
+	if jl.json {
+		jl.logger.Println(formatJSONLogLine(time.Now(), level, jl.jobID, jl.logFileNameSuffix, msg))
+		return
+	}
+	jl.logger.Println(msg)
+}
+
 func (jl *jobLogger) MinimumLogLevel() LogLevel {
 	return jl.minimumLevelToLog
 }
@@ -151,7 +180,7 @@ func (jl *jobLogger) CloseLog() {
 		return
 	}
 
-	jl.logger.Println("Closing Log")
+	jl.println(LogInfo, "Closing Log")
 	_ = jl.file.Close() // If it was already closed, that's alright. We wanted to close it, anyway.
 }
 
@@ -164,16 +193,16 @@ func (jl jobLogger) Log(loglevel LogLevel, msg string) {
 
 	// Go, and therefore the sdk, defaults to \n for line endings, so if the platform has a different line ending,
 	// we should replace them to ensure readability on the given platform.
-	if lineEnding != "\n" {
+	if lineEnding != "\n" && !jl.json {
 		msg = strings.Replace(msg, "\n", lineEnding, -1)
 	}
 	if jl.ShouldLog(loglevel) {
-		jl.logger.Println(msg)
+		jl.println(loglevel, msg)
 	}
 }
 
 func (jl jobLogger) Panic(err error) {
-	jl.logger.Println(err) // We do NOT panic here as the app would terminate; we just log it
+	jl.println(LogPanic, err.Error()) // We do NOT panic here as the app would terminate; we just log it
 	panic(err)
 	// We should never reach this line of code!
 }
@@ -25,9 +25,153 @@ package common
 import (
 	"fmt"
 	"log/syslog"
+	"net/url"
 	"runtime"
+	"strings"
+	"time"
 )
 
+// syslogConfig is what SetLogTarget was given, parsed ready for dialling syslogd
+var syslogConfig = struct {
+	network, raddr string
+	facility       syslog.Priority
+	severities     map[LogLevel]syslog.Priority
+}{
+	facility:   syslog.LOG_USER,
+	severities: defaultSyslogSeverities(),
+}
+
+func defaultSyslogSeverities() map[LogLevel]syslog.Priority {
+	return map[LogLevel]syslog.Priority{
+		LogFatal:   syslog.LOG_EMERG,
+		LogPanic:   syslog.LOG_CRIT,
+		LogError:   syslog.LOG_ERR,
+		LogWarning: syslog.LOG_WARNING,
+		LogInfo:    syslog.LOG_INFO,
+		LogDebug:   syslog.LOG_DEBUG,
+	}
+}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL, "daemon": syslog.LOG_DAEMON,
+	"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG, "lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS,
+	"uucp": syslog.LOG_UUCP, "cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2, "local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5, "local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"emerg": syslog.LOG_EMERG, "alert": syslog.LOG_ALERT, "crit": syslog.LOG_CRIT, "err": syslog.LOG_ERR,
+	"error": syslog.LOG_ERR, "warning": syslog.LOG_WARNING, "warn": syslog.LOG_WARNING, "notice": syslog.LOG_NOTICE,
+	"info": syslog.LOG_INFO, "debug": syslog.LOG_DEBUG,
+}
+
+func configureSyslog(options SyslogOptions) error {
+	facility, ok := syslogFacilities[strings.ToLower(options.Facility)]
+	if options.Facility == "" {
+		facility, ok = syslog.LOG_USER, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown syslog facility '%s'", options.Facility)
+	}
+
+	network, raddr, err := parseSyslogAddress(options.Address)
+	if err != nil {
+		return err
+	}
+
+	severities, err := parseSyslogSeverities(options.Severities)
+	if err != nil {
+		return err
+	}
+
+	// check that syslogd can be reached now, rather than finding out once a job's logger is opened
+	probe, err := syslog.Dial(network, raddr, facility|syslog.LOG_NOTICE, "azcopy")
+	if err != nil {
+		return fmt.Errorf("cannot reach syslog: %w", err)
+	}
+	_ = probe.Close()
+
+	syslogConfig.network, syslogConfig.raddr = network, raddr
+	syslogConfig.facility = facility
+	syslogConfig.severities = severities
+	return nil
+}
+
+// parseSyslogAddress turns an address given by the user into what syslog.Dial wants. An empty address means the local
+// syslogd, which syslog.Dial finds by itself (at /dev/log, or /var/run/log on FreeBSD).
+func parseSyslogAddress(address string) (network, raddr string, err error) {
+	if address == "" {
+		return "", "", nil
+	}
+	if strings.HasPrefix(address, "/") {
+		return "unixgram", address, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid syslog address '%s': %w", address, err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Port() == "" {
+			return u.Scheme, u.Host + ":514", nil
+		}
+		return u.Scheme, u.Host, nil
+	case "unix", "unixgram":
+		return u.Scheme, u.Path, nil
+	}
+	return "", "", fmt.Errorf("invalid syslog address '%s': expected a socket path, or udp://host[:port] or tcp://host[:port]", address)
+}
+
+// parseSyslogSeverities applies overrides like "INFO=notice,WARNING=err" to the default mapping of log levels to
+// syslog severities
+func parseSyslogSeverities(overrides string) (map[LogLevel]syslog.Priority, error) {
+	severities := defaultSyslogSeverities()
+	if overrides == "" {
+		return severities, nil
+	}
+
+	for _, pair := range strings.Split(overrides, ",") {
+		levelName, severityName, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid syslog severity mapping '%s': expected LEVEL=severity", pair)
+		}
+		var level LogLevel
+		if err := level.Parse(levelName); err != nil || level == ELogLevel.None() {
+			return nil, fmt.Errorf("invalid log level '%s' in syslog severity mapping", levelName)
+		}
+		severity, ok := syslogSeverities[strings.ToLower(severityName)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog severity '%s'", severityName)
+		}
+		severities[level] = severity
+	}
+	return severities, nil
+}
+
+// writeSyslog writes msg to w with the given severity. syslog.Writer only lets us choose the severity per call through
+// its per-severity methods.
+func writeSyslog(w *syslog.Writer, severity syslog.Priority, msg string) error {
+	switch severity {
+	case syslog.LOG_EMERG:
+		return w.Emerg(msg)
+	case syslog.LOG_ALERT:
+		return w.Alert(msg)
+	case syslog.LOG_CRIT:
+		return w.Crit(msg)
+	case syslog.LOG_ERR:
+		return w.Err(msg)
+	case syslog.LOG_WARNING:
+		return w.Warning(msg)
+	case syslog.LOG_NOTICE:
+		return w.Notice(msg)
+	case syslog.LOG_INFO:
+		return w.Info(msg)
+	}
+	return w.Debug(msg)
+}
+
 // ////////////////////////////////////////
 type sysLogger struct {
 	// minimum loglevel represents the minimum severity of log messages which can be logged to Job Log file.
@@ -37,14 +181,22 @@ type sysLogger struct {
 	writer            *syslog.Writer // The Job's logger
 	logSuffix         string
 	sanitizer         LogSanitizer
+
+	// fileLogger is the job's file logger. If syslogd can't be reached when the log is opened, we say so there, and,
+	// unless it is already being written to (with --log-target=both), log there instead.
+	fileLogger    ILoggerResetable
+	fileLoggerToo bool
+	fellBack      bool
 }
 
-func NewSysLogger(jobID JobID, minimumLevelToLog LogLevel, logSuffix string) ILoggerResetable {
+func NewSysLogger(jobID JobID, minimumLevelToLog LogLevel, logSuffix string, fileLogger ILoggerResetable, fileLoggerToo bool) ILoggerResetable {
 	return &sysLogger{
 		jobID:             jobID,
 		minimumLevelToLog: minimumLevelToLog,
 		logSuffix:         logSuffix,
 		sanitizer:         NewAzCopyLogSanitizer(),
+		fileLogger:        fileLogger,
+		fileLoggerToo:     fileLoggerToo,
 	}
 }
 
@@ -52,15 +204,32 @@ func (sl *sysLogger) OpenLog() {
 	if sl.minimumLevelToLog == LogNone {
 		return
 	}
-	writer, err := syslog.New(syslog.LOG_NOTICE, fmt.Sprintf("%s %s", sl.logSuffix, sl.jobID.String()))
-	PanicIfErr(err)
+	// the tag is what aggregators usually know as the program name, so we keep the job ID out of it
+	writer, err := syslog.Dial(syslogConfig.network, syslogConfig.raddr, syslogConfig.facility|syslog.LOG_NOTICE, "azcopy"+sl.logSuffix)
+	if err != nil {
+		// SetLogTarget could reach syslogd, so it has gone away since; that's no reason to fail the job
+		if !sl.fileLoggerToo {
+			sl.fileLogger.OpenLog()
+			sl.fellBack = true
+		}
+		sl.fileLogger.Log(LogWarning, "Cannot reach syslog, so the job isn't being logged there: "+err.Error())
+		return
+	}
 
 	sl.writer = writer
 	// Log the Azcopy Version
-	_ = sl.writer.Notice("AzcopyVersion " + AzcopyVersion)
+	_ = sl.writer.Notice(sl.format(LogInfo, "AzcopyVersion "+AzcopyVersion))
 	// Log the OS Environment and OS Architecture
-	_ = sl.writer.Notice("OS-Environment " + runtime.GOOS)
-	_ = sl.writer.Notice("OS-Architecture " + runtime.GOARCH)
+	_ = sl.writer.Notice(sl.format(LogInfo, "OS-Environment "+runtime.GOOS))
+	_ = sl.writer.Notice(sl.format(LogInfo, "OS-Architecture "+runtime.GOARCH))
+}
+
+// format prefixes msg with the job's ID, or turns it into JSON with --log-format=json
+func (sl *sysLogger) format(level LogLevel, msg string) string {
+	if logFormat == ELogFormat.Json() {
+		return formatJSONLogLine(time.Now(), level, sl.jobID, sl.logSuffix, msg)
+	}
+	return sl.jobID.String() + " " + msg
 }
 
 func (sl *sysLogger) MinimumLogLevel() LogLevel {
@@ -75,41 +244,39 @@ func (jl *sysLogger) ShouldLog(level LogLevel) bool {
 }
 
 func (sl *sysLogger) CloseLog() {
-	if sl.minimumLevelToLog == LogNone {
+	if sl.fellBack {
+		sl.fileLogger.CloseLog()
+		return
+	}
+	if sl.minimumLevelToLog == LogNone || sl.writer == nil {
 		return
 	}
 
-	_ = sl.writer.Notice("Closing Log")
+	_ = sl.writer.Notice(sl.format(LogInfo, "Closing Log"))
 	sl.writer.Close()
 }
 
 func (sl *sysLogger) Panic(err error) {
-	_ = sl.writer.Crit(err.Error()) // We do NOT panic here as the app would terminate;
+	if sl.fellBack {
+		sl.fileLogger.Panic(err)
+	}
+	if sl.writer == nil {
+		return
+	}
+	_ = sl.writer.Crit(sl.format(LogPanic, err.Error())) // We do NOT panic here as the app would terminate;
 	//we just log it. We should never reach this line of code!
 }
 
 func (sl *sysLogger) Log(loglevel LogLevel, msg string) {
-	if !sl.ShouldLog(loglevel) {
+	if sl.fellBack {
+		sl.fileLogger.Log(loglevel, msg)
+		return
+	}
+	if !sl.ShouldLog(loglevel) || sl.writer == nil {
 		return
 	}
-	w := sl.writer
 	// ensure all secrets are redacted
 	msg = sl.sanitizer.SanitizeLogMessage(msg)
 
-	switch loglevel {
-	case LogNone:
-		//nothing to do
-	case LogFatal:
-		_ = w.Emerg(msg)
-	case LogPanic:
-		_ = w.Crit(msg)
-	case LogError:
-		_ = w.Err(msg)
-	case LogWarning:
-		_ = w.Warning(msg)
-	case LogInfo:
-		_ = w.Info(msg)
-	case LogDebug:
-		_ = w.Debug(msg)
-	}
+	_ = writeSyslog(sl.writer, syslogConfig.severities[loglevel], sl.format(loglevel, msg))
 }
@@ -0,0 +1,146 @@
+//go:build linux || darwin || freebsd
+
+package common
+
+import (
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSyslogAddress(t *testing.T) {
+	for _, tc := range []struct {
+		address string
+		network string
+		raddr   string
+		wantErr bool
+	}{
+		{address: "", network: "", raddr: ""}, // the local syslogd, wherever syslog.Dial finds it
+		{address: "/var/run/log", network: "unixgram", raddr: "/var/run/log"},
+		{address: "udp://loghost", network: "udp", raddr: "loghost:514"},
+		{address: "udp://10.0.0.1:5514", network: "udp", raddr: "10.0.0.1:5514"},
+		{address: "tcp://loghost:601", network: "tcp", raddr: "loghost:601"},
+		{address: "tcp://[::1]", network: "tcp", raddr: "[::1]:514"},
+		{address: "unix:///var/run/logpriv", network: "unix", raddr: "/var/run/logpriv"},
+		{address: "unixgram:///var/run/log", network: "unixgram", raddr: "/var/run/log"},
+		{address: "http://loghost", wantErr: true},
+		{address: "loghost:514", wantErr: true},
+		{address: "udp://%zz", wantErr: true},
+	} {
+		t.Run(tc.address, func(t *testing.T) {
+			a := assert.New(t)
+			network, raddr, err := parseSyslogAddress(tc.address)
+			if tc.wantErr {
+				a.Error(err)
+				return
+			}
+			a.NoError(err)
+			a.Equal(tc.network, network)
+			a.Equal(tc.raddr, raddr)
+		})
+	}
+}
+
+func TestParseSyslogSeverities(t *testing.T) {
+	for _, tc := range []struct {
+		overrides string
+		changed   map[LogLevel]syslog.Priority // on top of the defaults
+		wantErr   bool
+	}{
+		{overrides: ""},
+		{overrides: "info=notice, Warning=err", changed: map[LogLevel]syslog.Priority{LogInfo: syslog.LOG_NOTICE, LogWarning: syslog.LOG_ERR}},
+		{overrides: "ERROR=crit", changed: map[LogLevel]syslog.Priority{LogError: syslog.LOG_CRIT}},
+		{overrides: "debug=warn,debug=info", changed: map[LogLevel]syslog.Priority{LogDebug: syslog.LOG_INFO}}, // the last one wins
+		{overrides: "info", wantErr: true},
+		{overrides: "none=err", wantErr: true},
+		{overrides: "chatty=info", wantErr: true},
+		{overrides: "info=loud", wantErr: true},
+		{overrides: "info=notice,", wantErr: true},
+	} {
+		t.Run(tc.overrides, func(t *testing.T) {
+			a := assert.New(t)
+			severities, err := parseSyslogSeverities(tc.overrides)
+			if tc.wantErr {
+				a.Error(err)
+				return
+			}
+			a.NoError(err)
+			expected := defaultSyslogSeverities()
+			for level, severity := range tc.changed {
+				expected[level] = severity
+			}
+			a.Equal(expected, severities)
+		})
+	}
+}
+
+func TestJobLoggerWritesToSyslog(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	a.NoError(err)
+	defer conn.Close()
+
+	a.NoError(SetLogTarget(ELogTarget.Syslog(), SyslogOptions{Facility: "local3", Address: path, Severities: "WARNING=err"}))
+	defer func() { _ = SetLogTarget(ELogTarget.File(), SyslogOptions{}) }()
+
+	jobID := NewJobID()
+	logger := NewJobLogger(jobID, LogWarning, t.TempDir(), "-scanning")
+	logger.OpenLog()
+	logger.Log(LogInfo, "not wanted at this level")
+	logger.Log(LogWarning, "something slow")
+	logger.CloseLog()
+
+	var lines []string
+	buf := make([]byte, 4096)
+	for len(lines) < 5 {
+		n, err := conn.Read(buf)
+		a.NoError(err)
+		lines = append(lines, string(buf[:n]))
+	}
+
+	// 3 lines of version info, then our warning logged as local3.err, i.e. <19*8+3>
+	a.True(strings.HasPrefix(lines[3], "<155>"), lines[3])
+	a.Contains(lines[3], "azcopy-scanning")
+	a.Contains(lines[3], jobID.String()+" something slow")
+	a.Contains(lines[4], "Closing Log")
+}
+
+func TestSetLogTargetRejectsBadSyslogOptions(t *testing.T) {
+	a := assert.New(t)
+	a.Error(SetLogTarget(ELogTarget.Both(), SyslogOptions{Facility: "nosuch"}))
+	a.Equal(ELogTarget.File(), logTarget)
+
+	a.Error(SetLogTarget(ELogTarget.Syslog(), SyslogOptions{Address: filepath.Join(t.TempDir(), "nosuch")}))
+	a.Equal(ELogTarget.File(), logTarget)
+}
+
+func TestJobLoggerFallsBackToFileWhenSyslogIsGone(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	a.NoError(err)
+	a.NoError(SetLogTarget(ELogTarget.Syslog(), SyslogOptions{Address: path}))
+	defer func() { _ = SetLogTarget(ELogTarget.File(), SyslogOptions{}) }()
+
+	// syslogd goes away between the job starting and its logger being opened
+	conn.Close()
+	_ = os.Remove(path)
+
+	jobID := NewJobID()
+	logDir := t.TempDir()
+	logger := NewJobLogger(jobID, LogInfo, logDir, "")
+	a.NotPanics(logger.OpenLog)
+	logger.Log(LogInfo, "still logged")
+	logger.CloseLog()
+
+	b, err := os.ReadFile(filepath.Join(logDir, jobID.String()+".log"))
+	a.NoError(err)
+	a.Contains(string(b), "Cannot reach syslog")
+	a.Contains(string(b), "still logged")
+}
@@ -0,0 +1,12 @@
+package common
+
+import "errors"
+
+func configureSyslog(options SyslogOptions) error {
+	return errors.New("logging to syslog is not supported on Windows")
+}
+
+// NewSysLogger is only here so that NewJobLogger builds; SetLogTarget never lets it be called on Windows
+func NewSysLogger(jobID JobID, minimumLevelToLog LogLevel, logSuffix string, fileLogger ILoggerResetable, fileLoggerToo bool) ILoggerResetable {
+	panic("logging to syslog is not supported on Windows")
+}
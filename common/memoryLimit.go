@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// The memory limit, of --memory-limit, bounds the memory that AzCopy's transfers hold: the chunks in the buffers of
+// the RAM cache limiter, the idle slices that the slice pool keeps for reuse, and the mapped plan files. A chunk that
+// would take them past it waits, as it does at the cache limiter's own limit, until others have been sent or written,
+// rather than the process being killed for running out of memory on a small VM or in a jail. It's also made the Go
+// runtime's soft memory limit, so that the garbage collector works harder as it's approached.
+var memoryLimit atomic.Int64
+
+var (
+	bufferMemory   atomic.Int64 // the chunks in the buffers of the memory-limited cache limiter
+	pooledMemory   atomic.Int64 // the idle slices in the slice pools
+	planFileMemory atomic.Int64 // the mapped plan files
+)
+
+// SetMemoryLimit sets the memory limit, in bytes, or lifts it, with 0
+func SetMemoryLimit(bytes int64) error {
+	if bytes < 0 {
+		return fmt.Errorf("the memory limit can't be negative")
+	}
+	memoryLimit.Store(bytes)
+	if bytes > 0 {
+		debug.SetMemoryLimit(bytes)
+	}
+	return nil
+}
+
+func MemoryLimit() int64 {
+	return memoryLimit.Load()
+}
+
+// AddPlanFileMemory counts a plan file that's been mapped, or, with a negative size, unmapped, towards the memory
+// limit
+func AddPlanFileMemory(delta int64) {
+	planFileMemory.Add(delta)
+}
+
+// withinMemoryLimit is whether buffers, the memory of the chunks in the buffers, after count more were added to them,
+// leaves the memory limit, or the fraction of it that's given, unreached. A chunk is always let into empty buffers, so
+// that there's progress even if the plan files alone are over the limit.
+func withinMemoryLimit(buffers, count int64, fraction float32) bool {
+	limit := memoryLimit.Load()
+	if limit <= 0 || buffers == count {
+		return true
+	}
+	return buffers+planFileMemory.Load() <= int64(float32(limit)*fraction)
+}
+
+// roomToPool is whether an idle slice of size bytes can be kept in the slice pool without taking the memory past the
+// limit. Slices that can't be are left for the garbage collector.
+func roomToPool(size int64) bool {
+	limit := memoryLimit.Load()
+	return limit <= 0 || bufferMemory.Load()+pooledMemory.Load()+planFileMemory.Load()+size <= limit
+}
+
+// MemoryLimitDescription is how the memory limit is given in the job log, or "" if there's none
+func MemoryLimitDescription() string {
+	limit := memoryLimit.Load()
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d MiB, of which plan files use %d MiB", limit/(1024*1024), planFileMemory.Load()/(1024*1024))
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+const MmapUploadsFlagName = "mmap-uploads"
+
+// mmapUploadMinSize is the smallest file that's mapped for upload. Smaller ones go in a chunk or two, and gain too
+// little from it to make up for mapping and unmapping them.
+const mmapUploadMinSize = 8 * 1024 * 1024
+
+// mmapUploads makes uploads read local files through a memory mapping. It's a global for the same reason as aioWrites.
+var mmapUploads = false
+
+func SetMmapUploads(enable bool) error {
+	if enable && !mmapUploadsSupported {
+		return errors.New("the --" + MmapUploadsFlagName + " flag isn't supported on this platform")
+	}
+	if enable && cacheBypass != CacheBypassNone {
+		return fmt.Errorf("--%s reads files through the page cache, so it can't be used with --%s", MmapUploadsFlagName, CacheBypassFlagName)
+	}
+	mmapUploads = enable
+	return nil
+}
+
+// MappedSourceFile is a local file that's uploaded from a memory mapping of it, so that each chunk is a slice of the
+// mapping, rather than being read into a buffer from the pool. The mapping outlives the file being closed, until the
+// last chunk that uses it has been sent.
+type MappedSourceFile struct {
+	*os.File
+	mapping []byte
+	refs    atomic.Int32 // the file's own, and one for each chunk that's using the mapping
+	closed  atomic.Bool
+}
+
+// mapSourceFile maps f, or returns nil if it's too small to be worth it, or can't be mapped
+func mapSourceFile(f *os.File) *MappedSourceFile {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() < mmapUploadMinSize || int64(int(info.Size())) != info.Size() {
+		return nil
+	}
+	mapping, err := mmapForReading(f, info.Size())
+	if err != nil {
+		return nil
+	}
+	m := &MappedSourceFile{File: f, mapping: mapping}
+	m.refs.Store(1)
+	return m
+}
+
+// mappedRange returns a range of the file, straight from the mapping, and what to call once it's no longer needed.
+// It returns false if the range isn't all mapped, because the file was smaller when it was opened than when its
+// chunks were planned.
+func (m *MappedSourceFile) mappedRange(offset, length int64) ([]byte, func(), bool) {
+	if offset < 0 || offset+length > int64(len(m.mapping)) {
+		return nil, nil, false
+	}
+	m.refs.Add(1)
+	return m.mapping[offset : offset+length : offset+length], m.release, true
+}
+
+func (m *MappedSourceFile) release() {
+	if m.refs.Add(-1) == 0 {
+		_ = munmapSourceFile(m.mapping)
+	}
+}
+
+// Close closes the file, and unmaps it once no chunk is using the mapping
+func (m *MappedSourceFile) Close() error {
+	if !m.closed.Swap(true) {
+		m.release()
+	}
+	return m.File.Close()
+}
+
+// readMapping runs read, which reads from a mapped file. If the file has been truncated since it was mapped, that
+// faults, which, rather than crashing AzCopy, is returned as an error.
+func readMapping(read func()) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			fault, ok := r.(interface{ Addr() uintptr })
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("the file was truncated while it was being read (fault at %#x)", fault.Addr())
+		}
+	}()
+	read()
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !freebsd && !darwin
+
+package common
+
+import (
+	"errors"
+	"os"
+)
+
+const mmapUploadsSupported = false
+
+func mmapForReading(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("memory-mapped uploads aren't supported on this platform")
+}
+
+func munmapSourceFile(mapping []byte) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build linux || freebsd || darwin
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const mmapUploadsSupported = true
+
+// mmapForReading maps the first size bytes of f, read-only. Unlike NewMMF, it advises only sequential access, since
+// WILLNEED would have Linux read the whole of a big file ahead of its chunks.
+func mmapForReading(f *os.File, size int64) ([]byte, error) {
+	mapping, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	_ = unix.Madvise(mapping, unix.MADV_SEQUENTIAL)
+	return mapping, nil
+}
+
+func munmapSourceFile(mapping []byte) error {
+	return unix.Munmap(mapping)
+}
@@ -92,3 +92,13 @@ func (m *MMF) UnuseMMF() {
 func (m *MMF) Slice() []byte {
 	return m.slice
 }
+
+// Flush writes any modified pages back to the underlying file, so the file on disk is current
+// even if the process is killed before Unmap is called.
+func (m *MMF) Flush() error {
+	if !m.UseMMF() {
+		return nil
+	}
+	defer m.UnuseMMF()
+	return unix.Msync(m.slice, unix.MS_SYNC)
+}
@@ -113,6 +113,20 @@ func (m *MMF) Slice() []byte {
 	return m.slice
 }
 
+// Flush writes any modified pages back to the underlying file, so the file on disk is current
+// even if the process is killed before Unmap is called.
+func (m *MMF) Flush() error {
+	if !m.UseMMF() {
+		return nil
+	}
+	defer m.UnuseMMF()
+	if len(m.slice) == 0 {
+		return nil
+	}
+	addr := uintptr(unsafe.Pointer(&(([]byte)(m.slice)[0])))
+	return syscall.FlushViewOfFile(addr, uintptr(m.length))
+}
+
 type memoryRangeEntry struct {
 	VirtualAddress uintptr
 	NumberOfBytes  int
@@ -22,12 +22,17 @@ package common
 
 import (
 	"math/bits"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 // A pool of byte slices
 // Like sync.Pool, but strongly-typed to byte slices
 type ByteSlicePooler interface {
 	RentSlice(desiredLength int64) []byte
+	// RetainSlice adds a reference to a rented slice, for when it's shared with code that will return it too
+	RetainSlice(slice []byte)
 	ReturnSlice(slice []byte)
 	Prune()
 }
@@ -45,11 +50,23 @@ type ByteSlicePooler interface {
 // https://github.com/golang/go/issues/22950
 type simpleSlicePool struct {
 	c chan []byte
+
+	// the references to slices that are rented out, by their addresses, so that a slice goes back in the pool only
+	// when the last of them is returned. In the big slots, every rented slice is counted, so that one that's returned
+	// twice by mistake is never rented to two users at once. In the small ones, which are rented far more often, only
+	// the references that RetainSlice adds are. Addresses, rather than pointers, are kept so that a slice that's never
+	// returned can still be collected.
+	countsRentals bool
+	refsMu        sync.Mutex
+	refs          map[uintptr]int32
+	retained      atomic.Int32 // how many slices are in refs, in the small slots, so that returns needn't lock if none
 }
 
-func newSimpleSlicePool(maxCapacity int) *simpleSlicePool {
+func newSimpleSlicePool(maxCapacity int, countsRentals bool) *simpleSlicePool {
 	return &simpleSlicePool{
-		c: make(chan []byte, maxCapacity),
+		c:             make(chan []byte, maxCapacity),
+		countsRentals: countsRentals,
+		refs:          make(map[uintptr]int32),
 	}
 }
 
@@ -62,17 +79,66 @@ func (p *simpleSlicePool) Get() []byte {
 	}
 }
 
-func (p *simpleSlicePool) Put(b []byte) {
+func (p *simpleSlicePool) Put(b []byte) (pooled bool) {
 	select {
 	case p.c <- b:
-		return
+		return true
 	default:
 		// just throw b away and let it get GC'd if p.c is full
+		return false
+	}
+}
+
+func sliceAddress(b []byte) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(b[:cap(b)])))
+}
+
+// rent counts the reference of whoever has just rented b
+func (p *simpleSlicePool) rent(b []byte) {
+	if p.countsRentals {
+		p.retain(b)
+	}
+}
+
+// retain counts another reference to b, which is rented out
+func (p *simpleSlicePool) retain(b []byte) {
+	p.refsMu.Lock()
+	defer p.refsMu.Unlock()
+	addr := sliceAddress(b)
+	p.refs[addr]++
+	if !p.countsRentals && p.refs[addr] == 1 {
+		p.retained.Add(1)
+	}
+}
+
+// release drops a reference to b, and returns whether it was the last of them, so that b can be pooled
+func (p *simpleSlicePool) release(b []byte) bool {
+	if !p.countsRentals && p.retained.Load() == 0 {
+		return true
+	}
+	p.refsMu.Lock()
+	defer p.refsMu.Unlock()
+	addr := sliceAddress(b)
+	switch refs := p.refs[addr]; {
+	case refs == 0:
+		// uncounted, the renter's own reference is the last; counted, b isn't rented out, so it's in the pool already,
+		// or was never from it
+		return !p.countsRentals
+	case refs == 1:
+		delete(p.refs, addr)
+		if !p.countsRentals {
+			p.retained.Add(-1)
+			return false // the renter's own reference is still to be returned
+		}
+		return true
+	default:
+		p.refs[addr] = refs - 1
+		return false
 	}
 }
 
 // A pool of byte slices, optimized so that it actually has a sub-pool for each
-// different size (in powers of 2) up to some pre-specified limit.  The use of sub-pools
+// different size class up to some pre-specified limit.  The use of sub-pools
 // minimized wastage, in cases where the desired slice sizes vary greatly.
 // (E.g. if only had one pool, holding really big slices, it would be wasteful when
 // we only need to put put small amounts of data into them).
@@ -88,12 +154,21 @@ func NewMultiSizeSlicePool(maxSliceLength int64) ByteSlicePooler {
 	poolsBySize := make([]*simpleSlicePool, maxSlotIndex+1)
 	for i := 0; i <= maxSlotIndex; i++ {
 		maxCount := getMaxSliceCountInPool(i)
-		poolsBySize[i] = newSimpleSlicePool(maxCount)
+		poolsBySize[i] = newSimpleSlicePool(maxCount, !holdsSmallSlices(i))
 	}
 	return &multiSizeSlicePool{poolsBySize: poolsBySize}
 }
 
-var indexOf32KSlot, _ = getSlotInfo(32 * 1024)
+const indexOf32KSlot = 15 // log-base2 of 32 KB, the biggest of the small slots
+
+// slotsPerDoubling is how many slots there are for each power of two above 32 KB, so that a chunk whose size is a
+// little over a power of two, as the block size of a huge file often is, doesn't waste up to half of its slice. With
+// four, slices are 1, 1.25, 1.5 and 1.75 times the power of two, and the waste is at most a fifth.
+const slotsPerDoubling = 4
+
+// sliceAlignment is the boundary that the slices of the big slots start on: a page, so that the kernel can read and
+// write them directly, as it does with O_DIRECT, rather than through a buffer of its own
+const sliceAlignment = 4096
 
 // For a given requested len(slice), this returns the slot index to use, and the max
 // cap(slice) of the slices that will be found at that index
@@ -101,6 +176,10 @@ func getSlotInfo(exactSliceLength int64) (slotIndex int, maxCapInSlot int) {
 	if exactSliceLength <= 0 {
 		panic("exact slice length must be greater than zero")
 	}
+	if exactSliceLength > 32*1024 {
+		return getBigSlotInfo(exactSliceLength)
+	}
+
 	// raw slot index is fast computation of the base-2 logarithm, rounded down...
 	rawSlotIndex := 63 - bits.LeadingZeros64(uint64(exactSliceLength))
 
@@ -127,6 +206,19 @@ func getSlotInfo(exactSliceLength int64) (slotIndex int, maxCapInSlot int) {
 	return
 }
 
+// getBigSlotInfo is getSlotInfo for lengths over 32 KB, which have slotsPerDoubling slots for each power of two.
+// Exact powers of two are still the largest thing in their slots.
+func getBigSlotInfo(exactSliceLength int64) (slotIndex int, maxCapInSlot int) {
+	n := uint64(exactSliceLength - 1)
+	power := 63 - bits.LeadingZeros64(n)                       // of the power of two that's below exactSliceLength
+	step := uint(power - bits.TrailingZeros(slotsPerDoubling)) // log-base2 of the gap between slots, at this power
+	slotInDoubling := int(n>>step) - slotsPerDoubling          // the top bits of n, after its leading one
+
+	slotIndex = indexOf32KSlot + 1 + (power-indexOf32KSlot)*slotsPerDoubling + slotInDoubling
+	maxCapInSlot = (slotsPerDoubling + slotInDoubling + 1) << step
+	return
+}
+
 func holdsSmallSlices(slotIndex int) bool {
 	return slotIndex <= indexOf32KSlot
 }
@@ -145,45 +237,64 @@ func getMaxSliceCountInPool(slotIndex int) int {
 	}
 }
 
+// makeSlice allocates a new slice, which, for the big slots, starts on a sliceAlignment boundary. Go's allocator
+// already puts objects that big at the start of their own pages, so it's rare for there to be any need to make a
+// bigger one and slice it.
+func makeSlice(desiredSize int64, maxCapInSlot int) []byte {
+	b := make([]byte, maxCapInSlot)
+	if maxCapInSlot <= 32*1024 || sliceAddress(b)%sliceAlignment == 0 {
+		return b[:desiredSize]
+	}
+	b = make([]byte, maxCapInSlot+sliceAlignment)
+	offset := sliceAlignment - int(sliceAddress(b)%sliceAlignment)
+	return b[offset : offset+int(desiredSize) : offset+maxCapInSlot]
+}
+
 // RentSlice borrows a slice from the pool (or creates a new one if none of suitable capacity is available)
 // Note that the returned slice may contain non-zero data - i.e. old data from the previous time it was used.
 // That's safe IFF you are going to do the likes of io.ReadFull to read into it, since you know that all of the
-// old bytes will be overwritten in that case.
+// old bytes will be overwritten in that case. They aren't cleared here, since, for chunk-sized slices, that would
+// cost as much again as the read that fills them.
 func (mp *multiSizeSlicePool) RentSlice(desiredSize int64) []byte {
 	slotIndex, maxCapInSlot := getSlotInfo(desiredSize)
 
 	// get the pool that most closely corresponds to the desired size
 	pool := mp.poolsBySize[slotIndex]
 
-	// try to get a pooled slice
-	if typedSlice := pool.Get(); typedSlice != nil {
-		// clear out the entire slice up to the capacity
-		// a zero-ing-out loop written in the right form in Go, will be automatically turned into a call to memclr,
-		// which is an optimized Go runtime routine written in assembler
-		// more info here: https://github.com/golang/go/commit/f03c9202c43e0abb130669852082117ca50aa9b1
-		typedSlice = typedSlice[0:maxCapInSlot]
-		for i := range typedSlice {
-			typedSlice[i] = 0
-		}
-
+	// try to get a pooled slice, or make a new one if nothing pooled
+	typedSlice := pool.Get()
+	if typedSlice != nil {
+		pooledMemory.Add(-int64(cap(typedSlice)))
 		// here we set len to the exact desired size that was requested
 		typedSlice = typedSlice[0:desiredSize]
-		return typedSlice
+	} else {
+		typedSlice = makeSlice(desiredSize, maxCapInSlot)
 	}
+	pool.rent(typedSlice)
+	return typedSlice
+}
 
-	// make a new slice if nothing pooled
-	return make([]byte, desiredSize, maxCapInSlot)
+// RetainSlice adds a reference to a rented slice. It goes back in the pool only once it has been returned as many
+// times as it was rented and retained.
+func (mp *multiSizeSlicePool) RetainSlice(slice []byte) {
+	slotIndex, _ := getSlotInfo(int64(cap(slice)))
+	mp.poolsBySize[slotIndex].retain(slice)
 }
 
 // returns the slice to its pool
 func (mp *multiSizeSlicePool) ReturnSlice(slice []byte) {
-	slotIndex, _ := getSlotInfo(int64(cap(slice))) // be sure to use capacity, not length, here
+	slotIndex, maxCapInSlot := getSlotInfo(int64(cap(slice))) // be sure to use capacity, not length, here
 
 	// get the pool that most closely corresponds to the desired size
 	pool := mp.poolsBySize[slotIndex]
+	if !pool.release(slice) || cap(slice) != maxCapInSlot {
+		return // it's still in use, or isn't one of ours
+	}
 
-	// put the slice back into the pool
-	pool.Put(slice)
+	// put the slice back into the pool, unless it would take us past the memory limit, in which case it's left for the GC
+	if roomToPool(int64(cap(slice))) && pool.Put(slice) {
+		pooledMemory.Add(int64(cap(slice)))
+	}
 }
 
 // Prune inactive stuff in all the big slots if due (don't worry about the little ones, they don't eat much RAM)
@@ -202,7 +313,9 @@ func (mp *multiSizeSlicePool) Prune() {
 			// With repeated calls of Prune, this will gradually drain idle pools.
 			// But, since Prune is not called very often,
 			// it won't have much adverse impact on active pools.
-			_ = mp.poolsBySize[index].Get()
+			if slice := mp.poolsBySize[index].Get(); slice != nil {
+				pooledMemory.Add(-int64(cap(slice)))
+			}
 		}
 	}
 }
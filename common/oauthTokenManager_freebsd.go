@@ -90,7 +90,7 @@ func newAzcopyHTTPClient() *http.Client {
 		Transport: &http.Transport{
 			Proxy: GlobalProxyLookup,
 			// We use Dial instead of DialContext as DialContext has been reported to cause slower performance.
-			Dial /*Context*/ : (&net.Dialer{
+			Dial /*Context*/ : NewDialer(net.Dialer{
 				Timeout:   10 * time.Second,
 				KeepAlive: 10 * time.Second,
 				DualStack: true,
@@ -98,6 +98,7 @@ func newAzcopyHTTPClient() *http.Client {
 			MaxIdleConns:           0, // No limit
 			MaxIdleConnsPerHost:    1000,
 			IdleConnTimeout:        180 * time.Second,
+			TLSClientConfig:        ClientTLSConfig(),
 			TLSHandshakeTimeout:    10 * time.Second,
 			ExpectContinueTimeout:  1 * time.Second,
 			DisableKeepAlives:      false,
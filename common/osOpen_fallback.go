@@ -13,11 +13,11 @@ import (
 
 // NOTE: OSOpenFile not safe to use on directories on Windows. See comment on the Windows version of this routine
 func OSOpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
-	return os.OpenFile(name, flag, perm)
+	return SandboxOpenFile(name, flag, perm)
 }
 
 func OSStat(name string) (os.FileInfo, error) {
-	return os.Stat(name)
+	return SandboxStat(name)
 }
 
 func EnsureRunningAsRoot() error {
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// pacEnvironment is what the PAC functions find out about the world, which tests replace
+type pacEnvironment struct {
+	resolve   func(host string) net.IP // an IPv4 address of host, or nil if it can't be resolved
+	myAddress func() string
+	now       func() time.Time
+}
+
+// pacDNSTimeout is how long a PAC file's DNS lookups may take
+const pacDNSTimeout = 5 * time.Second
+
+func newPACEnvironment() pacEnvironment {
+	return pacEnvironment{
+		resolve: func(host string) net.IP {
+			ctx, cancel := context.WithTimeout(context.Background(), pacDNSTimeout)
+			defer cancel()
+			addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+			if err != nil || len(addrs) == 0 {
+				return nil
+			}
+			return addrs[0]
+		},
+		myAddress: func() string {
+			if source := SourceAddr(); source != nil {
+				return source.(*net.TCPAddr).IP.String()
+			}
+			// dialing UDP sends nothing, but picks the address that traffic to the internet would leave from
+			conn, err := net.Dial("udp4", "198.51.100.1:53")
+			if err != nil {
+				return "127.0.0.1"
+			}
+			defer conn.Close()
+			return conn.LocalAddr().(*net.UDPAddr).IP.String()
+		},
+		now: time.Now,
+	}
+}
+
+// pacIP is host, if it's an IPv4 address, or what it resolves to
+func (e pacEnvironment) pacIP(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4()
+	}
+	return e.resolve(host)
+}
+
+var pacWeekdays = map[string]time.Weekday{
+	"SUN": time.Sunday, "MON": time.Monday, "TUE": time.Tuesday, "WED": time.Wednesday,
+	"THU": time.Thursday, "FRI": time.Friday, "SAT": time.Saturday,
+}
+
+var pacMonths = map[string]time.Month{
+	"JAN": time.January, "FEB": time.February, "MAR": time.March, "APR": time.April, "MAY": time.May, "JUN": time.June,
+	"JUL": time.July, "AUG": time.August, "SEP": time.September, "OCT": time.October, "NOV": time.November, "DEC": time.December,
+}
+
+// builtins are the functions that PAC files can call, as Netscape defined them
+func (e pacEnvironment) builtins() map[string]pacBuiltin {
+	str := func(args []goja.Value, i int) string {
+		return pacArg(args, i).String()
+	}
+	return map[string]pacBuiltin{
+		"isPlainHostName": func(args []goja.Value) any {
+			return !strings.Contains(str(args, 0), ".")
+		},
+		"dnsDomainIs": func(args []goja.Value) any {
+			return strings.HasSuffix(strings.ToLower(str(args, 0)), strings.ToLower(str(args, 1)))
+		},
+		"localHostOrDomainIs": func(args []goja.Value) any {
+			host, hostdom := strings.ToLower(str(args, 0)), strings.ToLower(str(args, 1))
+			if strings.Contains(host, ".") {
+				return host == hostdom
+			}
+			return strings.HasPrefix(hostdom+".", host+".")
+		},
+		"isResolvable": func(args []goja.Value) any {
+			return e.pacIP(str(args, 0)) != nil
+		},
+		"dnsResolve": func(args []goja.Value) any {
+			if ip := e.pacIP(str(args, 0)); ip != nil {
+				return ip.String()
+			}
+			return nil
+		},
+		"isInNet": func(args []goja.Value) any {
+			ip, pattern, mask := e.pacIP(str(args, 0)), net.ParseIP(str(args, 1)).To4(), net.ParseIP(str(args, 2)).To4()
+			if ip == nil || pattern == nil || mask == nil {
+				return false
+			}
+			return ip.Mask(net.IPMask(mask)).Equal(pattern.Mask(net.IPMask(mask)))
+		},
+		"myIpAddress": func(args []goja.Value) any {
+			return e.myAddress()
+		},
+		"dnsDomainLevels": func(args []goja.Value) any {
+			return strings.Count(str(args, 0), ".")
+		},
+		"shExpMatch": func(args []goja.Value) any {
+			return shExpMatch(str(args, 0), str(args, 1))
+		},
+		"convert_addr": func(args []goja.Value) any {
+			ip := net.ParseIP(str(args, 0)).To4()
+			if ip == nil {
+				return 0
+			}
+			return binary.BigEndian.Uint32(ip)
+		},
+		"weekdayRange": func(args []goja.Value) any {
+			now, args := e.pacNow(args)
+			first, ok := pacWeekdays[strings.ToUpper(str(args, 0))]
+			if !ok {
+				return false
+			}
+			last := first
+			if len(args) > 1 {
+				if last, ok = pacWeekdays[strings.ToUpper(str(args, 1))]; !ok {
+					return false
+				}
+			}
+			day := now.Weekday()
+			if first <= last {
+				return first <= day && day <= last
+			}
+			return day >= first || day <= last
+		},
+		"timeRange": func(args []goja.Value) any {
+			now, args := e.pacNow(args)
+			seconds := func(h, m, s int64) int {
+				return int(h*3600 + m*60 + s)
+			}
+			n := func(i int) int64 {
+				return args[i].ToInteger()
+			}
+			current := now.Hour()*3600 + now.Minute()*60 + now.Second()
+			var start, end int
+			switch len(args) {
+			case 1:
+				return now.Hour() == int(n(0))
+			case 2:
+				start, end = seconds(n(0), 0, 0), seconds(n(1), 0, 0)
+			case 4:
+				start, end = seconds(n(0), n(1), 0), seconds(n(2), n(3), 0)
+			case 6:
+				start, end = seconds(n(0), n(1), n(2)), seconds(n(3), n(4), n(5))
+			default:
+				return false
+			}
+			if start <= end {
+				return start <= current && current < end
+			}
+			return current >= start || current < end
+		},
+		"dateRange": func(args []goja.Value) any {
+			now, args := e.pacNow(args)
+			return pacDateRange(now, args)
+		},
+		"alert": func(args []goja.Value) any {
+			return nil
+		},
+	}
+}
+
+// pacNow is the time that weekdayRange, timeRange and dateRange compare with, which is in UTC if their last argument
+// is "GMT", and the rest of their arguments
+func (e pacEnvironment) pacNow(args []goja.Value) (time.Time, []goja.Value) {
+	if len(args) > 0 && args[len(args)-1].String() == "GMT" {
+		return e.now().UTC(), args[:len(args)-1]
+	}
+	return e.now(), args
+}
+
+// pacDateRange is dateRange(), whose arguments are days of the month (1 to 31), month names and years, either one of
+// them, or the start and end of a range of them
+func pacDateRange(now time.Time, args []goja.Value) bool {
+	// part is which of day, month and year an argument is, and its value
+	part := func(v goja.Value) (int, int) {
+		if month, ok := pacMonths[strings.ToUpper(v.String())]; ok {
+			return 1, int(month)
+		}
+		n := int(v.ToInteger())
+		if n < 32 {
+			return 0, n
+		}
+		return 2, n
+	}
+	current := [3]int{now.Day(), int(now.Month()), now.Year()}
+
+	if len(args) == 1 {
+		which, value := part(args[0])
+		return current[which] == value
+	}
+	if len(args) == 0 || len(args)%2 != 0 || len(args) > 6 {
+		return false
+	}
+	// the range starts at the start of the year and ends at its end, except where the arguments say otherwise
+	start, end := [3]int{1, 1, now.Year()}, [3]int{31, 12, now.Year()}
+	given := [3]bool{}
+	for i, a := range args {
+		which, value := part(a)
+		given[which] = true
+		if i < len(args)/2 {
+			start[which] = value
+		} else {
+			end[which] = value
+		}
+	}
+	// a range of days alone is in the current month
+	if given[0] && !given[1] {
+		start[1], end[1] = current[1], current[1]
+	}
+	key := func(d [3]int) int {
+		return d[2]*10000 + d[1]*100 + d[0]
+	}
+	if !given[2] && key(start) > key(end) {
+		// a range that runs through the end of the year, as DEC to JAN does
+		return key(current) >= key(start) || key(current) <= key(end)
+	}
+	return key(start) <= key(current) && key(current) <= key(end)
+}
+
+// shExpMatch matches s against a shell expression, in which * is any run of characters and ? is any one
+func shExpMatch(s, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	return err == nil && re.MatchString(s)
+}
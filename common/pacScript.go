@@ -0,0 +1,89 @@
+package common
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// pacScript is a proxy auto-config (PAC) file, run by goja, a JavaScript interpreter written in Go, with the PAC
+// functions (shExpMatch, dnsDomainIs, isInNet and the rest) among its globals. A goja.Runtime isn't safe to use from
+// more than one goroutine at a time, so neither is a pacScript.
+type pacScript struct {
+	vm *goja.Runtime
+}
+
+// pacBuiltin is a function that PAC files can call, given the arguments that they pass it
+type pacBuiltin func(args []goja.Value) any
+
+// pacCallTimeout is how long the top level of a PAC file, or a call of one of its functions, may run, which is long
+// enough for the DNS lookups of a few dnsResolve calls, but stops a loop that never ends
+var pacCallTimeout = time.Minute
+
+var errPACTimeout = errors.New("it ran for too long")
+
+// newPACScript parses src and runs its top level, which declares FindProxyForURL and anything that it uses
+func newPACScript(src string, builtins map[string]pacBuiltin) (*pacScript, error) {
+	s := &pacScript{vm: goja.New()}
+	for name, fn := range builtins {
+		if err := s.vm.Set(name, s.wrap(fn)); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.run(func() error {
+		_, err := s.vm.RunString(src)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if _, ok := goja.AssertFunction(s.vm.Get("FindProxyForURL")); !ok {
+		return nil, errors.New("it doesn't define the function FindProxyForURL(url, host)")
+	}
+	return s, nil
+}
+
+// call calls the script's function called name
+func (s *pacScript) call(name string, args ...string) (result goja.Value, err error) {
+	fn, ok := goja.AssertFunction(s.vm.Get(name))
+	if !ok {
+		return nil, errors.New("it doesn't define the function " + name)
+	}
+	values := make([]goja.Value, len(args))
+	for i, a := range args {
+		values[i] = s.vm.ToValue(a)
+	}
+	err = s.run(func() error {
+		result, err = fn(goja.Undefined(), values...)
+		return err
+	})
+	return result, err
+}
+
+// run runs f, interrupting the script if it takes longer than pacCallTimeout
+func (s *pacScript) run(f func() error) error {
+	timer := time.AfterFunc(pacCallTimeout, func() { s.vm.Interrupt(errPACTimeout) })
+	err := f()
+	timer.Stop()
+	s.vm.ClearInterrupt()
+
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) && interrupted.Value() == errPACTimeout {
+		return errPACTimeout
+	}
+	return err
+}
+
+func (s *pacScript) wrap(fn pacBuiltin) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		return s.vm.ToValue(fn(call.Arguments))
+	}
+}
+
+// pacArg is the i'th of args, or undefined if there aren't that many
+func pacArg(args []goja.Value, i int) goja.Value {
+	if i < len(args) && args[i] != nil {
+		return args[i]
+	}
+	return goja.Undefined()
+}
@@ -38,6 +38,10 @@ type failableFileInfo interface {
 	Error() error
 }
 
+// DirFilter decides whether a directory found during a walk should be descended into.
+// The directory itself is still reported to the WalkFunc either way.
+type DirFilter func(fullPath string, info os.FileInfo) bool
+
 type DirReader interface {
 	Readdir(dir *os.File, n int) ([]os.FileInfo, error)
 	Close()
@@ -48,12 +52,18 @@ type DirReader interface {
 // The items in the CrawResult output channel are FileSystemEntry s.
 // For a wrapper that makes this look more like filepath.Walk, see parallel.Walk.
 func CrawlLocalDirectory(ctx context.Context, root string, parallelism int, reader DirReader) <-chan CrawlResult {
-	return Crawl(ctx,
+	return crawlLocalDirectory(ctx, root, parallelism, reader, nil, TraversalAuto, false)
+}
+
+func crawlLocalDirectory(ctx context.Context, root string, parallelism int, reader DirReader, descend DirFilter, order TraversalOrder, reportDone bool) <-chan CrawlResult {
+	return crawl(ctx,
 		root,
 		func(dir Directory, enqueueDir func(Directory), enqueueOutput func(DirectoryEntry, error)) error {
-			return enumerateOneFileSystemDirectory(dir, enqueueDir, enqueueOutput, reader)
+			return enumerateOneFileSystemDirectory(dir, enqueueDir, enqueueOutput, reader, descend)
 		},
 		parallelism,
+		order,
+		reportDone,
 	)
 }
 
@@ -64,6 +74,17 @@ func CrawlLocalDirectory(ctx context.Context, root string, parallelism int, read
 // 2. If the return value of walkFunc function is not nil, enumeration will always stop, not matter what the type of the error.
 //    (Unlike filepath.WalkFunc, where returning filePath.SkipDir is handled as a special case).
 func Walk(appCtx context.Context, root string, parallelism int, parallelStat bool, walkFn filepath.WalkFunc) {
+	WalkFiltered(appCtx, root, parallelism, parallelStat, nil, TraversalAuto, walkFn, nil)
+}
+
+// DirDoneFunc is told of each directory, the root included, once walkFn has been given everything in it, if it was
+// read without any errors. subdirs is how many directories in it are to be walked in turn.
+type DirDoneFunc func(fullPath string, subdirs int)
+
+// WalkFiltered is Walk, except that directories below the root are only descended into if descend returns true for them,
+// and are read in the given order. A nil descend means every directory is descended into. dirDone, if it isn't nil,
+// is told of each directory that has been read completely.
+func WalkFiltered(appCtx context.Context, root string, parallelism int, parallelStat bool, descend DirFilter, order TraversalOrder, walkFn filepath.WalkFunc, dirDone DirDoneFunc) {
 	var ctx context.Context
 	var cancel context.CancelFunc
 	signalRootError := func(e error) {
@@ -101,9 +122,13 @@ func Walk(appCtx context.Context, root string, parallelism int, parallelStat boo
 
 	ctx, cancel = context.WithCancel(appCtx)
 	defer cancel()
-	ch := CrawlLocalDirectory(ctx, root, remainingParallelism, reader)
+	ch := crawlLocalDirectory(ctx, root, remainingParallelism, reader, descend, order, dirDone != nil)
 	for crawlResult := range ch {
 		entry, err := crawlResult.Item()
+		if done, ok := entry.(DirectoryDone); ok {
+			dirDone(done.Dir.(string), done.Subdirs)
+			continue
+		}
 		if err == nil {
 			fsEntry := entry.(FileSystemEntry)
 			err = walkFn(fsEntry.fullPath, fsEntry.info, nil)
@@ -123,7 +148,7 @@ func Walk(appCtx context.Context, root string, parallelism int, parallelStat boo
 }
 
 // enumerateOneFileSystemDirectory is an implementation of EnumerateOneDirFunc specifically for the local file system
-func enumerateOneFileSystemDirectory(dir Directory, enqueueDir func(Directory), enqueueOutput func(DirectoryEntry, error), r DirReader) error {
+func enumerateOneFileSystemDirectory(dir Directory, enqueueDir func(Directory), enqueueOutput func(DirectoryEntry, error), r DirReader, descend DirFilter) error {
 	dirString := dir.(string)
 
 	d, err := os.Open(dirString) // for directories, we don't need a special open with FILE_FLAG_BACKUP_SEMANTICS, because directory opening uses FindFirst which doesn't need that flag. https://blog.differentpla.net/blog/2007/05/25/findfirstfile-and-se_backup_name
@@ -163,7 +188,7 @@ func enumerateOneFileSystemDirectory(dir Directory, enqueueDir func(Directory),
 				continue
 			}
 			isSymlink := childInfo.Mode()&os.ModeSymlink != 0 // for compatibility with filepath.Walk, we do not follow symlinks, but we do enqueue them as output
-			if childInfo.IsDir() && !isSymlink {
+			if childInfo.IsDir() && !isSymlink && (descend == nil || descend(childEntry.fullPath, childInfo)) {
 				enqueueDir(childEntry.fullPath)
 			}
 			enqueueOutput(childEntry, nil)
@@ -30,6 +30,8 @@ type crawler struct {
 	output      chan CrawlResult
 	workerBody  EnumerateOneDirFunc
 	parallelism int
+	order       TraversalOrder
+	reportDone  bool
 	cond        *sync.Cond
 	// the following are protected by cond (and must only be accessed when cond.L is held)
 	unstartedDirs      []Directory // not a channel, because channels have length limits, and those get in our way
@@ -37,9 +39,31 @@ type crawler struct {
 	lastAutoShutdown   time.Time
 }
 
+// TraversalOrder is which of the directories that have been found, but not yet read, a crawl reads next
+type TraversalOrder int
+
+const (
+	// TraversalAuto reads directories in the order they were found, which spreads the work across the tree, until a
+	// great many are waiting to be read, and then reads the ones found most recently, to keep the queue from growing
+	TraversalAuto TraversalOrder = iota
+	// TraversalBreadthFirst always reads directories in the order they were found, so that each level of the tree is
+	// finished before the one below it is started. The queue of unread directories may grow very large.
+	TraversalBreadthFirst
+	// TraversalDepthFirst reads the directories found most recently first, so that a subdirectory and everything in it
+	// is found before its siblings are started (with parallelism 1; with more, several subdirectories are in progress at once)
+	TraversalDepthFirst
+)
+
 type Directory interface{}
 type DirectoryEntry interface{}
 
+// DirectoryDone is put in the output of a crawl that reports them, after everything found in Dir, when Dir has been
+// read without any errors. Subdirs is how many directories were found in it to be read in turn.
+type DirectoryDone struct {
+	Dir     Directory
+	Subdirs int
+}
+
 type CrawlResult struct {
 	item DirectoryEntry
 	err  error
@@ -55,11 +79,22 @@ type EnumerateOneDirFunc func(dir Directory, enqueueDir func(Directory), enqueue
 // Crawl crawls an abstract directory tree, using the supplied enumeration function.  May be use for whatever
 // that function can enumerate (i.e. not necessarily a local file system, just anything tree-structured)
 func Crawl(ctx context.Context, root Directory, worker EnumerateOneDirFunc, parallelism int) <-chan CrawlResult {
+	return CrawlInOrder(ctx, root, worker, parallelism, TraversalAuto)
+}
+
+// CrawlInOrder is Crawl, reading the directories it finds in the given order
+func CrawlInOrder(ctx context.Context, root Directory, worker EnumerateOneDirFunc, parallelism int, order TraversalOrder) <-chan CrawlResult {
+	return crawl(ctx, root, worker, parallelism, order, false)
+}
+
+func crawl(ctx context.Context, root Directory, worker EnumerateOneDirFunc, parallelism int, order TraversalOrder, reportDone bool) <-chan CrawlResult {
 	c := &crawler{
 		unstartedDirs: make([]Directory, 0, 1024),
 		output:        make(chan CrawlResult, 1000),
 		workerBody:    worker,
 		parallelism:   parallelism,
+		order:         order,
+		reportDone:    reportDone,
 		cond:          sync.NewCond(&sync.Mutex{}),
 	}
 	go c.start(ctx, root)
@@ -130,7 +165,9 @@ func (c *crawler) processOneDirectory(ctx context.Context, workerIndex int) (boo
 		stop = ctx.Err() != nil
 		if !stop {
 			if len(c.unstartedDirs) > 0 {
-				if len(c.unstartedDirs) < maxQueueDirsForBreadthFirst {
+				breadthFirst := c.order == TraversalBreadthFirst ||
+					(c.order == TraversalAuto && len(c.unstartedDirs) < maxQueueDirsForBreadthFirst)
+				if breadthFirst {
 					// pop from start of list. This gives a breadth-first flavour to the search.
 					// (Breadth-first is useful for distributing small-file workloads over the full keyspace, which
 					// is can help performance when uploading small files to Azure Blob Storage)
@@ -168,18 +205,30 @@ func (c *crawler) processOneDirectory(ctx context.Context, workerIndex int) (boo
 	addDir := func(d Directory) {
 		foundDirectories = append(foundDirectories, d)
 	}
+	failed := false
 	addOutput := func(de DirectoryEntry, er error) {
+		failed = failed || er != nil
 		select {
 		case c.output <- CrawlResult{item: de, err: er}:
 		case <-ctx.Done(): // don't block on full channel if cancelled
 		}
 	}
 	bodyErr := c.workerBody(toExamine, addDir, addOutput) // this is the worker body supplied by our caller
+	if c.reportDone && bodyErr == nil && !failed {
+		// before the directories found are queued, so that it comes before anything found in them
+		addOutput(DirectoryDone{Dir: toExamine, Subdirs: len(foundDirectories)}, nil)
+	}
 
 	// finally, update shared state (inside the lock)
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
 
+	if c.order == TraversalDepthFirst {
+		// reversed, so that they're popped from the end of the list in the order they were found
+		for i, j := 0, len(foundDirectories)-1; i < j; i, j = i+1, j-1 {
+			foundDirectories[i], foundDirectories[j] = foundDirectories[j], foundDirectories[i]
+		}
+	}
 	c.unstartedDirs = append(c.unstartedDirs, foundDirectories...) // do NOT try to wait here if unstartedDirs is getting big. May cause deadlocks, due to all workers waiting and none processing the queue
 	c.dirInProgressCount--                                         // we were doing something, and now we have finished it
 	c.cond.Broadcast()                                             // let other workers know that the state has changed
@@ -177,3 +177,26 @@ func TestRootErrorsAreSignalled(t *testing.T) {
 	})
 	a.True(receivedError)
 }
+
+func TestWalkFilteredDoesNotDescendIntoRejectedDirectories(t *testing.T) {
+	a := assert.New(t)
+	root := t.TempDir()
+	for _, d := range []string{"keep/sub", "mnt/sub"} {
+		a.NoError(os.MkdirAll(filepath.Join(root, d), 0755))
+	}
+	for _, f := range []string{"keep/a", "keep/sub/b", "mnt/c", "mnt/sub/d"} {
+		a.NoError(os.WriteFile(filepath.Join(root, f), nil, 0644))
+	}
+
+	found := make(map[string]bool)
+	WalkFiltered(context.TODO(), root, 4, false, func(path string, _ os.FileInfo) bool {
+		return filepath.Base(path) != "mnt"
+	}, TraversalAuto, func(path string, _ os.FileInfo, fileErr error) error {
+		a.NoError(fileErr)
+		rel, _ := filepath.Rel(root, path)
+		found[filepath.ToSlash(rel)] = true
+		return nil
+	}, nil)
+
+	a.Equal(map[string]bool{".": true, "keep": true, "keep/a": true, "keep/sub": true, "keep/sub/b": true, "mnt": true}, found)
+}
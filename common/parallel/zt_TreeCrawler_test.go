@@ -0,0 +1,93 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parallel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawlInOrder(t *testing.T) {
+	a := assert.New(t)
+	tree := map[string][]string{
+		"/":    {"/a", "/b"},
+		"/a":   {"/a/x", "/a/y"},
+		"/b":   {"/b/z"},
+		"/a/x": {"/a/x/deep"},
+	}
+	crawled := func(order TraversalOrder) []string {
+		// one worker, so that the directories are read in exactly the order chosen
+		ch := CrawlInOrder(context.Background(), "/", func(dir Directory, enqueueDir func(Directory), enqueueOutput func(DirectoryEntry, error)) error {
+			for _, child := range tree[dir.(string)] {
+				enqueueDir(child)
+				enqueueOutput(child, nil)
+			}
+			return nil
+		}, 1, order)
+		var found []string
+		for r := range ch {
+			item, err := r.Item()
+			a.NoError(err)
+			found = append(found, item.(string))
+		}
+		return found
+	}
+
+	a.Equal([]string{"/a", "/b", "/a/x", "/a/y", "/b/z", "/a/x/deep"}, crawled(TraversalBreadthFirst))
+	a.Equal([]string{"/a", "/b", "/a/x", "/a/y", "/a/x/deep", "/b/z"}, crawled(TraversalDepthFirst))
+	a.Equal(crawled(TraversalBreadthFirst), crawled(TraversalAuto), "a small tree is read breadth first")
+}
+
+func TestWalkReportsDirectoriesDone(t *testing.T) {
+	a := assert.New(t)
+	root := t.TempDir()
+	for _, dir := range []string{"a/x", "b"} {
+		a.NoError(os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	a.NoError(os.WriteFile(filepath.Join(root, "a", "file"), nil, 0644))
+
+	seen := map[string]bool{}
+	done := map[string]int{}
+	WalkFiltered(context.Background(), root, 4, false, nil, TraversalAuto, func(path string, _ os.FileInfo, err error) error {
+		a.NoError(err)
+		seen[path] = true
+		return nil
+	}, func(dir string, subdirs int) {
+		for path := range seen {
+			if filepath.Dir(path) == dir {
+				a.True(seen[path], "everything in a directory comes before it's done")
+			}
+		}
+		a.True(seen[dir])
+		done[dir] = subdirs
+	})
+
+	a.Equal(map[string]int{
+		root:                          2,
+		filepath.Join(root, "a"):      1,
+		filepath.Join(root, "a", "x"): 0,
+		filepath.Join(root, "b"):      0,
+	}, done)
+}
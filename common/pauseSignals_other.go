@@ -0,0 +1,12 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignal and resumeSignal let an operator pause transfers (e.g. during business hours) and pick them up again
+// later, without cancelling the job.
+var pauseSignal, resumeSignal os.Signal = syscall.SIGUSR1, syscall.SIGUSR2
@@ -0,0 +1,6 @@
+package common
+
+import "os"
+
+// Windows has no equivalent of SIGUSR1/SIGUSR2, so there's no way to pause transfers from outside.
+var pauseSignal, resumeSignal os.Signal
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const ( // ACL metadata
+	POSIXACLMeta        = "posix_acl"         // access (or NFSv4) ACL of the file or folder
+	POSIXDefaultACLMeta = "posix_acl_default" // default ACL inherited by new children of a POSIX.1e folder
+)
+
+var ErrACLsNotSupported = errors.New("ACLs are not supported on this platform")
+
+// ACLBrand distinguishes the two ACL models FreeBSD can expose for a file.
+// A file system carries either POSIX.1e ACLs (UFS with "acls") or NFSv4 ACLs (ZFS, UFS with "nfsv4acls"), never both.
+type ACLBrand string
+
+const (
+	EACLBrandPOSIX ACLBrand = "posix1e"
+	EACLBrandNFS4  ACLBrand = "nfs4"
+)
+
+// ACLEntry mirrors struct acl_entry from sys/acl.h.
+// Qualifiers are kept numeric; mapping them to names is left to the idmap handling of owners.
+type ACLEntry struct {
+	Tag       uint32
+	ID        uint32
+	Perm      uint32
+	EntryType uint16 // NFSv4 only: allow/deny/audit/alarm
+	Flags     uint16 // NFSv4 only: inheritance flags
+}
+
+type ACL struct {
+	Brand   ACLBrand
+	Entries []ACLEntry
+}
+
+// String serializes the ACL into a compact, header-safe form suitable for blob metadata:
+// "<brand>:<tag>/<id>/<perm>/<type>/<flags>,..." with every number in hex.
+func (a ACL) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(a.Brand))
+	sb.WriteByte(':')
+	for i, e := range a.Entries {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		_, _ = fmt.Fprintf(&sb, "%x/%x/%x/%x/%x", e.Tag, e.ID, e.Perm, e.EntryType, e.Flags)
+	}
+	return sb.String()
+}
+
+// ParseACL is the inverse of ACL.String.
+func ParseACL(s string) (ACL, error) {
+	brand, body, ok := strings.Cut(s, ":")
+	if !ok {
+		return ACL{}, fmt.Errorf("invalid ACL %q: missing brand", s)
+	}
+
+	out := ACL{Brand: ACLBrand(brand)}
+	if out.Brand != EACLBrandPOSIX && out.Brand != EACLBrandNFS4 {
+		return ACL{}, fmt.Errorf("invalid ACL %q: unknown brand %q", s, brand)
+	}
+
+	if body == "" {
+		return out, nil
+	}
+
+	for _, raw := range strings.Split(body, ",") {
+		fields := strings.Split(raw, "/")
+		if len(fields) != 5 {
+			return ACL{}, fmt.Errorf("invalid ACL entry %q", raw)
+		}
+
+		var nums [5]uint64
+		for i, f := range fields {
+			bits := 32
+			if i >= 3 {
+				bits = 16
+			}
+			n, err := strconv.ParseUint(f, 16, bits)
+			if err != nil {
+				return ACL{}, fmt.Errorf("invalid ACL entry %q: %w", raw, err)
+			}
+			nums[i] = n
+		}
+
+		out.Entries = append(out.Entries, ACLEntry{
+			Tag:       uint32(nums[0]),
+			ID:        uint32(nums[1]),
+			Perm:      uint32(nums[2]),
+			EntryType: uint16(nums[3]),
+			Flags:     uint16(nums[4]),
+		})
+	}
+
+	return out, nil
+}
+
+// AddACLsToBlobMetadata stores the access (or NFSv4) ACL, and the default ACL if there is one, in the metadata.
+func AddACLsToBlobMetadata(access, def *ACL, metadata Metadata) {
+	if access != nil {
+		TryAddMetadata(metadata, POSIXACLMeta, access.String())
+	}
+	if def != nil {
+		TryAddMetadata(metadata, POSIXDefaultACLMeta, def.String())
+	}
+}
+
+// ReadACLsFromMetadata returns whichever ACLs were stored by AddACLsToBlobMetadata. Either may be nil.
+func ReadACLsFromMetadata(metadata Metadata) (access, def *ACL, err error) {
+	if v, ok := metadata[POSIXACLMeta]; ok && v != nil {
+		a, err := ParseACL(*v)
+		if err != nil {
+			return nil, nil, err
+		}
+		access = &a
+	}
+	if v, ok := metadata[POSIXDefaultACLMeta]; ok && v != nil {
+		d, err := ParseACL(*v)
+		if err != nil {
+			return nil, nil, err
+		}
+		def = &d
+	}
+	return access, def, nil
+}
@@ -0,0 +1,167 @@
+//go:build freebsd
+
+package common
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Values from sys/acl.h. The kernel interface (__acl_get_file/__acl_set_file) takes a struct acl directly,
+// which spares us a cgo dependency on libc's acl_* wrappers; those only add text conversion on top.
+const (
+	aclMaxEntries = 254
+
+	aclTypeAccess  = 2
+	aclTypeDefault = 3
+	aclTypeNFS4    = 4
+
+	aclTagUserObj  = 0x01
+	aclTagGroupObj = 0x04
+	aclTagOther    = 0x20
+)
+
+type rawACLEntry struct {
+	tag       uint32
+	id        uint32
+	perm      uint32
+	entryType uint16
+	flags     uint16
+}
+
+type rawACL struct {
+	maxCnt  uint32
+	cnt     uint32
+	spare   [4]int32
+	entries [aclMaxEntries]rawACLEntry
+}
+
+func aclGetFile(path string, aclType int) (*rawACL, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	acl := &rawACL{maxCnt: aclMaxEntries}
+	_, _, errno := unix.Syscall(unix.SYS___ACL_GET_FILE, uintptr(unsafe.Pointer(p)), uintptr(aclType), uintptr(unsafe.Pointer(acl)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return acl, nil
+}
+
+func aclSetFile(path string, aclType int, acl *rawACL) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS___ACL_SET_FILE, uintptr(unsafe.Pointer(p)), uintptr(aclType), uintptr(unsafe.Pointer(acl)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (r *rawACL) toACL(brand ACLBrand) *ACL {
+	out := &ACL{Brand: brand, Entries: make([]ACLEntry, 0, r.cnt)}
+	for _, e := range r.entries[:r.cnt] {
+		out.Entries = append(out.Entries, ACLEntry{Tag: e.tag, ID: e.id, Perm: e.perm, EntryType: e.entryType, Flags: e.flags})
+	}
+	return out
+}
+
+func rawACLFrom(a *ACL) (*rawACL, error) {
+	if len(a.Entries) > aclMaxEntries {
+		return nil, errors.New("ACL has too many entries")
+	}
+
+	out := &rawACL{maxCnt: aclMaxEntries, cnt: uint32(len(a.Entries))}
+	for i, e := range a.Entries {
+		out.entries[i] = rawACLEntry{tag: e.Tag, id: e.ID, perm: e.Perm, entryType: e.EntryType, flags: e.Flags}
+	}
+	return out, nil
+}
+
+// isTrivial reports whether a POSIX.1e ACL carries nothing beyond the mode bits, which are already preserved separately.
+func (r *rawACL) isTrivial() bool {
+	if r.cnt > 3 {
+		return false
+	}
+	for _, e := range r.entries[:r.cnt] {
+		if e.tag != aclTagUserObj && e.tag != aclTagGroupObj && e.tag != aclTagOther {
+			return false
+		}
+	}
+	return true
+}
+
+func aclUnsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// GetFileACLs reads the ACLs of path. NFSv4 ACLs are tried first, falling back to POSIX.1e.
+// Both results are nil if the file system supports neither, or if the ACL is equivalent to the mode bits.
+func GetFileACLs(path string, isDir bool) (access, def *ACL, err error) {
+	if raw, err := aclGetFile(path, aclTypeNFS4); err == nil {
+		return raw.toACL(EACLBrandNFS4), nil, nil
+	} else if !aclUnsupported(err) {
+		return nil, nil, err
+	}
+
+	raw, err := aclGetFile(path, aclTypeAccess)
+	if err != nil {
+		if aclUnsupported(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if !raw.isTrivial() {
+		access = raw.toACL(EACLBrandPOSIX)
+	}
+
+	if isDir {
+		raw, err = aclGetFile(path, aclTypeDefault)
+		if err != nil && !aclUnsupported(err) {
+			return nil, nil, err
+		}
+		if err == nil && raw.cnt > 0 {
+			def = raw.toACL(EACLBrandPOSIX)
+		}
+	}
+
+	return access, def, nil
+}
+
+// SetFileACLs applies ACLs previously read by GetFileACLs. Either ACL may be nil.
+// Applying an ACL whose brand the destination file system doesn't support fails with EINVAL.
+func SetFileACLs(path string, access, def *ACL) error {
+	if access != nil {
+		raw, err := rawACLFrom(access)
+		if err != nil {
+			return err
+		}
+
+		aclType := aclTypeAccess
+		if access.Brand == EACLBrandNFS4 {
+			aclType = aclTypeNFS4
+		}
+		if err = aclSetFile(path, aclType, raw); err != nil {
+			return err
+		}
+	}
+
+	if def != nil {
+		raw, err := rawACLFrom(def)
+		if err != nil {
+			return err
+		}
+		if err = aclSetFile(path, aclTypeDefault, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
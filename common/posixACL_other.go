@@ -0,0 +1,11 @@
+//go:build !freebsd
+
+package common
+
+func GetFileACLs(path string, isDir bool) (access, def *ACL, err error) {
+	return nil, nil, ErrACLsNotSupported
+}
+
+func SetFileACLs(path string, access, def *ACL) error {
+	return ErrACLsNotSupported
+}
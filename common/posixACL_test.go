@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACLRoundTripsThroughMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	access := &ACL{Brand: EACLBrandNFS4, Entries: []ACLEntry{
+		{Tag: 0x01, ID: 0xffffffff, Perm: 0x1c0f9, EntryType: 0x100},
+		{Tag: 0x02, ID: 1001, Perm: 0x8, EntryType: 0x200, Flags: 0x3},
+	}}
+	def := &ACL{Brand: EACLBrandPOSIX, Entries: []ACLEntry{
+		{Tag: 0x01, ID: 0, Perm: 0x7},
+		{Tag: 0x08, ID: 20, Perm: 0x5},
+	}}
+
+	meta := Metadata{}
+	AddACLsToBlobMetadata(access, def, meta)
+
+	gotAccess, gotDef, err := ReadACLsFromMetadata(meta)
+	a.NoError(err)
+	a.Equal(access, gotAccess)
+	a.Equal(def, gotDef)
+}
+
+func TestParseACLRejectsGarbage(t *testing.T) {
+	a := assert.New(t)
+
+	for _, s := range []string{"", "1/2/3/4/5", "mac:1/2/3/4/5", "nfs4:1/2/3", "nfs4:1/2/3/4/10000"} {
+		_, err := ParseACL(s)
+		a.Error(err, s)
+	}
+
+	empty, err := ParseACL("posix1e:")
+	a.NoError(err)
+	a.Empty(empty.Entries)
+}
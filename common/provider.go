@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider lets AzCopy copy to and from storage it has no built-in support for. A provider is registered
+// for one or more URL schemes, and any source or destination with one of those schemes is handed to it,
+// as the ELocation.Provider() location. It can be copied to and from local storage, and into Blob storage.
+//
+// Providers are registered from an init func, the same way database/sql drivers are, so a provider kept outside
+// this repository is added by building AzCopy from a main package that imports it for its side effects.
+type Provider interface {
+	// Enumerate calls found for every object under root, or for root itself if it is an object.
+	// If recursive is false, only the objects directly under root are wanted.
+	Enumerate(ctx context.Context, root *url.URL, recursive bool, found func(ProviderObject) error) error
+
+	// Properties returns the properties of the object at u. If there is no object at u, but there are
+	// objects under it, the returned object has IsDirectory set. If there is nothing at all, the error
+	// must wrap ErrProviderObjectNotFound, so that a missing destination can be told from a failure.
+	Properties(ctx context.Context, u *url.URL) (ProviderObject, error)
+
+	// Open returns a reader for the object at u. Reads may come from several goroutines at once.
+	Open(ctx context.Context, u *url.URL) (CloseableReaderAt, error)
+
+	// Create starts writing an object of obj.Size bytes at u. Nothing need appear at u before Commit.
+	Create(ctx context.Context, u *url.URL, obj ProviderObject) (ProviderWriter, error)
+}
+
+// ProviderObject describes an object that a Provider holds.
+type ProviderObject struct {
+	// RelativePath is the slash-separated path of the object from the root it was enumerated under.
+	// It is empty for the root itself.
+	RelativePath string
+	IsDirectory  bool
+	Size         int64
+	LastModified time.Time
+	ContentType  string
+	ContentMD5   []byte
+	Metadata     map[string]string
+}
+
+// ProviderWriter writes one object. Chunks may be written in any order, and from several goroutines at once.
+type ProviderWriter interface {
+	io.WriterAt
+
+	// Commit makes the object visible, once every chunk has been written.
+	Commit(ctx context.Context) error
+
+	// Abort discards whatever has been written, after a failure or cancellation.
+	Abort() error
+}
+
+var ErrProviderObjectNotFound = errors.New("object not found")
+
+var providers = struct {
+	sync.RWMutex
+	byScheme map[string]Provider
+}{byScheme: map[string]Provider{}}
+
+// RegisterProvider makes p the provider for URLs with the given scheme. Like sql.Register, it panics if the scheme
+// already has a provider. Single-letter schemes are refused, since they would swallow Windows paths like C:\data.
+func RegisterProvider(scheme string, p Provider) {
+	scheme = strings.ToLower(scheme)
+	if len(scheme) < 2 || p == nil {
+		panic(fmt.Sprintf("invalid provider registration for scheme %q", scheme))
+	}
+	switch scheme {
+	case "http", "https", "file":
+		panic(fmt.Sprintf("the %q scheme belongs to AzCopy's built-in locations", scheme))
+	}
+
+	providers.Lock()
+	defer providers.Unlock()
+	if _, ok := providers.byScheme[scheme]; ok {
+		panic(fmt.Sprintf("a provider is already registered for scheme %q", scheme))
+	}
+	providers.byScheme[scheme] = p
+}
+
+// unregisterProvider removes the provider for scheme, so that tests can register theirs without leaking it.
+func unregisterProvider(scheme string) {
+	providers.Lock()
+	defer providers.Unlock()
+	delete(providers.byScheme, strings.ToLower(scheme))
+}
+
+// ProviderSchemes returns the schemes that have providers, in order.
+func ProviderSchemes() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	schemes := make([]string, 0, len(providers.byScheme))
+	for scheme := range providers.byScheme {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// LookupProvider returns the provider for rawURL, and the URL parsed, or nil if no provider has its scheme.
+func LookupProvider(rawURL string) (Provider, *url.URL) {
+	scheme, _, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return nil, nil
+	}
+
+	providers.RLock()
+	p := providers.byScheme[strings.ToLower(scheme)]
+	providers.RUnlock()
+	if p == nil {
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil
+	}
+	return p, u
+}
+
+// GetProvider is LookupProvider for when there should be a provider, such as for a job being resumed.
+func GetProvider(rawURL string) (Provider, *url.URL, error) {
+	p, u := LookupProvider(rawURL)
+	if p == nil {
+		supported := "none"
+		if schemes := ProviderSchemes(); len(schemes) > 0 {
+			supported = strings.Join(schemes, ", ")
+		}
+		return nil, nil, fmt.Errorf("no provider is registered for %s (this build of AzCopy has providers for: %s)",
+			URLStringExtension(rawURL).RedactSecretQueryParamForLogging(), supported)
+	}
+	return p, u, nil
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopProvider struct{}
+
+func (nopProvider) Enumerate(ctx context.Context, root *url.URL, recursive bool, found func(ProviderObject) error) error {
+	return nil
+}
+
+func (nopProvider) Properties(ctx context.Context, u *url.URL) (ProviderObject, error) {
+	return ProviderObject{}, ErrProviderObjectNotFound
+}
+
+func (nopProvider) Open(ctx context.Context, u *url.URL) (CloseableReaderAt, error) {
+	return nil, ErrProviderObjectNotFound
+}
+
+func (nopProvider) Create(ctx context.Context, u *url.URL, obj ProviderObject) (ProviderWriter, error) {
+	return nil, ErrProviderObjectNotFound
+}
+
+func TestRegisterProvider(t *testing.T) {
+	a := assert.New(t)
+
+	RegisterProvider("TestNop", nopProvider{})
+	t.Cleanup(func() { unregisterProvider("testnop") })
+	a.Contains(ProviderSchemes(), "testnop")
+
+	// registering the same scheme twice, single-letter schemes and built-in schemes are all refused
+	a.Panics(func() { RegisterProvider("testnop", nopProvider{}) })
+	a.Panics(func() { RegisterProvider("c", nopProvider{}) })
+	a.Panics(func() { RegisterProvider("https", nopProvider{}) })
+
+	p, u := LookupProvider("testnop://bucket/dir/file.txt")
+	a.NotNil(p)
+	a.Equal("bucket", u.Host)
+	a.Equal("/dir/file.txt", u.Path)
+
+	p, _ = LookupProvider("unknownscheme://bucket/file.txt")
+	a.Nil(p)
+	p, _ = LookupProvider(`C:\data\file.txt`)
+	a.Nil(p)
+
+	_, _, err := GetProvider("unknownscheme://bucket/file.txt?sig=secret")
+	a.Error(err)
+	a.Contains(err.Error(), "testnop")
+	a.NotContains(err.Error(), "secret")
+}
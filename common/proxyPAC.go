@@ -0,0 +1,227 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	pacFetchTimeout  = 30 * time.Second
+	pacMaxSize       = 1024 * 1024
+	pacCacheDuration = 5 * time.Minute // as with ProxyLookupCache, plenty, given how long AzCopy's retries go on for
+)
+
+// pacProxyFunc finds the proxy for each request by running the proxy auto-config (PAC) file at location, which is
+// an http://, https:// or file:// URL, or a path. It's fetched when the first request is made, and, if that fails,
+// again when a request is made a few minutes later. As browsers do, it's given only the scheme and host of the URL,
+// and its answer for them is kept for a few minutes, so that its DNS lookups aren't made for every request. Only the
+// first proxy that it gives is used; AzCopy's retries take the place of falling back to the others.
+func pacProxyFunc(location string) ProxyLookupFunc {
+	loader := &pacLoader{location: location}
+	return func(req *http.Request) (*url.URL, error) {
+		now := time.Now()
+		p, err := loader.get(now)
+		if err != nil {
+			return nil, err
+		}
+		return p.proxyFor(req.URL, now)
+	}
+}
+
+// pacLoader fetches and parses the PAC file once it's first needed. A failure is kept for as long as an answer from
+// the file would be, so that a PAC server that's down isn't asked for every request, but also doesn't fail the rest
+// of a long job.
+type pacLoader struct {
+	location string
+
+	mu      sync.Mutex
+	proxy   *pacProxy
+	err     error
+	retryAt time.Time
+}
+
+func (l *pacLoader) get(now time.Time) (*pacProxy, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.proxy != nil {
+		return l.proxy, nil
+	}
+	if l.err != nil && now.Before(l.retryAt) {
+		return nil, l.err
+	}
+
+	l.proxy, l.err = loadPAC(l.location)
+	if l.err != nil {
+		l.retryAt = now.Add(pacCacheDuration)
+	}
+	return l.proxy, l.err
+}
+
+func loadPAC(location string) (*pacProxy, error) {
+	src, err := fetchPAC(location)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch the PAC file %s: %w", location, err)
+	}
+	script, err := newPACScript(src, newPACEnvironment().builtins())
+	if err != nil {
+		return nil, fmt.Errorf("the PAC file %s can't be used: %w", location, err)
+	}
+	return newPACProxy(script), nil
+}
+
+func fetchPAC(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		// it's fetched directly, since it's what says which proxy to go through
+		client := &http.Client{Timeout: pacFetchTimeout, Transport: &http.Transport{DialContext: NewDialer(net.Dialer{}).DialContext}}
+		resp, err := client.Get(location)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("the server responded %s", resp.Status)
+		}
+		buf, err := io.ReadAll(io.LimitReader(resp.Body, pacMaxSize))
+		return string(buf), err
+	}
+
+	path := location
+	if err == nil && u.Scheme == "file" {
+		path = u.Path
+		if runtime.GOOS == "windows" {
+			// file:///C:/proxy.pac
+			path = filepath.FromSlash(strings.TrimPrefix(path, "/"))
+		}
+	}
+	buf, err := os.ReadFile(path)
+	return string(buf), err
+}
+
+type pacResult struct {
+	proxy   *url.URL
+	err     error
+	expires time.Time
+}
+
+// pacProxy runs a PAC file's FindProxyForURL for each host that it hasn't got a recent answer for. The script is
+// run for one host at a time, since a PAC file's functions may share global variables, but answers that are kept
+// are given without waiting for it, so a slow DNS lookup for one host doesn't hold up requests to the others.
+type pacProxy struct {
+	scriptMu sync.Mutex
+	script   *pacScript
+
+	mu      sync.Mutex
+	results map[string]pacResult     // by scheme://host
+	running map[string]chan struct{} // closed once the script has answered for the host
+}
+
+func newPACProxy(script *pacScript) *pacProxy {
+	return &pacProxy{script: script, results: map[string]pacResult{}, running: map[string]chan struct{}{}}
+}
+
+func (p *pacProxy) proxyFor(u *url.URL, now time.Time) (*url.URL, error) {
+	key := u.Scheme + "://" + u.Host + "/"
+	var done chan struct{}
+	for done == nil {
+		p.mu.Lock()
+		if r, ok := p.results[key]; ok && now.Before(r.expires) {
+			p.mu.Unlock()
+			return r.proxy, r.err
+		}
+		if running, ok := p.running[key]; ok {
+			// another request for the host is already waiting for the script, so share its answer
+			p.mu.Unlock()
+			<-running
+			continue
+		}
+		done = make(chan struct{})
+		p.running[key] = done
+		p.mu.Unlock()
+	}
+
+	r := pacResult{expires: now.Add(pacCacheDuration)}
+	p.scriptMu.Lock()
+	result, err := p.script.call("FindProxyForURL", key, u.Hostname())
+	p.scriptMu.Unlock()
+	if err != nil {
+		r.err = fmt.Errorf("the PAC file failed for %s: %w", u.Host, err)
+	} else {
+		r.proxy, r.err = parsePACResult(result.String())
+	}
+
+	p.mu.Lock()
+	p.results[key] = r
+	delete(p.running, key)
+	p.mu.Unlock()
+	close(done)
+	return r.proxy, r.err
+}
+
+// parsePACResult is the first proxy in what FindProxyForURL returns, as in "PROXY proxy:8080; SOCKS5 socks:1080;
+// DIRECT", or nil for DIRECT
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			continue // such as SOCKS4, which Go can't use
+		}
+		if len(fields) > 1 {
+			return &url.URL{Scheme: scheme, Host: fields[1]}, nil
+		}
+	}
+	return nil, fmt.Errorf("the PAC file gave '%s', which has no proxy that AzCopy can use", result)
+}
+
+// bypassProxyFunc sends requests to the hosts in bypass directly, and the rest to the proxy that lookup gives. bypass
+// is a list, separated by commas, semicolons or spaces, of host names, which cover the names under them too; domains
+// that start with a dot or *., which cover only the names under them; IP addresses and CIDR ranges; any of them with
+// a :port; and <local>, for names without a dot, as in Windows' proxy bypass list.
+func bypassProxyFunc(bypass string, lookup ProxyLookupFunc) ProxyLookupFunc {
+	local := false
+	var entries []string
+	for _, e := range strings.FieldsFunc(bypass, func(r rune) bool { return r == ',' || r == ';' || unicode.IsSpace(r) }) {
+		if strings.EqualFold(e, "<local>") {
+			local = true
+			continue
+		}
+		entries = append(entries, e)
+	}
+	// httpproxy gives no proxy for the hosts in its NO_PROXY list
+	matcher := (&httpproxy.Config{HTTPProxy: "bypass.invalid", HTTPSProxy: "bypass.invalid", NoProxy: strings.Join(entries, ",")}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Hostname()
+		if local && !strings.Contains(host, ".") && net.ParseIP(host) == nil {
+			return nil, nil
+		}
+		if u, _ := matcher(req.URL); u == nil {
+			return nil, nil
+		}
+		return lookup(req)
+	}
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ReportSigner signs the reports that AzCopy writes, so that they can be shown not to have been changed since. The
+// signature is detached, and is what OpenSSL makes and checks, so that the report can be checked without AzCopy:
+//
+//	openssl pkeyutl -verify -pubin -inkey public.pem -rawin -in report.json -sigfile report.json.sig   (Ed25519)
+//	openssl dgst -sha256 -verify public.pem -signature report.json.sig report.json                    (RSA and ECDSA)
+type ReportSigner struct {
+	signer crypto.Signer
+}
+
+// NewReportSigner reads the private key to sign reports with from a PEM file, in PKCS #8 form (as made by
+// "openssl genpkey"), or in the PKCS #1 or SEC 1 forms of RSA and EC keys
+func NewReportSigner(path string) (*ReportSigner, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the signing key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("the signing key %s isn't in PEM form", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing the signing key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return &ReportSigner{signer: k}, nil
+	case *rsa.PrivateKey:
+		return &ReportSigner{signer: k}, nil
+	case *ecdsa.PrivateKey:
+		return &ReportSigner{signer: k}, nil
+	default:
+		return nil, errors.New("the signing key must be an Ed25519, RSA or ECDSA private key")
+	}
+}
+
+// Sign returns the signature of report. Ed25519 signs the report itself, and the others its SHA-256 digest.
+func (s *ReportSigner) Sign(report []byte) ([]byte, error) {
+	if _, ok := s.signer.(ed25519.PrivateKey); ok {
+		return s.signer.Sign(rand.Reader, report, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(report)
+	return s.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// WriteSignedReport writes report to path, and, if signer isn't nil, its signature beside it, in path + ".sig"
+func WriteSignedReport(path string, report []byte, signer *ReportSigner) error {
+	if err := os.WriteFile(path, report, 0644); err != nil {
+		return err
+	}
+	if signer == nil {
+		return nil
+	}
+	signature, err := signer.Sign(report)
+	if err != nil {
+		return fmt.Errorf("signing the report: %w", err)
+	}
+	return os.WriteFile(path+".sig", signature, 0644)
+}
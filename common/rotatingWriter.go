@@ -102,8 +102,8 @@ func (w *rotatingWriter) Write(p []byte) (n int, err error) {
 	// We have to save curSuffix here so that if we rotate() the
 	// same log file we checked here.
 	currSuffix := atomic.LoadInt32(&w.currentSuffix)
-	if atomic.AddUint64(&w.currentSize, uint64(len(p))) <= w.maxLogSize {
-		// we've enough size
+	if atomic.AddUint64(&w.currentSize, uint64(len(p))) <= w.maxLogSize || InSandbox() {
+		// we've enough size, or are in the sandbox, where the log can't be renamed, so it just keeps growing
 		return w.file.Write(p)
 	}
 
@@ -106,6 +106,13 @@ type CopyJobPartOrderRequest struct {
 	ForceWrite          OverwriteOption // to determine if the existing needs to be overwritten or not. If set to true, existing blobs are overwritten
 	ForceIfReadOnly     bool            // Supplements ForceWrite with addition setting for Azure Files objects with read-only attribute
 	AutoDecompress      bool            // if true, source data with encodings that represent compression are automatically decompressed when downloading
+	KeepCompressed      bool            // if true, blobs that were compressed as they were uploaded aren't decompressed when downloading either
+	ExtractArchivePacks bool            // if true, archive packs are extracted as they are downloaded, instead of being saved
+	Untar               bool            // if true, tar archives (gzipped or not) are extracted as they are downloaded, instead of being saved
+	Unzip               bool            // if true, zip archives are extracted as they are downloaded, instead of being saved
+	UploadCompression   CompressionType // files are compressed with this as they are uploaded, and the blobs' Content-Encoding says so
+	ClientSideKey       string          // the URL of a Key Vault key, or the path of a key file, that blobs are encrypted with on the client
+	HoldTransfers       bool            // if true, the part's transfers aren't scheduled until the scan is over and --sandbox has been entered
 	Priority            JobPriority     // priority of the task
 	FromTo              FromTo
 	Fpo                 FolderPropertyOption // passed in from front-end to ensure that front-end and STE agree on the desired behaviour for the job
@@ -131,11 +138,16 @@ type CopyJobPartOrderRequest struct {
 	PreservePermissions            PreservePermissionsOption
 	PreserveInfo                   bool
 	PreservePOSIXProperties        bool
+	PreserveACLs                   bool
+	PreserveExtAttrs               bool
+	PreserveFileFlags              bool
+	RestoreOwner                   bool // chown downloads to the source's owner and group, when running as root
 	S2SGetPropertiesInBackend      bool
 	S2SSourceChangeValidation      bool
 	DestLengthValidation           bool
 	S2SInvalidMetadataHandleOption InvalidMetadataHandleOption
 	S2SPreserveBlobTags            bool
+	S2SPreserveEncryptionScope     bool
 	CpkOptions                     CpkOptions
 	SetPropertiesFlags             SetPropertiesFlags
 	BlobFSRecursiveDelete          bool
@@ -199,6 +211,8 @@ type BlobTransferAttributes struct {
 	PreserveLastModifiedTime         bool                  // when downloading, tell engine to set file's timestamp to timestamp of blob
 	PutMd5                           bool                  // when uploading, should we create and PUT Content-MD5 hashes
 	MD5ValidationOption              HashValidationOption  // when downloading, how strictly should we validate MD5 hashes?
+	ContentHashType                  ContentHashType       // the hash that is put on upload and validated on download, which need not be MD5
+	Fsync                            FsyncPolicy           // when downloading, when files are synced to disk
 	BlockSizeInBytes                 int64                 // when uploading/downloading/copying, specify the size of each chunk
 	PutBlobSizeInBytes               int64                 // when uploading, specify the threshold to determine if the blob should be uploaded in a single PUT request
 	DeleteSnapshotsOption            DeleteSnapshotsOption // when deleting, specify what to do with the snapshots
@@ -206,6 +220,8 @@ type BlobTransferAttributes struct {
 	PermanentDeleteOption            PermanentDeleteOption // Permanently deletes soft-deleted snapshots when indicated by user
 	RehydratePriority                RehydratePriorityType // rehydrate priority of blob
 	DeleteDestinationFileIfNecessary bool                  // deletes the dst blob if indicated
+	BlockDelta                       bool                  // when uploading to block blobs, send only the blocks the destination blob doesn't have already
+	ContentDefinedBlocks             bool                  // when uploading with BlockDelta, split files into blocks where their content says, instead of every BlockSizeInBytes
 }
 
 // This struct represents the optional attribute for file request header
@@ -258,6 +274,11 @@ type ListJobSummaryResponse struct {
 	FoldersSkipped     uint32 `json:",string"`
 	TransfersSkipped   uint32 `json:",string"`
 
+	// how many of TransfersFailed weren't authorized (401 or 403), and how many were failed by the network, which
+	// the exit code is chosen by
+	TransfersFailedAuth    uint32 `json:",string"`
+	TransfersFailedNetwork uint32 `json:",string"`
+
 	// includes bytes sent in retries (i.e. has double counting, if there are retries) and in failed transfers
 	BytesOverWire uint64 `json:",string"`
 
@@ -278,6 +299,12 @@ type ListJobSummaryResponse struct {
 	AverageE2EMilliseconds int     `json:",string"`
 	ServerBusyPercentage   float32 `json:",string"`
 	NetworkErrorPercentage float32 `json:",string"`
+	// the retries that waited as long as the server asked with Retry-After, and how long they waited in all
+	RetryAfterCount       int64 `json:",string"`
+	ThrottledMilliseconds int64 `json:",string"`
+	// the tries that were cancelled and retried because their connections stalled, and how long they ran in all
+	StallCount          int64 `json:",string"`
+	StalledMilliseconds int64 `json:",string"`
 
 	FailedTransfers         []TransferDetail
 	SkippedTransfers        []TransferDetail
@@ -288,6 +315,21 @@ type ListJobSummaryResponse struct {
 	SkippedSymlinkCount     uint32 `json:",string"`
 	HardlinksConvertedCount uint32 `json:",string"`
 	SkippedSpecialFileCount uint32 `json:",string"`
+	SkippedNodumpCount      uint32 `json:",string"`
+	// the first MaxListedSpecialFiles of the special files counted in SkippedSpecialFileCount
+	SkippedSpecialFiles []SkippedSpecialFile `json:",omitempty"`
+	// how far the scan of the source has got, while it's still going on
+	ScanProgress *ScanProgress `json:",omitempty"`
+}
+
+// ScanProgress is how many files and folders a job's scan has found, how quickly it's finding them, and where it's got to
+type ScanProgress struct {
+	FilesFound       uint64 `json:",string"`
+	FoldersFound     uint64 `json:",string"`
+	FilesPerSecond   float64
+	FoldersPerSecond float64
+	// the last file or folder found that wasn't filtered out, relative to the root of the scan
+	CurrentPath string
 }
 
 // wraps the standard ListJobSummaryResponse with sync-specific stats
@@ -308,9 +350,11 @@ type ResumeJobRequest struct {
 	DestinationSAS   string
 	SrcServiceClient *ServiceClient
 	DstServiceClient *ServiceClient
-	IncludeTransfer  map[string]int
-	ExcludeTransfer  map[string]int
+	FailedOnly       bool     // retry only the transfers that failed
+	IncludeTransfer  []string // if any, retry only the transfers whose source matches one of these patterns
+	ExcludeTransfer  []string // don't retry the transfers whose source matches any of these patterns
 	CredentialInfo   CredentialInfo
+	ContinueScan     bool // resume a job whose scan was interrupted, whose remaining parts are about to be ordered
 }
 
 // represents the Details and details of a single transfer
@@ -321,6 +365,7 @@ type TransferDetail struct {
 	TransferStatus     TransferStatus
 	TransferSize       uint64
 	ErrorCode          int32 `json:",string"`
+	IsNetworkFailure   bool  `json:"-"` // whether it failed because of the network, rather than the service or the local system
 }
 
 type CancelPauseResumeResponse struct {
@@ -50,9 +50,9 @@ type S3URLParts struct {
 	Region         string // Ex: endpoint region, e.g. "eu-west-1"
 	UnparsedParams string
 
-	isPathStyle bool
-	isDualStack bool
-	// TODO: Other S3 compatible service which might be with IP endpoint style
+	isPathStyle      bool
+	isDualStack      bool
+	isCompatibleHost bool // on the S3-compatible service named by AZCOPY_S3_ENDPOINT, rather than AWS
 }
 
 const s3HostPattern = "^(?P<bucketName>.+\\.)?s3[.-](?P<dualStackOrRegionOrAWSDomain>[a-z0-9-]+)\\.(?P<regionOrAWSDomainOrCom>[a-z0-9-]+)"
@@ -69,7 +69,24 @@ func IsS3URL(u url.URL) bool {
 	if _, isS3URL := findS3URLMatches(strings.ToLower(u.Host)); isS3URL {
 		return true
 	}
-	return false
+	return IsS3CompatibleHost(u.Host)
+}
+
+// S3CompatibleEndpoint returns the host[:port] given in AZCOPY_S3_ENDPOINT, lower-cased, and whether it is reached over TLS.
+// It returns an empty host if no S3-compatible service has been configured.
+func S3CompatibleEndpoint() (host string, secure bool) {
+	endpoint := strings.ToLower(strings.TrimSpace(GetEnvironmentVariable(EEnvironmentVariable.S3Endpoint())))
+	if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		return strings.TrimSuffix(rest, "/"), false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(endpoint, "https://"), "/"), true
+}
+
+// IsS3CompatibleHost says whether host is the S3-compatible service named by AZCOPY_S3_ENDPOINT, or a virtual-hosted bucket on it.
+func IsS3CompatibleHost(host string) bool {
+	endpoint, _ := S3CompatibleEndpoint()
+	host = strings.ToLower(host)
+	return endpoint != "" && (host == endpoint || strings.HasSuffix(host, "."+endpoint))
 }
 
 func findS3URLMatches(host string) (matches []string, isS3Host bool) {
@@ -86,7 +103,7 @@ func NewS3URLParts(u url.URL) (S3URLParts, error) {
 	host := strings.ToLower(u.Host)
 
 	matchSlices, isS3URL := findS3URLMatches(host)
-	if !isS3URL {
+	if !isS3URL && !IsS3CompatibleHost(host) {
 		return S3URLParts{}, errors.New(invalidS3URLErrorMessage)
 	}
 
@@ -102,7 +119,19 @@ func NewS3URLParts(u url.URL) (S3URLParts, error) {
 	}
 
 	// Check what's the path style, and parse accordingly.
-	if matchSlices[1] != "" { // Go's implementation is a bit strange, even if the first subexp fail to be matched, "" will be returned for that sub exp
+	if !isS3URL {
+		// an S3-compatible service has no naming scheme of its own to go by: the bucket is whatever comes before its host
+		up.isCompatibleHost = true
+		up.Endpoint, _ = S3CompatibleEndpoint()
+		up.Region = GetEnvironmentVariable(EEnvironmentVariable.S3Region())
+		if host == up.Endpoint {
+			up.isPathStyle = true
+			up.BucketName, up.ObjectKey, _ = strings.Cut(path, "/")
+		} else {
+			up.BucketName = strings.TrimSuffix(host, "."+up.Endpoint)
+			up.ObjectKey = path
+		}
+	} else if matchSlices[1] != "" { // Go's implementation is a bit strange, even if the first subexp fail to be matched, "" will be returned for that sub exp
 		// In this case, it would be in virtual-hosted-style URL, and has host prefix like bucket.s3[-.]
 		up.BucketName = matchSlices[1][:len(matchSlices[1])-1] // Removing the trailing '.' at the end
 		up.ObjectKey = path
@@ -121,14 +150,16 @@ func NewS3URLParts(u url.URL) (S3URLParts, error) {
 
 		up.Endpoint = host
 	}
-	// Check if dualstack is contained in host name
-	if matchSlices[2] == s3KeywordDualStack {
-		up.isDualStack = true
-		if matchSlices[3] != s3KeywordAmazonAWS {
-			up.Region = matchSlices[3]
+	// Check if dualstack is contained in host name. (An S3-compatible service's region, if any, came from AZCOPY_S3_REGION.)
+	if isS3URL {
+		if matchSlices[2] == s3KeywordDualStack {
+			up.isDualStack = true
+			if matchSlices[3] != s3KeywordAmazonAWS {
+				up.Region = matchSlices[3]
+			}
+		} else if matchSlices[2] != s3KeywordAmazonAWS {
+			up.Region = matchSlices[2]
 		}
-	} else if matchSlices[2] != s3KeywordAmazonAWS {
-		up.Region = matchSlices[2]
 	}
 
 	// Convert the query parameters to a case-sensitive map & trim whitespace
@@ -181,6 +212,11 @@ func (p *S3URLParts) String() string {
 	return u.String()
 }
 
+// IsS3Compatible says whether the URL is on the S3-compatible service named by AZCOPY_S3_ENDPOINT, rather than AWS
+func (p *S3URLParts) IsS3Compatible() bool {
+	return p.isCompatibleHost
+}
+
 func (p *S3URLParts) IsServiceSyntactically() bool {
 	if p.Host != "" && p.BucketName == "" {
 		return true
@@ -201,4 +201,61 @@ func TestS3URLParseNegative(t *testing.T) {
 	_, err = NewS3URLParts(*u)
 	a.NotNil(err)
 	a.True(strings.Contains(err.Error(), invalidS3URLErrorMessage))
-}
\ No newline at end of file
+}
+func TestS3CompatibleURLParse(t *testing.T) {
+	a := assert.New(t)
+	u, _ := url.Parse("http://minio.example.com:9000/bucket/keydir/keyname?versionId=v1")
+	a.False(IsS3URL(*u))
+	_, err := NewS3URLParts(*u)
+	a.NotNil(err)
+
+	t.Setenv(EEnvironmentVariable.S3Endpoint().Name, "http://MinIO.example.com:9000/")
+	t.Setenv(EEnvironmentVariable.S3Region().Name, "us-east-1")
+	host, secure := S3CompatibleEndpoint()
+	a.Equal("minio.example.com:9000", host)
+	a.False(secure)
+
+	// path-style
+	a.True(IsS3URL(*u))
+	p, err := NewS3URLParts(*u)
+	a.Nil(err)
+	a.True(p.IsS3Compatible())
+	a.Equal("minio.example.com:9000", p.Endpoint)
+	a.Equal("bucket", p.BucketName)
+	a.Equal("keydir/keyname", p.ObjectKey)
+	a.Equal("v1", p.Version)
+	a.Equal("us-east-1", p.Region)
+	a.Equal("http://minio.example.com:9000/bucket/keydir/keyname?versionId=v1", p.String())
+
+	u, _ = url.Parse("http://minio.example.com:9000/bucket")
+	p, err = NewS3URLParts(*u)
+	a.Nil(err)
+	a.True(p.IsBucketSyntactically())
+	u, _ = url.Parse("http://minio.example.com:9000")
+	p, err = NewS3URLParts(*u)
+	a.Nil(err)
+	a.True(p.IsServiceSyntactically())
+
+	// virtual-hosted-style
+	u, _ = url.Parse("http://bucket.minio.example.com:9000/keyname")
+	p, err = NewS3URLParts(*u)
+	a.Nil(err)
+	a.Equal("minio.example.com:9000", p.Endpoint)
+	a.Equal("bucket", p.BucketName)
+	a.Equal("keyname", p.ObjectKey)
+	a.Equal("http://bucket.minio.example.com:9000/keyname", p.String())
+
+	// neither AWS nor anything else is mistaken for it
+	u, _ = url.Parse("https://s3.amazonaws.com/bucket")
+	p, err = NewS3URLParts(*u)
+	a.Nil(err)
+	a.False(p.IsS3Compatible())
+	a.Equal("", p.Region)
+	u, _ = url.Parse("http://notminio.example.com:9000/bucket")
+	a.False(IsS3URL(*u))
+
+	t.Setenv(EEnvironmentVariable.S3Endpoint().Name, "10.0.0.5:9000")
+	host, secure = S3CompatibleEndpoint()
+	a.Equal("10.0.0.5:9000", host)
+	a.True(secure)
+}
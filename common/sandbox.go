@@ -0,0 +1,149 @@
+package common
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const SandboxFlagName = "sandbox"
+
+// A Sandbox confines AzCopy, for --sandbox, to capsicum(4)'s capability mode, in which nothing can be opened by its
+// path, and no connection can be made. So files are opened beneath directories that were opened beforehand, with
+// openat(2), and connections are made by the system.net service of Casper (libcasper(3)), which runs outside the
+// sandbox, and which looks names up and connects the sockets it's handed.
+type Sandbox struct {
+	wd    string // the working directory, since relative paths can't be made absolute in capability mode
+	roots []sandboxRoot
+	net   sandboxNet
+}
+
+type sandboxRoot struct {
+	dir  string
+	root *os.Root
+}
+
+// sandboxNet makes connections for a process in capability mode
+type sandboxNet interface {
+	dial(ctx context.Context, network, address string, dialer net.Dialer) (net.Conn, error)
+}
+
+// currentSandbox is the sandbox that has been opened, if any, and inCapabilityMode is set once it has been entered
+var currentSandbox atomic.Pointer[Sandbox]
+var inCapabilityMode atomic.Bool
+
+var errNotInSandbox = errors.New("it isn't beneath any of the directories that --sandbox opened")
+
+// OpenSandbox gets ready to confine the process to the directories dirs. From then on, connections are made through
+// Casper, and files beneath dirs are opened beneath them, as they will have to be once Enter has been called.
+func OpenSandbox(dirs ...string) (*Sandbox, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{wd: wd}
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(wd, dir)
+		}
+		root, err := os.OpenRoot(dir)
+		if err != nil {
+			s.closeRoots()
+			return nil, fmt.Errorf("couldn't open %s for the sandbox: %w", dir, err)
+		}
+		s.roots = append(s.roots, sandboxRoot{dir: filepath.Clean(dir), root: root})
+	}
+	if s.net, err = openSandboxNet(); err != nil {
+		s.closeRoots()
+		return nil, err
+	}
+	if !currentSandbox.CompareAndSwap(nil, s) {
+		s.closeRoots()
+		return nil, errors.New("a sandbox has already been opened")
+	}
+	return s, nil
+}
+
+func (s *Sandbox) closeRoots() {
+	for _, r := range s.roots {
+		_ = r.root.Close()
+	}
+}
+
+// Enter puts the process in capability mode. What Go loads from the file system the first time that it's needed is
+// loaded first, since it couldn't be afterwards: the trusted root certificates, the local time zone and the MIME types.
+func (s *Sandbox) Enter() error {
+	_, _ = x509.SystemCertPool()
+	_, _ = time.Now().Zone()
+	_ = mime.TypeByExtension(".txt")
+
+	if err := enterCapabilityMode(); err != nil {
+		return err
+	}
+	inCapabilityMode.Store(true)
+	return nil
+}
+
+// InSandbox says whether the process is in capability mode, so that what can't be done there, such as renaming a file,
+// can be left undone
+func InSandbox() bool {
+	return inCapabilityMode.Load()
+}
+
+// beneath finds the root that path is beneath, the deepest if there's more than one, and path relative to it
+func (s *Sandbox) beneath(path string) (*os.Root, string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.wd, path)
+	}
+	path = filepath.Clean(path)
+
+	var found *sandboxRoot
+	var rel string
+	for i, r := range s.roots {
+		if path != r.dir && !strings.HasPrefix(path, strings.TrimSuffix(r.dir, string(os.PathSeparator))+string(os.PathSeparator)) {
+			continue
+		}
+		if found == nil || len(r.dir) > len(found.dir) {
+			found = &s.roots[i]
+			rel, _ = filepath.Rel(r.dir, path)
+		}
+	}
+	if found == nil {
+		return nil, "", false
+	}
+	return found.root, rel, true
+}
+
+// SandboxOpenFile is os.OpenFile, except that once a sandbox has been opened, a file beneath one of its directories is
+// opened beneath that directory
+func SandboxOpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if s := currentSandbox.Load(); s != nil {
+		if root, rel, ok := s.beneath(name); ok {
+			return root.OpenFile(rel, flag, perm)
+		} else if InSandbox() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errNotInSandbox}
+		}
+	}
+	return os.OpenFile(name, flag, perm)
+}
+
+// SandboxStat is os.Stat, except that once a sandbox has been opened, a file beneath one of its directories is looked
+// up beneath that directory
+func SandboxStat(name string) (os.FileInfo, error) {
+	if s := currentSandbox.Load(); s != nil {
+		if root, rel, ok := s.beneath(name); ok {
+			return root.Stat(rel)
+		} else if InSandbox() {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: errNotInSandbox}
+		}
+	}
+	return os.Stat(name)
+}
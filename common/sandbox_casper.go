@@ -0,0 +1,257 @@
+//go:build freebsd && cgo
+
+package common
+
+/*
+#cgo CFLAGS: -DWITH_CASPER
+#cgo LDFLAGS: -lcasper -lcap_net
+
+#include <sys/types.h>
+#include <sys/socket.h>
+#include <sys/nv.h>
+#include <netinet/in.h>
+#include <netdb.h>
+#include <errno.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include <libcasper.h>
+#include <casper/cap_net.h>
+
+// azcopy_open_net opens Casper's system.net service, limited to looking names up and connecting sockets
+static cap_channel_t *azcopy_open_net(void) {
+	cap_channel_t *casper = cap_init();
+	if (casper == NULL)
+		return NULL;
+	cap_channel_t *chan = cap_service_open(casper, "system.net");
+	cap_close(casper);
+	if (chan == NULL)
+		return NULL;
+
+	cap_net_limit_t *limit = cap_net_limit_init(chan, CAPNET_NAME2ADDR | CAPNET_CONNECT);
+	if (limit == NULL || cap_net_limit(limit) != 0) {
+		int saved = errno;
+		cap_close(chan);
+		errno = saved;
+		return NULL;
+	}
+	return chan;
+}
+
+// azcopy_connect has the service connect the socket s to ip, of 4 or 16 bytes, and port
+static int azcopy_connect(cap_channel_t *chan, int s, int family, const void *ip, int port) {
+	if (family == AF_INET) {
+		struct sockaddr_in sin;
+		memset(&sin, 0, sizeof(sin));
+		sin.sin_len = sizeof(sin);
+		sin.sin_family = AF_INET;
+		sin.sin_port = htons(port);
+		memcpy(&sin.sin_addr, ip, 4);
+		return cap_connect(chan, s, (struct sockaddr *)&sin, sizeof(sin));
+	}
+
+	struct sockaddr_in6 sin6;
+	memset(&sin6, 0, sizeof(sin6));
+	sin6.sin6_len = sizeof(sin6);
+	sin6.sin6_family = AF_INET6;
+	sin6.sin6_port = htons(port);
+	memcpy(&sin6.sin6_addr, ip, 16);
+	return cap_connect(chan, s, (struct sockaddr *)&sin6, sizeof(sin6));
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxIdleCasperChannels is how many channels to the service are kept for connections to be made through. A channel
+// carries one request at a time, and a connection can take a while to make, so each connection has a channel of its
+// own while it's being made; more than this are cloned when needed, and closed afterwards.
+const maxIdleCasperChannels = 16
+
+// casperNet makes connections through Casper's system.net service
+type casperNet struct {
+	mu   sync.Mutex // guards base, which is only used to clone channels
+	base *C.cap_channel_t
+	idle chan *C.cap_channel_t
+}
+
+func openSandboxNet() (sandboxNet, error) {
+	base, err := C.azcopy_open_net()
+	if base == nil {
+		return nil, fmt.Errorf("couldn't open Casper's system.net service for the sandbox: %w", err)
+	}
+	return &casperNet{base: base, idle: make(chan *C.cap_channel_t, maxIdleCasperChannels)}, nil
+}
+
+func enterCapabilityMode() error {
+	if _, _, errno := unix.Syscall(unix.SYS_CAP_ENTER, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("couldn't enter capability mode: %w", errno)
+	}
+	return nil
+}
+
+func (n *casperNet) channel() (*C.cap_channel_t, error) {
+	select {
+	case ch := <-n.idle:
+		return ch, nil
+	default:
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch, err := C.cap_clone(n.base)
+	if ch == nil {
+		return nil, fmt.Errorf("couldn't open another channel to Casper's system.net service: %w", err)
+	}
+	return ch, nil
+}
+
+func (n *casperNet) release(ch *C.cap_channel_t) {
+	select {
+	case n.idle <- ch:
+	default:
+		C.cap_close(ch)
+	}
+}
+
+func (n *casperNet) dial(ctx context.Context, network, address string, dialer net.Dialer) (net.Conn, error) {
+	if dialer.LocalAddr != nil {
+		return nil, errors.New("connections can't be made from a given source address in the sandbox")
+	}
+	family := C.int(C.AF_UNSPEC)
+	switch network {
+	case "tcp":
+	case "tcp4":
+		family = C.AF_INET
+	case "tcp6":
+		family = C.AF_INET6
+	default:
+		return nil, fmt.Errorf("%s connections can't be made in the sandbox", network)
+	}
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portString) // a named port would need /etc/services
+	if err != nil {
+		return nil, fmt.Errorf("the port of %s isn't a number", address)
+	}
+
+	if dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = n.lookup(host, family); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = n.connect(ctx, ip, port); err == nil {
+			if tcp, ok := conn.(*net.TCPConn); ok && dialer.KeepAlive > 0 {
+				_ = tcp.SetKeepAlive(true)
+				_ = tcp.SetKeepAlivePeriod(dialer.KeepAlive)
+			}
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, &net.OpError{Op: "dial", Net: network, Addr: nil, Err: err}
+}
+
+func (n *casperNet) lookup(host string, family C.int) ([]net.IP, error) {
+	ch, err := n.channel()
+	if err != nil {
+		return nil, err
+	}
+	defer n.release(ch)
+
+	cHost := C.CString(host)
+	defer C.free(unsafe.Pointer(cHost))
+	var hints C.struct_addrinfo
+	hints.ai_family = family
+	hints.ai_socktype = C.SOCK_STREAM
+	var result *C.struct_addrinfo
+	if rc := C.cap_getaddrinfo(ch, cHost, nil, &hints, &result); rc != 0 {
+		return nil, fmt.Errorf("couldn't look up %s: %s", host, C.GoString(C.gai_strerror(rc)))
+	}
+	defer C.freeaddrinfo(result)
+
+	var ips []net.IP
+	for ai := result; ai != nil; ai = ai.ai_next {
+		switch ai.ai_family {
+		case C.AF_INET:
+			sin := (*C.struct_sockaddr_in)(unsafe.Pointer(ai.ai_addr))
+			ips = append(ips, net.IP(C.GoBytes(unsafe.Pointer(&sin.sin_addr), 4)))
+		case C.AF_INET6:
+			sin6 := (*C.struct_sockaddr_in6)(unsafe.Pointer(ai.ai_addr))
+			ips = append(ips, net.IP(C.GoBytes(unsafe.Pointer(&sin6.sin6_addr), 16)))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", host)
+	}
+	return ips, nil
+}
+
+// connect makes a socket, which capability mode allows, and has the service connect it, which it doesn't. The service
+// can't be interrupted, so if ctx is done first, the socket is closed once it has finished.
+func (n *casperNet) connect(ctx context.Context, ip net.IP, port int) (net.Conn, error) {
+	family, raw := unix.AF_INET6, ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		family, raw = unix.AF_INET, ip4
+	}
+	fd, err := unix.Socket(family, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ch, err := n.channel()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer n.release(ch)
+		if rc, err := C.azcopy_connect(ch, C.int(fd), C.int(family), unsafe.Pointer(&raw[0]), C.int(port)); rc != 0 {
+			done <- err
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		go func() {
+			<-done
+			_ = unix.Close(fd)
+		}()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), "")
+	defer f.Close() // FileConn has its own copy
+	return net.FileConn(f)
+}
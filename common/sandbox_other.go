@@ -0,0 +1,15 @@
+//go:build !freebsd || !cgo
+
+package common
+
+import "errors"
+
+var errSandboxUnsupported = errors.New("the --" + SandboxFlagName + " flag is only supported on FreeBSD, by builds of AzCopy made with cgo")
+
+func openSandboxNet() (sandboxNet, error) {
+	return nil, errSandboxUnsupported
+}
+
+func enterCapabilityMode() error {
+	return errSandboxUnsupported
+}
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"hash"
 	"io"
+	"os"
 	"sync"
 )
 
@@ -143,6 +144,12 @@ type singleChunkReader struct {
 	// buffer used by prefetch
 	buffer []byte
 
+	// set when buffer is a slice of a memory-mapped source file, rather than from slicePool, to release it
+	releaseMapping func()
+
+	// true if the prefetch found the chunk to be a hole in a sparse source file, so buffer is known to be all zeros
+	isHole bool
+
 	// muMaster locks everything for single-threaded use...
 	muMaster *sync.Mutex
 
@@ -189,13 +196,24 @@ func (cr *singleChunkReader) HasPrefetchedEntirelyZeros() bool {
 	if cr.buffer == nil {
 		return false // not prefetched (and, to simply error handling in the caller, we don't call retryBlockingPrefetchIfNecessary here)
 	}
+	if cr.isHole {
+		return true
+	}
 
-	for _, b := range cr.buffer {
-		if b != 0 {
-			return false // it's not all zeroes
+	allZeros := func() bool {
+		for _, b := range cr.buffer {
+			if b != 0 {
+				return false // it's not all zeroes
+			}
 		}
+		return true
 	}
-	return true
+	if cr.releaseMapping != nil {
+		zeros := false // unless we can read it all
+		_ = readMapping(func() { zeros = allZeros() })
+		return zeros
+	}
+	return allZeros()
 
 	// note: we are not using this optimization: int64Slice := (*(*[]int64)(unsafe.Pointer(&rangeBytes)))[:len(rangeBytes)/8]
 	//       Why?  Because (a) it only works when chunk size is divisible by 8, and that's not universally the case (e.g. last chunk in a file)
@@ -238,12 +256,27 @@ func (cr *singleChunkReader) blockingPrefetch(fileReader io.ReaderAt, isRetry bo
 		cr.chunkLogger.LogChunkStatus(cr.chunkId, EWaitReason.DiskIO())
 	}
 
+	// a memory-mapped source file needs no reading into a buffer: the chunk is a slice of the mapping
+	if mapped, ok := fileReader.(*MappedSourceFile); ok {
+		return cr.useMapping(mapped)
+	}
+
 	targetBuffer := cr.slicePool.RentSlice(cr.length)
 
 	// read WITHOUT holding the "close" lock.  While we don't have the lock, we mutate ONLY local variables, no instance state.
 	// (Don't release the other lock, muMaster, since that's unnecessary would make it harder to reason about behaviour - e.g. is something other than Close happening?)
 	cr.muClose.Unlock()
-	n, readErr := fileReader.ReadAt(targetBuffer, cr.chunkId.OffsetInFile())
+	var n int
+	var readErr error
+	f, isFile := fileReader.(*os.File)
+	isHole := isFile && IsHole(f, cr.chunkId.OffsetInFile(), cr.length)
+	if isHole {
+		// no need to read what the file system already knows to be zeros. Pooled slices are not zeroed, so do that ourselves.
+		clear(targetBuffer)
+		n = len(targetBuffer)
+	} else {
+		n, readErr = fileReader.ReadAt(targetBuffer, cr.chunkId.OffsetInFile())
+	}
 	cr.muClose.Lock()
 
 	// now that we have the lock again, see if any error means we can't continue
@@ -264,6 +297,34 @@ func (cr *singleChunkReader) blockingPrefetch(fileReader io.ReaderAt, isRetry bo
 
 	// We can continue, so use the data we have read
 	cr.buffer = targetBuffer
+	cr.isHole = isHole
+	return nil
+}
+
+// useMapping makes the chunk's buffer a slice of a mapped source file. It's still counted against the RAM limit, as
+// its pages are resident from when it's hashed until it has been sent.
+func (cr *singleChunkReader) useMapping(mapped *MappedSourceFile) error {
+	var err error
+	if cr.isClosed {
+		err = errors.New("closed while reading")
+	} else if cr.ctx.Err() != nil {
+		err = cr.ctx.Err() // context cancelled
+	}
+	buffer, release, ok := mapped.mappedRange(cr.chunkId.OffsetInFile(), cr.length)
+	if err == nil && !ok {
+		err = errors.New("the chunk is past the end of the file, which must have shrunk")
+	}
+	if err != nil {
+		if ok {
+			release()
+		}
+		cr.cacheLimiter.Remove(cr.length)
+		return err
+	}
+
+	cr.buffer = buffer
+	cr.releaseMapping = release
+	cr.isHole = false
 	return nil
 }
 
@@ -355,7 +416,15 @@ func (cr *singleChunkReader) doRead(p []byte, freeBufferOnEof bool) (n int, err
 	}
 
 	// Copy the data across
-	bytesCopied := copy(p, cr.buffer[cr.positionInChunk:])
+	var bytesCopied int
+	if cr.releaseMapping != nil {
+		err = readMapping(func() { bytesCopied = copy(p, cr.buffer[cr.positionInChunk:]) })
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		bytesCopied = copy(p, cr.buffer[cr.positionInChunk:])
+	}
 	cr.positionInChunk += int64(bytesCopied)
 
 	// check for EOF
@@ -382,7 +451,12 @@ func (cr *singleChunkReader) closeBuffer() {
 }
 
 func (cr *singleChunkReader) returnSlice(slice []byte) {
-	cr.slicePool.ReturnSlice(slice)
+	if cr.releaseMapping != nil {
+		cr.releaseMapping()
+		cr.releaseMapping = nil
+	} else {
+		cr.slicePool.ReturnSlice(slice)
+	}
 	cr.cacheLimiter.Remove(int64(len(slice)))
 }
 
@@ -465,8 +539,17 @@ func (cr *singleChunkReader) WriteBufferTo(h hash.Hash) {
 	if cr.buffer == nil {
 		panic("invalid state. No prefetch buffer is present")
 	}
-	_, err := h.Write(cr.buffer)
-	if err != nil {
-		panic("documentation of hash.Hash.Write says it will never return an error")
+	write := func() {
+		_, err := h.Write(cr.buffer)
+		if err != nil {
+			panic("documentation of hash.Hash.Write says it will never return an error")
+		}
+	}
+	if cr.releaseMapping != nil {
+		// if the file has been truncated under the mapping, the hash is left incomplete, and it's the change detection
+		// at the end of the transfer that fails it
+		_ = readMapping(write)
+		return
 	}
+	write()
 }
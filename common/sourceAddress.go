@@ -0,0 +1,142 @@
+package common
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// sourceAddresses are the local addresses that AzCopy's connections are made from, in turn, once --source-ip or
+// --interface has been given
+var sourceAddresses atomic.Pointer[[]*net.TCPAddr]
+
+// nextSourceAddress counts the connections that have been made from sourceAddresses, to say whose turn it is
+var nextSourceAddress atomic.Uint64
+
+var sourceAddressDescription atomic.Value
+
+// BindSourceAddress makes the connections that are made from then on come from sourceIPs, or from the addresses of
+// the network interfaces in ifaces, so that a multi-homed host's traffic goes out on particular networks. Each is a
+// list, separated by commas, and only one of them may be given. With more than one address, each connection is made
+// from the next in turn, so that the transfer's connections, and their bandwidth, are spread across them. An interface
+// with both an IPv4 and an IPv6 address is bound by its IPv4 one, unless SetIPVersion has restricted connections to
+// IPv6; so SetIPVersion is called first. The addresses must all be of the same family, since the connections are only
+// made to addresses of the same family as their own.
+func BindSourceAddress(sourceIPs, ifaces string) error {
+	if sourceIPs == "" && ifaces == "" {
+		return nil
+	}
+	if sourceIPs != "" && ifaces != "" {
+		return errors.New("only one of --source-ip and --interface can be given")
+	}
+
+	var sources []*net.TCPAddr
+	var descriptions []string
+	version := IPVersion()
+	allAddrs, err := net.InterfaceAddrs()
+	for _, source := range strings.Split(cmp.Or(sourceIPs, ifaces), ",") {
+		source = strings.TrimSpace(source)
+		var ip net.IP
+		if sourceIPs != "" {
+			if ip = net.ParseIP(source); ip == nil {
+				return fmt.Errorf("'%s' isn't an IP address", source)
+			}
+			if err == nil && !hasAddress(allAddrs, ip) {
+				return fmt.Errorf("%s isn't an address of any of this machine's network interfaces", source)
+			}
+			descriptions = append(descriptions, ip.String())
+		} else {
+			i, err := net.InterfaceByName(source)
+			if err != nil {
+				return fmt.Errorf("there's no network interface called %s", source)
+			}
+			addrs, err := i.Addrs()
+			if err != nil {
+				return fmt.Errorf("couldn't get the addresses of %s: %w", source, err)
+			}
+			if ip = interfaceAddress(addrs, version); ip == nil {
+				return fmt.Errorf("the network interface %s has no address that can be used", source)
+			}
+			descriptions = append(descriptions, fmt.Sprintf("%s (%s)", ip, source))
+		}
+
+		family := 6
+		if ip.To4() != nil {
+			family = 4
+		}
+		switch {
+		case version == 0:
+			version = family
+		case family != version && IPVersion() != 0:
+			return fmt.Errorf("%s isn't an IPv%d address, as --ip-version requires", source, version)
+		case family != version:
+			return fmt.Errorf("%s isn't an IPv%d address, as the first one is; the addresses must all be of the same family", source, version)
+		}
+		sources = append(sources, &net.TCPAddr{IP: ip})
+	}
+
+	sourceAddresses.Store(&sources)
+	description := strings.Join(descriptions, ", ")
+	if len(descriptions) > 1 {
+		description += ", in turn"
+	}
+	sourceAddressDescription.Store(description)
+	return nil
+}
+
+func hasAddress(addrs []net.Addr, ip net.IP) bool {
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceAddress is the address that an interface's connections are made from: its first IPv4 address, or, if it
+// has none, or version is 6, its first IPv6 one that isn't link-local, since those can't reach the internet. version
+// is that of SetIPVersion, or 0 for either.
+func interfaceAddress(addrs []net.Addr, version int) net.IP {
+	var v4, v6 net.IP
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if n.IP.To4() != nil {
+			if v4 == nil {
+				v4 = n.IP
+			}
+		} else if v6 == nil && !n.IP.IsLinkLocalUnicast() {
+			v6 = n.IP
+		}
+	}
+	switch {
+	case version == 6:
+		return v6
+	case version == 4 || v4 != nil:
+		return v4
+	default:
+		return v6
+	}
+}
+
+// SourceAddr is the local address that the next connection should be made from, as a net.Dialer's LocalAddr, taking
+// each of those that BindSourceAddress bound in turn, or nil for whichever the system picks
+func SourceAddr() net.Addr {
+	sources := sourceAddresses.Load()
+	if sources == nil || len(*sources) == 0 {
+		return nil
+	}
+	return (*sources)[(nextSourceAddress.Add(1)-1)%uint64(len(*sources))]
+}
+
+// SourceAddressDescription says which addresses BindSourceAddress bound connections to, or is empty if it hasn't been
+// called
+func SourceAddressDescription() string {
+	description, _ := sourceAddressDescription.Load().(string)
+	return description
+}
@@ -0,0 +1,30 @@
+//go:build freebsd
+
+package common
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// holesSupported is true where skipping over a range of a file leaves a hole, rather than making the file system write zeros.
+// UFS and ZFS both keep holes; ZFS also turns written zeros into holes when compression is on, but only then.
+const holesSupported = true
+
+// HolesSupported reports whether downloads leave holes where the data is all zeros, rather than writing the zeros out
+func HolesSupported() bool {
+	return holesSupported
+}
+
+// IsHole reports whether [offset, offset+length) of f is entirely a hole, and so reads as zeros without touching the disk.
+// Any error is treated as "not a hole"; the caller will just read the range as usual.
+func IsHole(f *os.File, offset, length int64) bool {
+	next, err := unix.Seek(int(f.Fd()), offset, unix.SEEK_DATA)
+	if err != nil {
+		// ENXIO means there's no data at or after offset, i.e. we're in the trailing hole
+		return errors.Is(err, unix.ENXIO)
+	}
+	return next >= offset+length
+}
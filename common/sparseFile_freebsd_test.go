@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sparseTestSize = 8 * 1024 * 1024
+
+// sparseTestFile makes a file that is a hole apart from some data in the middle, or skips the test if the
+// file system under TMPDIR doesn't report holes (e.g. tmpfs)
+func sparseTestFile(t *testing.T) (f *os.File, dataStart, dataEnd int64) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	if err = f.Truncate(sparseTestSize); err != nil {
+		t.Fatal(err)
+	}
+	if !IsHole(f, 0, sparseTestSize) {
+		t.Skip("file system doesn't report holes")
+	}
+
+	dataStart, dataEnd = sparseTestSize/2, sparseTestSize/2+4096
+	if _, err = f.WriteAt(make([]byte, 4096), dataStart); err != nil { // written zeros are data, not a hole
+		t.Fatal(err)
+	}
+	if err = f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	return f, dataStart, dataEnd
+}
+
+func TestIsHole(t *testing.T) {
+	a := assert.New(t)
+	f, dataStart, dataEnd := sparseTestFile(t)
+
+	a.True(IsHole(f, 0, 1024*1024))
+	a.False(IsHole(f, 0, sparseTestSize))
+	a.False(IsHole(f, dataStart, dataEnd-dataStart))
+	a.True(IsHole(f, sparseTestSize-1024*1024, 1024*1024)) // the trailing hole
+}
+
+func TestSingleChunkReaderDoesNotReadHoles(t *testing.T) {
+	a := assert.New(t)
+	f, dataStart, _ := sparseTestFile(t)
+	const chunkSize = 1024 * 1024
+
+	// pooled slices aren't zeroed, so dirty the one the reader will be given
+	pool := NewMultiSizeSlicePool(chunkSize)
+	dirty := pool.RentSlice(chunkSize)
+	for i := range dirty {
+		dirty[i] = 0xff
+	}
+	pool.ReturnSlice(dirty)
+
+	for _, c := range []struct {
+		offset int64
+		isHole bool
+	}{
+		{0, true},
+		{dataStart, false},
+	} {
+		factory := func() (CloseableReaderAt, error) { return f, nil }
+		cr := NewSingleChunkReader(context.Background(), factory, NewChunkID(f.Name(), c.offset, chunkSize), chunkSize, nil, nil, pool, NewCacheLimiter(4*chunkSize))
+		a.NoError(cr.BlockingPrefetch(f, false))
+		a.Equal(c.isHole, cr.(*singleChunkReader).isHole)
+		a.True(cr.HasPrefetchedEntirelyZeros())
+
+		data, err := io.ReadAll(cr)
+		a.NoError(err)
+		a.Equal(make([]byte, chunkSize), data)
+		cr.Close()
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !freebsd
+
+package common
+
+import "os"
+
+const holesSupported = false
+
+func HolesSupported() bool {
+	return holesSupported
+}
+
+func IsHole(f *os.File, offset, length int64) bool {
+	return false
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"io"
+	"os"
+)
+
+// maxVectoredWriteChunks is how many contiguous chunks the chunkedFileWriter saves together, when that many have
+// arrived by the time it gets to them
+const maxVectoredWriteChunks = 64
+
+// maxIOVectors is IOV_MAX on both Linux and FreeBSD: the most buffers that one pwritev(2) call can take
+const maxIOVectors = 1024
+
+// vectoredWrites says whether the chunkedFileWriter uses pwritev(2). It's only turned off by tests and benchmarks,
+// to compare with the write(2) path.
+var vectoredWrites = vectoredWritesSupported
+
+// writeVectoredAt writes bufs, one after the other, at off, with as few pwritev(2) calls as the kernel allows, so
+// that the buffers needn't be copied into a bigger one first, nor written one call at a time. It may change bufs.
+func writeVectoredAt(f *os.File, bufs [][]byte, off int64) error {
+	written := 0
+	for {
+		// drop what's been written, which may end part way through a buffer, and any empty buffers
+		for len(bufs) > 0 && written >= len(bufs[0]) {
+			written -= len(bufs[0])
+			bufs = bufs[1:]
+		}
+		if len(bufs) == 0 {
+			return nil
+		}
+		bufs[0] = bufs[0][written:]
+
+		var err error
+		written, err = pwritev(f, bufs[:min(len(bufs), maxIOVectors)], off)
+		if err != nil {
+			return err
+		}
+		if written == 0 {
+			return io.ErrShortWrite
+		}
+		off += int64(written)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build freebsd
+
+package common
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// x/sys has no Pwritev for FreeBSD, so the syscall is made directly. As with posix_fadvise, that's only done on
+// 64-bit platforms, where the offset is a single argument.
+const vectoredWritesSupported = strconv.IntSize == 64
+
+func pwritev(f *os.File, bufs [][]byte, off int64) (int, error) {
+	iovecs := make([]unix.Iovec, 0, len(bufs))
+	for _, b := range bufs {
+		if len(b) == 0 {
+			continue
+		}
+		iov := unix.Iovec{Base: &b[0]}
+		iov.SetLen(len(b))
+		iovecs = append(iovecs, iov)
+	}
+	if len(iovecs) == 0 {
+		return 0, nil
+	}
+
+	n, _, errno := unix.Syscall6(unix.SYS_PWRITEV, f.Fd(), uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)), uintptr(off), 0, 0)
+	runtime.KeepAlive(iovecs)
+	runtime.KeepAlive(bufs)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const vectoredWritesSupported = true
+
+func pwritev(f *os.File, bufs [][]byte, off int64) (int, error) {
+	return unix.Pwritev(int(f.Fd()), bufs, off)
+}
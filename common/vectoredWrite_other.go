@@ -0,0 +1,14 @@
+//go:build !linux && !freebsd
+
+package common
+
+import (
+	"errors"
+	"os"
+)
+
+const vectoredWritesSupported = false
+
+func pwritev(f *os.File, bufs [][]byte, off int64) (int, error) {
+	return 0, errors.New("pwritev isn't supported on this platform")
+}
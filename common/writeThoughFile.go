@@ -33,6 +33,15 @@ const BackupModeFlagName = "backup" // original name, backup mode, matches the n
 const PreserveOwnerFlagName = "preserve-owner"
 const PreserveSymlinkFlagName = "preserve-symlinks"
 const PreserveOwnerDefault = true
+const PreallocateFlagName = "preallocate"
+
+// preallocateFiles controls whether CreateFileOfSizeWithWriteThroughOption reserves disk space for the whole file up front,
+// rather than just setting its size. It's a global because it is a property of the local machine, not of any one transfer.
+var preallocateFiles = true
+
+func SetPreallocateFiles(enable bool) {
+	preallocateFiles = enable
+}
 
 // The regex doesn't require a / on the ending, it just requires something similar to the following
 // C:
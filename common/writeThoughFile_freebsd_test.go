@@ -0,0 +1,34 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestPreallocationFallsBackToTruncate(t *testing.T) {
+	a := assert.New(t)
+	defer func() { fallocate = posixFallocate }()
+	dir := t.TempDir()
+
+	// file systems that can't preallocate, such as ZFS, get a sparse file of the right size instead
+	for _, errno := range []error{unix.EINVAL, unix.EOPNOTSUPP} {
+		fallocate = func(*os.File, int64) error { return errno }
+		f, err := CreateFileOfSizeWithWriteThroughOption(filepath.Join(dir, "fallback"), 4096, false, nil, false)
+		if a.NoError(err, errno.Error()) {
+			fi, err := f.Stat()
+			a.NoError(err)
+			a.Equal(int64(4096), fi.Size())
+			a.NoError(f.Close())
+		}
+	}
+
+	// but running out of space is reported straight away
+	fallocate = func(*os.File, int64) error { return unix.ENOSPC }
+	f, err := CreateFileOfSizeWithWriteThroughOption(filepath.Join(dir, "full"), 4096, false, nil, false)
+	a.ErrorIs(err, unix.ENOSPC)
+	a.Nil(f)
+}
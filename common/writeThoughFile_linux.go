@@ -170,7 +170,7 @@ func CreateFileOfSizeWithWriteThroughOption(destinationPath string, fileSize int
 		// TODO: conduct further testing of this code path, on Linux
 		flags = flags | os.O_SYNC // technically, O_DSYNC may be very slightly faster, but its not exposed in the os package
 	}
-	f, err := os.OpenFile(destinationPath, flags, DEFAULT_FILE_PERM)
+	f, err := OpenFileToWrite(destinationPath, flags, DEFAULT_FILE_PERM)
 	if err != nil {
 		return nil, err
 	}
@@ -179,6 +179,14 @@ func CreateFileOfSizeWithWriteThroughOption(destinationPath string, fileSize int
 		return f, err
 	}
 
+	if !preallocateFiles {
+		if err = f.Truncate(fileSize); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
 	for i := 0; i < EINTR_RETRY_COUNT; i++ { // Perform up to 5 EINTR error retries
 		err = syscall.Fallocate(int(f.Fd()), 0, 0, fileSize)
 		if err == nil || err != syscall.EINTR {
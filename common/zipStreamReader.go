@@ -0,0 +1,218 @@
+package common
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// A zip archive is meant to be read from its end, where its central directory is. But each file in it is also
+// preceded by a local header, which is enough to extract it as the archive is read from the start, so long as the
+// size of the file's data is known by then. It always is for deflated files, since the deflate stream marks its own
+// end. It is for stored files unless the archive's writer only gave their sizes after their data (as Go's does).
+// See https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT
+
+const (
+	zipLocalHeaderSignature      = 0x04034b50
+	zipDataDescriptorSignature   = 0x08074b50
+	zipCentralHeaderSignature    = 0x02014b50
+	zipEndSignature              = 0x06054b50
+	zip64EndSignature            = 0x06064b50
+	zipArchiveExtraDataSignature = 0x08064b50
+
+	zipLocalHeaderLen = 26 // after the signature, and before the name and extra fields
+
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+
+	zipMethodStore   = 0
+	zipMethodDeflate = 8
+
+	zip64ExtraID         = 0x0001
+	zipExtTimeExtraID    = 0x5455
+	zip64SizePlaceholder = 0xffffffff
+)
+
+func (x *archiveExtractor) extractZip(r *bufio.Reader) error {
+	for {
+		var sig [4]byte
+		if _, err := io.ReadFull(r, sig[:]); err != nil {
+			return zipReadError(err)
+		}
+		switch binary.LittleEndian.Uint32(sig[:]) {
+		case zipLocalHeaderSignature:
+			if err := x.extractZipFile(r); err != nil {
+				return err
+			}
+		case zipCentralHeaderSignature, zipEndSignature, zip64EndSignature, zipArchiveExtraDataSignature:
+			return nil // all that's left is the directory, which says again what the local headers did
+		default:
+			return errors.New("this isn't a zip archive, or is one with data between its files, which can't be extracted as it is downloaded")
+		}
+	}
+}
+
+func zipReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("the zip archive ends unexpectedly")
+	}
+	return err
+}
+
+// zipLocalHeader is what the local header says about a file
+type zipLocalHeader struct {
+	name           string
+	flags          uint16
+	method         uint16
+	modTime        time.Time
+	crc32          uint32
+	compressedSize uint64
+	size           uint64
+	zip64          bool
+}
+
+func readZipLocalHeader(r io.Reader) (*zipLocalHeader, error) {
+	var fixed [zipLocalHeaderLen]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, zipReadError(err)
+	}
+	le := binary.LittleEndian
+	h := &zipLocalHeader{
+		flags:          le.Uint16(fixed[2:]),
+		method:         le.Uint16(fixed[4:]),
+		modTime:        msDosTime(le.Uint16(fixed[8:]), le.Uint16(fixed[6:])),
+		crc32:          le.Uint32(fixed[10:]),
+		compressedSize: uint64(le.Uint32(fixed[14:])),
+		size:           uint64(le.Uint32(fixed[18:])),
+	}
+
+	nameLen, extraLen := int(le.Uint16(fixed[22:])), int(le.Uint16(fixed[24:]))
+	variable := make([]byte, nameLen+extraLen)
+	if _, err := io.ReadFull(r, variable); err != nil {
+		return nil, zipReadError(err)
+	}
+	h.name = string(variable[:nameLen])
+
+	for extra := variable[nameLen:]; len(extra) >= 4; {
+		id, size := le.Uint16(extra), int(le.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+		data := extra[4 : 4+size]
+		extra = extra[4+size:]
+
+		switch id {
+		case zip64ExtraID:
+			// the sizes are only here if they are too big for the header
+			h.zip64 = true
+			if h.size == zip64SizePlaceholder && len(data) >= 8 {
+				h.size, data = le.Uint64(data), data[8:]
+			}
+			if h.compressedSize == zip64SizePlaceholder && len(data) >= 8 {
+				h.compressedSize = le.Uint64(data)
+			}
+		case zipExtTimeExtraID:
+			if len(data) >= 5 && data[0]&1 != 0 { // the modification time is present
+				h.modTime = time.Unix(int64(int32(le.Uint32(data[1:]))), 0)
+			}
+		}
+	}
+	return h, nil
+}
+
+// msDosTime converts the date and time of a local header, which are in the local time of whoever made the archive
+func msDosTime(date, t uint16) time.Time {
+	return time.Date(1980+int(date>>9), time.Month(date>>5&0xf), int(date&0x1f),
+		int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.Local)
+}
+
+// zipChecker sums what is extracted, to be checked against what the archive says
+type zipChecker struct {
+	hash.Hash32
+	size uint64
+}
+
+func (c *zipChecker) Write(p []byte) (int, error) {
+	c.size += uint64(len(p))
+	return c.Hash32.Write(p)
+}
+
+func (x *archiveExtractor) extractZipFile(r *bufio.Reader) error {
+	h, err := readZipLocalHeader(r)
+	if err != nil {
+		return err
+	}
+	hasDescriptor := h.flags&zipFlagDataDescriptor != 0
+
+	var content io.Reader
+	switch {
+	case h.flags&zipFlagEncrypted != 0:
+		return fmt.Errorf("%s is encrypted, so it can't be extracted", h.name)
+	case h.method == zipMethodDeflate:
+		fr := flate.NewReader(r) // which reads no further than the end of the data, since r is a ByteReader
+		defer fr.Close()
+		content = fr
+	case h.method == zipMethodStore && hasDescriptor && h.compressedSize == 0:
+		return fmt.Errorf("%s is stored without its size before its data, so the archive can't be extracted as it is downloaded", h.name)
+	case h.method == zipMethodStore:
+		content = io.LimitReader(r, int64(h.compressedSize))
+	default:
+		return fmt.Errorf("%s is compressed with method %d, which can't be extracted", h.name, h.method)
+	}
+
+	checker := &zipChecker{Hash32: crc32.NewIEEE()}
+	content = io.TeeReader(content, checker)
+	if strings.HasSuffix(h.name, "/") {
+		if err = x.extractFolder(h.name); err == nil {
+			_, err = io.Copy(io.Discard, content)
+		}
+	} else {
+		err = x.extractFile(h.name, h.modTime, content)
+	}
+	if err != nil {
+		return zipReadError(err)
+	}
+
+	if hasDescriptor {
+		// some writers only give 64 bit sizes there, for files too big for 32 bits, without saying so beforehand
+		zip64 := h.zip64 || checker.size >= zip64SizePlaceholder
+		if h.crc32, h.size, err = readZipDataDescriptor(r, zip64); err != nil {
+			return err
+		}
+	}
+	if checker.Sum32() != h.crc32 || checker.size != h.size {
+		return fmt.Errorf("%s is corrupt in the zip archive, since its checksum or size isn't what the archive says", h.name)
+	}
+	return nil
+}
+
+// readZipDataDescriptor reads the checksum and size that follow a file's data, when they weren't known beforehand
+func readZipDataDescriptor(r io.Reader, zip64 bool) (crc uint32, size uint64, err error) {
+	le := binary.LittleEndian
+	var buf [24]byte
+	if _, err = io.ReadFull(r, buf[:4]); err != nil {
+		return 0, 0, zipReadError(err)
+	}
+	if le.Uint32(buf[:]) == zipDataDescriptorSignature { // which is optional
+		if _, err = io.ReadFull(r, buf[:4]); err != nil {
+			return 0, 0, zipReadError(err)
+		}
+	}
+	crc = le.Uint32(buf[:])
+
+	sizes := buf[4:Iff(zip64, 20, 12)] // the compressed size, then the size
+	if _, err = io.ReadFull(r, sizes); err != nil {
+		return 0, 0, zipReadError(err)
+	}
+	if zip64 {
+		return crc, le.Uint64(sizes[8:]), nil
+	}
+	return crc, uint64(le.Uint32(sizes[4:])), nil
+}
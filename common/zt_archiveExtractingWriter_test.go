@@ -0,0 +1,149 @@
+package common
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testArchiveModTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+var testArchiveContents = map[string]string{
+	"a.txt":           "hello",
+	"empty":           "",
+	"sub/b.bin":       strings.Repeat("b", 100000),
+	"sub/ünïcode.txt": "ü",
+}
+
+// extractInPieces writes an archive to an extracting writer in pieces, as a download would
+func extractInPieces(a *assert.Assertions, format ArchiveFormat, archive []byte, dir string) error {
+	w := NewArchiveExtractingWriter(format, dir, ArchiveExtractOptions{})
+	for len(archive) > 0 {
+		n := min(len(archive), 3000)
+		if _, err := w.Write(archive[:n]); err != nil {
+			a.Error(w.Close()) // which reports it too
+			return err
+		}
+		archive = archive[n:]
+	}
+	return w.Close()
+}
+
+func assertExtracted(a *assert.Assertions, dir string) {
+	for name, content := range testArchiveContents {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		extracted, err := os.ReadFile(path)
+		a.NoError(err, name)
+		a.Equal(content, string(extracted), name)
+		fi, err := os.Stat(path)
+		a.NoError(err)
+		a.True(testArchiveModTime.Equal(fi.ModTime()), name)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "folder"))
+	a.NoError(err)
+	a.True(fi.IsDir())
+}
+
+func TestGetArchiveFormat(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(EArchiveFormat.Tar(), GetArchiveFormat("/dir/backup.TAR"))
+	a.Equal(EArchiveFormat.TarGzip(), GetArchiveFormat("backup.tar.gz"))
+	a.Equal(EArchiveFormat.TarGzip(), GetArchiveFormat("backup.tgz"))
+	a.Equal(EArchiveFormat.Zip(), GetArchiveFormat("backup.zip"))
+	a.Equal(EArchiveFormat.None(), GetArchiveFormat("backup.gz"))
+	a.Equal(EArchiveFormat.None(), GetArchiveFormat("tar"))
+}
+
+func TestArchiveExtractingWriterUntarsGzippedArchives(t *testing.T) {
+	a := assert.New(t)
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	a.NoError(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "folder/", Mode: 0755}))
+	for name, content := range testArchiveContents {
+		a.NoError(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(content)), Mode: 0644, ModTime: testArchiveModTime}))
+		_, err := tw.Write([]byte(content))
+		a.NoError(err)
+	}
+	a.NoError(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/etc/passwd"}))
+	a.NoError(tw.Close())
+	a.NoError(gz.Close())
+
+	dir := t.TempDir()
+	a.NoError(extractInPieces(a, EArchiveFormat.TarGzip(), archive.Bytes(), dir))
+	assertExtracted(a, dir)
+	_, err := os.Lstat(filepath.Join(dir, "link"))
+	a.True(os.IsNotExist(err))
+}
+
+func TestArchiveExtractingWriterUnzips(t *testing.T) {
+	a := assert.New(t)
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	_, err := zw.CreateHeader(&zip.FileHeader{Name: "folder/", Modified: testArchiveModTime})
+	a.NoError(err)
+	for name, content := range testArchiveContents {
+		if name == "a.txt" {
+			// stored, with its size given up front, as most writers do. The modification time is given as an
+			// extended timestamp, as CreateHeader would have.
+			extTime := []byte{0x55, 0x54, 5, 0, 1, 0, 0, 0, 0}
+			binary.LittleEndian.PutUint32(extTime[5:], uint32(testArchiveModTime.Unix()))
+			w, err := zw.CreateRaw(&zip.FileHeader{
+				Name:               name,
+				Method:             zip.Store,
+				Extra:              extTime,
+				CRC32:              crc32.ChecksumIEEE([]byte(content)),
+				CompressedSize64:   uint64(len(content)),
+				UncompressedSize64: uint64(len(content)),
+			})
+			a.NoError(err)
+			_, err = w.Write([]byte(content))
+			a.NoError(err)
+			continue
+		}
+		// deflated, with the size and checksum in a descriptor after the data
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: testArchiveModTime})
+		a.NoError(err)
+		_, err = w.Write([]byte(content))
+		a.NoError(err)
+	}
+	a.NoError(zw.Close())
+
+	dir := t.TempDir()
+	a.NoError(extractInPieces(a, EArchiveFormat.Zip(), archive.Bytes(), dir))
+	assertExtracted(a, dir)
+
+	// a corrupt file is noticed
+	corrupt := bytes.Replace(archive.Bytes(), []byte("hello"), []byte("jello"), 1)
+	a.ErrorContains(extractInPieces(a, EArchiveFormat.Zip(), corrupt, t.TempDir()), "a.txt is corrupt")
+}
+
+func TestArchiveExtractingWriterExplainsUnstreamableZips(t *testing.T) {
+	a := assert.New(t)
+
+	// Go's writer only gives the size of a stored file after its data, so the end of the data can't be found
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "stored.txt", Method: zip.Store})
+	a.NoError(err)
+	_, _ = w.Write([]byte("data"))
+	a.NoError(zw.Close())
+
+	err = extractInPieces(a, EArchiveFormat.Zip(), archive.Bytes(), t.TempDir())
+	a.ErrorContains(err, "stored.txt is stored without its size")
+
+	err = extractInPieces(a, EArchiveFormat.Zip(), []byte(strings.Repeat("not a zip", 100)), t.TempDir())
+	a.ErrorContains(err, "isn't a zip archive")
+}
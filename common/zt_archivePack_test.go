@@ -0,0 +1,192 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makePackMembers(a *assert.Assertions, dir string, contents map[string]string) []ArchivePackMember {
+	var members []ArchivePackMember
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"a.txt", "empty", "sub/b.bin", longPackMemberName, "sub/ünïcode.txt"} {
+		content, ok := contents[name]
+		if !ok {
+			continue
+		}
+		source := filepath.Join(dir, filepath.FromSlash(name))
+		a.NoError(os.MkdirAll(filepath.Dir(source), os.ModePerm))
+		a.NoError(os.WriteFile(source, []byte(content), 0644))
+		a.NoError(os.Chtimes(source, modTime, modTime))
+		members = append(members, ArchivePackMember{Source: source, Name: name, Size: int64(len(content)), ModTime: modTime})
+		modTime = modTime.Add(time.Hour)
+	}
+	return members
+}
+
+var longPackMemberName = "sub/" + strings.Repeat("long", 40) + ".txt" // too long for a plain ustar header
+
+var testPackContents = map[string]string{
+	"a.txt":            "hello",
+	"empty":            "",
+	"sub/b.bin":        strings.Repeat("b", 1500),
+	longPackMemberName: "long",
+	"sub/ünïcode.txt":  "ü",
+}
+
+func TestArchivePackIsATarArchive(t *testing.T) {
+	a := assert.New(t)
+	members := makePackMembers(a, t.TempDir(), testPackContents)
+
+	pack, err := NewArchivePack(members)
+	a.NoError(err)
+	a.Equal(members[len(members)-1].ModTime, pack.ModTime())
+
+	// read it in awkwardly sized pieces, as chunks of an upload would be
+	r := pack.Open()
+	defer r.Close()
+	var archive []byte
+	piece := make([]byte, 700)
+	for off := int64(0); ; {
+		n, err := r.ReadAt(piece, off)
+		archive = append(archive, piece[:n]...)
+		off += int64(n)
+		if err == io.EOF {
+			break
+		}
+		a.NoError(err)
+	}
+	a.Equal(pack.Size(), int64(len(archive)))
+
+	tr := tar.NewReader(bytes.NewReader(archive))
+	h, err := tr.Next()
+	a.NoError(err)
+	a.Equal(ArchivePackIndexName, h.Name)
+	var index []struct {
+		Name   string
+		Offset int64
+		Size   int64
+	}
+	a.NoError(json.NewDecoder(tr).Decode(&index))
+	a.Len(index, len(members))
+	jobIndex := pack.JobIndexEntries("pack.tar")
+
+	for i, m := range members {
+		h, err := tr.Next()
+		a.NoError(err)
+		a.Equal(m.Name, h.Name)
+		a.True(m.ModTime.Equal(h.ModTime))
+		content, err := io.ReadAll(tr)
+		a.NoError(err)
+		a.Equal(testPackContents[m.Name], string(content))
+
+		// the index says where to find each file in the blob
+		a.Equal(m.Name, index[i].Name)
+		a.Equal(testPackContents[m.Name], string(archive[index[i].Offset:index[i].Offset+index[i].Size]))
+		// and so does the job's index
+		a.Equal(ArchivePackJobIndexEntry{Name: m.Name, Pack: "pack.tar", Offset: index[i].Offset, Size: index[i].Size}, jobIndex[i])
+	}
+	_, err = tr.Next()
+	a.Equal(io.EOF, err)
+}
+
+func TestArchivePackSurvivesSaveAndLoad(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	pack, err := NewArchivePack(makePackMembers(a, dir, testPackContents))
+	a.NoError(err)
+
+	saved := filepath.Join(dir, "pack.json")
+	a.NoError(pack.Save(saved))
+	loaded, err := LoadArchivePack(saved)
+	a.NoError(err)
+	a.Equal(pack.Size(), loaded.Size())
+
+	lmt, err := loaded.FreshModTime()
+	a.NoError(err)
+	a.True(pack.ModTime().Equal(lmt))
+
+	// a member that has changed changes the archive, so the pack can't be read as it was planned
+	a.NoError(os.WriteFile(loaded.Members[0].Source, []byte("hi"), 0644))
+	_, err = loaded.FreshModTime()
+	a.Error(err)
+	_, err = loaded.Open().ReadAt(make([]byte, loaded.Size()), 0)
+	a.ErrorContains(err, "smaller than when it was scanned")
+}
+
+func TestArchivePackNames(t *testing.T) {
+	a := assert.New(t)
+	jobID := NewJobID()
+
+	name := ArchivePackName(jobID, 12)
+	parsedID, n, ok := ParseArchivePackName(name)
+	a.True(ok)
+	a.Equal(jobID, parsedID)
+	a.Equal(uint32(12), n)
+
+	for _, notAPack := range []string{"pack.tar", "azcopy-pack-12.tar", name + ".gz", "x" + name} {
+		_, _, ok = ParseArchivePackName(notAPack)
+		a.False(ok, notAPack)
+	}
+
+	indexName := ArchivePackJobIndexName(jobID)
+	parsedID, ok = ParseArchivePackJobIndexName(indexName)
+	a.True(ok)
+	a.Equal(jobID, parsedID)
+	_, _, ok = ParseArchivePackName(indexName)
+	a.False(ok, "the index isn't a pack")
+	_, ok = ParseArchivePackJobIndexName(name)
+	a.False(ok, "a pack isn't the index")
+}
+
+func TestTarExtractingWriter(t *testing.T) {
+	a := assert.New(t)
+	pack, err := NewArchivePack(makePackMembers(a, t.TempDir(), testPackContents))
+	a.NoError(err)
+	dst := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(dst, "a.txt"), []byte("already here"), 0644))
+
+	w := NewArchiveExtractingWriter(EArchiveFormat.Tar(), dst, ArchiveExtractOptions{SkipIndex: true})
+	_, err = io.Copy(w, io.NewSectionReader(pack.Open(), 0, pack.Size()))
+	a.NoError(err)
+	a.NoError(w.Close())
+
+	for _, m := range pack.Members {
+		content, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(m.Name)))
+		a.NoError(err)
+		if m.Name == "a.txt" {
+			a.Equal("already here", string(content)) // not overwritten
+			continue
+		}
+		a.Equal(testPackContents[m.Name], string(content))
+		fi, err := os.Stat(filepath.Join(dst, filepath.FromSlash(m.Name)))
+		a.NoError(err)
+		a.True(m.ModTime.Equal(fi.ModTime()))
+	}
+	_, err = os.Stat(filepath.Join(dst, ArchivePackIndexName))
+	a.True(os.IsNotExist(err))
+}
+
+func TestTarExtractingWriterRefusesPathsOutsideTheDestination(t *testing.T) {
+	a := assert.New(t)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	a.NoError(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../escaped.txt", Size: 1, Mode: 0644}))
+	_, _ = tw.Write([]byte("x"))
+	a.NoError(tw.Close())
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	w := NewArchiveExtractingWriter(EArchiveFormat.Tar(), dst, ArchiveExtractOptions{Overwrite: true})
+	_, _ = w.Write(buf.Bytes())
+	a.ErrorContains(w.Close(), "outside the destination folder")
+	_, err := os.Stat(filepath.Join(filepath.Dir(dst), "escaped.txt"))
+	a.True(os.IsNotExist(err))
+}
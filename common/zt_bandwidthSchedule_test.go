@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	a := assert.New(t)
+
+	for _, s := range []string{"08:00-18:00 50", "mon-fri 08:00-18:00 50; sat,sun 10:00-16:00 unlimited", "fri-mon 22:00-06:00 12.5Mbps;"} {
+		_, err := ParseBandwidthSchedule(s)
+		a.NoError(err, s)
+	}
+	for _, s := range []string{"", ";", "08:00-18:00", "weekdays 08:00-18:00 50", "mon-fri 08:00 50", "25:00-26:00 50",
+		"08:60-09:00 50", "08:00-08:00 50", "08:00-18:00 fast", "08:00-18:00 -5", "mon fri 08:00-18:00 50"} {
+		_, err := ParseBandwidthSchedule(s)
+		a.Error(err, s)
+	}
+}
+
+func TestBandwidthScheduleMbpsAt(t *testing.T) {
+	a := assert.New(t)
+	s, err := ParseBandwidthSchedule("mon-fri 08:00-18:00 50; sat 00:00-24:00 unlimited; fri-sun 22:00-06:00 200")
+	a.NoError(err)
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, time.UTC) // the 16th is a Friday
+	}
+	check := func(t time.Time, mbps float64, ok bool) {
+		actualMbps, actualOK := s.MbpsAt(t)
+		a.Equal(ok, actualOK, t.String())
+		a.Equal(mbps, actualMbps, t.String())
+	}
+
+	check(at(16, 8, 0), 50, true)
+	check(at(16, 17, 59), 50, true)
+	check(at(16, 18, 0), 0, false)
+	check(at(16, 23, 0), 200, true) // Friday night, running into Saturday
+	check(at(17, 3, 0), 0, true)    // Saturday's rule comes first
+	check(at(18, 23, 0), 200, true) // Sunday night
+	check(at(19, 5, 59), 200, true) // which runs into Monday morning
+	check(at(19, 6, 0), 0, false)
+	check(at(19, 8, 30), 50, true)
+	check(at(20, 23, 0), 0, false) // Tuesday night isn't covered
+}
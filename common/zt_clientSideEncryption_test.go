@@ -0,0 +1,175 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closableBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closableBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestAESKeyWrapMatchesRFC3394(t *testing.T) {
+	a := assert.New(t)
+
+	// the 256-bit key data wrapped with a 256-bit key, from section 4.6 of the RFC
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	expected, _ := hex.DecodeString("28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")
+
+	wrapped, err := aesKeyWrap(kek, key)
+	a.NoError(err)
+	a.Equal(expected, wrapped)
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	a.NoError(err)
+	a.Equal(key, unwrapped)
+
+	kek[0] ^= 1
+	_, err = aesKeyUnwrap(kek, wrapped)
+	a.Error(err)
+}
+
+func writeTestKeyFile(a *assert.Assertions, dir string, name string, b byte) string {
+	path := filepath.Join(dir, name)
+	a.NoError(os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))+"\n"), 0600))
+	return path
+}
+
+func TestEncryptedChunksDecryptToTheFile(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	const chunkSize = 4096
+	dir := t.TempDir()
+	wrapper, err := NewLocalKeyWrapper(writeTestKeyFile(a, dir, "key", 7))
+	a.NoError(err)
+	a.True(strings.HasPrefix(wrapper.KeyID(), "local:"))
+
+	content := []byte(strings.Repeat("some secret data\n", 1000))
+	path := filepath.Join(dir, "secret.txt")
+	a.NoError(os.WriteFile(path, content, 0644))
+	f, err := os.Open(path)
+	a.NoError(err)
+	defer f.Close()
+	factory := func() (CloseableReaderAt, error) { return os.Open(path) }
+	pool := NewMultiSizeSlicePool(chunkSize)
+
+	key, metadata, err := NewContentEncryptionKey(ctx, wrapper, chunkSize, int64(len(content)))
+	a.NoError(err)
+	a.NotContains(metadata, base64.StdEncoding.EncodeToString(key))
+
+	var blob bytes.Buffer
+	hasher := md5.New()
+	for offset := int64(0); offset < int64(len(content)); offset += chunkSize {
+		length := min(chunkSize, int64(len(content))-offset)
+		chunk := NewSingleChunkReader(ctx, factory, NewChunkID(path, offset, length), length, nil, nil, pool, NewCacheLimiter(4*chunkSize))
+		a.NoError(chunk.BlockingPrefetch(f, false))
+
+		encrypted, err := NewEncryptingChunkReader(chunk, key)
+		a.NoError(err)
+		a.Equal(length+ClientSideEncryptionOverhead, encrypted.Length())
+		encrypted.WriteBufferTo(hasher)
+		_, err = io.Copy(&blob, encrypted)
+		a.NoError(err)
+		a.NoError(encrypted.Close())
+	}
+	a.Equal(EncryptedSize(int64(len(content)), chunkSize), int64(blob.Len()))
+	a.Equal(md5.Sum(blob.Bytes()), [16]byte(hasher.Sum(nil)))
+	a.NotContains(blob.String(), "secret")
+
+	decryptWith := func(metadata string, wrapper KeyWrapper, blob []byte) ([]byte, error) {
+		d, err := ParseClientSideEncryptionData(metadata)
+		a.NoError(err)
+		a.Equal(int64(chunkSize), d.EncryptedRegionInfo.DataLength)
+		decrypter, err := d.NewContentDecrypter(ctx, wrapper)
+		if err != nil {
+			return nil, err
+		}
+		var dst closableBuffer
+		w := decrypter.NewDecryptingWriter(&dst)
+		for len(blob) > 0 { // in pieces that don't line up with the regions, as a download would
+			n := min(len(blob), 3000)
+			if _, err = w.Write(blob[:n]); err != nil {
+				_ = w.Close()
+				return nil, err
+			}
+			blob = blob[n:]
+		}
+		err = w.Close()
+		a.True(dst.closed)
+		return dst.Bytes(), err
+	}
+	decrypt := func(wrapper KeyWrapper, blob []byte) ([]byte, error) {
+		return decryptWith(metadata, wrapper, blob)
+	}
+
+	decrypted, err := decrypt(wrapper, blob.Bytes())
+	a.NoError(err)
+	a.Equal(content, decrypted)
+
+	// a blob that has been changed fails
+	tampered := bytes.Clone(blob.Bytes())
+	tampered[chunkSize+100] ^= 1
+	_, err = decrypt(wrapper, tampered)
+	a.ErrorContains(err, "region 1")
+	_, err = decrypt(wrapper, blob.Bytes()[:blob.Len()-1])
+	a.Error(err)
+
+	// as does one with whole regions cut from its end, though each region left is intact
+	_, err = decrypt(wrapper, blob.Bytes()[:2*(chunkSize+ClientSideEncryptionOverhead)])
+	a.ErrorContains(err, "decrypted to")
+
+	// which can't be told for blobs whose length wasn't recorded, such as those the Storage client libraries encrypt
+	var d ClientSideEncryptionData
+	a.NoError(json.Unmarshal([]byte(metadata), &d))
+	delete(d.KeyWrappingMetadata, clientSideEncryptionLengthKey)
+	unrecorded, err := json.Marshal(d)
+	a.NoError(err)
+	decrypted, err = decryptWith(string(unrecorded), wrapper, blob.Bytes()[:2*(chunkSize+ClientSideEncryptionOverhead)])
+	a.NoError(err)
+	a.Equal(content[:2*chunkSize], decrypted)
+
+	// and another key can't unwrap the blob's
+	other, err := NewLocalKeyWrapper(writeTestKeyFile(a, dir, "other", 8))
+	a.NoError(err)
+	_, err = decrypt(other, blob.Bytes())
+	a.ErrorContains(err, "not with the key in the key file")
+}
+
+func TestLocalKeyFileMustHoldA256BitKey(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	raw := filepath.Join(dir, "raw")
+	a.NoError(os.WriteFile(raw, bytes.Repeat([]byte{7}, 32), 0600))
+	fromRaw, err := NewLocalKeyWrapper(raw)
+	a.NoError(err)
+	fromBase64, err := NewLocalKeyWrapper(writeTestKeyFile(a, dir, "b64", 7))
+	a.NoError(err)
+	a.Equal(fromRaw.KeyID(), fromBase64.KeyID())
+
+	short := filepath.Join(dir, "short")
+	a.NoError(os.WriteFile(short, []byte(base64.StdEncoding.EncodeToString([]byte("too short"))), 0600))
+	_, err = NewLocalKeyWrapper(short)
+	a.ErrorContains(err, "256-bit key")
+
+	a.True(IsKeyVaultKey("https://myvault.vault.azure.net/keys/mykey"))
+	a.False(IsKeyVaultKey(raw))
+}
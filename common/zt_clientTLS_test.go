@@ -0,0 +1,137 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificate is a certificate that parent signs, or that signs itself if parent is nil
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCertificate(t *testing.T, name string, isCA bool, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCertificate{cert: cert, key: key, der: der}
+}
+
+func (c *testCertificate) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCertificate) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func TestLoadClientTLS(t *testing.T) {
+	a := assert.New(t)
+	t.Cleanup(func() { clientTLS.Store(nil) })
+
+	// a server with a certificate from a private CA, which only lets in clients with a certificate from it too
+	ca := newTestCertificate(t, "Private CA", true, nil)
+	serverCert := newTestCertificate(t, "server", false, ca)
+	clientCert := newTestCertificate(t, "client", false, ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.der}, PrivateKey: serverCert.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile, bothFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"), filepath.Join(dir, "both.pem")
+	a.NoError(os.WriteFile(caFile, ca.certPEM(), 0o644))
+	a.NoError(os.WriteFile(certFile, clientCert.certPEM(), 0o644))
+	a.NoError(os.WriteFile(keyFile, clientCert.keyPEM(t), 0o600))
+	a.NoError(os.WriteFile(bothFile, append(clientCert.certPEM(), clientCert.keyPEM(t)...), 0o600))
+
+	get := func() (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: ClientTLSConfig()}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		buf := make([]byte, 64)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n]), nil
+	}
+
+	a.Nil(ClientTLSConfig())
+	_, err := get()
+	a.Error(err, "the server's CA isn't trusted")
+
+	a.NoError(LoadClientTLS(caFile, "", ""))
+	_, err = get()
+	a.Error(err, "the server wants a client certificate")
+
+	a.NoError(LoadClientTLS(caFile, certFile, keyFile))
+	name, err := get()
+	a.NoError(err)
+	a.Equal("client", name)
+	a.Contains(ClientTLSDescription(), "presenting the client certificate in "+certFile)
+
+	a.NoError(LoadClientTLS(caFile, bothFile, ""))
+	name, err = get()
+	a.NoError(err)
+	a.Equal("client", name)
+}
+
+func TestLoadClientTLSErrors(t *testing.T) {
+	a := assert.New(t)
+	t.Cleanup(func() { clientTLS.Store(nil) })
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	a.NoError(os.WriteFile(notPEM, []byte("not a certificate"), 0o644))
+
+	a.ErrorContains(LoadClientTLS(notPEM, "", ""), "has no PEM certificates in it")
+	a.ErrorContains(LoadClientTLS(filepath.Join(dir, "missing.pem"), "", ""), "couldn't read the CA bundle")
+	a.ErrorContains(LoadClientTLS("", "", notPEM), "a client key was given without its certificate")
+	a.ErrorContains(LoadClientTLS("", notPEM, ""), "couldn't load the client certificate")
+	a.Nil(ClientTLSConfig())
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedChunksConcatenateToTheFile(t *testing.T) {
+	a := assert.New(t)
+
+	const chunkSize = 4096
+	content := []byte(strings.Repeat("2024-03-01 12:00:00 INFO a very compressible log line\n", 200))
+	path := filepath.Join(t.TempDir(), "app.log")
+	a.NoError(os.WriteFile(path, content, 0644))
+	f, err := os.Open(path)
+	a.NoError(err)
+	defer f.Close()
+	factory := func() (CloseableReaderAt, error) { return os.Open(path) }
+	pool := NewMultiSizeSlicePool(chunkSize)
+
+	var blob bytes.Buffer
+	hasher := md5.New()
+	for offset := int64(0); offset < int64(len(content)); offset += chunkSize {
+		length := min(chunkSize, int64(len(content))-offset)
+		chunk := NewSingleChunkReader(context.Background(), factory, NewChunkID(path, offset, length), length, nil, nil, pool, NewCacheLimiter(4*chunkSize))
+		a.NoError(chunk.BlockingPrefetch(f, false))
+
+		compressed, err := NewCompressingChunkReader(chunk, ECompressionType.GZip())
+		a.NoError(err)
+		a.Less(compressed.Length(), length)
+		if offset == 0 {
+			a.Equal(content[:512], compressed.GetPrologueState().LeadingBytes)
+		}
+		compressed.WriteBufferTo(hasher)
+
+		// as a retry would, read it twice
+		_, err = io.Copy(io.Discard, compressed)
+		a.NoError(err)
+		_, err = compressed.Seek(0, io.SeekStart)
+		a.NoError(err)
+		_, err = io.Copy(&blob, compressed)
+		a.NoError(err)
+		a.NoError(compressed.Close())
+	}
+	a.Equal(md5.Sum(blob.Bytes()), [16]byte(hasher.Sum(nil)))
+
+	gz, err := gzip.NewReader(&blob)
+	a.NoError(err)
+	uncompressed, err := io.ReadAll(gz)
+	a.NoError(err)
+	a.Equal(content, uncompressed)
+}
+
+func TestParseUploadCompression(t *testing.T) {
+	a := assert.New(t)
+
+	ct, err := ParseUploadCompression("gzip")
+	a.NoError(err)
+	a.Equal(ECompressionType.GZip(), ct)
+	ct, err = ParseUploadCompression("")
+	a.NoError(err)
+	a.Equal(ECompressionType.None(), ct)
+
+	for _, name := range []string{"zstd", "deflate", "brotli"} {
+		_, err = ParseUploadCompression(name)
+		a.Error(err, name)
+	}
+}
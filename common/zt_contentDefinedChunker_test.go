@@ -0,0 +1,58 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func contentDefinedChunks(a *assert.Assertions, c *ContentDefinedChunker, data []byte) map[[32]byte]bool {
+	ends, err := c.ChunkEnds(context.Background(), bytes.NewReader(data))
+	a.NoError(err)
+	chunks := map[[32]byte]bool{}
+	start := int64(0)
+	for i, end := range ends {
+		size := end - start
+		a.LessOrEqual(size, c.MaxSize())
+		if i < len(ends)-1 {
+			a.GreaterOrEqual(size, int64(1024), "only the last chunk is smaller than the minimum")
+		}
+		chunks[sha256.Sum256(data[start:end])] = true
+		start = end
+	}
+	a.Equal(int64(len(data)), start, "the chunks cover the stream")
+	return chunks
+}
+
+func TestContentDefinedChunker(t *testing.T) {
+	a := assert.New(t)
+	c, err := NewContentDefinedChunker(4096, 1024, 16384)
+	a.NoError(err)
+
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	chunks := contentDefinedChunks(a, c, data)
+	a.InDelta(len(data)/4096, len(chunks), float64(len(data)/4096)/2, "chunks are about the average size")
+	a.Equal(chunks, contentDefinedChunks(a, c, data), "the same stream is split the same way")
+
+	// bytes inserted near the start change only the chunks around them
+	edited := append(append(append([]byte{}, data[:10000]...), "inserted"...), data[10000:]...)
+	shared := 0
+	for chunk := range contentDefinedChunks(a, c, edited) {
+		if chunks[chunk] {
+			shared++
+		}
+	}
+	a.GreaterOrEqual(shared, len(chunks)-3)
+
+	ends, err := c.ChunkEnds(context.Background(), bytes.NewReader(nil))
+	a.NoError(err)
+	a.Empty(ends)
+
+	_, err = NewContentDefinedChunker(4096, 8192, 16384)
+	a.Error(err)
+}
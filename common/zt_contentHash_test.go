@@ -0,0 +1,47 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageCRC64(t *testing.T) {
+	a := assert.New(t)
+
+	// the check value of the CRC-64 with the Storage service's polynomial (also used by NVMe), stored little-endian
+	h := EContentHashType.CRC64().NewHasher()
+	_, _ = h.Write([]byte("1234"))
+	_, _ = h.Write([]byte("56789"))
+	a.Equal("8898790a86148bae", hex.EncodeToString(h.Sum(nil)))
+	a.Equal(8, h.Size())
+
+	h.Reset()
+	a.Equal(make([]byte, 8), h.Sum(nil))
+}
+
+func TestContentHashReadFromMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	sum := sha256.Sum256([]byte("some data"))
+	metadata := Metadata{ContentSHA256Meta: to.Ptr(base64.StdEncoding.EncodeToString(sum[:]))}
+	a.Equal(sum[:], EContentHashType.SHA256().ReadFromMetadata(metadata))
+	a.Nil(EContentHashType.CRC64().ReadFromMetadata(metadata))
+
+	// MD5 has a property of its own
+	a.Empty(EContentHashType.MD5().MetadataKey())
+	a.Nil(EContentHashType.MD5().ReadFromMetadata(metadata))
+
+	// and a malformed hash is as good as none
+	metadata[ContentSHA256Meta] = to.Ptr("not base64!")
+	a.Nil(EContentHashType.SHA256().ReadFromMetadata(metadata))
+
+	var ht ContentHashType
+	a.NoError(ht.Parse("sha256"))
+	a.Equal(EContentHashType.SHA256(), ht)
+	a.Error(ht.Parse("sha1"))
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/minio/minio-go/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3ClientGoesThroughTheProxy(t *testing.T) {
+	a := assert.New(t)
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	defer func(lookup ProxyLookupFunc) { GlobalProxyLookup = lookup }(GlobalProxyLookup)
+	GlobalProxyLookup = func(*http.Request) (*url.URL, error) { return proxyURL, nil }
+	t.Setenv(EEnvironmentVariable.S3Endpoint().Name, "http://s3.example.invalid")
+	t.Setenv(EEnvironmentVariable.S3AddressingStyle().Name, "path")
+
+	info := S3CredentialInfo{Endpoint: "s3.example.invalid", Region: "us-east-1"}
+	client, err := newS3Client(info, credentials.NewStatic("", "", "", credentials.SignatureAnonymous))
+	if !a.NoError(err) {
+		return
+	}
+	exists, err := client.BucketExists("bucket")
+	a.NoError(err)
+	a.True(exists)
+	a.Equal([]string{"http://s3.example.invalid/bucket/"}, proxied)
+}
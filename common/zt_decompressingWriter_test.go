@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"crypto/md5"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"math/rand"
@@ -116,6 +117,47 @@ func TestDecompressingWriter_EarlyClose(t *testing.T) {
 	}
 }
 
+func TestDecompressingWriter_VerifiesChunkwiseCompressedFiles(t *testing.T) {
+	a := assert.New(t)
+
+	// as uploaded with --compress, each chunk a gzip stream of its own
+	original := genCompressibleTestData(300 * 1024)
+	var compressed []byte
+	for offset := 0; offset < len(original); offset += 100 * 1024 {
+		compBuf := &bytes.Buffer{}
+		gz := gzip.NewWriter(compBuf)
+		_, err := gz.Write(original[offset : offset+100*1024])
+		a.Nil(err)
+		a.Nil(gz.Close())
+		compressed = append(compressed, compBuf.Bytes()...)
+	}
+	hash := md5.Sum(original)
+
+	for _, c := range []struct {
+		size    int64
+		md5     []byte
+		problem string
+	}{
+		{int64(len(original)), hash[:], ""},
+		{int64(len(original)), nil, ""},
+		{int64(len(original)) + 1, hash[:], "decompressed to"},
+		{int64(len(original)), make([]byte, md5.Size), "MD5 hash"},
+	} {
+		destFile := &closeableBuffer{Buffer: &bytes.Buffer{}}
+		decWriter := NewDecompressingWriter(NewDecompressedFileVerifier(destFile, c.size, c.md5), ECompressionType.GZip())
+		_, err := io.Copy(decWriter, bytes.NewReader(compressed))
+		a.Nil(err)
+		err = decWriter.Close()
+		a.True(destFile.closeWasCalled())
+		if c.problem == "" {
+			a.Nil(err)
+			a.Equal(original, destFile.Bytes())
+		} else {
+			a.ErrorContains(err, c.problem)
+		}
+	}
+}
+
 func getTestData(a *assert.Assertions, tp CompressionType, originalSize int) (original []byte, compressed []byte) {
 	// we have original uncompressed data
 	originalData := genCompressibleTestData(originalSize)
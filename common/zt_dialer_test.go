@@ -0,0 +1,62 @@
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetIPVersion(t *testing.T) {
+	a := assert.New(t)
+	defer ipVersion.Store(0)
+
+	for version, expected := range map[string]int{"4": 4, "IPv4": 4, "6": 6, "ipv6": 6, "auto": 0, "": 0} {
+		a.NoError(SetIPVersion(version))
+		a.Equal(expected, IPVersion(), version)
+	}
+	a.Error(SetIPVersion("5"))
+
+	a.NoError(SetIPVersion("6"))
+	a.Equal("tcp6", ipNetwork("tcp"))
+	a.Equal("tcp6", ipNetwork("tcp6"))
+	a.Equal("unix", ipNetwork("unix"))
+	a.True(DialingConfigured())
+	a.NoError(SetIPVersion("auto"))
+	a.Equal("tcp", ipNetwork("tcp"))
+	a.False(DialingConfigured())
+}
+
+func TestDialerKeepsToTheIPVersion(t *testing.T) {
+	a := assert.New(t)
+	defer ipVersion.Store(0)
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if !a.NoError(err) {
+		return
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	dialer := NewDialer(net.Dialer{})
+
+	a.NoError(SetIPVersion("4"))
+	conn, err := dialer.Dial("tcp", "127.0.0.1:"+port)
+	if a.NoError(err) {
+		a.Equal("127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		conn.Close()
+	}
+
+	// there's no IPv6 way to reach an IPv4 address
+	a.NoError(SetIPVersion("6"))
+	_, err = dialer.Dial("tcp", "127.0.0.1:"+port)
+	a.Error(err)
+}
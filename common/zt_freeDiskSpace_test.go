@@ -0,0 +1,21 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	free, err := FreeDiskSpace(dir)
+	a.NoError(err)
+	a.NotZero(free)
+
+	// a destination that hasn't been created yet is on the file system of the directory it will be made in
+	notYet, err := FreeDiskSpace(filepath.Join(dir, "not", "made", "yet"))
+	a.NoError(err)
+	a.InDelta(free, notYet, float64(free)/10)
+}
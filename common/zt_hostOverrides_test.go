@@ -0,0 +1,74 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostOverride(t *testing.T) {
+	a := assert.New(t)
+
+	for text, expected := range map[string]hostOverride{
+		"myaccount.blob.core.windows.net:10.1.2.3":     {host: "myaccount.blob.core.windows.net", addrs: []string{"10.1.2.3"}},
+		"MyAccount.blob.core.windows.net:443:10.1.2.3": {host: "myaccount.blob.core.windows.net", port: "443", addrs: []string{"10.1.2.3"}},
+		"example.com:10.1.2.3, 10.1.2.4":               {host: "example.com", addrs: []string{"10.1.2.3", "10.1.2.4"}},
+		"example.com:[fd00::5]":                        {host: "example.com", addrs: []string{"fd00::5"}},
+		"example.com:443:[fd00::5],10.1.2.3":           {host: "example.com", port: "443", addrs: []string{"fd00::5", "10.1.2.3"}},
+		"example.com:2001:db8::5":                      {host: "example.com", addrs: []string{"2001:db8::5"}},
+	} {
+		o, err := parseHostOverride(text)
+		if a.NoError(err, text) {
+			a.Equal(expected, o, text)
+		}
+	}
+
+	for _, text := range []string{"example.com", "example.com:", ":10.1.2.3", "example.com:443:", "example.com:not-an-ip", "example.com:443:10.1.2.3,"} {
+		_, err := parseHostOverride(text)
+		a.Error(err, text)
+	}
+}
+
+func TestOverriddenAddresses(t *testing.T) {
+	a := assert.New(t)
+	defer hostOverrides.Store(nil)
+
+	a.Nil(overriddenAddresses("example.com", "443"))
+	a.NoError(SetHostOverrides([]string{"example.com:10.0.0.1", "example.com:8443:10.0.0.2", "other.com:80:10.0.0.3"}))
+	a.Equal([]string{"10.0.0.1"}, overriddenAddresses("EXAMPLE.com", "443"))
+	a.Equal([]string{"10.0.0.2"}, overriddenAddresses("example.com", "8443"))
+	a.Equal([]string{"10.0.0.3"}, overriddenAddresses("other.com", "80"))
+	a.Nil(overriddenAddresses("other.com", "443"))
+	a.Nil(overriddenAddresses("unknown.com", "443"))
+	a.True(DialingConfigured())
+	a.Equal("example.com to 10.0.0.1; example.com:8443 to 10.0.0.2; other.com:80 to 10.0.0.3", HostOverridesDescription())
+
+	a.Error(SetHostOverrides([]string{"example.com:nowhere"}))
+	a.NoError(SetHostOverrides(nil))
+	a.False(DialingConfigured())
+}
+
+func TestDialerSendsOverriddenHosts(t *testing.T) {
+	a := assert.New(t)
+	defer hostOverrides.Store(nil)
+
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	// storage.invalid can't be resolved, so the request only gets there with the override
+	a.NoError(SetHostOverrides([]string{"storage.invalid:" + u.Hostname()}))
+	client := &http.Client{Transport: &http.Transport{DialContext: NewDialer(net.Dialer{}).DialContext}}
+	resp, err := client.Get("http://storage.invalid:" + u.Port() + "/")
+	if a.NoError(err) {
+		resp.Body.Close()
+		a.Equal("storage.invalid:"+u.Port(), host)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenFileToWriteLocksTheFile(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(SetLockFiles(true))
+	defer func() { _ = SetLockFiles(false) }()
+
+	path := filepath.Join(t.TempDir(), "file")
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	f, err := OpenFileToWrite(path, flags, DEFAULT_FILE_PERM)
+	a.NoError(err)
+	_, err = f.WriteString("half")
+	a.NoError(err)
+
+	// a second download of the file fails, and leaves what the first has written alone
+	_, err = OpenFileToWrite(path, flags, DEFAULT_FILE_PERM)
+	a.ErrorContains(err, "is locked by another process")
+	written, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal("half", string(written))
+
+	// as does one that carries on from where it got to
+	partial, err := os.OpenFile(path, os.O_RDWR, 0)
+	a.NoError(err)
+	a.ErrorIs(TryLockFile(partial), ErrFileLocked)
+	a.Error(LockFileToWrite(partial))
+	a.NoError(partial.Close())
+
+	// until the first has finished
+	a.NoError(f.Close())
+	f, err = OpenFileToWrite(path, flags, DEFAULT_FILE_PERM)
+	a.NoError(err)
+	a.NoError(f.Close())
+	written, err = os.ReadFile(path)
+	a.NoError(err)
+	a.Empty(written)
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryLimitTest sets the memory limit without making it the runtime's, which at these sizes would have the GC
+// running all the time, and returns what undoes it
+func memoryLimitTest(limit, planFiles int64) func() {
+	memoryLimit.Store(limit)
+	planFileMemory.Add(planFiles)
+	return func() {
+		memoryLimit.Store(0)
+		planFileMemory.Add(-planFiles)
+	}
+}
+
+func TestMemoryCacheLimiterKeepsToTheMemoryLimit(t *testing.T) {
+	a := assert.New(t)
+	defer memoryLimitTest(100, 0)()
+	c := NewMemoryCacheLimiter(1000)
+
+	a.True(c.TryAdd(60, true))
+	a.False(c.TryAdd(50, true), "it would take the buffers past the memory limit")
+	a.True(c.TryAdd(40, true))
+	a.Equal(int64(100), bufferMemory.Load())
+	c.Remove(100)
+	a.Equal(int64(0), bufferMemory.Load())
+
+	a.True(c.TryAdd(70, false))
+	a.False(c.TryAdd(10, false), "the strict limit holds back a quarter of the memory limit too")
+	a.True(c.TryAdd(10, true))
+	c.Remove(80)
+
+	// a plain cache limiter, such as the one for open files, doesn't count towards it
+	a.True(NewCacheLimiter(1000).TryAdd(500, true))
+	a.Equal(int64(0), bufferMemory.Load())
+}
+
+func TestMemoryLimitCountsPlanFiles(t *testing.T) {
+	a := assert.New(t)
+	defer memoryLimitTest(100, 80)()
+	c := NewMemoryCacheLimiter(1000)
+
+	a.True(c.TryAdd(60, true), "a chunk is always let into empty buffers, so that the job carries on")
+	a.False(c.TryAdd(10, true))
+	c.Remove(60)
+	a.True(c.TryAdd(10, true))
+	a.True(c.TryAdd(10, true))
+	a.False(c.TryAdd(10, true))
+	c.Remove(20)
+}
+
+func TestSlicePoolKeepsToTheMemoryLimit(t *testing.T) {
+	a := assert.New(t)
+	pool := NewMultiSizeSlicePool(1024)
+	pooled := pooledMemory.Load() // what other tests' pools are keeping
+
+	pool.ReturnSlice(pool.RentSlice(64))
+	a.Equal(pooled+64, pooledMemory.Load())
+	a.Len(pool.RentSlice(64), 64)
+	a.Equal(pooled, pooledMemory.Load(), "renting it took it out of the pool")
+
+	defer memoryLimitTest(pooled+100, 80)()
+	pool.ReturnSlice(pool.RentSlice(64))
+	a.Equal(pooled, pooledMemory.Load(), "it's left for the GC, rather than kept past the limit")
+	pool.ReturnSlice(pool.RentSlice(16))
+	a.Equal(pooled+16, pooledMemory.Load())
+	pool.RentSlice(16)
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mmapTestChunkSize = 4 * 1024 * 1024
+
+// mmapTestFile writes a file that's big enough to be mapped, and turns on --mmap-uploads, returning what turns it off
+func mmapTestFile(t *testing.T, size int) (string, []byte, func()) {
+	if !mmapUploadsSupported {
+		t.Skip("memory-mapped uploads aren't supported on this platform")
+	}
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "big")
+	assert.NoError(t, os.WriteFile(path, content, 0644))
+	assert.NoError(t, SetMmapUploads(true))
+	return path, content, func() { _ = SetMmapUploads(false) }
+}
+
+func TestMmapUploadsSliceChunksFromTheMapping(t *testing.T) {
+	a := assert.New(t)
+	path, content, done := mmapTestFile(t, 2*mmapTestChunkSize+1000)
+	defer done()
+	factory := func() (CloseableReaderAt, error) { return OpenLocalSourceFile(path) }
+
+	src, err := factory()
+	a.NoError(err)
+	mapped, ok := src.(*MappedSourceFile)
+	a.True(ok)
+
+	pool := NewMultiSizeSlicePool(mmapTestChunkSize)
+	var chunks []SingleChunkReader
+	for offset := int64(0); offset < int64(len(content)); offset += mmapTestChunkSize {
+		length := min(mmapTestChunkSize, int64(len(content))-offset)
+		chunk := NewSingleChunkReader(context.Background(), factory, NewChunkID(path, offset, length), length, nil, nil, pool, NewCacheLimiter(4*mmapTestChunkSize))
+		a.NoError(chunk.BlockingPrefetch(src, false))
+		a.NotNil(chunk.(*singleChunkReader).releaseMapping)
+		chunks = append(chunks, chunk)
+	}
+
+	// as when uploading, the file is closed once its chunks have been prefetched, but before they've all been sent
+	a.NoError(src.Close())
+	_ = src.Close() // which, done twice, doesn't take the chunks' references
+	a.Equal(int32(len(chunks)), mapped.refs.Load())
+
+	var sent bytes.Buffer
+	for _, chunk := range chunks {
+		a.False(chunk.HasPrefetchedEntirelyZeros())
+		_, err = io.Copy(&sent, chunk)
+		a.NoError(err)
+		a.NoError(chunk.Close())
+	}
+	a.Equal(content, sent.Bytes())
+	a.Equal(int32(0), mapped.refs.Load(), "the last chunk to be sent unmapped it")
+
+	// small files are read as usual
+	small := filepath.Join(t.TempDir(), "small")
+	a.NoError(os.WriteFile(small, content[:1000], 0644))
+	f, err := OpenLocalSourceFile(small)
+	a.NoError(err)
+	a.IsType(&os.File{}, f)
+	a.NoError(f.Close())
+}
+
+func TestMmapUploadOfATruncatedFileFails(t *testing.T) {
+	a := assert.New(t)
+	path, _, done := mmapTestFile(t, 2*mmapTestChunkSize)
+	defer done()
+	factory := func() (CloseableReaderAt, error) { return OpenLocalSourceFile(path) }
+
+	src, err := factory()
+	a.NoError(err)
+	defer src.Close()
+	chunk := NewSingleChunkReader(context.Background(), factory, NewChunkID(path, mmapTestChunkSize, mmapTestChunkSize), mmapTestChunkSize, nil, nil, NewMultiSizeSlicePool(mmapTestChunkSize), NewCacheLimiter(4*mmapTestChunkSize))
+	a.NoError(chunk.BlockingPrefetch(src, false))
+
+	// the pages under the chunk are no longer part of the file, so reading them faults, which is an error rather than a crash
+	a.NoError(os.Truncate(path, 0))
+	_, err = io.Copy(io.Discard, chunk)
+	a.ErrorContains(err, "truncated")
+	a.False(chunk.HasPrefetchedEntirelyZeros())
+}
+
+func TestMmapUploadsCantBypassTheCache(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(SetCacheBypass(CacheBypassDontNeed))
+	defer func() { _ = SetCacheBypass(CacheBypassNone) }()
+	a.Error(SetMmapUploads(true))
+}
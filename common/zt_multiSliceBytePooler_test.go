@@ -21,9 +21,13 @@
 package common
 
 import (
-	"github.com/stretchr/testify/assert"
 	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMultiSliceSlotInfo(t *testing.T) {
@@ -42,23 +46,106 @@ func TestMultiSliceSlotInfo(t *testing.T) {
 		{5, 3, 8},
 		{8, 3, 8},
 		{9, 4, 16},
-		{eightMB - 1, 23, eightMB},
-		{eightMB, 23, eightMB},
-		{eightMB + 1, 24, eightMB * 2},
-		{100 * 1024 * 1024, 27, 128 * 1024 * 1024},
+		{32 * 1024, 15, 32 * 1024},
+		// above 32 KB, there are four slots to each power of two
+		{32*1024 + 1, 16, 40 * 1024},
+		{64 * 1024, 19, 64 * 1024},
+		{64*1024 + 1, 20, 80 * 1024},
+		{eightMB - 1, 47, eightMB},
+		{eightMB, 47, eightMB},
+		{eightMB + 1, 48, eightMB + eightMB/4},
+		{100 * 1024 * 1024, 62, 112 * 1024 * 1024},
 	}
 
 	for _, x := range cases {
-		logBase2 := math.Log2(float64(x.size))
-		roundedLogBase2 := int(math.Round(logBase2 + 0.49999999999999)) // rounds up unless already exact(ish)
-
-		// now lets see if the pooler is working as we expect
 		slotIndex, maxCap := getSlotInfo(int64(x.size))
 
-		a.Equal(roundedLogBase2, slotIndex)     // this what, mathematically, we expect
-		a.Equal(x.expectedSlotIndex, slotIndex) // this what our test case said (should be same)
+		if x.size <= 32*1024 {
+			logBase2 := math.Log2(float64(x.size))
+			roundedLogBase2 := int(math.Round(logBase2 + 0.49999999999999)) // rounds up unless already exact(ish)
+			a.Equal(roundedLogBase2, slotIndex)                             // this what, mathematically, we expect
+		}
+		a.Equal(x.expectedSlotIndex, slotIndex, x.size) // this what our test case said
+		a.Equal(x.expectedMaxCapInSlot, maxCap, x.size)
+	}
+
+}
+
+func TestMultiSliceRefCounting(t *testing.T) {
+	a := assert.New(t)
+	for _, size := range []int64{1000, 5 * 1024 * 1024} {
+		pool := NewMultiSizeSlicePool(8 * 1024 * 1024)
+		slice := pool.RentSlice(size)
 
-		a.Equal(x.expectedMaxCapInSlot, maxCap)
+		pool.RetainSlice(slice)
+		pool.ReturnSlice(slice)
+		a.NotSame(&slice[0], &pool.RentSlice(size)[0], "it's still retained, so it isn't pooled")
+		pool.ReturnSlice(slice)
+		a.Same(&slice[0], &pool.RentSlice(size)[0], "the last reference has been returned")
 	}
 
-}
\ No newline at end of file
+	// in the big slots, a slice that's returned twice is pooled once
+	pool := NewMultiSizeSlicePool(8 * 1024 * 1024)
+	slice := pool.RentSlice(1024 * 1024)
+	pool.ReturnSlice(slice)
+	pool.ReturnSlice(slice)
+	a.Same(&slice[0], &pool.RentSlice(1024 * 1024)[0])
+	a.NotSame(&slice[0], &pool.RentSlice(1024 * 1024)[0])
+}
+
+func TestMultiSliceAlignment(t *testing.T) {
+	a := assert.New(t)
+	pool := NewMultiSizeSlicePool(8 * 1024 * 1024)
+	for _, size := range []int64{33 * 1024, 100 * 1000, 5*1024*1024 + 1} {
+		slice := pool.RentSlice(size)
+		a.Len(slice, int(size))
+		a.Zero(sliceAddress(slice)%sliceAlignment, size)
+		_, maxCap := getSlotInfo(size)
+		a.Equal(maxCap, cap(slice))
+	}
+}
+
+// BenchmarkSlicePool rents, fills and returns chunk buffers from as many goroutines at once as a busy job has: for
+// 10k files, each in a chunk of its own size, and for one huge file, in chunks of a block size that isn't a power of two
+func BenchmarkSlicePool(b *testing.B) {
+	const KiB, MiB = 1024, 1024 * 1024
+	cases := []struct {
+		name        string
+		concurrency int
+		chunkSize   func(i int) int64
+	}{
+		{"10k-files", 64, func(i int) int64 { return int64(1*KiB + (i%10000)*97) }}, // from 1 KiB to a little under 1 MiB
+		{"1-huge-file", 16, func(i int) int64 { return 20 * MiB }},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			source := make([]byte, c.chunkSize(9999))
+			pool := NewMultiSizeSlicePool(MaxBlockBlobBlockSize)
+			var next atomic.Int64
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			gcPause := stats.PauseTotalNs
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for w := 0; w < c.concurrency; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := int(next.Add(1)); i <= b.N; i = int(next.Add(1)) {
+						slice := pool.RentSlice(c.chunkSize(i))
+						copy(slice, source)
+						pool.ReturnSlice(slice)
+					}
+				}()
+			}
+			wg.Wait()
+			b.StopTimer()
+
+			runtime.ReadMemStats(&stats)
+			b.ReportMetric(float64(stats.PauseTotalNs-gcPause)/float64(b.N), "gc-pause-ns/op")
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPACEnvironment resolves the hosts in addresses, and says that it's Wednesday 15 May 2024, at 14:30 UTC
+func testPACEnvironment(addresses map[string]string) pacEnvironment {
+	return pacEnvironment{
+		resolve: func(host string) net.IP {
+			return net.ParseIP(addresses[host]).To4()
+		},
+		myAddress: func() string { return "10.1.2.3" },
+		now: func() time.Time {
+			return time.Date(2024, time.May, 15, 14, 30, 0, 0, time.UTC)
+		},
+	}
+}
+
+const testPACFile = `
+// the sort of PAC file that an enterprise network has
+var direct = "DIRECT";
+var bypassDomains = [".corp.example.com", ".internal"];
+
+function isBypassed(host) {
+	for (var i = 0; i < bypassDomains.length; i++) {
+		if (dnsDomainIs(host, bypassDomains[i])) {
+			return true;
+		}
+	}
+	return false;
+}
+
+function FindProxyForURL(url, host) {
+	host = host.toLowerCase();
+	if (isPlainHostName(host) || isBypassed(host))
+		return direct;
+	/* the lab's network has its own proxy */
+	if (isInNet(myIpAddress(), "10.1.0.0", "255.255.0.0") && shExpMatch(host, "*.blob.core.windows.net"))
+		return "SOCKS5 lab-socks:1080; PROXY proxy.corp.example.com:8080"
+	if (isInNet(dnsResolve(host), "192.168.0.0", "255.255.0.0")) return 'DIRECT'
+	if (url.substring(0, 6) == "https:" && weekdayRange("MON", "FRI") && timeRange(9, 17)) {
+		return "PROXY busy-hours:3128; DIRECT";
+	}
+	return "PROXY proxy.corp.example.com:8080; DIRECT";
+}
+`
+
+func TestPACScript(t *testing.T) {
+	a := assert.New(t)
+	env := testPACEnvironment(map[string]string{"nas.example.com": "192.168.1.20", "www.example.com": "203.0.113.10"})
+	script, err := newPACScript(testPACFile, env.builtins())
+	a.NoError(err)
+
+	for _, c := range []struct{ url, host, expected string }{
+		{"https://fileserver/", "fileserver", "DIRECT"},
+		{"https://build.CORP.example.com/", "build.CORP.example.com", "DIRECT"},
+		{"https://account.blob.core.windows.net/", "account.blob.core.windows.net", "SOCKS5 lab-socks:1080; PROXY proxy.corp.example.com:8080"},
+		{"https://nas.example.com/", "nas.example.com", "DIRECT"},
+		{"https://www.example.com/", "www.example.com", "PROXY busy-hours:3128; DIRECT"},
+		{"http://www.example.com/", "www.example.com", "PROXY proxy.corp.example.com:8080; DIRECT"},
+	} {
+		result, err := script.call("FindProxyForURL", c.url, c.host)
+		if a.NoError(err, c.host) {
+			a.Equal(c.expected, result.String(), c.host)
+		}
+	}
+}
+
+func TestPACScriptExpressions(t *testing.T) {
+	a := assert.New(t)
+	for src, expected := range map[string]string{
+		`return 1 + 2 * 3 - 4 / 2;`:                             "5",
+		`return "a" + 1 + 2;`:                                   "a12",
+		`return 1 + 2 + "a";`:                                   "3a",
+		`return 10 % 4 == 2 && !(1 > 2);`:                       "true",
+		`return "2" == 2;`:                                      "true",
+		`return "2" === 2;`:                                     "false",
+		`return null == undefined;`:                             "true",
+		`return "" || "fallback";`:                              "fallback",
+		`return 0 ? "yes" : "no";`:                              "no",
+		`var n = 0; while (n < 10) { n += 3; } return n;`:       "12",
+		`var n = 0; for (;;) { if (++n > 4) break; } return n;`: "5",
+		`var s = ""; for (var i = 0; i < 5; i++) { if (i % 2) continue; s += i; } return s;`:                 "024",
+		`var x = [1, 2]; x.push(3); x[5] = 6; return x.join("-");`:                                           "1-2-3---6",
+		`return "a.b.c".split(".").length + "abc".indexOf("c");`:                                             "5",
+		`return "Hello".charAt(1) + "Hello"[4] + "Hello".substr(-3, 2) + "Hello".substring(3, 1);`:           "eollel",
+		`var f = function(a, b) { return b === undefined ? a : a + b; }; return f(1) + f(2, 3);`:             "6",
+		`function outer() { var x = 1; function inner() { return x + 1; } return inner(); } return outer();`: "2",
+		`return ["a", "b"].indexOf("b") + ["a"].includes("a");`:                                              "2",
+		`switch (host) { case "a": return "PROXY a:1"; default: return "DIRECT"; }`:                          "DIRECT",
+		`return /^int/.test("internal.example.com") ? "DIRECT" : "PROXY p:1";`:                               "DIRECT",
+	} {
+		script, err := newPACScript("function FindProxyForURL(url, host) {\n"+src+"\n}", nil)
+		if !a.NoError(err, src) {
+			continue
+		}
+		result, err := script.call("FindProxyForURL", "", "")
+		if a.NoError(err, src) {
+			a.Equal(expected, result.String(), src)
+		}
+	}
+}
+
+func TestPACFunctions(t *testing.T) {
+	a := assert.New(t)
+	env := testPACEnvironment(map[string]string{"host.example.com": "10.0.5.1"})
+	for src, expected := range map[string]string{
+		`localHostOrDomainIs("www", "www.example.com")`:                "true",
+		`localHostOrDomainIs("www.example.com", "www.example.com")`:    "true",
+		`localHostOrDomainIs("www.other.com", "www.example.com")`:      "false",
+		`localHostOrDomainIs("ww", "www.example.com")`:                 "false",
+		`dnsDomainLevels("a.b.example.com")`:                           "3",
+		`isResolvable("host.example.com") && !isResolvable("nowhere")`: "true",
+		`isInNet("host.example.com", "10.0.0.0", "255.0.0.0")`:         "true",
+		`isInNet("nowhere", "10.0.0.0", "255.0.0.0")`:                  "false",
+		`dnsResolve("nowhere")`:                                        "null",
+		`convert_addr("10.0.0.1")`:                                     "167772161",
+		`shExpMatch("a.b.example.com", "*.example.???")`:               "true",
+		`shExpMatch("example.com", "*.example.com")`:                   "false",
+		`shExpMatch("a+b.com", "a+b.*")`:                               "true",
+		`weekdayRange("WED")`:                                          "true",
+		`weekdayRange("SAT", "MON")`:                                   "false",
+		`weekdayRange("FRI", "WED", "GMT")`:                            "true",
+		`timeRange(14)`:                                                "true",
+		`timeRange(9, 14)`:                                             "false",
+		`timeRange(14, 30, 15, 0)`:                                     "true",
+		`timeRange(22, 6)`:                                             "false",
+		`dateRange(15)`:                                                "true",
+		`dateRange("MAY")`:                                             "true",
+		`dateRange(2023)`:                                              "false",
+		`dateRange("APR", "JUN")`:                                      "true",
+		`dateRange(1, 14)`:                                             "false",
+		`dateRange("NOV", "FEB")`:                                      "false",
+		`dateRange(1, "MAY", 2024, 31, "DEC", 2024)`:                   "true",
+	} {
+		script, err := newPACScript("function FindProxyForURL(url, host) { return "+src+"; }", env.builtins())
+		if !a.NoError(err, src) {
+			continue
+		}
+		result, err := script.call("FindProxyForURL", "", "")
+		if a.NoError(err, src) {
+			a.Equal(expected, result.String(), src)
+		}
+	}
+}
+
+func TestPACScriptErrors(t *testing.T) {
+	a := assert.New(t)
+	for src, expected := range map[string]string{
+		`function FindProxyForURL(url, host) { return "DIRECT" `:  "Line 1:55 Unexpected end of input",
+		`function FindProxyForURL(url, host) { return 'DIRECT; }`: "Line 1:46 Unexpected token ILLEGAL",
+		`function findProxy(url, host) { return "DIRECT"; }`:      "it doesn't define the function FindProxyForURL(url, host)",
+	} {
+		_, err := newPACScript(src, nil)
+		a.ErrorContains(err, expected, src)
+	}
+
+	script, err := newPACScript(`function FindProxyForURL(url, host) { return lookup(host); }`, nil)
+	a.NoError(err)
+	_, err = script.call("FindProxyForURL", "", "")
+	a.ErrorContains(err, "lookup is not defined")
+
+	defer func(timeout time.Duration) { pacCallTimeout = timeout }(pacCallTimeout)
+	pacCallTimeout = 100 * time.Millisecond
+	script, err = newPACScript(`function FindProxyForURL(url, host) { while (true) {} }`, nil)
+	a.NoError(err)
+	_, err = script.call("FindProxyForURL", "", "")
+	a.ErrorIs(err, errPACTimeout)
+	_, err = newPACScript(`for (;;) {}`, nil)
+	a.ErrorIs(err, errPACTimeout)
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePACResult(t *testing.T) {
+	a := assert.New(t)
+	for result, expected := range map[string]string{
+		"PROXY proxy:8080; DIRECT":          "http://proxy:8080",
+		"  SOCKS5 localhost:1080 ; DIRECT":  "socks5://localhost:1080",
+		"SOCKS4 old:1080; HTTPS secure:443": "https://secure:443",
+		"DIRECT; PROXY never-used:8080":     "",
+		"socks localhost:1080":              "socks5://localhost:1080",
+	} {
+		u, err := parsePACResult(result)
+		a.NoError(err, result)
+		if expected == "" {
+			a.Nil(u, result)
+		} else {
+			a.Equal(expected, u.String(), result)
+		}
+	}
+
+	_, err := parsePACResult("SOCKS4 old:1080")
+	a.ErrorContains(err, "has no proxy that AzCopy can use")
+}
+
+func TestPACProxyFunc(t *testing.T) {
+	a := assert.New(t)
+	var fetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write([]byte(`function FindProxyForURL(url, host) {
+			if (dnsDomainIs(host, ".blob.core.windows.net")) return "SOCKS5 localhost:1080";
+			return "DIRECT";
+		}`))
+	}))
+	defer server.Close()
+
+	lookup := pacProxyFunc(server.URL + "/proxy.pac")
+	req, _ := http.NewRequest(http.MethodGet, "https://account.blob.core.windows.net/c/b", nil)
+	u, err := lookup(req)
+	a.NoError(err)
+	a.Equal("socks5://localhost:1080", u.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "https://account.file.core.windows.net/s/f", nil)
+	u, err = lookup(req)
+	a.NoError(err)
+	a.Nil(u)
+	a.Equal(1, fetches, "the PAC file is only fetched once")
+
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	a.NoError(os.WriteFile(path, []byte(`function FindProxyForURL(url, host) { return "PROXY proxy:3128"; }`), 0o644))
+	u, err = pacProxyFunc(path)(req)
+	a.NoError(err)
+	a.Equal("http://proxy:3128", u.String())
+
+	_, err = pacProxyFunc(filepath.Join(t.TempDir(), "missing.pac"))(req)
+	a.ErrorContains(err, "couldn't fetch the PAC file")
+}
+
+func TestPACLoaderRetriesAfterFailure(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	loader := &pacLoader{location: path}
+
+	now := time.Now()
+	_, err := loader.get(now)
+	a.ErrorContains(err, "couldn't fetch the PAC file")
+
+	a.NoError(os.WriteFile(path, []byte(`function FindProxyForURL(url, host) { return "DIRECT"; }`), 0o644))
+	_, err = loader.get(now.Add(time.Minute))
+	a.Error(err, "the failure is kept for a while")
+	p, err := loader.get(now.Add(pacCacheDuration + time.Second))
+	a.NoError(err, "but not for the rest of the job")
+	a.NotNil(p)
+
+	a.NoError(os.Remove(path))
+	p2, err := loader.get(now.Add(2 * pacCacheDuration))
+	a.NoError(err, "once loaded, the file isn't fetched again")
+	a.Same(p, p2)
+}
+
+func TestPACProxyDoesntHoldUpOtherHosts(t *testing.T) {
+	a := assert.New(t)
+	release := make(chan struct{})
+	defer close(release)
+	script, err := newPACScript(`function FindProxyForURL(url, host) { return wait(host); }`, map[string]pacBuiltin{
+		"wait": func(args []goja.Value) any {
+			if args[0].String() == "slow.example.com" {
+				<-release // as a DNS lookup that takes a long time
+			}
+			return "PROXY proxy:8080"
+		},
+	})
+	a.NoError(err)
+	p := newPACProxy(script)
+
+	now := time.Now()
+	fast, _ := url.Parse("https://fast.example.com/c/b")
+	_, err = p.proxyFor(fast, now)
+	a.NoError(err)
+
+	slow, _ := url.Parse("https://slow.example.com/c/b")
+	go func() { _, _ = p.proxyFor(slow, now) }()
+	time.Sleep(50 * time.Millisecond) // let the script start on the slow host
+
+	answered := make(chan struct{})
+	go func() {
+		_, _ = p.proxyFor(fast, now.Add(time.Minute))
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(5 * time.Second):
+		a.Fail("the answer kept for another host waited for the script")
+	}
+}
+
+func TestPACProxyCachesByHost(t *testing.T) {
+	a := assert.New(t)
+	script, err := newPACScript(`var calls = 0;
+		function FindProxyForURL(url, host) { calls++; return "PROXY proxy-" + calls + ":8080"; }`, nil)
+	a.NoError(err)
+	p := newPACProxy(script)
+
+	now := time.Now()
+	first, _ := url.Parse("https://account.blob.core.windows.net/c/first")
+	second, _ := url.Parse("https://account.blob.core.windows.net/c/second")
+	u, err := p.proxyFor(first, now)
+	a.NoError(err)
+	a.Equal("proxy-1:8080", u.Host)
+	u, _ = p.proxyFor(second, now.Add(time.Minute))
+	a.Equal("proxy-1:8080", u.Host, "the answer for the host is kept")
+	u, _ = p.proxyFor(second, now.Add(pacCacheDuration+time.Second))
+	a.Equal("proxy-2:8080", u.Host, "until it's out of date")
+}
+
+func TestBypassProxyFunc(t *testing.T) {
+	a := assert.New(t)
+	proxy, _ := url.Parse("http://proxy:3128")
+	lookup := bypassProxyFunc("storage.example.com, .corp.example.com;10.0.0.0/8 <local> 192.168.1.5:8443",
+		func(*http.Request) (*url.URL, error) { return proxy, nil })
+
+	for target, proxied := range map[string]bool{
+		"https://storage.example.com/c/b":         false,
+		"https://account.storage.example.com/c/b": false,
+		"https://corp.example.com/c/b":            true,
+		"https://build.corp.example.com/c/b":      false,
+		"https://10.1.2.3/c/b":                    false,
+		"https://fileserver/c/b":                  false,
+		"https://192.168.1.5:8443/c/b":            false,
+		"https://192.168.1.5/c/b":                 true,
+		"https://account.blob.core.windows.net/":  true,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		u, err := lookup(req)
+		a.NoError(err)
+		a.Equal(proxied, u != nil, target)
+	}
+}
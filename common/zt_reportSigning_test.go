@@ -0,0 +1,56 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestSigningKey(a *assert.Assertions, dir string, name string, key any) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	a.NoError(err)
+	path := filepath.Join(dir, name)
+	a.NoError(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return path
+}
+
+func TestSignedReportCanBeVerified(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	report := []byte(`{"Passed": true}`)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	a.NoError(err)
+	signer, err := NewReportSigner(writeTestSigningKey(a, dir, "ed25519.pem", private))
+	a.NoError(err)
+	reportPath := filepath.Join(dir, "report.json")
+	a.NoError(WriteSignedReport(reportPath, report, signer))
+	signature, err := os.ReadFile(reportPath + ".sig")
+	a.NoError(err)
+	a.True(ed25519.Verify(public, report, signature))
+	a.False(ed25519.Verify(public, []byte(`{"Passed": false}`), signature))
+
+	// other keys sign the SHA-256 digest of the report
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+	signer, err = NewReportSigner(writeTestSigningKey(a, dir, "ec.pem", ecKey))
+	a.NoError(err)
+	signature, err = signer.Sign(report)
+	a.NoError(err)
+	digest := sha256.Sum256(report)
+	a.True(ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], signature))
+
+	notPEM := filepath.Join(dir, "key.txt")
+	a.NoError(os.WriteFile(notPEM, []byte("not a key"), 0600))
+	_, err = NewReportSigner(notPEM)
+	a.ErrorContains(err, "PEM")
+}
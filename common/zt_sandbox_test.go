@@ -0,0 +1,79 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestSandbox is OpenSandbox without the Casper service, which the tests don't connect through
+func openTestSandbox(t *testing.T, dirs ...string) *Sandbox {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	s := &Sandbox{wd: wd}
+	for _, dir := range dirs {
+		root, err := os.OpenRoot(dir)
+		assert.NoError(t, err)
+		s.roots = append(s.roots, sandboxRoot{dir: filepath.Clean(dir), root: root})
+	}
+	assert.True(t, currentSandbox.CompareAndSwap(nil, s))
+	t.Cleanup(func() {
+		currentSandbox.Store(nil)
+		inCapabilityMode.Store(false)
+		s.closeRoots()
+	})
+	return s
+}
+
+func TestSandboxFindsDeepestRoot(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	inner := filepath.Join(dir, "inner")
+	a.NoError(os.Mkdir(inner, 0755))
+	s := openTestSandbox(t, dir, inner)
+
+	root, rel, ok := s.beneath(filepath.Join(inner, "a", "b"))
+	a.True(ok)
+	a.Same(s.roots[1].root, root)
+	a.Equal(filepath.Join("a", "b"), rel)
+
+	root, rel, ok = s.beneath(dir)
+	a.True(ok)
+	a.Same(s.roots[0].root, root)
+	a.Equal(".", rel)
+
+	_, _, ok = s.beneath(dir + "other")
+	a.False(ok, "a sibling whose name starts with the root's isn't beneath it")
+}
+
+func TestSandboxOpensBeneathRoots(t *testing.T) {
+	a := assert.New(t)
+	dir, outside := t.TempDir(), t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(dir, "in"), []byte("in"), 0644))
+	a.NoError(os.WriteFile(filepath.Join(outside, "out"), []byte("out"), 0644))
+	a.NoError(os.Symlink(filepath.Join(outside, "out"), filepath.Join(dir, "escape")))
+	openTestSandbox(t, dir)
+
+	f, err := SandboxOpenFile(filepath.Join(dir, "in"), os.O_RDONLY, 0)
+	a.NoError(err)
+	a.NoError(f.Close())
+	_, err = SandboxStat(filepath.Join(dir, "in"))
+	a.NoError(err)
+
+	// a link out of the root can't be followed, as it couldn't be in capability mode
+	_, err = SandboxOpenFile(filepath.Join(dir, "escape"), os.O_RDONLY, 0)
+	a.Error(err)
+
+	// outside the roots, files are opened as they are without a sandbox, until it has been entered
+	f, err = SandboxOpenFile(filepath.Join(outside, "out"), os.O_RDONLY, 0)
+	a.NoError(err)
+	a.NoError(f.Close())
+
+	inCapabilityMode.Store(true)
+	_, err = SandboxOpenFile(filepath.Join(outside, "out"), os.O_RDONLY, 0)
+	a.ErrorIs(err, errNotInSandbox)
+	_, err = SandboxStat(filepath.Join(outside, "out"))
+	a.ErrorIs(err, errNotInSandbox)
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetSourceAddress() {
+	sourceAddresses.Store(nil)
+	sourceAddressDescription.Store("")
+}
+
+func TestBindSourceAddressErrors(t *testing.T) {
+	a := assert.New(t)
+	defer resetSourceAddress()
+
+	a.NoError(BindSourceAddress("", ""))
+	a.Nil(SourceAddr())
+	a.Error(BindSourceAddress("127.0.0.1", "lo"))
+	a.Error(BindSourceAddress("not-an-ip", ""))
+	a.Error(BindSourceAddress("192.0.2.99", ""), "an address that this machine doesn't have")
+	a.Error(BindSourceAddress("", "no-such-interface0"))
+	a.Error(BindSourceAddress("127.0.0.1,192.0.2.99", ""))
+	a.Error(BindSourceAddress("127.0.0.1,::1", ""), "addresses of different families")
+	a.Nil(SourceAddr())
+	a.Empty(SourceAddressDescription())
+}
+
+func TestInterfaceAddress(t *testing.T) {
+	a := assert.New(t)
+	network := func(cidr string) net.Addr {
+		ip, n, _ := net.ParseCIDR(cidr)
+		n.IP = ip
+		return n
+	}
+
+	addrs := []net.Addr{network("fe80::1/64"), network("2001:db8::5/64"), network("10.0.0.5/24")}
+	a.Equal("10.0.0.5", interfaceAddress(addrs, 0).String())
+	a.Equal("10.0.0.5", interfaceAddress(addrs, 4).String())
+	a.Equal("2001:db8::5", interfaceAddress(addrs, 6).String())
+	a.Equal("2001:db8::5", interfaceAddress(addrs[:2], 0).String())
+	a.Nil(interfaceAddress(addrs[:2], 4))
+	a.Nil(interfaceAddress(addrs[:1], 0))
+	a.Nil(interfaceAddress(nil, 0))
+}
+
+func TestBindSourceAddressConnectsFromIt(t *testing.T) {
+	a := assert.New(t)
+	defer resetSourceAddress()
+
+	var loopback string
+	interfaces, _ := net.Interfaces()
+	for _, i := range interfaces {
+		addrs, _ := i.Addrs()
+		if i.Flags&net.FlagLoopback != 0 && hasAddress(addrs, net.IPv4(127, 0, 0, 1)) {
+			loopback = i.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("there's no loopback interface with 127.0.0.1")
+	}
+
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}))
+	defer server.Close()
+
+	for _, bind := range [][2]string{{"127.0.0.1", ""}, {"", loopback}} {
+		a.NoError(BindSourceAddress(bind[0], bind[1]))
+		a.Equal("127.0.0.1", SourceAddr().(*net.TCPAddr).IP.String())
+		a.Contains(SourceAddressDescription(), "127.0.0.1")
+
+		client := newAzcopyHTTPClient()
+		resp, err := client.Get(server.URL)
+		if a.NoError(err) {
+			resp.Body.Close()
+			a.Equal("127.0.0.1", remote)
+		}
+	}
+	a.Contains(SourceAddressDescription(), loopback)
+}
+
+func TestSourceAddrTakesTurns(t *testing.T) {
+	a := assert.New(t)
+	defer resetSourceAddress()
+
+	sources := []*net.TCPAddr{{IP: net.ParseIP("10.0.0.5")}, {IP: net.ParseIP("10.1.0.5")}, {IP: net.ParseIP("10.2.0.5")}}
+	sourceAddresses.Store(&sources)
+	counts := map[string]int{}
+	for range 30 {
+		counts[SourceAddr().String()]++
+	}
+	a.Equal(map[string]int{"10.0.0.5:0": 10, "10.1.0.5:0": 10, "10.2.0.5:0": 10}, counts)
+
+	a.NoError(BindSourceAddress("127.0.0.1, 127.0.0.1", ""))
+	a.Len(*sourceAddresses.Load(), 2)
+	a.Equal("127.0.0.1, 127.0.0.1, in turn", SourceAddressDescription())
+}
@@ -83,6 +83,9 @@ var JobsAdmin interface {
 	// returns the current value of bytesOverWire.
 	BytesOverWire() int64
 
+	// UpdateTargetBandwidth changes the cap on the transfer rate, in bytes per second, with 0 for no cap
+	UpdateTargetBandwidth(newTarget int64)
+
 	//DeleteJob(jobID common.JobID)
 
 	TryGetPerformanceAdvice(bytesInJob uint64, filesInJob uint32, fromTo common.FromTo, dir common.TransferDirection, p *ste.PipelineNetworkStats) []common.PerformanceAdvice
@@ -94,7 +97,7 @@ var JobsAdmin interface {
 	JobMgrCleanUp(jobId common.JobID)
 }
 
-func initJobsAdmin(appCtx context.Context, concurrency ste.ConcurrencySettings, targetRateInMegaBitsPerSec float64) {
+func initJobsAdmin(appCtx context.Context, concurrency ste.ConcurrencySettings, targetRateInMegaBitsPerSec float64, burstMegabytes float64) {
 	if JobsAdmin != nil {
 		panic("initJobsAdmin was already called once")
 	}
@@ -108,11 +111,16 @@ func initJobsAdmin(appCtx context.Context, concurrency ste.ConcurrencySettings,
 	}
 
 	maxRamBytesToUse := getMaxRamForChunks()
+	if limit := common.MemoryLimit(); limit > 0 && limit < maxRamBytesToUse {
+		// the buffers could never fill up anyway
+		maxRamBytesToUse = limit
+	}
 
 	// use the "networking mega" (based on powers of 10, not powers of 2, since that's what mega means in networking context)
 	targetRateInBytesPerSec := int64(targetRateInMegaBitsPerSec * 1000 * 1000 / 8)
-	unusedExpectedCoarseRequestByteCount := int64(0)
-	pacer := ste.NewTokenBucketPacer(targetRateInBytesPerSec, unusedExpectedCoarseRequestByteCount)
+	// but megabytes of burst, like megabytes of block size, are powers of 2
+	burstBytes := int64(burstMegabytes * 1024 * 1024)
+	pacer := ste.NewTokenBucketPacer(targetRateInBytesPerSec, burstBytes)
 	// Note: as at July 2019, we don't currently have a shutdown method/event on JobsAdmin where this pacer
 	// could be shut down. But, it's global anyway, so we just leave it running until application exit.
 	ja := &jobsAdmin{
@@ -120,7 +128,7 @@ func initJobsAdmin(appCtx context.Context, concurrency ste.ConcurrencySettings,
 		jobIDToJobMgr:      newJobIDToJobMgr(),
 		pacer:              pacer,
 		slicePool:          common.NewMultiSizeSlicePool(common.MaxBlockBlobBlockSize),
-		cacheLimiter:       common.NewCacheLimiter(maxRamBytesToUse),
+		cacheLimiter:       common.NewMemoryCacheLimiter(maxRamBytesToUse),
 		fileCountLimiter:   common.NewCacheLimiter(int64(concurrency.MaxOpenDownloadFiles)),
 		cpuMonitor:         cpuMon,
 		appCtx:             appCtx,
@@ -150,7 +158,7 @@ func initJobsAdmin(appCtx context.Context, concurrency ste.ConcurrencySettings,
 // Note that, as at Feb 2019, the multiSizeSlicePooler uses additional RAM, over this level, since it includes the cache of
 // currently-unused, reusable slices, that is not tracked by cacheLimiter.
 // Also, block sizes that are not powers of two result in extra usage over and above this limit. (E.g. 100 MB blocks each
-// count 100 MB towards this limit, but actually consume 128 MB)
+// count 100 MB towards this limit, but actually consume 112 MB, since the pool has four slice sizes per power of two)
 func getMaxRamForChunks() int64 {
 
 	// return the user-specified override value, if any
@@ -0,0 +1,70 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jobsAdmin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// FollowBandwidthSchedule changes the cap on the transfer rate whenever schedule says, for as long as AzCopy runs.
+// Outside the schedule's rules, the cap is defaultMbps, which is what --cap-mbps gave. current is the cap that the
+// pacer was started with.
+func FollowBandwidthSchedule(schedule *common.BandwidthSchedule, defaultMbps, current float64) {
+	go func() {
+		for {
+			// the rules are to the minute, so the cap can only change at the start of one
+			next := time.Now().Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(time.Until(next))
+
+			mbps := scheduledMbps(schedule, defaultMbps, next)
+			if mbps == current {
+				continue
+			}
+			current = mbps
+			if mbps == 0 {
+				common.LogToJobLogWithPrefix("Bandwidth schedule: the transfer rate is no longer capped", common.LogInfo)
+			} else {
+				common.LogToJobLogWithPrefix(fmt.Sprintf("Bandwidth schedule: capping the transfer rate at %g Mbps", mbps), common.LogInfo)
+			}
+			// use the "networking mega", as initJobsAdmin does
+			JobsAdmin.UpdateTargetBandwidth(int64(mbps * 1000 * 1000 / 8))
+		}
+	}()
+}
+
+// scheduledMbps is the cap that schedule gives at t, or defaultMbps if it doesn't give one
+func scheduledMbps(schedule *common.BandwidthSchedule, defaultMbps float64, t time.Time) float64 {
+	if mbps, ok := schedule.MbpsAt(t); ok {
+		return mbps
+	}
+	return defaultMbps
+}
+
+// InitialMbps is the cap to start the pacer with, given what --cap-mbps and the schedule, if any, say
+func InitialMbps(schedule *common.BandwidthSchedule, capMbps float64) float64 {
+	if schedule == nil {
+		return capMbps
+	}
+	return scheduledMbps(schedule, capMbps, time.Now())
+}
@@ -25,7 +25,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -52,9 +54,13 @@ func ToFixed(num float64, precision int) float64 {
 }
 
 // MainSTE initializes the Storage Transfer Engine
-func MainSTE(concurrency ste.ConcurrencySettings, targetRateInMegaBitsPerSec float64) error {
+func MainSTE(concurrency ste.ConcurrencySettings, targetRateInMegaBitsPerSec float64, burstMegabytes float64) error {
 	// Initialize the JobsAdmin, resurrect Job plan files
-	initJobsAdmin(steCtx, concurrency, targetRateInMegaBitsPerSec)
+	initJobsAdmin(steCtx, concurrency, targetRateInMegaBitsPerSec, burstMegabytes)
+	// jobs planned by an earlier release, whose plans can be converted, are carried on with like its own
+	if err := ste.MigratePlanFiles(); err != nil {
+		common.GetLifecycleMgr().Warn("Some jobs planned by an earlier version of AzCopy can't be resumed, since their plan files couldn't be converted: " + err.Error())
+	}
 	// TODO: We may want to list listen first and terminate if there is already an instance listening
 
 	// if we've a custom mime map
@@ -115,7 +121,7 @@ func(order common.CopyJobPartOrderRequest) common.CopyJobPartOrderResponse {
 		SrcClient:         order.SrcServiceClient,
 		DstClient:         order.DstServiceClient,
 		SrcIsOAuth:        order.S2SSourceCredentialType.IsAzureOAuth(),
-		ScheduleTransfers: true,
+		ScheduleTransfers: !order.HoldTransfers,
 	}
 	jm.AddJobPart(args)
 
@@ -131,6 +137,18 @@ func(order common.CopyJobPartOrderRequest) common.CopyJobPartOrderResponse {
 	return common.CopyJobPartOrderResponse{JobStarted: true}
 }
 
+// ScheduleHeldJobParts schedules the transfers of a job's parts, which were ordered with HoldTransfers so that none of
+// them started before the last part had been ordered
+func ScheduleHeldJobParts(jobID common.JobID) {
+	jm, found := JobsAdmin.JobMgr(jobID)
+	if !found {
+		return
+	}
+	jm.IterateJobParts(true, func(partNum common.PartNumber, jpm ste.IJobPartMgr) {
+		jm.QueueJobParts(jpm)
+	})
+}
+
 // cancelpauseJobOrder api cancel/pause a job with given JobId
 /* A Job cannot be cancelled/paused in following cases
 	* If the Job has not been ordered completely it cannot be cancelled or paused
@@ -174,21 +192,8 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 	// Get the Job manager again for given JobId
 	jm, _ := JobsAdmin.JobMgr(req.JobID)
 
-	// Check whether Job has been completely ordered or not
-	completeJobOrdered := func(jm ste.IJobMgr) bool {
-		// completeJobOrdered determines whether final part for job with JobId has been ordered or not.
-		completeJobOrdered := false
-		for p := ste.PartNumber(0); true; p++ {
-			jpm, found := jm.JobPartMgr(p)
-			if !found {
-				break
-			}
-			completeJobOrdered = completeJobOrdered || jpm.Plan().IsFinalPart
-		}
-		return completeJobOrdered
-	}
-	// If the job has not been ordered completely, then job cannot be resumed
-	if !completeJobOrdered(jm) {
+	// If the job has not been ordered completely, then job cannot be resumed, except by the copy that carries on its scan
+	if !req.ContinueScan && !completeJobOrdered(jm) {
 		return common.CancelPauseResumeResponse{
 			CancelledPauseResumed: false,
 			ErrorMsg:              fmt.Sprintf("cannot resume job with JobId %s . It hasn't been ordered completely", req.JobID),
@@ -257,8 +262,12 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 		}
 	}
 
-	// After creating the Job mgr, set the include / exclude list of transfer.
-	jm.SetIncludeExclude(req.IncludeTransfer, req.ExcludeTransfer)
+	// After creating the Job mgr, set which of its transfers are to be retried.
+	var resumeFilter *ste.ResumeFilter
+	if req.FailedOnly || len(req.IncludeTransfer) > 0 || len(req.ExcludeTransfer) > 0 {
+		resumeFilter = &ste.ResumeFilter{FailedOnly: req.FailedOnly, Include: req.IncludeTransfer, Exclude: req.ExcludeTransfer}
+	}
+	jm.SetResumeFilter(resumeFilter)
 	jpp0 := jpm.Plan()
 	switch jpp0.JobStatus() {
 	// Cannot resume a Job which is in Cancelling state
@@ -307,8 +316,8 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 				// transferHeader represents the memory map transfer header of transfer at index position for given job and part number
 				jppt := jpp.Transfer(t)
 				// If the transfer status is less than -1, it means the transfer failed because of some reason.
-				// Transfer Status needs to reset.
-				if jppt.TransferStatus() <= common.ETransferStatus.Failed() {
+				// Transfer Status needs to reset, unless the transfer isn't being retried this time.
+				if jppt.TransferStatus() <= common.ETransferStatus.Failed() && resumeFilter.ShouldResume(jpp, t) {
 					jppt.SetTransferStatus(common.ETransferStatus.Restarted(), true)
 					jppt.SetErrorCode(0, true)
 				}
@@ -325,6 +334,57 @@ func ResumeJobOrder(req common.ResumeJobRequest) common.CancelPauseResumeRespons
 	return jr
 }
 
+// completeJobOrdered determines whether final part for job with JobId has been ordered or not.
+func completeJobOrdered(jm ste.IJobMgr) bool {
+	completeJobOrdered := false
+	for p := ste.PartNumber(0); true; p++ {
+		jpm, found := jm.JobPartMgr(p)
+		if !found {
+			break
+		}
+		completeJobOrdered = completeJobOrdered || jpm.Plan().IsFinalPart
+	}
+	return completeJobOrdered
+}
+
+// JobCompletelyOrdered reports whether the final part of the given job has been ordered, resurrecting the job if needs
+// be. A job that doesn't exist is reported as completely ordered, since there's no more of it to order.
+func JobCompletelyOrdered(jobID common.JobID) bool {
+	jm, found := JobsAdmin.JobMgr(jobID)
+	if !found {
+		if !JobsAdmin.ResurrectJob(jobID, nil, nil, false) {
+			return true
+		}
+		jm, _ = JobsAdmin.JobMgr(jobID)
+	}
+	return completeJobOrdered(jm)
+}
+
+// ListJobSources calls add with the source of each transfer in the parts of the given job from part fromPart on, as
+// it is in the plan, relative to the job's source root. It returns how many parts the job has. The job must have been
+// resurrected.
+func ListJobSources(jobID common.JobID, fromPart common.PartNumber, add func(source string)) (parts common.PartNumber) {
+	jm, found := JobsAdmin.JobMgr(jobID)
+	if !found {
+		return 0
+	}
+	for ; ; parts++ {
+		jpm, found := jm.JobPartMgr(parts)
+		if !found {
+			break
+		}
+		if parts < fromPart {
+			continue
+		}
+		jpp := jpm.Plan()
+		for t := uint32(0); t < jpp.NumTransfers; t++ {
+			src, _ := jpp.TransferSrcDstRelatives(t)
+			add(strings.Clone(src)) // it's in the plan file's memory map
+		}
+	}
+	return parts
+}
+
 // GetJobSummary api returns the job progress summary of an active job
 /*
 * Return following Properties in Job Progress Summary
@@ -399,6 +459,10 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		js.AverageE2EMilliseconds = pipeStats.AverageE2EMilliseconds()
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
+		js.RetryAfterCount = pipeStats.RetryAfterCount()
+		js.ThrottledMilliseconds = pipeStats.ThrottledTime().Milliseconds()
+		js.StallCount = pipeStats.StallCount()
+		js.StalledMilliseconds = pipeStats.StalledTime().Milliseconds()
 	}
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
@@ -486,6 +550,9 @@ func resurrectJobSummary(jm ste.IJobMgr) common.ListJobSummaryResponse {
 				common.ETransferStatus.TierAvailabilityCheckFailure(),
 				common.ETransferStatus.BlobTierFailure():
 				js.TransfersFailed++
+				if code := jppt.ErrorCode(); code == http.StatusUnauthorized || code == http.StatusForbidden {
+					js.TransfersFailedAuth++
+				}
 				// getting the source and destination for failed transfer at position - index
 				src, dst, isFolder := jpp.TransferSrcDstStrings(t)
 				// appending to list of failed transfer
@@ -549,6 +616,10 @@ func resurrectJobSummary(jm ste.IJobMgr) common.ListJobSummaryResponse {
 		js.AverageE2EMilliseconds = pipeStats.AverageE2EMilliseconds()
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
+		js.RetryAfterCount = pipeStats.RetryAfterCount()
+		js.ThrottledMilliseconds = pipeStats.ThrottledTime().Milliseconds()
+		js.StallCount = pipeStats.StallCount()
+		js.StalledMilliseconds = pipeStats.StalledTime().Milliseconds()
 	}
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
@@ -630,6 +701,122 @@ func ListJobTransfers(r common.ListJobTransfersRequest) common.ListJobTransfersR
 	return ljt
 }
 
+// PlannedHardlink is a hard link that a download job was told to make, as recorded in its plan.
+type PlannedHardlink struct {
+	Destination string
+	Target      string // the POSIXHardlinkMeta value of the blob standing in for the link
+}
+
+// ListJobHardlinks returns the hard links in the plan of the given job, so that they can be made when it is resumed,
+// and the overwrite option they are to be made with.
+func ListJobHardlinks(jobID common.JobID) (links []PlannedHardlink, overwrite common.OverwriteOption) {
+	jm, found := JobsAdmin.JobMgr(jobID)
+	if !found {
+		return nil, common.EOverwriteOption.False()
+	}
+
+	for partNum := ste.PartNumber(0); true; partNum++ {
+		jpm, found := jm.JobPartMgr(partNum)
+		if !found {
+			break
+		}
+		jpp := jpm.Plan()
+		overwrite = jpp.ForceWrite
+		for t := uint32(0); t < jpp.NumTransfers; t++ {
+			if jpp.Transfer(t).EntityType != common.EEntityType.Hardlink() {
+				continue
+			}
+			_, metadata, _, _, _, _, _, _, _, _, _, _, _ := jpp.TransferSrcPropertiesAndMetadata(t)
+			target, ok := common.TryReadMetadata(metadata, common.POSIXHardlinkMeta)
+			if !ok || target == nil {
+				continue
+			}
+			_, dst, _ := jpp.TransferSrcDstStrings(t)
+			links = append(links, PlannedHardlink{Destination: dst, Target: *target})
+		}
+	}
+	return links, overwrite
+}
+
+// ExportedJobPlan is everything a job's plan files record about it, for people and other tools to read
+type ExportedJobPlan struct {
+	JobID           common.JobID
+	CommandString   string
+	FromTo          string
+	JobStatus       string
+	SourceRoot      string
+	DestinationRoot string
+	Parts           uint32
+	Transfers       []ExportedTransfer
+}
+
+// ExportedTransfer is a transfer of an ExportedJobPlan. Its source and destination leave out the SAS, and any other
+// query, that the job was given.
+type ExportedTransfer struct {
+	PartNumber       uint32
+	TransferIndex    uint32
+	Source           string
+	Destination      string
+	EntityType       string
+	Status           string
+	ErrorCode        int32
+	SourceSize       int64
+	LastModifiedTime time.Time `json:",omitzero"`
+	SavedOffset      int64     // how much of a download was kept when the job was last shut down, to carry on from
+	BlockSize        int64     // the block size that AzCopy chose for the transfer, or 0 if it was given or not chosen yet
+}
+
+// ExportJobPlan reads the plan files of the given job, resurrecting it if needs be, and returns all of its transfers
+func ExportJobPlan(jobID common.JobID) (ExportedJobPlan, error) {
+	jm, found := JobsAdmin.JobMgr(jobID)
+	if !found {
+		if !JobsAdmin.ResurrectJob(jobID, nil, nil, false) {
+			return ExportedJobPlan{}, fmt.Errorf("no job with JobId %v exists", jobID)
+		}
+		jm, _ = JobsAdmin.JobMgr(jobID)
+	}
+
+	export := ExportedJobPlan{JobID: jobID, Transfers: []ExportedTransfer{}}
+	for partNum := ste.PartNumber(0); true; partNum++ {
+		jpm, found := jm.JobPartMgr(partNum)
+		if !found {
+			break
+		}
+		jpp := jpm.Plan()
+		srcRoot := string(jpp.SourceRoot[:jpp.SourceRootLength])
+		dstRoot := string(jpp.DestinationRoot[:jpp.DestinationRootLength])
+		if partNum == 0 {
+			export.CommandString = jpp.CommandString()
+			export.FromTo = jpp.FromTo.String()
+			export.JobStatus = jpp.JobStatus().String()
+			export.SourceRoot, export.DestinationRoot = srcRoot, dstRoot
+		}
+		export.Parts++
+
+		for t := uint32(0); t < jpp.NumTransfers; t++ {
+			transferEntry := jpp.Transfer(t)
+			srcRelative, dstRelative := jpp.TransferSrcDstRelatives(t)
+			exported := ExportedTransfer{
+				PartNumber:    uint32(partNum),
+				TransferIndex: t,
+				Source:        common.GenerateFullPath(srcRoot, srcRelative),
+				Destination:   common.GenerateFullPath(dstRoot, dstRelative),
+				EntityType:    transferEntry.EntityType.String(),
+				Status:        transferEntry.TransferStatus().String(),
+				ErrorCode:     transferEntry.ErrorCode(),
+				SourceSize:    transferEntry.SourceSize,
+				SavedOffset:   transferEntry.SavedOffset(),
+				BlockSize:     transferEntry.BlockSize(),
+			}
+			if transferEntry.ModifiedTime != 0 {
+				exported.LastModifiedTime = time.Unix(0, transferEntry.ModifiedTime).UTC()
+			}
+			export.Transfers = append(export.Transfers, exported)
+		}
+	}
+	return export, nil
+}
+
 func GetJobLCMWrapper(jobID common.JobID) common.LifecycleMgr {
 	jobmgr, found := JobsAdmin.JobMgr(jobID)
 	lcm := common.GetLifecycleMgr()
@@ -0,0 +1,178 @@
+// Copyright © 2025 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jobsAdmin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// The job history is a file of JSON lines, a line for each finished job, in the plan folder. It is appended to, so
+// that jobs finishing at once in different processes don't lose each other's lines, and can be read by other tools,
+// such as jq, as well as by 'azcopy jobs history'. A database, such as SQLite or bolt, would need a dependency, cgo or
+// a lock that every AzCopy process takes, for what is a short list that's only ever added to and read whole. Once it
+// has more than AZCOPY_JOB_HISTORY_KEEP_LAST jobs, by a tenth, it's rewritten with only the newest of them.
+const jobHistoryFileName = "history.jsonl"
+
+// JobHistoryRecord is the summary of a finished job, as kept in the job history
+type JobHistoryRecord struct {
+	JobID              common.JobID
+	Command            string   // with any SAS redacted
+	Flags              []string // the names of the flags the job was run with
+	FromTo             string
+	Status             string
+	StartTime          time.Time
+	EndTime            time.Time
+	DurationSeconds    float64
+	TotalTransfers     uint32
+	TransfersCompleted uint32
+	TransfersFailed    uint32
+	TransfersSkipped   uint32
+	BytesTransferred   uint64
+	BytesExpected      uint64
+}
+
+func jobHistoryPath() string {
+	return filepath.Join(common.AzcopyJobPlanFolder, jobHistoryFileName)
+}
+
+// RecordJobHistory adds a finished job to the job history
+func RecordJobHistory(record JobHistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := common.SandboxOpenFile(jobHistoryPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_ = f.Chmod(0600) // as a history written by an earlier version may not be
+	// a single write, so that the line isn't interleaved with another process's
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if common.InSandbox() {
+		return nil // the history can't be replaced from the sandbox; it's pruned when the next job outside it finishes
+	}
+	return pruneJobHistory()
+}
+
+// jobHistoryKeepLast returns how many jobs the history keeps
+func jobHistoryKeepLast() (int, error) {
+	env := common.EEnvironmentVariable.JobHistoryKeepLast()
+	keep, err := strconv.Atoi(common.GetEnvironmentVariable(env))
+	if err != nil || keep < 1 {
+		return 0, errors.New(env.Name + " must be a number of jobs")
+	}
+	return keep, nil
+}
+
+// pruneJobHistory rewrites the job history with only the newest jobs, once it has more than it keeps by a tenth, so
+// that it isn't rewritten each time a job finishes. A job that finishes in another process while it's being rewritten
+// may be left out.
+func pruneJobHistory() error {
+	keep, err := jobHistoryKeepLast()
+	if err != nil {
+		return err
+	}
+	lines, err := readJobHistoryLines()
+	if err != nil || len(lines) <= keep+keep/10 {
+		return err
+	}
+
+	// the history is written under another name first, so that it's never found half written
+	tmp, err := os.CreateTemp(common.AzcopyJobPlanFolder, "history-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, line := range lines[len(lines)-keep:] {
+		_, _ = w.Write(line)
+		_ = w.WriteByte('\n')
+	}
+	err = w.Flush()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), jobHistoryPath())
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// readJobHistoryLines returns the lines of the job history that are jobs, oldest first. A line that can't be read,
+// such as one cut short by a full disk, is left out, however long it is.
+func readJobHistoryLines() ([][]byte, error) {
+	f, err := os.Open(jobHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); json.Valid(line) && bytes.HasPrefix(line, []byte("{")) {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadJobHistory returns the jobs in the job history, oldest first. A line that can't be read, such as one cut short
+// by a full disk, is left out.
+func ReadJobHistory() ([]JobHistoryRecord, error) {
+	lines, err := readJobHistoryLines()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]JobHistoryRecord, 0, len(lines))
+	for _, line := range lines {
+		var record JobHistoryRecord
+		if json.Unmarshal(line, &record) == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -148,3 +148,29 @@ func removeFilesWithPredicate(targetFolder string, predicate func(string) bool)
 
 	return count, nil
 }
+
+// JobFileSizes returns how much space the plan and log files of each job take up
+func JobFileSizes() map[common.JobID]int64 {
+	sizes := make(map[common.JobID]int64)
+	idLength := len(common.JobID{}.String())
+	for _, folder := range []string{common.AzcopyJobPlanFolder, common.LogPathFolder} {
+		files, err := os.ReadDir(folder)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			// the files of a job are named after it, e.g. [job ID]--00000.steV[version] and [job ID]-scanning.log
+			if f.IsDir() || len(f.Name()) < idLength {
+				continue
+			}
+			jobID, err := common.ParseJobID(f.Name()[:idLength])
+			if err != nil {
+				continue
+			}
+			if info, err := f.Info(); err == nil {
+				sizes[jobID] += info.Size()
+			}
+		}
+	}
+	return sizes
+}
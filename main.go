@@ -24,6 +24,8 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/cmd"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"os"
+
+	_ "github.com/Azure/azure-storage-azcopy/v10/webdav" // registers the webdav:// and webdavs:// providers
 )
 
 // get the lifecycle manager to print messages
@@ -1,6 +1,7 @@
 package ste
 
 import (
+	"encoding/binary"
 	"errors"
 	"sync/atomic"
 	"unsafe"
@@ -13,7 +14,7 @@ import (
 // dataSchemaVersion defines the data schema version of JobPart order files supported by
 // current version of azcopy
 // To be Incremented every time when we release azcopy with changed dataSchema
-const DataSchemaVersion common.Version = 19
+const DataSchemaVersion common.Version = 20
 
 const (
 	CustomHeaderMaxBytes = 256
@@ -31,7 +32,12 @@ func (mmf *JobPartPlanMMF) Plan() *JobPartPlanHeader {
 	slice := (*common.MMF)(mmf).Slice()
 	return (*JobPartPlanHeader)(unsafe.Pointer(&slice[0]))
 }
-func (mmf *JobPartPlanMMF) Unmap() { (*common.MMF)(mmf).Unmap() }
+func (mmf *JobPartPlanMMF) Flush() error { return (*common.MMF)(mmf).Flush() }
+
+func (mmf *JobPartPlanMMF) Unmap() {
+	common.AddPlanFileMemory(-int64(len((*common.MMF)(mmf).Slice())))
+	(*common.MMF)(mmf).Unmap()
+}
 
 // //////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
@@ -44,7 +50,7 @@ type IJobPartPlanHeader interface {
 	Transfer(transferIndex uint32) *JobPartPlanTransfer
 	TransferSrcDstRelatives(transferIndex uint32) (relSource string, relDest string)
 	TransferSrcDstStrings(transferIndex uint32) (source string, destination string, isFolder bool)
-	TransferSrcPropertiesAndMetadata(transferIndex uint32) (h common.ResourceHTTPHeaders, metadata common.Metadata, blobType blob.BlobType, blobTier blob.AccessTier, s2sGetPropertiesInBackend bool, DestLengthValidation bool, s2sSourceChangeValidation bool, s2sInvalidMetadataHandleOption common.InvalidMetadataHandleOption, entityType common.EntityType, blobVersionID string, blobSnapshotID string, blobTags common.BlobTags)
+	TransferSrcPropertiesAndMetadata(transferIndex uint32) (h common.ResourceHTTPHeaders, metadata common.Metadata, blobType blob.BlobType, blobTier blob.AccessTier, s2sGetPropertiesInBackend bool, DestLengthValidation bool, s2sSourceChangeValidation bool, s2sInvalidMetadataHandleOption common.InvalidMetadataHandleOption, entityType common.EntityType, blobVersionID string, blobSnapshotID string, blobTags common.BlobTags, blobEncryptionScope string)
 }
 
 // JobPartPlanHeader represents the header of Job Part's memory-mapped file
@@ -66,12 +72,20 @@ type JobPartPlanHeader struct {
 	ForceWrite             common.OverwriteOption      // True if the existing blobs needs to be overwritten.
 	ForceIfReadOnly        bool                        // Supplements ForceWrite with an additional setting for Azure Files. If true, the read-only attribute will be cleared before we overwrite
 	AutoDecompress         bool                        // if true, source data with encodings that represent compression are automatically decompressed when downloading
+	KeepCompressed         bool                        // if true, blobs that were compressed as they were uploaded aren't decompressed when downloading either
+	ExtractArchivePacks    bool                        // if true, archive packs are extracted as they are downloaded, instead of being saved
+	Untar                  bool                        // if true, tar archives (gzipped or not) are extracted as they are downloaded, instead of being saved
+	Unzip                  bool                        // if true, zip archives are extracted as they are downloaded, instead of being saved
+	UploadCompression      common.CompressionType      // files are compressed with this as they are uploaded, and the blobs' Content-Encoding says so
+	ClientSideKeyLength    uint16                      // The length of the client-side encryption key string
+	ClientSideKey          [1000]byte                  // the URL of a Key Vault key, or the path of a key file, that blobs are encrypted with on the client as they are uploaded, and decrypted with as they are downloaded
 	Priority               common.JobPriority          // The Job Part's priority
 	TTLAfterCompletion     uint32                      // Time to live after completion is used to persists the file on disk of specified time after the completion of JobPartOrder
 	FromTo                 common.FromTo               // The location of the transfer's source & destination
 	Fpo                    common.FolderPropertyOption // option specifying how folders will be handled
 	CommandStringLength    uint32
 	NumTransfers           uint32              // The number of transfers in the Job part
+	TransferIndexOffset    int64               // Where the index of the transfers' records starts (see JobPartPlanWriter.go)
 	LogLevel               common.LogLevel     // This Job Part's minimal log level
 	DstBlobData            JobPartPlanDstBlob  // Additional data for blob destinations
 	DstLocalData           JobPartPlanDstLocal // Additional data for local destinations
@@ -80,6 +94,10 @@ type JobPartPlanHeader struct {
 	PreservePermissions     common.PreservePermissionsOption
 	PreserveInfo            bool
 	PreservePOSIXProperties bool
+	PreserveACLs            bool
+	PreserveExtAttrs        bool
+	PreserveFileFlags       bool
+	RestoreOwner            bool
 	// S2SGetPropertiesInBackend represents whether to enable get S3 objects' or Azure files' properties during s2s copy in backend.
 	S2SGetPropertiesInBackend bool
 	// S2SSourceChangeValidation represents whether user wants to check if source has changed after enumerating.
@@ -90,6 +108,10 @@ type JobPartPlanHeader struct {
 	S2SInvalidMetadataHandleOption common.InvalidMetadataHandleOption
 	// BlobFSRecursiveDelete represents whether the user wants to make a recursive call to the DFS endpoint or not
 	BlobFSRecursiveDelete bool
+	// S2SPreserveEncryptionScope represents whether blobs are copied into the encryption scope of their source
+	S2SPreserveEncryptionScope bool
+	// ContentHashType is the hash that files are hashed with when uploaded, and checked with when downloaded
+	ContentHashType common.ContentHashType
 
 	// Any fields below this comment are NOT constants; they may change over as the job part is processed.
 	// Care must be taken to read/write to these fields in a thread-safe way!
@@ -134,30 +156,20 @@ func (jpph *JobPartPlanHeader) Transfer(transferIndex uint32) *JobPartPlanTransf
 		panic(errors.New("requesting a transfer index greater than what is available"))
 	}
 
-	// (Job Part Plan's file address) + (header size) + (padding to 8 bytes) --> beginning of transfers in file
-	// Add (transfer size) * (transfer index)
-	transfersOffset := unsafe.Sizeof(*jpph) + uintptr(jpph.CommandStringLength)
-	transfersOffset = (transfersOffset + 7) & ^uintptr(7)
-	return (*JobPartPlanTransfer)(unsafe.Pointer((uintptr(unsafe.Pointer(jpph)) + transfersOffset) + (unsafe.Sizeof(JobPartPlanTransfer{}) * uintptr(transferIndex))))
+	// the index, which follows the records, has the offset of each transfer's record, in units of 8 bytes
+	index := unsafe.Slice((*uint32)(unsafe.Add(unsafe.Pointer(jpph), jpph.TransferIndexOffset)), jpph.NumTransfers)
+	return (*JobPartPlanTransfer)(unsafe.Add(unsafe.Pointer(jpph), int64(index[transferIndex])*8))
 }
 
 // CommandString returns the command string given by user when job was created
 func (jpph *JobPartPlanHeader) CommandString() string {
-	// Calculate the start address of the command string
-	start := uintptr(unsafe.Pointer(jpph)) + unsafe.Sizeof(*jpph)
-
-	// Create a slice from the calculated start address
-	commandSlice := unsafe.Slice((*byte)(unsafe.Pointer(start)), int(jpph.CommandStringLength))
-	return string(commandSlice)
+	// the command string follows the header; it's copied, since it's kept after the plan is unmapped, as when jobs are listed
+	return string(unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(jpph), unsafe.Sizeof(*jpph))), int(jpph.CommandStringLength)))
 }
 
 func (jpph *JobPartPlanHeader) TransferSrcDstRelatives(transferIndex uint32) (relSource, relDest string) {
-	jppt := jpph.Transfer(transferIndex)
-
-	srcData := unsafe.Pointer(uintptr(unsafe.Pointer(jpph)) + uintptr(jppt.SrcOffset))                           // Address of Job Part Plan + this transfer's src string offset
-	dstData := unsafe.Pointer(uintptr(unsafe.Pointer(jpph)) + uintptr(jppt.SrcOffset) + uintptr(jppt.SrcLength)) // Address of Job Part Plan + this transfer's src string offset + length of this transfer's src string
-
-	return unsafe.String((*byte)(srcData), int(jppt.SrcLength)), unsafe.String((*byte)(dstData), int(jppt.DstLength))
+	strings := jpph.Transfer(transferIndex).strings()
+	return strings.srcDst()
 }
 
 // TransferSrcDstDetail returns the source and destination string for a transfer at given transferIndex in JobPartOrder
@@ -178,15 +190,10 @@ func (jpph *JobPartPlanHeader) TransferSrcDstStrings(transferIndex uint32) (sour
 		isFolder
 }
 
-func (jpph *JobPartPlanHeader) getString(offset int64, length int16) string {
-	data := unsafe.Pointer(uintptr(unsafe.Pointer(jpph)) + uintptr(offset)) // Address of Job Part Plan + this string's offset
-	return unsafe.String((*byte)(data), int(length))
-}
-
 // TransferSrcPropertiesAndMetadata returns the SrcHTTPHeaders, properties and metadata for a transfer at given transferIndex in JobPartOrder
 // TODO: Refactor return type to an object
 func (jpph *JobPartPlanHeader) TransferSrcPropertiesAndMetadata(transferIndex uint32) (h common.ResourceHTTPHeaders, metadata common.Metadata, blobType blob.BlobType, blobTier blob.AccessTier,
-	s2sGetPropertiesInBackend bool, DestLengthValidation bool, s2sSourceChangeValidation bool, s2sInvalidMetadataHandleOption common.InvalidMetadataHandleOption, entityType common.EntityType, blobVersionID string, blobSnapshotID string, blobTags common.BlobTags) {
+	s2sGetPropertiesInBackend bool, DestLengthValidation bool, s2sSourceChangeValidation bool, s2sInvalidMetadataHandleOption common.InvalidMetadataHandleOption, entityType common.EntityType, blobVersionID string, blobSnapshotID string, blobTags common.BlobTags, blobEncryptionScope string) {
 	var err error
 	t := jpph.Transfer(transferIndex)
 
@@ -195,63 +202,32 @@ func (jpph *JobPartPlanHeader) TransferSrcPropertiesAndMetadata(transferIndex ui
 	s2sInvalidMetadataHandleOption = jpph.S2SInvalidMetadataHandleOption
 	DestLengthValidation = jpph.DestLengthValidation
 
-	offset := t.SrcOffset + int64(t.SrcLength) + int64(t.DstLength)
-
 	entityType = t.EntityType
 
-	if t.SrcContentTypeLength != 0 {
-		h.ContentType = jpph.getString(offset, t.SrcContentTypeLength)
-		offset += int64(t.SrcContentTypeLength)
-	}
-	if t.SrcContentEncodingLength != 0 {
-		h.ContentEncoding = jpph.getString(offset, t.SrcContentEncodingLength)
-		offset += int64(t.SrcContentEncodingLength)
-	}
-	if t.SrcContentLanguageLength != 0 {
-		h.ContentLanguage = jpph.getString(offset, t.SrcContentLanguageLength)
-		offset += int64(t.SrcContentLanguageLength)
-	}
-	if t.SrcContentDispositionLength != 0 {
-		h.ContentDisposition = jpph.getString(offset, t.SrcContentDispositionLength)
-		offset += int64(t.SrcContentDispositionLength)
-	}
-	if t.SrcCacheControlLength != 0 {
-		h.CacheControl = jpph.getString(offset, t.SrcCacheControlLength)
-		offset += int64(t.SrcCacheControlLength)
-	}
-	if t.SrcContentMD5Length != 0 {
-		h.ContentMD5 = []byte(jpph.getString(offset, t.SrcContentMD5Length))
-		offset += int64(t.SrcContentMD5Length)
+	strings := t.strings()
+	strings.srcDst()
+	p := strings.properties()
+
+	h.ContentType = p[planContentType]
+	h.ContentEncoding = p[planContentEncoding]
+	h.ContentLanguage = p[planContentLanguage]
+	h.ContentDisposition = p[planContentDisposition]
+	h.CacheControl = p[planCacheControl]
+	if p[planContentMD5] != "" {
+		h.ContentMD5 = []byte(p[planContentMD5])
 	}
-	if t.SrcMetadataLength != 0 {
-		tmpMetaData := jpph.getString(offset, t.SrcMetadataLength)
-		metadata, err = common.UnMarshalToCommonMetadata(tmpMetaData)
+	if p[planMetadata] != "" {
+		metadata, err = common.UnMarshalToCommonMetadata(p[planMetadata])
 		common.PanicIfErr(err)
-		offset += int64(t.SrcMetadataLength)
 	}
-	if t.SrcBlobTypeLength != 0 {
-		tmpBlobTypeStr := []byte(jpph.getString(offset, t.SrcBlobTypeLength))
-		blobType = blob.BlobType(tmpBlobTypeStr)
-		offset += int64(t.SrcBlobTypeLength)
-	}
-	if t.SrcBlobTierLength != 0 {
-		tmpBlobTierStr := []byte(jpph.getString(offset, t.SrcBlobTierLength))
-		blobTier = blob.AccessTier(tmpBlobTierStr)
-		offset += int64(t.SrcBlobTierLength)
-	}
-	if t.SrcBlobVersionIDLength != 0 {
-		blobVersionID = jpph.getString(offset, t.SrcBlobVersionIDLength)
-		offset += int64(t.SrcBlobVersionIDLength)
-	}
-	if t.SrcBlobSnapshotIDLength != 0 {
-		blobSnapshotID = jpph.getString(offset, t.SrcBlobSnapshotIDLength)
-		offset += int64(t.SrcBlobSnapshotIDLength)
-	}
-	if t.SrcBlobTagsLength != 0 {
-		blobTagsString := jpph.getString(offset, t.SrcBlobTagsLength)
-		blobTags = common.ToCommonBlobTagsMap(blobTagsString)
-		offset += int64(t.SrcBlobTagsLength) //nolint:ineffassign
+	blobType = blob.BlobType(p[planBlobType])
+	blobTier = blob.AccessTier(p[planBlobTier])
+	blobVersionID = p[planBlobVersionID]
+	blobSnapshotID = p[planBlobSnapshotID]
+	if p[planBlobTags] != "" {
+		blobTags = common.ToCommonBlobTagsMap(p[planBlobTags])
 	}
+	blobEncryptionScope = p[planBlobEncryptionScope]
 	return
 }
 
@@ -322,6 +298,11 @@ type JobPartPlanDstBlob struct {
 	SetPropertiesFlags common.SetPropertiesFlags
 
 	DeleteDestinationFileIfNecessary bool
+
+	// Uploads to block blobs name blocks by their content, and send only those the destination blob doesn't have already
+	BlockDelta bool
+	// and split files into blocks where their content says, instead of every BlockSize bytes
+	ContentDefinedBlocks bool
 }
 
 // JobPartPlanDstFile holds additional settings required when the destination is a file
@@ -340,46 +321,27 @@ type JobPartPlanDstLocal struct {
 
 	// says how MD5 verification failures should be actioned
 	MD5VerificationOption common.HashValidationOption
+
+	// says when downloaded files are synced to disk
+	Fsync common.FsyncPolicy
 }
 
 // //////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// JobPartPlanTransfer represent the header of Job Part's Transfer in Memory Map File
+// JobPartPlanTransfer represent the header of Job Part's Transfer in Memory Map File. It's the fixed part of the
+// transfer's record, which the transfer's strings follow (see JobPartPlanWriter.go).
 type JobPartPlanTransfer struct {
 	// Once set, the following fields are constants; they should never be modified
 
-	// SrcOffset represents the actual start offset transfer header written in JobPartOrder file
-	SrcOffset int64
-	// SrcLength represents the actual length of source string for specific transfer
-	SrcLength int16
-	// DstLength represents the actual length of destination string for specific transfer
-	DstLength int16
-	// ChunkCount represents the num of chunks a transfer is split into
-	// ChunkCount uint16	// TODO: Remove this, we need to determine it at runtime
-	// EntityType indicates whether this is a file or a folder
-	// We use a dedicated field for this because the alternative (of doing something fancy the names) was too complex and error-prone
-	EntityType common.EntityType
 	// ModifiedTime represents the last time at which source was modified before start of transfer stored as nanoseconds.
 	ModifiedTime int64
 	// SourceSize represents the actual size of the source on disk
 	SourceSize int64
-	// CompletionTime represents the time at which transfer was completed
-	CompletionTime uint64
-
-	// For S2S copy, per Transfer source's properties
-	// TODO: ensure the length is enough
-	SrcContentTypeLength        int16
-	SrcContentEncodingLength    int16
-	SrcContentLanguageLength    int16
-	SrcContentDispositionLength int16
-	SrcCacheControlLength       int16
-	SrcContentMD5Length         int16
-	SrcMetadataLength           int16
-	SrcBlobTypeLength           int16
-	SrcBlobTierLength           int16
-	SrcBlobVersionIDLength      int16
-	SrcBlobSnapshotIDLength     int16
-	SrcBlobTagsLength           int16
+	// stringsLength is how many bytes of strings follow this in the plan file
+	stringsLength uint32
+	// EntityType indicates whether this is a file or a folder
+	// We use a dedicated field for this because the alternative (of doing something fancy the names) was too complex and error-prone
+	EntityType common.EntityType
 
 	// Any fields below this comment are NOT constants; they may change over as the transfer is processed.
 	// Care must be taken to read/write to these fields in a thread-safe way!
@@ -392,6 +354,66 @@ type JobPartPlanTransfer struct {
 	// atomicErrorCode has a default value (0) which means either there was no error or transfer failed because some non storageError.
 	// atomicErrorCode should not be directly accessed anywhere except by transferStatus and setTransferStatus
 	atomicErrorCode int32
+
+	// atomicSavedOffset is how many bytes at the start of a download are known to be saved, so that resuming the job
+	// can carry on from there rather than downloading them again. It is recorded as the download goes, so that it
+	// survives AzCopy being killed, and when the job is shut down cleanly. It is 0 at all other times.
+	atomicSavedOffset int64
+
+	// atomicBlockSize is the block size that the transfer was started with, when AzCopy chose it, so that resuming
+	// the job carries on with the same one. It is 0 until the transfer is first started.
+	atomicBlockSize int64
+}
+
+// strings returns the transfer's strings, which follow it in the plan file
+func (jppt *JobPartPlanTransfer) strings() planStrings {
+	return unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(jppt), unsafe.Sizeof(*jppt))), jppt.stringsLength)
+}
+
+// planStrings reads the strings of a transfer's record, in the order they're written (see JobPartPlanWriter.go). The
+// strings it returns are those of the plan's memory map, rather than copies.
+type planStrings []byte
+
+var errCorruptPlanStrings = errors.New("a transfer's strings in the job part plan are corrupt")
+
+func (s *planStrings) uvarint() uint64 {
+	v, n := binary.Uvarint(*s)
+	if n <= 0 {
+		panic(errCorruptPlanStrings)
+	}
+	*s = (*s)[n:]
+	return v
+}
+
+func (s *planStrings) string(length uint64) string {
+	if length > uint64(len(*s)) {
+		panic(errCorruptPlanStrings)
+	}
+	str := unsafe.String(unsafe.SliceData(*s), int(length))
+	*s = (*s)[length:]
+	return str
+}
+
+// srcDst reads the source and the destination, which is only stored if it isn't the same as the source
+func (s *planStrings) srcDst() (src, dst string) {
+	src = s.string(s.uvarint())
+	if n := s.uvarint(); n == 0 {
+		dst = src
+	} else {
+		dst = s.string(n - 1)
+	}
+	return src, dst
+}
+
+// properties reads the properties that follow the source and destination, those the transfer doesn't have being ""
+func (s *planStrings) properties() (p [numPlanProperties]string) {
+	present := s.uvarint()
+	for i := range p {
+		if present&(1<<i) != 0 {
+			p[i] = s.string(s.uvarint())
+		}
+	}
+	return p
 }
 
 // TransferStatus returns the transfer's status
@@ -435,3 +457,23 @@ func (jppt *JobPartPlanTransfer) SetErrorCode(errorCode int32, overwrite bool) {
 		atomic.StoreInt32(&jppt.atomicErrorCode, errorCode)
 	}
 }
+
+// SavedOffset returns how much of the transfer's destination file is known to be saved
+func (jppt *JobPartPlanTransfer) SavedOffset() int64 {
+	return atomic.LoadInt64(&jppt.atomicSavedOffset)
+}
+
+// SetSavedOffset records how much of the transfer's destination file has been saved
+func (jppt *JobPartPlanTransfer) SetSavedOffset(offset int64) {
+	atomic.StoreInt64(&jppt.atomicSavedOffset, offset)
+}
+
+// BlockSize returns the block size that was chosen for the transfer, or 0 if none has been
+func (jppt *JobPartPlanTransfer) BlockSize() int64 {
+	return atomic.LoadInt64(&jppt.atomicBlockSize)
+}
+
+// SetBlockSize records the block size that was chosen for the transfer
+func (jppt *JobPartPlanTransfer) SetBlockSize(blockSize int64) {
+	atomic.StoreInt64(&jppt.atomicBlockSize, blockSize)
+}
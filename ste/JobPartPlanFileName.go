@@ -1,16 +1,13 @@
 package ste
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"math"
+	"io/fs"
 	"os"
-	"reflect"
+	"path/filepath"
 	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
@@ -60,6 +57,42 @@ func (jpfn JobPartPlanFileName) Parse() (jobID common.JobID, partNumber common.P
 	return
 }
 
+// migratableDataSchemaVersion is that of the plans of the last release of AzCopy whose plans had another layout
+const migratableDataSchemaVersion common.Version = 19
+
+// MigratePlanFiles converts the plan files of jobs planned by an earlier release of AzCopy, whose plans are of version
+// migratableDataSchemaVersion, to those of the current version, so that the jobs are listed, resumed and cleaned up like
+// any other. Plans of versions before that are left alone, and their jobs can't be resumed by this version.
+func MigratePlanFiles() error {
+	folder := common.AzcopyJobPlanFolder
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	old := fmt.Sprintf(".steV%d", migratableDataSchemaVersion)
+	current := fmt.Sprintf(".steV%d", DataSchemaVersion)
+	var errs []error
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, old) {
+			continue
+		}
+		from, to := filepath.Join(folder, name), filepath.Join(folder, strings.TrimSuffix(name, old)+current)
+		if _, err = os.Stat(to); err == nil {
+			// another AzCopy has converted it, and may be running its job already
+			_ = os.Remove(from)
+			continue
+		}
+		if err = migrateV19PlanFile(from, to); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (jpfn JobPartPlanFileName) Delete() error {
 	return os.Remove(string(jpfn))
 }
@@ -75,6 +108,7 @@ func (jpfn JobPartPlanFileName) Map() *JobPartPlanMMF {
 	common.PanicIfErr(err)
 	mmf, err := common.NewMMF(file, true, 0, fileInfo.Size())
 	common.PanicIfErr(err)
+	common.AddPlanFileMemory(fileInfo.Size())
 	return (*JobPartPlanMMF)(mmf)
 }
 
@@ -97,6 +131,9 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	if len(order.DestinationRoot.ExtraQuery) > len(JobPartPlanHeader{}.DestExtraQuery) {
 		panic(fmt.Errorf("destination extra query strings too large: %q", order.DestinationRoot.ExtraQuery))
 	}
+	if len(order.ClientSideKey) > len(JobPartPlanHeader{}.ClientSideKey) {
+		panic(fmt.Errorf("client-side encryption key string is too large: %q", order.ClientSideKey))
+	}
 	if len(order.BlobAttributes.ContentType) > len(JobPartPlanDstBlob{}.ContentType) {
 		panic(fmt.Errorf("content type string is too large: %q", order.BlobAttributes.ContentType))
 	}
@@ -119,17 +156,6 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		panic(fmt.Errorf("blob tags string is too large: %q", order.BlobAttributes.BlobTagsString))
 	}
 
-	// This nested function writes a structure value to an io.Writer & returns the number of bytes written
-	writeValue := func(writer io.Writer, v interface{}) int64 {
-		rv := reflect.ValueOf(v)
-		structSize := reflect.TypeOf(v).Elem().Size()
-		byteSlice := unsafe.Slice((*byte)(rv.UnsafePointer()), int(structSize))
-		err := binary.Write(writer, binary.LittleEndian, byteSlice)
-		common.PanicIfErr(err)
-		return int64(structSize)
-	}
-
-	eof := int64(0)
 	/*
 	*       Following Steps are executed:
 	*		1. Get File Name from JobId and Part Number
@@ -182,12 +208,16 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		ForceWrite:             order.ForceWrite,
 		ForceIfReadOnly:        order.ForceIfReadOnly,
 		AutoDecompress:         order.AutoDecompress,
+		KeepCompressed:         order.KeepCompressed,
+		ExtractArchivePacks:    order.ExtractArchivePacks,
+		Untar:                  order.Untar,
+		Unzip:                  order.Unzip,
+		UploadCompression:      order.UploadCompression,
+		ClientSideKeyLength:    uint16(len(order.ClientSideKey)),
 		Priority:               order.Priority,
 		TTLAfterCompletion:     uint32(time.Time{}.Nanosecond()),
 		FromTo:                 order.FromTo,
 		Fpo:                    order.Fpo,
-		CommandStringLength:    uint32(len(order.CommandString)),
-		NumTransfers:           uint32(len(order.Transfers.List)),
 		LogLevel:               order.LogLevel,
 		DstBlobData: JobPartPlanDstBlob{
 			BlobType:                         order.BlobAttributes.BlobType,
@@ -209,20 +239,29 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 			IsSourceEncrypted:                order.CpkOptions.IsSourceEncrypted,
 			SetPropertiesFlags:               order.SetPropertiesFlags,
 			DeleteDestinationFileIfNecessary: order.BlobAttributes.DeleteDestinationFileIfNecessary,
+			BlockDelta:                       order.BlobAttributes.BlockDelta,
+			ContentDefinedBlocks:             order.BlobAttributes.ContentDefinedBlocks,
 		},
 		DstLocalData: JobPartPlanDstLocal{
 			PreserveLastModifiedTime: order.BlobAttributes.PreserveLastModifiedTime,
 			MD5VerificationOption:    order.BlobAttributes.MD5ValidationOption, // here because it relates to downloads (file destination)
+			Fsync:                    order.BlobAttributes.Fsync,
 		},
 		PreservePermissions:     order.PreservePermissions,
 		PreserveInfo:            order.PreserveInfo,
 		PreservePOSIXProperties: order.PreservePOSIXProperties,
+		PreserveACLs:            order.PreserveACLs,
+		PreserveExtAttrs:        order.PreserveExtAttrs,
+		PreserveFileFlags:       order.PreserveFileFlags,
+		RestoreOwner:            order.RestoreOwner,
 		// For S2S copy, per JobPartPlan info
 		S2SGetPropertiesInBackend:      order.S2SGetPropertiesInBackend,
 		S2SSourceChangeValidation:      order.S2SSourceChangeValidation,
 		S2SInvalidMetadataHandleOption: order.S2SInvalidMetadataHandleOption,
 		DestLengthValidation:           order.DestLengthValidation,
 		BlobFSRecursiveDelete:          order.BlobFSRecursiveDelete,
+		S2SPreserveEncryptionScope:     order.S2SPreserveEncryptionScope,
+		ContentHashType:                order.BlobAttributes.ContentHashType,
 		atomicJobStatus:                common.EJobStatus.InProgress(), // We default to InProgress
 		DeleteSnapshotsOption:          order.BlobAttributes.DeleteSnapshotsOption,
 		PermanentDeleteOption:          order.BlobAttributes.PermanentDeleteOption,
@@ -238,6 +277,7 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	copy(jpph.SourceExtraQuery[:], order.SourceRoot.ExtraQuery)
 	copy(jpph.DestinationRoot[:], order.DestinationRoot.Value)
 	copy(jpph.DestExtraQuery[:], order.DestinationRoot.ExtraQuery)
+	copy(jpph.ClientSideKey[:], order.ClientSideKey)
 	copy(jpph.DstBlobData.ContentType[:], order.BlobAttributes.ContentType)
 	copy(jpph.DstBlobData.ContentEncoding[:], order.BlobAttributes.ContentEncoding)
 	copy(jpph.DstBlobData.ContentLanguage[:], order.BlobAttributes.ContentLanguage)
@@ -247,180 +287,13 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	copy(jpph.DstBlobData.BlobTags[:], order.BlobAttributes.BlobTagsString)
 	copy(jpph.DstBlobData.CpkScopeInfo[:], order.CpkOptions.CpkScopeInfo)
 
-	eof += writeValue(file, &jpph)
-
-	// write the command string in the JobPart Plan file
-	bytesWritten, err := file.WriteString(order.CommandString)
-	if err != nil {
-		panic(err)
-	}
-	eof += int64(bytesWritten)
-
-	// ensure 8 byte alignment so that Atomic fields of JobPartPlanTransfer can actually be accessed atomically
-	paddingLen := ((eof + 7) & ^7) - eof
-	if paddingLen != 0 {
-		bytesWritten, err := file.Write(make([]byte, paddingLen))
-		if err != nil {
-			panic(err)
-		}
-		eof += int64(bytesWritten)
-	}
-
-	// srcDstStringsOffset points to after the header & all the transfers; this is where the src/dst strings go for each transfer
-	srcDstStringsOffset := make([]int64, jpph.NumTransfers)
-
-	// Initialize the offset for the 1st transfer's src/dst strings
-	currentSrcStringOffset := eof + int64(unsafe.Sizeof(JobPartPlanTransfer{}))*int64(jpph.NumTransfers)
-
-	// Write each transfer to the Job Part Plan file (except for the src/dst strings; comes come later)
+	// write the header and the command string, then each transfer's record, and lastly their index
+	w := newJobPartPlanWriter(file, jpph, order.CommandString)
 	for t := range order.Transfers.List {
-		if len(order.Transfers.List[t].Source) > math.MaxInt16 || len(order.Transfers.List[t].Destination) > math.MaxInt16 {
-			panic(fmt.Sprintf("The file %s exceeds azcopy's current maximum path length on either the source or the destination.", order.Transfers.List[t].Source))
-		}
-
-		// Prepare info for JobPartPlanTransfer
-		// Sending Metadata type to Transfer could ensure strong type validation.
-		// TODO: discuss the performance drop of marshaling metadata twice
-		srcMetadataLength := 0
-		if order.Transfers.List[t].Metadata != nil {
-			metadataStr, err := order.Transfers.List[t].Metadata.Marshal()
-			if err != nil {
-				panic(err)
-			}
-			srcMetadataLength = len(metadataStr)
-		}
-		if srcMetadataLength > math.MaxInt16 {
-			panic(fmt.Sprintf("The metadata on source file %s exceeds azcopy's current maximum metadata length, and cannot be processed.", order.Transfers.List[t].Source))
-		}
-
-		srcBlobTagsLength := 0
-		if order.Transfers.List[t].BlobTags != nil {
-			blobTagsStr := order.Transfers.List[t].BlobTags.ToString()
-			srcBlobTagsLength = len(blobTagsStr)
-		}
-		if srcBlobTagsLength > math.MaxInt16 {
-			panic(fmt.Sprintf("The length of tags %s exceeds maximum allowed length, and cannot be processed.", order.Transfers.List[t].BlobTags))
-		}
-		// Create & initialize this transfer's Job Part Plan Transfer
-		jppt := JobPartPlanTransfer{
-			SrcOffset:      currentSrcStringOffset, // SrcOffset of the src string
-			SrcLength:      int16(len(order.Transfers.List[t].Source)),
-			DstLength:      int16(len(order.Transfers.List[t].Destination)),
-			EntityType:     order.Transfers.List[t].EntityType,
-			ModifiedTime:   order.Transfers.List[t].LastModifiedTime.UnixNano(),
-			SourceSize:     order.Transfers.List[t].SourceSize,
-			CompletionTime: 0,
-			// For S2S copy, per Transfer source's properties
-			SrcContentTypeLength:        int16(len(order.Transfers.List[t].ContentType)),
-			SrcContentEncodingLength:    int16(len(order.Transfers.List[t].ContentEncoding)),
-			SrcContentLanguageLength:    int16(len(order.Transfers.List[t].ContentLanguage)),
-			SrcContentDispositionLength: int16(len(order.Transfers.List[t].ContentDisposition)),
-			SrcCacheControlLength:       int16(len(order.Transfers.List[t].CacheControl)),
-			SrcContentMD5Length:         int16(len(order.Transfers.List[t].ContentMD5)),
-			SrcMetadataLength:           int16(srcMetadataLength),
-			SrcBlobTypeLength:           int16(len(order.Transfers.List[t].BlobType)),
-			SrcBlobTierLength:           int16(len(order.Transfers.List[t].BlobTier)),
-			SrcBlobVersionIDLength:      int16(len(order.Transfers.List[t].BlobVersionID)),
-			SrcBlobSnapshotIDLength:     int16(len(order.Transfers.List[t].BlobSnapshotID)),
-			SrcBlobTagsLength:           int16(srcBlobTagsLength),
-
-			atomicTransferStatus: common.ETransferStatus.Started(), // Default
-			// ChunkNum:                getNumChunks(uint64(order.Transfers.List[t].SourceSize), uint64(data.BlockSize)),
-		}
-		eof += writeValue(file, &jppt) // Write the transfer entry
-
-		// The NEXT transfer's src/dst string come after THIS transfer's src/dst strings
-		srcDstStringsOffset[t] = currentSrcStringOffset
-
-		currentSrcStringOffset += int64(jppt.SrcLength + jppt.DstLength + jppt.SrcContentTypeLength +
-			jppt.SrcContentEncodingLength + jppt.SrcContentLanguageLength + jppt.SrcContentDispositionLength +
-			jppt.SrcCacheControlLength + jppt.SrcContentMD5Length + jppt.SrcMetadataLength +
-			jppt.SrcBlobTypeLength + jppt.SrcBlobTierLength + jppt.SrcBlobVersionIDLength + jppt.SrcBlobSnapshotIDLength + jppt.SrcBlobTagsLength)
-	}
-
-	// All the transfers were written; now write each transfer's src/dst strings
-	for t := range order.Transfers.List {
-		// Sanity check: Verify that we are were we think we are and that no bug has occurred
-		if eof != srcDstStringsOffset[t] {
-			panic(errors.New("job plan file's EOF and the transfer's offset didn't line up; filename: " + order.Transfers.List[t].Source))
-		}
-
-		// Write the src & dst strings to the job part plan file
-		bytesWritten, err := file.WriteString(order.Transfers.List[t].Source)
-		common.PanicIfErr(err)
-		eof += int64(bytesWritten)
-		// write the destination string in memory map file
-		bytesWritten, err = file.WriteString(order.Transfers.List[t].Destination)
+		r, err := newJobPartPlanRecord(&order.Transfers.List[t])
 		common.PanicIfErr(err)
-		eof += int64(bytesWritten)
-
-		// For S2S copy (and, in the case of Content-MD5, always), write the src properties
-		if len(order.Transfers.List[t].ContentType) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].ContentType)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].ContentEncoding) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].ContentEncoding)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].ContentLanguage) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].ContentLanguage)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].ContentDisposition) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].ContentDisposition)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].CacheControl) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].CacheControl)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if order.Transfers.List[t].ContentMD5 != nil { // if non-nil but 0 len, will simply not be read by the consumer (since length is zero)
-			bytesWritten, err = file.WriteString(string(order.Transfers.List[t].ContentMD5))
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		// For S2S copy, write the src metadata
-		if order.Transfers.List[t].Metadata != nil {
-			metadataStr, err := order.Transfers.List[t].Metadata.Marshal()
-			common.PanicIfErr(err)
-
-			bytesWritten, err = file.WriteString(metadataStr)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].BlobType) != 0 {
-			bytesWritten, err = file.WriteString(string(order.Transfers.List[t].BlobType))
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].BlobTier) != 0 {
-			bytesWritten, err = file.WriteString(string(order.Transfers.List[t].BlobTier))
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].BlobVersionID) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].BlobVersionID)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		if len(order.Transfers.List[t].BlobSnapshotID) != 0 {
-			bytesWritten, err = file.WriteString(order.Transfers.List[t].BlobSnapshotID)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
-		// For S2S copy, write the source tags in job part plan transfer
-		if len(order.Transfers.List[t].BlobTags) != 0 {
-			blobTagsStr := order.Transfers.List[t].BlobTags.ToString()
-			bytesWritten, err = file.WriteString(blobTagsStr)
-			common.PanicIfErr(err)
-			eof += int64(bytesWritten)
-		}
+		common.PanicIfErr(w.add(&r))
 	}
+	common.PanicIfErr(w.finish())
 	// the file is closed to due to defer above
 }
@@ -0,0 +1,247 @@
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"unsafe"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// The layout of the plans of data schema version 19, which earlier releases of AzCopy 10 wrote. Each type is a copy of
+// the one in JobPartPlan.go that it's named after, as it was then; see there for what their fields mean. The header's
+// fields have only been added to since, never renamed or removed, so that its header is converted field by field. Its
+// transfers, whose layout has changed, are read by readV19PlanRecord.
+
+type jobPartPlanHeaderV19 struct {
+	Version                        common.Version
+	StartTime                      int64
+	JobID                          common.JobID
+	PartNum                        common.PartNumber
+	SourceRootLength               uint16
+	SourceRoot                     [1000]byte
+	SourceExtraQueryLength         uint16
+	SourceExtraQuery               [1000]byte
+	DestinationRootLength          uint16
+	DestinationRoot                [1000]byte
+	DestExtraQueryLength           uint16
+	DestExtraQuery                 [1000]byte
+	IsFinalPart                    bool
+	ForceWrite                     common.OverwriteOption
+	ForceIfReadOnly                bool
+	AutoDecompress                 bool
+	Priority                       common.JobPriority
+	TTLAfterCompletion             uint32
+	FromTo                         common.FromTo
+	Fpo                            common.FolderPropertyOption
+	CommandStringLength            uint32
+	NumTransfers                   uint32
+	LogLevel                       common.LogLevel
+	DstBlobData                    jobPartPlanDstBlobV19
+	DstLocalData                   jobPartPlanDstLocalV19
+	DstFileData                    JobPartPlanDstFile
+	PreservePermissions            common.PreservePermissionsOption
+	PreserveInfo                   bool
+	PreservePOSIXProperties        bool
+	S2SGetPropertiesInBackend      bool
+	S2SSourceChangeValidation      bool
+	DestLengthValidation           bool
+	S2SInvalidMetadataHandleOption common.InvalidMetadataHandleOption
+	BlobFSRecursiveDelete          bool
+	atomicJobStatus                common.JobStatus
+	atomicPartStatus               common.JobStatus
+	DeleteSnapshotsOption          common.DeleteSnapshotsOption
+	PermanentDeleteOption          common.PermanentDeleteOption
+	RehydratePriority              common.RehydratePriorityType
+}
+
+type jobPartPlanDstBlobV19 struct {
+	BlobType                         common.BlobType
+	NoGuessMimeType                  bool
+	ContentTypeLength                uint16
+	ContentType                      [CustomHeaderMaxBytes]byte
+	ContentEncodingLength            uint16
+	ContentEncoding                  [CustomHeaderMaxBytes]byte
+	ContentLanguageLength            uint16
+	ContentLanguage                  [CustomHeaderMaxBytes]byte
+	ContentDispositionLength         uint16
+	ContentDisposition               [CustomHeaderMaxBytes]byte
+	CacheControlLength               uint16
+	CacheControl                     [CustomHeaderMaxBytes]byte
+	BlockBlobTier                    common.BlockBlobTier
+	PageBlobTier                     common.PageBlobTier
+	PutMd5                           bool
+	MetadataLength                   uint16
+	Metadata                         [MetadataMaxBytes]byte
+	BlobTagsLength                   uint16
+	BlobTags                         [BlobTagsMaxByte]byte
+	CpkInfo                          bool
+	IsSourceEncrypted                bool
+	CpkScopeInfo                     [CustomHeaderMaxBytes]byte
+	CpkScopeInfoLength               uint16
+	BlockSize                        int64
+	PutBlobSize                      int64
+	SetPropertiesFlags               common.SetPropertiesFlags
+	DeleteDestinationFileIfNecessary bool
+}
+
+type jobPartPlanDstLocalV19 struct {
+	PreserveLastModifiedTime bool
+	MD5VerificationOption    common.HashValidationOption
+}
+
+type jobPartPlanTransferV19 struct {
+	SrcOffset                   int64
+	SrcLength                   int16
+	DstLength                   int16
+	EntityType                  common.EntityType
+	ModifiedTime                int64
+	SourceSize                  int64
+	CompletionTime              uint64
+	SrcContentTypeLength        int16
+	SrcContentEncodingLength    int16
+	SrcContentLanguageLength    int16
+	SrcContentDispositionLength int16
+	SrcCacheControlLength       int16
+	SrcContentMD5Length         int16
+	SrcMetadataLength           int16
+	SrcBlobTypeLength           int16
+	SrcBlobTierLength           int16
+	SrcBlobVersionIDLength      int16
+	SrcBlobSnapshotIDLength     int16
+	SrcBlobTagsLength           int16
+	atomicTransferStatus        common.TransferStatus
+	atomicErrorCode             int32
+}
+
+// migrateV19PlanFile writes the plan of version 19 at from as a plan of the current version at to. A plan of version 19
+// is the header, the command string, padding to 8 bytes, a fixed size entry for each transfer, and then the strings of
+// each transfer, one after another, at the offset and with the lengths that its entry has. Each transfer is read from
+// its entry and strings, and written through a jobPartPlanWriter, as a new plan's would be. The settings that version
+// 19 didn't have are left as their zero values, which are what a job planned without them has.
+func migrateV19PlanFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	var oldHeader jobPartPlanHeaderV19
+	oldHeaderSize := int64(unsafe.Sizeof(oldHeader))
+	if int64(len(data)) < oldHeaderSize {
+		return fmt.Errorf("%s is too short to be a job plan", filepath.Base(from))
+	}
+	copy(structBytes(&oldHeader), data)
+	oldTransfersOffset := (oldHeaderSize + int64(oldHeader.CommandStringLength) + 7) &^ 7
+	oldStringsOffset := oldTransfersOffset + int64(unsafe.Sizeof(jobPartPlanTransferV19{}))*int64(oldHeader.NumTransfers)
+	if int64(len(data)) < oldStringsOffset {
+		return fmt.Errorf("%s is too short for the %d transfers it says it has", filepath.Base(from), oldHeader.NumTransfers)
+	}
+
+	var header JobPartPlanHeader
+	copyPlanFields(reflect.ValueOf(&header).Elem(), reflect.ValueOf(&oldHeader).Elem())
+	header.Version = DataSchemaVersion
+	commandString := string(data[oldHeaderSize : oldHeaderSize+int64(oldHeader.CommandStringLength)])
+
+	// the plan is written under another name first, so that it's never found half written
+	tmp, err := os.CreateTemp(filepath.Dir(to), "migrating-*")
+	if err != nil {
+		return err
+	}
+	w := newJobPartPlanWriter(tmp, header, commandString)
+	for t := int64(0); t < int64(oldHeader.NumTransfers) && err == nil; t++ {
+		var oldTransfer jobPartPlanTransferV19
+		copy(structBytes(&oldTransfer), data[oldTransfersOffset+t*int64(unsafe.Sizeof(oldTransfer)):])
+		var r jobPartPlanRecord
+		if r, err = readV19PlanRecord(data, &oldTransfer); err != nil {
+			err = fmt.Errorf("%s: transfer %d: %w", filepath.Base(from), t, err)
+			break
+		}
+		err = w.add(&r)
+	}
+	if err == nil {
+		err = w.finish()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), to)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Remove(from); errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// readV19PlanRecord reads the record of a transfer of a plan of version 19 from its entry and the strings that it
+// points to in data: its source, its destination, and then the properties that it has a length for, which are those
+// of planProperty but for the encryption scope, in the same order
+func readV19PlanRecord(data []byte, old *jobPartPlanTransferV19) (r jobPartPlanRecord, err error) {
+	r.transfer = JobPartPlanTransfer{
+		ModifiedTime:         old.ModifiedTime,
+		SourceSize:           old.SourceSize,
+		EntityType:           old.EntityType,
+		atomicTransferStatus: old.atomicTransferStatus,
+		atomicErrorCode:      old.atomicErrorCode,
+	}
+
+	offset := old.SrcOffset
+	next := func(length int16) string {
+		if err != nil {
+			return ""
+		}
+		if length < 0 || offset < 0 || offset+int64(length) > int64(len(data)) {
+			err = errors.New("its strings are beyond the end of the plan")
+			return ""
+		}
+		s := string(data[offset : offset+int64(length)])
+		offset += int64(length)
+		return s
+	}
+	r.src = next(old.SrcLength)
+	r.dst = next(old.DstLength)
+	for p, length := range [...]int16{
+		planContentType:        old.SrcContentTypeLength,
+		planContentEncoding:    old.SrcContentEncodingLength,
+		planContentLanguage:    old.SrcContentLanguageLength,
+		planContentDisposition: old.SrcContentDispositionLength,
+		planCacheControl:       old.SrcCacheControlLength,
+		planContentMD5:         old.SrcContentMD5Length,
+		planMetadata:           old.SrcMetadataLength,
+		planBlobType:           old.SrcBlobTypeLength,
+		planBlobTier:           old.SrcBlobTierLength,
+		planBlobVersionID:      old.SrcBlobVersionIDLength,
+		planBlobSnapshotID:     old.SrcBlobSnapshotIDLength,
+		planBlobTags:           old.SrcBlobTagsLength,
+	} {
+		r.properties[p] = next(length)
+	}
+	return r, err
+}
+
+// copyPlanFields copies each field of from to the field of the same name in to, field by field through structs whose
+// layout has changed. The unexported fields, such as the statuses, are copied too.
+func copyPlanFields(to, from reflect.Value) {
+	for i := 0; i < from.NumField(); i++ {
+		f := from.Field(i)
+		t := to.FieldByName(from.Type().Field(i).Name)
+		if f.Type() != t.Type() {
+			copyPlanFields(t, f)
+			continue
+		}
+		reflect.NewAt(t.Type(), unsafe.Pointer(t.UnsafeAddr())).Elem().
+			Set(reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem())
+	}
+}
+
+// structBytes returns the memory of *v, which is what's written of it to a plan file
+func structBytes[T any](v *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(v)), unsafe.Sizeof(*v))
+}
@@ -0,0 +1,180 @@
+package ste
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"unsafe"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// A plan file is laid out as:
+//
+//	the JobPartPlanHeader
+//	the command string, padded to 8 bytes
+//	a record for each transfer: its JobPartPlanTransfer, then its strings, padded to 8 bytes
+//	the index: the offset of each transfer's record, in units of 8 bytes, as a uint32
+//
+// The records are written one after another as transfers are added, without knowing how many there will be, and only
+// the index and the header's NumTransfers and TransferIndexOffset are written once they all have been. Each transfer
+// takes its fixed part and only the strings that it has, and since they're next to its status, working through a
+// part's transfers in order reads its memory map from front to back, once.
+//
+// A transfer's strings are each preceded by their length, as a uvarint:
+//
+//	the source
+//	the destination, with its length plus one, or just 0 if it's the same as the source, as it is for most transfers
+//	a uvarint with a bit for each property that the transfer has, in planProperty order
+//	each of those properties
+
+// planProperty is a property of a transfer's source that's stored with its strings, if it has it
+type planProperty int
+
+const (
+	planContentType planProperty = iota
+	planContentEncoding
+	planContentLanguage
+	planContentDisposition
+	planCacheControl
+	planContentMD5
+	planMetadata
+	planBlobType
+	planBlobTier
+	planBlobVersionID
+	planBlobSnapshotID
+	planBlobTags
+	planBlobEncryptionScope
+	numPlanProperties
+)
+
+// jobPartPlanRecord is what's written of a transfer to a plan file
+type jobPartPlanRecord struct {
+	transfer   JobPartPlanTransfer
+	src, dst   string
+	properties [numPlanProperties]string
+}
+
+// newJobPartPlanRecord returns the record of a transfer that's yet to start
+func newJobPartPlanRecord(t *common.CopyTransfer) (r jobPartPlanRecord, err error) {
+	r.transfer = JobPartPlanTransfer{
+		ModifiedTime:         t.LastModifiedTime.UnixNano(),
+		SourceSize:           t.SourceSize,
+		EntityType:           t.EntityType,
+		atomicTransferStatus: common.ETransferStatus.Started(), // Default
+	}
+	r.src, r.dst = t.Source, t.Destination
+
+	// For S2S copy (and, in the case of Content-MD5, always), the src properties
+	r.properties[planContentType] = t.ContentType
+	r.properties[planContentEncoding] = t.ContentEncoding
+	r.properties[planContentLanguage] = t.ContentLanguage
+	r.properties[planContentDisposition] = t.ContentDisposition
+	r.properties[planCacheControl] = t.CacheControl
+	r.properties[planContentMD5] = string(t.ContentMD5)
+	if t.Metadata != nil {
+		if r.properties[planMetadata], err = t.Metadata.Marshal(); err != nil {
+			return r, err
+		}
+	}
+	r.properties[planBlobType] = string(t.BlobType)
+	r.properties[planBlobTier] = string(t.BlobTier)
+	r.properties[planBlobVersionID] = t.BlobVersionID
+	r.properties[planBlobSnapshotID] = t.BlobSnapshotID
+	if t.BlobTags != nil {
+		r.properties[planBlobTags] = t.BlobTags.ToString()
+	}
+	r.properties[planBlobEncryptionScope] = t.BlobEncryptionScope
+	return r, nil
+}
+
+// jobPartPlanWriter writes a plan file, a transfer at a time
+type jobPartPlanWriter struct {
+	file    *os.File
+	w       *bufio.Writer
+	header  JobPartPlanHeader
+	eof     int64
+	index   []uint32
+	strings []byte // the strings of the transfer being added, kept to be reused
+}
+
+// newJobPartPlanWriter starts a plan file with header, whose NumTransfers and TransferIndexOffset are set once all of
+// the transfers have been added
+func newJobPartPlanWriter(file *os.File, header JobPartPlanHeader, commandString string) *jobPartPlanWriter {
+	header.CommandStringLength = uint32(len(commandString))
+	header.NumTransfers = 0
+	header.TransferIndexOffset = 0
+	w := &jobPartPlanWriter{file: file, w: bufio.NewWriter(file), header: header}
+	w.write(structBytes(&w.header))
+	w.write([]byte(commandString))
+	w.pad()
+	return w
+}
+
+// write writes b after what has been written so far. An error is kept by the bufio.Writer, and returned by finish.
+func (w *jobPartPlanWriter) write(b []byte) {
+	n, _ := w.w.Write(b)
+	w.eof += int64(n)
+}
+
+// pad ensures 8 byte alignment so that Atomic fields of JobPartPlanTransfer can actually be accessed atomically
+func (w *jobPartPlanWriter) pad() {
+	var padding [8]byte
+	w.write(padding[:((w.eof+7)&^7)-w.eof])
+}
+
+// add writes the record of a transfer
+func (w *jobPartPlanWriter) add(r *jobPartPlanRecord) error {
+	if w.eof/8 > math.MaxUint32 {
+		return errors.New("the job part plan is too big for the offsets of its transfers")
+	}
+
+	s := binary.AppendUvarint(w.strings[:0], uint64(len(r.src)))
+	s = append(s, r.src...)
+	if r.dst == r.src {
+		s = binary.AppendUvarint(s, 0)
+	} else {
+		s = binary.AppendUvarint(s, uint64(len(r.dst))+1)
+		s = append(s, r.dst...)
+	}
+	var present uint64
+	for p, v := range r.properties {
+		if v != "" {
+			present |= 1 << p
+		}
+	}
+	s = binary.AppendUvarint(s, present)
+	for _, v := range r.properties {
+		if v != "" {
+			s = binary.AppendUvarint(s, uint64(len(v)))
+			s = append(s, v...)
+		}
+	}
+	if len(s) > math.MaxUint32 {
+		return errors.New("the strings of the transfer of " + r.src + " are too long for the job part plan")
+	}
+	w.strings = s
+
+	transfer := r.transfer
+	transfer.stringsLength = uint32(len(s))
+	w.index = append(w.index, uint32(w.eof/8))
+	w.write(structBytes(&transfer))
+	w.write(s)
+	w.pad()
+	return nil
+}
+
+// finish writes the index after the records, and the header again, now that it knows how many transfers there are
+// and where their index is
+func (w *jobPartPlanWriter) finish() error {
+	w.header.NumTransfers = uint32(len(w.index))
+	w.header.TransferIndexOffset = w.eof
+	w.write(unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(w.index))), len(w.index)*4))
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteAt(structBytes(&w.header), 0)
+	return err
+}
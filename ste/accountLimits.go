@@ -0,0 +1,200 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// AccountLimit caps the requests that this process makes to a storage account, so that jobs that share a production
+// account can't trip its ingress and egress limits between them
+type AccountLimit struct {
+	Account  string  // the account name, which is the first label of its hosts, or a whole host, such as 127.0.0.1:10000
+	Requests int     // how many requests may be in progress at once; 0 for no cap
+	Mbps     float64 // the cap on the bytes sent and received, in megabits per second; 0 for no cap
+}
+
+// ParseAccountLimits parses the limits of --account-limits. They are separated by semicolons, and each is an account
+// followed by requests=N, mbps=N or both, as in 'prodaccount requests=16 mbps=200; backupaccount mbps=500'.
+func ParseAccountLimits(s string) ([]AccountLimit, error) {
+	var limits []AccountLimit
+	seen := map[string]bool{}
+	for _, text := range strings.Split(s, ";") {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		limit := AccountLimit{Account: strings.ToLower(fields[0])}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("'%s' has no limits; give requests=N, mbps=N or both", strings.TrimSpace(text))
+		}
+		if seen[limit.Account] {
+			return nil, fmt.Errorf("the limits of %s are given more than once", limit.Account)
+		}
+		seen[limit.Account] = true
+
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(strings.ToLower(field), "=")
+			var err error
+			switch name {
+			case "requests":
+				limit.Requests, err = strconv.Atoi(value)
+				err = checkAccountLimit(err, limit.Requests > 0)
+			case "mbps":
+				limit.Mbps, err = strconv.ParseFloat(value, 64)
+				err = checkAccountLimit(err, limit.Mbps > 0)
+			default:
+				err = fmt.Errorf("'%s' isn't requests=N or mbps=N", field)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: '%s': %w", limit.Account, field, err)
+			}
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+func checkAccountLimit(err error, positive bool) error {
+	if err != nil || !positive {
+		return fmt.Errorf("the limit must be a number greater than zero")
+	}
+	return nil
+}
+
+// accountLimiter holds what is needed to keep to an account's limits
+type accountLimiter struct {
+	requests chan struct{} // a slot for each request that may be in progress; nil if they aren't capped
+	pacer    pacer         // nil if the bytes sent and received aren't capped
+}
+
+var (
+	accountLimitersMu sync.RWMutex
+	// accountLimiters are the limits of this process, by account. It is nil until EnableAccountLimits is called.
+	accountLimiters map[string]*accountLimiter
+)
+
+// EnableAccountLimits has the requests to each account in limits kept within them. They are shared by every job
+// that this process runs; jobs in other processes have limits of their own.
+func EnableAccountLimits(limits []AccountLimit) {
+	limiters := make(map[string]*accountLimiter, len(limits))
+	for _, limit := range limits {
+		l := &accountLimiter{}
+		if limit.Requests > 0 {
+			l.requests = make(chan struct{}, limit.Requests)
+		}
+		if limit.Mbps > 0 {
+			// use the "networking mega", as --cap-mbps does
+			l.pacer = NewTokenBucketPacer(int64(limit.Mbps*1000*1000/8), 0)
+		}
+		limiters[limit.Account] = l
+	}
+
+	accountLimitersMu.Lock()
+	defer accountLimitersMu.Unlock()
+	accountLimiters = limiters
+}
+
+// accountLimiterFor finds the limits of the account that host belongs to, or returns nil if it has none
+func accountLimiterFor(host string) *accountLimiter {
+	accountLimitersMu.RLock()
+	defer accountLimitersMu.RUnlock()
+	if len(accountLimiters) == 0 {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if l, ok := accountLimiters[host]; ok {
+		return l
+	}
+	account, _, _ := strings.Cut(host, ".")
+	return accountLimiters[account]
+}
+
+// accountLimitPolicy paces the bytes sent to and received from accounts with limits as a per-call policy, so that a
+// request body is only wrapped once however often it's retried, and holds one of their request slots for each try as
+// a per-retry policy, so that a request waiting to be retried doesn't hold one.
+type accountLimitPolicy struct {
+	perTry bool
+}
+
+func newAccountLimitPolicy(perTry bool) policy.Policy {
+	return accountLimitPolicy{perTry: perTry}
+}
+
+func (p accountLimitPolicy) Do(req *policy.Request) (*http.Response, error) {
+	l := accountLimiterFor(req.Raw().URL.Host)
+	if l == nil {
+		return req.Next()
+	}
+	ctx := req.Raw().Context()
+
+	if p.perTry {
+		if l.requests == nil {
+			return req.Next()
+		}
+		select {
+		case l.requests <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release := sync.OnceFunc(func() { <-l.requests })
+		resp, err := req.Next()
+		if err != nil || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+			release()
+			return resp, err
+		}
+		// a download is still in progress until its body has been read
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		return resp, err
+	}
+
+	if l.pacer == nil {
+		return req.Next()
+	}
+	if body := req.Body(); body != nil {
+		if err := req.SetBody(newPacedRequestBody(ctx, body, l.pacer), req.Raw().Header.Get("Content-Type")); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := req.Next()
+	if err == nil && resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = newPacedResponseBody(ctx, resp.Body, l.pacer)
+	}
+	return resp, err
+}
+
+// releasingBody gives back a request slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,65 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const (
+	// blockSendTarget is how long a block should take to send over one connection. Blocks much quicker than that
+	// spend too much of their time on each request's round trip; much slower, and each retry repeats too much.
+	blockSendTarget = 2 * time.Second
+	// maxTunedBlockSize is as large as throughput alone makes blocks; only the 50,000-block limit makes them larger
+	maxTunedBlockSize = 100 * 1024 * 1024
+	// minBlocksPerFile is how many blocks a file is split into, at least, if blocks of the default size allow it, so
+	// that a single large file still goes over several connections at once
+	minBlocksPerFile  = 16
+	blockSizeRounding = 1024 * 1024
+)
+
+// chooseBlockSize is the block size for a file of sourceSize bytes when --block-size-mb isn't given. It's as large as
+// one connection can send in blockSendTarget, from connectionThroughput, in bytes per second, or 0 if that hasn't been
+// measured yet; but no larger than splits the file into minBlocksPerFile blocks, or lets a few of them share
+// memoryLimit; and always large enough for the file to fit in the 50,000 blocks that a blob can have.
+func chooseBlockSize(sourceSize int64, connectionThroughput int64, memoryLimit int64) int64 {
+	blockSize := int64(common.DefaultBlockBlobBlockSize)
+	if connectionThroughput > 0 {
+		blockSize = max(blockSize, min(connectionThroughput*int64(blockSendTarget/time.Second), maxTunedBlockSize))
+	}
+	blockSize = min(blockSize, max(common.DefaultBlockBlobBlockSize, sourceSize/minBlocksPerFile))
+	if memoryLimit > 0 {
+		// getVerifiedChunkParams warns when fewer than MinParallelChunkCountThreshold of them fit
+		blockSize = min(blockSize, max(common.DefaultBlockBlobBlockSize, memoryLimit/(2*common.MinParallelChunkCountThreshold)))
+	}
+	blockSize = roundUpBlockSize(blockSize)
+
+	// the limit on blocks can't be worked around, so it comes last
+	fewestBlocks := roundUpBlockSize((sourceSize + common.MaxNumberOfBlocksPerBlob - 1) / common.MaxNumberOfBlocksPerBlob)
+	return min(max(blockSize, fewestBlocks), common.MaxBlockBlobBlockSize)
+}
+
+// roundUpBlockSize rounds n up to a whole number of MiB
+func roundUpBlockSize(n int64) int64 {
+	return (n + blockSizeRounding - 1) / blockSizeRounding * blockSizeRounding
+}
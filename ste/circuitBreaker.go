@@ -0,0 +1,170 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CircuitBreakerSettings say when a job stops starting new transfers because too many of its recent ones have
+// failed, as they all do once a SAS has expired, for example, rather than failing every file that's left. Once it
+// has tripped, transfers start again after the cool-down, and if the first of them to finish fails too, it trips
+// again.
+type CircuitBreakerSettings struct {
+	FailureRate float64       // the fraction of the recent transfers that must have failed for it to trip, or 0 for it never to
+	Window      int           // how many of the most recent transfers are counted; it can't trip before that many have finished
+	Cooldown    time.Duration // how long it holds new transfers back for
+}
+
+func DefaultCircuitBreakerSettings() CircuitBreakerSettings {
+	return CircuitBreakerSettings{Window: 50, Cooldown: 5 * time.Minute}
+}
+
+var circuitBreakerSettings atomic.Pointer[CircuitBreakerSettings]
+
+// SetCircuitBreakerSettings sets the circuit breaker of the jobs that are started from then on
+func SetCircuitBreakerSettings(s CircuitBreakerSettings) error {
+	switch {
+	case s.FailureRate < 0 || s.FailureRate > 1:
+		return errors.New("the failure rate must be from 0 to 1")
+	case s.Window < 1:
+		return errors.New("the window must be at least 1 transfer")
+	case s.Cooldown <= 0:
+		return errors.New("the cool-down must be more than 0")
+	}
+	circuitBreakerSettings.Store(&s)
+	return nil
+}
+
+func currentCircuitBreakerSettings() CircuitBreakerSettings {
+	if p := circuitBreakerSettings.Load(); p != nil {
+		return *p
+	}
+	return DefaultCircuitBreakerSettings()
+}
+
+// String is how the settings are given in the job log
+func (s CircuitBreakerSettings) String() string {
+	if s.FailureRate == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("trips when %.0f%% of the last %d transfers have failed, for %v at a time", s.FailureRate*100, s.Window, s.Cooldown)
+}
+
+// circuitBreaker counts how a job's transfers end, and holds new ones back at the job's transfer gate when too many
+// of them have failed
+type circuitBreaker struct {
+	settings CircuitBreakerSettings
+	gate     *TransferGate
+	notify   func(msg string) // tells the job log and the user
+
+	mu       sync.Mutex
+	recent   []bool // whether each of the recent transfers failed, oldest first once next wraps around
+	next     int
+	failures int
+	tripped  bool // holding new transfers back
+	probing  bool // letting them start again, until the first of them to finish says whether it was too soon
+	timer    *time.Timer
+}
+
+func newCircuitBreaker(settings CircuitBreakerSettings, gate *TransferGate, notify func(msg string)) *circuitBreaker {
+	return &circuitBreaker{settings: settings, gate: gate, notify: notify}
+}
+
+// record counts a transfer that has finished, and trips the breaker if it's one failure too many
+func (b *circuitBreaker) record(failed bool, reason string) {
+	if b == nil || b.settings.FailureRate == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.tripped:
+		// it was started before the breaker tripped, so it says nothing new
+		return
+	case b.probing:
+		b.probing = false
+		if failed {
+			b.trip(fmt.Sprintf("A transfer failed again after the pause, with: %s.", reason))
+		} else {
+			b.recent, b.next, b.failures = b.recent[:0], 0, 0
+		}
+		return
+	}
+
+	if len(b.recent) < b.settings.Window {
+		b.recent = append(b.recent, failed)
+	} else {
+		if b.recent[b.next] {
+			b.failures--
+		}
+		b.recent[b.next] = failed
+		b.next = (b.next + 1) % b.settings.Window
+	}
+	if failed {
+		b.failures++
+	}
+	if len(b.recent) == b.settings.Window && float64(b.failures) >= b.settings.FailureRate*float64(b.settings.Window) {
+		b.trip(fmt.Sprintf("%d of the last %d transfers failed, the last with: %s.", b.failures, b.settings.Window, reason))
+	}
+}
+
+// trip holds new transfers back for the cool-down. The caller holds b.mu.
+func (b *circuitBreaker) trip(why string) {
+	b.tripped = true
+	b.gate.trip()
+	b.notify(fmt.Sprintf("%s No new transfers will start for %v, while the ones in flight finish. If the cause needs fixing, "+
+		"such as an expired SAS, cancel the job, and resume it once it's fixed.", why, b.settings.Cooldown))
+	b.timer = time.AfterFunc(b.settings.Cooldown, b.retry)
+}
+
+// retry lets transfers start again, once the cool-down is over
+func (b *circuitBreaker) retry() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tripped {
+		return
+	}
+	b.tripped = false
+	b.probing = true
+	b.gate.untrip()
+	b.notify("Transfers are starting again, after the circuit breaker's cool-down.")
+}
+
+// reset starts afresh, for a job that is being run again in the same process
+func (b *circuitBreaker) reset() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.recent, b.next, b.failures = b.recent[:0], 0, 0
+	b.tripped, b.probing = false, false
+	b.gate.untrip()
+}
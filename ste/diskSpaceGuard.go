@@ -0,0 +1,174 @@
+package ste
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// DiskSpaceSettings say how much space downloads leave free on the file system they are saved to. A download that
+// would leave less, or that finds less left as it goes, either holds the job back until space has been freed, or has
+// the job cancelled, rather than every file that's left failing once the disk fills up.
+type DiskSpaceSettings struct {
+	MinFree  uint64        // in bytes, or 0 for free space not to be checked
+	Fail     bool          // cancel the job, rather than waiting for space to be freed
+	Interval time.Duration // how often free space is checked while downloading, and while waiting for it to be freed
+}
+
+func DefaultDiskSpaceSettings() DiskSpaceSettings {
+	return DiskSpaceSettings{Interval: 10 * time.Second}
+}
+
+var diskSpaceSettings atomic.Pointer[DiskSpaceSettings]
+
+// SetDiskSpaceSettings sets the free space checks of the jobs that are started from then on
+func SetDiskSpaceSettings(s DiskSpaceSettings) error {
+	if s.Interval <= 0 {
+		return errors.New("free space must be checked at an interval of more than 0")
+	}
+	diskSpaceSettings.Store(&s)
+	return nil
+}
+
+func currentDiskSpaceSettings() DiskSpaceSettings {
+	if p := diskSpaceSettings.Load(); p != nil {
+		return *p
+	}
+	return DefaultDiskSpaceSettings()
+}
+
+// String is how the settings are given in the job log
+func (s DiskSpaceSettings) String() string {
+	if s.MinFree == 0 {
+		return "not checked"
+	}
+	return fmt.Sprintf("downloads leave %s free, or %s", mebibytes(s.MinFree), common.Iff(s.Fail, "the job is cancelled", "the job pauses until they can"))
+}
+
+func mebibytes(n uint64) string {
+	return fmt.Sprintf("%d MiB", n/(1024*1024))
+}
+
+// diskSpaceGuard checks the free space on the file system that a job's downloads are saved to, and holds the job's
+// workers back at its transfer gate, or cancels the job, when there isn't enough
+type diskSpaceGuard struct {
+	settings  DiskSpaceSettings
+	gate      *TransferGate
+	notify    func(msg string) // tells the job log and the user
+	cancelJob func(msg string)
+	freeSpace func(path string) (uint64, error) // common.FreeDiskSpace, except in tests
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	waiting   bool   // holding the workers back until space has been freed
+	path      string // while waiting, the download that found too little space, whose file system is watched
+	need      uint64 // while waiting, how much must be free for the workers to carry on
+	err       error  // set once the job has been cancelled for want of space
+	timer     *time.Timer
+}
+
+func newDiskSpaceGuard(settings DiskSpaceSettings, gate *TransferGate, notify func(msg string), cancelJob func(msg string)) *diskSpaceGuard {
+	return &diskSpaceGuard{settings: settings, gate: gate, notify: notify, cancelJob: cancelJob, freeSpace: common.FreeDiskSpace}
+}
+
+// beforeDownload waits until there's room for size more bytes at path, on top of MinFree. If the job is cancelled
+// instead, it returns why.
+func (g *diskSpaceGuard) beforeDownload(ctx context.Context, path string, size uint64) error {
+	if g == nil || g.settings.MinFree == 0 {
+		return nil
+	}
+	for {
+		held, err := g.check(path, size, true)
+		if held == nil || err != nil {
+			return err
+		}
+		select {
+		case <-held:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// whileDownloading checks, at most once an Interval, that downloads haven't used up the space that's free at path.
+// It doesn't wait for space to be freed: the chunk it's called for is allowed to finish, while those after it are held.
+func (g *diskSpaceGuard) whileDownloading(path string) {
+	if g == nil || g.settings.MinFree == 0 {
+		return
+	}
+	_, _ = g.check(path, 0, false)
+}
+
+// check looks at the free space at path, unless it was looked at less than an Interval ago and always isn't set. If
+// there's too little, it either cancels the job, and returns why, or holds the workers back, and returns a channel that
+// is closed once space may have been freed.
+func (g *diskSpaceGuard) check(path string, size uint64, always bool) (<-chan struct{}, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	need := g.settings.MinFree + size
+	switch {
+	case g.err != nil:
+		return nil, g.err
+	case g.waiting:
+		// recheck is watching the free space already
+		g.need = max(g.need, need)
+		return g.gate.spaceHeld(), nil
+	case !always && time.Since(g.lastCheck) < g.settings.Interval:
+		return nil, nil
+	}
+
+	g.lastCheck = time.Now()
+	free, err := g.freeSpace(path)
+	if err != nil || free >= need {
+		return nil, nil // if the file system can't say, the download finds out for itself
+	}
+	why := fmt.Sprintf("Only %s is free for %s, which needs %s.", mebibytes(free), path, mebibytes(need))
+	if g.settings.Fail {
+		g.err = errors.New(why)
+		g.cancelJob(why + " Cancelling the job. Resume it once space has been freed.")
+		return nil, g.err
+	}
+
+	g.waiting, g.path, g.need = true, path, need
+	g.gate.holdForSpace()
+	g.notify(why + " No new transfers or chunks will start until space has been freed. Those in flight will finish.")
+	g.timer = time.AfterFunc(g.settings.Interval, g.recheck)
+	return g.gate.spaceHeld(), nil
+}
+
+// recheck lets the workers carry on once space has been freed, and otherwise looks again after another Interval
+func (g *diskSpaceGuard) recheck() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.waiting {
+		return
+	}
+	if free, err := g.freeSpace(g.path); err == nil && free < g.need {
+		g.timer = time.AfterFunc(g.settings.Interval, g.recheck)
+		return
+	}
+	g.waiting = false
+	g.lastCheck = time.Now()
+	g.gate.releaseForSpace()
+	g.notify("Transfers are starting again, now that space has been freed.")
+}
+
+// reset starts afresh, for a job that is being run again in the same process
+func (g *diskSpaceGuard) reset() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.waiting, g.err, g.lastCheck = false, nil, time.Time{}
+	g.gate.releaseForSpace()
+}
@@ -75,7 +75,7 @@ func (bd *blobFSDownloader) CreateFile(jptm IJobPartTransferMgr, destination str
 		flags |= os.O_SYNC
 	}
 
-	file, err = os.OpenFile(destination, flags, os.FileMode(mode)) // os.FileMode is uint32 on Linux.
+	file, err = common.OpenFileToWrite(destination, flags, os.FileMode(mode)) // os.FileMode is uint32 on Linux.
 	if err != nil {
 		return
 	}
@@ -127,12 +127,12 @@ func (bd *blobFSDownloader) ApplyUnixProperties(adapter common.UnixStatAdapter)
 
 		uid := stat.Uid
 		if common.StatXReturned(mask, common.STATX_UID) {
-			uid = adapter.Owner()
+			uid = common.MapUID(adapter.Owner())
 		}
 
 		gid := stat.Gid
 		if common.StatXReturned(mask, common.STATX_GID) {
-			gid = adapter.Group()
+			gid = common.MapGID(adapter.Group())
 		}
 		// set ownership
 		err = os.Chown(destination, int(uid), int(gid))
@@ -160,7 +160,7 @@ func (bd *blobFSDownloader) ApplyUnixProperties(adapter common.UnixStatAdapter)
 		if err != nil {
 			return "chmod", err
 		}
-		err = os.Chown(destination, int(adapter.Owner()), int(adapter.Group()))
+		err = os.Chown(destination, int(common.MapUID(adapter.Owner())), int(common.MapGID(adapter.Group())))
 		if err != nil {
 			return "chown", err
 		}
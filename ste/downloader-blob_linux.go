@@ -74,7 +74,7 @@ func (bd *blobDownloader) CreateFile(jptm IJobPartTransferMgr, destination strin
 		flags |= os.O_SYNC
 	}
 
-	file, err = os.OpenFile(destination, flags, os.FileMode(mode)) // os.FileMode is uint32 on Linux.
+	file, err = common.OpenFileToWrite(destination, flags, os.FileMode(mode)) // os.FileMode is uint32 on Linux.
 	if err != nil {
 		return
 	}
@@ -126,12 +126,12 @@ func (bd *blobDownloader) ApplyUnixProperties(adapter common.UnixStatAdapter) (s
 
 		uid := stat.Uid
 		if common.StatXReturned(mask, common.STATX_UID) {
-			uid = adapter.Owner()
+			uid = common.MapUID(adapter.Owner())
 		}
 
 		gid := stat.Gid
 		if common.StatXReturned(mask, common.STATX_GID) {
-			gid = adapter.Group()
+			gid = common.MapGID(adapter.Group())
 		}
 		// set ownership
 		err = os.Chown(destination, int(uid), int(gid))
@@ -159,7 +159,7 @@ func (bd *blobDownloader) ApplyUnixProperties(adapter common.UnixStatAdapter) (s
 		if err != nil {
 			return "chmod", err
 		}
-		err = os.Chown(destination, int(adapter.Owner()), int(adapter.Group()))
+		err = os.Chown(destination, int(common.MapUID(adapter.Owner())), int(common.MapGID(adapter.Group())))
 		if err != nil {
 			return "chown", err
 		}
@@ -0,0 +1,236 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// localCopyStep bounds how much is copied between checks for cancellation.
+const localCopyStep = 64 * 1024 * 1024
+
+// localDownloader "downloads" from a local file, for LocalLocal jobs. Plugging into the download path means
+// overwrite handling, temp file naming, resume and folder creation all work just as they do for real downloads.
+type localDownloader struct {
+	jptm    IJobPartTransferMgr
+	sip     *localFileSourceInfoProvider
+	srcMu   sync.Mutex
+	srcFile common.CloseableReaderAt // only opened if we go through the chunked path
+}
+
+func newLocalDownloader(jptm IJobPartTransferMgr) (downloader, error) {
+	return &localDownloader{
+		jptm: jptm,
+		sip:  &localFileSourceInfoProvider{jptm, jptm.Info()},
+	}, nil
+}
+
+// CreateFile takes the fast path for everything but /dev/null: the whole file is copied here, and no chunks are scheduled.
+// os.File.ReadFrom uses copy_file_range(2) on FreeBSD and Linux, so the data never passes through user space, and on
+// ZFS with block cloning it is not copied at all. Elsewhere it falls back to an ordinary read/write loop.
+func (ld *localDownloader) CreateFile(jptm IJobPartTransferMgr, destination string, size int64, writeThrough bool, t FolderCreationTracker) (file io.WriteCloser, needChunks bool, err error) {
+	info := jptm.Info()
+
+	dst, err := common.CreateFileOfSizeWithWriteThroughOption(destination, size, writeThrough, t, jptm.GetForceIfReadOnly())
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = ld.copyWholeFile(dst, size)
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(destination)
+		return nil, false, err
+	}
+
+	// with no chunks, the download epilogue has no file to rename, so do it ourselves
+	if destination != info.Destination {
+		if err = renameDownload(jptm, info); err != nil {
+			_ = os.Remove(destination)
+			return nil, false, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (ld *localDownloader) copyWholeFile(dst *os.File, size int64) error {
+	src, err := ld.sip.OpenSourceFile()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var f *os.File
+	var bypass *common.CacheBypassingFile
+	switch s := src.(type) {
+	case *os.File:
+		f = s
+	case common.CacheBypassingFile:
+		f, bypass = s.File, &s
+	default:
+		return nil // a pipe, device or socket; there's no content to copy
+	}
+
+	for copied := int64(0); copied < size; {
+		if ld.jptm.WasCanceled() {
+			return errors.New("transfer cancelled")
+		}
+		n, err := io.CopyN(dst, f, min(localCopyStep, size-copied))
+		if bypass != nil {
+			bypass.DropFromCache(copied, n)
+		}
+		copied += n
+		if err == io.EOF {
+			return fmt.Errorf("source file shrank during copy: expected %d bytes, found %d", size, copied)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return ld.checkSourceUnchanged()
+}
+
+// checkSourceUnchanged mirrors the change detection done at the end of uploads.
+func (ld *localDownloader) checkSourceUnchanged() error {
+	lmt, err := ld.sip.GetFreshFileLastModifiedTime()
+	if err != nil {
+		return err
+	}
+	if !lmt.Equal(ld.jptm.LastModifiedTime()) {
+		common.DocumentationForDependencyOnChangeDetection()
+		ld.jptm.Log(common.LogError, fmt.Sprintf("Source Modified during transfer. Enumeration %v, current %v", ld.jptm.LastModifiedTime(), lmt))
+		return errors.New("source modified during transfer")
+	}
+	return nil
+}
+
+func (ld *localDownloader) Prologue(jptm IJobPartTransferMgr) {
+	ld.jptm = jptm
+}
+
+// GenerateDownloadFunc is only used when CreateFile isn't, i.e. when the destination is /dev/null
+func (ld *localDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, destWriter common.ChunkedFileWriter, id common.ChunkID, length int64, pacer pacer) chunkFunc {
+	return createDownloadChunkFunc(jptm, id, func() {
+		src, err := ld.openSourceOnce()
+		if err != nil {
+			jptm.FailActiveDownload("Opening source file", err)
+			return
+		}
+
+		jptm.LogChunkStatus(id, common.EWaitReason.DiskIO())
+		err = destWriter.EnqueueChunk(jptm.Context(), id, length, io.NewSectionReader(src, id.OffsetInFile(), length), false)
+		if err != nil {
+			jptm.FailActiveDownload("Enqueuing chunk", err)
+		}
+	})
+}
+
+func (ld *localDownloader) openSourceOnce() (common.CloseableReaderAt, error) {
+	ld.srcMu.Lock()
+	defer ld.srcMu.Unlock()
+	if ld.srcFile == nil {
+		f, err := ld.sip.OpenSourceFile()
+		if err != nil {
+			return nil, err
+		}
+		ld.srcFile = f
+	}
+	return ld.srcFile, nil
+}
+
+func (ld *localDownloader) Epilogue() {
+	if ld.srcFile != nil {
+		_ = ld.srcFile.Close()
+	}
+
+	info := ld.jptm.Info()
+	if ld.jptm.IsLive() && info.Destination != common.Dev_Null {
+		if err := ld.copyProperties(info.Destination); err != nil {
+			ld.jptm.FailActiveDownload("Copying file properties", err)
+		}
+	}
+}
+
+func (ld *localDownloader) SetFolderProperties(jptm IJobPartTransferMgr) error {
+	return ld.copyProperties(jptm.Info().Destination)
+}
+
+func (ld *localDownloader) CreateSymlink(jptm IJobPartTransferMgr) error {
+	target, err := ld.sip.ReadLink()
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, jptm.Info().Destination)
+}
+
+// copyProperties carries the permission bits across, as cp(1) does, plus ACLs and extended attributes if asked for.
+// Times are left to the download epilogue, which already handles --preserve-last-modified-time.
+func (ld *localDownloader) copyProperties(dst string) error {
+	info := ld.jptm.Info()
+
+	fi, err := os.Stat(info.Source)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if info.PreserveACLs {
+		access, def, err := ld.sip.GetACLs()
+		if err != nil {
+			return err
+		}
+		if access != nil || def != nil {
+			if err = common.SetFileACLs(dst, access, def); err != nil {
+				return err
+			}
+		}
+	}
+
+	if info.PreserveExtAttrs {
+		attrs, err := ld.sip.GetExtAttrs()
+		if err != nil {
+			return err
+		}
+		for key, value := range attrs {
+			if err = common.SetExtAttr(dst, key, value); err != nil {
+				if errors.Is(err, os.ErrPermission) {
+					ld.jptm.LogAtLevelForCurrentTransfer(common.LogWarning, fmt.Sprintf("Not permitted to set extended attribute %s", key))
+					continue
+				}
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package ste
+
+import (
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// providerDownloader downloads from a location served by a registered common.Provider.
+type providerDownloader struct {
+	mu     sync.Mutex
+	source common.CloseableReaderAt // opened by the first chunk, and shared by the rest
+}
+
+func newProviderDownloader(jptm IJobPartTransferMgr) (downloader, error) {
+	return &providerDownloader{}, nil
+}
+
+func (pd *providerDownloader) Prologue(jptm IJobPartTransferMgr) {}
+
+func (pd *providerDownloader) GenerateDownloadFunc(jptm IJobPartTransferMgr, destWriter common.ChunkedFileWriter, id common.ChunkID, length int64, pacer pacer) chunkFunc {
+	return createDownloadChunkFunc(jptm, id, func() {
+		source, err := pd.open(jptm)
+		if err != nil {
+			jptm.FailActiveDownload("Opening source object", err)
+			return
+		}
+
+		jptm.LogChunkStatus(id, common.EWaitReason.Body())
+		body := newPacedResponseBody(jptm.Context(), io.NopCloser(io.NewSectionReader(source, id.OffsetInFile(), length)), pacer)
+		if err = destWriter.EnqueueChunk(jptm.Context(), id, length, body, false); err != nil {
+			jptm.FailActiveDownload("Enqueuing chunk", err)
+		}
+	})
+}
+
+func (pd *providerDownloader) open(jptm IJobPartTransferMgr) (common.CloseableReaderAt, error) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	if pd.source == nil {
+		p, u, err := common.GetProvider(jptm.Info().Source)
+		if err != nil {
+			return nil, err
+		}
+		if pd.source, err = p.Open(jptm.Context(), u); err != nil {
+			return nil, err
+		}
+	}
+	return pd.source, nil
+}
+
+func (pd *providerDownloader) Epilogue() {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	if pd.source != nil {
+		_ = pd.source.Close()
+	}
+}
@@ -95,5 +95,8 @@ func createDownloadChunkFunc(jptm IJobPartTransferMgr, id common.ChunkID, body f
 	// If uploading, we set the chunk status to done as soon as the chunkFunc completes.
 	// But we don't do that for downloads, since for those the chunk is not "done" until its flushed out
 	// by the ChunkedFileWriter. (The ChunkedFileWriter will set the status to done at that time.)
-	return createChunkFunc(false, jptm, id, body)
+	return createChunkFunc(false, jptm, id, func() {
+		jptm.CheckDiskSpace() // so that the job stops before the disk is full, rather than every download failing when it is
+		body()
+	})
 }
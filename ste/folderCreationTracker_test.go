@@ -48,7 +48,7 @@ func (jpph *mockedJPPH)	Transfer(idx uint32) *JobPartPlanTransfer {
 }
 func (jpph *mockedJPPH)	TransferSrcDstRelatives(uint32) (string, string) { panic("Not implemented") }
 func (jpph *mockedJPPH)	TransferSrcDstStrings(uint32) (string, string, bool) { panic("Not implemented") }
-func (jpph *mockedJPPH)	TransferSrcPropertiesAndMetadata(uint32) (common.ResourceHTTPHeaders, common.Metadata, blob.BlobType, blob.AccessTier, bool, bool, bool, common.InvalidMetadataHandleOption, common.EntityType, string, string, common.BlobTags) {
+func (jpph *mockedJPPH)	TransferSrcPropertiesAndMetadata(uint32) (common.ResourceHTTPHeaders, common.Metadata, blob.BlobType, blob.AccessTier, bool, bool, bool, common.InvalidMetadataHandleOption, common.EntityType, string, string, common.BlobTags, string) {
 	panic("Not implemented")
 }
 
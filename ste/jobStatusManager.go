@@ -21,6 +21,7 @@
 package ste
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
@@ -161,6 +162,11 @@ func (jm *jobMgr) handleStatusUpdateMessage() {
 					js.FoldersFailed++
 				}
 				js.TransfersFailed++
+				if msg.ErrorCode == http.StatusUnauthorized || msg.ErrorCode == http.StatusForbidden {
+					js.TransfersFailedAuth++
+				} else if msg.IsNetworkFailure {
+					js.TransfersFailedNetwork++
+				}
 				js.FailedTransfers = append(js.FailedTransfers, msg)
 			case common.ETransferStatus.SkippedEntityAlreadyExists(),
 				common.ETransferStatus.SkippedBlobHasSnapshots():
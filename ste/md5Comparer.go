@@ -23,6 +23,8 @@ package ste
 import (
 	"bytes"
 	"errors"
+	"fmt"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
@@ -34,6 +36,7 @@ type md5Comparer struct {
 	expected         []byte
 	actualAsSaved    []byte
 	validationOption common.HashValidationOption
+	hashType         common.ContentHashType // MD5 unless the job hashes with another algorithm, whose hash is in metadata
 	logger           transferSpecificLogger
 }
 
@@ -48,6 +51,29 @@ const noMD5Stored = "no MD5 was stored in the Blob/File service against this fil
 
 var errExpectedMd5Missing = errors.New(noMD5Stored + " This application is currently configured to treat missing MD5 hashes as errors")
 
+// hashes other than MD5 are recorded in metadata, by AzCopy (or by another tool that records them the same way)
+func hashMismatchError(hashType common.ContentHashType) error {
+	if hashType == common.EContentHashType.MD5() {
+		return errMd5Mismatch
+	}
+	return fmt.Errorf("the %s hash of the data, as we received it, did not match the expected value, as found in the %s metadata of the source. "+
+		"This means that either there is a data integrity error OR another tool has changed the data without updating the hash", hashType, hashType.MetadataKey())
+}
+
+func hashNotStoredMessage(hashType common.ContentHashType) string {
+	if hashType == common.EContentHashType.MD5() {
+		return noMD5Stored
+	}
+	return fmt.Sprintf("no %s hash was stored in the %s metadata of this file. So the downloaded data cannot be %s-validated.", hashType, hashType.MetadataKey(), hashType)
+}
+
+func expectedHashMissingError(hashType common.ContentHashType) error {
+	if hashType == common.EContentHashType.MD5() {
+		return errExpectedMd5Missing
+	}
+	return errors.New(hashNotStoredMessage(hashType) + " This application is currently configured to treat missing hashes as errors")
+}
+
 var errActualMd5NotComputed = errors.New("no MDB was computed within this application. This indicates a logic error in this application")
 
 // Check compares the two MD5s, and returns any error if applicable
@@ -83,7 +109,7 @@ func (c *md5Comparer) Check() error {
 		switch c.validationOption {
 		case common.EHashValidationOption.FailIfDifferentOrMissing(),
 			common.EHashValidationOption.FailIfDifferent():
-			return errMd5Mismatch
+			return hashMismatchError(c.hashType)
 		case common.EHashValidationOption.LogOnly():
 			c.logAsDifferent()
 			return nil
@@ -96,9 +122,9 @@ func (c *md5Comparer) Check() error {
 }
 
 func (c *md5Comparer) logAsMissing() {
-	c.logger.LogAtLevelForCurrentTransfer(common.LogWarning, noMD5Stored)
+	c.logger.LogAtLevelForCurrentTransfer(common.LogWarning, hashNotStoredMessage(c.hashType))
 }
 
 func (c *md5Comparer) logAsDifferent() {
-	c.logger.LogAtLevelForCurrentTransfer(common.LogWarning, errMd5Mismatch.Error())
+	c.logger.LogAtLevelForCurrentTransfer(common.LogWarning, hashMismatchError(c.hashType).Error())
 }
@@ -30,6 +30,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
@@ -55,8 +58,8 @@ type IJobMgr interface {
 	// If existingPlanMMF is nil, a new MMF is opened.
 	AddJobPart(args *AddJobPartArgs) IJobPartMgr
 
-	SetIncludeExclude(map[string]int, map[string]int)
-	IncludeExclude() (map[string]int, map[string]int)
+	SetResumeFilter(filter *ResumeFilter)
+	ResumeFilter() *ResumeFilter
 	ResumeTransfers(appCtx context.Context)
 	ResetFailedTransfersCount()
 	AllTransfersScheduled() bool
@@ -97,6 +100,11 @@ type IJobMgr interface {
 
 	/* Some comment */
 	IterateJobParts(readonly bool, f func(k common.PartNumber, v IJobPartMgr))
+	QueueJobParts(jpm IJobPartMgr)
+	FlushPlans() error
+	TransfersInFlight() int64
+	ConnectionThroughput() int64
+	TransferGate() *TransferGate
 	TransferDirection() common.TransferDirection
 	AddSuccessfulBytesInActiveFiles(n int64)
 	SuccessfulBytesInActiveFiles() uint64
@@ -149,7 +157,7 @@ func NewJobMgr(concurrency ConcurrencySettings, jobID common.JobID, appCtx conte
 		jobLogger.OpenLog()
 	}
 
-	jm := jobMgr{jobID: jobID, jobPartMgrs: newJobPartToJobPartMgr(), include: map[string]int{}, exclude: map[string]int{},
+	jm := jobMgr{jobID: jobID, jobPartMgrs: newJobPartToJobPartMgr(),
 		httpClient:           NewAzcopyHTTPClient(concurrency.MaxIdleConnections),
 		logger:               jobLogger,
 		chunkStatusLogger:    common.NewChunkStatusLogger(jobID, cpuMon, common.LogPathFolder, enableChunkLogOutput),
@@ -187,8 +195,21 @@ func NewJobMgr(concurrency ConcurrencySettings, jobID common.JobID, appCtx conte
 		fileCountLimiter: fileCountLimiter,
 		cpuMon:           cpuMon,
 		jstm:             &jstm,
+		gate:             newTransferGate(),
 		isDaemon:         daemonMode,
 		/*Other fields remain zero-value until this job is scheduled */}
+	jm.breaker = newCircuitBreaker(currentCircuitBreakerSettings(), jm.gate, func(msg string) {
+		jm.Log(common.LogWarning, msg)
+		common.GetLifecycleMgr().Info(msg)
+	})
+	jm.diskSpace = newDiskSpaceGuard(currentDiskSpaceSettings(), jm.gate, func(msg string) {
+		jm.Log(common.LogWarning, msg)
+		common.GetLifecycleMgr().Info(msg)
+	}, func(msg string) {
+		jm.Log(common.LogError, msg)
+		common.GetLifecycleMgr().Warn(msg)
+		go jm.CancelPauseJobOrder(common.EJobStatus.Cancelled()) // not while the guard is locked, since cancelling may block
+	})
 	jm.Reset(appCtx, commandString)
 	// One routine constantly monitors the partsChannel.  It takes the JobPartManager from
 	// the Channel and schedules the transfers of that JobPart.
@@ -221,6 +242,14 @@ func (jm *jobMgr) Reset(appCtx context.Context, commandString string) IJobMgr {
 	}
 	jm.logConcurrencyParameters()
 	jm.ctx, jm.cancel = context.WithCancel(appCtx)
+	jm.gate.reset()
+	jm.breaker.reset()
+	jm.diskSpace.reset()
+	if jm.jobSpan != nil {
+		jm.jobSpan.End() // resuming in the same process starts a new root span in the job's trace
+	}
+	jm.ctx, jm.jobSpan = startJobSpan(jm.ctx, jm.jobID)
+	jm.ctx = withPacerShare(jm.ctx, jm.jobID.String())
 	atomic.StoreUint64(&jm.atomicNumberOfBytesCovered, 0)
 	atomic.StoreUint64(&jm.atomicTotalBytesToXfer, 0)
 	jm.partsDone = 0
@@ -262,6 +291,26 @@ func (jm *jobMgr) logConcurrencyParameters() {
 
 	jm.logger.Log(level, fmt.Sprintf("Max open files when downloading: %d (auto-computed)",
 		jm.concurrency.MaxOpenDownloadFiles))
+	if description := common.MemoryLimitDescription(); description != "" {
+		jm.logger.Log(level, "Memory limit: "+description)
+	}
+
+	jm.logger.Log(level, fmt.Sprintf("HTTP transport: %s", transportSettings()))
+	jm.logger.Log(level, fmt.Sprintf("Retries: %s", currentRetrySettings()))
+	jm.logger.Log(level, fmt.Sprintf("Circuit breaker: %s", currentCircuitBreakerSettings()))
+	jm.logger.Log(level, fmt.Sprintf("Free disk space: %s", currentDiskSpaceSettings()))
+	if description := common.ClientTLSDescription(); description != "" {
+		jm.logger.Log(level, "TLS: "+description)
+	}
+	if description := common.SourceAddressDescription(); description != "" {
+		jm.logger.Log(level, "Outgoing connections are made from "+description)
+	}
+	if version := common.IPVersion(); version != 0 {
+		jm.logger.Log(level, fmt.Sprintf("Connections are made over IPv%d only", version))
+	}
+	if description := common.HostOverridesDescription(); description != "" {
+		jm.logger.Log(level, "Connections are sent "+description)
+	}
 }
 
 // jobMgrInitState holds one-time init structures (such as SIPM), that initialize when the first part is added.
@@ -284,6 +333,8 @@ type jobMgr struct {
 	atomicCurrentConcurrentConnections int64
 	/* Pool sizer related values */
 	atomicSuccessfulBytesInActiveFiles int64 // atomic 64-bit values should always be at the start of a struct to ensure alignment
+	atomicTransfersInFlight            int64 // transfers that have been started, but not yet reported done
+	atomicConnectionThroughput         int64 // bytes per second per connection, as the pool sizer last measured it
 	atomicCurrentMainPoolSize          int32
 	// atomicAllTransfersScheduled defines whether all job parts have been iterated and resumed or not
 	atomicAllTransfersScheduled     int32
@@ -296,6 +347,7 @@ type jobMgr struct {
 	jobID                           common.JobID // The Job's unique ID
 	ctx                             context.Context
 	cancel                          context.CancelFunc
+	jobSpan                         trace.Span
 	pipelineNetworkStats            *PipelineNetworkStats
 
 	// Share the same HTTP Client across all job parts, so that the we maximize reuse of
@@ -312,10 +364,8 @@ type jobMgr struct {
 	// throughput  common.CountPerSecond // TODO: Set LastCheckedTime to now
 
 	inMemoryTransitJobState InMemoryTransitJobState
-	// list of transfer mentioned to include only then while resuming the job
-	include map[string]int
-	// list of transfer mentioned to exclude while resuming the job
-	exclude map[string]int
+	// which transfers are retried when the job is resumed; nil retries all that didn't succeed
+	resumeFilter *ResumeFilter
 
 	// only a single instance of the prompter is needed for all transfers
 	overwritePrompter *overwritePrompter
@@ -336,6 +386,13 @@ type jobMgr struct {
 	fileCountLimiter    common.CacheLimiter
 	jstm                *jobStatusManager
 
+	// holds the workers back while the job is paused or draining
+	gate *TransferGate
+	// holds new transfers back at the gate when too many have failed
+	breaker *circuitBreaker
+	// holds the workers back at the gate, or cancels the job, when downloads would fill the disk
+	diskSpace *diskSpaceGuard
+
 	isDaemon bool /* is it running as service */
 }
 
@@ -448,6 +505,7 @@ func (jm *jobMgr) AddJobPart(args *AddJobPartArgs) IJobPartMgr {
 		fileCountLimiter:  jm.fileCountLimiter,
 		closeOnCompletion: args.CompletionChan,
 		srcIsOAuth:        args.SrcIsOAuth,
+		keyWrapperMutex:   &sync.Mutex{},
 	}
 	// If an existing plan MMF was supplied, re use it. Otherwise, init a new one.
 	if args.ExistingPlanMMF == nil {
@@ -501,6 +559,7 @@ func (jm *jobMgr) AddJobOrder(order common.CopyJobPartOrderRequest) IJobPartMgr
 		fileCountLimiter: jm.fileCountLimiter,
 		credInfo:         order.CredentialInfo,
 		srcIsOAuth:       order.S2SSourceCredentialType.IsAzureOAuth(),
+		keyWrapperMutex:  &sync.Mutex{},
 	}
 	jpm.planMMF = jpm.filename.Map()
 	jm.jobPartMgrs.Set(order.PartNum, jpm)
@@ -555,7 +614,7 @@ func (jm *jobMgr) setDirection(fromTo common.FromTo) {
 	if fromTo.IsUpload() {
 		jm.atomicTransferDirection.AtomicStore(common.ETransferDirection.Upload())
 	}
-	if fromTo.IsDownload() {
+	if fromTo.IsDownload() || fromTo == common.EFromTo.LocalLocal() {
 		jm.atomicTransferDirection.AtomicStore(common.ETransferDirection.Download())
 		jm.RequestTuneSlowly()
 	}
@@ -578,16 +637,14 @@ func (jm *jobMgr) PipelineNetworkStats() *PipelineNetworkStats {
 	return jm.pipelineNetworkStats
 }
 
-// SetIncludeExclude sets the include / exclude list of transfers
-// supplied with resume command to include or exclude mentioned transfers
-func (jm *jobMgr) SetIncludeExclude(include, exclude map[string]int) {
-	jm.include = include
-	jm.exclude = exclude
+// SetResumeFilter sets which transfers are retried when the job is resumed, as supplied with the resume command
+func (jm *jobMgr) SetResumeFilter(filter *ResumeFilter) {
+	jm.resumeFilter = filter
 }
 
-// Returns the list of transfer mentioned to include / exclude
-func (jm *jobMgr) IncludeExclude() (map[string]int, map[string]int) {
-	return jm.include, jm.exclude
+// ResumeFilter returns which transfers are retried when the job is resumed
+func (jm *jobMgr) ResumeFilter() *ResumeFilter {
+	return jm.resumeFilter
 }
 
 // ScheduleTransfers schedules this job part's transfers. It is called when a new job part is ordered & is also called to resume a paused Job
@@ -598,7 +655,6 @@ func (jm *jobMgr) ResumeTransfers(appCtx context.Context) {
 	jm.ResetAllTransfersScheduled()
 	jm.jobPartMgrs.Iterate(false, func(p common.PartNumber, jpm IJobPartMgr) {
 		jm.QueueJobParts(jpm)
-		// jpm.ScheduleTransfers(jm.ctx, includeTransfer, excludeTransfer)
 	})
 }
 
@@ -623,6 +679,8 @@ func (jm *jobMgr) ResetFailedTransfersCount() {
 	// Reset job summary in status manager
 	summaryResp := jm.ListJobSummary()
 	summaryResp.TransfersFailed = 0
+	summaryResp.TransfersFailedAuth = 0
+	summaryResp.TransfersFailedNetwork = 0
 	summaryResp.FailedTransfers = []common.TransferDetail{}
 	summaryResp.TotalBytesExpected = totalBytesExpected
 
@@ -736,6 +794,9 @@ func (jm *jobMgr) reportJobPartDoneHandler() {
 						jobProgressInfo.transfersCompleted > 0))
 				}
 
+				jm.jobSpan.SetAttributes(attribute.String("azcopy.status", part0Plan.JobStatus().String()))
+				jm.jobSpan.End()
+
 				// reset counters
 				atomic.StoreUint32(&jm.partsDone, 0)
 				jobProgressInfo = jobPartProgressInfo{}
@@ -981,13 +1042,17 @@ func (jm *jobMgr) poolSizer() {
 			throughputMonitoringInterval = expandedMonitoringInterval
 			slowTuneCh = nil // so we won't keep running this case at the expense of others)
 		case <-time.After(throughputMonitoringInterval):
-			if targetConcurrency != 0 && actualConcurrency == targetConcurrency { // scalebacks can take time. Don't want to do any tuning if actual is not yet aligned to target
+			if jm.gate.Paused() {
+				// throughput measured across a pause says nothing about the network, so start afresh once we resume
+				hasHadTimeToStablize = false
+			} else if targetConcurrency != 0 && actualConcurrency == targetConcurrency { // scalebacks can take time. Don't want to do any tuning if actual is not yet aligned to target
 				bytesOnWire := jm.pacer.GetTotalTraffic()
 				if hasHadTimeToStablize {
 					// throughput has had time to stabilize since last change, so we can meaningfully measure and act on throughput
 					elapsedSeconds := time.Since(lastBytesTime).Seconds()
 					bytes := bytesOnWire - lastBytesOnWire
 					megabitsPerSec := (8 * float64(bytes) / elapsedSeconds) / (1000 * 1000)
+					atomic.StoreInt64(&jm.atomicConnectionThroughput, int64(float64(bytes)/elapsedSeconds)/int64(actualConcurrency))
 					if megabitsPerSec > 4000 {
 						throughputMonitoringInterval = expandedMonitoringInterval // start averaging throughputs over longer time period, since in some tests it takes a little longer to get a good average
 					}
@@ -1050,6 +1115,17 @@ func (jm *jobMgr) chunkProcessor(workerID int) {
 		case <-jm.poolSizingChannels.scalebackRequestCh:
 			return
 		default:
+			if held := jm.gate.chunksHeld(); held != nil && jm.Context().Err() == nil {
+				// leave the chunks queued until we're resumed. Once the job is cancelled though, we drain them as usual,
+				// since they'll just be cancelled straight away
+				select {
+				case <-held:
+				case <-jm.Context().Done():
+				case <-jm.poolSizingChannels.scalebackRequestCh:
+					return
+				}
+				continue
+			}
 			select {
 			case chunkFunc := <-jm.xferChannels.normalChunckCh:
 				chunkFunc(workerID)
@@ -1082,11 +1158,27 @@ func (jm *jobMgr) transferProcessor(workerID int) {
 		} else {
 			// TODO fix preceding space
 			jptm.Log(common.LogDebug, fmt.Sprintf("has worker %d which is processing TRANSFER %d", workerID, jptm.(*jobPartTransferMgr).transferIndex))
+			jm.reportTransferInFlight(jptm)
+			jptm.(*jobPartTransferMgr).startTime = time.Now()
+			startFileSpan(jptm.(*jobPartTransferMgr))
 			jptm.StartJobXfer()
 		}
 	}
 
 	for {
+		// No new transfers are started while paused or draining. Once the job is cancelled though, the queued ones are
+		// picked up as usual, since they'll just be cancelled straight away
+		if held := jm.gate.transfersHeld(); held != nil && jm.Context().Err() == nil {
+			select {
+			case <-held:
+			case <-jm.Context().Done():
+			case <-jm.xferChannels.closeTransferCh:
+				jm.Log(common.LogInfo, "transferProcessor done called")
+				return
+			}
+			continue
+		}
+
 		// No scaleback check here, because this routine runs only in a small number of goroutines, so no need to kill them off
 		select {
 		case <-jm.xferChannels.closeTransferCh:
@@ -1111,6 +1203,38 @@ func (jm *jobMgr) IterateJobParts(readonly bool, f func(k common.PartNumber, v I
 	jm.jobPartMgrs.Iterate(readonly, f)
 }
 
+func (jm *jobMgr) reportTransferInFlight(jptm IJobPartTransferMgr) {
+	atomic.AddInt64(&jm.atomicTransfersInFlight, 1)
+	atomic.StoreUint32(&jptm.(*jobPartTransferMgr).atomicInFlightIndicator, 1)
+}
+
+// TransferGate returns what pauses and drains the job's transfers
+func (jm *jobMgr) TransferGate() *TransferGate {
+	return jm.gate
+}
+
+// TransfersInFlight returns the number of transfers that have been started and are not yet done
+func (jm *jobMgr) TransfersInFlight() int64 {
+	return atomic.LoadInt64(&jm.atomicTransfersInFlight)
+}
+
+// ConnectionThroughput is how many bytes per second each of the job's connections most recently sent or received, or
+// 0 before it has been measured
+func (jm *jobMgr) ConnectionThroughput() int64 {
+	return atomic.LoadInt64(&jm.atomicConnectionThroughput)
+}
+
+// FlushPlans writes the in-memory state of every part's plan file to disk
+func (jm *jobMgr) FlushPlans() error {
+	var firstErr error
+	jm.IterateJobParts(true, func(k common.PartNumber, v IJobPartMgr) {
+		if err := v.FlushPlan(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	})
+	return firstErr
+}
+
 func (jm *jobMgr) TransferDirection() common.TransferDirection {
 	return jm.atomicTransferDirection.AtomicLoad()
 }
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,18 +28,27 @@ var DebugSkipFiles = make(map[string]bool)
 
 type IJobPartMgr interface {
 	Plan() *JobPartPlanHeader
+	FlushPlan() error
 	ScheduleTransfers(jobCtx context.Context)
 	StartJobXfer(jptm IJobPartTransferMgr)
 	ReportTransferDone(status common.TransferStatus) uint32
 	GetOverwriteOption() common.OverwriteOption
 	GetForceIfReadOnly() bool
 	AutoDecompress() bool
+	KeepCompressed() bool
+	ExtractArchivePacks() bool
+	Untar() bool
+	Unzip() bool
+	UploadCompression() common.CompressionType
+	ClientSideKeyWrapper(ctx context.Context) (common.KeyWrapper, error)
 	ScheduleChunks(chunkFunc chunkFunc)
 	RescheduleTransfer(jptm IJobPartTransferMgr)
 	BlobTypeOverride() common.BlobType
 	BlobTiers() (blockBlobTier common.BlockBlobTier, pageBlobTier common.PageBlobTier)
 	ShouldPutMd5() bool
 	DeleteDestinationFileIfNecessary() bool
+	BlockDelta() bool
+	ContentDefinedBlocks() bool
 	SAS() (string, string)
 	// CancelJob()
 	Close()
@@ -80,22 +90,23 @@ type IJobPartMgr interface {
 // 'ulimit -Hn' is low).
 func NewAzcopyHTTPClient(maxIdleConns int) *http.Client {
 	const concurrentDialsPerCpu = 10 // exact value doesn't matter too much, but too low will be too slow, and too high will reduce the beneficial effect on thread count
-	return &http.Client{
-		Transport: &http.Transport{
-			Proxy:                  common.GlobalProxyLookup,
-			MaxConnsPerHost:        concurrentDialsPerCpu * runtime.NumCPU(),
-			MaxIdleConns:           0, // No limit
-			MaxIdleConnsPerHost:    maxIdleConns,
-			IdleConnTimeout:        180 * time.Second,
-			TLSHandshakeTimeout:    10 * time.Second,
-			ExpectContinueTimeout:  1 * time.Second,
-			DisableKeepAlives:      false,
-			DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
-			MaxResponseHeaderBytes: 0,
-			// ResponseHeaderTimeout:  time.Duration{},
-			// ExpectContinueTimeout:  time.Duration{},
-		},
+	transport := &http.Transport{
+		Proxy:                  common.GlobalProxyLookup,
+		MaxConnsPerHost:        concurrentDialsPerCpu * runtime.NumCPU(),
+		MaxIdleConns:           0, // No limit
+		MaxIdleConnsPerHost:    maxIdleConns,
+		IdleConnTimeout:        180 * time.Second,
+		TLSHandshakeTimeout:    10 * time.Second,
+		ExpectContinueTimeout:  1 * time.Second,
+		DisableKeepAlives:      false,
+		DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
+		MaxResponseHeaderBytes: 0,
+		// ResponseHeaderTimeout:  time.Duration{},
+		// ExpectContinueTimeout:  time.Duration{},
 	}
+	settings := transportSettings()
+	settings.apply(transport)
+	return &http.Client{Transport: settings.roundTripper(transport)}
 }
 
 func NewClientOptions(retry policy.RetryOptions, telemetry policy.TelemetryOptions, transport policy.Transporter, log LogOptions, srcCred *common.ScopedToken, dstCred *common.ScopedAuthenticator) azcore.ClientOptions {
@@ -103,13 +114,21 @@ func NewClientOptions(retry policy.RetryOptions, telemetry policy.TelemetryOptio
 	// [includeResponsePolicy, newAPIVersionPolicy (ignored), NewTelemetryPolicy, perCall, NewRetryPolicy, perRetry, NewLogPolicy, httpHeaderPolicy, bodyDownloadPolicy]
 	perCallPolicies := []policy.Policy{azruntime.NewRequestIDPolicy(), NewVersionPolicy(), newFileUploadRangeFromURLFixPolicy()}
 	// TODO : Default logging policy is not equivalent to old one. tracing HTTP request
-	perRetryPolicies := []policy.Policy{newRetryNotificationPolicy(), newLogPolicy(log), newStatsPolicy()}
+	perRetryPolicies := []policy.Policy{newRetryBackoffPolicy(true), newRetryNotificationPolicy(), newLogPolicy(log), newStatsPolicy()}
 	if dstCred != nil {
 		perCallPolicies = append(perRetryPolicies, NewDestReauthPolicy(dstCred))
 	}
 	if srcCred != nil {
 		perRetryPolicies = append(perRetryPolicies, NewSourceAuthPolicy(srcCred))
 	}
+	// a transfer's retries are the tries of its requests less the requests themselves
+	perCallPolicies = append(perCallPolicies, newRequestCountPolicy(false))
+	perRetryPolicies = append(perRetryPolicies, newRequestCountPolicy(true))
+	perCallPolicies = append(perCallPolicies, newAccountLimitPolicy(false))
+	perCallPolicies = append(perCallPolicies, newRetryBackoffPolicy(false))
+	perRetryPolicies = append(perRetryPolicies, newAccountLimitPolicy(true), newThrottleBackoffPolicy())
+	// last, so that it sees the transport's reads of the bodies
+	perRetryPolicies = append(perRetryPolicies, newStallPolicy())
 	retry.ShouldRetry = GetShouldRetry(&log)
 
 	return azcore.ClientOptions{
@@ -159,6 +178,9 @@ type jobPartMgr struct {
 	credInfo   common.CredentialInfo
 	srcIsOAuth bool // true if source is authenticated via oauth
 	credOption *common.CredentialOpOptions
+
+	keyWrapperMutex *sync.Mutex
+	keyWrapper      common.KeyWrapper // for client-side encryption; see ClientSideKeyWrapper
 	// When the part is schedule to run (inprogress), the below fields are used
 	planMMF *JobPartPlanMMF // This Job part plan's MMF
 
@@ -176,6 +198,9 @@ type jobPartMgr struct {
 
 	deleteDestinationFileIfNecessary bool
 
+	blockDelta           bool
+	contentDefinedBlocks bool
+
 	metadata common.Metadata
 
 	blobTags common.BlobTags
@@ -229,6 +254,10 @@ func (jpm *jobPartMgr) Plan() *JobPartPlanHeader {
 	return jpm.planMMF.Plan()
 }
 
+func (jpm *jobPartMgr) FlushPlan() error {
+	return jpm.planMMF.Flush()
+}
+
 // ScheduleTransfers schedules this job part's transfers. It is called when a new job part is ordered & is also called to resume a paused Job
 func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	jobCtx = context.WithValue(jobCtx, ServiceAPIVersionOverride, DefaultServiceApiVersion)
@@ -243,11 +272,8 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 		return
 	}
 
-	// get the list of include / exclude transfers
-	includeTransfer, excludeTransfer := jpm.jobMgr.IncludeExclude()
-	if len(includeTransfer) > 0 || len(excludeTransfer) > 0 {
-		panic("List of transfers is obsolete.")
-	}
+	// which transfers a resume is to retry
+	resumeFilter := jpm.jobMgr.ResumeFilter()
 
 	// *** Open the job part: process any job part plan-setting used by all transfers ***
 	dstData := plan.DstBlobData
@@ -264,6 +290,8 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	jpm.blockBlobTier = dstData.BlockBlobTier
 	jpm.pageBlobTier = dstData.PageBlobTier
 	jpm.deleteDestinationFileIfNecessary = dstData.DeleteDestinationFileIfNecessary
+	jpm.blockDelta = dstData.BlockDelta
+	jpm.contentDefinedBlocks = dstData.ContentDefinedBlocks
 
 	// For this job part, split the metadata string apart and create an common.Metadata out of it
 	metadataString := string(dstData.Metadata[:dstData.MetadataLength])
@@ -313,6 +341,11 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			continue
 		}
 
+		if !resumeFilter.ShouldResume(plan, t) {
+			jpm.leaveTransfer(plan, t, ts)
+			continue
+		}
+
 		// If the transfer was failed, then while rescheduling the transfer marking it Started.
 		if ts == common.ETransferStatus.Failed() {
 			jppt.SetTransferStatus(common.ETransferStatus.Restarted(), true)
@@ -341,6 +374,9 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 		transferCtx, transferCancel := context.WithCancel(jobCtx)
 		// Add the pipeline network stats to the context. This will be manually unset for all sourceInfoProvider contexts.
 		transferCtx = withPipelineNetworkStats(transferCtx, jpm.jobMgr.PipelineNetworkStats())
+		// and count the transfer's requests, for the transfer report
+		counts := &requestCounts{}
+		transferCtx = withRequestCounts(transferCtx, counts)
 		// Initialize a job part transfer manager
 		jptm := &jobPartTransferMgr{
 			jobPartMgr:          jpm,
@@ -348,6 +384,7 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 			transferIndex:       t,
 			ctx:                 transferCtx,
 			cancel:              transferCancel,
+			requestCounts:       counts,
 			// TODO: insert the factory func interface in jptm.
 			// numChunks will be set by the transfer's prologue method
 		}
@@ -403,6 +440,32 @@ func (jpm *jobPartMgr) ScheduleTransfers(jobCtx context.Context) {
 	}
 }
 
+// leaveTransfer counts a transfer that this resume isn't retrying as done, with the status it already had, so that the
+// part can still finish. The transfer itself is left as it was, so that a later resume can retry it.
+func (jpm *jobPartMgr) leaveTransfer(plan *JobPartPlanHeader, transferIndex uint32, status common.TransferStatus) {
+	switch status {
+	case common.ETransferStatus.Failed(),
+		common.ETransferStatus.BlobTierFailure(),
+		common.ETransferStatus.TierAvailabilityCheckFailure():
+		// the resume cleared the job's failures, expecting them to be retried, so this one is reported again
+		src, dst, isFolder := plan.TransferSrcDstStrings(transferIndex)
+		jppt := plan.Transfer(transferIndex)
+		jpm.SendXferDoneMsg(xferDoneMsg{Src: src,
+			Dst:                dst,
+			IsFolderProperties: isFolder,
+			TransferStatus:     status,
+			TransferSize:       uint64(jppt.SourceSize),
+			ErrorCode:          jppt.ErrorCode(),
+		})
+	case common.ETransferStatus.SkippedEntityAlreadyExists(),
+		common.ETransferStatus.SkippedBlobHasSnapshots():
+	default:
+		// neither done nor failed, so it counts towards neither
+		status = common.ETransferStatus.Cancelled()
+	}
+	jpm.ReportTransferDone(status)
+}
+
 func (jpm *jobPartMgr) ScheduleChunks(chunkFunc chunkFunc) {
 	jpm.jobMgr.ScheduleChunk(jpm.priority, chunkFunc)
 }
@@ -461,6 +524,48 @@ func (jpm *jobPartMgr) AutoDecompress() bool {
 	return jpm.Plan().AutoDecompress
 }
 
+func (jpm *jobPartMgr) KeepCompressed() bool {
+	return jpm.Plan().KeepCompressed
+}
+
+func (jpm *jobPartMgr) ExtractArchivePacks() bool {
+	return jpm.Plan().ExtractArchivePacks
+}
+
+func (jpm *jobPartMgr) Untar() bool {
+	return jpm.Plan().Untar
+}
+
+func (jpm *jobPartMgr) Unzip() bool {
+	return jpm.Plan().Unzip
+}
+
+func (jpm *jobPartMgr) UploadCompression() common.CompressionType {
+	return jpm.Plan().UploadCompression
+}
+
+// ClientSideKeyWrapper returns the wrapper for the key that blobs are encrypted with on the client, or nil if they
+// aren't. A Key Vault key is fetched the first time it's asked for, and signed in to with the job's OAuth token. Failures
+// aren't kept, so that a transfer that fails to fetch it doesn't fail those after it.
+func (jpm *jobPartMgr) ClientSideKeyWrapper(ctx context.Context) (common.KeyWrapper, error) {
+	plan := jpm.Plan()
+	key := string(plan.ClientSideKey[:plan.ClientSideKeyLength])
+	if key == "" {
+		return nil, nil
+	}
+
+	jpm.keyWrapperMutex.Lock()
+	defer jpm.keyWrapperMutex.Unlock()
+	if jpm.keyWrapper == nil {
+		wrapper, err := common.NewKeyWrapper(ctx, key, jpm.credInfo.OAuthTokenInfo.GetTokenCredential)
+		if err != nil {
+			return nil, err
+		}
+		jpm.keyWrapper = wrapper
+	}
+	return jpm.keyWrapper, nil
+}
+
 func (jpm *jobPartMgr) resourceDstData(fullFilePath string, dataFileToXfer []byte) (headers common.ResourceHTTPHeaders,
 	metadata common.Metadata, blobTags common.BlobTags, cpkOptions common.CpkOptions) {
 	if jpm.planMMF.Plan().DstBlobData.NoGuessMimeType {
@@ -551,6 +656,14 @@ func (jpm *jobPartMgr) DeleteDestinationFileIfNecessary() bool {
 	return jpm.deleteDestinationFileIfNecessary
 }
 
+func (jpm *jobPartMgr) BlockDelta() bool {
+	return jpm.blockDelta
+}
+
+func (jpm *jobPartMgr) ContentDefinedBlocks() bool {
+	return jpm.contentDefinedBlocks
+}
+
 func (jpm *jobPartMgr) SAS() (string, string) {
 	return jpm.sourceSAS, jpm.destinationSAS
 }
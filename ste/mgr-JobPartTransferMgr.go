@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -16,6 +17,8 @@ import (
 
 	"net/url"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
@@ -27,7 +30,11 @@ type IJobPartTransferMgr interface {
 	PreserveLastModifiedTime() (time.Time, bool)
 	ShouldPutMd5() bool
 	DeleteDestinationFileIfNecessary() bool
+	BlockDelta() bool
+	ContentDefinedBlocks() bool
 	MD5ValidationOption() common.HashValidationOption
+	FsyncPolicy() common.FsyncPolicy
+	ContentHashType() common.ContentHashType
 	BlobTypeOverride() common.BlobType
 	BlobTiers() (blockBlobTier common.BlockBlobTier, pageBlobTier common.PageBlobTier)
 	JobHasLowFileCount() bool
@@ -42,6 +49,11 @@ type IJobPartTransferMgr interface {
 	GetOverwriteOption() common.OverwriteOption
 	GetForceIfReadOnly() bool
 	ShouldDecompress() bool
+	ShouldExtractArchivePack() bool
+	ArchiveToExtract() common.ArchiveFormat
+	UploadCompression() common.CompressionType
+	ClientSideKeyWrapper() (common.KeyWrapper, error)
+	ShouldDecrypt() bool
 	GetSourceCompressionType() (common.CompressionType, error)
 	ReportChunkDone(id common.ChunkID) (lastChunk bool, chunksDone uint32)
 	TransferStatusIgnoringCancellation() common.TransferStatus
@@ -103,6 +115,11 @@ type IJobPartTransferMgr interface {
 	SuccessfulBytesTransferred() int64
 	TransferIndex() (partNum, transferIndex uint32)
 	RestartedTransfer() bool
+	ResumeOffset() int64
+	SetResumeOffset(offset int64)
+	ShuttingDown() bool
+	WaitForDiskSpace(size int64) error
+	CheckDiskSpace()
 }
 
 // TransferInfo is a per path object that needs to be transferred
@@ -117,6 +134,10 @@ type TransferInfo struct {
 	PreservePermissions     common.PreservePermissionsOption
 	PreserveInfo            bool
 	PreservePOSIXProperties bool
+	PreserveACLs            bool
+	PreserveExtAttrs        bool
+	PreserveFileFlags       bool
+	RestoreOwner            bool
 	BlobFSRecursiveDelete   bool
 
 	// Paths of targets excluding the container/fileshare name.
@@ -138,6 +159,8 @@ type TransferInfo struct {
 	// Blob
 	SrcBlobType    blob.BlobType   // used for both S2S and for downloads to local from blob
 	S2SSrcBlobTier blob.AccessTier // AccessTierType (string) is used to accommodate service-side support matrix change.
+	// S2SSrcEncryptionScope is the source blob's encryption scope, when the destination is to be encrypted with it
+	S2SSrcEncryptionScope string
 
 	RehydratePriority blob.RehydratePriority
 
@@ -149,6 +172,18 @@ func (i *TransferInfo) IsFilePropertiesTransfer() bool {
 	return i.EntityType == common.EEntityType.FileProperties()
 }
 
+// HardlinkTarget returns the POSIXHardlinkMeta value of a transfer standing in for a hard link, as enumerated with --hardlinks=preserve.
+func (i *TransferInfo) HardlinkTarget() (string, bool) {
+	if i.EntityType != common.EEntityType.Hardlink() {
+		return "", false
+	}
+	target, ok := common.TryReadMetadata(i.SrcMetadata, common.POSIXHardlinkMeta)
+	if !ok || target == nil {
+		return "", false
+	}
+	return *target, true
+}
+
 func (i *TransferInfo) IsFolderPropertiesTransfer() bool {
 	return i.EntityType == common.EEntityType.Folder()
 }
@@ -204,12 +239,18 @@ type jobPartTransferMgr struct {
 	// used defensively to protect against accidental double counting
 	atomicCompletionIndicator uint32
 
+	// used to show whether THIS jptm is counted in the job's transfers in flight
+	atomicInFlightIndicator uint32
+
 	// used to show whether we have started doing things that may affect the destination
 	atomicDestModifiedIndicator uint32
 
 	// used to show whether THIS jptm holds the destination lock
 	atomicDestLockHeldIndicator uint32
 
+	// used to show whether the transfer failed because of the network
+	atomicNetworkFailureIndicator uint32
+
 	jobPartMgr          IJobPartMgr // Refers to the "owning" Job Part
 	jobPartPlanTransfer *JobPartPlanTransfer
 	transferIndex       uint32
@@ -220,6 +261,16 @@ type jobPartTransferMgr struct {
 	// Call cancel to cancel the transfer
 	cancel context.CancelFunc
 
+	// the transfer's trace span, if tracing is enabled
+	span trace.Span
+
+	// when the transfer was started, which is zero if it was cancelled before it was
+	startTime time.Time
+
+	// the transfer's requests and their tries, and why it failed, for the transfer report
+	requestCounts  *requestCounts
+	failureMessage atomic.Pointer[string]
+
 	numChunks uint32
 
 	transferInfo *TransferInfo
@@ -256,14 +307,62 @@ func (jptm *jobPartTransferMgr) GetForceIfReadOnly() bool {
 	return jptm.jobPartMgr.GetForceIfReadOnly()
 }
 
+// ShouldDecompress says whether the download is to be decompressed as it is saved. Blobs that AzCopy compressed as
+// it uploaded them are, unless asked not to be; others only are if asked to be.
 func (jptm *jobPartTransferMgr) ShouldDecompress() bool {
-	if jptm.jobPartMgr.AutoDecompress() {
+	_, compressedByUpload := common.TryReadMetadata(jptm.Info().SrcMetadata, common.UncompressedSizeMeta)
+	if jptm.jobPartMgr.AutoDecompress() || compressedByUpload && !jptm.jobPartMgr.KeepCompressed() {
 		ct, _ := jptm.GetSourceCompressionType()
 		return ct != common.ECompressionType.None()
 	}
 	return false
 }
 
+// ShouldExtractArchivePack says whether this transfer is the download of an archive pack that is to be extracted
+func (jptm *jobPartTransferMgr) ShouldExtractArchivePack() bool {
+	if jptm.jobPartMgr.ExtractArchivePacks() {
+		_, _, isPack := common.ParseArchivePackName(filepath.Base(jptm.Info().Destination))
+		return isPack
+	}
+	return false
+}
+
+// ArchiveToExtract returns the format of the archive this transfer downloads, if it is to be extracted rather than
+// saved, or None if it isn't
+func (jptm *jobPartTransferMgr) ArchiveToExtract() common.ArchiveFormat {
+	if jptm.ShouldExtractArchivePack() {
+		return common.EArchiveFormat.Tar()
+	}
+	switch format := common.GetArchiveFormat(jptm.Info().Destination); format {
+	case common.EArchiveFormat.Tar(), common.EArchiveFormat.TarGzip():
+		return common.Iff(jptm.jobPartMgr.Untar(), format, common.EArchiveFormat.None())
+	case common.EArchiveFormat.Zip():
+		return common.Iff(jptm.jobPartMgr.Unzip(), format, common.EArchiveFormat.None())
+	}
+	return common.EArchiveFormat.None()
+}
+
+// UploadCompression returns the compression to apply to the file this transfer uploads. An empty file is never
+// compressed, since it is uploaded without any chunks to compress.
+func (jptm *jobPartTransferMgr) UploadCompression() common.CompressionType {
+	if jptm.Info().SourceSize == 0 {
+		return common.ECompressionType.None()
+	}
+	return jptm.jobPartMgr.UploadCompression()
+}
+
+// ClientSideKeyWrapper returns the wrapper for the key that blobs are encrypted with on the client, or nil if they aren't
+func (jptm *jobPartTransferMgr) ClientSideKeyWrapper() (common.KeyWrapper, error) {
+	return jptm.jobPartMgr.ClientSideKeyWrapper(jptm.Context())
+}
+
+// ShouldDecrypt says whether the download is of a blob that was encrypted on the client, and is to be decrypted as it
+// is saved. Blobs are only decrypted when a key is given to decrypt them with; otherwise they are saved as they are.
+func (jptm *jobPartTransferMgr) ShouldDecrypt() bool {
+	_, encrypted := common.TryReadMetadata(jptm.Info().SrcMetadata, common.ClientSideEncryptionMeta)
+	return encrypted && jptm.jobPartMgr.Plan().ClientSideKeyLength > 0
+}
+
 func (jptm *jobPartTransferMgr) GetSourceCompressionType() (common.CompressionType, error) {
 	encoding := jptm.Info().SrcHTTPHeaders.ContentEncoding
 	return common.GetCompressionType(encoding)
@@ -295,8 +394,11 @@ func (jptm *jobPartTransferMgr) Info() *TransferInfo {
 		}
 	}
 
-	srcHTTPHeaders, srcMetadata, srcBlobType, srcBlobTier, s2sGetPropertiesInBackend, DestLengthValidation, s2sSourceChangeValidation, s2sInvalidMetadataHandleOption, entityType, versionID, snapshotID, blobTags :=
+	srcHTTPHeaders, srcMetadata, srcBlobType, srcBlobTier, s2sGetPropertiesInBackend, DestLengthValidation, s2sSourceChangeValidation, s2sInvalidMetadataHandleOption, entityType, versionID, snapshotID, blobTags, srcEncryptionScope :=
 		plan.TransferSrcPropertiesAndMetadata(jptm.transferIndex)
+	if !plan.S2SPreserveEncryptionScope {
+		srcEncryptionScope = ""
+	}
 	srcSAS, dstSAS := jptm.jobPartMgr.SAS()
 	// If the length of destination SAS is greater than 0
 	// it means the destination is remote url and destination SAS
@@ -360,26 +462,27 @@ func (jptm *jobPartTransferMgr) Info() *TransferInfo {
 		srcURI = sURL.String()
 	}
 
-	sourceSize := plan.Transfer(jptm.transferIndex).SourceSize
+	transfer := plan.Transfer(jptm.transferIndex)
+	sourceSize := transfer.SourceSize
 	var blockSize = dstBlobData.BlockSize
-	// If the blockSize is 0, then User didn't provide any blockSize
-	// We need to set the blockSize in such way that number of blocks per blob
-	// does not exceeds 50000 (max number of block per blob)
+	// If the blockSize is 0, then User didn't provide any blockSize, so we choose one for this file, and keep it in the
+	// plan, since the blocks that a resumed transfer has already sent must be the same size as those still to send
 	if blockSize == 0 {
-		blockSize = common.DefaultBlockBlobBlockSize
-		for ; sourceSize >= common.MaxNumberOfBlocksPerBlob*blockSize; blockSize = 2 * blockSize {
-			if blockSize > common.BlockSizeThreshold {
-				/*
-				 * For a RAM usage of 0.5G/core, we would have 4G memory on typical 8 core device, meaning at a blockSize of 256M,
-				 * we can have 4 blocks in core, waiting for a disk or n/w operation. Any higher block size would *sort of*
-				 * serialize n/w and disk operations, and is better avoided.
-				 */
-				if sourceSize%common.MaxNumberOfBlocksPerBlob == 0 {
-					blockSize = sourceSize / common.MaxNumberOfBlocksPerBlob
-				} else {
-					blockSize = sourceSize/common.MaxNumberOfBlocksPerBlob + 1
+		if blockSize = transfer.BlockSize(); blockSize == 0 {
+			// A delta upload's blocks must fall where the last upload's did, so its block size depends on nothing but
+			// the size of the file
+			var connectionThroughput, memoryLimit int64
+			if !dstBlobData.BlockDelta {
+				connectionThroughput = jptm.jobPartMgr.(*jobPartMgr).jobMgr.ConnectionThroughput()
+				if limiter := jptm.jobPartMgr.CacheLimiter(); limiter != nil {
+					memoryLimit = limiter.Limit()
 				}
-				break
+			}
+			blockSize = chooseBlockSize(sourceSize, connectionThroughput, memoryLimit)
+			transfer.SetBlockSize(blockSize)
+			if blockSize != common.DefaultBlockBlobBlockSize {
+				jptm.Log(common.LogDebug, fmt.Sprintf("Chose a block size of %d for %d bytes, at %d bytes per second per connection",
+					blockSize, sourceSize, connectionThroughput))
 			}
 		}
 	}
@@ -423,6 +526,10 @@ func (jptm *jobPartTransferMgr) Info() *TransferInfo {
 		PreservePermissions:            plan.PreservePermissions,
 		PreserveInfo:                   plan.PreserveInfo,
 		PreservePOSIXProperties:        plan.PreservePOSIXProperties,
+		PreserveACLs:                   plan.PreserveACLs,
+		PreserveExtAttrs:               plan.PreserveExtAttrs,
+		PreserveFileFlags:              plan.PreserveFileFlags,
+		RestoreOwner:                   plan.RestoreOwner,
 		S2SGetPropertiesInBackend:      s2sGetPropertiesInBackend,
 		S2SSourceChangeValidation:      s2sSourceChangeValidation,
 		S2SInvalidMetadataHandleOption: s2sInvalidMetadataHandleOption,
@@ -433,11 +540,12 @@ func (jptm *jobPartTransferMgr) Info() *TransferInfo {
 			SrcMetadata:    srcMetadata,
 			SrcBlobTags:    srcBlobTags,
 		},
-		SrcBlobType:       srcBlobType,
-		S2SSrcBlobTier:    srcBlobTier,
-		RehydratePriority: plan.RehydratePriority.ToRehydratePriorityType(),
-		VersionID:         versionID,
-		SnapshotID:        snapshotID,
+		SrcBlobType:           srcBlobType,
+		S2SSrcBlobTier:        srcBlobTier,
+		S2SSrcEncryptionScope: srcEncryptionScope,
+		RehydratePriority:     plan.RehydratePriority.ToRehydratePriorityType(),
+		VersionID:             versionID,
+		SnapshotID:            snapshotID,
 	}
 }
 
@@ -521,8 +629,9 @@ func (jptm *jobPartTransferMgr) HoldsDestinationLock() bool {
 }
 
 func (jptm *jobPartTransferMgr) useFileCountLimiter() bool {
-	ft := jptm.FromTo()    // TODO: consider changing isDownload (and co) to have struct receiver instead of pointer receiver, so don't need variable like this
-	return ft.IsDownload() // count-based limits are only applied for download a present
+	ft := jptm.FromTo() // TODO: consider changing isDownload (and co) to have struct receiver instead of pointer receiver, so don't need variable like this
+	// count-based limits are only applied for downloads (and local copies, which work the same way) at present
+	return ft.IsDownload() || ft == common.EFromTo.LocalLocal()
 }
 
 func (jptm *jobPartTransferMgr) RescheduleTransfer() {
@@ -560,10 +669,33 @@ func (jptm *jobPartTransferMgr) DeleteDestinationFileIfNecessary() bool {
 	return jptm.jobPartMgr.DeleteDestinationFileIfNecessary()
 }
 
+// BlockDelta is whether this transfer names the blocks it uploads by their content, and sends only those the
+// destination blob doesn't have already
+func (jptm *jobPartTransferMgr) BlockDelta() bool {
+	return jptm.jobPartMgr.BlockDelta()
+}
+
+// ContentDefinedBlocks is whether this transfer splits the file it uploads into blocks where its content says, instead
+// of every BlockSize bytes, so that bytes inserted or removed only change the blocks they're in
+func (jptm *jobPartTransferMgr) ContentDefinedBlocks() bool {
+	return jptm.jobPartMgr.ContentDefinedBlocks()
+}
+
 func (jptm *jobPartTransferMgr) MD5ValidationOption() common.HashValidationOption {
 	return jptm.jobPartMgr.(*jobPartMgr).localDstData().MD5VerificationOption
 }
 
+// FsyncPolicy is when a downloaded file, and the directory it's saved in, are synced to disk
+func (jptm *jobPartTransferMgr) FsyncPolicy() common.FsyncPolicy {
+	return jptm.jobPartMgr.(*jobPartMgr).localDstData().Fsync
+}
+
+// ContentHashType is the hash that ShouldPutMd5 puts, and MD5ValidationOption checks. MD5 is stored in Content-MD5, and
+// the others in metadata.
+func (jptm *jobPartTransferMgr) ContentHashType() common.ContentHashType {
+	return jptm.jobPartMgr.Plan().ContentHashType
+}
+
 func (jptm *jobPartTransferMgr) DeleteSnapshotsOption() common.DeleteSnapshotsOption {
 	return jptm.jobPartMgr.(*jobPartMgr).deleteSnapshotsOption()
 }
@@ -584,7 +716,12 @@ func (jptm *jobPartTransferMgr) CpkInfo() *blob.CPKInfo {
 	return jptm.jobPartMgr.CpkInfo()
 }
 
+// CpkScopeInfo is the encryption scope the destination is written with: the source's, when it is being preserved and the
+// source has one, or else the job's
 func (jptm *jobPartTransferMgr) CpkScopeInfo() *blob.CPKScopeInfo {
+	if scope := jptm.Info().S2SSrcEncryptionScope; scope != "" {
+		return &blob.CPKScopeInfo{EncryptionScope: &scope}
+	}
 	return jptm.jobPartMgr.CpkScopeInfo()
 }
 
@@ -610,6 +747,41 @@ func (jptm *jobPartTransferMgr) RestartedTransfer() bool {
 		jptm.TransferStatusIgnoringCancellation() == common.ETransferStatus.Restarted())
 }
 
+// ResumeOffset returns how much of the destination file is known to have been saved, by this run or an earlier one
+func (jptm *jobPartTransferMgr) ResumeOffset() int64 {
+	return jptm.jobPartPlanTransfer.SavedOffset()
+}
+
+// SetResumeOffset records, in the plan, how much of the destination file has been saved
+func (jptm *jobPartTransferMgr) SetResumeOffset(offset int64) {
+	jptm.jobPartPlanTransfer.SetSavedOffset(offset)
+}
+
+// ShuttingDown reports whether the job is being shut down cleanly, in which case a cancelled transfer may be
+// checkpointed so that it can carry on from where it got to when the job is resumed
+func (jptm *jobPartTransferMgr) ShuttingDown() bool {
+	return jptm.jobPartMgr.(*jobPartMgr).jobMgr.TransferGate().Draining()
+}
+
+// WaitForDiskSpace waits until the destination's file system has room for size more bytes, on top of the space that
+// downloads are to leave free, or returns why the job has been cancelled instead
+func (jptm *jobPartTransferMgr) WaitForDiskSpace(size int64) error {
+	if strings.EqualFold(jptm.Info().Destination, common.Dev_Null) {
+		return nil
+	}
+	guard := jptm.jobPartMgr.(*jobPartMgr).jobMgr.(*jobMgr).diskSpace
+	return guard.beforeDownload(jptm.Context(), jptm.Info().Destination, uint64(size))
+}
+
+// CheckDiskSpace checks, every so often, that downloads haven't used up the space they are to leave free on the
+// destination's file system
+func (jptm *jobPartTransferMgr) CheckDiskSpace() {
+	if strings.EqualFold(jptm.Info().Destination, common.Dev_Null) {
+		return
+	}
+	jptm.jobPartMgr.(*jobPartMgr).jobMgr.(*jobMgr).diskSpace.whileDownloading(jptm.Info().Destination)
+}
+
 // JobHasLowFileCount returns an estimate of whether we only have a very small number of files in the overall job
 // (An "estimate" because it actually only looks at the current job part)
 func (jptm *jobPartTransferMgr) JobHasLowFileCount() bool {
@@ -844,14 +1016,19 @@ func (jptm *jobPartTransferMgr) failActiveTransfer(typ transferErrorCode, descri
 
 		if serviceCode == common.CPK_ERROR_SERVICE_CODE {
 			cpkAccessFailureLogGLCM.Do(func() {
-				common.GetLifecycleMgr().Info("One or more transfers have failed because AzCopy currently does not support blobs encrypted with customer provided keys (CPK). " +
-					"If you wish to access CPK-encrypted blobs, we recommend using one of the Azure Storage SDKs to do so.")
+				common.GetLifecycleMgr().Info("One or more transfers have failed because the blobs are encrypted with customer provided keys (CPK). " +
+					"Provide the key with --cpk-by-value or --cpk-by-name to access them. CPK-encrypted blobs can't be the source of a service-to-service copy.")
 			})
 		}
 
 		requestID := ErrorEx{err}.MSRequestID()
 		fullMsg := fmt.Sprintf("%s. When %s. X-Ms-Request-Id: %s\n", msg, descriptionOfWhereErrorOccurred, requestID) // trailing \n to separate it better from any later, unrelated, log lines
 		jptm.logTransferError(typ, jptm.Info().Source, jptm.Info().Destination, fullMsg, status)
+		failureMessage := strings.TrimSuffix(fullMsg, "\n")
+		jptm.failureMessage.Store(&failureMessage)
+		if common.IsNetworkError(err) {
+			atomic.StoreUint32(&jptm.atomicNetworkFailureIndicator, 1)
+		}
 		jptm.SetStatus(failureStatus)
 		jptm.SetErrorCode(int32(status)) // TODO: what are the rules about when this needs to be set, and doesn't need to be (e.g. for earlier failures)?
 		// If the status code was 403, it means there was an authentication error and we exit.
@@ -998,6 +1175,23 @@ func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
 	if atomic.SwapUint32(&jptm.atomicCompletionIndicator, 1) != 0 {
 		panic("cannot report the same transfer done twice")
 	}
+	if atomic.SwapUint32(&jptm.atomicInFlightIndicator, 0) != 0 {
+		jm := jptm.jobPartMgr.(*jobPartMgr).jobMgr.(*jobMgr)
+		atomic.AddInt64(&jm.atomicTransfersInFlight, -1)
+		// only the transfers that were started count towards the circuit breaker
+		switch status := jptm.jobPartPlanTransfer.TransferStatus(); status {
+		case common.ETransferStatus.Success(), common.ETransferStatus.FolderCreated():
+			jm.breaker.record(false, "")
+		case common.ETransferStatus.Failed(), common.ETransferStatus.BlobTierFailure(), common.ETransferStatus.TierAvailabilityCheckFailure():
+			reason := status.String()
+			if msg := jptm.failureMessage.Load(); msg != nil {
+				reason = *msg
+			}
+			jm.breaker.record(true, reason)
+		}
+	}
+
+	endFileSpan(jptm)
 
 	// Update Status Manager
 	jptm.jobPartMgr.SendXferDoneMsg(xferDoneMsg{Src: jptm.Info().Source,
@@ -1006,7 +1200,9 @@ func (jptm *jobPartTransferMgr) ReportTransferDone() uint32 {
 		TransferStatus:     jptm.jobPartPlanTransfer.TransferStatus(),
 		TransferSize:       uint64(jptm.Info().SourceSize),
 		ErrorCode:          jptm.ErrorCode(),
+		IsNetworkFailure:   atomic.LoadUint32(&jptm.atomicNetworkFailureIndicator) == 1,
 	})
+	reportTransfer(jptm)
 
 	return jptm.jobPartMgr.ReportTransferDone(jptm.jobPartPlanTransfer.TransferStatus())
 }
@@ -0,0 +1,217 @@
+package ste
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpExporter sends spans to an OpenTelemetry collector using OTLP over HTTP, with the JSON encoding.
+// (We don't use the exporter from the OpenTelemetry project, to avoid its dependency on gRPC and protobuf.)
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// newOTLPExporter returns an exporter that posts to endpoint. Like OTEL_EXPORTER_OTLP_ENDPOINT, an endpoint with no
+// path gets /v1/traces appended.
+func newOTLPExporter(endpoint string, headers map[string]string) (*otlpExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid trace endpoint '%s': expected an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return &otlpExporter{endpoint: u.String(), headers: headers, client: &http.Client{}}, nil
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("trace collector at %s returned %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// The types below are the parts of OTLP's JSON encoding that we use. Note that IDs are hex, not base64, and that 64
+// bit integers are strings.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+const otlpSpanKindInternal = 1
+
+func otlpRequest(spans []sdktrace.ReadOnlySpan) otlpTraces {
+	// spans are grouped by resource, and within that by instrumentation scope, in the order each is first seen
+	var result otlpTraces
+	resourceIndex := map[attribute.Distinct]int{}
+	scopeIndex := map[attribute.Distinct]map[instrumentation.Scope]int{}
+	for _, s := range spans {
+		var resourceKey attribute.Distinct
+		var resourceAttrs []attribute.KeyValue
+		if r := s.Resource(); r != nil {
+			resourceKey = r.Equivalent()
+			resourceAttrs = r.Attributes()
+		}
+		ri, ok := resourceIndex[resourceKey]
+		if !ok {
+			ri = len(result.ResourceSpans)
+			resourceIndex[resourceKey] = ri
+			scopeIndex[resourceKey] = map[instrumentation.Scope]int{}
+			result.ResourceSpans = append(result.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: otlpAttributes(resourceAttrs)},
+			})
+		}
+		rs := &result.ResourceSpans[ri]
+
+		scope := s.InstrumentationScope()
+		si, ok := scopeIndex[resourceKey][scope]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopeIndex[resourceKey][scope] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: scope.Name, Version: scope.Version}})
+		}
+
+		span := otlpSpan{
+			TraceID:           s.SpanContext().TraceID().String(),
+			SpanID:            s.SpanContext().SpanID().String(),
+			Name:              s.Name(),
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes()),
+		}
+		if s.Parent().IsValid() {
+			span.ParentSpanID = s.Parent().SpanID().String()
+		}
+		switch s.Status().Code {
+		case codes.Ok:
+			span.Status.Code = 1
+		case codes.Error:
+			span.Status = otlpStatus{Code: 2, Message: s.Status().Description}
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, span)
+	}
+	return result
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]any
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			value = map[string]any{"boolValue": a.Value.AsBool()}
+		case attribute.INT64:
+			value = map[string]any{"intValue": strconv.FormatInt(a.Value.AsInt64(), 10)}
+		case attribute.FLOAT64:
+			value = map[string]any{"doubleValue": a.Value.AsFloat64()}
+		default:
+			value = map[string]any{"stringValue": a.Value.Emit()}
+		}
+		result = append(result, otlpKeyValue{Key: string(a.Key), Value: value})
+	}
+	return result
+}
+
+// ParseOTLPHeaders parses headers in the form used by OTEL_EXPORTER_OTLP_HEADERS, i.e. "key1=value1,key2=value2",
+// with percent-encoded values. (A '+' is a plus, not a space, as it is in a query string.)
+func ParseOTLPHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return headers, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid OTLP header '%s': expected key=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header '%s': %w", pair, err)
+		}
+		headers[strings.TrimSpace(key)] = decoded
+	}
+	return headers, nil
+}
@@ -22,11 +22,9 @@ package ste
 
 import (
 	"context"
-	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
 // pacer is used by callers whose activity must be controlled to a certain pace
@@ -53,36 +51,70 @@ type PacerAdmin interface {
 	GetTotalTraffic() int64
 }
 
-const (
-	// How long to sleep in the loop that puts tokens into the bucket
-	bucketFillSleepDuration = time.Duration(float32(time.Second) * 0.1)
-
-	// How long to sleep when reading from the bucket and finding there's not enough tokens
-	bucketDrainSleepDuration = time.Duration(float32(time.Second) * 0.333)
-
-	// Controls the max amount by which the contents of the token bucket can build up, unused.
-	maxSecondsToOverpopulateBucket = 2.5 // had 5, when doing coarse-grained pacing. TODO: find best all-round value, or parameterize
-)
+// How much the bucket holds, in seconds at the target rate, if no burst size is given
+const defaultBurstSeconds = 1.0
 
-// tokenBucketPacer allows us to control the pace of an activity, using a basic token bucket algorithm.
-// The target rate is fixed, but can be modified at any time through SetTargetBytesPerSecond
+// tokenBucketPacer allows us to control the pace of an activity, using a token bucket. Tokens accrue at the target
+// rate, up to the burst size, so that after a quiet spell that much can go at once. A request for more than the
+// bucket holds is let through once the bucket is full, and leaves it in debt, so that the cap is kept however low it
+// is compared to the size of the requests.
+//
+// Requests that have to wait are served a job at a time, in turn, so that a job with many requests waiting can't
+// crowd out another job in the same process. The target rate can be changed at any time through
+// UpdateTargetBytesPerSecond.
 type tokenBucketPacer struct {
-	atomicTokenBucket          int64
 	atomicTargetBytesPerSecond int64
 	atomicGrandTotal           int64
-	atomicWaitCount            int64
-	expectedBytesPerRequest    int64
-	newTargetBytesPerSecond    chan int64
-	done                       chan struct{}
+
+	mu         sync.Mutex
+	tokens     float64 // negative while the bucket is in debt
+	lastRefill time.Time
+	burstBytes int64                  // 0 for defaultBurstSeconds at the target rate
+	queues     map[string]*pacerQueue // the requests waiting, by the job they are for
+	turns      []*pacerQueue          // the queues that have requests waiting, in the order that they take turns
+
+	wake      chan struct{} // tells pacerBody to look at the queues again
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// pacerRequest is a request that is waiting for tokens
+type pacerRequest struct {
+	bytes   int64
+	granted chan struct{}
+	settled bool // granted, or given up on by its caller. p.mu must be held.
+}
+
+type pacerQueue struct {
+	job      string
+	requests []*pacerRequest
+}
+
+var pacerShareContextKey = contextKey{"pacerShare"}
+
+// withPacerShare returns a context whose requests to pacers wait their turn with those of the same job, so that
+// different jobs are served fairly
+func withPacerShare(ctx context.Context, job string) context.Context {
+	return context.WithValue(ctx, pacerShareContextKey, job)
 }
 
-func NewTokenBucketPacer(bytesPerSecond int64, expectedBytesPerCoarseRequest int64) *tokenBucketPacer {
-	p := &tokenBucketPacer{atomicTokenBucket: bytesPerSecond / 4, // seed it immediately with part-of-a-second's worth, to avoid a sluggish start
+func pacerShare(ctx context.Context) string {
+	job, _ := ctx.Value(pacerShareContextKey).(string)
+	return job
+}
+
+// NewTokenBucketPacer makes a pacer for bytesPerSecond, with 0 for no cap. Its bucket holds burstBytes, or, if that
+// is 0, a second's worth at the target rate.
+func NewTokenBucketPacer(bytesPerSecond int64, burstBytes int64) *tokenBucketPacer {
+	p := &tokenBucketPacer{
 		atomicTargetBytesPerSecond: bytesPerSecond,
-		expectedBytesPerRequest:    int64(expectedBytesPerCoarseRequest),
+		lastRefill:                 time.Now(),
+		burstBytes:                 burstBytes,
+		queues:                     map[string]*pacerQueue{},
+		wake:                       make(chan struct{}, 1),
 		done:                       make(chan struct{}),
-		newTargetBytesPerSecond:    make(chan int64),
 	}
+	p.tokens = p.burst() // start full, so that there's no sluggish start
 
 	go p.pacerBody()
 
@@ -92,106 +124,173 @@ func NewTokenBucketPacer(bytesPerSecond int64, expectedBytesPerCoarseRequest int
 // RequestTrafficAllocation function is called by goroutines to request right to send a certain amount of bytes.
 // It controls their rate by blocking until they are allowed to proceed
 func (p *tokenBucketPacer) RequestTrafficAllocation(ctx context.Context, byteCount int64) error {
-	targetBytes := p.targetBytesPerSecond()
 	//if targetBytesIsZero, we have a null pacer, we just track GrandTotal
-	if targetBytes == 0 {
+	if p.targetBytesPerSecond() == 0 {
 		atomic.AddInt64(&p.atomicGrandTotal, byteCount)
 		return nil
 	}
 
-	if targetBytes < byteCount {
-		return errors.New("request size greater than pacer target. ensure --block-size-mb is smaller than --cap-mbps and retry the transfer")
+	p.mu.Lock()
+	// if no one is waiting, and there are tokens enough, there's no need to queue
+	p.refill(time.Now())
+	if len(p.turns) == 0 && p.tokens >= min(float64(byteCount), p.burst()) {
+		p.take(byteCount)
+		p.mu.Unlock()
+		return nil
 	}
+	r := &pacerRequest{bytes: byteCount, granted: make(chan struct{})}
+	p.enqueue(pacerShare(ctx), r)
+	p.mu.Unlock()
+	p.poke()
 
-	// block until tokens are available
-	for atomic.AddInt64(&p.atomicTokenBucket, -byteCount) < 0 {
-
-		// by taking our desired count we've moved below zero, which means our allocation is not available
-		// right now, so put back what we asked for, and then wait
-		atomic.AddInt64(&p.atomicTokenBucket, byteCount)
-
-		// vary the wait amount, to reduce risk of any kind of pulsing or synchronization effect, without the perf and
-		// and threadsafety issues of actual random numbers
-		totalWaitsSoFar := atomic.AddInt64(&p.atomicWaitCount, 1)
-		modifiedSleepDuration := time.Duration(float32(bucketDrainSleepDuration) * (float32(totalWaitsSoFar%10) + 5) / 10) // 50 to 150% of bucketDrainSleepDuration
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(modifiedSleepDuration):
-			// keep looping
-		}
-
-		// If we've updated target to a NULL pacer, we'll return immediately
-		if p.targetBytesPerSecond() == 0 {
-			atomic.AddInt64(&p.atomicGrandTotal, byteCount)
-			return nil
+	select {
+	case <-r.granted:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if r.settled {
+			// it was granted as it was given up on, so put the tokens back
+			p.giveBack(byteCount)
 		}
-
+		r.settled = true // so that pacerBody skips it
+		return ctx.Err()
 	}
-
-	// record what we issued
-	atomic.AddInt64(&p.atomicGrandTotal, byteCount)
-
-	return nil
 }
 
 // UndoRequest allows a caller to return unused tokens
 func (p *tokenBucketPacer) UndoRequest(byteCount int64) {
 	if byteCount > 0 {
-		atomic.AddInt64(&p.atomicTokenBucket, byteCount) // put them back in the bucket
-		atomic.AddInt64(&p.atomicGrandTotal, -byteCount) // deduct them from all-time issued count
+		p.mu.Lock()
+		p.giveBack(byteCount)
+		p.mu.Unlock()
+		p.poke()
 	}
 }
 
 func (p *tokenBucketPacer) Close() error {
-	close(p.done)
+	p.closeOnce.Do(func() { close(p.done) })
 	return nil
 }
 
+// pacerBody grants waiting requests as the bucket fills, until the pacer is closed
 func (p *tokenBucketPacer) pacerBody() {
-	lastTime := time.Now()
-
-	lastTargetUpdateTime := time.Now()
-	newTarget := p.targetBytesPerSecond()
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
 	for {
+		var timeout <-chan time.Time
+		if wait := p.serve(); wait >= 0 {
+			timer.Reset(wait)
+			timeout = timer.C
+		}
 
 		select {
 		case <-p.done:
+			timer.Stop()
 			return
-		case newTarget = <-p.newTargetBytesPerSecond:
-		default:
+		case <-p.wake:
+		case <-timeout:
 		}
-
-		/*check if we have to update target rate */
-		if newTarget != p.targetBytesPerSecond() && time.Since(lastTargetUpdateTime) >= deadBandDuration {
-			p.setTargetBytesPerSecond(newTarget)
-			lastTargetUpdateTime = time.Now()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
 		}
+	}
+}
 
-		currentTarget := atomic.LoadInt64(&p.atomicTargetBytesPerSecond)
-		time.Sleep(bucketFillSleepDuration)
-		elapsedSeconds := time.Since(lastTime).Seconds()
-		bytesToRelease := int64(float64(currentTarget) * elapsedSeconds)
-		newTokenCount := atomic.AddInt64(&p.atomicTokenBucket, bytesToRelease)
-
-		// If the backlog of unsent bytes is now too great, then trim it back down.
-		// Why don't we want a big backlog? Because it limits our ability to accurately control the speed.
-		maxAllowedUnsentBytes := int64(float32(currentTarget) * maxSecondsToOverpopulateBucket)
-		if maxAllowedUnsentBytes < p.expectedBytesPerRequest {
-			maxAllowedUnsentBytes = p.expectedBytesPerRequest // just in case we are very coarse grained at a very slow speed
+// serve grants waiting requests, taking a request from each job in turn, for as long as the bucket has the tokens
+// for them. It returns how long until it has the tokens for the next one, or -1 if none is waiting.
+func (p *tokenBucketPacer) serve() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.turns) > 0 {
+		q := p.turns[0]
+		r := q.requests[0]
+		if r.settled {
+			p.dequeue(q, false)
+			continue
 		}
-		if newTokenCount > maxAllowedUnsentBytes {
-			common.AtomicMorphInt64(&p.atomicTokenBucket, func(currentVal int64) (newVal int64, _ interface{}) {
-				newVal = currentVal
-				if currentVal > maxAllowedUnsentBytes {
-					newVal = maxAllowedUnsentBytes
-				}
-				return
-			})
+
+		target := p.targetBytesPerSecond()
+		if target > 0 {
+			p.refill(time.Now())
+			need := min(float64(r.bytes), p.burst())
+			if p.tokens < need {
+				return time.Duration((need-p.tokens)/float64(target)*float64(time.Second)) + time.Millisecond
+			}
+			p.take(r.bytes)
+		} else {
+			atomic.AddInt64(&p.atomicGrandTotal, r.bytes)
 		}
+		r.settled = true
+		close(r.granted)
+		p.dequeue(q, true)
+	}
+	return -1
+}
+
+// enqueue adds r to the queue of job. p.mu must be held.
+func (p *tokenBucketPacer) enqueue(job string, r *pacerRequest) {
+	q, ok := p.queues[job]
+	if !ok {
+		q = &pacerQueue{job: job}
+		p.queues[job] = q
+		p.turns = append(p.turns, q)
+	}
+	q.requests = append(q.requests, r)
+}
+
+// dequeue removes the first request of q, which is first in turn, and sends q to the back of the line if that was its
+// turn. p.mu must be held.
+func (p *tokenBucketPacer) dequeue(q *pacerQueue, hadTurn bool) {
+	q.requests[0] = nil
+	q.requests = q.requests[1:]
+	switch {
+	case len(q.requests) == 0:
+		delete(p.queues, q.job)
+		p.turns = p.turns[1:]
+	case hadTurn:
+		p.turns = append(p.turns[1:], q)
+	}
+}
+
+// refill adds the tokens that have accrued since the last refill. p.mu must be held.
+func (p *tokenBucketPacer) refill(now time.Time) {
+	elapsed := now.Sub(p.lastRefill).Seconds()
+	p.lastRefill = now
+	if elapsed > 0 {
+		p.tokens = min(p.burst(), p.tokens+float64(p.targetBytesPerSecond())*elapsed)
+	}
+}
+
+// take spends tokens for byteCount, and records them as issued. p.mu must be held.
+func (p *tokenBucketPacer) take(byteCount int64) {
+	p.tokens -= float64(byteCount)
+	atomic.AddInt64(&p.atomicGrandTotal, byteCount)
+}
+
+// giveBack returns tokens that weren't used, and deducts them from the issued count. p.mu must be held.
+func (p *tokenBucketPacer) giveBack(byteCount int64) {
+	p.tokens = min(p.burst(), p.tokens+float64(byteCount))
+	atomic.AddInt64(&p.atomicGrandTotal, -byteCount)
+}
+
+// burst is how many tokens the bucket holds. p.mu must be held, or p not yet shared.
+func (p *tokenBucketPacer) burst() float64 {
+	if p.burstBytes > 0 {
+		return float64(p.burstBytes)
+	}
+	return max(1, float64(p.targetBytesPerSecond())*defaultBurstSeconds)
+}
 
-		lastTime = time.Now()
+// poke tells pacerBody to look at the queues again
+func (p *tokenBucketPacer) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
 	}
 }
 
@@ -200,11 +299,16 @@ func (p *tokenBucketPacer) targetBytesPerSecond() int64 {
 }
 
 func (p *tokenBucketPacer) setTargetBytesPerSecond(value int64) {
+	p.mu.Lock()
+	p.refill(time.Now()) // what accrued at the old rate
 	atomic.StoreInt64(&p.atomicTargetBytesPerSecond, value)
+	p.tokens = min(p.burst(), p.tokens)
+	p.mu.Unlock()
+	p.poke()
 }
 
 func (p *tokenBucketPacer) UpdateTargetBytesPerSecond(value int64) {
-	p.newTargetBytesPerSecond <- value
+	p.setTargetBytesPerSecond(value)
 }
 
 func (p *tokenBucketPacer) GetTotalTraffic() int64 {
@@ -0,0 +1,94 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// ResumeFilter picks which of a job's unfinished transfers are retried when it is resumed. The rest are left as they
+// were, so that a later resume can still pick them up. A nil ResumeFilter retries all of them.
+type ResumeFilter struct {
+	// FailedOnly retries only the transfers that failed, and not those that were skipped, cancelled or never finished
+	FailedOnly bool
+	// Include, if not empty, retries only the transfers whose source matches one of these patterns
+	Include []string
+	// Exclude doesn't retry the transfers whose source matches any of these patterns
+	Exclude []string
+}
+
+// ShouldResume says whether the transfer at transferIndex of the plan is to be retried
+func (f *ResumeFilter) ShouldResume(plan *JobPartPlanHeader, transferIndex uint32) bool {
+	status := plan.Transfer(transferIndex).TransferStatus()
+	if f == nil {
+		return status != common.ETransferStatus.Success()
+	}
+
+	relSrc, _ := plan.TransferSrcDstRelatives(transferIndex)
+	if plan.FromTo.From().IsRemote() {
+		if unescaped, err := url.PathUnescape(relSrc); err == nil {
+			relSrc = unescaped
+		}
+	}
+	return f.shouldResume(status, strings.TrimPrefix(relSrc, common.AZCOPY_PATH_SEPARATOR_STRING))
+}
+
+func (f *ResumeFilter) shouldResume(status common.TransferStatus, relSource string) bool {
+	switch status {
+	case common.ETransferStatus.Success():
+		return false
+	case common.ETransferStatus.Failed(),
+		common.ETransferStatus.BlobTierFailure(),
+		common.ETransferStatus.TierAvailabilityCheckFailure(),
+		common.ETransferStatus.Restarted(): // a failed transfer that an earlier resume didn't get to the end of
+	default:
+		if f.FailedOnly {
+			return false
+		}
+	}
+
+	if len(f.Include) > 0 && !matchesResumePattern(f.Include, relSource) {
+		return false
+	}
+	return !matchesResumePattern(f.Exclude, relSource)
+}
+
+// matchesResumePattern says whether any of the patterns matches the path, or the name, of the source. As with the
+// include-pattern flag of copy, a pattern without a slash matches names.
+func matchesResumePattern(patterns []string, relSource string) bool {
+	for _, pattern := range patterns {
+		if pattern == relSource {
+			return true
+		}
+		target := relSource
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(relSource)
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/minio/minio-go"
+)
+
+// defaultRetryJitter spreads retries over three quarters to one and a quarter of their backoff, about as much as the
+// SDK's own retry policy does
+const defaultRetryJitter = 0.25
+
+// defaultStallTimeout is long enough for a busy server or a slow disk, but cuts short the minutes that a dead
+// connection can otherwise hold a chunk for
+const defaultStallTimeout = time.Minute
+
+// RetrySettings are how the requests of the blob, file and dfs clients are retried when they fail. S3's client has its
+// own backoff, so only MaxRetries applies to it.
+type RetrySettings struct {
+	MaxRetries    int           // after the first try
+	RetryDelay    time.Duration // before the first retry; each one after it waits twice as long as the one before
+	MaxRetryDelay time.Duration
+	Jitter        float64       // how far each delay may be from its backoff, at random, as a fraction of it, from 0 to 1
+	TryTimeout    time.Duration // 0 for AZCOPY_REQUEST_TRY_TIMEOUT, or 15 minutes
+	Deadline      time.Duration // how long after a request's first try its retries may start, or 0 for no limit
+	StallTimeout  time.Duration // how long a try's connection may move no data before it's retried, or 0 for no limit
+	Budget        int           // how many retries all the requests of a transfer may make between them, or 0 for no limit
+}
+
+func DefaultRetrySettings() RetrySettings {
+	return RetrySettings{
+		MaxRetries:    UploadMaxTries,
+		RetryDelay:    UploadRetryDelay,
+		MaxRetryDelay: UploadMaxRetryDelay,
+		Jitter:        defaultRetryJitter,
+		StallTimeout:  defaultStallTimeout,
+	}
+}
+
+var retrySettings atomic.Pointer[RetrySettings]
+
+// SetRetrySettings makes the clients that are made from then on retry as s says
+func SetRetrySettings(s RetrySettings) error {
+	switch {
+	case s.MaxRetries < 0 || s.Budget < 0:
+		return errors.New("the number of retries and the retry budget can't be negative")
+	case s.RetryDelay <= 0:
+		return errors.New("the retry delay must be more than 0")
+	case s.MaxRetryDelay < s.RetryDelay:
+		return fmt.Errorf("the maximum retry delay, %v, is less than the retry delay, %v", s.MaxRetryDelay, s.RetryDelay)
+	case s.Jitter < 0 || s.Jitter > 1:
+		return errors.New("the retry jitter must be from 0 to 1")
+	case s.TryTimeout < 0 || s.Deadline < 0 || s.StallTimeout < 0:
+		return errors.New("the try timeout, retry deadline and stall timeout can't be negative")
+	}
+	retrySettings.Store(&s)
+	// minio's MaxRetry counts the first try too
+	minio.MaxRetry = s.MaxRetries + 1
+	return nil
+}
+
+func currentRetrySettings() RetrySettings {
+	s := DefaultRetrySettings()
+	if p := retrySettings.Load(); p != nil {
+		s = *p
+	}
+	if s.TryTimeout == 0 {
+		s.TryTimeout = UploadTryTimeout
+	}
+	return s
+}
+
+// RetryOptions are the options of the SDK's retry policy that go with the retry settings. The SDK's policy decides
+// whether to retry, and waits for Retry-After, but retryBackoffPolicy waits between the other tries, so that the
+// jitter can be set, and gives up at the deadline.
+func RetryOptions() policy.RetryOptions {
+	s := currentRetrySettings()
+	maxRetries := s.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = -1 // the SDK's policy takes 0 as its default
+	}
+	return policy.RetryOptions{
+		MaxRetries:    int32(maxRetries),
+		TryTimeout:    s.TryTimeout,
+		RetryDelay:    time.Nanosecond, // next to no delay of the SDK's own; with 0, its overflow check makes it MaxRetryDelay
+		MaxRetryDelay: s.MaxRetryDelay,
+	}
+}
+
+// delay is how long to wait before the retry'th retry, with random from 0 to 1 placing it within the jitter
+func (s RetrySettings) delay(retry int, random float64) time.Duration {
+	d := s.RetryDelay
+	for i := 1; i < retry && d < s.MaxRetryDelay; i++ {
+		d *= 2
+	}
+	d = min(d, s.MaxRetryDelay)
+	return min(s.MaxRetryDelay, time.Duration(float64(d)*(1+s.Jitter*(2*random-1))))
+}
+
+func (s RetrySettings) randomDelay(retry int) time.Duration {
+	return s.delay(retry, rand.Float64())
+}
+
+// String is how the settings are given in the job log
+func (s RetrySettings) String() string {
+	parts := []string{
+		fmt.Sprintf("%d retries", s.MaxRetries),
+		fmt.Sprintf("backoff from %v to %v", s.RetryDelay, s.MaxRetryDelay),
+		fmt.Sprintf("jitter %.0f%%", s.Jitter*100),
+		fmt.Sprintf("try timeout %v", s.TryTimeout),
+	}
+	if s.Deadline > 0 {
+		parts = append(parts, fmt.Sprintf("deadline %v", s.Deadline))
+	}
+	if s.Budget > 0 {
+		parts = append(parts, fmt.Sprintf("budget of %d retries per transfer", s.Budget))
+	}
+	if s.StallTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("stall timeout %v", s.StallTimeout))
+	}
+	return strings.Join(parts, ", ")
+}
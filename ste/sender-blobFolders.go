@@ -83,6 +83,18 @@ func (b *blobFolderSender) overwriteDFSProperties() (string, error) {
 	if err != nil {
 		return "Get Extra Properties", fmt.Errorf("when getting additional folder properties: %w", err)
 	}
+	err = addSourceACLsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return "Get ACLs", fmt.Errorf("when getting folder ACLs: %w", err)
+	}
+	err = addSourceExtAttrsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return "Get Extended Attributes", fmt.Errorf("when getting folder extended attributes: %w", err)
+	}
+	err = addSourceFileFlagsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return "Get File Flags", fmt.Errorf("when getting folder file flags: %w", err)
+	}
 
 	// do not set folder flag as it's invalid to modify a folder with
 	delete(b.metadataToApply, "hdi_isfolder")
@@ -197,6 +209,18 @@ func (b *blobFolderSender) EnsureFolderExists() error {
 	if err != nil {
 		return fmt.Errorf("when getting additional folder properties: %w", err)
 	}
+	err = addSourceACLsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return fmt.Errorf("when getting folder ACLs: %w", err)
+	}
+	err = addSourceExtAttrsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return fmt.Errorf("when getting folder extended attributes: %w", err)
+	}
+	err = addSourceFileFlagsToMetadata(b.jptm, b.sip, b.metadataToApply)
+	if err != nil {
+		return fmt.Errorf("when getting folder file flags: %w", err)
+	}
 
 	err = t.CreateFolder(b.DirUrlToString(), func() error {
 		blobTags := b.blobTagsToApply
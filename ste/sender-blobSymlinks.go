@@ -97,6 +97,41 @@ func (s *blobSymlinkSender) SendSymlink(linkData string) error {
 	return nil
 }
 
+// SendHardlink uploads an empty blob recording where the link points, relative to its own directory.
+// The linked file's content is uploaded once, under its own name.
+func (s *blobSymlinkSender) SendHardlink(target string) error {
+	s.metadataToApply = s.metadataToApply.Clone()
+	s.metadataToApply[common.POSIXHardlinkMeta] = to.Ptr(target)
+
+	blobTags := s.blobTagsToApply
+	setTags := separateSetTagsRequired(blobTags)
+	if setTags || len(blobTags) == 0 {
+		blobTags = nil
+	}
+
+	_, err := s.destinationClient.Upload(s.jptm.Context(), streaming.NopCloser(strings.NewReader("")),
+		&blockblob.UploadOptions{
+			HTTPHeaders:  &s.headersToApply,
+			Metadata:     s.metadataToApply,
+			Tier:         s.destBlobTier,
+			Tags:         blobTags,
+			CPKInfo:      s.jptm.CpkInfo(),
+			CPKScopeInfo: s.jptm.CpkScopeInfo(),
+		})
+	if err != nil {
+		s.jptm.FailActiveSend(common.Iff(len(blobTags) > 0, "Upload hard link (with tags)", "Upload hard link"), err)
+		return nil
+	}
+
+	if setTags {
+		if _, err := s.destinationClient.SetTags(s.jptm.Context(), s.blobTagsToApply, nil); err != nil {
+			s.jptm.FailActiveSend("Set tags", err)
+			return nil
+		}
+	}
+	return nil
+}
+
 // ===== Implement sender so that it can be returned in newBlobUploader. =====
 /*
 	It's OK to just panic all of these out, as they will never get called in a symlink transfer.
@@ -64,6 +64,9 @@ type blockBlobSenderBase struct {
 	muBlockIDs             *sync.Mutex
 	blockNamePrefix        string
 	completedBlockList     map[int]string
+
+	// the sizes of the blocks the destination blob has already, by name, for uploads with --block-delta
+	existingBlocks map[string]int64
 }
 
 func getVerifiedChunkParams(transferInfo *TransferInfo, memLimit int64, strictMemLimit int64) (chunkSize int64, numChunks uint32, err error) {
@@ -221,7 +224,7 @@ func (s *blockBlobSenderBase) RemoteFileExists() (bool, time.Time, error) {
 }
 
 func (s *blockBlobSenderBase) Prologue(ps common.PrologueState) (destinationModified bool) {
-	if s.jptm.RestartedTransfer() {
+	if s.jptm.RestartedTransfer() && !s.jptm.BlockDelta() { // a delta upload finds the blocks it staged itself
 		s.buildCommittedBlockMap()
 	}
 	if s.jptm.ShouldInferContentType() {
@@ -230,6 +233,10 @@ func (s *blockBlobSenderBase) Prologue(ps common.PrologueState) (destinationModi
 	if s.jptm.DeleteDestinationFileIfNecessary() {
 		s.DeleteDstBlob()
 	}
+	// after any deletion, since the blocks that go are no use
+	if s.jptm.BlockDelta() && s.numChunks > 1 {
+		s.buildExistingBlockMap()
+	}
 
 	return false
 }
@@ -374,11 +381,13 @@ func (s *blockBlobSenderBase) Cleanup() {
 		// Delete the uncommitted blobs
 		deletionContext, cancelFn := context.WithTimeout(context.WithValue(context.Background(), ServiceAPIVersionOverride, DefaultServiceApiVersion), 30*time.Second)
 		defer cancelFn()
-		if jptm.WasCanceled() {
+		if jptm.WasCanceled() || jptm.BlockDelta() {
 			// If we cancelled, and the only blocks that exist are uncommitted, then clean them up.
 			// This prevents customer paying for their storage for a week until they get garbage collected, and it
 			// also prevents any issues with "too many uncommitted blocks" if user tries to upload the blob again in future.
 			// But if there are committed blocks, leave them there (since they still safely represent the state before our job even started)
+			// A failed delta upload is treated the same way, since the blob it would have changed is still there, and the next
+			// attempt can use the blocks that were staged.
 			blockList, err := s.destBlockBlobClient.GetBlockList(deletionContext, blockblob.BlockListTypeAll, nil)
 			hasUncommittedOnly := err == nil && len(blockList.CommittedBlocks) == 0 && len(blockList.UncommittedBlocks) > 0
 			if hasUncommittedOnly {
@@ -490,6 +499,32 @@ func (s *blockBlobSenderBase) buildCommittedBlockMap() {
 	s.completedBlockList = list
 }
 
+// buildExistingBlockMap lists the blocks the destination blob has, committed or not, so that a delta upload can leave
+// out those it would send again. If they can't be listed, every block is sent.
+func (s *blockBlobSenderBase) buildExistingBlockMap() {
+	blockList, err := s.destBlockBlobClient.GetBlockList(s.jptm.Context(), blockblob.BlockListTypeAll, nil)
+	if err != nil {
+		if !bloberror.HasCode(err, bloberror.BlobNotFound) {
+			s.jptm.LogAtLevelForCurrentTransfer(common.LogWarning, fmt.Sprintf("Failed to get blocklist, so every block is uploaded: %v", err))
+		}
+		return
+	}
+
+	existing := make(map[string]int64, len(blockList.CommittedBlocks)+len(blockList.UncommittedBlocks))
+	for _, blocks := range [][]*blockblob.Block{blockList.CommittedBlocks, blockList.UncommittedBlocks} {
+		for _, block := range blocks {
+			existing[common.IffNotNil(block.Name, "")] = common.IffNotNil(block.Size, 0)
+		}
+	}
+	s.existingBlocks = existing
+}
+
+// hasBlock is whether the destination blob has the block with this name and size already
+func (s *blockBlobSenderBase) hasBlock(name string, size int64) bool {
+	existingSize, ok := s.existingBlocks[name]
+	return ok && existingSize == size
+}
+
 func (s *blockBlobSenderBase) ChunkAlreadyTransferred(index int32) bool {
 	if s.completedBlockList != nil {
 		return false
@@ -22,9 +22,16 @@ package ste
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"io"
+	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -34,6 +41,16 @@ type blockBlobUploader struct {
 	blockBlobSenderBase
 
 	md5Channel chan []byte
+
+	// the number of blocks a delta upload found in the destination blob already, and didn't send
+	atomicBlocksReused int32
+
+	// where each block ends, when the file is split where its content says; see findContentDefinedBlocks
+	blockEnds []int64
+
+	// for client-side encryption; see newBlockBlobUploader
+	encryptionKey  []byte
+	encryptionData string
 }
 
 func newBlockBlobUploader(jptm IJobPartTransferMgr, pacer pacer, sip ISourceInfoProvider) (sender, error) {
@@ -42,7 +59,67 @@ func newBlockBlobUploader(jptm IJobPartTransferMgr, pacer pacer, sip ISourceInfo
 		return nil, err
 	}
 
-	return &blockBlobUploader{blockBlobSenderBase: *senderBase, md5Channel: newMd5Channel()}, nil
+	u := &blockBlobUploader{blockBlobSenderBase: *senderBase, md5Channel: newMd5Channel()}
+
+	if jptm.ContentDefinedBlocks() && u.numChunks > 1 {
+		if err := u.findContentDefinedBlocks(); err != nil {
+			return nil, err
+		}
+	}
+
+	// A file that is to be encrypted gets a key of its own, which the blob's metadata records wrapped by the user's key.
+	// An empty file is left as it is, since it's uploaded without any chunks to encrypt.
+	wrapper, err := jptm.ClientSideKeyWrapper()
+	if err != nil {
+		return nil, err
+	}
+	if wrapper != nil && jptm.Info().SourceSize > 0 {
+		if u.encryptionKey, u.encryptionData, err = common.NewContentEncryptionKey(jptm.Context(), wrapper, u.chunkSize, jptm.Info().SourceSize); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// findContentDefinedBlocks reads the file to find where to split it into blocks, at places chosen by its content, so
+// that bytes inserted or removed only change the blocks they're in, and a delta upload sends just those. Blocks are
+// the chunk size on average, and between a quarter of it and four times it, except that there can't be more than the
+// most a blob can have.
+func (u *blockBlobUploader) findContentDefinedBlocks() error {
+	localSIP, ok := u.sip.(ILocalSourceInfoProvider)
+	if !ok {
+		return nil
+	}
+	size := u.jptm.Info().SourceSize
+	minSize := max(u.chunkSize/4, (size+common.MaxNumberOfBlocksPerBlob-1)/common.MaxNumberOfBlocksPerBlob)
+	maxSize := max(u.chunkSize, min(4*u.chunkSize, common.MaxBlockBlobBlockSize, u.jptm.CacheLimiter().StrictLimit()/2))
+	chunker, err := common.NewContentDefinedChunker(u.chunkSize, minSize, maxSize)
+	if err != nil {
+		return err
+	}
+
+	file, err := localSIP.OpenSourceFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ends, err := chunker.ChunkEnds(u.jptm.Context(), io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return fmt.Errorf("finding the blocks to split the file into: %w", err)
+	}
+	if len(ends) == 0 || ends[len(ends)-1] != size {
+		return errors.New("the file changed size while its blocks were found")
+	}
+
+	u.blockEnds = ends
+	u.numChunks = uint32(len(ends))
+	u.blockIDs = make([]string, len(ends))
+	u.jptm.LogAtLevelForCurrentTransfer(common.LogDebug, fmt.Sprintf("Split into %d blocks where the content says", len(ends)))
+	return nil
+}
+
+func (u *blockBlobUploader) ChunkEnds() []int64 {
+	return u.blockEnds
 }
 
 func (s *blockBlobUploader) Prologue(ps common.PrologueState) (destinationModified bool) {
@@ -61,6 +138,31 @@ func (s *blockBlobUploader) Prologue(ps common.PrologueState) (destinationModifi
 		}
 	}
 
+	if s.jptm.Info().PreserveACLs || s.jptm.Info().PreserveExtAttrs || s.jptm.Info().PreserveFileFlags {
+		s.metadataToApply = s.metadataToApply.Clone()
+
+		if err := addSourceACLsToMetadata(s.jptm, s.sip, s.metadataToApply); err != nil {
+			s.jptm.FailActiveSend("GetACLs", err)
+		}
+		if err := addSourceExtAttrsToMetadata(s.jptm, s.sip, s.metadataToApply); err != nil {
+			s.jptm.FailActiveSend("GetExtAttrs", err)
+		}
+		if err := addSourceFileFlagsToMetadata(s.jptm, s.sip, s.metadataToApply); err != nil {
+			s.jptm.FailActiveSend("GetFileFlags", err)
+		}
+	}
+
+	if ct := s.Compression(); ct != common.ECompressionType.None() {
+		s.headersToApply.BlobContentEncoding = to.Ptr(strings.ToLower(ct.String()))
+		s.metadataToApply = s.metadataToApply.Clone()
+		s.metadataToApply[common.UncompressedSizeMeta] = to.Ptr(strconv.FormatInt(s.jptm.Info().SourceSize, 10))
+	}
+
+	if s.encryptionData != "" {
+		s.metadataToApply = s.metadataToApply.Clone()
+		s.metadataToApply[common.ClientSideEncryptionMeta] = to.Ptr(s.encryptionData)
+	}
+
 	return s.blockBlobSenderBase.Prologue(ps)
 }
 
@@ -68,6 +170,33 @@ func (u *blockBlobUploader) Md5Channel() chan<- []byte {
 	return u.md5Channel
 }
 
+func (u *blockBlobUploader) Compression() common.CompressionType {
+	return u.jptm.UploadCompression()
+}
+
+func (u *blockBlobUploader) ContentEncryptionKey() []byte {
+	return u.encryptionKey
+}
+
+func (u *blockBlobUploader) SetUncompressedMd5(md5 []byte) {
+	u.metadataToApply[common.UncompressedMD5Meta] = to.Ptr(base64.StdEncoding.EncodeToString(md5)) // cloned by the prologue
+}
+
+func (u *blockBlobUploader) RecordsContentHashInMetadata() bool {
+	return true
+}
+
+// applyContentHash puts the hash of the file where it belongs: an MD5 in Content-MD5, and the others in metadata
+func (u *blockBlobUploader) applyContentHash(contentHash []byte) {
+	key := u.jptm.ContentHashType().MetadataKey()
+	if key == "" {
+		u.headersToApply.BlobContentMD5 = contentHash
+		return
+	}
+	u.metadataToApply = u.metadataToApply.Clone()
+	u.metadataToApply[key] = to.Ptr(base64.StdEncoding.EncodeToString(contentHash))
+}
+
 // Returns a chunk-func for blob uploads
 func (u *blockBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader, chunkIsWholeFile bool) chunkFunc {
 	if chunkIsWholeFile {
@@ -85,6 +214,11 @@ func (u *blockBlobUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int
 // generatePutBlock generates a func to upload the block of src data from given startIndex till the given chunkSize.
 func (u *blockBlobUploader) generatePutBlock(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader) chunkFunc {
 	return createSendToRemoteChunkFunc(u.jptm, id, func() {
+		if u.jptm.BlockDelta() {
+			u.putDeltaBlock(id, blockIndex, reader)
+			return
+		}
+
 		// step 1: generate block ID
 		encodedBlockID := u.generateEncodedBlockID(blockIndex)
 
@@ -115,6 +249,37 @@ func (u *blockBlobUploader) generatePutBlock(id common.ChunkID, blockIndex int32
 	})
 }
 
+// putDeltaBlock names the block by its content, and stages it only if the destination blob doesn't have it already.
+// Either way, the block is in the list that's committed.
+func (u *blockBlobUploader) putDeltaBlock(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader) {
+	hasher := sha256.New()
+	reader.WriteBufferTo(hasher)
+	encodedBlockID := common.GenerateDeltaBlockID(hasher.Sum(nil))
+	u.setBlockID(blockIndex, encodedBlockID)
+
+	if u.hasBlock(encodedBlockID, reader.Length()) {
+		u.jptm.LogAtLevelForCurrentTransfer(common.LogDebug,
+			fmt.Sprintf("Skipping chunk %d as the destination has it already.", blockIndex))
+		atomic.AddInt32(&u.atomicBlocksReused, 1)
+		atomic.AddInt32(&u.atomicChunksWritten, 1)
+		return
+	}
+
+	u.jptm.LogChunkStatus(id, common.EWaitReason.Body())
+	body := newPacedRequestBody(u.jptm.Context(), reader, u.pacer)
+	_, err := u.destBlockBlobClient.StageBlock(u.jptm.Context(), encodedBlockID, body,
+		&blockblob.StageBlockOptions{
+			CPKInfo:      u.jptm.CpkInfo(),
+			CPKScopeInfo: u.jptm.CpkScopeInfo(),
+		})
+	if err != nil {
+		u.jptm.FailActiveUpload("Staging block", err)
+		return
+	}
+
+	atomic.AddInt32(&u.atomicChunksWritten, 1)
+}
+
 // generates PUT Blob (for a blob that fits in a single put request)
 func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, reader common.SingleChunkReader) chunkFunc {
 
@@ -160,7 +325,7 @@ func (u *blockBlobUploader) generatePutWholeBlob(id common.ChunkID, reader commo
 				return
 			}
 			if len(md5Hash) != 0 {
-				u.headersToApply.BlobContentMD5 = md5Hash
+				u.applyContentHash(md5Hash)
 			}
 
 			// Upload the file
@@ -202,12 +367,17 @@ func (u *blockBlobUploader) Epilogue() {
 		md5Hash, ok := <-u.md5Channel
 		if ok {
 			if len(md5Hash) != 0 {
-				u.headersToApply.BlobContentMD5 = md5Hash
+				u.applyContentHash(md5Hash)
 			}
 		} else {
 			jptm.FailActiveSend("Getting hash", errNoHash)
 			return
 		}
+
+		if reused := atomic.LoadInt32(&u.atomicBlocksReused); reused > 0 {
+			jptm.Log(common.LogInfo, fmt.Sprintf("Uploaded %d of %d blocks; the destination had the others already",
+				u.numChunks-uint32(reused), u.numChunks))
+		}
 	}
 
 	u.blockBlobSenderBase.Epilogue()
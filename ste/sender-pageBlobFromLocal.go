@@ -60,6 +60,20 @@ func (u *pageBlobUploader) Prologue(ps common.PrologueState) (destinationModifie
 		}
 	}
 
+	if u.jptm.Info().PreserveACLs || u.jptm.Info().PreserveExtAttrs || u.jptm.Info().PreserveFileFlags {
+		u.metadataToApply = u.metadataToApply.Clone()
+
+		if err := addSourceACLsToMetadata(u.jptm, u.sip, u.metadataToApply); err != nil {
+			u.jptm.FailActiveSend("GetACLs", err)
+		}
+		if err := addSourceExtAttrsToMetadata(u.jptm, u.sip, u.metadataToApply); err != nil {
+			u.jptm.FailActiveSend("GetExtAttrs", err)
+		}
+		if err := addSourceFileFlagsToMetadata(u.jptm, u.sip, u.metadataToApply); err != nil {
+			u.jptm.FailActiveSend("GetFileFlags", err)
+		}
+	}
+
 	return u.pageBlobSenderBase.Prologue(ps)
 }
 
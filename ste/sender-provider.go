@@ -0,0 +1,145 @@
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// providerUploader sends local files to a location served by a registered common.Provider.
+type providerUploader struct {
+	jptm       IJobPartTransferMgr
+	provider   common.Provider
+	dest       *url.URL
+	sip        ISourceInfoProvider
+	pacer      pacer
+	chunkSize  int64
+	numChunks  uint32
+	md5Channel chan []byte
+	writer     common.ProviderWriter
+}
+
+func newProviderUploader(jptm IJobPartTransferMgr, destination string, pacer pacer, sip ISourceInfoProvider) (sender, error) {
+	p, u, err := common.GetProvider(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	info := jptm.Info()
+	return &providerUploader{
+		jptm:       jptm,
+		provider:   p,
+		dest:       u,
+		sip:        sip,
+		pacer:      pacer,
+		chunkSize:  info.BlockSize,
+		numChunks:  getNumChunks(info.SourceSize, info.BlockSize, info.BlockSize),
+		md5Channel: newMd5Channel(),
+	}, nil
+}
+
+func (u *providerUploader) ChunkSize() int64 {
+	return u.chunkSize
+}
+
+func (u *providerUploader) NumChunks() uint32 {
+	return u.numChunks
+}
+
+func (u *providerUploader) Md5Channel() chan<- []byte {
+	return u.md5Channel
+}
+
+func (u *providerUploader) RemoteFileExists() (bool, time.Time, error) {
+	obj, err := u.provider.Properties(u.jptm.Context(), u.dest)
+	if errors.Is(err, common.ErrProviderObjectNotFound) {
+		return false, time.Time{}, nil
+	} else if err != nil {
+		return false, time.Time{}, err
+	}
+	return !obj.IsDirectory, obj.LastModified, nil
+}
+
+func (u *providerUploader) Prologue(state common.PrologueState) (destinationModified bool) {
+	info := u.jptm.Info()
+	obj := common.ProviderObject{
+		Size:         info.SourceSize,
+		LastModified: u.jptm.LastModifiedTime(),
+	}
+	if props, err := u.sip.Properties(); err == nil {
+		if props.SrcHTTPHeaders.ContentType != "" {
+			obj.ContentType = props.SrcHTTPHeaders.ContentType
+		}
+		obj.Metadata = map[string]string{}
+		for k, v := range props.SrcMetadata {
+			if v != nil {
+				obj.Metadata[k] = *v
+			}
+		}
+	}
+	if obj.ContentType == "" {
+		if inferred := state.GetInferredContentType(u.jptm); inferred != nil {
+			obj.ContentType = *inferred
+		}
+	}
+
+	writer, err := u.provider.Create(u.jptm.Context(), u.dest, obj)
+	if err != nil {
+		u.jptm.FailActiveUpload("Creating object", err)
+		return false
+	}
+	u.writer = writer
+	return true
+}
+
+func (u *providerUploader) GenerateUploadFunc(id common.ChunkID, blockIndex int32, reader common.SingleChunkReader, chunkIsWholeFile bool) chunkFunc {
+	return createSendToRemoteChunkFunc(u.jptm, id, func() {
+		jptm := u.jptm
+		if jptm.Info().SourceSize == 0 {
+			return // nothing to write; Commit will still create the object
+		}
+
+		jptm.LogChunkStatus(id, common.EWaitReason.Body())
+		body := newPacedRequestBody(jptm.Context(), reader, u.pacer)
+		if _, err := io.Copy(io.NewOffsetWriter(u.writer, id.OffsetInFile()), body); err != nil {
+			jptm.FailActiveUpload("Writing chunk", err)
+		}
+	})
+}
+
+func (u *providerUploader) Epilogue() {
+	jptm := u.jptm
+	if !jptm.IsLive() {
+		return
+	}
+	if _, ok := <-u.md5Channel; !ok {
+		jptm.FailActiveUpload("Getting hash", errNoHash)
+		return
+	}
+	if err := u.writer.Commit(jptm.Context()); err != nil {
+		jptm.FailActiveUpload("Committing object", err)
+	}
+}
+
+func (u *providerUploader) Cleanup() {
+	if !u.jptm.IsDeadInflight() || u.writer == nil {
+		return
+	}
+	// the object is at an unknown stage of completeness, so it mustn't be left behind
+	if err := u.writer.Abort(); err != nil {
+		u.jptm.Log(common.LogError, fmt.Sprintf("error discarding the incomplete object %s: %s",
+			common.URLExtension{URL: *u.dest}.RedactSecretQueryParamForLogging(), err))
+	}
+}
+
+func (u *providerUploader) GetDestinationLength() (int64, error) {
+	obj, err := u.provider.Properties(u.jptm.Context(), u.dest)
+	if err != nil {
+		return -1, err
+	}
+	return obj.Size, nil
+}
@@ -105,6 +105,11 @@ type symlinkSender interface {
 	SendSymlink(linkData string) error
 }
 
+// hardlinkSender is a sender that can stand an empty object in for a hard link (see common.POSIXHardlinkMeta)
+type hardlinkSender interface {
+	SendHardlink(target string) error
+}
+
 type senderFactory func(jptm IJobPartTransferMgr, destination string, pacer pacer, sip ISourceInfoProvider) (sender, error)
 
 /////////////////////////////////////////////////////////////////////////////////////////////////
@@ -135,6 +140,45 @@ type uploader interface {
 	Md5Channel() chan<- []byte
 }
 
+// compressingUploader is an uploader that can store what it uploads compressed, with a Content-Encoding to say so
+type compressingUploader interface {
+	uploader
+
+	// Compression returns the compression that the chunks it is given should have. They are compressed one by one,
+	// so the compression must be one whose streams can be concatenated.
+	Compression() common.CompressionType
+
+	// SetUncompressedMd5 records the hash of the file as it was before it was compressed. It's called before the MD5
+	// hash of what was uploaded is sent on the Md5Channel.
+	SetUncompressedMd5(md5 []byte)
+}
+
+// encryptingUploader is an uploader that can store what it uploads encrypted on the client
+type encryptingUploader interface {
+	uploader
+
+	// ContentEncryptionKey returns the key that the chunks it is given should be encrypted with, each as a region of
+	// its own, or nil if they aren't to be encrypted
+	ContentEncryptionKey() []byte
+}
+
+// variableChunkUploader is an uploader whose chunks needn't all be ChunkSize bytes
+type variableChunkUploader interface {
+	uploader
+
+	// ChunkEnds returns the offset of the end of each chunk, or nil if all but the last are ChunkSize bytes
+	ChunkEnds() []int64
+}
+
+// contentHashUploader is an uploader that can record hashes other than MD5, which have no property of their own, in
+// metadata. It takes them from the Md5Channel, just as it takes MD5s.
+type contentHashUploader interface {
+	uploader
+
+	// RecordsContentHashInMetadata returns true if the uploader records hashes other than MD5 in metadata
+	RecordsContentHashInMetadata() bool
+}
+
 func newMd5Channel() chan []byte {
 	return make(chan []byte, 1) // must be buffered, so as not to hold up the goroutine running anyToRemote (which needs to start on the NEXT file after finishing its current one)
 }
@@ -180,11 +224,15 @@ func createSendToRemoteChunkFunc(jptm IJobPartTransferMgr, id common.ChunkID, bo
 
 // createChunkFunc adds a standard prefix, which all chunkFuncs require, to the given body
 func createChunkFunc(setDoneStatusOnExit bool, jptm IJobPartTransferMgr, id common.ChunkID, body func()) chunkFunc {
+	queuedAt := time.Now()
 	return func(workerId int) {
 
 		// BEGIN standard prefix that all chunk funcs need
 		defer jptm.ReportChunkDone(id) // whether successful or failed, it's always "done" and we must always tell the jptm
 
+		span := startChunkSpans(jptm, id, queuedAt)
+		defer span.End()
+
 		jptm.OccupyAConnection() // TODO: added the two operations for debugging purpose. remove later
 		defer jptm.ReleaseAConnection()
 
@@ -224,6 +272,8 @@ func newBlobUploader(jptm IJobPartTransferMgr, destination string, pacer pacer,
 		return newBlobFolderSender(jptm, destination, sip)
 	} else if jptm.Info().EntityType == common.EEntityType.Symlink() {
 		return newBlobSymlinkSender(jptm, destination, sip)
+	} else if _, isLink := jptm.Info().HardlinkTarget(); isLink && jptm.FromTo().IsUpload() {
+		return newBlobSymlinkSender(jptm, destination, sip) // also sends hard links, which are just as contentless
 	}
 
 	switch intendedType {
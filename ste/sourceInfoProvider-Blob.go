@@ -50,10 +50,26 @@ func (p *blobSourceInfoProvider) RawSource() string {
 	return p.source.URL()
 }
 
+// sourceCpkInfo is the key to read the source with. The job's key is the destination's in a S2S copy, and only the
+// source's when the source is encrypted with it, since the service rejects a key for a blob that isn't encrypted with one.
+func (p *blobSourceInfoProvider) sourceCpkInfo() *blob.CPKInfo {
+	if !p.jptm.IsSourceEncrypted() {
+		return nil
+	}
+	return p.jptm.CpkInfo()
+}
+
+func (p *blobSourceInfoProvider) sourceCpkScopeInfo() *blob.CPKScopeInfo {
+	if !p.jptm.IsSourceEncrypted() {
+		return nil
+	}
+	return p.jptm.CpkScopeInfo()
+}
+
 func (p *blobSourceInfoProvider) ReadLink() (string, error) {
 	resp, err := p.source.DownloadStream(p.ctx, &blob.DownloadStreamOptions{
-		CPKInfo:      p.jptm.CpkInfo(),
-		CPKScopeInfo: p.jptm.CpkScopeInfo(),
+		CPKInfo:      p.sourceCpkInfo(),
+		CPKScopeInfo: p.sourceCpkScopeInfo(),
 	})
 	if err != nil {
 		return "", err
@@ -79,6 +95,15 @@ func (p *blobSourceInfoProvider) GetUNIXProperties() (common.UnixStatAdapter, er
 	return common.ReadStatFromMetadata(prop.SrcMetadata, p.SourceSize())
 }
 
+func (p *blobSourceInfoProvider) GetACLs() (access, def *common.ACL, err error) {
+	prop, err := p.Properties()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return common.ReadACLsFromMetadata(prop.SrcMetadata)
+}
+
 func (p *blobSourceInfoProvider) HasUNIXProperties() bool {
 	prop, err := p.Properties()
 	if err != nil {
@@ -161,7 +186,7 @@ func (p *blobSourceInfoProvider) BlobType() blob.BlobType {
 
 func (p *blobSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
 	// We can't set a custom LMT on HNS, so it doesn't make sense to swap here.
-	properties, err := p.source.GetProperties(p.ctx, &blob.GetPropertiesOptions{CPKInfo: p.jptm.CpkInfo()})
+	properties, err := p.source.GetProperties(p.ctx, &blob.GetPropertiesOptions{CPKInfo: p.sourceCpkInfo()})
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -177,8 +202,8 @@ func (p *blobSourceInfoProvider) GetMD5(offset, count int64) ([]byte, error) {
 		&blob.DownloadStreamOptions{
 			Range:              blob.HTTPRange{Offset: offset, Count: count},
 			RangeGetContentMD5: rangeGetContentMD5,
-			CPKInfo:            p.jptm.CpkInfo(),
-			CPKScopeInfo:       p.jptm.CpkScopeInfo(),
+			CPKInfo:            p.sourceCpkInfo(),
+			CPKScopeInfo:       p.sourceCpkScopeInfo(),
 		})
 	if err != nil {
 		return nil, err
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
@@ -41,7 +42,26 @@ func (f localFileSourceInfoProvider) ReadLink() (string, error) {
 	return os.Readlink(f.jptm.Info().Source)
 }
 
+func (f localFileSourceInfoProvider) GetACLs() (access, def *common.ACL, err error) {
+	return common.GetFileACLs(f.jptm.Info().Source, f.EntityType() == common.EEntityType.Folder())
+}
+
+func (f localFileSourceInfoProvider) GetExtAttrs() (map[string][]byte, error) {
+	return common.GetExtAttrs(f.jptm.Info().Source)
+}
+
+func (f localFileSourceInfoProvider) GetFileFlags() (common.FileFlags, error) {
+	return common.GetFileFlags(f.jptm.Info().Source)
+}
+
 func newLocalSourceInfoProvider(jptm IJobPartTransferMgr) (ISourceInfoProvider, error) {
+	// an archive pack isn't a file of its own, but is made up of the small files listed for it
+	if jobID, n, ok := common.ParseArchivePackName(filepath.Base(jptm.Info().Source)); ok {
+		return newArchivePackSourceInfoProvider(jptm, jobID, n)
+	}
+	if jobID, ok := common.ParseArchivePackJobIndexName(filepath.Base(jptm.Info().Source)); ok {
+		return newArchivePackJobIndexSourceInfoProvider(jptm, jobID)
+	}
 	return &localFileSourceInfoProvider{jptm, jptm.Info()}, nil
 }
 
@@ -75,7 +95,7 @@ func (f localFileSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt,
 		return fi.Mode()&mode == mode
 	}
 
-	fi, err := os.Stat(path)
+	fi, err := common.SandboxStat(path)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +111,7 @@ func (f localFileSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt,
 	if custom, ok := interface{}(f).(ICustomLocalOpener); ok {
 		return custom.Open(path)
 	}
-	return os.Open(path)
+	return common.OpenLocalSourceFile(path)
 }
 
 func (f localFileSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
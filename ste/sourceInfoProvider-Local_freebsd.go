@@ -0,0 +1,92 @@
+//go:build freebsd
+
+package ste
+
+import (
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"golang.org/x/sys/unix"
+)
+
+func (f localFileSourceInfoProvider) HasUNIXProperties() bool {
+	return true
+}
+
+func (f localFileSourceInfoProvider) GetUNIXProperties() (common.UnixStatAdapter, error) {
+	var stat unix.Stat_t
+	var err error
+	if f.EntityType() == common.EEntityType.Symlink() {
+		err = unix.Lstat(f.transferInfo.Source, &stat)
+	} else {
+		err = unix.Stat(f.transferInfo.Source, &stat)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return freebsdStatAdapter(stat), nil
+}
+
+// freebsdStatAdapter presents stat(2) results the way the Linux build presents those of stat, rather than statx.
+type freebsdStatAdapter unix.Stat_t
+
+func (s freebsdStatAdapter) Extended() bool {
+	return false
+}
+
+func (s freebsdStatAdapter) StatxMask() uint32 {
+	return 0
+}
+
+func (s freebsdStatAdapter) Attribute() uint64 {
+	return 0
+}
+
+func (s freebsdStatAdapter) AttributeMask() uint64 {
+	return 0
+}
+
+func (s freebsdStatAdapter) BTime() time.Time {
+	return time.Time{}
+}
+
+func (s freebsdStatAdapter) NLink() uint64 {
+	return uint64(s.Nlink)
+}
+
+func (s freebsdStatAdapter) Owner() uint32 {
+	return s.Uid
+}
+
+func (s freebsdStatAdapter) Group() uint32 {
+	return s.Gid
+}
+
+func (s freebsdStatAdapter) FileMode() uint32 {
+	return uint32(s.Mode)
+}
+
+func (s freebsdStatAdapter) INode() uint64 {
+	return s.Ino
+}
+
+func (s freebsdStatAdapter) Device() uint64 {
+	return s.Dev
+}
+
+func (s freebsdStatAdapter) RDevice() uint64 {
+	return s.Rdev
+}
+
+func (s freebsdStatAdapter) ATime() time.Time {
+	return time.Unix(s.Atim.Unix())
+}
+
+func (s freebsdStatAdapter) MTime() time.Time {
+	return time.Unix(s.Mtim.Unix())
+}
+
+func (s freebsdStatAdapter) CTime() time.Time {
+	return time.Unix(s.Ctim.Unix())
+}
@@ -0,0 +1,96 @@
+package ste
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// urlSourceInfoProvider is for the http(s) URLs named in a list of URLs. They are public, or carry their own
+// SAS or presigned signature, so the destination service can read them just as they are.
+type urlSourceInfoProvider struct {
+	defaultRemoteSourceInfoProvider
+	client *http.Client
+}
+
+// urlSourceHTTPClient is made when it's first needed, after the TLS config of --ca-bundle and --client-cert is loaded
+var urlSourceHTTPClient = sync.OnceValue(func() *http.Client { return NewAzcopyHTTPClient(4) })
+
+func newURLSourceInfoProvider(jptm IJobPartTransferMgr) (ISourceInfoProvider, error) {
+	base, err := newDefaultRemoteSourceInfoProvider(jptm)
+	if err != nil {
+		return nil, err
+	}
+	return &urlSourceInfoProvider{defaultRemoteSourceInfoProvider: *base, client: urlSourceHTTPClient()}, nil
+}
+
+func (p *urlSourceInfoProvider) PreSignedSourceURL() (string, error) {
+	return p.transferInfo.Source, nil
+}
+
+func (p *urlSourceInfoProvider) RawSource() string {
+	return p.transferInfo.Source
+}
+
+// get reads a range of the source. Like the lister, it uses a ranged GET rather than HEAD, since presigned
+// URLs are often only signed for GET.
+func (p *urlSourceInfoProvider) get(offset, count int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(p.jptm.Context(), http.MethodGet, p.transferInfo.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r := formatHTTPRange(offset, count); r != nil {
+		req.Header.Set("Range", *r)
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+	source, _, _ := strings.Cut(p.transferInfo.Source, "?") // for errors, which leave out any signature
+	resp, err := p.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("GET %s: %w", source, err)
+	}
+	if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && offset == 0) {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+	}
+	return resp, nil
+}
+
+func (p *urlSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
+	resp, err := p.get(0, min(p.transferInfo.SourceSize, 1)) // an empty file has no first byte to ask for
+	if err != nil {
+		return time.Time{}, err
+	}
+	_ = resp.Body.Close()
+
+	lmt := resp.Header.Get("Last-Modified")
+	if lmt == "" {
+		return time.Time{}, nil // as it was when it was listed
+	}
+	return http.ParseTime(lmt)
+}
+
+func (p *urlSourceInfoProvider) GetMD5(offset, count int64) ([]byte, error) {
+	resp, err := p.get(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	h := md5.New()
+	if _, err = io.Copy(h, io.LimitReader(resp.Body, count)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
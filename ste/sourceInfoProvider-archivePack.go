@@ -0,0 +1,114 @@
+package ste
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// ArchivePackListPath is where the list of files in a pack is kept. It sits alongside the job's plan files, so that
+// the pack can be read again if the job is resumed, and is removed with them.
+func ArchivePackListPath(jobID common.JobID, n uint32) string {
+	return filepath.Join(common.AzcopyJobPlanFolder, fmt.Sprintf("%s-pack-%d.steV%d.json", jobID, n, DataSchemaVersion))
+}
+
+// ArchivePackJobIndexPath is where the index of all of a job's packs is written, as the packs are laid out
+func ArchivePackJobIndexPath(jobID common.JobID) string {
+	return filepath.Join(common.AzcopyJobPlanFolder, fmt.Sprintf("%s-pack-index.steV%d.jsonl", jobID, DataSchemaVersion))
+}
+
+// archivePackSourceInfoProvider reads an archive pack of small local files, which is uploaded in place of them
+type archivePackSourceInfoProvider struct {
+	jptm         IJobPartTransferMgr
+	transferInfo *TransferInfo
+	pack         *common.ArchivePack
+}
+
+func newArchivePackSourceInfoProvider(jptm IJobPartTransferMgr, jobID common.JobID, n uint32) (ISourceInfoProvider, error) {
+	pack, err := common.LoadArchivePack(ArchivePackListPath(jobID, n))
+	if err != nil {
+		return nil, err
+	}
+	return &archivePackSourceInfoProvider{jptm: jptm, transferInfo: jptm.Info(), pack: pack}, nil
+}
+
+func (p *archivePackSourceInfoProvider) Properties() (*SrcProperties, error) {
+	headers, metadata, blobTags, _ := p.jptm.ResourceDstData(nil)
+
+	return &SrcProperties{
+		SrcHTTPHeaders: headers,
+		SrcMetadata:    metadata,
+		SrcBlobTags:    blobTags,
+	}, nil
+}
+
+func (p *archivePackSourceInfoProvider) IsLocal() bool {
+	return true
+}
+
+func (p *archivePackSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt, error) {
+	return p.pack.Open(), nil
+}
+
+func (p *archivePackSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
+	return p.pack.FreshModTime()
+}
+
+func (p *archivePackSourceInfoProvider) EntityType() common.EntityType {
+	return p.transferInfo.EntityType
+}
+
+func (p *archivePackSourceInfoProvider) GetMD5(offset, count int64) ([]byte, error) {
+	h := md5.New()
+	if n, err := io.Copy(h, io.NewSectionReader(p.pack.Open(), offset, count)); err != nil {
+		return nil, err
+	} else if n != count {
+		return nil, errors.New("failed to read the full range of the archive pack")
+	}
+	return h.Sum(nil), nil
+}
+
+// archivePackJobIndexSourceInfoProvider reads the index of a job's packs, which is uploaded beside them
+type archivePackJobIndexSourceInfoProvider struct {
+	archivePackSourceInfoProvider
+	path string
+}
+
+func newArchivePackJobIndexSourceInfoProvider(jptm IJobPartTransferMgr, jobID common.JobID) (ISourceInfoProvider, error) {
+	return &archivePackJobIndexSourceInfoProvider{
+		archivePackSourceInfoProvider: archivePackSourceInfoProvider{jptm: jptm, transferInfo: jptm.Info()},
+		path:                          ArchivePackJobIndexPath(jobID),
+	}, nil
+}
+
+func (p *archivePackJobIndexSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt, error) {
+	return common.OpenLocalSourceFile(p.path)
+}
+
+func (p *archivePackJobIndexSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
+	fi, err := common.OSStat(p.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+func (p *archivePackJobIndexSourceInfoProvider) GetMD5(offset, count int64) ([]byte, error) {
+	f, err := p.OpenSourceFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if n, err := io.Copy(h, io.NewSectionReader(f, offset, count)); err != nil {
+		return nil, err
+	} else if n != count {
+		return nil, errors.New("failed to read the full range of the archive pack index")
+	}
+	return h.Sum(nil), nil
+}
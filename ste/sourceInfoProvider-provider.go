@@ -0,0 +1,73 @@
+package ste
+
+import (
+	"crypto/md5"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// providerSourceInfoProvider reads from a location served by a registered common.Provider. The service we send to
+// can't fetch a provider's objects from a URL, so they are read by AzCopy and uploaded, in the same way as local files.
+type providerSourceInfoProvider struct {
+	jptm         IJobPartTransferMgr
+	transferInfo *TransferInfo
+}
+
+func newProviderSourceInfoProvider(jptm IJobPartTransferMgr) (ISourceInfoProvider, error) {
+	return &providerSourceInfoProvider{jptm: jptm, transferInfo: jptm.Info()}, nil
+}
+
+func (p *providerSourceInfoProvider) Properties() (*SrcProperties, error) {
+	return &SrcProperties{
+		SrcHTTPHeaders: p.transferInfo.SrcHTTPHeaders,
+		SrcMetadata:    p.transferInfo.SrcMetadata,
+		SrcBlobTags:    p.transferInfo.SrcBlobTags,
+	}, nil
+}
+
+func (p *providerSourceInfoProvider) IsLocal() bool {
+	return true
+}
+
+func (p *providerSourceInfoProvider) OpenSourceFile() (common.CloseableReaderAt, error) {
+	provider, u, err := common.GetProvider(p.transferInfo.Source)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Open(p.jptm.Context(), u)
+}
+
+func (p *providerSourceInfoProvider) GetFreshFileLastModifiedTime() (time.Time, error) {
+	provider, u, err := common.GetProvider(p.transferInfo.Source)
+	if err != nil {
+		return time.Time{}, err
+	}
+	obj, err := provider.Properties(p.jptm.Context(), u)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return obj.LastModified, nil
+}
+
+func (p *providerSourceInfoProvider) EntityType() common.EntityType {
+	return p.transferInfo.EntityType
+}
+
+func (p *providerSourceInfoProvider) GetMD5(offset, count int64) ([]byte, error) {
+	source, err := p.OpenSourceFile()
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	h := md5.New()
+	if n, err := io.Copy(h, io.NewSectionReader(source, offset, count)); err != nil {
+		return nil, err
+	} else if n != count {
+		return nil, errors.New("failed to read the full range of the source object")
+	}
+	return h.Sum(nil), nil
+}
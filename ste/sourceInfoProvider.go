@@ -119,6 +119,27 @@ type IUNIXPropertyBearingSourceInfoProvider interface {
 	HasUNIXProperties() bool
 }
 
+type IACLBearingSourceInfoProvider interface {
+	ISourceInfoProvider
+
+	// GetACLs returns the access (or NFSv4) ACL and, for folders, the default ACL. Either may be nil.
+	GetACLs() (access, def *common.ACL, err error)
+}
+
+type IExtAttrBearingSourceInfoProvider interface {
+	ISourceInfoProvider
+
+	// GetExtAttrs returns extended attributes keyed as "<namespace>.<name>".
+	GetExtAttrs() (map[string][]byte, error)
+}
+
+type IFileFlagBearingSourceInfoProvider interface {
+	ISourceInfoProvider
+
+	// GetFileFlags returns the BSD st_flags of the file or folder.
+	GetFileFlags() (common.FileFlags, error)
+}
+
 type ISymlinkBearingSourceInfoProvider interface {
 	ISourceInfoProvider
 
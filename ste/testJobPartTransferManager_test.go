@@ -39,12 +39,24 @@ type testJobPartTransferManager struct {
 	jobPartMgr jobPartMgr
 	ctx        context.Context
 	status     common.TransferStatus
+
+	resumeOffset     int64
+	shuttingDown     bool
+	lastModifiedTime time.Time
 }
 
 func (t *testJobPartTransferManager) DeleteDestinationFileIfNecessary() bool {
 	return t.jobPartMgr.DeleteDestinationFileIfNecessary()
 }
 
+func (t *testJobPartTransferManager) BlockDelta() bool {
+	return false
+}
+
+func (t *testJobPartTransferManager) ContentDefinedBlocks() bool {
+	return false
+}
+
 func (t *testJobPartTransferManager) Info() *TransferInfo {
 	return t.info
 }
@@ -114,7 +126,7 @@ func (t *testJobPartTransferManager) ResourceDstData(dataFileToXfer []byte) (hea
 }
 
 func (t *testJobPartTransferManager) LastModifiedTime() time.Time {
-	panic("implement me")
+	return t.lastModifiedTime
 }
 
 func (t *testJobPartTransferManager) PreserveLastModifiedTime() (time.Time, bool) {
@@ -129,6 +141,14 @@ func (t *testJobPartTransferManager) MD5ValidationOption() common.HashValidation
 	panic("implement me")
 }
 
+func (t *testJobPartTransferManager) FsyncPolicy() common.FsyncPolicy {
+	return common.FsyncPolicy{}
+}
+
+func (t *testJobPartTransferManager) ContentHashType() common.ContentHashType {
+	panic("implement me")
+}
+
 func (t *testJobPartTransferManager) BlobTypeOverride() common.BlobType {
 	panic("implement me")
 }
@@ -174,11 +194,31 @@ func (t *testJobPartTransferManager) GetOverwriteOption() common.OverwriteOption
 }
 
 func (t *testJobPartTransferManager) GetForceIfReadOnly() bool {
-	panic("implement me")
+	return false
 }
 
 func (t *testJobPartTransferManager) ShouldDecompress() bool {
-	panic("implement me")
+	return false
+}
+
+func (t *testJobPartTransferManager) ShouldExtractArchivePack() bool {
+	return false
+}
+
+func (t *testJobPartTransferManager) ArchiveToExtract() common.ArchiveFormat {
+	return common.EArchiveFormat.None()
+}
+
+func (t *testJobPartTransferManager) UploadCompression() common.CompressionType {
+	return common.ECompressionType.None()
+}
+
+func (t *testJobPartTransferManager) ClientSideKeyWrapper() (common.KeyWrapper, error) {
+	return nil, nil
+}
+
+func (t *testJobPartTransferManager) ShouldDecrypt() bool {
+	return false
 }
 
 func (t *testJobPartTransferManager) GetSourceCompressionType() (common.CompressionType, error) {
@@ -231,7 +271,7 @@ func (t *testJobPartTransferManager) Cancel() {
 }
 
 func (t *testJobPartTransferManager) WasCanceled() bool {
-	panic("implement me")
+	return false
 }
 
 func (t *testJobPartTransferManager) IsLive() bool {
@@ -364,7 +404,6 @@ func (t *testJobPartTransferManager) ChunkStatusLogger() common.ChunkStatusLogge
 }
 
 func (t *testJobPartTransferManager) LogAtLevelForCurrentTransfer(level common.LogLevel, msg string) {
-	panic("implement me")
 }
 
 func (t *testJobPartTransferManager) GetOverwritePrompter() *overwritePrompter {
@@ -372,7 +411,7 @@ func (t *testJobPartTransferManager) GetOverwritePrompter() *overwritePrompter {
 }
 
 func (t *testJobPartTransferManager) GetFolderCreationTracker() FolderCreationTracker {
-	panic("implement me")
+	return &nullFolderTracker{}
 }
 
 func (t *testJobPartTransferManager) ShouldLog(level common.LogLevel) bool {
@@ -442,3 +481,21 @@ func (t *testJobPartTransferManager) TransferIndex() (partNum, transferIndex uin
 func (t *testJobPartTransferManager) RestartedTransfer() bool {
 	return false
 }
+
+func (t *testJobPartTransferManager) ResumeOffset() int64 {
+	return t.resumeOffset
+}
+
+func (t *testJobPartTransferManager) SetResumeOffset(offset int64) {
+	t.resumeOffset = offset
+}
+
+func (t *testJobPartTransferManager) ShuttingDown() bool {
+	return t.shuttingDown
+}
+
+func (t *testJobPartTransferManager) WaitForDiskSpace(size int64) error {
+	return nil
+}
+
+func (t *testJobPartTransferManager) CheckDiskSpace() {}
@@ -0,0 +1,185 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+const (
+	// how much the cap on the requests in progress to a busy endpoint is cut by
+	throttleDecreaseFactor = 0.5
+
+	// The requests that were already in progress when the first 503 came back are likely to get 503s too, so the cap
+	// isn't cut again for this long after it's cut, so as not to overreact to them
+	throttleDecreaseInterval = 5 * time.Second
+)
+
+var adaptiveThrottlingEnabled = sync.OnceValue(func() bool {
+	return strings.ToLower(common.GetEnvironmentVariable(common.EEnvironmentVariable.AdaptiveThrottling())) != "false"
+})
+
+// endpointThrottle cuts back the requests in progress to an endpoint when it says that it's busy, with a 503 such as
+// "Ingress is over the account limit", rather than letting the retries of every request keep it busy. The cap is
+// halved at most once per throttleDecreaseInterval, and grows by one for each cap's worth of requests that succeed,
+// as TCP's window does. Once it's back to the number of requests that were in progress when the endpoint was first
+// busy, it is lifted.
+type endpointThrottle struct {
+	mu           sync.Mutex
+	host         string
+	limit        float64 // 0 if the requests aren't capped
+	ceiling      float64 // the requests that were in progress when the endpoint was first busy
+	inProgress   int
+	lastDecrease time.Time
+	changed      chan struct{} // closed when a request finishes, or the cap grows, for those waiting for a slot
+}
+
+var (
+	endpointThrottlesMu sync.Mutex
+	endpointThrottles   = map[string]*endpointThrottle{}
+)
+
+func endpointThrottleFor(host string) *endpointThrottle {
+	endpointThrottlesMu.Lock()
+	defer endpointThrottlesMu.Unlock()
+	e, ok := endpointThrottles[host]
+	if !ok {
+		e = &endpointThrottle{host: host}
+		endpointThrottles[host] = e
+	}
+	return e
+}
+
+// acquire waits until the cap allows another request to be in progress
+func (e *endpointThrottle) acquire(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		if e.limit == 0 || float64(e.inProgress) < e.limit {
+			e.inProgress++
+			e.mu.Unlock()
+			return nil
+		}
+		if e.changed == nil {
+			e.changed = make(chan struct{})
+		}
+		changed := e.changed
+		e.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (e *endpointThrottle) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inProgress--
+	e.wake()
+}
+
+// wake lets those waiting for a slot look again. e.mu must be held.
+func (e *endpointThrottle) wake() {
+	if e.changed != nil {
+		close(e.changed)
+		e.changed = nil
+	}
+}
+
+// busy records a 503 from the endpoint, for a request that is still counted as in progress
+func (e *endpointThrottle) busy(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Sub(e.lastDecrease) < throttleDecreaseInterval {
+		return
+	}
+	e.lastDecrease = now
+	if e.limit == 0 {
+		e.ceiling = float64(e.inProgress)
+		e.limit = e.ceiling
+	}
+	e.limit = max(1, e.limit*throttleDecreaseFactor)
+	common.LogToJobLogWithPrefix(fmt.Sprintf("%s is busy, so the requests in progress to it are capped at %d until it recovers",
+		e.host, int(e.limit)), common.LogWarning)
+}
+
+// succeeded records a request that the endpoint wasn't too busy for
+func (e *endpointThrottle) succeeded() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.limit == 0 {
+		return
+	}
+	before := int(e.limit)
+	e.limit += 1 / e.limit
+	if e.limit >= e.ceiling {
+		e.limit = 0
+		common.LogToJobLogWithPrefix(fmt.Sprintf("%s has recovered, so the requests in progress to it are no longer capped", e.host), common.LogInfo)
+	}
+	if e.limit == 0 || int(e.limit) > before {
+		e.wake()
+	}
+}
+
+// throttleBackoffPolicy keeps each try within the cap of its endpoint, and tells the endpoint's throttle how it went.
+// A download holds its slot until its body has been read.
+type throttleBackoffPolicy struct{}
+
+func newThrottleBackoffPolicy() policy.Policy {
+	return throttleBackoffPolicy{}
+}
+
+func (throttleBackoffPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if !adaptiveThrottlingEnabled() {
+		return req.Next()
+	}
+	e := endpointThrottleFor(req.Raw().URL.Host)
+	if err := e.acquire(req.Raw().Context()); err != nil {
+		return nil, err
+	}
+	release := sync.OnceFunc(e.release)
+
+	resp, err := req.Next()
+	if resp != nil {
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			e.busy(time.Now())
+		} else if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			e.succeeded()
+		}
+	}
+	if err != nil || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		release()
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, err
+}
@@ -0,0 +1,182 @@
+package ste
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// tracer emits the spans for jobs, files and chunks. It does nothing until EnableTracing is called.
+var tracer trace.Tracer = noop.NewTracerProvider().Tracer("")
+var tracerProvider *sdktrace.TracerProvider
+
+// EnableTracing sends a span for every job, scan, file, chunk and commit to the OTLP/HTTP collector at endpoint.
+// Each job is its own trace, and the trace ID is the job ID, so that a job's spans are easy to find from its log.
+func EnableTracing(endpoint string, headers map[string]string) error {
+	exporter, err := newOTLPExporter(endpoint, headers)
+	if err != nil {
+		return err
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithIDGenerator(jobIDGenerator{}),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "azcopy"),
+			attribute.String("service.version", common.AzcopyVersion))))
+	tracer = tracerProvider.Tracer("github.com/Azure/azure-storage-azcopy/v10/ste")
+	return nil
+}
+
+// ShutdownTracing exports any spans that haven't been sent yet. It must be called before we exit.
+func ShutdownTracing(ctx context.Context) error {
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
+
+// jobSpanContexts holds the span context of each job's root span, so that spans started outside the STE (e.g. for
+// scanning) can be parented to it before the job's root span has even started
+var jobSpanContexts = struct {
+	sync.Mutex
+	m map[common.JobID]trace.SpanContext
+}{m: map[common.JobID]trace.SpanContext{}}
+
+func jobSpanContext(jobID common.JobID) trace.SpanContext {
+	jobSpanContexts.Lock()
+	defer jobSpanContexts.Unlock()
+
+	sc, ok := jobSpanContexts.m[jobID]
+	if !ok {
+		// the span ID is new for each process, since a resumed job gets a new root span in the same trace
+		sc = trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    jobTraceID(jobID),
+			SpanID:     newSpanID(),
+			TraceFlags: trace.FlagsSampled,
+		})
+		jobSpanContexts.m[jobID] = sc
+	}
+	return sc
+}
+
+// jobTraceID is the job ID, so that searching a tracing backend for the ID (without its dashes) finds the job
+func jobTraceID(jobID common.JobID) trace.TraceID {
+	var traceID trace.TraceID
+	b, _ := hex.DecodeString(strings.ReplaceAll(jobID.String(), "-", ""))
+	copy(traceID[:], b)
+	return traceID
+}
+
+type jobSpanKey struct{}
+
+// jobIDGenerator gives a job's root span the IDs from jobSpanContext, and random IDs to everything else
+type jobIDGenerator struct{}
+
+func (jobIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if sc, ok := ctx.Value(jobSpanKey{}).(trace.SpanContext); ok {
+		return sc.TraceID(), sc.SpanID()
+	}
+	var traceID trace.TraceID
+	_, _ = rand.Read(traceID[:])
+	return traceID, newSpanID()
+}
+
+func (jobIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+	var spanID trace.SpanID
+	_, _ = rand.Read(spanID[:])
+	return spanID
+}
+
+func startJobSpan(ctx context.Context, jobID common.JobID) (context.Context, trace.Span) {
+	if tracerProvider == nil {
+		return ctx, trace.SpanFromContext(ctx) // a no-op span
+	}
+	return tracer.Start(context.WithValue(ctx, jobSpanKey{}, jobSpanContext(jobID)), "job",
+		trace.WithNewRoot(), trace.WithAttributes(attribute.String("azcopy.job_id", jobID.String())))
+}
+
+// TraceScan starts the span for enumerating jobID's source (and destination, for sync), and returns the function that
+// ends it. The span is part of the job's trace, even though the job might not have been created yet.
+func TraceScan(jobID common.JobID) (end func(err error)) {
+	if tracerProvider == nil {
+		return func(error) {}
+	}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), jobSpanContext(jobID))
+	_, span := tracer.Start(ctx, "scan")
+	return func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// startFileSpan starts the span for jptm's transfer, which its chunks' spans are then parented to
+func startFileSpan(jptm *jobPartTransferMgr) {
+	if tracerProvider == nil {
+		return
+	}
+	info := jptm.Info()
+	jptm.ctx, jptm.span = tracer.Start(jptm.ctx, "file", trace.WithAttributes(
+		attribute.String("azcopy.source", common.URLStringExtension(info.Source).RedactSecretQueryParamForLogging()),
+		attribute.String("azcopy.destination", common.URLStringExtension(info.Destination).RedactSecretQueryParamForLogging()),
+		attribute.Int64("azcopy.size", info.SourceSize)))
+}
+
+func endFileSpan(jptm *jobPartTransferMgr) {
+	if jptm.span == nil {
+		return
+	}
+	status := jptm.jobPartPlanTransfer.TransferStatus()
+	jptm.span.SetAttributes(attribute.String("azcopy.status", status.String()))
+	switch status {
+	case common.ETransferStatus.Failed(), common.ETransferStatus.BlobTierFailure(), common.ETransferStatus.TierAvailabilityCheckFailure():
+		jptm.span.SetStatus(codes.Error, status.String())
+	}
+	jptm.span.End()
+}
+
+// startChunkSpans records how long the chunk waited in the queue since queuedAt, and starts the span for sending
+// or receiving it
+func startChunkSpans(jptm IJobPartTransferMgr, id common.ChunkID, queuedAt time.Time) trace.Span {
+	ctx := jptm.Context()
+	if tracerProvider == nil {
+		return trace.SpanFromContext(ctx)
+	}
+
+	_, queue := tracer.Start(ctx, "queue", trace.WithTimestamp(queuedAt))
+	queue.End()
+
+	name := "PUT"
+	if jptm.FromTo().IsDownload() {
+		name = "GET"
+	}
+	_, span := tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.Int64("azcopy.offset", id.OffsetInFile()),
+		attribute.Int64("azcopy.length", id.Length())))
+	return span
+}
+
+// startCommitSpan starts the span for a transfer's epilogue, e.g. putting the block list, or renaming the
+// downloaded file into place
+func startCommitSpan(jptm IJobPartTransferMgr) trace.Span {
+	_, span := tracer.Start(jptm.Context(), "commit")
+	return span
+}
@@ -0,0 +1,142 @@
+package ste
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestJobTraceExportedOverOTLP(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	var spans []otlpSpan
+	var resource []otlpKeyValue
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/v1/traces", r.URL.Path)
+		a.Equal("application/json", r.Header.Get("Content-Type"))
+		a.Equal("secret", r.Header.Get("Authorization"))
+
+		var traces otlpTraces
+		a.NoError(json.NewDecoder(r.Body).Decode(&traces))
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range traces.ResourceSpans {
+			resource = rs.Resource.Attributes
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	a.NoError(EnableTracing(collector.URL, map[string]string{"Authorization": "secret"}))
+	defer func() {
+		tracerProvider = nil
+		tracer = noop.NewTracerProvider().Tracer("")
+	}()
+
+	jobID := common.NewJobID()
+	endScan := TraceScan(jobID) // before the job's root span, as for copy
+	_, jobSpan := startJobSpan(context.Background(), jobID)
+	endScan(errors.New("listing failed"))
+	jobSpan.End()
+	a.NoError(ShutdownTracing(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	a.Len(spans, 2)
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	job, scan := byName["job"], byName["scan"]
+	traceID := strings.ReplaceAll(jobID.String(), "-", "")
+	a.Equal(traceID, job.TraceID)
+	a.Equal(traceID, scan.TraceID)
+	a.Empty(job.ParentSpanID)
+	a.Equal(job.SpanID, scan.ParentSpanID)
+	a.Equal(2, scan.Status.Code)
+	a.Equal("listing failed", scan.Status.Message)
+	a.Contains(job.Attributes, otlpKeyValue{Key: "azcopy.job_id", Value: map[string]any{"stringValue": jobID.String()}})
+	a.Contains(resource, otlpKeyValue{Key: "service.name", Value: map[string]any{"stringValue": "azcopy"}})
+}
+
+func TestNewOTLPExporterEndpoint(t *testing.T) {
+	a := assert.New(t)
+
+	e, err := newOTLPExporter("http://collector:4318", nil)
+	a.NoError(err)
+	a.Equal("http://collector:4318/v1/traces", e.endpoint)
+
+	e, err = newOTLPExporter("https://collector/custom/path", nil)
+	a.NoError(err)
+	a.Equal("https://collector/custom/path", e.endpoint)
+
+	_, err = newOTLPExporter("collector:4318", nil)
+	a.Error(err)
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	headers, err := ParseOTLPHeaders("api-key=abc%3D%3D, x-tenant = blue")
+	a.NoError(err)
+	a.Equal(map[string]string{"api-key": "abc==", "x-tenant": "blue"}, headers)
+
+	// base64 keys and tokens keep their plus signs
+	headers, err = ParseOTLPHeaders("Authorization=Bearer%20a+b/c==")
+	a.NoError(err)
+	a.Equal(map[string]string{"Authorization": "Bearer a+b/c=="}, headers)
+
+	headers, err = ParseOTLPHeaders("")
+	a.NoError(err)
+	a.Empty(headers)
+
+	_, err = ParseOTLPHeaders("novalue")
+	a.Error(err)
+}
+
+func TestOTLPRequestGroupsByResourceAndScope(t *testing.T) {
+	a := assert.New(t)
+
+	azcopy := resource.NewSchemaless(attribute.String("service.name", "azcopy"))
+	other := resource.NewSchemaless(attribute.String("service.name", "other"))
+	spans := tracetest.SpanStubs{
+		{Name: "job", Resource: azcopy, InstrumentationLibrary: instrumentation.Scope{Name: "azcopy"}},
+		{Name: "http", Resource: azcopy, InstrumentationLibrary: instrumentation.Scope{Name: "net/http"}},
+		{Name: "file", Resource: azcopy, InstrumentationLibrary: instrumentation.Scope{Name: "azcopy"}},
+		{Name: "elsewhere", Resource: other, InstrumentationLibrary: instrumentation.Scope{Name: "azcopy"}},
+	}.Snapshots()
+
+	traces := otlpRequest(spans)
+	a.Len(traces.ResourceSpans, 2)
+
+	first := traces.ResourceSpans[0]
+	a.Contains(first.Resource.Attributes, otlpKeyValue{Key: "service.name", Value: map[string]any{"stringValue": "azcopy"}})
+	a.Len(first.ScopeSpans, 2)
+	a.Equal("azcopy", first.ScopeSpans[0].Scope.Name)
+	a.Len(first.ScopeSpans[0].Spans, 2)
+	a.Equal("net/http", first.ScopeSpans[1].Scope.Name)
+	a.Len(first.ScopeSpans[1].Spans, 1)
+
+	second := traces.ResourceSpans[1]
+	a.Contains(second.Resource.Attributes, otlpKeyValue{Key: "service.name", Value: map[string]any{"stringValue": "other"}})
+	a.Len(second.ScopeSpans, 1)
+	a.Equal("elsewhere", second.ScopeSpans[0].Spans[0].Name)
+}
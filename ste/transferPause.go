@@ -0,0 +1,176 @@
+package ste
+
+import "sync"
+
+// TransferGate holds a job's workers back while its transfers are paused (e.g. by an operator sending SIGUSR1 to free up
+// bandwidth and IO), or once the job has been asked to shut down cleanly. Each job has its own, so that neither state
+// outlives the job it was asked for, such as in a daemon that runs one job after another.
+type TransferGate struct {
+	mu       sync.Mutex
+	paused   bool
+	draining bool
+
+	// unpaused is closed while transfers aren't paused. Workers that find transfers paused wait for it, rather than
+	// polling, so that they cost nothing while paused and pick up work again as soon as they are resumed.
+	unpaused chan struct{}
+
+	// tripped is set while the circuit breaker holds new transfers back, and untripped is closed while it isn't. It's
+	// kept apart from paused, so that the breaker never resumes what an operator paused, nor the other way round.
+	tripped   bool
+	untripped chan struct{}
+
+	// lowOnSpace is set while chunks and new transfers are held back until disk space has been freed, and spaceFreed is
+	// closed while they aren't. It's kept apart from paused and tripped, for the same reason as they are from each other.
+	lowOnSpace bool
+	spaceFreed chan struct{}
+}
+
+func newTransferGate() *TransferGate {
+	g := &TransferGate{unpaused: make(chan struct{}), untripped: make(chan struct{}), spaceFreed: make(chan struct{})}
+	close(g.unpaused)
+	close(g.untripped)
+	close(g.spaceFreed)
+	return g
+}
+
+// Pause stops chunk and transfer workers from picking up new work. Anything already in flight is allowed to
+// finish, so that the plan file is left consistent. It returns false if transfers were already paused.
+func (g *TransferGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.unpaused = make(chan struct{})
+	return true
+}
+
+// Resume undoes Pause. It returns false if transfers weren't paused.
+func (g *TransferGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.unpaused)
+	return true
+}
+
+func (g *TransferGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Drain stops new transfers from starting, and lifts any pause so that the ones in flight can finish.
+// It returns false if we were already draining.
+func (g *TransferGate) Drain() bool {
+	g.Resume()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		return false
+	}
+	g.draining = true
+	return true
+}
+
+func (g *TransferGate) Draining() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.draining
+}
+
+// trip holds new transfers back, for the circuit breaker, until untrip
+func (g *TransferGate) trip() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.tripped {
+		g.tripped = true
+		g.untripped = make(chan struct{})
+	}
+}
+
+func (g *TransferGate) untrip() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.tripped {
+		g.tripped = false
+		close(g.untripped)
+	}
+}
+
+// holdForSpace holds chunks and new transfers back, for the disk space guard, until releaseForSpace
+func (g *TransferGate) holdForSpace() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.lowOnSpace {
+		g.lowOnSpace = true
+		g.spaceFreed = make(chan struct{})
+	}
+}
+
+func (g *TransferGate) releaseForSpace() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.lowOnSpace {
+		g.lowOnSpace = false
+		close(g.spaceFreed)
+	}
+}
+
+// spaceHeld returns a channel to wait on while the disk space guard holds the workers back, or nil if it doesn't
+func (g *TransferGate) spaceHeld() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.lowOnSpace {
+		return nil
+	}
+	return g.spaceFreed
+}
+
+// reset lifts the pause, the drain, the circuit breaker and the hold for disk space, for a job that is being run
+// again in the same process
+func (g *TransferGate) reset() {
+	g.Resume()
+	g.untrip()
+	g.releaseForSpace()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.draining = false
+}
+
+// chunksHeld returns a channel to wait on while chunks are held back, or nil if they may be run
+func (g *TransferGate) chunksHeld() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return g.unpaused
+	} else if g.lowOnSpace {
+		return g.spaceFreed
+	}
+	return nil
+}
+
+// transfersHeld returns a channel to wait on while new transfers are held back, or nil if they may be started.
+// When draining, the channel is never closed: the transfers stay queued until the job is cancelled.
+func (g *TransferGate) transfersHeld() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		return neverOpens
+	} else if g.paused {
+		return g.unpaused
+	} else if g.lowOnSpace {
+		return g.spaceFreed
+	} else if g.tripped {
+		return g.untripped
+	}
+	return nil
+}
+
+var neverOpens = make(chan struct{})
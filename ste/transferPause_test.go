@@ -0,0 +1,108 @@
+package ste
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseAndResumeTransfers(t *testing.T) {
+	a := assert.New(t)
+	g := newTransferGate()
+
+	a.False(g.Paused())
+	a.False(g.Resume()) // nothing to resume
+	a.Nil(g.chunksHeld())
+	a.Nil(g.transfersHeld())
+
+	a.True(g.Pause())
+	a.True(g.Paused())
+	a.False(g.Pause()) // already paused, so there's nothing to announce a second time
+	held := g.chunksHeld()
+	a.NotNil(held)
+
+	a.True(g.Resume())
+	a.False(g.Paused())
+	select {
+	case <-held:
+	default:
+		a.Fail("workers waiting while paused weren't released on resume")
+	}
+	a.Nil(g.chunksHeld())
+}
+
+func TestDrainTransfersLiftsPause(t *testing.T) {
+	a := assert.New(t)
+	g := newTransferGate()
+
+	g.Pause()
+	a.True(g.Drain())
+	a.True(g.Draining())
+	a.False(g.Paused())         // otherwise the transfers in flight could never finish
+	a.Nil(g.chunksHeld())       // chunks of transfers in flight carry on
+	a.NotNil(g.transfersHeld()) // but no new transfers are started
+
+	a.False(g.Drain())
+
+	// a job run again in the same process starts afresh
+	g.reset()
+	a.False(g.Draining())
+	a.Nil(g.transfersHeld())
+}
+
+func TestChunksHeldWhilePausedThenRunOnResume(t *testing.T) {
+	a := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jm := &jobMgr{
+		ctx:  ctx,
+		gate: newTransferGate(),
+		xferChannels: XferChannels{
+			normalChunckCh: make(chan chunkFunc, 10),
+			lowChunkCh:     make(chan chunkFunc, 10),
+		},
+		poolSizingChannels: poolSizingChannels{
+			entryNotificationCh: make(chan struct{}, 1),
+			exitNotificationCh:  make(chan struct{}, 1),
+			scalebackRequestCh:  make(chan struct{}),
+		},
+	}
+
+	jm.gate.Pause()
+	go jm.chunkProcessor(0)
+
+	ran := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		jm.xferChannels.normalChunckCh <- func(int) { ran <- struct{}{} }
+	}
+
+	select {
+	case <-ran:
+		a.Fail("a chunk ran while paused")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	resumed := time.Now()
+	jm.gate.Resume()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ran:
+		case <-time.After(5 * time.Second):
+			a.FailNow("chunks weren't run after resuming")
+		}
+	}
+	// the workers wake as soon as they are resumed, rather than on their next poll
+	a.Less(time.Since(resumed), 90*time.Millisecond)
+
+	// and a paused worker still leaves the pool when asked to
+	jm.gate.Pause()
+	jm.poolSizingChannels.scalebackRequestCh <- struct{}{}
+	select {
+	case <-jm.poolSizingChannels.exitNotificationCh:
+	case <-time.After(5 * time.Second):
+		a.Fail("paused worker didn't scale back")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// the formats that the transfer report is written in
+const (
+	TransferReportFormatCSV  = "csv"
+	TransferReportFormatJSON = "json" // a JSON object per line, so that the report can be written as transfers finish
+)
+
+// TransferReportEntry is what the transfer report records about each transfer
+type TransferReportEntry struct {
+	JobID       common.JobID
+	Source      string
+	Destination string
+	EntityType  string
+	Status      string
+	Bytes       int64     // the size of the source
+	StartTime   time.Time `json:",omitzero"` // zero if the transfer was cancelled before it started
+	EndTime     time.Time
+	Retries     int64
+	ErrorCode   int32  `json:",omitempty"`
+	Error       string `json:",omitempty"`
+}
+
+var transferReportCSVHeader = []string{"JobID", "Source", "Destination", "EntityType", "Status", "Bytes", "StartTime",
+	"EndTime", "Retries", "ErrorCode", "Error"}
+
+func (e TransferReportEntry) csvRecord() []string {
+	startTime := ""
+	if !e.StartTime.IsZero() {
+		startTime = e.StartTime.UTC().Format(time.RFC3339Nano)
+	}
+	return []string{e.JobID.String(), e.Source, e.Destination, e.EntityType, e.Status, strconv.FormatInt(e.Bytes, 10),
+		startTime, e.EndTime.UTC().Format(time.RFC3339Nano), strconv.FormatInt(e.Retries, 10),
+		strconv.FormatInt(int64(e.ErrorCode), 10), e.Error}
+}
+
+// transferReport writes a line for each transfer as it finishes, so that even the largest jobs needn't keep them
+type transferReport struct {
+	sync.Mutex
+	file   *os.File
+	buffer *bufio.Writer
+	csv    *csv.Writer // nil for JSON
+	err    error       // the first error in writing the report, which is returned when it is closed
+}
+
+// transferReportWriter is the transfer report of this process. It is nil until EnableTransferReport is called.
+var transferReportWriter *transferReport
+
+// EnableTransferReport writes a line to the file at path, in format, for every transfer of the jobs run from now on.
+// The lines are buffered, so FlushTransferReport should be called when each job is done, and CloseTransferReport
+// before we exit.
+func EnableTransferReport(path, format string) error {
+	if format != TransferReportFormatCSV && format != TransferReportFormatJSON {
+		return fmt.Errorf("'%s' is not a report format. Use %s or %s", format, TransferReportFormatCSV, TransferReportFormatJSON)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating the transfer report: %w", err)
+	}
+
+	r := &transferReport{file: file, buffer: bufio.NewWriter(file)}
+	if format == TransferReportFormatCSV {
+		r.csv = csv.NewWriter(r.buffer)
+		r.err = r.csv.Write(transferReportCSVHeader)
+	}
+	transferReportWriter = r
+	return nil
+}
+
+// FlushTransferReport writes what has been reported so far, so that the report is complete for the jobs that are done
+func FlushTransferReport() error {
+	r := transferReportWriter
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	r.flush()
+	return r.err
+}
+
+// CloseTransferReport writes what is left of the transfer report, if there is one, and closes it
+func CloseTransferReport() error {
+	r := transferReportWriter
+	if r == nil {
+		return nil
+	}
+	transferReportWriter = nil
+	r.Lock()
+	defer r.Unlock()
+
+	r.flush()
+	r.setErr(r.file.Close())
+	return r.err
+}
+
+func (r *transferReport) flush() {
+	if r.csv != nil {
+		r.csv.Flush()
+		r.setErr(r.csv.Error())
+	}
+	r.setErr(r.buffer.Flush())
+}
+
+func (r *transferReport) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *transferReport) write(e TransferReportEntry) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.err != nil {
+		return // the report is incomplete already, so there's no use in going on
+	}
+	if r.csv != nil {
+		r.setErr(r.csv.Write(e.csvRecord()))
+		return
+	}
+	b, err := json.Marshal(e)
+	if err == nil {
+		b = append(b, '\n')
+		_, err = r.buffer.Write(b)
+	}
+	r.setErr(err)
+}
+
+// reportTransfer adds jptm's transfer, which has just finished, to the transfer report, if there is one
+func reportTransfer(jptm *jobPartTransferMgr) {
+	r := transferReportWriter
+	if r == nil {
+		return
+	}
+	r.write(newTransferReportEntry(jptm))
+}
+
+func newTransferReportEntry(jptm *jobPartTransferMgr) TransferReportEntry {
+	info := jptm.Info()
+	e := TransferReportEntry{
+		JobID:       info.JobID,
+		Source:      common.URLStringExtension(info.Source).RedactSecretQueryParamForLogging(),
+		Destination: common.URLStringExtension(info.Destination).RedactSecretQueryParamForLogging(),
+		EntityType:  info.EntityType.String(),
+		Status:      jptm.jobPartPlanTransfer.TransferStatus().String(),
+		Bytes:       info.SourceSize,
+		StartTime:   jptm.startTime,
+		EndTime:     time.Now(),
+		ErrorCode:   jptm.ErrorCode(),
+	}
+	if jptm.requestCounts != nil {
+		e.Retries = jptm.requestCounts.retries()
+	}
+	if msg := jptm.failureMessage.Load(); msg != nil {
+		// a line per transfer, however the error was laid out
+		e.Error = strings.ReplaceAll(*msg, "\n", " ")
+	}
+	return e
+}
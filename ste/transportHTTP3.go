@@ -0,0 +1,109 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// errHTTP3NotBuilt is why AZCOPY_HTTP3 can't be used with a build of AzCopy that was made without the http3 tag
+var errHTTP3NotBuilt = errors.New("this build of AzCopy doesn't include HTTP/3; build it with -tags http3 to use it")
+
+// http3RetryInterval is how long a host that HTTP/3 failed to is sent requests over TCP, before HTTP/3 is tried again
+const http3RetryInterval = 10 * time.Minute
+
+// http3FallbackTransport sends requests over HTTP/3 (QUIC), which doesn't suffer TCP's head-of-line blocking on a
+// lossy link, and falls back to the TCP transport, with HTTP/1.1 or HTTP/2, for a host that HTTP/3 fails to. A
+// network that blocks UDP then costs a failed attempt per host every http3RetryInterval, rather than failing the job.
+// QUIC can't go through an HTTP or SOCKS5 proxy, so requests that have one go over TCP.
+type http3FallbackTransport struct {
+	h3       http.RoundTripper
+	fallback http.RoundTripper
+	proxy    func(*http.Request) (*url.URL, error) // nil for none
+
+	mu       sync.Mutex
+	fellBack map[string]time.Time // by host, when to try HTTP/3 to it again
+}
+
+func newHTTP3FallbackTransport(h3, fallback http.RoundTripper, proxy func(*http.Request) (*url.URL, error)) *http3FallbackTransport {
+	return &http3FallbackTransport{h3: h3, fallback: fallback, proxy: proxy, fellBack: map[string]time.Time{}}
+}
+
+func (t *http3FallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || t.proxied(req) || !t.useHTTP3(req.URL.Host, time.Now()) {
+		return t.fallback.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body couldn't be sent again if HTTP/3 failed part way through it
+		return t.fallback.RoundTrip(req)
+	}
+
+	resp, err := t.h3.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	t.fallBack(req.URL.Host, err, time.Now())
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.fallback.RoundTrip(retry)
+}
+
+// proxied says whether req goes through a proxy, or would if the proxy could be found
+func (t *http3FallbackTransport) proxied(req *http.Request) bool {
+	if t.proxy == nil {
+		return false
+	}
+	u, err := t.proxy(req)
+	return u != nil || err != nil
+}
+
+func (t *http3FallbackTransport) useHTTP3(host string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	retryAt, ok := t.fellBack[host]
+	if ok && now.After(retryAt) {
+		delete(t.fellBack, host)
+		return true
+	}
+	return !ok
+}
+
+func (t *http3FallbackTransport) fallBack(host string, err error, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.fellBack[host]; !ok {
+		common.LogToJobLogWithPrefix(fmt.Sprintf("HTTP/3 to %s failed, so requests to it will go over TCP for %v: %v",
+			host, http3RetryInterval, err), common.LogWarning)
+	}
+	t.fellBack[host] = now.Add(http3RetryInterval)
+}
@@ -0,0 +1,48 @@
+//go:build http3
+
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Transport is the HTTP/3 transport that goes alongside the TCP one, t, with the same TLS config and timeouts
+func newHTTP3Transport(t *http.Transport, s TransportSettings) (http.RoundTripper, error) {
+	h3 := &http3.Transport{
+		QUICConfig: &quic.Config{
+			HandshakeIdleTimeout: t.TLSHandshakeTimeout,
+			MaxIdleTimeout:       t.IdleConnTimeout,
+		},
+		DisableCompression: t.DisableCompression,
+	}
+	if t.TLSClientConfig != nil {
+		h3.TLSClientConfig = t.TLSClientConfig.Clone()
+	}
+	if s.KeepAlive > 0 {
+		h3.QUICConfig.KeepAlivePeriod = s.KeepAlive
+	}
+	return h3, nil
+}
@@ -0,0 +1,213 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// TransportSettings are the knobs of the HTTP transport that transfers go over, which can be tuned through
+// environment variables for links with a long round trip, or servers that hold many connections open. A zero field
+// leaves the transport as it would otherwise be.
+type TransportSettings struct {
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSSessionCacheSize int
+	DisableHTTP2        bool
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	KeepAlive           time.Duration // negative to not send TCP keep-alive probes
+	HTTP3               bool          // try HTTP/3 first, falling back to TCP for a host it fails to
+}
+
+// readTransportSettings reads the transport settings from the environment
+func readTransportSettings() (TransportSettings, error) {
+	s := TransportSettings{}
+	ints := []struct {
+		envVar common.EnvironmentVariable
+		value  *int
+	}{
+		{common.EEnvironmentVariable.MaxIdleConnsPerHost(), &s.MaxIdleConnsPerHost},
+		{common.EEnvironmentVariable.TLSSessionCacheSize(), &s.TLSSessionCacheSize},
+	}
+	for _, i := range ints {
+		if raw := common.GetEnvironmentVariable(i.envVar); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 0 {
+				return s, fmt.Errorf("%s must be a whole number, not '%s'", i.envVar.Name, raw)
+			}
+			*i.value = v
+		}
+	}
+
+	durations := []struct {
+		envVar        common.EnvironmentVariable
+		value         *time.Duration
+		allowNegative bool
+	}{
+		{common.EEnvironmentVariable.IdleConnTimeout(), &s.IdleConnTimeout, false},
+		{common.EEnvironmentVariable.DialTimeout(), &s.DialTimeout, false},
+		{common.EEnvironmentVariable.TLSHandshakeTimeout(), &s.TLSHandshakeTimeout, false},
+		{common.EEnvironmentVariable.TCPKeepAlive(), &s.KeepAlive, true},
+	}
+	for _, d := range durations {
+		if raw := common.GetEnvironmentVariable(d.envVar); raw != "" {
+			v, err := time.ParseDuration(raw)
+			if err != nil || (v < 0 && !d.allowNegative) {
+				return s, fmt.Errorf("%s must be a duration, such as 30s or 2m, not '%s'", d.envVar.Name, raw)
+			}
+			*d.value = v
+		}
+	}
+
+	bools := []struct {
+		envVar common.EnvironmentVariable
+		value  *bool
+	}{
+		{common.EEnvironmentVariable.DisableHTTP2(), &s.DisableHTTP2},
+		{common.EEnvironmentVariable.HTTP3(), &s.HTTP3},
+	}
+	for _, b := range bools {
+		if raw := common.GetEnvironmentVariable(b.envVar); raw != "" {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				return s, fmt.Errorf("%s must be true or false, not '%s'", b.envVar.Name, raw)
+			}
+			*b.value = v
+		}
+	}
+
+	if s.HTTP3 {
+		if _, err := newHTTP3Transport(&http.Transport{}, s); err != nil {
+			return s, fmt.Errorf("%s: %w", common.EEnvironmentVariable.HTTP3().Name, err)
+		}
+	}
+	return s, nil
+}
+
+// transportSettings is read from the environment once, when the first client is made. As with the concurrency
+// settings, a mistake in them stops AzCopy.
+var transportSettings = sync.OnceValue(func() TransportSettings {
+	s, err := readTransportSettings()
+	if err != nil {
+		log.Fatalf("error parsing the transport settings: %v", err)
+	}
+	return s
+})
+
+// roundTripper is the transport that requests go over: t, or, with HTTP/3, an HTTP/3 transport that falls back to t.
+// HTTP/3 isn't used with --source-ip, --interface, --ip-version or --resolve, since its UDP sockets don't go through
+// the dialer.
+func (s TransportSettings) roundTripper(t *http.Transport) http.RoundTripper {
+	if !s.HTTP3 || common.DialingConfigured() {
+		return t
+	}
+	h3, err := newHTTP3Transport(t, s)
+	if err != nil {
+		// readTransportSettings has checked that it can be made, so this doesn't happen
+		return t
+	}
+	return newHTTP3FallbackTransport(h3, t, t.Proxy)
+}
+
+// apply sets the fields of t that s overrides, the TLS config of --ca-bundle and --client-cert, and the dialer of
+// --source-ip, --interface, --ip-version and --resolve
+func (s TransportSettings) apply(t *http.Transport) {
+	if s.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = s.IdleConnTimeout
+	}
+	if s.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = s.TLSHandshakeTimeout
+	}
+	if s.DialTimeout != 0 || s.KeepAlive != 0 || common.DialingConfigured() {
+		t.DialContext = common.NewDialer(net.Dialer{Timeout: s.DialTimeout, KeepAlive: s.KeepAlive}).DialContext
+	}
+	if config := common.ClientTLSConfig(); config != nil {
+		t.TLSClientConfig = config
+	}
+	if s.TLSSessionCacheSize > 0 {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(s.TLSSessionCacheSize)
+	}
+
+	// a transport with its own dialer or TLS config only tries HTTP/2 if it's told to, and one with an empty
+	// TLSNextProto never does
+	if s.DisableHTTP2 {
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		t.ForceAttemptHTTP2 = true
+		// cancelling a stalled request closes its HTTP/1.1 connection, but not an HTTP/2 one, which other requests
+		// share, so that's pinged once it's been quiet for as long, and closed if it doesn't answer
+		if stall := currentRetrySettings().StallTimeout; stall > 0 {
+			t.HTTP2 = &http.HTTP2Config{SendPingTimeout: stall}
+		}
+	}
+}
+
+// String is how the settings are given in the job log, with only those that are set
+func (s TransportSettings) String() string {
+	var parts []string
+	if s.MaxIdleConnsPerHost > 0 {
+		parts = append(parts, fmt.Sprintf("max idle connections per host %d", s.MaxIdleConnsPerHost))
+	}
+	if s.IdleConnTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("idle connection timeout %v", s.IdleConnTimeout))
+	}
+	if s.TLSSessionCacheSize > 0 {
+		parts = append(parts, fmt.Sprintf("TLS session cache %d", s.TLSSessionCacheSize))
+	}
+	if s.DisableHTTP2 {
+		parts = append(parts, "HTTP/2 disabled")
+	}
+	if s.HTTP3 {
+		parts = append(parts, "HTTP/3 with fallback to TCP")
+	}
+	if s.DialTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("dial timeout %v", s.DialTimeout))
+	}
+	if s.TLSHandshakeTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("TLS handshake timeout %v", s.TLSHandshakeTimeout))
+	}
+	if s.KeepAlive > 0 {
+		parts = append(parts, fmt.Sprintf("TCP keep-alive %v", s.KeepAlive))
+	} else if s.KeepAlive < 0 {
+		parts = append(parts, "TCP keep-alive off")
+	}
+	if len(parts) == 0 {
+		return "defaults"
+	}
+	return strings.Join(parts, ", ")
+}
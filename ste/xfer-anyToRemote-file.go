@@ -199,7 +199,9 @@ func anyToRemote(jptm IJobPartTransferMgr, pacer pacer, senderFactory senderFact
 	case common.EEntityType.FileProperties():
 		anyToRemote_fileProperties(jptm, info, pacer, senderFactory, sipf)
 	case common.EEntityType.File(), common.EEntityType.Hardlink():
-		if jptm.GetOverwriteOption() == common.EOverwriteOption.PosixProperties() {
+		if _, isLink := info.HardlinkTarget(); isLink && jptm.FromTo().IsUpload() {
+			anyToRemote_hardlink(jptm, info, pacer, senderFactory, sipf)
+		} else if jptm.GetOverwriteOption() == common.EOverwriteOption.PosixProperties() {
 			anyToRemote_fileProperties(jptm, info, pacer, senderFactory, sipf)
 		} else {
 			anyToRemote_file(jptm, info, pacer, senderFactory, sipf)
@@ -256,6 +258,16 @@ func anyToRemote_file(jptm IJobPartTransferMgr, info *TransferInfo, pacer pacer,
 		return
 	}
 
+	// a file that is to be encrypted on the client mustn't be uploaded unencrypted by a sender that can't encrypt it
+	if _, encrypts := s.(encryptingUploader); !encrypts && srcInfoProvider.IsLocal() {
+		if wrapper, err := jptm.ClientSideKeyWrapper(); err != nil || wrapper != nil {
+			jptm.LogSendError(info.Source, info.Destination, "Only block blobs can be encrypted on the client, and this file isn't uploaded as one", 0)
+			jptm.SetStatus(common.ETransferStatus.Failed())
+			jptm.ReportTransferDone()
+			return
+		}
+	}
+
 	// step 2b. Read chunk size and count from the sender (since it may have applied its own defaults and/or calculations to produce these values
 	numChunks := s.NumChunks()
 	if jptm.ShouldLog(common.LogInfo) {
@@ -403,8 +415,8 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 	ps := common.PrologueState{}
 
 	var md5Hasher hash.Hash
-	if jptm.ShouldPutMd5() {
-		md5Hasher = md5.New()
+	if jptm.ShouldPutMd5() && canPutContentHash(jptm, s) {
+		md5Hasher = jptm.ContentHashType().NewHasher()
 	} else {
 		md5Hasher = common.NewNullHasher()
 	}
@@ -415,13 +427,37 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 		defer close(md5Channel)
 	}
 
+	// a file that is compressed as it is uploaded has its own hash recorded too, to check what the blob decompresses to
+	var compressor compressingUploader
+	uncompressedMd5Hasher := common.NewNullHasher()
+	if cu, ok := s.(compressingUploader); ok && srcInfoProvider.IsLocal() && cu.Compression() != common.ECompressionType.None() {
+		compressor = cu
+		uncompressedMd5Hasher = md5.New()
+	}
+
+	// a file that is encrypted on the client as it is uploaded is encrypted chunk by chunk, each as a region of its own
+	var encryptionKey []byte
+	if eu, ok := s.(encryptingUploader); ok && srcInfoProvider.IsLocal() {
+		encryptionKey = eu.ContentEncryptionKey()
+	}
+
+	// the chunks of an uploader that splits files where their content says are of any size
+	var chunkEnds []int64
+	if vu, ok := s.(variableChunkUploader); ok && srcInfoProvider.IsLocal() {
+		chunkEnds = vu.ChunkEnds()
+	}
+
 	chunkIDCount := int32(0)
-	for startIndex := int64(0); startIndex < srcSize || isDummyChunkInEmptyFile(startIndex, srcSize); startIndex += int64(chunkSize) {
+	chunkLength := int64(chunkSize)
+	for startIndex := int64(0); startIndex < srcSize || isDummyChunkInEmptyFile(startIndex, srcSize); startIndex += chunkLength {
 
-		adjustedChunkSize := int64(chunkSize)
+		if chunkEnds != nil {
+			chunkLength = chunkEnds[chunkIDCount] - startIndex
+		}
+		adjustedChunkSize := chunkLength
 
 		// compute actual size of the chunk
-		if startIndex+int64(chunkSize) > srcSize {
+		if startIndex+adjustedChunkSize > srcSize {
 			adjustedChunkSize = srcSize - startIndex
 		}
 
@@ -441,6 +477,15 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 
 					// Wait until we have enough RAM, and when we do, prefetch the data for this chunk.
 					prefetchErr = chunkReader.BlockingPrefetch(srcFile, false)
+					if compressor != nil && prefetchErr == nil {
+						// compressed here, in order, so that the hash is of what is stored
+						chunkReader.WriteBufferTo(uncompressedMd5Hasher)
+						chunkReader, prefetchErr = common.NewCompressingChunkReader(chunkReader, compressor.Compression())
+					}
+					if encryptionKey != nil && prefetchErr == nil {
+						// encrypted here too, for the same reason
+						chunkReader, prefetchErr = common.NewEncryptingChunkReader(chunkReader, encryptionKey)
+					}
 					if prefetchErr == nil {
 						// *** NOTE: the hasher hashes the buffer as it is right now.  IF the chunk upload fails, then
 						//     the chunkReader will repeat the read from disk. So there is an essential dependency
@@ -497,10 +542,27 @@ func scheduleSendChunks(jptm IJobPartTransferMgr, srcPath string, srcFile common
 	}
 
 	if srcInfoProvider.IsLocal() && safeToUseHash {
+		if compressor != nil {
+			compressor.SetUncompressedMd5(uncompressedMd5Hasher.Sum(nil)) // before the send, which the uploader waits for
+		}
 		md5Channel <- md5Hasher.Sum(nil)
 	}
 }
 
+// canPutContentHash reports whether the sender can put the job's hash. Any can put an MD5, but only those that can
+// record the others in metadata can put those.
+func canPutContentHash(jptm IJobPartTransferMgr, s sender) bool {
+	hashType := jptm.ContentHashType()
+	if hashType == common.EContentHashType.MD5() {
+		return true
+	}
+	if hu, ok := s.(contentHashUploader); ok && hu.RecordsContentHashInMetadata() {
+		return true
+	}
+	jptm.LogAtLevelForCurrentTransfer(common.LogWarning, fmt.Sprintf("No hash will be put, since a %s hash can't be recorded at this destination", hashType))
+	return false
+}
+
 // Make reader for this chunk.
 // Each chunk reader also gets a factory to make a reader for the file, in case it needs to repeat its part
 // of the file read later (when doing a retry)
@@ -526,6 +588,8 @@ func isDummyChunkInEmptyFile(startIndex int64, fileSize int64) bool {
 // Complete epilogue. Handles both success and failure.
 func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s sender, sip ISourceInfoProvider) {
 	info := jptm.Info()
+	span := startCommitSpan(jptm)
+	defer span.End()
 	// allow our usual state tracking mechanism to keep count of how many epilogues are running at any given instant, for perf diagnostics
 	pseudoId := common.NewPseudoChunkIDForWholeFile(info.Source)
 	jptm.LogChunkStatus(pseudoId, common.EWaitReason.Epilogue())
@@ -588,6 +652,12 @@ func epilogueWithCleanupSendToRemote(jptm IJobPartTransferMgr, s sender, sip ISo
 		}
 	}
 
+	if jptm.IsLive() {
+		if err := setHnsAccessControlFromSource(jptm, sip); err != nil {
+			jptm.FailActiveSend("Setting owner and permissions", err)
+		}
+	}
+
 	if jptm.HoldsDestinationLock() { // TODO consider add test of jptm.IsDeadInflight here, so we can remove that from inside all the cleanup methods
 		s.Cleanup() // Perform jptm cleanup, if THIS jptm has the lock on the destination
 	}
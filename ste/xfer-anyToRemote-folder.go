@@ -97,5 +97,11 @@ func anyToRemote_folder(jptm IJobPartTransferMgr, info *TransferInfo, pacer pace
 		}
 	}
 
+	if jptm.IsLive() {
+		if err = setHnsAccessControlFromSource(jptm, srcInfoProvider); err != nil {
+			jptm.FailActiveSend("setting folder owner and permissions", err)
+		}
+	}
+
 	commonSenderCompletion(jptm, baseSender, info) // for consistency, always run the standard epilogue
 }
@@ -0,0 +1,50 @@
+package ste
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// anyToRemote_hardlink sends the stand-in for a hard link whose content was sent under another name.
+func anyToRemote_hardlink(jptm IJobPartTransferMgr, info *TransferInfo, pacer pacer, senderFactory senderFactory, sipf sourceInfoProviderFactory) {
+	// Check if cancelled
+	if jptm.WasCanceled() {
+		/* This is earliest we detect that jptm has been cancelled before we reach destination */
+		jptm.SetStatus(common.ETransferStatus.Cancelled())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	target, _ := info.HardlinkTarget()
+
+	// Create SIP
+	srcInfoProvider, err := sipf(jptm)
+	if err != nil {
+		jptm.LogSendError(info.Source, info.Destination, err.Error(), 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	baseSender, err := senderFactory(jptm, info.Destination, pacer, srcInfoProvider)
+	if err != nil {
+		jptm.LogSendError(info.Source, info.Destination, err.Error(), 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	s, ok := baseSender.(hardlinkSender)
+	if !ok {
+		jptm.LogSendError(info.Source, info.Destination, "sender implementation does not support hard links", 0)
+		jptm.SetStatus(common.ETransferStatus.Failed())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	err = s.SendHardlink(target)
+	if err != nil {
+		jptm.FailActiveSend("creating destination hard link representative", err)
+	}
+
+	commonSenderCompletion(jptm, baseSender, info)
+}
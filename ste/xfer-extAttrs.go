@@ -0,0 +1,90 @@
+package ste
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// addSourceExtAttrsToMetadata copies the source's extended attributes into metadata when --preserve-extattrs is on.
+// Attributes that don't fit within the metadata size limits are left behind, and named in the job log.
+func addSourceExtAttrsToMetadata(jptm IJobPartTransferMgr, sip ISourceInfoProvider, metadata common.Metadata) error {
+	if !jptm.Info().PreserveExtAttrs {
+		return nil
+	}
+
+	xaSIP, ok := sip.(IExtAttrBearingSourceInfoProvider)
+	if !ok {
+		return nil // S2S copies carry the attribute metadata along with everything else.
+	}
+
+	attrs, err := xaSIP.GetExtAttrs()
+	if err != nil {
+		return err
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	value, dropped := common.EncodeExtAttrs(attrs)
+	if len(dropped) > 0 {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("Extended attributes too large to store in metadata were not preserved: %s", strings.Join(dropped, ", ")))
+	}
+	if value == "" {
+		return nil
+	}
+
+	if _, exists := metadata[common.POSIXExtAttrsMeta]; exists {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("Metadata key %s was supplied by the user, so extended attributes were not preserved", common.POSIXExtAttrsMeta))
+		return nil
+	}
+	common.TryAddMetadata(metadata, common.POSIXExtAttrsMeta, value)
+	return nil
+}
+
+// applyExtAttrsFromSourceMetadata restores attributes stored by addSourceExtAttrsToMetadata.
+// Attributes already on the destination are overwritten, and logged when their value differs.
+// Writing the system namespace needs root; when we aren't, those attributes are skipped with a warning rather than failing the transfer.
+func applyExtAttrsFromSourceMetadata(jptm IJobPartTransferMgr, path string) error {
+	info := jptm.Info()
+	if !info.PreserveExtAttrs {
+		return nil
+	}
+
+	value, ok := info.SrcMetadata[common.POSIXExtAttrsMeta]
+	if !ok || value == nil {
+		return nil
+	}
+
+	attrs, err := common.DecodeExtAttrs(*value)
+	if err != nil {
+		return fmt.Errorf("decoding %s metadata: %w", common.POSIXExtAttrsMeta, err)
+	}
+
+	existing, err := common.GetExtAttrs(path)
+	if err != nil {
+		return err
+	}
+
+	for name, v := range attrs {
+		if old, ok := existing[name]; ok && !bytes.Equal(old, v) {
+			jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "Overwriting existing extended attribute "+name)
+		}
+
+		err = common.SetExtAttr(path, name, v)
+		if errors.Is(err, os.ErrPermission) {
+			jptm.LogAtLevelForCurrentTransfer(common.LogWarning, "Insufficient privileges to restore extended attribute "+name)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("setting extended attribute %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// addSourceFileFlagsToMetadata records the source's st_flags in metadata when --preserve-file-flags is on.
+func addSourceFileFlagsToMetadata(jptm IJobPartTransferMgr, sip ISourceInfoProvider, metadata common.Metadata) error {
+	if !jptm.Info().PreserveFileFlags {
+		return nil
+	}
+
+	ffSIP, ok := sip.(IFileFlagBearingSourceInfoProvider)
+	if !ok {
+		return nil // S2S copies carry the flags metadata along with everything else.
+	}
+
+	flags, err := ffSIP.GetFileFlags()
+	if err != nil {
+		return err
+	}
+	if flags == 0 {
+		return nil
+	}
+
+	if _, exists := metadata[common.POSIXFileFlagsMeta]; exists {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("Metadata key %s was supplied by the user, so file flags were not preserved", common.POSIXFileFlagsMeta))
+		return nil
+	}
+	common.TryAddMetadata(metadata, common.POSIXFileFlagsMeta, flags.String())
+	return nil
+}
+
+// applyFileFlagsFromSource restores the flags stored by addSourceFileFlagsToMetadata, or for a local to local copy,
+// those of the source itself. It must run after everything else has been done to the destination, since
+// immutable and append-only flags would make later changes fail.
+// SF_* flags can only be changed by root, so for anyone else they are dropped with a warning rather than failing the transfer.
+func applyFileFlagsFromSource(jptm IJobPartTransferMgr, path string, isFolder bool) error {
+	info := jptm.Info()
+	if !info.PreserveFileFlags {
+		return nil
+	}
+
+	var flags common.FileFlags
+	if jptm.FromTo() == common.EFromTo.LocalLocal() {
+		var err error
+		if flags, err = common.GetFileFlags(info.Source); err != nil {
+			return err
+		}
+	} else {
+		value, ok := info.SrcMetadata[common.POSIXFileFlagsMeta]
+		if !ok || value == nil {
+			return nil
+		}
+		var err error
+		if flags, err = common.ParseFileFlags(*value); err != nil {
+			return fmt.Errorf("decoding %s metadata: %w", common.POSIXFileFlagsMeta, err)
+		}
+	}
+
+	// snapshot files belong to the filesystem which made them; the flag can't be set by hand
+	flags &^= common.SF_SNAPSHOT
+
+	if flags&common.SF_SETTABLE != 0 && os.Getuid() != 0 {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("Only root can set system file flags, so %s were not restored", (flags&common.SF_SETTABLE).String()))
+		flags &^= common.SF_SETTABLE
+	}
+
+	// files for the folder's contents may still be arriving, so don't lock it against them
+	if isFolder && flags&common.FileFlagsBlockingWrites != 0 {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("File flags %s were not restored on the folder, since they would stop its contents being written", (flags&common.FileFlagsBlockingWrites).String()))
+		flags &^= common.FileFlagsBlockingWrites
+	}
+
+	existing, err := common.GetFileFlags(path)
+	if err != nil {
+		return err
+	}
+	if existing == flags {
+		return nil
+	}
+
+	err = common.SetFileFlags(path, flags)
+	if errors.Is(err, os.ErrPermission) {
+		// securelevel > 0 stops even root changing SF_* flags
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning, "Insufficient privileges to restore file flags "+flags.String())
+		return nil
+	}
+	return err
+}
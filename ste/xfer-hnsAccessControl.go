@@ -0,0 +1,163 @@
+package ste
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/file"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// hnsAccounts remembers, per blob endpoint, whether the account has a hierarchical namespace,
+// so that only the first transfer to each one has to ask.
+var hnsAccounts sync.Map
+
+// isHnsAccount reports whether the account behind sc has a hierarchical namespace, and so takes x-ms-owner,
+// x-ms-group and x-ms-permissions. BlobFS locations always do; Blob locations might (Blob NFS 3.0 accounts do).
+func isHnsAccount(jptm IJobPartTransferMgr, loc common.Location, sc *common.ServiceClient, container string) (bool, error) {
+	if loc == common.ELocation.BlobFS() {
+		return true, nil
+	}
+
+	bsc, err := sc.BlobServiceClient()
+	if err != nil {
+		return false, err
+	}
+	key := bsc.URL()
+	if u, err := url.Parse(key); err == nil {
+		key = u.Host
+	}
+	if known, ok := hnsAccounts.Load(key); ok {
+		return known.(bool), nil
+	}
+
+	// ask the container rather than the service, since a container SAS can do that
+	resp, err := bsc.NewContainerClient(container).GetAccountInfo(jptm.Context(), nil)
+	if err != nil {
+		return false, err
+	}
+	hns := resp.IsHierarchicalNamespaceEnabled != nil && *resp.IsHierarchicalNamespaceEnabled
+	hnsAccounts.Store(key, hns)
+	return hns, nil
+}
+
+// setHnsAccessControlFromSource gives an uploaded file or folder the owner, group and mode of its local source,
+// when --preserve-posix-properties is on and the destination account has a hierarchical namespace.
+// This is on top of the POSIX metadata, and is what NFS clients of the account will see.
+func setHnsAccessControlFromSource(jptm IJobPartTransferMgr, sip ISourceInfoProvider) error {
+	info := jptm.Info()
+	fromTo := jptm.FromTo()
+	if !info.PreservePOSIXProperties || !fromTo.IsUpload() || !fromTo.To().SupportsHnsACLs() ||
+		info.EntityType == common.EEntityType.Symlink() {
+		return nil
+	}
+
+	unixSIP, ok := sip.(IUNIXPropertyBearingSourceInfoProvider)
+	if !ok || !unixSIP.HasUNIXProperties() {
+		return nil
+	}
+
+	if hns, err := isHnsAccount(jptm, fromTo.To(), jptm.DstServiceClient(), info.DstContainer); err != nil {
+		return fmt.Errorf("checking for a hierarchical namespace: %w", err)
+	} else if !hns {
+		return nil
+	}
+
+	stat, err := unixSIP.GetUNIXProperties()
+	if err != nil {
+		return err
+	}
+
+	dsc, err := jptm.DstServiceClient().DatalakeServiceClient()
+	if err != nil {
+		return err
+	}
+	fileClient := dsc.NewFileSystemClient(info.DstContainer).NewFileClient(info.DstFilePath)
+
+	options := &file.SetAccessControlOptions{
+		Owner:       to.Ptr(strconv.FormatUint(uint64(stat.Owner()), 10)),
+		Group:       to.Ptr(strconv.FormatUint(uint64(stat.Group()), 10)),
+		Permissions: to.Ptr(common.FormatHnsPermissions(stat.FileMode())),
+	}
+	_, err = fileClient.SetAccessControl(jptm.Context(), options)
+
+	// Without NFS v3, an account wants Entra object IDs rather than UIDs and GIDs. The mode still means something there.
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning,
+			fmt.Sprintf("The destination did not accept owner %s and group %s, so only the permissions were set: %s", *options.Owner, *options.Group, respErr.ErrorCode))
+		_, err = fileClient.SetAccessControl(jptm.Context(), &file.SetAccessControlOptions{Permissions: options.Permissions})
+	}
+	return err
+}
+
+// applyHnsAccessControlFromSource is the reverse of setHnsAccessControlFromSource: it chowns and chmods a downloaded
+// file or folder to match the x-ms-owner, x-ms-group and x-ms-permissions of a source with a hierarchical namespace.
+// Owners that aren't numeric, and aren't in the --idmap-file, have no local equivalent and are left alone,
+// as is ownership on FreeBSD unless restoringOwner.
+func applyHnsAccessControlFromSource(jptm IJobPartTransferMgr, path string) error {
+	info := jptm.Info()
+	fromTo := jptm.FromTo()
+	if !info.PreservePOSIXProperties || !fromTo.IsDownload() || !fromTo.From().SupportsHnsACLs() || runtime.GOOS == "windows" {
+		return nil
+	}
+
+	if hns, err := isHnsAccount(jptm, fromTo.From(), jptm.SrcServiceClient(), info.SrcContainer); err != nil {
+		return fmt.Errorf("checking for a hierarchical namespace: %w", err)
+	} else if !hns {
+		return nil
+	}
+
+	dsc, err := jptm.SrcServiceClient().DatalakeServiceClient()
+	if err != nil {
+		return err
+	}
+	resp, err := dsc.NewFileSystemClient(info.SrcContainer).NewFileClient(info.SrcFilePath).GetAccessControl(jptm.Context(), nil)
+	if err != nil {
+		return err
+	}
+
+	uid, gid := -1, -1 // -1 leaves that ID unchanged
+	if resp.Owner != nil {
+		if id, ok := common.MapOwner(*resp.Owner); ok {
+			uid = int(id)
+		}
+	}
+	if resp.Group != nil {
+		if id, ok := common.MapGroup(*resp.Group); ok {
+			gid = int(id)
+		}
+	}
+	if (uid != -1 || gid != -1) && (runtime.GOOS != "freebsd" || restoringOwner(info)) {
+		if err := os.Lchown(path, uid, gid); errors.Is(err, syscall.EPERM) {
+			jptm.LogAtLevelForCurrentTransfer(common.LogWarning, "Only root can give files away, so the owner and group were not restored")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if resp.Permissions != nil {
+		mode, err := common.ParseHnsPermissions(*resp.Permissions)
+		if err != nil {
+			return err
+		}
+		fileMode := os.FileMode(mode & 0777)
+		if mode&common.S_ISVTX != 0 {
+			fileMode |= os.ModeSticky
+		}
+		// chown may have cleared setuid and setgid, but ADLS doesn't keep them anyway
+		if err := os.Chmod(path, fileMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
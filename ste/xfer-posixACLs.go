@@ -0,0 +1,49 @@
+package ste
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// addSourceACLsToMetadata copies the source's ACLs into metadata when --preserve-acls is on.
+// The caller must pass metadata it owns, since the same source metadata map is shared between transfers.
+func addSourceACLsToMetadata(jptm IJobPartTransferMgr, sip ISourceInfoProvider, metadata common.Metadata) error {
+	if !jptm.Info().PreserveACLs {
+		return nil
+	}
+
+	aclSIP, ok := sip.(IACLBearingSourceInfoProvider)
+	if !ok {
+		return nil // S2S copies carry the ACL metadata along with everything else.
+	}
+
+	access, def, err := aclSIP.GetACLs()
+	if err != nil {
+		return err
+	}
+
+	common.AddACLsToBlobMetadata(access, def, metadata)
+	return nil
+}
+
+// applyACLsFromSourceMetadata reapplies ACLs stored by addSourceACLsToMetadata to a downloaded file or folder.
+// Sources that were uploaded without --preserve-acls simply have nothing to apply.
+func applyACLsFromSourceMetadata(jptm IJobPartTransferMgr, path string) error {
+	info := jptm.Info()
+	if !info.PreserveACLs {
+		return nil
+	}
+
+	access, def, err := common.ReadACLsFromMetadata(info.SrcMetadata)
+	if err != nil {
+		return err
+	}
+	if access == nil && def == nil {
+		return nil
+	}
+
+	err = common.SetFileACLs(path, access, def)
+	if err == nil {
+		jptm.Log(common.LogDebug, "Preserved ACLs for "+path)
+	}
+	return err
+}
@@ -24,12 +24,15 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 )
 
@@ -43,6 +46,8 @@ func remoteToLocal(jptm IJobPartTransferMgr, pacer pacer, df downloaderFactory)
 		remoteToLocal_folder(jptm, pacer, df)
 	} else if info.EntityType == common.EEntityType.Symlink() {
 		remoteToLocal_symlink(jptm, pacer, df)
+	} else if _, isHardlink := info.HardlinkTarget(); isHardlink {
+		remoteToLocal_hardlink(jptm, pacer, df)
 	} else {
 		remoteToLocal_file(jptm, pacer, df)
 	}
@@ -75,6 +80,12 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 		jptm.ReportTransferDone()
 		return
 	}
+
+	// An earlier run may have left part of the file saved for us to carry on from. Take the offset out of the plan now,
+	// since it stops describing the file if we start it afresh. It is recorded again as the download goes.
+	savedOffset := jptm.ResumeOffset()
+	jptm.SetResumeOffset(0)
+
 	// if the force Write flags is set to false or prompt
 	// then check the file exists at the remote location
 	// if it does, react accordingly
@@ -107,8 +118,8 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 	if jptm.MD5ValidationOption() == common.EHashValidationOption.FailIfDifferentOrMissing() {
 		// We can make a check early on MD5 existence and fail the transfer if it's not present.
 		// This will save hours in the event a user has say, a several hundred gigabyte file.
-		if len(info.SrcHTTPHeaders.ContentMD5) == 0 {
-			jptm.LogDownloadError(info.Source, info.Destination, errExpectedMd5Missing.Error(), 0)
+		if len(expectedContentHash(jptm)) == 0 {
+			jptm.LogDownloadError(info.Source, info.Destination, expectedHashMissingError(jptm.ContentHashType()).Error(), 0)
 			jptm.SetStatus(common.ETransferStatus.Failed())
 			jptm.ReportTransferDone()
 			return
@@ -129,13 +140,65 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 	//    }
 
 	var dstFile io.WriteCloser
-	if ctdl, ok := dl.(creationTimeDownloader); info.Destination != os.DevNull && ok { // ctdl never needs to handle devnull
+
+	// how much of the file was saved before the job was shut down, and needn't be downloaded again
+	resumeFrom := int64(0)
+	if format := jptm.ArchiveToExtract(); format != common.EArchiveFormat.None() {
+		// The archive's files are extracted into the folder it would have been saved in, as it is downloaded,
+		// so there is no file of the archive's own
+		failExtraction := func(err error) {
+			jptm.LogDownloadError(info.Source, info.Destination, "Archive Extraction Error "+err.Error(), 0)
+			jptm.SetStatus(common.ETransferStatus.Failed())
+			epilogueWithCleanupDownload(jptm, dl, nil, nil)
+		}
+		err := jptm.WaitUntilLockDestination(jptm.Context())
+		if err != nil {
+			failExtraction(err)
+			return
+		}
+		ct := common.ECompressionType.None()
+		if jptm.ShouldDecompress() {
+			if ct, err = jptm.GetSourceCompressionType(); err != nil {
+				failExtraction(err)
+				return
+			}
+		}
+		decrypter, err := clientSideDecrypter(jptm)
+		if err != nil {
+			failExtraction(err)
+			return
+		}
+		if fileSize == 0 { // there's nothing to extract
+			dl.Prologue(jptm)
+			epilogueWithCleanupDownload(jptm, dl, nil, nil)
+			return
+		}
+
+		dstDir := filepath.Dir(info.Destination)
+		jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "will be extracted from "+format.String()+" into "+dstDir)
+		dstFile = common.NewArchiveExtractingWriter(format, dstDir, common.ArchiveExtractOptions{
+			Overwrite: jptm.GetOverwriteOption() == common.EOverwriteOption.True(),
+			SkipIndex: jptm.ShouldExtractArchivePack(),
+		})
+		if ct != common.ECompressionType.None() {
+			// an archive stored with a content encoding is decompressed on its way to the extractor
+			dstFile = common.NewDecompressingWriter(verifyDecompression(jptm, dstFile), ct)
+		}
+		if decrypter != nil {
+			dstFile = decrypter.NewDecryptingWriter(dstFile) // before anything else, since it's the encrypted blob that is downloaded
+		}
+	} else if ctdl, ok := dl.(creationTimeDownloader); info.Destination != os.DevNull && ok { // ctdl never needs to handle devnull
 		failFileCreation := func(err error) {
 			jptm.LogDownloadError(info.Source, info.Destination, "File Creation Error "+err.Error(), 0)
 			jptm.SetStatus(common.ETransferStatus.Failed())
 			// use standard epilogue for consistency, but force release of file count (without an actual file) if necessary
 			epilogueWithCleanupDownload(jptm, dl, nil, nil)
 		}
+		// wait for room for the file, rather than filling the disk up with it
+		if err := jptm.WaitForDiskSpace(diskSpaceNeeded(jptm, fileSize-savedOffset)); err != nil {
+			failFileCreation(err)
+			return
+		}
 		// block until we can safely use a file handle
 		err := jptm.WaitUntilLockDestination(jptm.Context())
 		if err != nil {
@@ -156,6 +219,14 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 			// Because we have better ability to report unsupported compression types here, with clear "transfer failed" handling,
 			// and we still need to set size to zero here, so relying on enumeration more wouldn't simply this code much, if at all.
 		}
+		decrypter, err := clientSideDecrypter(jptm)
+		if err != nil {
+			failFileCreation(err)
+			return
+		}
+		if decrypter != nil {
+			size = 0 // since it's smaller once decrypted
+		}
 
 		// Normal scenario, create the destination file as expected
 		// Use pseudo chunk id to allow our usual state tracking mechanism to keep count of how many
@@ -164,13 +235,18 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 		// We create the file to a temporary location with name .azcopy-<jobID>-<actualName> and then move it
 		// to correct name.
 		pseudoId := common.NewPseudoChunkIDForWholeFile(info.Source)
-		jptm.LogChunkStatus(pseudoId, common.EWaitReason.CreateLocalFile())
 		var needChunks bool
-		dstFile, needChunks, err = ctdl.CreateFile(jptm, info.getDownloadPath(), size, writeThrough, jptm.GetFolderCreationTracker())
-		jptm.LogChunkStatus(pseudoId, common.EWaitReason.ChunkDone()) // normal setting to done doesn't apply to these pseudo ids
-		if err != nil {
-			failFileCreation(err)
-			return
+		if partial := openPartialDownload(jptm, savedOffset, downloadChunkSize); partial != nil {
+			// carry on from where the job got to before it was shut down
+			dstFile, needChunks, resumeFrom = partial, true, savedOffset
+		} else {
+			jptm.LogChunkStatus(pseudoId, common.EWaitReason.CreateLocalFile())
+			dstFile, needChunks, err = ctdl.CreateFile(jptm, info.getDownloadPath(), size, writeThrough, jptm.GetFolderCreationTracker())
+			jptm.LogChunkStatus(pseudoId, common.EWaitReason.ChunkDone()) // normal setting to done doesn't apply to these pseudo ids
+			if err != nil {
+				failFileCreation(err)
+				return
+			}
 		}
 
 		if !needChunks { // If no chunks need to be transferred (e.g. this is 0-bytes long, a symlink, etc.), treat it as a 0-byte transfer
@@ -183,11 +259,14 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 			jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "will be decompressed from "+ct.String())
 
 			// wrap for automatic decompression
-			dstFile = common.NewDecompressingWriter(dstFile, ct)
+			dstFile = common.NewDecompressingWriter(verifyDecompression(jptm, dstFile), ct)
 			// why don't we just let Go's network stack automatically decompress for us? Because
 			// 1. Then we can't check the MD5 hash (since logically, any stored hash should be the hash of the file that exists in Storage, i.e. the compressed one)
 			// 2. Then we can't pre-plan a certain number of fixed-size chunks (which is required by the way our architecture currently works).
 		}
+		if decrypter != nil {
+			dstFile = decrypter.NewDecryptingWriter(dstFile)
+		}
 	} else {
 		// step 4b: special handling for empty files
 		if fileSize == 0 {
@@ -221,6 +300,11 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 			// use standard epilogue for consistency, but force release of file count (without an actual file) if necessary
 			epilogueWithCleanupDownload(jptm, dl, nil, nil)
 		}
+		// wait for room for the file, rather than filling the disk up with it
+		if err := jptm.WaitForDiskSpace(diskSpaceNeeded(jptm, fileSize-savedOffset)); err != nil {
+			failFileCreation(err)
+			return
+		}
 		// block until we can safely use a file handle
 		err := jptm.WaitUntilLockDestination(jptm.Context())
 		if err != nil {
@@ -231,6 +315,9 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 		if strings.EqualFold(info.Destination, common.Dev_Null) {
 			// the user wants to discard the downloaded data
 			dstFile = devNullWriter{}
+		} else if partial := openPartialDownload(jptm, savedOffset, downloadChunkSize); partial != nil {
+			// carry on from where the job got to before it was shut down
+			dstFile, resumeFrom = partial, savedOffset
 		} else {
 			// Normal scenario, create the destination file as expected
 			// Use pseudo chunk id to allow our usual state tracking mechanism to keep count of how many
@@ -266,27 +353,55 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 			return
 		}*/
 
+	if resumeFrom > 0 {
+		jptm.LogAtLevelForCurrentTransfer(common.LogInfo, fmt.Sprintf("Carrying on from byte %d, which was saved before the job stopped", resumeFrom))
+	}
+
 	// step 5a: compute num chunks
 	numChunks := uint32(0)
-	if rem := fileSize % downloadChunkSize; rem == 0 {
-		numChunks = uint32(fileSize / downloadChunkSize)
+	if rem := (fileSize - resumeFrom) % downloadChunkSize; rem == 0 {
+		numChunks = uint32((fileSize - resumeFrom) / downloadChunkSize)
 	} else {
-		numChunks = uint32(fileSize/downloadChunkSize + 1)
+		numChunks = uint32((fileSize-resumeFrom)/downloadChunkSize + 1)
 	}
 
 	// step 5b: create destination writer
 	chunkLogger := jptm.ChunkStatusLogger()
-	sourceMd5Exists := len(info.SrcHTTPHeaders.ContentMD5) > 0
-	dstWriter := common.NewChunkedFileWriter(
-		jptm.Context(),
-		jptm.SlicePool(),
-		jptm.CacheLimiter(),
-		chunkLogger,
-		dstFile,
-		numChunks,
-		MaxRetryPerDownloadBody,
-		jptm.MD5ValidationOption(),
-		sourceMd5Exists)
+	sourceMd5Exists := len(expectedContentHash(jptm)) > 0
+	var dstWriter common.ChunkedFileWriter
+	if resumeFrom > 0 {
+		dstWriter = common.ResumeChunkedFileWriter(
+			jptm.Context(),
+			jptm.SlicePool(),
+			jptm.CacheLimiter(),
+			chunkLogger,
+			dstFile,
+			io.NewSectionReader(dstFile.(*os.File), 0, resumeFrom),
+			numChunks,
+			MaxRetryPerDownloadBody,
+			jptm.MD5ValidationOption(),
+			jptm.ContentHashType(),
+			sourceMd5Exists)
+	} else {
+		dstWriter = common.NewChunkedFileWriter(
+			jptm.Context(),
+			jptm.SlicePool(),
+			jptm.CacheLimiter(),
+			chunkLogger,
+			dstFile,
+			numChunks,
+			MaxRetryPerDownloadBody,
+			jptm.MD5ValidationOption(),
+			jptm.ContentHashType(),
+			sourceMd5Exists)
+	}
+	if canCarryOnDownload(jptm) {
+		// record how much is saved as we go, so that if AzCopy is killed, or the machine goes down, the job can carry on
+		// from there when it's resumed, rather than start the file again
+		jptm.SetResumeOffset(resumeFrom)
+		dstWriter.CheckpointEvery(downloadCheckpointInterval, jptm.SetResumeOffset)
+	}
+	dstWriter.SyncWhileSaving(jptm.FsyncPolicy())
 
 	// step 5c: run prologue in downloader (here it can, for example, create things that will require cleanup in the epilogue)
 	common.GetLifecycleMgr().E2EAwaitAllowOpenFiles()
@@ -306,7 +421,7 @@ func remoteToLocal_file(jptm IJobPartTransferMgr, pacer pacer, df downloaderFact
 	// eventually reach numChunks, since we have no better short-term alternative.
 
 	chunkCount := uint32(0)
-	for startIndex := int64(0); startIndex < fileSize; startIndex += downloadChunkSize {
+	for startIndex := resumeFrom; startIndex < fileSize; startIndex += downloadChunkSize {
 		adjustedChunkSize := downloadChunkSize
 
 		// compute exact size of the chunk
@@ -351,27 +466,123 @@ func createDestinationFile(jptm IJobPartTransferMgr, destination string, size in
 		// Because we have better ability to report unsupported compression types here, with clear "transfer failed" handling,
 		// and we still need to set size to zero here, so relying on enumeration more wouldn't simply this code much, if at all.
 	}
-
-	var dstFile io.WriteCloser
-	dstFile, err = common.CreateFileOfSizeWithWriteThroughOption(destination, size, writeThrough, jptm.GetFolderCreationTracker(), jptm.GetForceIfReadOnly())
+	decrypter, err := clientSideDecrypter(jptm)
 	if err != nil {
 		return nil, err
 	}
+	if decrypter != nil {
+		size = 0 // since it's smaller once decrypted
+	}
+
+	var dstFile io.WriteCloser
+	if leaveHoles(jptm) {
+		// created empty and then extended, which leaves the whole file as a hole until we write to it
+		f, err := common.CreateFileOfSizeWithWriteThroughOption(destination, 0, writeThrough, jptm.GetFolderCreationTracker(), jptm.GetForceIfReadOnly())
+		if err != nil {
+			return nil, err
+		}
+		if err = f.Truncate(size); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		dstFile = f
+	} else {
+		dstFile, err = common.CreateFileOfSizeWithWriteThroughOption(destination, size, writeThrough, jptm.GetFolderCreationTracker(), jptm.GetForceIfReadOnly())
+		if err != nil {
+			return nil, err
+		}
+	}
 	if jptm.ShouldDecompress() {
 		jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "will be decompressed from "+ct.String())
 
 		// wrap for automatic decompression
-		dstFile = common.NewDecompressingWriter(dstFile, ct)
+		dstFile = common.NewDecompressingWriter(verifyDecompression(jptm, dstFile), ct)
 		// why don't we just let Go's network stack automatically decompress for us? Because
 		// 1. Then we can't check the MD5 hash (since logically, any stored hash should be the hash of the file that exists in Storage, i.e. the compressed one)
 		// 2. Then we can't pre-plan a certain number of fixed-size chunks (which is required by the way our architecture currently works).
 	}
+	if decrypter != nil {
+		dstFile = decrypter.NewDecryptingWriter(dstFile)
+	}
 	return dstFile, nil
 }
 
+// clientSideDecrypter returns what decrypts the blob this transfer downloads, if it was encrypted on the client and is to
+// be decrypted, or nil if not. The blob's key is unwrapped here, before anything is created, so that failing to unwrap
+// it leaves nothing to clean up.
+func clientSideDecrypter(jptm IJobPartTransferMgr) (*common.ContentDecrypter, error) {
+	if !jptm.ShouldDecrypt() {
+		return nil, nil
+	}
+	wrapper, err := jptm.ClientSideKeyWrapper()
+	if err != nil {
+		return nil, err
+	}
+	rawData, _ := common.TryReadMetadata(jptm.Info().SrcMetadata, common.ClientSideEncryptionMeta)
+	if rawData == nil {
+		return nil, errors.New("the blob's " + common.ClientSideEncryptionMeta + " metadata is empty")
+	}
+	data, err := common.ParseClientSideEncryptionData(*rawData)
+	if err != nil {
+		return nil, err
+	}
+	jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "will be decrypted with the key "+data.WrappedContentKey.KeyId)
+	return data.NewContentDecrypter(jptm.Context(), wrapper)
+}
+
+// expectedContentHash is the hash that the source has of its content, of the type that the job checks. An MD5 is in the
+// Content-MD5 property, and the others in metadata.
+func expectedContentHash(jptm IJobPartTransferMgr) []byte {
+	if hashType := jptm.ContentHashType(); hashType != common.EContentHashType.MD5() {
+		return hashType.ReadFromMetadata(jptm.Info().SrcMetadata)
+	}
+	return jptm.Info().SrcHTTPHeaders.ContentMD5
+}
+
+// verifyDecompression wraps what a blob is decompressed into, so that if AzCopy compressed the blob as it uploaded it,
+// what it decompresses to is checked against the size and hash it recorded of the file it was uploaded from
+func verifyDecompression(jptm IJobPartTransferMgr, dstFile io.WriteCloser) io.WriteCloser {
+	metadata := jptm.Info().SrcMetadata
+	rawSize, ok := common.TryReadMetadata(metadata, common.UncompressedSizeMeta)
+	if !ok || rawSize == nil {
+		return dstFile
+	}
+	size, err := strconv.ParseInt(*rawSize, 10, 64)
+	if err != nil {
+		jptm.LogAtLevelForCurrentTransfer(common.LogWarning, "Ignoring the invalid uncompressed size in the blob's metadata: "+*rawSize)
+		return dstFile
+	}
+
+	var md5Hash []byte
+	if jptm.MD5ValidationOption() != common.EHashValidationOption.NoCheck() {
+		if rawMd5, ok := common.TryReadMetadata(metadata, common.UncompressedMD5Meta); ok && rawMd5 != nil {
+			md5Hash, _ = base64.StdEncoding.DecodeString(*rawMd5)
+		}
+	}
+	return common.NewDecompressedFileVerifier(dstFile, size, md5Hash)
+}
+
+// leaveHoles reports whether a download should be created sparse, rather than preallocated, so that the all-zero ranges
+// that the chunked file writer skips over are left as holes. Preallocating would fill them in. We do this for page blobs,
+// which usually hold disk images that are mostly zeros.
+func leaveHoles(jptm IJobPartTransferMgr) bool {
+	return common.HolesSupported() && !jptm.ShouldDecompress() && !jptm.ShouldDecrypt() && jptm.Info().SrcBlobType == blob.BlobTypePageBlob
+}
+
+// diskSpaceNeeded is how much of the destination's file system a download of size more bytes will take up, as far as we
+// can tell before starting it. Page blobs take up none, since their zeros are left as holes.
+func diskSpaceNeeded(jptm IJobPartTransferMgr, size int64) int64 {
+	if leaveHoles(jptm) {
+		return 0
+	}
+	return size
+}
+
 // complete epilogue. Handles both success and failure
 func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, activeDstFile io.WriteCloser, cw common.ChunkedFileWriter) {
 	info := jptm.Info()
+	span := startCommitSpan(jptm)
+	defer span.End()
 
 	// allow our usual state tracking mechanism to keep count of how many epilogues are running at any given instant, for perf diagnostics
 	pseudoId := common.NewPseudoChunkIDForWholeFile(info.Source)
@@ -385,6 +596,7 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 	}
 
 	haveNonEmptyFile := activeDstFile != nil
+	extracted := jptm.ArchiveToExtract() != common.EArchiveFormat.None() // in which case there's no file at the destination, only the files extracted beside it
 	if haveNonEmptyFile {
 
 		// wait until all received chunks are flushed out
@@ -393,6 +605,12 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		if flushError != nil {
 			jptm.FailActiveDownload("Flushing file", flushError)
 		}
+		// what was recorded as the download went is no use now that it has stopped, unless we are shutting down, and
+		// without it, a failed download's file is deleted
+		jptm.SetResumeOffset(0)
+		if closeErr == nil && jptm.TransferStatusIgnoringCancellation() == common.ETransferStatus.Cancelled() { // i.e. not failed
+			checkpointPartialDownload(jptm, cw.SavedOffset())
+		}
 		if closeErr != nil {
 			jptm.FailActiveDownload("Closing file", closeErr)
 			jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "Error closing file: "+closeErr.Error()) // log this way so that this line will be logged even if transfer is already failed
@@ -401,7 +619,8 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		// Check MD5 (but only if file was fully flushed and saved - else no point and may not have actualAsSaved hash anyway)
 		if jptm.IsLive() {
 			comparison := md5Comparer{
-				expected:         info.SrcHTTPHeaders.ContentMD5, // the MD5 that came back from Service when we enumerated the source
+				expected:         expectedContentHash(jptm), // the hash that came back from Service when we enumerated the source
+				hashType:         jptm.ContentHashType(),
 				actualAsSaved:    md5OfFileAsWritten,
 				validationOption: jptm.MD5ValidationOption(),
 				logger:           jptm}
@@ -410,8 +629,8 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 				jptm.FailActiveDownload("Checking MD5 hash", err)
 			}
 
-			// check length if enabled (except for dev null and decompression case, where that's impossible)
-			if info.DestLengthValidation && info.Destination != common.Dev_Null && !jptm.ShouldDecompress() {
+			// check length if enabled (except for dev null, decompression and decryption cases, where that's impossible)
+			if info.DestLengthValidation && info.Destination != common.Dev_Null && !jptm.ShouldDecompress() && !jptm.ShouldDecrypt() && !extracted {
 				fi, err := common.OSStat(info.getDownloadPath())
 
 				if err != nil {
@@ -421,16 +640,46 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 				}
 			}
 
+			// sync the file before it's renamed into place, so that the name never stands for less than the whole file
+			fsync := jptm.FsyncPolicy().Mode != common.EFsyncMode.Off() && info.Destination != common.Dev_Null && !extracted
+			if fsync && jptm.IsLive() {
+				if syncErr := common.SyncFile(info.getDownloadPath()); syncErr != nil {
+					jptm.FailActiveDownload("Syncing file", syncErr)
+				}
+			}
+
 			// check if we need to rename back to original name. At this point, we're sure the file is completely
 			// downloaded and not corrupt.
 			renameNecessary := !strings.EqualFold(info.getDownloadPath(), info.Destination) &&
-				!strings.EqualFold(info.Destination, common.Dev_Null)
-			if err == nil && renameNecessary {
-				renameErr := os.Rename(info.getDownloadPath(), info.Destination)
+				!strings.EqualFold(info.Destination, common.Dev_Null) && !extracted
+			if err == nil && renameNecessary && jptm.IsLive() {
+				renameErr := renameDownload(jptm, info)
 				if renameErr != nil {
 					jptm.FailActiveDownload("Download rename", renameErr)
 				}
 			}
+			if fsync && jptm.IsLive() {
+				if syncErr := common.SyncDirectory(filepath.Dir(info.Destination)); syncErr != nil {
+					jptm.FailActiveDownload("Syncing directory", syncErr)
+				}
+			}
+		}
+	}
+
+	// Apply ACLs before the downloader's epilogue applies POSIX properties, so that the mode
+	// (which a POSIX.1e ACL maps onto its mask entry) is the last thing written.
+	if jptm.IsLive() && info.Destination != common.Dev_Null && !extracted {
+		if err := applyACLsFromSourceMetadata(jptm, info.Destination); err != nil {
+			jptm.FailActiveDownload("Setting ACLs", err)
+		}
+		if err := applyExtAttrsFromSourceMetadata(jptm, info.Destination); err != nil {
+			jptm.FailActiveDownload("Setting extended attributes", err)
+		}
+		if err := applyHnsAccessControlFromSource(jptm, info.Destination); err != nil {
+			jptm.FailActiveDownload("Setting owner and permissions", err)
+		}
+		if err := restoreOwnerFromSourceMetadata(jptm, info.Destination); err != nil {
+			jptm.FailActiveDownload("Restoring owner", err)
 		}
 	}
 
@@ -446,7 +695,7 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		// TODO: ...So I have preserved that behavior here.
 		// TODO: question: But is that correct?
 		lastModifiedTime, preserveLastModifiedTime := jptm.PreserveLastModifiedTime()
-		if preserveLastModifiedTime && !info.PreserveInfo {
+		if preserveLastModifiedTime && !info.PreserveInfo && !extracted {
 			err := os.Chtimes(jptm.Info().Destination, lastModifiedTime, lastModifiedTime)
 			if err != nil {
 				jptm.LogError(info.Destination, "Changing Modified Time ", err)
@@ -457,6 +706,13 @@ func epilogueWithCleanupDownload(jptm IJobPartTransferMgr, dl downloader, active
 		}
 	}
 
+	// File flags go last, since uchg and friends would block everything above
+	if jptm.IsLive() && info.Destination != common.Dev_Null && !extracted {
+		if err := applyFileFlagsFromSource(jptm, info.Destination, false); err != nil {
+			jptm.FailActiveDownload("Setting file flags", err)
+		}
+	}
+
 	commonDownloaderCompletion(jptm, info, common.EEntityType.File())
 }
 
@@ -473,8 +729,10 @@ redoCompletion:
 		if jptm.ShouldLog(common.LogDebug) {
 			jptm.Log(common.LogDebug, " Finalizing Transfer Cancellation/Failure")
 		}
-		// for files only, cleanup local file if applicable
-		if entityType == entityType.File() && jptm.IsDeadInflight() && jptm.HoldsDestinationLock() {
+		// for files only, cleanup local file if applicable (but keep what a clean shutdown saved, for the job to carry on from)
+		// (an extracted archive has none, and the files already extracted from it are left in place)
+		if entityType == entityType.File() && jptm.IsDeadInflight() && jptm.HoldsDestinationLock() && jptm.ResumeOffset() == 0 &&
+			jptm.ArchiveToExtract() == common.EArchiveFormat.None() {
 			jptm.LogAtLevelForCurrentTransfer(common.LogInfo, "Deleting incomplete destination file")
 
 			// the file created locally should be deleted
@@ -486,7 +744,8 @@ redoCompletion:
 		}
 
 		// Attempt to put MD5 data if necessary, compliant with the sync hash scheme
-		if jptm.ShouldPutMd5() {
+		if jptm.ShouldPutMd5() && jptm.ContentHashType() == common.EContentHashType.MD5() &&
+			jptm.ArchiveToExtract() == common.EArchiveFormat.None() && !jptm.ShouldDecrypt() { // the hash is of the encrypted blob
 			fi, err := os.Stat(info.Destination)
 			if err != nil {
 				jptm.FailActiveDownload("saving MD5 data (stat to pull LMT)", err)
@@ -533,6 +792,75 @@ redoCompletion:
 	jptm.ReportTransferDone()
 }
 
+// renameDownload moves a complete file from where it was downloaded to into place. The rename is atomic, so the
+// destination is never seen part written.
+func renameDownload(jptm IJobPartTransferMgr, info *TransferInfo) error {
+	if common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadTempDir()) == "" {
+		return os.Rename(info.getDownloadPath(), info.Destination)
+	}
+
+	// the file was created in the temporary directory, so the destination's own directory may not exist yet
+	if err := common.CreateParentDirectoryIfNotExist(info.Destination, jptm.GetFolderCreationTracker()); err != nil {
+		return err
+	}
+	if err := os.Rename(info.getDownloadPath(), info.Destination); err != nil {
+		return fmt.Errorf("%w (%s must be on the same file system as the destination)", err, common.EEnvironmentVariable.DownloadTempDir().Name)
+	}
+	return nil
+}
+
+// downloadCheckpointInterval is how much more of a download is saved each time we record how far it has got
+const downloadCheckpointInterval = 128 * 1024 * 1024
+
+// canCarryOnDownload reports whether part of a download can be kept for a resumed job to carry on from.
+// That's only safe when downloading to a temporary path, since otherwise a resumed job would take the partial file
+// for one that already exists, and when the bytes in the file are the ones downloaded, at the same offsets.
+func canCarryOnDownload(jptm IJobPartTransferMgr) bool {
+	info := jptm.Info()
+	return !jptm.ShouldDecompress() && !jptm.ShouldDecrypt() && jptm.ArchiveToExtract() == common.EArchiveFormat.None() &&
+		!strings.EqualFold(info.Destination, common.Dev_Null) && !strings.EqualFold(info.getDownloadPath(), info.Destination)
+}
+
+// checkpointPartialDownload records how much of a cancelled download was saved, if the job is being shut down cleanly,
+// so that the file isn't deleted and the job can carry on from there when it is resumed.
+func checkpointPartialDownload(jptm IJobPartTransferMgr, savedOffset int64) {
+	info := jptm.Info()
+	if savedOffset <= 0 || savedOffset >= info.SourceSize || !jptm.ShuttingDown() || !canCarryOnDownload(jptm) {
+		return
+	}
+	jptm.SetResumeOffset(savedOffset)
+	jptm.LogAtLevelForCurrentTransfer(common.LogInfo, fmt.Sprintf("Shut down after saving %d bytes, which are kept for when the job is resumed", savedOffset))
+}
+
+// openPartialDownload reopens the file that was being downloaded when the job stopped, whether it was shut down cleanly
+// or not, positioned to carry on from where it got to. It returns nil if there's nothing to carry on from, in which case
+// the file is downloaded afresh.
+func openPartialDownload(jptm IJobPartTransferMgr, offset int64, chunkSize int64) *os.File {
+	info := jptm.Info()
+	if offset <= 0 || offset >= info.SourceSize || offset%chunkSize != 0 || !canCarryOnDownload(jptm) {
+		return nil
+	}
+
+	f, err := common.OSOpenFile(info.getDownloadPath(), os.O_RDWR, common.DEFAULT_FILE_PERM)
+	if err != nil {
+		return nil
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() || fi.Size() != info.SourceSize {
+		_ = f.Close()
+		return nil
+	}
+	// if another process has it locked, the file is created afresh, which finds the lock and fails
+	if err := common.LockFileToWrite(f); err != nil {
+		_ = f.Close()
+		return nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil
+	}
+	return f
+}
+
 // create an empty file and its parent directories, without any content
 func createEmptyFile(jptm IJobPartTransferMgr, destinationPath string) error {
 	err := common.CreateParentDirectoryIfNotExist(destinationPath, jptm.GetFolderCreationTracker())
@@ -568,7 +896,9 @@ func tryDeleteFile(info *TransferInfo, jptm IJobPartTransferMgr) {
 
 // Returns the path of file to be downloaded. If we want to
 // download to a temp path we return a temp path in format
-// /actual/parent/path/.azDownload-<jobID>-<actualFileName>
+// /actual/parent/path/.azDownload-<jobID>-<actualFileName><suffix>
+// or, if AZCOPY_DOWNLOAD_TEMP_DIR is set, /temp/dir/.azDownload-<jobID>-<hash of destination>-<actualFileName><suffix>,
+// so that files with the same name in different directories don't collide there.
 func (info *TransferInfo) getDownloadPath() string {
 	downloadToTempPath, err := strconv.ParseBool(common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadToTempPath()))
 	if err != nil {
@@ -578,7 +908,13 @@ func (info *TransferInfo) getDownloadPath() string {
 
 	if downloadToTempPath && info.SourceSize > 0 { // 0-byte files don't need a rename.
 		parent, fileName := filepath.Split(info.Destination)
-		fileName = fmt.Sprintf(azcopyTempDownloadPrefix, info.JobID.String()) + fileName
+		prefix := fmt.Sprintf(azcopyTempDownloadPrefix, info.JobID.String())
+		if tempDir := common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadTempDir()); tempDir != "" {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(info.Destination))
+			parent, prefix = tempDir, fmt.Sprintf("%s%016x-", prefix, h.Sum64())
+		}
+		fileName = prefix + fileName + common.GetEnvironmentVariable(common.EEnvironmentVariable.DownloadTempSuffix())
 		return filepath.Join(parent, fileName)
 	}
 	return info.Destination
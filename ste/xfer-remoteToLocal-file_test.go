@@ -0,0 +1,148 @@
+package ste
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestPartialDownloadCheckpointedOnlyWhenShuttingDown(t *testing.T) {
+	a := assert.New(t)
+	jptm := &testJobPartTransferManager{info: &TransferInfo{
+		JobID:       common.NewJobID(),
+		Destination: filepath.Join(t.TempDir(), "file"),
+		SourceSize:  4096,
+	}}
+
+	// an ordinary cancellation deletes the file, as it always has
+	checkpointPartialDownload(jptm, 1024)
+	a.Zero(jptm.ResumeOffset())
+
+	jptm.shuttingDown = true
+	checkpointPartialDownload(jptm, 0)
+	a.Zero(jptm.ResumeOffset())
+	checkpointPartialDownload(jptm, 4096) // nothing left to carry on with
+	a.Zero(jptm.ResumeOffset())
+	checkpointPartialDownload(jptm, 1024)
+	a.Equal(int64(1024), jptm.ResumeOffset())
+
+	// without a temporary path, a resumed job would mistake the partial file for a complete one
+	jptm.SetResumeOffset(0)
+	t.Setenv(common.EEnvironmentVariable.DownloadToTempPath().Name, "false")
+	checkpointPartialDownload(jptm, 1024)
+	a.Zero(jptm.ResumeOffset())
+}
+
+func TestOpenPartialDownload(t *testing.T) {
+	a := assert.New(t)
+	jptm := &testJobPartTransferManager{info: &TransferInfo{
+		JobID:       common.NewJobID(),
+		Destination: filepath.Join(t.TempDir(), "file"),
+		SourceSize:  4096,
+	}}
+	const chunkSize = 1024
+
+	a.Nil(openPartialDownload(jptm, 2048, chunkSize)) // nothing there yet
+
+	partialPath := jptm.Info().getDownloadPath()
+	a.NotEqual(jptm.Info().Destination, partialPath)
+	a.NoError(os.WriteFile(partialPath, make([]byte, 4096), 0644))
+
+	f := openPartialDownload(jptm, 2048, chunkSize)
+	if a.NotNil(f) {
+		pos, err := f.Seek(0, io.SeekCurrent)
+		a.NoError(err)
+		a.Equal(int64(2048), pos)
+		a.NoError(f.Close())
+	}
+
+	a.Nil(openPartialDownload(jptm, 0, chunkSize))
+	a.Nil(openPartialDownload(jptm, 1000, chunkSize)) // not where a chunk starts
+	a.Nil(openPartialDownload(jptm, 4096, chunkSize))
+
+	// a file that isn't the size that was created can't be the one we left behind
+	a.NoError(os.Truncate(partialPath, 3000))
+	a.Nil(openPartialDownload(jptm, 2048, chunkSize))
+}
+
+func TestPageBlobDownloadsLeaveHoles(t *testing.T) {
+	a := assert.New(t)
+
+	pageBlob := &testJobPartTransferManager{info: &TransferInfo{SrcBlobType: blob.BlobTypePageBlob}}
+	a.Equal(common.HolesSupported(), leaveHoles(pageBlob))
+
+	// anything else is preallocated, if --preallocate is on
+	blockBlob := &testJobPartTransferManager{info: &TransferInfo{SrcBlobType: blob.BlobTypeBlockBlob}}
+	a.False(leaveHoles(blockBlob))
+}
+
+func TestDownloadPath(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	jobID := common.NewJobID()
+	info := func(destination string) *TransferInfo {
+		return &TransferInfo{JobID: jobID, Destination: destination, SourceSize: 10}
+	}
+
+	a.Equal(filepath.Join(dir, ".azDownload-"+jobID.String()+"-file"), info(filepath.Join(dir, "file")).getDownloadPath())
+	a.Equal(filepath.Join(dir, "empty"), (&TransferInfo{JobID: jobID, Destination: filepath.Join(dir, "empty")}).getDownloadPath())
+
+	t.Setenv(common.EEnvironmentVariable.DownloadTempSuffix().Name, ".part")
+	a.Equal(filepath.Join(dir, ".azDownload-"+jobID.String()+"-file.part"), info(filepath.Join(dir, "file")).getDownloadPath())
+
+	// in a directory of their own, files of the same name from different directories mustn't collide
+	tempDir := filepath.Join(dir, "temp")
+	t.Setenv(common.EEnvironmentVariable.DownloadTempDir().Name, tempDir)
+	first := info(filepath.Join(dir, "a", "file")).getDownloadPath()
+	second := info(filepath.Join(dir, "b", "file")).getDownloadPath()
+	a.Equal(tempDir, filepath.Dir(first))
+	a.NotEqual(first, second)
+	a.True(strings.HasSuffix(first, "-file.part"))
+	a.Equal(first, info(filepath.Join(dir, "a", "file")).getDownloadPath(), "a resumed job looks for the same path")
+
+	// the destination's directory is created when the file is renamed into place
+	jptm := &testJobPartTransferManager{info: info(filepath.Join(dir, "a", "file"))}
+	a.NoError(os.MkdirAll(tempDir, 0755))
+	a.NoError(os.WriteFile(first, []byte("downloaded"), 0644))
+	a.NoError(renameDownload(jptm, jptm.info))
+	saved, err := os.ReadFile(filepath.Join(dir, "a", "file"))
+	a.NoError(err)
+	a.Equal("downloaded", string(saved))
+	a.NoFileExists(first)
+}
+
+func TestLocalDownloaderRenamesIntoNewDirectory(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	source := filepath.Join(dir, "src", "file")
+	a.NoError(os.MkdirAll(filepath.Dir(source), 0755))
+	a.NoError(os.WriteFile(source, []byte("downloaded"), 0644))
+	tempDir := filepath.Join(dir, "temp")
+	a.NoError(os.MkdirAll(tempDir, 0755))
+	t.Setenv(common.EEnvironmentVariable.DownloadTempDir().Name, tempDir)
+
+	fi, err := os.Stat(source)
+	a.NoError(err)
+
+	// the file is copied into the temporary directory, and moved from there to a directory that doesn't exist yet
+	jptm := &testJobPartTransferManager{info: &TransferInfo{
+		JobID:       common.NewJobID(),
+		Source:      source,
+		Destination: filepath.Join(dir, "dst", "sub", "file"),
+		SourceSize:  fi.Size(),
+	}, lastModifiedTime: fi.ModTime()}
+	ld := &localDownloader{jptm: jptm, sip: &localFileSourceInfoProvider{jptm, jptm.Info()}}
+	_, needChunks, err := ld.CreateFile(jptm, jptm.info.getDownloadPath(), jptm.info.SourceSize, false, jptm.GetFolderCreationTracker())
+	a.NoError(err)
+	a.False(needChunks)
+	saved, err := os.ReadFile(jptm.info.Destination)
+	a.NoError(err)
+	a.Equal("downloaded", string(saved))
+}
@@ -70,10 +70,35 @@ func remoteToLocal_folder(jptm IJobPartTransferMgr, pacer pacer, df downloaderFa
 			return
 		}
 
+		err = applyACLsFromSourceMetadata(jptm, info.Destination)
+		if err != nil {
+			jptm.FailActiveDownload("setting folder ACLs", err)
+		}
+
+		err = applyExtAttrsFromSourceMetadata(jptm, info.Destination)
+		if err != nil {
+			jptm.FailActiveDownload("setting folder extended attributes", err)
+		}
+
+		err = applyHnsAccessControlFromSource(jptm, info.Destination)
+		if err != nil {
+			jptm.FailActiveDownload("setting folder owner and permissions", err)
+		}
+
+		err = restoreOwnerFromSourceMetadata(jptm, info.Destination)
+		if err != nil {
+			jptm.FailActiveDownload("restoring folder owner", err)
+		}
+
 		err = dl.SetFolderProperties(jptm)
 		if err != nil {
 			jptm.FailActiveDownload("setting folder properties", err)
 		}
+
+		err = applyFileFlagsFromSource(jptm, info.Destination, true)
+		if err != nil {
+			jptm.FailActiveDownload("setting folder file flags", err)
+		}
 	}
 	commonDownloaderCompletion(jptm, info, common.EEntityType.Folder()) // for consistency, always run the standard epilogue
 
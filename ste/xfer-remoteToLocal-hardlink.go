@@ -0,0 +1,23 @@
+package ste
+
+import (
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// remoteToLocal_hardlink accounts for a blob standing in for a hard link, downloaded with --hardlinks=preserve.
+// Nothing is written here: the link is made by the front end once the job is done, when its target has surely arrived.
+// Having the transfer in the plan is what lets a resumed job make the link too.
+func remoteToLocal_hardlink(jptm IJobPartTransferMgr, pacer pacer, df downloaderFactory) {
+	if jptm.WasCanceled() {
+		jptm.SetStatus(common.ETransferStatus.Cancelled())
+		jptm.ReportTransferDone()
+		return
+	}
+
+	if jptm.ShouldLog(common.LogDebug) {
+		target, _ := jptm.Info().HardlinkTarget()
+		jptm.Log(common.LogDebug, "Hard link to "+target+" will be made once the job is done")
+	}
+	jptm.SetStatus(common.ETransferStatus.Success())
+	jptm.ReportTransferDone()
+}
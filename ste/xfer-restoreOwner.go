@@ -0,0 +1,51 @@
+package ste
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// restoringOwner reports whether a download is to be chowned to the owner and group recorded at the source.
+// Like tar and rsync, we only try when running as root, since nobody else can give files away, and --preserve-owner=false opts out.
+// This only applies on FreeBSD: on Linux, ApplyUnixProperties chowns with the rest of the POSIX properties, as it always has.
+func restoringOwner(info *TransferInfo) bool {
+	return info.RestoreOwner && runtime.GOOS != "windows" && os.Geteuid() == 0
+}
+
+// restoreOwnerFromSourceMetadata chowns a downloaded file or folder to the posix_owner and posix_group in its metadata.
+// Linux does this as part of ApplyUnixProperties, along with the rest of the POSIX properties, so this is for FreeBSD.
+func restoreOwnerFromSourceMetadata(jptm IJobPartTransferMgr, path string) error {
+	info := jptm.Info()
+	if runtime.GOOS != "freebsd" || !info.PreservePOSIXProperties || !jptm.FromTo().IsDownload() || !restoringOwner(info) {
+		return nil
+	}
+
+	uid, err := ownerIDFromMetadata(info.SrcMetadata, common.POSIXOwnerMeta, common.MapOwner)
+	if err != nil {
+		return err
+	}
+	gid, err := ownerIDFromMetadata(info.SrcMetadata, common.POSIXGroupMeta, common.MapGroup)
+	if err != nil {
+		return err
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	return os.Lchown(path, uid, gid)
+}
+
+// ownerIDFromMetadata returns the local uid or gid for the one stored under key, or -1 (which chown leaves unchanged) if there isn't one.
+func ownerIDFromMetadata(metadata common.Metadata, key string, mapID func(string) (uint32, bool)) (int, error) {
+	value, ok := metadata[key]
+	if !ok || value == nil {
+		return -1, nil
+	}
+	id, ok := mapID(*value)
+	if !ok {
+		return -1, fmt.Errorf("invalid %s metadata %q", key, *value)
+	}
+	return int(id), nil
+}
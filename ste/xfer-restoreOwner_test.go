@@ -0,0 +1,31 @@
+package ste
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnerIDFromMetadata(t *testing.T) {
+	a := assert.New(t)
+	metadata := common.Metadata{
+		common.POSIXOwnerMeta: to.Ptr("1001"),
+		common.POSIXGroupMeta: to.Ptr("wheel"),
+	}
+
+	uid, err := ownerIDFromMetadata(metadata, common.POSIXOwnerMeta, common.MapOwner)
+	a.NoError(err)
+	a.Equal(1001, uid)
+
+	_, err = ownerIDFromMetadata(metadata, common.POSIXGroupMeta, common.MapGroup)
+	a.Error(err)
+
+	// missing IDs are left as they are
+	id, err := ownerIDFromMetadata(common.Metadata{}, common.POSIXGroupMeta, common.MapGroup)
+	a.NoError(err)
+	a.Equal(-1, id)
+
+	a.False(restoringOwner(&TransferInfo{RestoreOwner: false}))
+}
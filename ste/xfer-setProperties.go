@@ -90,7 +90,11 @@ func setPropertiesBlob(jptm IJobPartTransferMgr) {
 	}
 
 	if PropertiesToTransfer.ShouldTransferMetaData() {
-		_, err := srcBlobClient.SetMetadata(jptm.Context(), metadata, nil)
+		// metadata is encrypted with the blob's key, so the blob's key is needed to replace it
+		_, err := srcBlobClient.SetMetadata(jptm.Context(), metadata, &blob.SetMetadataOptions{
+			CPKInfo:      jptm.CpkInfo(),
+			CPKScopeInfo: jptm.CpkScopeInfo(),
+		})
 		//TODO the canonical thing in this is changing key value to upper case. How to go around it?
 		if err != nil {
 			errorHandlerForXferSetProperties(err, jptm, transferDone)
@@ -153,7 +157,11 @@ func setPropertiesBlobFS(jptm IJobPartTransferMgr) {
 	}
 
 	if PropertiesToTransfer.ShouldTransferMetaData() {
-		_, err := srcBlobClient.SetMetadata(jptm.Context(), metadata, nil)
+		// metadata is encrypted with the blob's key, so the blob's key is needed to replace it
+		_, err := srcBlobClient.SetMetadata(jptm.Context(), metadata, &blob.SetMetadataOptions{
+			CPKInfo:      jptm.CpkInfo(),
+			CPKScopeInfo: jptm.CpkScopeInfo(),
+		})
 		if err != nil {
 			errorHandlerForXferSetProperties(err, jptm, transferDone)
 			return
@@ -90,13 +90,16 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 			return newAzureFilesDownloader
 		case common.ELocation.BlobFS():
 			return newBlobFSDownloader
+		case common.ELocation.Provider():
+			return newProviderDownloader
 		default:
 			panic("unexpected source type")
 		}
 	}
 
 	getSenderFactory := func(fromTo common.FromTo) senderFactory {
-		isFromRemote := fromTo.From().IsRemote()
+		// a provider's objects are read by AzCopy itself, as local files are, since the service can't fetch them from a URL
+		isFromRemote := fromTo.From().IsRemote() && fromTo.From() != common.ELocation.Provider()
 		if isFromRemote {
 			// sending from remote = doing an S2S copy
 			switch fromTo.To() {
@@ -119,6 +122,8 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 				return newAzureFilesUploader
 			case common.ELocation.BlobFS():
 				return newBlobFSUploader
+			case common.ELocation.Provider():
+				return newProviderUploader
 			default:
 				panic("unexpected target location type")
 			}
@@ -141,6 +146,10 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 			return newS3SourceInfoProvider
 		case common.ELocation.GCP():
 			return newGCPSourceInfoProvider
+		case common.ELocation.Provider():
+			return newProviderSourceInfoProvider
+		case common.ELocation.URLList():
+			return newURLSourceInfoProvider
 		default:
 			panic("unexpected source type")
 		}
@@ -156,6 +165,9 @@ func computeJobXfer(fromTo common.FromTo, blobType common.BlobType) newJobXfer {
 		return DeleteHNSResource
 	case common.EFromTo.BlobNone(), common.EFromTo.BlobFSNone(), common.EFromTo.FileNone():
 		return SetProperties
+	case common.EFromTo.LocalLocal():
+		// local copies reuse the download path, with the source file standing in for the remote one
+		return parameterizeDownload(remoteToLocal, newLocalDownloader)
 	default:
 		if fromTo.IsDownload() {
 			return parameterizeDownload(remoteToLocal, getDownloader(fromTo.From()))
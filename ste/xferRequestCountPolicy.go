@@ -0,0 +1,67 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// requestCounts counts the requests that a transfer makes, and the times that they're tried, so that the transfer
+// report can say how many retries the transfer needed
+type requestCounts struct {
+	atomicRequests int64
+	atomicTries    int64
+}
+
+func (c *requestCounts) retries() int64 {
+	return max(0, atomic.LoadInt64(&c.atomicTries)-atomic.LoadInt64(&c.atomicRequests))
+}
+
+var requestCountsContextKey = contextKey{"requestCounts"}
+
+// withRequestCounts returns a context that contains request counts, which the requestCountPolicies then add to
+func withRequestCounts(ctx context.Context, c *requestCounts) context.Context {
+	return context.WithValue(ctx, requestCountsContextKey, c)
+}
+
+// requestCountPolicy counts requests as a per-call policy, and their tries as a per-retry policy
+type requestCountPolicy struct {
+	perTry bool
+}
+
+func newRequestCountPolicy(perTry bool) policy.Policy {
+	return requestCountPolicy{perTry: perTry}
+}
+
+func (p requestCountPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if c, ok := req.Raw().Context().Value(requestCountsContextKey).(*requestCounts); ok && c != nil {
+		if p.perTry {
+			atomic.AddInt64(&c.atomicTries, 1)
+		} else {
+			atomic.AddInt64(&c.atomicRequests, 1)
+		}
+	}
+	return req.Next()
+}
@@ -0,0 +1,175 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// retryState is what retryBackoffPolicy knows about the tries of a request
+type retryState struct {
+	settings   RetrySettings
+	start      time.Time
+	tries      int
+	retryAfter time.Duration // how long the last response asked to wait, with Retry-After
+	sdkWaits   bool          // whether the SDK's retry policy waits for it, so that this policy doesn't
+	last       string        // how the last try went
+}
+
+var retryStateContextKey = contextKey{"retryState"}
+
+// retryBackoffPolicy waits between a request's tries, as the retry settings say, and gives up once their deadline has
+// passed, or once the transfer that the request is for has used up its retry budget. As a per-call policy, it starts
+// the request's retry state, and as a per-retry policy, before each retry, it waits for the backoff. When the server
+// has said how long to wait, with Retry-After, it waits exactly that long instead, and the wait is logged, and counted
+// in the job's throttled time; the SDK's retry policy does the waiting for the formats of Retry-After that it
+// understands.
+type retryBackoffPolicy struct {
+	perTry bool
+}
+
+func newRetryBackoffPolicy(perTry bool) policy.Policy {
+	return retryBackoffPolicy{perTry: perTry}
+}
+
+func (p retryBackoffPolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	if !p.perTry {
+		state := &retryState{settings: currentRetrySettings(), start: time.Now()}
+		return req.WithContext(context.WithValue(ctx, retryStateContextKey, state)).Next()
+	}
+
+	state, ok := ctx.Value(retryStateContextKey).(*retryState)
+	if !ok || state == nil {
+		return req.Next()
+	}
+	state.tries++
+	if state.tries > 1 {
+		var delay time.Duration
+		switch {
+		case state.sdkWaits:
+			// it already has
+		case state.retryAfter > 0:
+			delay = state.retryAfter
+		default:
+			delay = state.settings.randomDelay(state.tries - 1)
+		}
+		// a retry that would start after the deadline isn't waited for
+		if state.settings.Deadline > 0 && time.Since(state.start)+delay > state.settings.Deadline {
+			return nil, retryDeadlineError{deadline: state.settings.Deadline, tries: state.tries - 1, last: state.last}
+		}
+		// nor is one that would take the transfer's requests past its retry budget
+		if counts, ok := ctx.Value(requestCountsContextKey).(*requestCounts); ok && counts != nil &&
+			state.settings.Budget > 0 && counts.retries() >= int64(state.settings.Budget) {
+			return nil, retryBudgetError{budget: state.settings.Budget, last: state.last}
+		}
+		if state.retryAfter > 0 {
+			recordRetryAfter(req, state)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	resp, err := req.Next()
+	state.retryAfter, state.sdkWaits = retryAfter(resp, time.Now())
+	switch {
+	case err != nil:
+		state.last = err.Error()
+	case resp != nil:
+		state.last = resp.Status
+	}
+	return resp, err
+}
+
+// retryAfter is how long resp asks to wait before it's retried, with its Retry-After-Ms, X-Ms-Retry-After-Ms or
+// Retry-After header, which is in seconds or an HTTP date; and whether the SDK's retry policy waits for it, as it does
+// unless it's a date in a format other than RFC 1123's
+func retryAfter(resp *http.Response, now time.Time) (wait time.Duration, sdkWaits bool) {
+	if resp == nil {
+		return 0, false
+	}
+	for _, h := range []struct {
+		name  string
+		units time.Duration
+	}{{"Retry-After-Ms", time.Millisecond}, {"X-Ms-Retry-After-Ms", time.Millisecond}, {"Retry-After", time.Second}} {
+		v := resp.Header.Get(h.name)
+		if v == "" {
+			continue
+		}
+		if n, _ := strconv.Atoi(v); n > 0 {
+			return time.Duration(n) * h.units, true
+		}
+		if h.units == time.Second {
+			if t, err := http.ParseTime(v); err == nil && t.After(now) {
+				_, rfc1123Err := time.Parse(time.RFC1123, v)
+				return t.Sub(now), rfc1123Err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+// recordRetryAfter logs a retry that waits for Retry-After, and adds it to the job's throttled time
+func recordRetryAfter(req *policy.Request, state *retryState) {
+	if stats, ok := req.Raw().Context().Value(pipelineNetworkStatsContextKey).(*PipelineNetworkStats); ok && stats != nil {
+		stats.recordRetryAfter(state.retryAfter)
+	}
+	common.LogToJobLogWithPrefix(fmt.Sprintf("Waiting %v before retrying %s %s, as the server asked with Retry-After, after %s",
+		state.retryAfter, req.Raw().Method, common.URLExtension{URL: *req.Raw().URL}.RedactSecretQueryParamForLogging(), state.last), common.LogInfo)
+}
+
+// retryDeadlineError is the error of a request that was still failing when the retry deadline passed. It's
+// non-retriable, so that the SDK's retry policy stops.
+type retryDeadlineError struct {
+	deadline time.Duration
+	tries    int
+	last     string
+}
+
+func (e retryDeadlineError) Error() string {
+	return fmt.Sprintf("gave up retrying after the retry deadline of %v, and %d tries, the last of which failed with %s", e.deadline, e.tries, e.last)
+}
+
+func (retryDeadlineError) NonRetriable() {}
+
+// retryBudgetError is the error of a request that was still failing when the transfer it was for had made as many
+// retries as its budget allows. It's non-retriable, so that the SDK's retry policy stops.
+type retryBudgetError struct {
+	budget int
+	last   string
+}
+
+func (e retryBudgetError) Error() string {
+	return fmt.Sprintf("gave up retrying, since the transfer has used up its budget of %d retries; the last try failed with %s", e.budget, e.last)
+}
+
+func (retryBudgetError) NonRetriable() {}
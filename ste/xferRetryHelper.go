@@ -42,6 +42,11 @@ func GetShouldRetry(log *LogOptions) RetryFunc {
 	}
 
 	return func(resp *http.Response, err error) bool {
+		// a try that stalled is retried on a new connection
+		var stalled stalledError
+		if errors.As(err, &stalled) {
+			return true
+		}
 		if resp != nil {
 			if storageErrorCodes, ok := RetryStatusCodes[resp.StatusCode]; ok {
 				// compare to status codes
@@ -0,0 +1,238 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+// stallPolicy cancels a try whose connection has moved no data for the stall timeout, so that it's retried on a new
+// connection, instead of waiting out the try timeout on a dead one. The connection is only waited for while it has
+// something to do: while it's sending the request's body, and then while the response to it is awaited; and while
+// the response's body is being read. The time that AzCopy itself takes, to read a file or to write what it
+// downloaded, doesn't count. A stalled download's body read fails with a net.Error, so that the SDK's retry reader
+// fetches the rest of the chunk with a new request.
+type stallPolicy struct {
+	timeout time.Duration
+}
+
+func newStallPolicy() policy.Policy {
+	return stallPolicy{timeout: currentRetrySettings().StallTimeout}
+}
+
+func (p stallPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if p.timeout <= 0 {
+		return req.Next()
+	}
+	ctx, cancel := context.WithCancel(req.Raw().Context())
+	start := time.Now()
+	w := &stallWatch{timeout: p.timeout, cancel: cancel}
+	w.onStall = func() { recordStall(req, p.timeout, time.Since(start)) }
+
+	req = req.WithContext(ctx)
+	var body *stallRequestBody
+	if raw := req.Raw(); raw.Body != nil && raw.Body != http.NoBody {
+		body = &stallRequestBody{ReadCloser: raw.Body, watch: w}
+		raw.Body = body
+	}
+
+	resp, err := req.Next()
+	if body != nil {
+		// the transport can still be reading the body if the server responded before it was all sent
+		body.done.Store(true)
+	}
+	w.pause()
+	if err != nil || resp == nil {
+		w.stop()
+		if w.hasStalled() {
+			return resp, stalledError{timeout: p.timeout}
+		}
+		return resp, err
+	}
+	if payloadDownloaded(resp) {
+		w.stop()
+		return resp, nil
+	}
+	resp.Body = &stallResponseBody{ReadCloser: resp.Body, watch: w}
+	return resp, nil
+}
+
+// payloadDownloaded is whether resp's body has already been read into memory, as the SDK does for the responses
+// that aren't streamed. It's what the SDK's exported.PayloadDownloaded tells, which isn't exported from its module.
+func payloadDownloaded(resp *http.Response) bool {
+	_, ok := resp.Body.(interface {
+		Bytes() []byte
+		Set([]byte)
+	})
+	return ok
+}
+
+// recordStall logs a try that stalled, and adds it to the job's stall statistics
+func recordStall(req *policy.Request, timeout, lost time.Duration) {
+	if stats, ok := req.Raw().Context().Value(pipelineNetworkStatsContextKey).(*PipelineNetworkStats); ok && stats != nil {
+		stats.recordStall(lost)
+	}
+	common.LogToJobLogWithPrefix(fmt.Sprintf("Cancelled %s %s, since its connection moved no data for %v; it will be retried on a new connection",
+		req.Raw().Method, common.URLExtension{URL: *req.Raw().URL}.RedactSecretQueryParamForLogging(), timeout), common.LogWarning)
+}
+
+// stallWatch times how long a try has been waiting for its connection, and cancels the try when it's waited for
+// the timeout
+type stallWatch struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+	onStall func()
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	since   time.Time // when the connection started to be waited for, or zero while it isn't
+	stalled bool
+	stopped bool
+}
+
+// wait starts the wait for the connection, unless it's already being waited for
+func (w *stallWatch) wait() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped || !w.since.IsZero() {
+		return
+	}
+	w.since = time.Now()
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.timeout, w.check)
+	} else {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// pause ends the wait for the connection, since it's moved some data, or it's AzCopy that's being waited for
+func (w *stallWatch) pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.since = time.Time{}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// stop ends the watch, once the try is over
+func (w *stallWatch) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.since = time.Time{}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+func (w *stallWatch) check() {
+	w.mu.Lock()
+	if w.stopped || w.since.IsZero() {
+		w.mu.Unlock()
+		return
+	}
+	// the timer can fire just as a wait that started later is reset
+	if left := w.timeout - time.Since(w.since); left > 0 {
+		w.timer.Reset(left)
+		w.mu.Unlock()
+		return
+	}
+	w.stalled = true
+	w.stopped = true
+	w.mu.Unlock()
+	w.cancel()
+	w.onStall()
+}
+
+func (w *stallWatch) hasStalled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalled
+}
+
+// stallRequestBody waits for the connection between the transport's reads of the request's body, and after it's
+// all been read, for the response
+type stallRequestBody struct {
+	io.ReadCloser
+	watch *stallWatch
+	done  atomic.Bool // once the try has its response
+}
+
+func (b *stallRequestBody) Read(p []byte) (int, error) {
+	if b.done.Load() {
+		return b.ReadCloser.Read(p)
+	}
+	b.watch.pause()
+	n, err := b.ReadCloser.Read(p)
+	if !b.done.Load() && (err == nil || err == io.EOF) {
+		b.watch.wait()
+	}
+	return n, err
+}
+
+// stallResponseBody waits for the connection during each read of the response's body, and ends the try when it's
+// closed
+type stallResponseBody struct {
+	io.ReadCloser
+	watch *stallWatch
+}
+
+func (b *stallResponseBody) Read(p []byte) (int, error) {
+	b.watch.wait()
+	n, err := b.ReadCloser.Read(p)
+	b.watch.pause()
+	if err != nil && err != io.EOF && b.watch.hasStalled() {
+		return n, stalledError{timeout: b.watch.timeout}
+	}
+	return n, err
+}
+
+func (b *stallResponseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.watch.stop()
+	return err
+}
+
+// stalledError is the error of a try that was cancelled because its connection stalled. It's a net.Error that
+// times out, so that the retry readers of downloads retry it, as they do other network errors.
+type stalledError struct {
+	timeout time.Duration
+}
+
+func (e stalledError) Error() string {
+	return fmt.Sprintf("the connection moved no data for %v, so the request was cancelled, to be retried on a new connection", e.timeout)
+}
+
+func (stalledError) Timeout() bool { return true }
+
+func (stalledError) Temporary() bool { return true }
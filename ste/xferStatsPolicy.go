@@ -40,6 +40,10 @@ type PipelineNetworkStats struct {
 	atomic503CountUnknown      int64 // counts 503's when we don't know the reason
 	atomicE2ETotalMilliseconds int64 // should this be nanoseconds?  Not really needed, given typical minimum operation lengths that we observe
 	atomicStartSeconds         int64
+	atomicRetryAfterCount      int64 // retries that waited for the server's Retry-After
+	atomicRetryAfterNanos      int64 // how long they waited
+	atomicStallCount           int64 // tries that were cancelled because their connections stalled
+	atomicStalledNanos         int64 // how long those tries ran before they were
 	nocopy                     common.NoCopy
 	tunerInterface             ConcurrencyTuner
 }
@@ -64,6 +68,40 @@ func (s *PipelineNetworkStats) recordRetry(responseBody string) {
 	}
 }
 
+func (s *PipelineNetworkStats) recordRetryAfter(wait time.Duration) {
+	atomic.AddInt64(&s.atomicRetryAfterCount, 1)
+	atomic.AddInt64(&s.atomicRetryAfterNanos, int64(wait))
+}
+
+// RetryAfterCount is how many retries waited for the server's Retry-After
+func (s *PipelineNetworkStats) RetryAfterCount() int64 {
+	s.nocopy.Check()
+	return atomic.LoadInt64(&s.atomicRetryAfterCount)
+}
+
+// ThrottledTime is how long the retries that waited for the server's Retry-After waited, in all
+func (s *PipelineNetworkStats) ThrottledTime() time.Duration {
+	s.nocopy.Check()
+	return time.Duration(atomic.LoadInt64(&s.atomicRetryAfterNanos))
+}
+
+func (s *PipelineNetworkStats) recordStall(lost time.Duration) {
+	atomic.AddInt64(&s.atomicStallCount, 1)
+	atomic.AddInt64(&s.atomicStalledNanos, int64(lost))
+}
+
+// StallCount is how many tries were cancelled, to be retried, because their connections stalled
+func (s *PipelineNetworkStats) StallCount() int64 {
+	s.nocopy.Check()
+	return atomic.LoadInt64(&s.atomicStallCount)
+}
+
+// StalledTime is how long the tries that stalled ran before they were cancelled, in all
+func (s *PipelineNetworkStats) StalledTime() time.Duration {
+	s.nocopy.Check()
+	return time.Duration(atomic.LoadInt64(&s.atomicStalledNanos))
+}
+
 func (s *PipelineNetworkStats) OperationsPerSecond() int {
 	s.nocopy.Check()
 	elapsed := time.Since(time.Unix(s.getStartSeconds(), 0)).Seconds()
@@ -0,0 +1,106 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccountLimits(t *testing.T) {
+	a := assert.New(t)
+
+	limits, err := ParseAccountLimits("ProdAccount requests=16 mbps=200; 127.0.0.1:10000 mbps=12.5;")
+	a.NoError(err)
+	a.Equal([]AccountLimit{
+		{Account: "prodaccount", Requests: 16, Mbps: 200},
+		{Account: "127.0.0.1:10000", Mbps: 12.5},
+	}, limits)
+
+	for _, s := range []string{"prodaccount", "prodaccount requests=0", "prodaccount mbps=fast", "prodaccount iops=5",
+		"a requests=1; a mbps=1"} {
+		_, err = ParseAccountLimits(s)
+		a.Error(err, s)
+	}
+}
+
+func TestAccountLimiterFor(t *testing.T) {
+	a := assert.New(t)
+	defer EnableAccountLimits(nil)
+
+	a.Nil(accountLimiterFor("prodaccount.blob.core.windows.net"))
+	EnableAccountLimits([]AccountLimit{{Account: "prodaccount", Requests: 1}, {Account: "127.0.0.1:10000", Requests: 2}})
+	a.NotNil(accountLimiterFor("prodaccount.blob.core.windows.net"))
+	a.Same(accountLimiterFor("prodaccount.blob.core.windows.net"), accountLimiterFor("ProdAccount.dfs.core.windows.net"))
+	a.NotNil(accountLimiterFor("127.0.0.1:10000"))
+	a.Nil(accountLimiterFor("otheraccount.blob.core.windows.net"))
+	a.Nil(accountLimiterFor("127.0.0.1:10001"))
+}
+
+type bodyTransport struct{}
+
+func (bodyTransport) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("content")), Request: req}, nil
+}
+
+func TestAccountLimitPolicyHoldsSlotUntilBodyIsClosed(t *testing.T) {
+	a := assert.New(t)
+	defer EnableAccountLimits(nil)
+	EnableAccountLimits([]AccountLimit{{Account: "prodaccount", Requests: 1}})
+
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{PerRetry: []policy.Policy{newAccountLimitPolicy(true)}},
+		&policy.ClientOptions{Transport: bodyTransport{}, Retry: policy.RetryOptions{MaxRetries: -1}})
+	get := func(ctx context.Context, url string) (*http.Response, error) {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, url)
+		a.NoError(err)
+		return pipeline.Do(req)
+	}
+
+	first, err := get(context.Background(), "https://prodaccount.blob.core.windows.net/c/a")
+	a.NoError(err)
+
+	// the account's only slot is taken until the first response's body is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = get(ctx, "https://prodaccount.blob.core.windows.net/c/b")
+	a.ErrorIs(err, context.DeadlineExceeded)
+
+	// other accounts aren't held up
+	other, err := get(context.Background(), "https://otheraccount.blob.core.windows.net/c/a")
+	a.NoError(err)
+	a.NoError(other.Body.Close())
+
+	a.NoError(first.Body.Close())
+	second, err := get(context.Background(), "https://prodaccount.blob.core.windows.net/c/b")
+	a.NoError(err)
+	content, err := io.ReadAll(second.Body)
+	a.NoError(err)
+	a.Equal("content", string(content))
+	a.NoError(second.Body.Close())
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChooseBlockSize(t *testing.T) {
+	a := assert.New(t)
+	const MiB, GiB, TiB = int64(1024 * 1024), int64(1024 * 1024 * 1024), int64(1024 * 1024 * 1024 * 1024)
+
+	a.Equal(8*MiB, chooseBlockSize(100, 0, 0), "small files get the default")
+	a.Equal(8*MiB, chooseBlockSize(GiB, 0, 0), "before throughput is measured, so do large ones")
+	a.Equal(8*MiB, chooseBlockSize(GiB, 1000*1000, 0), "slow connections don't make blocks smaller than the default")
+
+	a.Equal(77*MiB, chooseBlockSize(10*GiB, 40*1000*1000, 0), "2s worth, rounded up to MiB")
+	a.Equal(64*MiB, chooseBlockSize(GiB, 40*1000*1000, 0), "a file is still split into 16 blocks")
+	a.Equal(100*MiB, chooseBlockSize(10*GiB, 1000*1000*1000, 0), "fast connections don't make blocks too large")
+	a.Equal(32*MiB, chooseBlockSize(10*GiB, 1000*1000*1000, 256*MiB), "8 blocks fit in the memory limit")
+
+	a.Equal(21*MiB, chooseBlockSize(TiB, 0, 0), "a blob has 50,000 blocks at most")
+	a.Equal(21*MiB, chooseBlockSize(TiB, 0, 64*MiB), "whatever the memory limit")
+	a.Equal(int64(common.MaxBlockBlobBlockSize), chooseBlockSize(200*TiB, 0, 0))
+}
+
+func TestChooseBlockSizeFitsTheBlockLimit(t *testing.T) {
+	a := assert.New(t)
+	maxBlobSize := int64(common.MaxNumberOfBlocksPerBlob) * common.MaxBlockBlobBlockSize
+	for _, size := range []int64{0, 1, 50000 * 8 * 1024 * 1024, 50000*8*1024*1024 + 1, 7 << 40, maxBlobSize - 1, maxBlobSize} {
+		for _, throughput := range []int64{0, 100 * 1000, 200 * 1000 * 1000} {
+			blockSize := chooseBlockSize(size, throughput, 1024*1024*1024)
+			a.Zero(blockSize%(1024*1024), "size %d", size)
+			a.LessOrEqual((size+blockSize-1)/blockSize, int64(common.MaxNumberOfBlocksPerBlob), "size %d", size)
+		}
+	}
+}
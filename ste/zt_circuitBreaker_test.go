@@ -0,0 +1,117 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func circuitBreakerTest(settings CircuitBreakerSettings) (*circuitBreaker, *TransferGate, *[]string) {
+	gate := newTransferGate()
+	var notes []string
+	return newCircuitBreaker(settings, gate, func(msg string) { notes = append(notes, msg) }), gate, &notes
+}
+
+func TestCircuitBreakerTripsAtTheFailureRate(t *testing.T) {
+	a := assert.New(t)
+	b, gate, notes := circuitBreakerTest(CircuitBreakerSettings{FailureRate: 0.75, Window: 4, Cooldown: time.Hour})
+	defer b.reset()
+
+	b.record(true, "")
+	b.record(true, "")
+	a.Nil(gate.transfersHeld(), "it doesn't trip before the window is full")
+	b.record(false, "")
+	b.record(false, "")
+	b.record(true, "")
+	a.Nil(gate.transfersHeld(), "the oldest failure has dropped out, leaving 2 of the last 4")
+	b.record(true, "")
+	a.Nil(gate.transfersHeld(), "2 of the last 4 failed")
+	b.record(true, "AuthenticationFailed")
+	a.NotNil(gate.transfersHeld())
+	a.Nil(gate.chunksHeld(), "the transfers in flight carry on")
+	if a.Len(*notes, 1) {
+		a.Contains((*notes)[0], "3 of the last 4 transfers failed, the last with: AuthenticationFailed.")
+	}
+
+	// the ones that were in flight when it tripped don't count
+	b.record(false, "")
+	a.NotNil(gate.transfersHeld())
+}
+
+func TestCircuitBreakerTriesAgainAfterTheCooldown(t *testing.T) {
+	a := assert.New(t)
+	b, gate, _ := circuitBreakerTest(CircuitBreakerSettings{FailureRate: 1, Window: 2, Cooldown: 20 * time.Millisecond})
+	defer b.reset()
+
+	b.record(true, "")
+	b.record(true, "")
+	held := gate.transfersHeld()
+	a.NotNil(held)
+	select {
+	case <-held:
+	case <-time.After(5 * time.Second):
+		a.FailNow("transfers weren't let through after the cool-down")
+	}
+
+	// the first transfer to fail after the cool-down trips it again
+	b.record(true, "")
+	held = gate.transfersHeld()
+	a.NotNil(held)
+	<-held
+
+	// and the first to succeed starts the count afresh
+	b.record(false, "")
+	b.record(true, "")
+	a.Nil(gate.transfersHeld())
+	b.record(true, "")
+	a.NotNil(gate.transfersHeld())
+}
+
+func TestCircuitBreakerIsKeptApartFromPauses(t *testing.T) {
+	a := assert.New(t)
+	g := newTransferGate()
+
+	g.trip()
+	a.True(g.Pause())
+	a.True(g.Resume())
+	a.NotNil(g.transfersHeld(), "resuming doesn't lift the circuit breaker")
+
+	g.Pause()
+	g.untrip()
+	a.True(g.Paused(), "nor does the circuit breaker resume a pause")
+	a.NotNil(g.transfersHeld())
+	g.Resume()
+	a.Nil(g.transfersHeld())
+}
+
+func TestCircuitBreakerOffByDefault(t *testing.T) {
+	a := assert.New(t)
+	b, gate, _ := circuitBreakerTest(DefaultCircuitBreakerSettings())
+	for i := 0; i < 100; i++ {
+		b.record(true, "")
+	}
+	a.Nil(gate.transfersHeld())
+	a.Error(SetCircuitBreakerSettings(CircuitBreakerSettings{FailureRate: 1.5, Window: 10, Cooldown: time.Minute}))
+	a.Error(SetCircuitBreakerSettings(CircuitBreakerSettings{FailureRate: 0.5, Window: 0, Cooldown: time.Minute}))
+}
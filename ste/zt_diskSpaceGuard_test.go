@@ -0,0 +1,126 @@
+package ste
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mib = 1024 * 1024
+
+// diskSpaceTest returns a guard of a disk with free bytes free, and what it has told the user and cancelled the job for
+func diskSpaceTest(settings DiskSpaceSettings, free *atomic.Uint64) (*diskSpaceGuard, *TransferGate, func() []string) {
+	gate := newTransferGate()
+	var mu sync.Mutex
+	var notes []string
+	note := func(msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		notes = append(notes, msg)
+	}
+	g := newDiskSpaceGuard(settings, gate, note, func(msg string) { note("cancelled: " + msg) })
+	g.freeSpace = func(string) (uint64, error) { return free.Load(), nil }
+	return g, gate, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, notes...)
+	}
+}
+
+func TestDiskSpaceGuardPausesUntilSpaceIsFreed(t *testing.T) {
+	a := assert.New(t)
+	var free atomic.Uint64
+	free.Store(100 * mib)
+	g, gate, notes := diskSpaceTest(DiskSpaceSettings{MinFree: 10 * mib, Interval: 10 * time.Millisecond}, &free)
+	defer g.reset()
+	ctx := context.Background()
+
+	a.NoError(g.beforeDownload(ctx, "/data/a", 90*mib))
+	a.Nil(gate.transfersHeld())
+
+	// a file that doesn't fit waits, and holds the rest of the job back, until it does
+	done := make(chan error, 1)
+	go func() { done <- g.beforeDownload(ctx, "/data/b", 95*mib) }()
+	a.Eventually(func() bool { return gate.transfersHeld() != nil }, 5*time.Second, time.Millisecond)
+	a.NotNil(gate.chunksHeld())
+	free.Store(104 * mib)
+	a.Never(func() bool { return len(done) > 0 }, 50*time.Millisecond, 5*time.Millisecond, "there's room for the file, but not for the space to leave free")
+	free.Store(105 * mib)
+	select {
+	case err := <-done:
+		a.NoError(err)
+	case <-time.After(5 * time.Second):
+		a.FailNow("the download wasn't let through once space was freed")
+	}
+	a.Nil(gate.transfersHeld())
+	if a.Len(notes(), 2) {
+		a.Contains(notes()[0], "Only 100 MiB is free for /data/b, which needs 105 MiB.")
+	}
+
+	// downloads in progress are checked too, but aren't held back themselves
+	free.Store(5 * mib)
+	a.Eventually(func() bool {
+		g.whileDownloading("/data/c")
+		return gate.chunksHeld() != nil
+	}, 5*time.Second, time.Millisecond)
+	free.Store(10 * mib)
+	a.Eventually(func() bool { return gate.chunksHeld() == nil }, 5*time.Second, time.Millisecond)
+}
+
+func TestDiskSpaceGuardCancelsTheJob(t *testing.T) {
+	a := assert.New(t)
+	var free atomic.Uint64
+	free.Store(100 * mib)
+	g, gate, notes := diskSpaceTest(DiskSpaceSettings{MinFree: 10 * mib, Fail: true, Interval: time.Hour}, &free)
+	defer g.reset()
+
+	// downloads in progress are checked at most once an interval
+	a.NoError(g.beforeDownload(context.Background(), "/data/a", 0))
+	free.Store(0)
+	g.whileDownloading("/data/a")
+	a.Empty(notes())
+
+	err := g.beforeDownload(context.Background(), "/data/a", 95*mib)
+	a.ErrorContains(err, "Only 0 MiB is free")
+	a.Nil(gate.transfersHeld(), "the job is cancelled, rather than held back")
+	a.Error(g.beforeDownload(context.Background(), "/data/b", 0), "the files that are left fail fast")
+	if a.Len(notes(), 1) {
+		a.Contains(notes()[0], "cancelled: ")
+	}
+
+	// a job that's resumed in the same process starts afresh
+	free.Store(100 * mib)
+	g.reset()
+	a.NoError(g.beforeDownload(context.Background(), "/data/b", 0))
+}
+
+func TestDiskSpaceGuardIsKeptApartFromPauses(t *testing.T) {
+	a := assert.New(t)
+	g := newTransferGate()
+
+	g.holdForSpace()
+	a.True(g.Pause())
+	a.True(g.Resume())
+	a.NotNil(g.chunksHeld(), "resuming doesn't lift the hold for disk space")
+
+	g.Pause()
+	g.releaseForSpace()
+	a.NotNil(g.chunksHeld(), "nor does freeing space resume a pause")
+	g.Resume()
+	a.Nil(g.chunksHeld())
+	a.Nil(g.transfersHeld())
+}
+
+func TestDiskSpaceGuardOffByDefault(t *testing.T) {
+	a := assert.New(t)
+	var free atomic.Uint64
+	g, gate, _ := diskSpaceTest(DefaultDiskSpaceSettings(), &free)
+	a.NoError(g.beforeDownload(context.Background(), "/data/a", 1024*mib))
+	g.whileDownloading("/data/a")
+	a.Nil(gate.transfersHeld())
+	a.Error(SetDiskSpaceSettings(DiskSpaceSettings{MinFree: mib}))
+}
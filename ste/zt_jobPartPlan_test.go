@@ -0,0 +1,196 @@
+package ste
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobPartPlanRecordsRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	value := "value"
+	longPath := "/" + strings.Repeat("d/", math.MaxInt16)
+	order := common.CopyJobPartOrderRequest{
+		JobID:         common.NewJobID(),
+		CommandString: "copy src dst",
+		Transfers: common.Transfers{List: []common.CopyTransfer{
+			{Source: "/dir/same", Destination: "/dir/same", ContentType: "text/plain", SourceSize: 1},
+			{Source: "/dir/old", Destination: "/dir/new", ContentType: "image/png", ContentMD5: []byte{0, 1, 2},
+				Metadata: common.Metadata{"key": &value}, BlobTags: common.BlobTags{"tag": "value"},
+				BlobType: "BlockBlob", BlobEncryptionScope: "scope", EntityType: common.EEntityType.File()},
+			{Source: "", Destination: ""},
+			{Source: longPath, Destination: longPath + "x", BlobVersionID: "version"},
+		}},
+	}
+	// enough transfers that the index is read past the first few records
+	for i := 0; i < 1000; i++ {
+		order.Transfers.List = append(order.Transfers.List, common.CopyTransfer{
+			Source: fmt.Sprintf("/many/%d", i), Destination: fmt.Sprintf("/many/%d", i), SourceSize: int64(i)})
+	}
+	plan := JobPartPlanFileName(fmt.Sprintf(JobPartPlanFileNameFormat, order.JobID.String(), 0, DataSchemaVersion))
+	plan.Create(order)
+	mmf := plan.Map()
+	defer mmf.Unmap()
+	jpph := mmf.Plan()
+
+	a.Equal(uint32(len(order.Transfers.List)), jpph.NumTransfers)
+	a.Equal(order.CommandString, jpph.CommandString())
+	for i, transfer := range order.Transfers.List {
+		jppt := jpph.Transfer(uint32(i))
+		a.Zero(uintptr(unsafe.Pointer(&jppt.atomicSavedOffset))%8, "the transfer's atomics are aligned")
+		a.Equal(transfer.SourceSize, jppt.SourceSize)
+		a.Equal(common.ETransferStatus.Started(), jppt.TransferStatus())
+
+		src, dst := jpph.TransferSrcDstRelatives(uint32(i))
+		a.Equal(transfer.Source, src)
+		a.Equal(transfer.Destination, dst)
+		h, metadata, blobType, _, _, _, _, _, entityType, versionID, _, tags, scope := jpph.TransferSrcPropertiesAndMetadata(uint32(i))
+		a.Equal(transfer.ContentType, h.ContentType)
+		a.Equal(transfer.ContentMD5, h.ContentMD5)
+		a.Equal(transfer.Metadata, metadata)
+		a.Equal(transfer.BlobType, blobType)
+		a.Equal(transfer.EntityType, entityType)
+		a.Equal(transfer.BlobVersionID, versionID)
+		a.Equal(transfer.BlobTags, tags)
+		a.Equal(transfer.BlobEncryptionScope, scope)
+	}
+
+	// a transfer's status is kept in the map, next to its strings
+	jpph.Transfer(2).SetTransferStatus(common.ETransferStatus.Success(), false)
+	a.Equal(common.ETransferStatus.Success(), jpph.Transfer(2).TransferStatus())
+	src, _ := jpph.TransferSrcDstRelatives(3)
+	a.Equal(longPath, src)
+}
+
+func TestMigratePlanFiles(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	// a plan of version 19, as an earlier release wrote it, of a job with two transfers
+	jobID := common.NewJobID()
+	command := "copy src dst"
+	header := jobPartPlanHeaderV19{
+		Version:             migratableDataSchemaVersion,
+		JobID:               jobID,
+		FromTo:              common.EFromTo.LocalBlob(),
+		CommandStringLength: uint32(len(command)),
+		NumTransfers:        2,
+		IsFinalPart:         true,
+		atomicJobStatus:     common.EJobStatus.InProgress(),
+	}
+	header.SourceRootLength = uint16(copy(header.SourceRoot[:], "/data"))
+	header.DstBlobData.BlockSize = 8 * 1024 * 1024
+	header.DstBlobData.ContentTypeLength = uint16(copy(header.DstBlobData.ContentType[:], "text/plain"))
+	header.DstLocalData.MD5VerificationOption = common.EHashValidationOption.FailIfDifferentOrMissing()
+	header.DeleteSnapshotsOption = common.EDeleteSnapshotsOption.Include()
+
+	transfersOffset := (int64(unsafe.Sizeof(header)) + int64(len(command)) + 7) &^ 7
+	stringsOffset := transfersOffset + 2*int64(unsafe.Sizeof(jobPartPlanTransferV19{}))
+	transfers := []jobPartPlanTransferV19{
+		{SrcOffset: stringsOffset, SrcLength: 2, DstLength: 2, SourceSize: 10, SrcContentTypeLength: 9,
+			atomicTransferStatus: common.ETransferStatus.Success()},
+		{SrcOffset: stringsOffset + 13, SrcLength: 2, DstLength: 3, SourceSize: 20,
+			atomicTransferStatus: common.ETransferStatus.Failed(), atomicErrorCode: 404},
+	}
+	plan := append(structBytes(&header), command...)
+	plan = append(plan, make([]byte, transfersOffset-int64(len(plan)))...)
+	for i := range transfers {
+		plan = append(plan, structBytes(&transfers[i])...)
+	}
+	plan = append(plan, "/a/aimage/png/b/bc"...)
+	name := fmt.Sprintf(JobPartPlanFileNameFormat, jobID.String(), 0, migratableDataSchemaVersion)
+	a.NoError(os.WriteFile(filepath.Join(common.AzcopyJobPlanFolder, name), plan, 0644))
+	older := fmt.Sprintf(JobPartPlanFileNameFormat, common.NewJobID().String(), 0, 18)
+	a.NoError(os.WriteFile(filepath.Join(common.AzcopyJobPlanFolder, older), nil, 0644))
+
+	a.NoError(MigratePlanFiles())
+	entries, err := os.ReadDir(common.AzcopyJobPlanFolder)
+	a.NoError(err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	migrated := JobPartPlanFileName(fmt.Sprintf(JobPartPlanFileNameFormat, jobID.String(), 0, DataSchemaVersion))
+	a.ElementsMatch([]string{string(migrated), older}, names, "plans of versions before 19 are left alone")
+
+	mmf := migrated.Map()
+	defer mmf.Unmap()
+	jpph := mmf.Plan()
+	a.Equal(DataSchemaVersion, jpph.Version)
+	a.Equal(jobID, jpph.JobID)
+	a.Equal(command, jpph.CommandString())
+	a.Equal(common.EFromTo.LocalBlob(), jpph.FromTo)
+	a.True(jpph.IsFinalPart)
+	a.Equal(common.EJobStatus.InProgress(), jpph.JobStatus())
+	a.Equal("/data", string(jpph.SourceRoot[:jpph.SourceRootLength]))
+	a.Equal(int64(8*1024*1024), jpph.DstBlobData.BlockSize)
+	a.Equal("text/plain", string(jpph.DstBlobData.ContentType[:jpph.DstBlobData.ContentTypeLength]))
+	a.Equal(common.EHashValidationOption.FailIfDifferentOrMissing(), jpph.DstLocalData.MD5VerificationOption)
+	a.Equal(common.EDeleteSnapshotsOption.Include(), jpph.DeleteSnapshotsOption)
+	a.False(jpph.DstBlobData.BlockDelta, "settings that version 19 didn't have are off")
+
+	for i, want := range []struct {
+		src, dst, contentType string
+		size                  int64
+		status                common.TransferStatus
+	}{
+		{"/a", "/a", "image/png", 10, common.ETransferStatus.Success()},
+		{"/b", "/bc", "", 20, common.ETransferStatus.Failed()},
+	} {
+		src, dst := jpph.TransferSrcDstRelatives(uint32(i))
+		a.Equal(want.src, src)
+		a.Equal(want.dst, dst)
+		h, _, _, _, _, _, _, _, _, _, _, _, _ := jpph.TransferSrcPropertiesAndMetadata(uint32(i))
+		a.Equal(want.contentType, h.ContentType)
+		a.Equal(want.size, jpph.Transfer(uint32(i)).SourceSize)
+		a.Equal(want.status, jpph.Transfer(uint32(i)).TransferStatus())
+	}
+	a.Equal(int32(404), jpph.Transfer(1).ErrorCode())
+}
+
+func TestMigrateV19PlanFileRefusesStringsPastTheEnd(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	header := jobPartPlanHeaderV19{Version: migratableDataSchemaVersion, NumTransfers: 1}
+	transfersOffset := int64(unsafe.Sizeof(header)+7) &^ 7
+	transfer := jobPartPlanTransferV19{SrcOffset: transfersOffset + int64(unsafe.Sizeof(jobPartPlanTransferV19{})), SrcLength: 100}
+	plan := append(structBytes(&header), make([]byte, transfersOffset-int64(unsafe.Sizeof(header)))...)
+	plan = append(plan, structBytes(&transfer)...)
+	from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+	a.NoError(os.WriteFile(from, plan, 0644))
+
+	a.Error(migrateV19PlanFile(from, to))
+	entries, err := os.ReadDir(dir)
+	a.NoError(err)
+	a.Len(entries, 1, "neither the plan nor the one it was being converted to is left behind but the original")
+}
+
+func TestJobPartPlanCommandStringOutlivesTheMap(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	order := common.CopyJobPartOrderRequest{JobID: common.NewJobID(), CommandString: "copy src dst --recursive"}
+	plan := JobPartPlanFileName(fmt.Sprintf(JobPartPlanFileNameFormat, order.JobID.String(), 0, DataSchemaVersion))
+	plan.Create(order)
+	mmf := plan.Map()
+	command := mmf.Plan().CommandString()
+	mmf.Unmap()
+
+	// as ListJobs does, the command string is used once the plan has been unmapped
+	a.Equal(order.CommandString, strings.Clone(command))
+}
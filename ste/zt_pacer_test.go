@@ -0,0 +1,173 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sendThroughPacer requests count allocations of size from p, one after another, and returns how long it took
+func sendThroughPacer(ctx context.Context, p pacer, count int, size int64) (time.Duration, error) {
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		if err := p.RequestTrafficAllocation(ctx, size); err != nil {
+			return time.Since(start), err
+		}
+	}
+	return time.Since(start), nil
+}
+
+func TestTokenBucketPacerKeepsToTheCap(t *testing.T) {
+	a := assert.New(t)
+	p := NewTokenBucketPacer(1024*1024, 64*1024)
+	defer p.Close()
+
+	// the first 64 KiB go at once, and the rest at a MiB a second
+	elapsed, err := sendThroughPacer(context.Background(), p, 64, 16*1024)
+	a.NoError(err)
+	a.InDelta(0.9375, elapsed.Seconds(), 0.1)
+	a.Equal(int64(1024*1024), p.GetTotalTraffic())
+}
+
+func TestTokenBucketPacerAllowsRequestsLargerThanTheBucket(t *testing.T) {
+	a := assert.New(t)
+	p := NewTokenBucketPacer(1024*1024, 64*1024)
+	defer p.Close()
+
+	// each request leaves the bucket in debt, which it must be out of before the next
+	elapsed, err := sendThroughPacer(context.Background(), p, 5, 256*1024)
+	a.NoError(err)
+	a.InDelta(1.0, elapsed.Seconds(), 0.1)
+}
+
+func TestTokenBucketPacerBursts(t *testing.T) {
+	a := assert.New(t)
+	p := NewTokenBucketPacer(1024*1024, 512*1024)
+	defer p.Close()
+
+	elapsed, err := sendThroughPacer(context.Background(), p, 8, 64*1024)
+	a.NoError(err)
+	a.Less(elapsed, 50*time.Millisecond, "a full bucket's worth goes at once")
+
+	elapsed, err = sendThroughPacer(context.Background(), p, 8, 64*1024)
+	a.NoError(err)
+	a.InDelta(0.5, elapsed.Seconds(), 0.1, "but then it's empty")
+}
+
+func TestTokenBucketPacerCancel(t *testing.T) {
+	a := assert.New(t)
+	p := NewTokenBucketPacer(64*1024, 64*1024)
+	defer p.Close()
+
+	a.NoError(p.RequestTrafficAllocation(context.Background(), 64*1024))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	a.ErrorIs(p.RequestTrafficAllocation(ctx, 64*1024), context.DeadlineExceeded)
+	a.Equal(int64(64*1024), p.GetTotalTraffic())
+
+	// a change of target applies to those waiting
+	done := make(chan error)
+	go func() { done <- p.RequestTrafficAllocation(context.Background(), 1024*1024) }()
+	time.Sleep(50 * time.Millisecond)
+	p.UpdateTargetBytesPerSecond(0)
+	select {
+	case err := <-done:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		a.Fail("the request wasn't let through when the cap was lifted")
+	}
+}
+
+func TestTokenBucketPacerSharesFairlyBetweenJobs(t *testing.T) {
+	a := assert.New(t)
+	p := NewTokenBucketPacer(1024*1024, 16*1024)
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	var busyJob, quietJob int64
+	var wg sync.WaitGroup
+	send := func(job string, total *int64) {
+		defer wg.Done()
+		jobCtx := withPacerShare(ctx, job)
+		for p.RequestTrafficAllocation(jobCtx, 16*1024) == nil {
+			atomic.AddInt64(total, 16*1024)
+		}
+	}
+	// one job has eight times the requests waiting that the other does, but they get the same share
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go send("busy", &busyJob)
+	}
+	wg.Add(1)
+	go send("quiet", &quietJob)
+	wg.Wait()
+
+	a.InDelta(float64(busyJob), float64(quietJob), float64(busyJob)*0.25)
+}
+
+// BenchmarkTokenBucketPacerAccuracy reports the rate that eight goroutines get through the pacer, as a percentage of
+// its target, at a low rate and a high one
+func BenchmarkTokenBucketPacerAccuracy(b *testing.B) {
+	for _, mbps := range []int64{1, 100, 10000} {
+		b.Run(fmt.Sprintf("%dMbps", mbps), func(b *testing.B) {
+			target := mbps * 1000 * 1000 / 8
+			p := NewTokenBucketPacer(target, 0)
+			defer p.Close()
+			const requestSize = 32 * 1024
+
+			// start with the bucket empty, so that the burst doesn't count
+			_ = p.RequestTrafficAllocation(context.Background(), int64(p.burst()))
+			b.ResetTimer()
+			start := time.Now()
+			var wg sync.WaitGroup
+			var next int64
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for atomic.AddInt64(&next, 1) <= int64(b.N) {
+						_ = p.RequestTrafficAllocation(context.Background(), requestSize)
+					}
+				}()
+			}
+			wg.Wait()
+			achieved := float64(b.N*requestSize) / time.Since(start).Seconds()
+			b.ReportMetric(100*achieved/float64(target), "%-of-target")
+		})
+	}
+}
+
+// BenchmarkTokenBucketPacerUncapped is the cost of a request to a pacer that has no cap
+func BenchmarkTokenBucketPacerUncapped(b *testing.B) {
+	p := NewTokenBucketPacer(0, 0)
+	defer p.Close()
+	for i := 0; i < b.N; i++ {
+		_ = p.RequestTrafficAllocation(context.Background(), 32*1024)
+	}
+}
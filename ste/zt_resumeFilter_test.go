@@ -0,0 +1,59 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeFilter(t *testing.T) {
+	a := assert.New(t)
+	failed, skipped, pending := common.ETransferStatus.Failed(), common.ETransferStatus.SkippedEntityAlreadyExists(), common.ETransferStatus.NotStarted()
+
+	// without a filter, everything that didn't succeed is retried
+	all := &ResumeFilter{}
+	a.True(all.shouldResume(failed, "dir/a.txt"))
+	a.True(all.shouldResume(skipped, "dir/a.txt"))
+	a.True(all.shouldResume(pending, "dir/a.txt"))
+	a.False(all.shouldResume(common.ETransferStatus.Success(), "dir/a.txt"))
+
+	failedOnly := &ResumeFilter{FailedOnly: true}
+	a.True(failedOnly.shouldResume(failed, "dir/a.txt"))
+	a.True(failedOnly.shouldResume(common.ETransferStatus.BlobTierFailure(), "dir/a.txt"))
+	a.True(failedOnly.shouldResume(common.ETransferStatus.Restarted(), "dir/a.txt"))
+	a.False(failedOnly.shouldResume(skipped, "dir/a.txt"))
+	a.False(failedOnly.shouldResume(pending, "dir/a.txt"))
+	a.False(failedOnly.shouldResume(common.ETransferStatus.Cancelled(), "dir/a.txt"))
+
+	// patterns without a slash match names, and those with one match paths
+	filtered := &ResumeFilter{FailedOnly: true, Include: []string{"*.txt", "logs/*"}, Exclude: []string{"secret.txt"}}
+	a.True(filtered.shouldResume(failed, "dir/a.txt"))
+	a.True(filtered.shouldResume(failed, "logs/today.log"))
+	a.False(filtered.shouldResume(failed, "dir/today.log"))
+	a.False(filtered.shouldResume(failed, "dir/secret.txt"))
+	a.False(filtered.shouldResume(skipped, "dir/a.txt"))
+
+	// a source's exact path matches too, even if it has characters that would otherwise be wildcards
+	a.True((&ResumeFilter{Include: []string{"dir/[1].txt"}}).shouldResume(failed, "dir/[1].txt"))
+}
@@ -0,0 +1,122 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointThrottleCutsBackAndRecovers(t *testing.T) {
+	a := assert.New(t)
+	e := &endpointThrottle{host: "busyaccount.blob.core.windows.net"}
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		a.NoError(e.acquire(ctx))
+	}
+	now := time.Now()
+	e.busy(now)
+	a.Equal(4.0, e.limit)
+	// the other requests that were in progress are busy too, but that doesn't cut it again
+	e.busy(now.Add(time.Second))
+	a.Equal(4.0, e.limit)
+	for i := 0; i < 8; i++ {
+		e.release()
+	}
+
+	for i := 0; i < 4; i++ {
+		a.NoError(e.acquire(ctx))
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	a.ErrorIs(e.acquire(waitCtx), context.DeadlineExceeded)
+
+	// a slot is freed when a request finishes
+	acquired := make(chan error)
+	go func() { acquired <- e.acquire(ctx) }()
+	e.release()
+	a.NoError(<-acquired)
+
+	// later it's busy again, and the cap is cut from 4 to 2
+	e.busy(now.Add(throttleDecreaseInterval))
+	a.Equal(2.0, e.limit)
+
+	// then successes build it back up, until it gets back to where it was throttled, when it's lifted
+	successes := 0
+	for e.limit != 0 {
+		e.succeeded()
+		successes++
+	}
+	a.Greater(successes, 10)
+	a.Less(successes, 40)
+}
+
+type busyTransport struct {
+	busy int // how many requests to say that the endpoint is busy to
+}
+
+func (b *busyTransport) Do(req *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	if b.busy > 0 {
+		b.busy--
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}, Request: req}, nil
+}
+
+func TestThrottleBackoffPolicy(t *testing.T) {
+	a := assert.New(t)
+	// start from a clean slate when the test is run more than once
+	endpointThrottlesMu.Lock()
+	delete(endpointThrottles, "throttledaccount.blob.core.windows.net")
+	endpointThrottlesMu.Unlock()
+
+	pipeline := runtime.NewPipeline("test", "v1", runtime.PipelineOptions{PerRetry: []policy.Policy{newThrottleBackoffPolicy()}},
+		&policy.ClientOptions{Transport: &busyTransport{busy: 1}, Retry: policy.RetryOptions{MaxRetries: -1}})
+
+	req, err := runtime.NewRequest(context.Background(), http.MethodPut, "https://throttledaccount.blob.core.windows.net/c/b")
+	a.NoError(err)
+	resp, err := pipeline.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	a.NoError(resp.Body.Close())
+
+	e := endpointThrottleFor("throttledaccount.blob.core.windows.net")
+	a.Equal(1.0, e.limit)
+	a.Equal(0, e.inProgress)
+
+	req, err = runtime.NewRequest(context.Background(), http.MethodPut, "https://throttledaccount.blob.core.windows.net/c/b")
+	a.NoError(err)
+	resp, err = pipeline.Do(req)
+	a.NoError(err)
+	a.Equal(http.StatusOK, resp.StatusCode)
+	a.NoError(resp.Body.Close())
+	a.Equal(0.0, e.limit, "one request was in progress when it was busy, so one success is enough to lift the cap")
+}
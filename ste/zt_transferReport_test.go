@@ -0,0 +1,135 @@
+// Copyright © Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func Test_RequestCountPolicy_Retries(t *testing.T) {
+	a := assert.New(t)
+
+	// the server is busy for the first two tries of each request
+	tries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		tries++
+		if tries%3 != 0 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	pl := runtime.NewPipeline("", "",
+		runtime.PipelineOptions{PerCall: []policy.Policy{newRequestCountPolicy(false)}, PerRetry: []policy.Policy{newRequestCountPolicy(true)}},
+		&policy.ClientOptions{Transport: http.DefaultClient, Retry: policy.RetryOptions{MaxRetries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond}},
+	)
+	counts := &requestCounts{}
+	ctx := withRequestCounts(context.Background(), counts)
+	for i := 0; i < 2; i++ {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, srv.URL)
+		a.NoError(err)
+		resp, err := pl.Do(req)
+		a.NoError(err)
+		a.Equal(http.StatusOK, resp.StatusCode)
+	}
+	a.EqualValues(2, counts.atomicRequests)
+	a.EqualValues(6, counts.atomicTries)
+	a.EqualValues(4, counts.retries())
+}
+
+func TestTransferReport(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	a.Error(EnableTransferReport(filepath.Join(dir, "report.xml"), "xml"))
+	a.Nil(transferReportWriter)
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	failed := TransferReportEntry{
+		JobID:       common.NewJobID(),
+		Source:      "/data/a, b.txt",
+		Destination: "https://account.blob.core.windows.net/container/a, b.txt",
+		EntityType:  common.EEntityType.File().String(),
+		Status:      common.ETransferStatus.Failed().String(),
+		Bytes:       1024,
+		StartTime:   start,
+		EndTime:     start.Add(time.Second),
+		Retries:     3,
+		ErrorCode:   503,
+		Error:       "ServerBusy. When Staging block.",
+	}
+	cancelled := TransferReportEntry{JobID: failed.JobID, Source: "/data/c", Destination: "https://account.blob.core.windows.net/container/c",
+		EntityType: common.EEntityType.File().String(), Status: common.ETransferStatus.Cancelled().String(), EndTime: start}
+
+	// CSV has a header, and quotes what needs it
+	csvPath := filepath.Join(dir, "report.csv")
+	a.NoError(EnableTransferReport(csvPath, TransferReportFormatCSV))
+	transferReportWriter.write(failed)
+	transferReportWriter.write(cancelled)
+	a.NoError(FlushTransferReport())
+	a.NoError(CloseTransferReport())
+	a.Nil(transferReportWriter)
+
+	f, err := os.Open(csvPath)
+	a.NoError(err)
+	records, err := csv.NewReader(f).ReadAll()
+	_ = f.Close()
+	a.NoError(err)
+	a.Len(records, 3)
+	a.Equal(transferReportCSVHeader, records[0])
+	a.Equal([]string{failed.JobID.String(), "/data/a, b.txt", "https://account.blob.core.windows.net/container/a, b.txt", "File",
+		"Failed", "1024", "2024-05-01T10:00:00Z", "2024-05-01T10:00:01Z", "3", "503", "ServerBusy. When Staging block."}, records[1])
+	a.Equal("", records[2][6]) // it never started
+
+	// JSON is an object per line
+	jsonPath := filepath.Join(dir, "report.json")
+	a.NoError(EnableTransferReport(jsonPath, TransferReportFormatJSON))
+	transferReportWriter.write(failed)
+	transferReportWriter.write(cancelled)
+	a.NoError(CloseTransferReport())
+
+	content, err := os.ReadFile(jsonPath)
+	a.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	a.Len(lines, 2)
+	var e TransferReportEntry
+	a.NoError(json.Unmarshal([]byte(lines[0]), &e))
+	a.Equal(failed, e)
+	a.NotContains(lines[1], "StartTime")
+	a.NotContains(lines[1], "Error")
+}
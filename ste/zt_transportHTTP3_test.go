@@ -0,0 +1,140 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTransport records the bodies of the requests it's sent, and fails them if err is set
+type recordingTransport struct {
+	err    error
+	bodies []string
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		buf, _ := io.ReadAll(req.Body)
+		body = string(buf)
+	}
+	r.bodies = append(r.bodies, body)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func newRewindableRequest(t *testing.T, ctx context.Context, url, body string) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	return req
+}
+
+func TestHTTP3FallbackTransport(t *testing.T) {
+	a := assert.New(t)
+	h3 := &recordingTransport{}
+	tcp := &recordingTransport{}
+	transport := newHTTP3FallbackTransport(h3, tcp, nil)
+
+	_, err := transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://account.blob.core.windows.net/c/a", "one"))
+	a.NoError(err)
+	a.Equal([]string{"one"}, h3.bodies)
+	a.Empty(tcp.bodies)
+
+	// when HTTP/3 fails, the request is sent again over TCP, with its body from the start
+	h3.err = errors.New("timeout: no recent network activity")
+	_, err = transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://account.blob.core.windows.net/c/b", "two"))
+	a.NoError(err)
+	a.Equal([]string{"one", "two"}, h3.bodies)
+	a.Equal([]string{"two"}, tcp.bodies)
+
+	// and later requests to that host go straight over TCP, while others still try HTTP/3
+	_, err = transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://account.blob.core.windows.net/c/c", "three"))
+	a.NoError(err)
+	a.Equal([]string{"two", "three"}, tcp.bodies)
+	_, _ = transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://other.blob.core.windows.net/c/d", "four"))
+	a.Equal([]string{"one", "two", "four"}, h3.bodies)
+
+	// until it's time to try HTTP/3 again
+	a.False(transport.useHTTP3("account.blob.core.windows.net", time.Now()))
+	a.True(transport.useHTTP3("account.blob.core.windows.net", time.Now().Add(http3RetryInterval+time.Second)))
+
+	// plain HTTP never goes over HTTP/3
+	_, err = transport.RoundTrip(newRewindableRequest(t, context.Background(), "http://127.0.0.1:10000/c/e", "five"))
+	a.NoError(err)
+	a.Equal([]string{"one", "two", "four"}, h3.bodies)
+}
+
+func TestHTTP3FallbackTransportProxied(t *testing.T) {
+	a := assert.New(t)
+	h3 := &recordingTransport{}
+	tcp := &recordingTransport{}
+	proxy, _ := url.Parse("socks5://localhost:1080")
+	transport := newHTTP3FallbackTransport(h3, tcp, func(req *http.Request) (*url.URL, error) {
+		if req.URL.Host == "direct.blob.core.windows.net" {
+			return nil, nil
+		}
+		return proxy, nil
+	})
+
+	_, err := transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://account.blob.core.windows.net/c/a", "one"))
+	a.NoError(err)
+	_, err = transport.RoundTrip(newRewindableRequest(t, context.Background(), "https://direct.blob.core.windows.net/c/b", "two"))
+	a.NoError(err)
+	a.Equal([]string{"one"}, tcp.bodies, "QUIC can't go through the proxy")
+	a.Equal([]string{"two"}, h3.bodies)
+}
+
+func TestHTTP3FallbackTransportCancelled(t *testing.T) {
+	a := assert.New(t)
+	h3 := &recordingTransport{err: context.Canceled}
+	tcp := &recordingTransport{}
+	transport := newHTTP3FallbackTransport(h3, tcp, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := transport.RoundTrip(newRewindableRequest(t, ctx, "https://account.blob.core.windows.net/c/a", "one"))
+	a.ErrorIs(err, context.Canceled)
+	a.Empty(tcp.bodies, "a cancelled request isn't sent again")
+	a.True(transport.useHTTP3("account.blob.core.windows.net", time.Now()))
+}
+
+func TestHTTP3NeedsTheBuildTag(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv(common.EEnvironmentVariable.HTTP3().Name, "true")
+	_, err := readTransportSettings()
+	if _, h3Err := newHTTP3Transport(&http.Transport{}, TransportSettings{}); h3Err == nil {
+		a.NoError(err)
+	} else {
+		a.ErrorIs(err, errHTTP3NotBuilt)
+	}
+}
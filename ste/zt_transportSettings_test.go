@@ -0,0 +1,93 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTransportSettings(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv(common.EEnvironmentVariable.MaxIdleConnsPerHost().Name, "64")
+	t.Setenv(common.EEnvironmentVariable.TLSSessionCacheSize().Name, "128")
+	t.Setenv(common.EEnvironmentVariable.DisableHTTP2().Name, "true")
+	t.Setenv(common.EEnvironmentVariable.DialTimeout().Name, "45s")
+	t.Setenv(common.EEnvironmentVariable.TCPKeepAlive().Name, "-1s")
+
+	s, err := readTransportSettings()
+	a.NoError(err)
+	a.Equal(TransportSettings{
+		MaxIdleConnsPerHost: 64,
+		TLSSessionCacheSize: 128,
+		DisableHTTP2:        true,
+		DialTimeout:         45 * time.Second,
+		KeepAlive:           -time.Second,
+	}, s)
+	a.Equal("max idle connections per host 64, TLS session cache 128, HTTP/2 disabled, dial timeout 45s, TCP keep-alive off", s.String())
+
+	transport := &http.Transport{MaxIdleConnsPerHost: 4, TLSHandshakeTimeout: 10 * time.Second}
+	s.apply(transport)
+	a.Equal(64, transport.MaxIdleConnsPerHost)
+	a.Equal(10*time.Second, transport.TLSHandshakeTimeout, "what isn't set is left alone")
+	a.NotNil(transport.DialContext)
+	a.NotNil(transport.TLSClientConfig.ClientSessionCache)
+	a.NotNil(transport.TLSNextProto)
+	a.Empty(transport.TLSNextProto)
+}
+
+func TestReadTransportSettingsDefaults(t *testing.T) {
+	a := assert.New(t)
+	s, err := readTransportSettings()
+	a.NoError(err)
+	a.Equal("defaults", s.String())
+
+	transport := &http.Transport{MaxIdleConnsPerHost: 4}
+	s.apply(transport)
+	a.Equal(4, transport.MaxIdleConnsPerHost)
+	a.Nil(transport.DialContext)
+	a.Nil(transport.TLSClientConfig)
+	a.True(transport.ForceAttemptHTTP2)
+}
+
+func TestReadTransportSettingsInvalid(t *testing.T) {
+	a := assert.New(t)
+	for _, c := range []struct {
+		envVar common.EnvironmentVariable
+		value  string
+	}{
+		{common.EEnvironmentVariable.MaxIdleConnsPerHost(), "lots"},
+		{common.EEnvironmentVariable.TLSSessionCacheSize(), "-1"},
+		{common.EEnvironmentVariable.DisableHTTP2(), "maybe"},
+		{common.EEnvironmentVariable.DialTimeout(), "30"},
+		{common.EEnvironmentVariable.TLSHandshakeTimeout(), "-5s"},
+	} {
+		t.Run(c.envVar.Name, func(t *testing.T) {
+			t.Setenv(c.envVar.Name, c.value)
+			_, err := readTransportSettings()
+			a.ErrorContains(err, c.envVar.Name)
+		})
+	}
+}
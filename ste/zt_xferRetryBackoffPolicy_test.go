@@ -0,0 +1,279 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/minio/minio-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrySettingsDelay(t *testing.T) {
+	a := assert.New(t)
+	s := RetrySettings{RetryDelay: time.Second, MaxRetryDelay: 10 * time.Second}
+
+	for retry, expected := range map[int]time.Duration{1: 1, 2: 2, 3: 4, 4: 8, 5: 10, 1000: 10} {
+		a.Equal(expected*time.Second, s.delay(retry, 0.5), "retry %d", retry)
+	}
+
+	s.Jitter = 0.25
+	a.Equal(750*time.Millisecond, s.delay(1, 0))
+	a.Equal(1250*time.Millisecond, s.delay(1, 1))
+	a.Equal(7500*time.Millisecond, s.delay(5, 0))
+	a.Equal(10*time.Second, s.delay(5, 1), "the jitter doesn't take it past the maximum")
+}
+
+func resetRetrySettings() {
+	retrySettings.Store(nil)
+	minio.MaxRetry = 10
+}
+
+func TestSetRetrySettings(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+
+	valid := DefaultRetrySettings()
+	for _, change := range []func(*RetrySettings){
+		func(s *RetrySettings) { s.MaxRetries = -1 },
+		func(s *RetrySettings) { s.RetryDelay = 0 },
+		func(s *RetrySettings) { s.MaxRetryDelay = s.RetryDelay / 2 },
+		func(s *RetrySettings) { s.Jitter = 1.5 },
+		func(s *RetrySettings) { s.Deadline = -time.Second },
+		func(s *RetrySettings) { s.StallTimeout = -time.Second },
+	} {
+		s := valid
+		change(&s)
+		a.Error(SetRetrySettings(s))
+	}
+
+	valid.MaxRetries = 0
+	a.NoError(SetRetrySettings(valid))
+	options := RetryOptions()
+	a.Equal(int32(-1), options.MaxRetries, "0 retries isn't the SDK's default")
+	a.Equal(UploadTryTimeout, options.TryTimeout)
+	a.Equal(time.Nanosecond, options.RetryDelay)
+	a.Equal(1, minio.MaxRetry)
+}
+
+// retryTestServer responds with the status codes in statuses, with header, and then with 200s, and records when each
+// request arrives
+func retryTestServer(statuses []int, header http.Header) (*httptest.Server, func() []time.Time) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		arrivals = append(arrivals, time.Now())
+		if len(arrivals) <= len(statuses) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(statuses[len(arrivals)-1])
+		}
+	}))
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return arrivals
+	}
+}
+
+// retryTestDo makes a request to url through a pipeline with the retry backoff policies and the current settings
+func retryTestDo(url string) (*http.Response, error) {
+	return retryTestDoWithContext(context.Background(), url)
+}
+
+func retryTestDoWithContext(ctx context.Context, url string) (*http.Response, error) {
+	pl := runtime.NewPipeline("", "",
+		runtime.PipelineOptions{
+			PerCall:  []policy.Policy{newRequestCountPolicy(false), newRetryBackoffPolicy(false)},
+			PerRetry: []policy.Policy{newRetryBackoffPolicy(true), newRequestCountPolicy(true)},
+		},
+		&policy.ClientOptions{Transport: http.DefaultClient, Retry: RetryOptions()},
+	)
+	req, err := runtime.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pl.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryBackoffPolicyWaits(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	a.NoError(SetRetrySettings(RetrySettings{MaxRetries: 5, RetryDelay: 50 * time.Millisecond, MaxRetryDelay: time.Second}))
+
+	srv, arrivals := retryTestServer([]int{503, 503}, nil)
+	defer srv.Close()
+	resp, err := retryTestDo(srv.URL)
+	if a.NoError(err) {
+		a.Equal(http.StatusOK, resp.StatusCode)
+	}
+	if times := arrivals(); a.Len(times, 3) {
+		a.GreaterOrEqual(times[1].Sub(times[0]), 50*time.Millisecond)
+		a.GreaterOrEqual(times[2].Sub(times[1]), 100*time.Millisecond)
+	}
+}
+
+func TestRetryBackoffPolicyKeepsToTheRetryCount(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+
+	for _, retries := range []int{0, 2} {
+		a.NoError(SetRetrySettings(RetrySettings{MaxRetries: retries, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond}))
+		srv, arrivals := retryTestServer([]int{503, 503, 503, 503}, nil)
+		resp, err := retryTestDo(srv.URL)
+		if a.NoError(err) {
+			a.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+		}
+		a.Len(arrivals(), retries+1)
+		srv.Close()
+	}
+}
+
+func TestRetryBackoffPolicyKeepsToTheTransfersRetryBudget(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	a.NoError(SetRetrySettings(RetrySettings{MaxRetries: 10, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond, Budget: 3}))
+
+	srv, arrivals := retryTestServer([]int{503, 503, 503, 503, 503, 503, 503, 503}, nil)
+	defer srv.Close()
+	counts := &requestCounts{}
+	ctx := withRequestCounts(context.Background(), counts)
+
+	_, err := retryTestDoWithContext(ctx, srv.URL)
+	var budgetErr retryBudgetError
+	if a.True(errors.As(err, &budgetErr)) {
+		a.Contains(err.Error(), "503")
+	}
+	a.Len(arrivals(), 4)
+
+	// the transfer's next request has no retries left
+	_, err = retryTestDoWithContext(ctx, srv.URL)
+	a.True(errors.As(err, &budgetErr))
+	a.Len(arrivals(), 5)
+	a.Equal(int64(3), counts.retries())
+}
+
+func TestRetryBackoffPolicyGivesUpAtTheDeadline(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	a.NoError(SetRetrySettings(RetrySettings{MaxRetries: 100, RetryDelay: 40 * time.Millisecond, MaxRetryDelay: 40 * time.Millisecond, Deadline: 100 * time.Millisecond}))
+
+	srv, arrivals := retryTestServer([]int{503, 503, 503, 503, 503, 503, 503, 503}, nil)
+	defer srv.Close()
+	_, err := retryTestDo(srv.URL)
+	var deadlineErr retryDeadlineError
+	if a.True(errors.As(err, &deadlineErr)) {
+		a.Contains(err.Error(), "503")
+	}
+	a.Len(arrivals(), 3, "at 0, 40 and 80ms")
+}
+
+func TestRetryBackoffPolicyLeavesRetryAfterToTheSDK(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	a.NoError(SetRetrySettings(RetrySettings{MaxRetries: 5, RetryDelay: time.Second, MaxRetryDelay: time.Second}))
+
+	srv, arrivals := retryTestServer([]int{503}, http.Header{"Retry-After-Ms": {"50"}})
+	defer srv.Close()
+	_, err := retryTestDo(srv.URL)
+	a.NoError(err)
+	if times := arrivals(); a.Len(times, 2) {
+		wait := times[1].Sub(times[0])
+		a.GreaterOrEqual(wait, 50*time.Millisecond)
+		a.Less(wait, 500*time.Millisecond, "the backoff isn't waited for as well")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	in := func(d time.Duration, format string) string {
+		return now.Add(d).Format(format)
+	}
+
+	for _, c := range []struct {
+		header   http.Header
+		wait     time.Duration
+		sdkWaits bool
+	}{
+		{http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"3"}}, 250 * time.Millisecond, true},
+		{http.Header{"X-Ms-Retry-After-Ms": {"40"}}, 40 * time.Millisecond, true},
+		{http.Header{"Retry-After": {in(90*time.Second, http.TimeFormat)}}, 90 * time.Second, true},
+		{http.Header{"Retry-After": {in(90*time.Second, time.RFC850)}}, 90 * time.Second, false},
+		{http.Header{"Retry-After": {in(90*time.Second, time.ANSIC)}}, 90 * time.Second, false},
+		{http.Header{"Retry-After": {in(-time.Minute, http.TimeFormat)}}, 0, false},
+		{http.Header{"Retry-After": {"0"}}, 0, false},
+		{http.Header{"Retry-After": {"soon"}}, 0, false},
+		{http.Header{}, 0, false},
+	} {
+		wait, sdkWaits := retryAfter(&http.Response{Header: c.header}, now)
+		a.Equal(c.wait, wait, c.header)
+		a.Equal(c.sdkWaits, sdkWaits, c.header)
+	}
+	wait, _ := retryAfter(nil, now)
+	a.Zero(wait)
+}
+
+func TestRetryBackoffPolicyWaitsForRetryAfterDatesAndCountsThem(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	a.NoError(SetRetrySettings(RetrySettings{MaxRetries: 5, RetryDelay: time.Millisecond, MaxRetryDelay: 5 * time.Second}))
+
+	// the SDK doesn't understand RFC 850 dates, which are to the second, so the wait is up to 2s
+	retryAt := time.Now().Add(2 * time.Second).UTC().Format(time.RFC850)
+	srv, arrivals := retryTestServer([]int{503}, http.Header{"Retry-After": {retryAt}})
+	defer srv.Close()
+
+	stats := newPipelineNetworkStats(&NullConcurrencyTuner{})
+	pl := runtime.NewPipeline("", "",
+		runtime.PipelineOptions{PerCall: []policy.Policy{newRetryBackoffPolicy(false)}, PerRetry: []policy.Policy{newRetryBackoffPolicy(true)}},
+		&policy.ClientOptions{Transport: http.DefaultClient, Retry: RetryOptions()},
+	)
+	req, err := runtime.NewRequest(withPipelineNetworkStats(context.Background(), stats), http.MethodGet, srv.URL)
+	a.NoError(err)
+	resp, err := pl.Do(req)
+	if a.NoError(err) {
+		resp.Body.Close()
+	}
+
+	if times := arrivals(); a.Len(times, 2) {
+		a.GreaterOrEqual(times[1].Sub(times[0]), time.Second)
+	}
+	a.Equal(int64(1), stats.RetryAfterCount())
+	a.GreaterOrEqual(stats.ThrottledTime(), time.Second)
+	a.LessOrEqual(stats.ThrottledTime(), 2*time.Second)
+}
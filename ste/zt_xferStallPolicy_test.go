@@ -0,0 +1,167 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/stretchr/testify/assert"
+)
+
+// stallTestTransport stands in for a connection that goes dead: each try reads the request's body, and while tries
+// are left in stalls, it hangs until it's cancelled, either before it responds, or after it's sent body
+type stallTestTransport struct {
+	stalls int32
+	body   string
+	tries  atomic.Int32
+}
+
+func (t *stallTestTransport) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+	}
+	stall := t.tries.Add(1) <= t.stalls
+	if stall && t.body == "" {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	var body io.Reader = strings.NewReader(t.body)
+	if stall {
+		body = io.MultiReader(body, stallTestReader{req.Context()})
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(body), Request: req}, nil
+}
+
+// stallTestReader reads nothing until ctx is cancelled
+type stallTestReader struct {
+	ctx context.Context
+}
+
+func (r stallTestReader) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+// slowTestReader is AzCopy being slow, sleeping before each read
+type slowTestReader struct {
+	*strings.Reader
+	delay time.Duration
+}
+
+func (r slowTestReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	return r.Reader.Read(p[:min(len(p), 2)])
+}
+
+func stallTestPipeline(timeout time.Duration, transport policy.Transporter) runtime.Pipeline {
+	_ = SetRetrySettings(RetrySettings{MaxRetries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond, StallTimeout: timeout})
+	return runtime.NewPipeline("", "",
+		runtime.PipelineOptions{PerCall: []policy.Policy{newRetryBackoffPolicy(false)}, PerRetry: []policy.Policy{newRetryBackoffPolicy(true), newStallPolicy()}},
+		&policy.ClientOptions{Transport: transport, Retry: RetryOptions()},
+	)
+}
+
+func TestStallPolicyRetriesARequestWhoseResponseStalls(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	savedCodes := RetryStatusCodes
+	defer func() { RetryStatusCodes = savedCodes }()
+	RetryStatusCodes, _ = ParseRetryCodes("503")
+	shouldRetry := GetShouldRetry(nil)
+	a.True(shouldRetry(nil, stalledError{timeout: time.Second}))
+
+	transport := &stallTestTransport{stalls: 2}
+	pl := stallTestPipeline(50*time.Millisecond, transport)
+	stats := newPipelineNetworkStats(nil)
+	req, err := runtime.NewRequest(withPipelineNetworkStats(context.Background(), stats), http.MethodPut, "https://account.blob.core.windows.net/c/b")
+	a.NoError(err)
+	a.NoError(req.SetBody(streaming.NopCloser(strings.NewReader("chunk")), "application/octet-stream"))
+
+	start := time.Now()
+	resp, err := pl.Do(req)
+	if a.NoError(err) {
+		a.Equal(http.StatusOK, resp.StatusCode)
+	}
+	a.Equal(int32(3), transport.tries.Load())
+	a.Less(time.Since(start), time.Second)
+	a.Equal(int64(2), stats.StallCount())
+	a.GreaterOrEqual(stats.StalledTime(), 100*time.Millisecond)
+}
+
+func TestStallPolicyFailsAStalledDownloadWithANetError(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	pl := stallTestPipeline(50*time.Millisecond, &stallTestTransport{stalls: 1, body: "abc"})
+	req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://account.blob.core.windows.net/c/b")
+	a.NoError(err)
+	runtime.SkipBodyDownload(req)
+
+	resp, err := pl.Do(req)
+	a.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	a.Equal("abc", string(body))
+	var netErr net.Error
+	if a.True(errors.As(err, &netErr), "the retry reader retries it") {
+		a.True(netErr.Timeout())
+	}
+	a.NoError(resp.Body.Close())
+}
+
+func TestStallPolicyDoesNotCountTimeSpentByAzCopy(t *testing.T) {
+	a := assert.New(t)
+	defer resetRetrySettings()
+	transport := &stallTestTransport{body: "abcdef"}
+	pl := stallTestPipeline(50*time.Millisecond, transport)
+	req, err := runtime.NewRequest(context.Background(), http.MethodPut, "https://account.blob.core.windows.net/c/b")
+	a.NoError(err)
+	// reading the file to upload, and writing what's downloaded, each take longer than the stall timeout
+	a.NoError(req.SetBody(streaming.NopCloser(slowTestReader{Reader: strings.NewReader("abcdef"), delay: 80 * time.Millisecond}), "application/octet-stream"))
+	runtime.SkipBodyDownload(req)
+
+	resp, err := pl.Do(req)
+	if a.NoError(err) {
+		var body []byte
+		buf := make([]byte, 2)
+		for {
+			time.Sleep(80 * time.Millisecond)
+			n, err := resp.Body.Read(buf)
+			body = append(body, buf[:n]...)
+			if err != nil {
+				a.Equal(io.EOF, err)
+				break
+			}
+		}
+		a.Equal("abcdef", string(body))
+		a.NoError(resp.Body.Close())
+	}
+	a.Equal(int32(1), transport.tries.Load())
+}
@@ -0,0 +1,332 @@
+// Package webdav lets AzCopy copy from WebDAV servers, such as Nextcloud and ownCloud, as a common.Provider.
+// A webdav:// URL is fetched over http, and a webdavs:// URL over https, e.g.
+//
+//	azcopy copy "webdavs://cloud.example.com/remote.php/dav/files/alice/photos/" "https://account.blob.core.windows.net/photos" --recursive
+//
+// Credentials for basic authentication are taken from AZCOPY_WEBDAV_USERNAME and AZCOPY_WEBDAV_PASSWORD.
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func init() {
+	p := &provider{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					if common.GlobalProxyLookup == nil {
+						return http.ProxyFromEnvironment(req)
+					}
+					return common.GlobalProxyLookup(req)
+				},
+				MaxIdleConnsPerHost:   64,
+				IdleConnTimeout:       180 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: time.Minute,
+			},
+		},
+	}
+	common.RegisterProvider("webdav", p)
+	common.RegisterProvider("webdavs", p)
+}
+
+type provider struct {
+	client *http.Client
+}
+
+// the properties we ask for. Servers often leave out getcontentlength and getcontenttype for collections.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontentlength/>
+    <d:getlastmodified/>
+    <d:getcontenttype/>
+  </d:prop>
+</d:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+				ContentType   string `xml:"DAV: getcontenttype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// entry is one resource from a PROPFIND response. Its path is unescaped and cleaned, with no trailing slash.
+type entry struct {
+	path string
+	obj  common.ProviderObject
+}
+
+// statusError is returned when the server answers with a status we didn't expect
+type statusError struct {
+	method string
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.method, e.url, e.status)
+}
+
+func statusCode(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code
+	}
+	return 0
+}
+
+// httpURL returns the http or https URL for a webdav or webdavs URL
+func httpURL(u *url.URL) *url.URL {
+	out := *u
+	out.Scheme = common.Iff(strings.EqualFold(u.Scheme, "webdavs"), "https", "http")
+	out.User = nil
+	return &out
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func (p *provider) newRequest(ctx context.Context, method string, u *url.URL, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, httpURL(u).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", common.UserAgent)
+	if user := common.GetEnvironmentVariable(common.EEnvironmentVariable.WebDAVUsername()); user != "" {
+		req.SetBasicAuth(user, common.GetEnvironmentVariable(common.EEnvironmentVariable.WebDAVPassword()))
+	}
+	return req, nil
+}
+
+// propfind lists u, and the resources under it to the given depth ("0", "1" or "infinity")
+func (p *provider) propfind(ctx context.Context, u *url.URL, depth string) ([]entry, error) {
+	req, err := p.newRequest(ctx, "PROPFIND", u, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, &statusError{method: req.Method, url: req.URL.String(), status: resp.Status, code: resp.StatusCode}
+	}
+
+	var ms multistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("reading PROPFIND response from %s: %w", req.URL, err)
+	}
+
+	entries := make([]entry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href) // may be a path, or a full URL
+		if err != nil {
+			return nil, fmt.Errorf("invalid href %q in PROPFIND response from %s: %w", r.Href, req.URL, err)
+		}
+		e := entry{path: cleanPath(href.Path)}
+		for _, ps := range r.Propstats {
+			// properties the server doesn't have come back in a propstat of their own, with a 404 status
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			e.obj.IsDirectory = ps.Prop.ResourceType.Collection != nil
+			if ps.Prop.ContentLength != "" {
+				if e.obj.Size, err = strconv.ParseInt(strings.TrimSpace(ps.Prop.ContentLength), 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid content length for %s: %w", e.path, err)
+				}
+			}
+			if ps.Prop.LastModified != "" {
+				e.obj.LastModified, _ = http.ParseTime(strings.TrimSpace(ps.Prop.LastModified))
+			}
+			e.obj.ContentType = ps.Prop.ContentType
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// relativePath returns p relative to the directory root, or false if it isn't under root
+func relativePath(root, p string) (string, bool) {
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(p, prefix), true
+}
+
+func (p *provider) Enumerate(ctx context.Context, root *url.URL, recursive bool, found func(common.ProviderObject) error) error {
+	depth := common.Iff(recursive, "infinity", "1")
+	entries, err := p.propfind(ctx, root, depth)
+	if recursive && statusCode(err) == http.StatusForbidden {
+		// RFC 4918 lets servers refuse Depth: infinity, so walk the tree a level at a time instead
+		entries, err = p.propfind(ctx, root, "1")
+	}
+	if err != nil {
+		return err
+	}
+	return p.report(ctx, root, cleanPath(root.Path), entries, recursive, found)
+}
+
+// report passes the entries listed for dir to found. If walk is set, and nothing deeper than dir's children was
+// listed, because we asked for Depth: 1, or because the server quietly capped Depth: infinity at 1 as Nextcloud and
+// ownCloud do, each subcollection is listed in turn.
+func (p *provider) report(ctx context.Context, dir *url.URL, rootPath string, entries []entry, walk bool, found func(common.ProviderObject) error) error {
+	dirPath := cleanPath(dir.Path)
+	var subdirs []string
+	deeper := false
+
+	for _, e := range entries {
+		if e.path == dirPath {
+			if !e.obj.IsDirectory && dirPath == rootPath {
+				return found(e.obj) // the root is a single file, which has an empty relative path
+			}
+			continue
+		}
+
+		rel, ok := relativePath(rootPath, e.path)
+		if !ok {
+			continue // servers shouldn't list anything outside what we asked for, but don't copy it if they do
+		}
+		e.obj.RelativePath = rel
+		if err := found(e.obj); err != nil {
+			return err
+		}
+
+		if childRel, _ := relativePath(dirPath, e.path); strings.Contains(childRel, "/") {
+			deeper = true
+		} else if e.obj.IsDirectory {
+			subdirs = append(subdirs, e.path)
+		}
+	}
+
+	if !walk || deeper {
+		return nil
+	}
+	for _, sub := range subdirs {
+		u := *dir
+		u.Path, u.RawPath = sub+"/", ""
+		entries, err := p.propfind(ctx, &u, "1")
+		if err != nil {
+			return err
+		}
+		if err = p.report(ctx, &u, rootPath, entries, true, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *provider) Properties(ctx context.Context, u *url.URL) (common.ProviderObject, error) {
+	entries, err := p.propfind(ctx, u, "0")
+	if statusCode(err) == http.StatusNotFound {
+		return common.ProviderObject{}, fmt.Errorf("%s: %w", httpURL(u), common.ErrProviderObjectNotFound)
+	} else if err != nil {
+		return common.ProviderObject{}, err
+	}
+	if len(entries) == 0 {
+		return common.ProviderObject{}, fmt.Errorf("%s: %w", httpURL(u), common.ErrProviderObjectNotFound)
+	}
+	return entries[0].obj, nil
+}
+
+func (p *provider) Open(ctx context.Context, u *url.URL) (common.CloseableReaderAt, error) {
+	obj, err := p.Properties(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if obj.IsDirectory {
+		return nil, fmt.Errorf("%s is a collection, not a file", httpURL(u))
+	}
+	return &rangeReader{p: p, ctx: ctx, u: u, size: obj.Size}, nil
+}
+
+func (p *provider) Create(ctx context.Context, u *url.URL, obj common.ProviderObject) (common.ProviderWriter, error) {
+	return nil, errors.New("WebDAV is only supported as a copy source")
+}
+
+// rangeReader reads a file with ranged GETs, so that each chunk can be fetched, and refetched on retry, on its own
+type rangeReader struct {
+	p    *provider
+	ctx  context.Context
+	u    *url.URL
+	size int64
+}
+
+func (r *rangeReader) ReadAt(b []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	want := int64(len(b))
+	if off+want > r.size {
+		want = r.size - off
+	}
+
+	req, err := r.p.newRequest(r.ctx, http.MethodGet, r.u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+want-1))
+	resp, err := r.p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server sent the whole file, which only helps if we wanted the start of it
+		if off != 0 {
+			return 0, fmt.Errorf("GET %s: the server doesn't support range requests", req.URL)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF // the file has shrunk since it was listed
+	default:
+		return 0, &statusError{method: req.Method, url: req.URL.String(), status: resp.Status, code: resp.StatusCode}
+	}
+
+	n, err := io.ReadFull(resp.Body, b[:want])
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err == nil && want < int64(len(b)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Close() error {
+	return nil
+}
@@ -0,0 +1,270 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+var testModTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeServer serves an in-memory tree of files over WebDAV. infinity decides what it does with Depth: infinity.
+type fakeServer struct {
+	files    map[string]string // by path, under /dav
+	infinity string            // "honour", "refuse" or "cap"
+	depths   []string          // the depth of each PROPFIND, in order
+}
+
+func (s *fakeServer) isDir(p string) bool {
+	if p == "/dav" {
+		return true
+	}
+	for f := range s.files {
+		if strings.HasPrefix(f, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := cleanPath(r.URL.Path)
+	content, isFile := s.files[p]
+	if !isFile && !s.isDir(p) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !isFile {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.ServeContent(w, r, path.Base(p), testModTime, strings.NewReader(content))
+
+	case "PROPFIND":
+		depth := r.Header.Get("Depth")
+		s.depths = append(s.depths, depth)
+		maxLevels := map[string]int{"0": 0, "1": 1, "infinity": 1000}[depth]
+		if depth == "infinity" {
+			switch s.infinity {
+			case "refuse":
+				w.WriteHeader(http.StatusForbidden)
+				return
+			case "cap":
+				maxLevels = 1
+			}
+		}
+
+		// every file and collection within maxLevels of p, including p itself
+		paths := map[string]bool{p: true}
+		for f := range s.files {
+			rel, ok := relativePath(p, f)
+			if !ok {
+				continue
+			}
+			parts := strings.Split(rel, "/")
+			for i := 1; i <= len(parts) && i <= maxLevels; i++ {
+				paths[p+"/"+strings.Join(parts[:i], "/")] = true
+			}
+		}
+		sorted := make([]string, 0, len(paths))
+		for q := range paths {
+			sorted = append(sorted, q)
+		}
+		sort.Strings(sorted)
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">`)
+		for _, q := range sorted {
+			href := (&url.URL{Path: q}).EscapedPath()
+			if f, ok := s.files[q]; ok {
+				fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype/>`+
+					`<d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>%s</d:getlastmodified>`+
+					`<d:getcontenttype>text/plain</d:getcontenttype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+					href, len(f), testModTime.Format(http.TimeFormat))
+			} else {
+				// collections have no length or type, which the server reports in a propstat of their own
+				fmt.Fprintf(w, `<d:response><d:href>%s/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype>`+
+					`<d:getlastmodified>%s</d:getlastmodified></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>`+
+					`<d:propstat><d:prop><d:getcontentlength/><d:getcontenttype/></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>`,
+					href, testModTime.Format(http.TimeFormat))
+			}
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestServer(t *testing.T, infinity string) (*fakeServer, *provider, *url.URL) {
+	s := &fakeServer{
+		files: map[string]string{
+			"/dav/top.txt":            "top",
+			"/dav/docs/a.txt":         "aaaa",
+			"/dav/docs/deep/b c.txt":  "bbbbbb",
+			"/dav/docs/deep/deeper/c": "c",
+			"/dav/photos/d.jpg":       "dddddddd",
+		},
+		infinity: infinity,
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	root, err := url.Parse(srv.URL + "/dav/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.Scheme = "webdav"
+	return s, &provider{client: srv.Client()}, root
+}
+
+func enumerate(a *assert.Assertions, p *provider, root *url.URL, recursive bool) map[string]common.ProviderObject {
+	found := map[string]common.ProviderObject{}
+	err := p.Enumerate(context.Background(), root, recursive, func(obj common.ProviderObject) error {
+		a.NotContains(found, obj.RelativePath, "listed twice")
+		found[obj.RelativePath] = obj
+		return nil
+	})
+	a.NoError(err)
+	return found
+}
+
+func fileNames(objs map[string]common.ProviderObject) []string {
+	var names []string
+	for name, obj := range objs {
+		if !obj.IsDirectory {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var allFiles = []string{"docs/a.txt", "docs/deep/b c.txt", "docs/deep/deeper/c", "photos/d.jpg", "top.txt"}
+
+func TestEnumerateWithDepthInfinity(t *testing.T) {
+	a := assert.New(t)
+	s, p, root := newTestServer(t, "honour")
+
+	found := enumerate(a, p, root, true)
+	a.Equal(allFiles, fileNames(found))
+	a.Equal([]string{"infinity"}, s.depths) // one request was enough
+
+	a.True(found["docs/deep"].IsDirectory)
+	b := found["docs/deep/b c.txt"]
+	a.Equal(int64(6), b.Size)
+	a.Equal("text/plain", b.ContentType)
+	a.True(testModTime.Equal(b.LastModified))
+}
+
+func TestEnumerateWhenDepthInfinityIsRefused(t *testing.T) {
+	a := assert.New(t)
+	s, p, root := newTestServer(t, "refuse")
+
+	a.Equal(allFiles, fileNames(enumerate(a, p, root, true)))
+	a.Equal("infinity", s.depths[0])
+	for _, depth := range s.depths[1:] {
+		a.Equal("1", depth)
+	}
+}
+
+func TestEnumerateWhenDepthInfinityIsCapped(t *testing.T) {
+	a := assert.New(t)
+	s, p, root := newTestServer(t, "cap")
+
+	// the server answers Depth: infinity as though it were Depth: 1, as Nextcloud does, so we have to walk the tree
+	a.Equal(allFiles, fileNames(enumerate(a, p, root, true)))
+	a.Greater(len(s.depths), 1)
+}
+
+func TestEnumerateNonRecursive(t *testing.T) {
+	a := assert.New(t)
+	s, p, root := newTestServer(t, "honour")
+
+	found := enumerate(a, p, root, false)
+	a.Equal([]string{"top.txt"}, fileNames(found))
+	a.True(found["docs"].IsDirectory)
+	a.Equal([]string{"1"}, s.depths)
+}
+
+func TestEnumerateSingleFile(t *testing.T) {
+	a := assert.New(t)
+	_, p, root := newTestServer(t, "honour")
+
+	file := *root
+	file.Path = "/dav/docs/a.txt"
+	found := enumerate(a, p, &file, true)
+	a.Len(found, 1)
+	a.Equal(int64(4), found[""].Size)
+}
+
+func TestProperties(t *testing.T) {
+	a := assert.New(t)
+	_, p, root := newTestServer(t, "honour")
+	ctx := context.Background()
+
+	u := *root
+	u.Path = "/dav/photos/d.jpg"
+	obj, err := p.Properties(ctx, &u)
+	a.NoError(err)
+	a.False(obj.IsDirectory)
+	a.Equal(int64(8), obj.Size)
+
+	u.Path = "/dav/photos"
+	obj, err = p.Properties(ctx, &u)
+	a.NoError(err)
+	a.True(obj.IsDirectory)
+
+	u.Path = "/dav/missing.txt"
+	_, err = p.Properties(ctx, &u)
+	a.True(errors.Is(err, common.ErrProviderObjectNotFound))
+}
+
+func TestRangedReads(t *testing.T) {
+	a := assert.New(t)
+	_, p, root := newTestServer(t, "honour")
+
+	u := *root
+	u.Path = "/dav/photos/d.jpg"
+	r, err := p.Open(context.Background(), &u)
+	a.NoError(err)
+	defer r.Close()
+
+	// each chunk is fetched on its own, so any of them can be fetched again after a failure
+	b := make([]byte, 3)
+	n, err := r.ReadAt(b, 2)
+	a.NoError(err)
+	a.Equal("ddd", string(b[:n]))
+
+	n, err = r.ReadAt(b, 6)
+	a.Equal(io.EOF, err)
+	a.Equal(2, n)
+
+	_, err = r.ReadAt(b, 8)
+	a.Equal(io.EOF, err)
+
+	all, err := io.ReadAll(io.NewSectionReader(r, 0, 8))
+	a.NoError(err)
+	a.Equal("dddddddd", string(all))
+
+	u.Path = "/dav/photos"
+	_, err = p.Open(context.Background(), &u)
+	a.Error(err)
+}